@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Placeholders in the command lines of custom actions.
+const (
+	placeholderFile       = "{file}"
+	placeholderGroupFiles = "{group_files}"
+	placeholderDir        = "{dir}"
+)
+
+// GroupAction is a command from the actions list of the config file, run on a
+// file of a group from the TUI's action menu, such as opening the file in
+// another application or showing its git log.
+type GroupAction struct {
+	Name string
+	argv []string
+}
+
+// NewGroupActions parses the actions of the config file, in order.
+func NewGroupActions(configs []ActionConfig) ([]GroupAction, error) {
+	var actions []GroupAction
+	for i, c := range configs {
+		if strings.TrimSpace(c.Name) == "" {
+			return nil, fmt.Errorf("action %d has no name", i+1)
+		}
+		argv, err := splitCommandLine(c.Command)
+		if err != nil {
+			return nil, fmt.Errorf("action %q: %w", c.Name, err)
+		}
+		if len(argv) == 0 {
+			return nil, fmt.Errorf("action %q has no command", c.Name)
+		}
+		actions = append(actions, GroupAction{Name: c.Name, argv: argv})
+	}
+	return actions, nil
+}
+
+// Command builds the command that runs the action on file, a file of group.
+// {file} is replaced by the absolute path of file and {dir} by its directory,
+// where the command also runs. An argument that is just {group_files} becomes
+// one argument per file of the group; within a longer argument the paths are
+// joined by spaces.
+func (a GroupAction) Command(file string, group []string) *exec.Cmd {
+	file = absPath(file)
+	dir := filepath.Dir(file)
+	paths := make([]string, len(group))
+	for i, f := range group {
+		paths[i] = absPath(f)
+	}
+
+	var argv []string
+	for _, arg := range a.argv {
+		if arg == placeholderGroupFiles {
+			argv = append(argv, paths...)
+			continue
+		}
+		arg = strings.ReplaceAll(arg, placeholderFile, file)
+		arg = strings.ReplaceAll(arg, placeholderDir, dir)
+		arg = strings.ReplaceAll(arg, placeholderGroupFiles, strings.Join(paths, " "))
+		argv = append(argv, arg)
+	}
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Dir = dir
+	return logCommand(cmd)
+}
+
+// describeActionResult returns a status line describing how an action exited.
+func describeActionResult(name string, err error) string {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return fmt.Sprintf("%s exited with status %d", name, exitErr.ExitCode())
+	}
+	if err != nil {
+		return fmt.Sprintf("%s failed: %v", name, err)
+	}
+	return fmt.Sprintf("Ran %s", name)
+}
+
+// actionMenu is the menu of custom actions that A opens for file, a file of
+// group; cursor is the highlighted action.
+type actionMenu struct {
+	file   string
+	group  []string
+	cursor int
+}
+
+// renderActionMenu renders the action menu with lines cut to width. Actions
+// are numbered so the first nine can be run with their digit.
+func renderActionMenu(menu *actionMenu, actions []GroupAction, name string, width int) string {
+	var s strings.Builder
+	s.WriteString(titleStyle.Render("Actions for " + name))
+	s.WriteString("\n\n")
+	for i, action := range actions {
+		prefix, style := "  ", normalStyle
+		if i == menu.cursor {
+			prefix, style = "> ", selectedStyle
+		}
+		number := " "
+		if i < 9 {
+			number = fmt.Sprint(i + 1)
+		}
+		s.WriteString(style.Render(fitWidth(fmt.Sprintf("%s%s  %s", prefix, number, action.Name), width)))
+		s.WriteString("\n")
+	}
+	s.WriteString("\n")
+	s.WriteString(helpStyle.Render(fitWidth("↑/↓: navigate  Enter or 1-9: run  Esc: close", width)))
+	return s.String()
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestNewGroupActions tests that actions without a name or command are refused.
+func TestNewGroupActions(t *testing.T) {
+	actions, err := NewGroupActions([]ActionConfig{
+		{Name: "Open in Obsidian", Command: "open 'obsidian://open?path={file}'"},
+		{Name: "git log", Command: "git log --follow {file}"},
+	})
+	if err != nil {
+		t.Fatalf("NewGroupActions() returned error: %v", err)
+	}
+	if len(actions) != 2 || actions[0].Name != "Open in Obsidian" {
+		t.Errorf("NewGroupActions() = %+v", actions)
+	}
+
+	for _, c := range []ActionConfig{
+		{Name: "", Command: "true"},
+		{Name: "empty", Command: "  "},
+		{Name: "unterminated", Command: "echo 'oops"},
+	} {
+		if _, err := NewGroupActions([]ActionConfig{c}); err == nil {
+			t.Errorf("NewGroupActions(%+v) should return an error", c)
+		}
+	}
+}
+
+// TestGroupAction_Command tests the substitution of the placeholders.
+func TestGroupAction_Command(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	file := createFileWithContent(t, tmpDir, "notes.md", "one\n")
+	other := createFileWithContent(t, tmpDir, "notes 2.md", "two\n")
+
+	actions, err := NewGroupActions([]ActionConfig{
+		{Name: "all", Command: "tool --in {dir} {file} -- {group_files}"},
+		{Name: "joined", Command: "sh -c 'echo {group_files}'"},
+	})
+	if err != nil {
+		t.Fatalf("NewGroupActions() returned error: %v", err)
+	}
+
+	cmd := actions[0].Command(file, []string{file, other})
+	want := []string{"tool", "--in", tmpDir, file, "--", file, other}
+	if !reflect.DeepEqual(cmd.Args, want) {
+		t.Errorf("Args = %q, expected %q", cmd.Args, want)
+	}
+	if cmd.Dir != filepath.Dir(file) {
+		t.Errorf("Dir = %q, expected the file's directory", cmd.Dir)
+	}
+
+	cmd = actions[1].Command(file, []string{file, other})
+	if got := cmd.Args[len(cmd.Args)-1]; got != "echo "+file+" "+other {
+		t.Errorf("last argument = %q, expected the paths joined by spaces", got)
+	}
+}
+
+// TestDescribeActionResult tests the status line after an action exits.
+func TestDescribeActionResult(t *testing.T) {
+	if got := describeActionResult("git log", nil); got != "Ran git log" {
+		t.Errorf("describeActionResult() = %q", got)
+	}
+	actions, err := NewGroupActions([]ActionConfig{{Name: "fail", Command: "sh -c 'exit 3'"}})
+	if err != nil {
+		t.Fatalf("NewGroupActions() returned error: %v", err)
+	}
+	err = actions[0].Command(".", nil).Run()
+	if got := describeActionResult("fail", err); got != "fail exited with status 3" {
+		t.Errorf("describeActionResult() = %q, expected the exit status", got)
+	}
+}
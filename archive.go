@@ -0,0 +1,265 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// archiveSeparator separates the path of an archive from the name of a file
+// inside it in the virtual paths --scan-archives lists, such as
+// backup.zip!/notes/todo.md.
+const archiveSeparator = "!/"
+
+// archiveMemberRefusal is the status shown when an action would change a file
+// inside an archive.
+const archiveMemberRefusal = "Files inside archives can't be deleted, linked, renamed, or moved"
+
+// isArchive reports whether the file at path is scanned as an archive with
+// --scan-archives.
+func isArchive(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".zip")
+}
+
+// splitArchivePath splits the virtual path of a file inside an archive into
+// the path of the archive and the name of the file within it, and returns
+// false for any other path.
+func splitArchivePath(p string) (archive, member string, ok bool) {
+	i := strings.Index(strings.ToLower(p), ".zip"+archiveSeparator)
+	if i < 0 {
+		return "", "", false
+	}
+	end := i + len(".zip")
+	return p[:end], p[end+len(archiveSeparator):], true
+}
+
+// isArchiveMember reports whether p is the virtual path of a file inside an archive.
+func isArchiveMember(p string) bool {
+	_, _, ok := splitArchivePath(p)
+	return ok
+}
+
+// archiveMembers returns the files inside the zip archive at archive, with
+// their virtual paths and info, leaving out directories.
+func archiveMembers(archive string) ([]string, []os.FileInfo, error) {
+	r, err := zip.OpenReader(archive)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer r.Close()
+
+	var paths []string
+	var infos []os.FileInfo
+	for _, f := range r.File {
+		if strings.HasSuffix(f.Name, "/") || !f.Mode().IsRegular() {
+			continue
+		}
+		paths = append(paths, archive+archiveSeparator+f.Name)
+		infos = append(infos, f.FileInfo())
+	}
+	return paths, infos, nil
+}
+
+// archiveListing lists the files inside the zip archive at archive, one per
+// line with its size and CRC-32 checksum, sorted by name.
+func archiveListing(archive string) (string, error) {
+	r, err := zip.OpenReader(archive)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	var lines []string
+	for _, f := range r.File {
+		if strings.HasSuffix(f.Name, "/") {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s  %s  crc32 %08x", f.Name, formatBytes(int64(f.UncompressedSize64)), f.CRC32))
+	}
+	slices.Sort(lines)
+	return strings.Join(lines, "\n"), nil
+}
+
+// scanArchive lists the files inside the archive at archive like readDir lists
+// a directory, applying the hidden and filter checks to each. An archive that
+// can't be read is left as a plain file.
+func (s *Scanner) scanArchive(archive string) []string {
+	paths, infos, err := archiveMembers(archive)
+	if err != nil {
+		logger.Debug("not scanned as an archive", "path", archive, "err", err)
+		return nil
+	}
+	var files []string
+	for i, p := range paths {
+		_, member, _ := splitArchivePath(p)
+		parts := strings.Split(member, "/")
+		if !s.includeHidden && slices.ContainsFunc(parts, isHidden) {
+			logger.Debug("skipped hidden file", "path", p)
+			continue
+		}
+		info := infos[i]
+		skip, _ := s.skipReason(path.Base(member), func() (os.FileInfo, error) { return info, nil })
+		if skip != "" {
+			logger.Debug("skipped file", "path", p, "reason", skip)
+			continue
+		}
+		files = append(files, p)
+		s.progress.AddFiles(1)
+	}
+	return files
+}
+
+// openArchiveMember opens the file inside an archive at the virtual path p.
+func openArchiveMember(p string) (io.ReadCloser, os.FileInfo, error) {
+	archive, member, ok := splitArchivePath(p)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s is not inside an archive", p)
+	}
+	r, err := zip.OpenReader(archive)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, f := range r.File {
+		if f.Name != member {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			r.Close()
+			return nil, nil, err
+		}
+		return archiveMemberReader{rc, r}, f.FileInfo(), nil
+	}
+	r.Close()
+	return nil, nil, &os.PathError{Op: "open", Path: p, Err: os.ErrNotExist}
+}
+
+// archiveMemberReader reads a file inside an archive and closes the archive with it.
+type archiveMemberReader struct {
+	io.ReadCloser
+	archive *zip.ReadCloser
+}
+
+// Close closes the file and its archive.
+func (r archiveMemberReader) Close() error {
+	return errors.Join(r.ReadCloser.Close(), r.archive.Close())
+}
+
+// statArchiveMember returns the info of the file inside an archive at the virtual path p.
+func statArchiveMember(p string) (os.FileInfo, error) {
+	r, info, err := openArchiveMember(p)
+	if err != nil {
+		return nil, err
+	}
+	r.Close()
+	return info, nil
+}
+
+// hashArchiveMember returns the hex-encoded SHA-256 of a file inside an
+// archive like hashFile, decompressing it as it goes.
+func hashArchiveMember(ctx context.Context, p string, progress *Progress) (string, error) {
+	r, _, err := openArchiveMember(p)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, contextReader{ctx, r})
+	progress.AddBytes(n)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ArchiveFiles extracts files inside archives to temporary files on demand,
+// so they can be diffed and previewed like any other file. A nil
+// *ArchiveFiles extracts nothing.
+type ArchiveFiles struct {
+	tempDir string
+	// local maps virtual paths to their extracted files, and virtual the
+	// other way around.
+	local   map[string]string
+	virtual map[string]string
+}
+
+// NewArchiveFiles returns an ArchiveFiles that extracts nothing until asked.
+func NewArchiveFiles() *ArchiveFiles {
+	return &ArchiveFiles{local: make(map[string]string), virtual: make(map[string]string)}
+}
+
+// Local returns a file holding the content at p: for a file inside an
+// archive, the file it is extracted to, once, keeping its name; for any other
+// path, p itself.
+func (a *ArchiveFiles) Local(p string) (string, error) {
+	if a == nil || !isArchiveMember(p) {
+		return p, nil
+	}
+	if local, ok := a.local[p]; ok {
+		return local, nil
+	}
+	r, _, err := openArchiveMember(p)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	if a.tempDir == "" {
+		if a.tempDir, err = os.MkdirTemp("", "doppel-archive-"); err != nil {
+			return "", err
+		}
+	}
+	// Files of the same name in different archives each get a directory
+	dir, err := os.MkdirTemp(a.tempDir, "")
+	if err != nil {
+		return "", err
+	}
+	local := filepath.Join(dir, path.Base(p))
+	f, err := os.OpenFile(local, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return "", fmt.Errorf("failed to extract %s: %w", p, err)
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	a.local[p], a.virtual[local] = local, p
+	return local, nil
+}
+
+// Virtual returns the virtual path of the file inside an archive that local
+// was extracted to, or local itself if it wasn't.
+func (a *ArchiveFiles) Virtual(local string) string {
+	if a != nil {
+		if p, ok := a.virtual[local]; ok {
+			return p
+		}
+	}
+	return local
+}
+
+// Extracted reports whether local is a file extracted from an archive.
+func (a *ArchiveFiles) Extracted(local string) bool {
+	return a.Virtual(local) != local
+}
+
+// Cleanup removes the extracted files.
+func (a *ArchiveFiles) Cleanup() error {
+	if a == nil || a.tempDir == "" {
+		return nil
+	}
+	return os.RemoveAll(a.tempDir)
+}
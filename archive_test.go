@@ -0,0 +1,162 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// createZip writes a zip archive named name in dir holding files, by name,
+// and returns its path.
+func createZip(t *testing.T, dir, name string, files map[string]string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestSplitArchivePath tests telling virtual paths inside archives from other paths.
+func TestSplitArchivePath(t *testing.T) {
+	tests := []struct {
+		path, archive, member string
+		ok                    bool
+	}{
+		{"/notes/backup.zip!/todo.md", "/notes/backup.zip", "todo.md", true},
+		{"backup.ZIP!/2024/todo.md", "backup.ZIP", "2024/todo.md", true},
+		{"/notes/backup.zip", "", "", false},
+		{"/notes/wow!/todo.md", "", "", false},
+	}
+	for _, tt := range tests {
+		archive, member, ok := splitArchivePath(tt.path)
+		if archive != tt.archive || member != tt.member || ok != tt.ok {
+			t.Errorf("splitArchivePath(%q) = %q, %q, %v; expected %q, %q, %v", tt.path, archive, member, ok, tt.archive, tt.member, tt.ok)
+		}
+	}
+}
+
+// TestScanner_Scan_Archives tests listing the files inside zip archives.
+func TestScanner_Scan_Archives(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	notes := createFileWithContent(t, tmpDir, "notes.md", "one")
+	backup := createZip(t, tmpDir, "backup.zip", map[string]string{
+		"notes.md":        "one",
+		"2024/todo.md":    "todo",
+		"2024/":           "",
+		".hidden/keys.md": "secret",
+		"photo.jpg":       "photo",
+	})
+	createFileWithContent(t, tmpDir, "broken.zip", "not a zip")
+
+	scanner := NewScanner(tmpDir)
+	scanner.SetExtensions([]string{"md", "zip"})
+	files, err := scanner.Scan()
+	if err != nil {
+		t.Fatalf("Scan() returned error: %v", err)
+	}
+	if len(files) != 3 {
+		t.Errorf("without scan-archives, only the archives and notes.md should be scanned, got %v", files)
+	}
+
+	scanner.SetScanArchives(true)
+	scanner.SetExtensions([]string{"md"})
+	files, err = scanner.Scan()
+	if err != nil {
+		t.Fatalf("Scan() returned error: %v", err)
+	}
+	want := map[string]bool{
+		backup + "!/notes.md":     true,
+		backup + "!/2024/todo.md": true,
+		notes:                     true,
+	}
+	got := make(map[string]bool)
+	for _, f := range files {
+		got[f] = true
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Scan() = %v, expected %v", files, want)
+	}
+}
+
+// TestArchiveFiles tests extracting files inside archives on demand.
+func TestArchiveFiles(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	backup := createZip(t, tmpDir, "backup.zip", map[string]string{"2024/todo.md": "todo"})
+	member := backup + "!/2024/todo.md"
+
+	archives := NewArchiveFiles()
+	local, err := archives.Local(member)
+	if err != nil {
+		t.Fatalf("Local() returned error: %v", err)
+	}
+	if filepath.Base(local) != "todo.md" {
+		t.Errorf("Local() = %s, expected a file named todo.md", local)
+	}
+	if data, err := os.ReadFile(local); err != nil || string(data) != "todo" {
+		t.Errorf("extracted file = %q, %v", data, err)
+	}
+	if again, _ := archives.Local(member); again != local {
+		t.Errorf("a file should be extracted once, got %s and %s", local, again)
+	}
+	if archives.Virtual(local) != member || !archives.Extracted(local) {
+		t.Errorf("Virtual(%s) = %s, expected %s", local, archives.Virtual(local), member)
+	}
+
+	other := filepath.Join(tmpDir, "notes.md")
+	if got, err := archives.Local(other); err != nil || got != other {
+		t.Errorf("Local() of a plain file = %s, %v; expected it unchanged", got, err)
+	}
+	if _, err := archives.Local(backup + "!/gone.md"); !os.IsNotExist(err) {
+		t.Errorf("Local() of a missing file = %v, expected not found", err)
+	}
+
+	if err := archives.Cleanup(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(local); !os.IsNotExist(err) {
+		t.Errorf("Cleanup() should remove the extracted files")
+	}
+}
+
+// TestBuildFileRecords_Archives tests reporting a file and its copy in an archive.
+func TestBuildFileRecords_Archives(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	notes := createFileWithContent(t, tmpDir, "notes.md", "one")
+	backup := createZip(t, tmpDir, "backup.zip", map[string]string{"notes.md": "one", "notes 2.md": "two"})
+
+	group := []string{notes, backup + "!/notes.md", backup + "!/notes 2.md"}
+	records, err := buildFileRecords(context.Background(), [][]string{group}, nil, nil)
+	if err != nil {
+		t.Fatalf("buildFileRecords() returned error: %v", err)
+	}
+	if len(records) != 3 || !records[1].IdenticalToLeader || records[2].IdenticalToLeader || records[1].HardlinkToLeader {
+		t.Errorf("unexpected records: %+v", records)
+	}
+	if records[1].Size != 3 {
+		t.Errorf("the size of a file inside an archive should be its uncompressed size, got %d", records[1].Size)
+	}
+}
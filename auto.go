@@ -0,0 +1,212 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// dirProfile is a set of flag defaults for a common type of directory, applied
+// when the directory looks like one unless --no-auto is given.
+type dirProfile struct {
+	name string
+	// detect reports whether dir is of the profile's type.
+	detect func(dir string) bool
+	// flags maps flag names to the values the profile sets, in order.
+	flags [][2]string
+}
+
+// dirProfiles are the profiles detected, in the order they are applied.
+var dirProfiles = []dirProfile{
+	{
+		// Attachments named after their note, such as Trip.md and Trip.png,
+		// aren't copies of it, and notes live in nested folders.
+		name:   "Obsidian vault",
+		detect: isObsidianVault,
+		flags:  [][2]string{{"recursive", "true"}, {"same-ext-only", "true"}},
+	},
+	{
+		// Cameras number photos, so IMG_1234.jpg and IMG_1235.jpg share a long
+		// prefix but are different shots; a copy keeps the whole name, and a
+		// RAW file and its JPEG are a pair rather than copies.
+		name:   "photo library",
+		detect: isPhotoLibrary,
+		flags:  [][2]string{{"recursive", "true"}, {"strip-ext", "true"}, {"same-ext-only", "true"}, {"prefix-ratio", "1"}},
+	},
+	{
+		// Build output and dependencies look like copies of the sources.
+		name:   "git repository",
+		detect: isGitRepository,
+		flags:  [][2]string{{"skip-git-ignored", "true"}},
+	},
+}
+
+// isObsidianVault reports whether dir is the root of an Obsidian vault, which
+// keeps its settings in .obsidian.
+func isObsidianVault(dir string) bool {
+	info, err := os.Stat(filepath.Join(dir, ".obsidian"))
+	return err == nil && info.IsDir()
+}
+
+// yearDir matches the names of the year folders photo libraries are often
+// sorted into.
+var yearDir = regexp.MustCompile(`^(19|20)\d\d$`)
+
+// isPhotoLibrary reports whether dir is laid out like a photo library: a
+// camera's DCIM folder, a folder holding one, or a folder of at least two
+// year folders such as 2023 and 2024.
+func isPhotoLibrary(dir string) bool {
+	if strings.EqualFold(filepath.Base(absPath(dir)), "DCIM") {
+		return true
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+	years := 0
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if strings.EqualFold(e.Name(), "DCIM") {
+			return true
+		}
+		if yearDir.MatchString(e.Name()) {
+			years++
+		}
+	}
+	return years >= 2
+}
+
+// isGitRepository reports whether dir is inside a git repository. Without
+// git installed, no directory is.
+func isGitRepository(dir string) bool {
+	repo, err := FindGitRepo(dir)
+	return err == nil && repo != nil
+}
+
+// flagProfile is a named set of flag values applied to a scan.
+type flagProfile struct {
+	name  string
+	flags [][2]string
+	// auto is set for the profiles applied without --profile, which --no-auto
+	// turns off.
+	auto bool
+}
+
+// scanProfiles returns the profiles for a scan of dir, in order of precedence:
+// the profile of config named with --profile, if any, then with auto set the
+// entries of the config's paths list that dir is under, each before the
+// profile it names, and the dirProfiles dir matches.
+func scanProfiles(config *Config, dir, name string, auto bool) ([]flagProfile, error) {
+	named := func(name string) (flagProfile, error) {
+		p, ok := config.Profiles[name]
+		if !ok {
+			return flagProfile{}, fmt.Errorf("unknown profile %q; add it to the profiles of the config file", name)
+		}
+		flags, err := p.flagValues()
+		if err != nil {
+			return flagProfile{}, fmt.Errorf("invalid profile %q: %w", name, err)
+		}
+		return flagProfile{name: name, flags: flags}, nil
+	}
+
+	var profiles []flagProfile
+	if name != "" {
+		p, err := named(name)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, p)
+	}
+	if !auto {
+		return profiles, nil
+	}
+	for _, pc := range config.pathConfigs(dir) {
+		flags, err := pc.Flags.flagValues()
+		if err != nil {
+			return nil, fmt.Errorf("invalid config for %s: %w", pc.Path, err)
+		}
+		if len(flags) > 0 {
+			profiles = append(profiles, flagProfile{name: pc.Path, flags: flags, auto: true})
+		}
+		if pc.Profile != "" {
+			p, err := named(pc.Profile)
+			if err != nil {
+				return nil, fmt.Errorf("invalid config for %s: %w", pc.Path, err)
+			}
+			p.auto = true
+			profiles = append(profiles, p)
+		}
+	}
+	for _, p := range dirProfiles {
+		if p.detect(dir) {
+			profiles = append(profiles, flagProfile{name: p.name, flags: p.flags, auto: true})
+		}
+	}
+	return profiles, nil
+}
+
+// applyProfiles sets the flag values of profiles on fs. A flag given on the
+// command line or set by an earlier profile is left alone, and flags fs
+// doesn't have, such as diff-tool for scan, are skipped. It writes the
+// profiles and the flags they set to w.
+func applyProfiles(w io.Writer, fs *flag.FlagSet, profiles []flagProfile) error {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	var names, applied []string
+	auto := false
+	for _, p := range profiles {
+		names = append(names, p.name)
+		mine := make(map[string]bool)
+		for _, kv := range p.flags {
+			f := fs.Lookup(kv[0])
+			if f == nil {
+				logger.Debug("skipped a flag of a profile", "profile", p.name, "flag", kv[0], "reason", "the command has no such flag")
+				continue
+			}
+			if set[kv[0]] {
+				continue
+			}
+			if !mine[kv[0]] && f.Value.String() == kv[1] {
+				// Already the value, but later profiles mustn't change it
+				mine[kv[0]] = true
+				continue
+			}
+			if err := fs.Set(kv[0], kv[1]); err != nil {
+				return fmt.Errorf("invalid profile %q: --%s: %w", p.name, kv[0], err)
+			}
+			mine[kv[0]] = true
+			applied = append(applied, flagArg(kv[0], kv[1]))
+			auto = auto || p.auto
+		}
+		for name := range mine {
+			set[name] = true
+		}
+	}
+	if len(names) > 0 {
+		logger.Info("applied profiles", "profiles", strings.Join(names, ", "), "flags", strings.Join(applied, " "))
+	}
+	if len(applied) > 0 {
+		note := fmt.Sprintf("Profile: %s (%s)", strings.Join(names, ", "), strings.Join(applied, " "))
+		if auto {
+			note += "; turn off with --no-auto"
+		}
+		fmt.Fprintln(w, note)
+	}
+	return nil
+}
+
+// flagArg returns a flag as it is written on the command line: --name for a
+// true boolean, --name=value otherwise.
+func flagArg(name, value string) string {
+	if value == "true" {
+		return "--" + name
+	}
+	return "--" + name + "=" + value
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestDirProfiles_Detect tests recognizing vaults and photo libraries.
+func TestDirProfiles_Detect(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	mkdir := func(parts ...string) string {
+		dir := filepath.Join(append([]string{tmpDir}, parts...)...)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		return dir
+	}
+	vault := mkdir("vault", ".obsidian")
+	camera := mkdir("card", "DCIM")
+	mkdir("pictures", "2023")
+	mkdir("pictures", "2024")
+	mkdir("notes", "2024")
+
+	tests := []struct {
+		dir            string
+		vault, library bool
+	}{
+		{filepath.Dir(vault), true, false},
+		{filepath.Dir(camera), false, true},
+		{camera, false, true},
+		{filepath.Join(tmpDir, "pictures"), false, true},
+		{filepath.Join(tmpDir, "notes"), false, false},
+	}
+	for _, tt := range tests {
+		if got := isObsidianVault(tt.dir); got != tt.vault {
+			t.Errorf("isObsidianVault(%s) = %v, expected %v", tt.dir, got, tt.vault)
+		}
+		if got := isPhotoLibrary(tt.dir); got != tt.library {
+			t.Errorf("isPhotoLibrary(%s) = %v, expected %v", tt.dir, got, tt.library)
+		}
+	}
+}
+
+// TestApplyDirProfiles tests that a profile sets its flags, except those
+// given on the command line, and says what it set.
+func TestApplyDirProfiles(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	if err := os.Mkdir(filepath.Join(tmpDir, ".obsidian"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := flag.NewFlagSet("scan", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	mf := addMatchFlags(fs)
+	if err := fs.Parse([]string{"--same-ext-only=false", tmpDir}); err != nil {
+		t.Fatal(err)
+	}
+	profiles, err := scanProfiles(&Config{}, tmpDir, "", true)
+	if err != nil || len(profiles) != 1 || profiles[0].name != "Obsidian vault" {
+		t.Fatalf("scanProfiles() = %v, %v, expected the Obsidian vault profile", profiles, err)
+	}
+	var out bytes.Buffer
+	if err := applyProfiles(&out, fs, profiles); err != nil {
+		t.Fatalf("applyProfiles() returned error: %v", err)
+	}
+	if !*mf.recursive || *mf.sameExtOnly {
+		t.Errorf("recursive = %v, same-ext-only = %v, expected the profile to set only recursive", *mf.recursive, *mf.sameExtOnly)
+	}
+	if expected := "Profile: Obsidian vault (--recursive); turn off with --no-auto\n"; out.String() != expected {
+		t.Errorf("applyProfiles() wrote %q, expected %q", out.String(), expected)
+	}
+}
+
+// TestScanProfiles_Config tests the precedence of --profile, the paths of the
+// config file, and the detected profiles, and that lists set repeated flags.
+func TestScanProfiles_Config(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	vault := filepath.Join(tmpDir, "vault")
+	if err := os.MkdirAll(filepath.Join(vault, ".obsidian"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{
+		Profiles: map[string]ProfileConfig{
+			"notes":  {"ext": []any{"md", "txt"}, "min-prefix": float64(5)},
+			"strict": {"prefix-ratio": 0.8, "same-ext-only": false},
+		},
+		Paths: []PathConfig{
+			{Path: tmpDir, Flags: ProfileConfig{"min-prefix": float64(4), "exclude": "*.tmp"}},
+			{Path: vault, Profile: "notes", Flags: ProfileConfig{"suffix": ` \d+`}},
+		},
+	}
+
+	profiles, err := scanProfiles(config, vault, "strict", true)
+	if err != nil {
+		t.Fatalf("scanProfiles() returned error: %v", err)
+	}
+	var names []string
+	for _, p := range profiles {
+		names = append(names, p.name)
+	}
+	if expected := []string{"strict", vault, "notes", tmpDir, "Obsidian vault"}; !reflect.DeepEqual(names, expected) {
+		t.Fatalf("scanProfiles() = %v, expected %v", names, expected)
+	}
+
+	fs := flag.NewFlagSet("scan", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	mf := addMatchFlags(fs)
+	if err := applyProfiles(io.Discard, fs, profiles); err != nil {
+		t.Fatalf("applyProfiles() returned error: %v", err)
+	}
+	if *mf.prefixRatio != 0.8 || *mf.sameExtOnly || *mf.minPrefix != 5 || *mf.suffixPattern != ` \d+` || !*mf.recursive {
+		t.Errorf("prefix-ratio %v, same-ext-only %v, min-prefix %d, suffix %q, recursive %v: expected the values of the most specific profile",
+			*mf.prefixRatio, *mf.sameExtOnly, *mf.minPrefix, *mf.suffixPattern, *mf.recursive)
+	}
+	if got := splitList(mf.extensions); !reflect.DeepEqual(got, []string{"md", "txt"}) {
+		t.Errorf("ext = %v, expected both values of the list", got)
+	}
+	if got := splitList(mf.excludes.stringListFlag); !reflect.DeepEqual(got, []string{"*.tmp"}) {
+		t.Errorf("exclude = %v, expected the value of the parent path", got)
+	}
+
+	// A malformed pattern fails when the profile sets it, not when files are scanned
+	fs = flag.NewFlagSet("scan", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	addMatchFlags(fs)
+	bad := []flagProfile{{name: "broken", flags: [][2]string{{"exclude", "*.tmp,[a-"}}}}
+	if err := applyProfiles(io.Discard, fs, bad); err == nil || !strings.Contains(err.Error(), `--exclude: invalid pattern "[a-"`) {
+		t.Errorf("applyProfiles() = %v, expected the malformed pattern to be reported", err)
+	}
+
+	if _, err := scanProfiles(config, vault, "missing", false); err == nil {
+		t.Error("scanProfiles() should fail for an unknown profile")
+	}
+	if profiles, _ := scanProfiles(config, vault, "", false); len(profiles) != 0 {
+		t.Errorf("scanProfiles() without auto = %v, expected none", profiles)
+	}
+}
+
+// TestRunCommand_NoAuto tests that --no-auto keeps a vault's subfolders out
+// of the scan.
+func TestRunCommand_NoAuto(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	if err := os.MkdirAll(filepath.Join(tmpDir, ".obsidian"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(tmpDir, "Daily"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	createFileWithContent(t, tmpDir, "Trip.md", "notes")
+	createFileWithContent(t, filepath.Join(tmpDir, "Daily"), "Trip (1).md", "notes")
+
+	if code := runCommand([]string{"scan", "--check", "-q", tmpDir}); code != exitGroupsFound {
+		t.Errorf("scan of a vault = %d, expected the profile to scan subfolders and find a group", code)
+	}
+	if code := runCommand([]string{"scan", "--check", "--no-auto", tmpDir}); code != exitNoGroups {
+		t.Errorf("scan --no-auto = %d, expected no groups", code)
+	}
+}
+
+// TestRunCommand_ProfileConflicts tests that flags set by a profile are checked
+// for conflicts like those given on the command line.
+func TestRunCommand_ProfileConflicts(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	createFileWithContent(t, tmpDir, "document.txt", "content")
+	createFileWithContent(t, tmpDir, "document_v2.txt", "content")
+	path := createFileWithContent(t, tmpDir, "config.json", `{"profiles": {"checksums": {"hash": "sha256"}, "spreadsheet": {"csv": true}, "content": {"by-content": true}}}`)
+	t.Setenv(configEnvVar, path)
+
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"hash with print0", []string{"scan", "-q", "--profile", "checksums", "--print0", tmpDir}},
+		{"csv with json", []string{"report", "-q", "--profile", "spreadsheet", "--json", tmpDir}},
+		{"compare by content", []string{"scan", "-q", "--profile", "content", "--compare", tmpDir, tmpDir}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := runCommand(tt.args); code != exitError {
+				t.Errorf("runCommand(%v) = %d, expected the conflict to be reported", tt.args, code)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// Baseline is the set of group fingerprints of an earlier JSON or YAML report, so a
+// run can leave out the groups that were already there and show only what is
+// new since. A nil *Baseline leaves out nothing.
+type Baseline struct {
+	// dir is the directory the report was taken of.
+	dir          string
+	fingerprints map[string]bool
+}
+
+// LoadBaseline reads the report written by "report --json" or "report --yaml"
+// at path.
+func LoadBaseline(path string) (*Baseline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var report jsonReport
+	if err := decodeJSONOrYAML(data, &report); err != nil {
+		return nil, fmt.Errorf("invalid baseline %s: %w", path, err)
+	}
+	if report.Groups == nil {
+		return nil, fmt.Errorf("invalid baseline %s: not a JSON report", path)
+	}
+
+	b := &Baseline{dir: report.Dir, fingerprints: make(map[string]bool)}
+	for _, g := range report.Groups {
+		if g.Fingerprint == "" {
+			return nil, fmt.Errorf("invalid baseline %s: group %d has no fingerprint", path, g.Group)
+		}
+		b.fingerprints[g.Fingerprint] = true
+	}
+	return b, nil
+}
+
+// Dir returns the directory the baseline report was taken of.
+func (b *Baseline) Dir() string {
+	return b.dir
+}
+
+// Filter returns the groups of a scan of dir whose fingerprints are not in
+// the baseline. A group that gained or lost a file since has another
+// fingerprint, so it is kept.
+func (b *Baseline) Filter(dir string, groups [][]string) [][]string {
+	if b == nil {
+		return groups
+	}
+	var result [][]string
+	for _, group := range groups {
+		if !b.fingerprints[groupFingerprint(dir, group)] {
+			result = append(result, group)
+		}
+	}
+	logger.Info("left out groups of the baseline", "groups", len(groups)-len(result))
+	return result
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestBaseline tests leaving out the groups of an earlier JSON report.
+func TestBaseline(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	notes := []string{
+		createFileWithContent(t, tmpDir, "notes.md", "one"),
+		createFileWithContent(t, tmpDir, "notes (1).md", "one"),
+	}
+	todo := []string{
+		createFileWithContent(t, tmpDir, "todo.md", "two"),
+		createFileWithContent(t, tmpDir, "todo-1.md", "two, edited"),
+	}
+
+	records, err := buildFileRecords(context.Background(), [][]string{notes, todo}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reportPath := filepath.Join(tmpDir, "report.json")
+	f, err := os.Create(reportPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeJSONReport(f, tmpDir, records); err != nil {
+		t.Fatalf("writeJSONReport() returned error: %v", err)
+	}
+	f.Close()
+
+	baseline, err := LoadBaseline(reportPath)
+	if err != nil {
+		t.Fatalf("LoadBaseline() returned error: %v", err)
+	}
+	if baseline.Dir() != tmpDir {
+		t.Errorf("Dir() = %s, expected %s", baseline.Dir(), tmpDir)
+	}
+
+	grown := append([]string{createFileWithContent(t, tmpDir, "todo-2.md", "three")}, todo...)
+	fresh := []string{filepath.Join(tmpDir, "plan.md"), filepath.Join(tmpDir, "plan-1.md")}
+	// Scan order doesn't change a group's fingerprint
+	reordered := []string{notes[1], notes[0]}
+	got := baseline.Filter(tmpDir, [][]string{reordered, grown, fresh})
+	if want := [][]string{grown, fresh}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Filter() = %v, expected %v", got, want)
+	}
+
+	var none *Baseline
+	if got := none.Filter(tmpDir, [][]string{notes}); len(got) != 1 {
+		t.Errorf("a nil baseline should leave out nothing, got %v", got)
+	}
+}
+
+// TestLoadBaseline_Invalid tests refusing files that aren't JSON reports.
+func TestLoadBaseline_Invalid(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	for name, content := range map[string]string{
+		"report.csv":     "group,path\n1,notes.md\n",
+		"ignored.json":   `{"ignored": ["af021d6738113403"]}`,
+		"unsigned.json":  `{"dir": "/notes", "groups": [{"group": 1, "files": []}]}`,
+		"truncated.json": `{"dir": "/notes", "groups": [`,
+	} {
+		if _, err := LoadBaseline(createFileWithContent(t, tmpDir, name, content)); err == nil {
+			t.Errorf("LoadBaseline(%s) should fail", name)
+		}
+	}
+	if _, err := LoadBaseline(filepath.Join(tmpDir, "missing.json")); !os.IsNotExist(err) {
+		t.Errorf("LoadBaseline() of a missing file = %v", err)
+	}
+}
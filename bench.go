@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// corpusConfig describes a synthetic tree for benchmarks; see generateCorpus.
+type corpusConfig struct {
+	// files is the number of files, copies included.
+	files int
+	// duplicates is the fraction of files that are copies of an earlier file.
+	duplicates float64
+	// dirs is the number of directories the files are spread over.
+	dirs int
+	// size is the size of each file in bytes.
+	size int
+	// seed makes the tree the same from run to run.
+	seed int64
+}
+
+// corpusNameLetters is the length of the random names of the original files.
+const corpusNameLetters = 12
+
+// generateCorpus writes a tree of cfg.files text files under dir, spread over
+// cfg.dirs subdirectories. Originals get random names of lowercase letters, so
+// they group only by coincidence, and each copy takes the name of an
+// original with a marker such as " (2)" and its content, in any directory.
+// It returns the total size written.
+func generateCorpus(dir string, cfg corpusConfig) (int64, error) {
+	if cfg.files < 1 || cfg.dirs < 1 || cfg.size < 0 || cfg.duplicates < 0 || cfg.duplicates >= 1 {
+		return 0, errors.New("a corpus needs files and directories, and a duplicate ratio from 0 to below 1")
+	}
+	rng := rand.New(rand.NewSource(cfg.seed))
+	for i := 0; i < cfg.dirs; i++ {
+		if err := os.MkdirAll(filepath.Join(dir, fmt.Sprintf("dir%04d", i)), 0755); err != nil {
+			return 0, err
+		}
+	}
+
+	type original struct {
+		name    string
+		content []byte
+		copies  int
+	}
+	var originals []*original
+	var total int64
+	for i := 0; i < cfg.files; i++ {
+		var name string
+		var content []byte
+		if len(originals) > 0 && rng.Float64() < cfg.duplicates {
+			o := originals[rng.Intn(len(originals))]
+			o.copies++
+			name = fmt.Sprintf("%s (%d).txt", o.name, o.copies)
+			content = o.content
+		} else {
+			letters := make([]byte, corpusNameLetters)
+			for j := range letters {
+				letters[j] = byte('a' + rng.Intn(26))
+			}
+			content = corpusText(rng, cfg.size)
+			originals = append(originals, &original{name: string(letters), content: content})
+			name = string(letters) + ".txt"
+		}
+		path := filepath.Join(dir, fmt.Sprintf("dir%04d", rng.Intn(cfg.dirs)), name)
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			return 0, err
+		}
+		total += int64(len(content))
+	}
+	return total, nil
+}
+
+// corpusText returns size bytes of lines of random lowercase words.
+func corpusText(rng *rand.Rand, size int) []byte {
+	text := make([]byte, size)
+	for i := range text {
+		switch n := rng.Intn(40); {
+		case n == 0 || i == size-1:
+			text[i] = '\n'
+		case n < 7:
+			text[i] = ' '
+		default:
+			text[i] = byte('a' + rng.Intn(26))
+		}
+	}
+	return text
+}
+
+// benchTimings are the durations of the phases of one benchmark run.
+type benchTimings struct {
+	scan, group, hash time.Duration
+}
+
+// runBench scans, groups, and hashes the grouped files of opts.dir, the way a
+// report does but without a hash cache, and times each phase. It returns the
+// number of files scanned, the groups, and the grouped files' total size.
+func runBench(ctx context.Context, opts options) (benchTimings, int, [][]string, int64, error) {
+	var t benchTimings
+	start := time.Now()
+	files, err := scanFiles(ctx, opts, opts.dir, nil)
+	if err != nil {
+		return t, 0, nil, 0, err
+	}
+	t.scan = time.Since(start)
+
+	start = time.Now()
+	groups, err := opts.matcher().GroupContext(ctx, files)
+	if err != nil {
+		return t, 0, nil, 0, err
+	}
+	t.group = time.Since(start)
+
+	start = time.Now()
+	var hashed int64
+	for _, group := range groups {
+		for _, file := range group {
+			if _, err := hashFile(ctx, file, nil); err != nil {
+				return t, 0, nil, 0, err
+			}
+			if info, err := os.Stat(file); err == nil {
+				hashed += info.Size()
+			}
+		}
+	}
+	t.hash = time.Since(start)
+	return t, len(files), groups, hashed, nil
+}
+
+// runBenchCommand implements "doppel bench", a command for developers that is
+// left out of the usage text: it generates a synthetic tree and times the
+// scan, group, and hash phases, so changes to them can be measured.
+func runBenchCommand(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: doppel bench [options]\n\n")
+		fmt.Fprintf(fs.Output(), "Generates a tree of synthetic text files and times scanning it, grouping the\n")
+		fmt.Fprintf(fs.Output(), "files, and hashing the grouped files. Each phase shows its fastest run. The\n")
+		fmt.Fprintf(fs.Output(), "grouping flags of the other commands apply; the tree is scanned recursively.\n\n")
+		fmt.Fprintf(fs.Output(), "Options:\n")
+		fs.PrintDefaults()
+	}
+	mf := addMatchFlags(fs)
+	var cfg corpusConfig
+	fs.IntVar(&cfg.files, "files", 10000, "Number of files to generate")
+	fs.Float64Var(&cfg.duplicates, "duplicates", 0.3, "Fraction of the files that are copies of another file, from 0 to below 1")
+	fs.IntVar(&cfg.dirs, "dirs", 100, "Number of directories to spread the files over")
+	size := byteSizeFlag(1024)
+	fs.Var(&size, "size", "Size of each file, e.g. 4k")
+	fs.Int64Var(&cfg.seed, "seed", 1, "Seed of the random tree; the same seed generates the same tree")
+	runs := fs.Int("runs", 3, "Number of times to run the phases")
+	keep := fs.String("keep", "", "Generate the tree in this new directory and keep it, instead of in a temporary one")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return exitError
+	}
+	if *mf.compare {
+		return exitWithError(errors.New("compare cannot be used with bench"))
+	}
+	if *runs < 1 {
+		return exitWithError(errors.New("runs must be at least 1"))
+	}
+	cfg.size = int(size)
+
+	dir := *keep
+	if dir == "" {
+		tmp, err := os.MkdirTemp("", "doppel-bench-")
+		if err != nil {
+			return exitWithError(err)
+		}
+		defer os.RemoveAll(tmp)
+		dir = tmp
+	} else if err := os.Mkdir(dir, 0755); err != nil {
+		return exitWithError(err)
+	}
+
+	if err := benchCorpus(ctx, os.Stdout, dir, cfg, mf, *runs); err != nil {
+		return exitWithError(err)
+	}
+	return 0
+}
+
+// benchCorpus generates the tree of cfg in dir, runs the phases runs times
+// with the options of mf, and writes the fastest time of each phase to w.
+func benchCorpus(ctx context.Context, w io.Writer, dir string, cfg corpusConfig, mf *matchFlags, runs int) error {
+	start := time.Now()
+	total, err := generateCorpus(dir, cfg)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Corpus:  %d files in %d directories, %.0f%% copies, %s, generated in %s\n",
+		cfg.files, cfg.dirs, cfg.duplicates*100, formatBytes(total), time.Since(start).Round(time.Millisecond))
+
+	opts, err := mf.optionsFor(dir, "")
+	if err != nil {
+		return err
+	}
+	opts.recursive = true
+
+	var best benchTimings
+	var fileCount int
+	var groups [][]string
+	var hashed int64
+	for i := 0; i < runs; i++ {
+		var t benchTimings
+		if t, fileCount, groups, hashed, err = runBench(ctx, opts); err != nil {
+			return err
+		}
+		if i == 0 {
+			best = t
+			continue
+		}
+		best.scan, best.group, best.hash = min(best.scan, t.scan), min(best.group, t.group), min(best.hash, t.hash)
+	}
+
+	grouped := 0
+	for _, group := range groups {
+		grouped += len(group)
+	}
+	fmt.Fprintf(w, "Scan:    %-10s %d files\n", benchDuration(best.scan), fileCount)
+	fmt.Fprintf(w, "Group:   %-10s %s\n", benchDuration(best.group), plural(len(groups), "group"))
+	_, err = fmt.Fprintf(w, "Hash:    %-10s %d files, %s\n", benchDuration(best.hash), grouped, formatBytes(hashed))
+	return err
+}
+
+// benchDuration rounds d to four or so significant digits for the timings.
+func benchDuration(d time.Duration) string {
+	if d >= time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+	return d.Round(time.Microsecond).String()
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestGenerateCorpus tests the synthetic tree: its size, and copies that share
+// the name and content of an original.
+func TestGenerateCorpus(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	cfg := corpusConfig{files: 200, duplicates: 0.5, dirs: 4, size: 64, seed: 1}
+	total, err := generateCorpus(tmpDir, cfg)
+	if err != nil {
+		t.Fatalf("generateCorpus() returned error: %v", err)
+	}
+	if total != 200*64 {
+		t.Errorf("generateCorpus() wrote %d bytes, expected %d", total, 200*64)
+	}
+
+	files, err := filepath.Glob(filepath.Join(tmpDir, "*", "*.txt"))
+	if err != nil || len(files) != cfg.files {
+		t.Fatalf("found %d files, expected %d", len(files), cfg.files)
+	}
+	originals := make(map[string]string)
+	for _, file := range files {
+		if name := filepath.Base(file); len(name) == corpusNameLetters+len(".txt") {
+			originals[name[:corpusNameLetters]] = file
+		}
+	}
+	copies := 0
+	for _, file := range files {
+		name := filepath.Base(file)
+		if len(name) == corpusNameLetters+len(".txt") {
+			continue
+		}
+		copies++
+		original, ok := originals[name[:corpusNameLetters]]
+		if !ok || !strings.HasPrefix(name[corpusNameLetters:], " (") {
+			t.Fatalf("copy %s has no original", name)
+		}
+		a, _ := os.ReadFile(file)
+		b, _ := os.ReadFile(original)
+		if !bytes.Equal(a, b) {
+			t.Errorf("copy %s differs from its original", name)
+		}
+	}
+	if copies < 70 || copies > 130 {
+		t.Errorf("generated %d copies, expected about half of %d files", copies, cfg.files)
+	}
+
+	if _, err := generateCorpus(tmpDir, corpusConfig{files: 10, duplicates: 1, dirs: 1}); err == nil {
+		t.Error("generateCorpus() should refuse a duplicate ratio of 1")
+	}
+}
+
+// benchmarkCorpus generates a tree of 10,000 files of 1 KB, 30% of them
+// copies, and returns options to scan it.
+func benchmarkCorpus(b *testing.B) options {
+	b.Helper()
+	dir := b.TempDir()
+	if _, err := generateCorpus(dir, corpusConfig{files: 10000, duplicates: 0.3, dirs: 100, size: 1024, seed: 1}); err != nil {
+		b.Fatal(err)
+	}
+	return options{dir: dir, minPrefix: corpusNameLetters, recursive: true, scanWorkers: defaultScanWorkers}
+}
+
+// BenchmarkScan measures scanning the synthetic tree.
+func BenchmarkScan(b *testing.B) {
+	opts := benchmarkCorpus(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := scanFiles(context.Background(), opts, opts.dir, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkGroup measures grouping the files of the synthetic tree.
+func BenchmarkGroup(b *testing.B) {
+	opts := benchmarkCorpus(b)
+	files, err := scanFiles(context.Background(), opts, opts.dir, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := opts.matcher().GroupContext(context.Background(), files); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkBench measures every phase of 'doppel bench' on the synthetic tree.
+func BenchmarkBench(b *testing.B) {
+	opts := benchmarkCorpus(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, _, _, err := runBench(context.Background(), opts); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
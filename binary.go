@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	// binarySniffLen is how many leading bytes are inspected when detecting binary files.
+	binarySniffLen = 8000
+	// hexDumpWidth is the number of bytes shown per hex dump row.
+	hexDumpWidth = 16
+	// hexDumpRows is the number of rows shown around the first difference.
+	hexDumpRows = 4
+)
+
+// BinaryComparison summarizes a byte-level comparison of two files.
+type BinaryComparison struct {
+	Size1, Size2 int64
+	Hash1, Hash2 string
+	// FirstDiff is the offset of the first differing byte, or -1 if the files are identical.
+	FirstDiff int64
+	// Region1 and Region2 hold the bytes around FirstDiff, starting at RegionStart.
+	Region1, Region2 []byte
+	RegionStart      int64
+}
+
+// Identical reports whether the compared files have the same content.
+func (c *BinaryComparison) Identical() bool {
+	return c.FirstDiff < 0
+}
+
+// isBinaryFile reports whether a file looks binary, using the same heuristic as
+// git and diff: a NUL byte within the first few kilobytes. UTF-16 text, whose
+// ASCII characters come with a NUL byte each, is not binary.
+func isBinaryFile(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	buf := make([]byte, binarySniffLen)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, err
+	}
+	if bytes.IndexByte(buf[:n], 0) < 0 {
+		return false, nil
+	}
+	_, text := detectEncoding(buf[:n], n == len(buf))
+	return !text, nil
+}
+
+// anyBinary reports whether either file looks binary.
+func anyBinary(file1, file2 string) (bool, error) {
+	for _, file := range []string{file1, file2} {
+		binary, err := isBinaryFile(file)
+		if err != nil {
+			return false, err
+		}
+		if binary {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// CompareBinary compares two files byte by byte, recording sizes, SHA-256 hashes,
+// and the region around the first difference.
+func CompareBinary(file1, file2 string) (*BinaryComparison, error) {
+	f1, err := os.Open(file1)
+	if err != nil {
+		return nil, err
+	}
+	defer f1.Close()
+	f2, err := os.Open(file2)
+	if err != nil {
+		return nil, err
+	}
+	defer f2.Close()
+
+	h1, h2 := sha256.New(), sha256.New()
+	r1 := bufio.NewReader(io.TeeReader(f1, h1))
+	r2 := bufio.NewReader(io.TeeReader(f2, h2))
+
+	result := &BinaryComparison{FirstDiff: -1}
+	var offset int64
+	for {
+		b1, err1 := r1.ReadByte()
+		b2, err2 := r2.ReadByte()
+		if err1 != nil && err1 != io.EOF {
+			return nil, err1
+		}
+		if err2 != nil && err2 != io.EOF {
+			return nil, err2
+		}
+		if err1 == io.EOF && err2 == io.EOF {
+			break
+		}
+		if result.FirstDiff < 0 && (err1 != nil || err2 != nil || b1 != b2) {
+			result.FirstDiff = offset
+			// Hashing only needs the remaining bytes, not a byte-wise walk
+			if _, err := io.Copy(io.Discard, r1); err != nil {
+				return nil, err
+			}
+			if _, err := io.Copy(io.Discard, r2); err != nil {
+				return nil, err
+			}
+			break
+		}
+		offset++
+	}
+
+	info1, err := f1.Stat()
+	if err != nil {
+		return nil, err
+	}
+	info2, err := f2.Stat()
+	if err != nil {
+		return nil, err
+	}
+	result.Size1, result.Size2 = info1.Size(), info2.Size()
+	result.Hash1 = hex.EncodeToString(h1.Sum(nil))
+	result.Hash2 = hex.EncodeToString(h2.Sum(nil))
+
+	if result.FirstDiff >= 0 {
+		result.RegionStart = result.FirstDiff - result.FirstDiff%hexDumpWidth
+		length := int64(hexDumpWidth * hexDumpRows)
+		if result.Region1, err = readRegion(file1, result.RegionStart, length); err != nil {
+			return nil, err
+		}
+		if result.Region2, err = readRegion(file2, result.RegionStart, length); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// filesByteIdentical reports whether two files have exactly the same content. Unlike
+// DiffExecutor.FilesIdentical, it ignores any diff options such as -w, so it is safe
+// to rely on before removing one of the files.
+func filesByteIdentical(file1, file2 string) (bool, error) {
+	info1, err := os.Stat(file1)
+	if err != nil {
+		return false, err
+	}
+	info2, err := os.Stat(file2)
+	if err != nil {
+		return false, err
+	}
+	if info1.Size() != info2.Size() {
+		return false, nil
+	}
+	comparison, err := CompareBinary(file1, file2)
+	if err != nil {
+		return false, err
+	}
+	return comparison.Identical(), nil
+}
+
+// readRegion reads up to length bytes of a file starting at offset.
+func readRegion(path string, offset, length int64) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	buf := make([]byte, length)
+	n, err := f.ReadAt(buf, offset)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// binaryDiff returns a formatted byte-level comparison if either file is binary.
+// The boolean result is false for text files, which should be diffed normally.
+func binaryDiff(file1, file2 string) (string, bool, error) {
+	binary, err := anyBinary(file1, file2)
+	if err != nil || !binary {
+		return "", false, err
+	}
+	comparison, err := CompareBinary(file1, file2)
+	if err != nil {
+		return "", true, err
+	}
+	return FormatBinaryComparison(comparison), true, nil
+}
+
+// FormatBinaryComparison renders a comparison as text for display in place of a diff.
+func FormatBinaryComparison(c *BinaryComparison) string {
+	var s strings.Builder
+
+	if c.Identical() {
+		s.WriteString("Binary files are identical\n\n")
+	} else {
+		s.WriteString("Binary files differ\n\n")
+	}
+	fmt.Fprintf(&s, "File 1: %d bytes  sha256 %s\n", c.Size1, c.Hash1)
+	fmt.Fprintf(&s, "File 2: %d bytes  sha256 %s\n", c.Size2, c.Hash2)
+
+	if c.Identical() {
+		return s.String()
+	}
+
+	fmt.Fprintf(&s, "\nFirst difference at offset %d (0x%x)\n\n", c.FirstDiff, c.FirstDiff)
+	rows := (max(len(c.Region1), len(c.Region2)) + hexDumpWidth - 1) / hexDumpWidth
+	for row := 0; row < rows; row++ {
+		start := row * hexDumpWidth
+		addr := c.RegionStart + int64(start)
+		fmt.Fprintf(&s, "%08x  %s | %s\n", addr,
+			hexRow(c.Region1, c.Region2, start), hexRow(c.Region2, c.Region1, start))
+	}
+	return s.String()
+}
+
+// hexRow renders one row of hex bytes from data, marking bytes that differ from other with '*'.
+func hexRow(data, other []byte, start int) string {
+	var s strings.Builder
+	for i := start; i < start+hexDumpWidth; i++ {
+		if i >= len(data) {
+			s.WriteString("   ")
+			continue
+		}
+		marker := " "
+		if i >= len(other) || data[i] != other[i] {
+			marker = "*"
+		}
+		fmt.Fprintf(&s, "%02x%s", data[i], marker)
+	}
+	return s.String()
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestIsBinaryFile tests NUL-byte sniffing.
+func TestIsBinaryFile(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	text := createFileWithContent(t, tmpDir, "notes.txt", "plain text\n")
+	binary := createFileWithContent(t, tmpDir, "image.bin", "PNG\x00\x01\x02")
+	empty := createFileWithContent(t, tmpDir, "empty.txt", "")
+
+	tests := []struct {
+		name     string
+		path     string
+		expected bool
+	}{
+		{"Text file", text, false},
+		{"Binary file", binary, true},
+		{"Empty file", empty, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := isBinaryFile(tt.path)
+			if err != nil {
+				t.Fatalf("isBinaryFile() returned error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("isBinaryFile(%q) = %v, expected %v", tt.path, got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestCompareBinary_Identical tests comparing identical binary files.
+func TestCompareBinary_Identical(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	content := "\x00\x01\x02\x03"
+	file1 := createFileWithContent(t, tmpDir, "a.bin", content)
+	file2 := createFileWithContent(t, tmpDir, "b.bin", content)
+
+	c, err := CompareBinary(file1, file2)
+	if err != nil {
+		t.Fatalf("CompareBinary() returned error: %v", err)
+	}
+	if !c.Identical() {
+		t.Errorf("Identical() = false, expected true (first diff at %d)", c.FirstDiff)
+	}
+	if c.Hash1 != c.Hash2 {
+		t.Error("identical files should have identical hashes")
+	}
+	if c.Size1 != 4 || c.Size2 != 4 {
+		t.Errorf("sizes = %d, %d, expected 4, 4", c.Size1, c.Size2)
+	}
+}
+
+// TestCompareBinary_FirstDifference tests locating the first differing byte.
+func TestCompareBinary_FirstDifference(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	prefix := strings.Repeat("\x00", 20)
+	file1 := createFileWithContent(t, tmpDir, "a.bin", prefix+"\x01tail")
+	file2 := createFileWithContent(t, tmpDir, "b.bin", prefix+"\x02tail")
+
+	c, err := CompareBinary(file1, file2)
+	if err != nil {
+		t.Fatalf("CompareBinary() returned error: %v", err)
+	}
+	if c.FirstDiff != 20 {
+		t.Errorf("FirstDiff = %d, expected 20", c.FirstDiff)
+	}
+	if c.RegionStart != 16 {
+		t.Errorf("RegionStart = %d, expected 16", c.RegionStart)
+	}
+	if c.Hash1 == c.Hash2 {
+		t.Error("different files should have different hashes")
+	}
+}
+
+// TestCompareBinary_Prefix tests that a file which is a prefix of another differs at its end.
+func TestCompareBinary_Prefix(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	file1 := createFileWithContent(t, tmpDir, "a.bin", "\x00abc")
+	file2 := createFileWithContent(t, tmpDir, "b.bin", "\x00abcdef")
+
+	c, err := CompareBinary(file1, file2)
+	if err != nil {
+		t.Fatalf("CompareBinary() returned error: %v", err)
+	}
+	if c.FirstDiff != 4 {
+		t.Errorf("FirstDiff = %d, expected 4", c.FirstDiff)
+	}
+	if c.Size1 != 4 || c.Size2 != 7 {
+		t.Errorf("sizes = %d, %d, expected 4, 7", c.Size1, c.Size2)
+	}
+}
+
+// TestBinaryDiff tests that text files are left to the normal diff path.
+func TestBinaryDiff(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	text1 := createFileWithContent(t, tmpDir, "a.txt", "hello\n")
+	text2 := createFileWithContent(t, tmpDir, "b.txt", "world\n")
+	bin := createFileWithContent(t, tmpDir, "c.bin", "\x00\xff")
+
+	if _, binary, err := binaryDiff(text1, text2); err != nil || binary {
+		t.Errorf("binaryDiff() on text files = (%v, %v), expected (false, nil)", binary, err)
+	}
+
+	output, binary, err := binaryDiff(text1, bin)
+	if err != nil {
+		t.Fatalf("binaryDiff() returned error: %v", err)
+	}
+	if !binary {
+		t.Fatal("binaryDiff() should treat a pair with a binary file as binary")
+	}
+	for _, want := range []string{"Binary files differ", "sha256", "First difference at offset 0"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("binaryDiff() output missing %q:\n%s", want, output)
+		}
+	}
+}
+
+// TestFilesByteIdentical tests exact content comparison, including same-size files.
+func TestFilesByteIdentical(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	a := createFileWithContent(t, tmpDir, "a.txt", "line 1\n")
+	b := createFileWithContent(t, tmpDir, "b.txt", "line 1\n")
+	c := createFileWithContent(t, tmpDir, "c.txt", "line 2\n")
+	d := createFileWithContent(t, tmpDir, "d.txt", "line  1\n")
+
+	tests := []struct {
+		file string
+		want bool
+	}{
+		{b, true},
+		{c, false},
+		{d, false},
+	}
+	for _, tt := range tests {
+		got, err := filesByteIdentical(a, tt.file)
+		if err != nil {
+			t.Fatalf("filesByteIdentical() returned error: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("filesByteIdentical(a.txt, %s) = %v, expected %v", filepath.Base(tt.file), got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// builtinDiffContext is the number of unchanged lines shown around each hunk
+// of a unified diff, as with diff -u.
+const builtinDiffContext = 3
+
+// diffOp is one line of an edit script: ' ' for a line of both files, '-' for
+// a line only the first file has, and '+' for one only the second has. For
+// ' ', a and b are the line as each file has it, which differ when
+// whitespace is ignored.
+type diffOp struct {
+	kind byte
+	a, b string
+}
+
+// builtinDiff diffs the text of two files without an external command, in the
+// output format of diff -u or diff -y, for systems without diff such as
+// Windows. Output beyond the executor's limit is cut like a command's.
+func (d *DiffExecutor) builtinDiff(file1, file2 string, unified bool) (string, error) {
+	a, err := readDiffLines(file1, d.ignoreEOL)
+	if err != nil {
+		return "", err
+	}
+	b, err := readDiffLines(file2, d.ignoreEOL)
+	if err != nil {
+		return "", err
+	}
+	equal := func(x, y string) bool { return x == y }
+	if d.ignoreWhitespace {
+		equal = func(x, y string) bool {
+			return strings.Join(strings.Fields(x), "") == strings.Join(strings.Fields(y), "")
+		}
+	}
+	ops, err := diffLines(a, b, equal)
+	if err != nil {
+		return "", err
+	}
+
+	var output string
+	if unified {
+		output = unifiedDiff(file1, file2, modTime(file1), modTime(file2), ops)
+	} else {
+		output = sideBySideDiff(ops, d.Width())
+	}
+	buf := &limitedBuffer{limit: d.maxOutput, full: func() {}}
+	buf.Write([]byte(output))
+	if buf.truncated {
+		return buf.String() + fmt.Sprintf("\n[diff output truncated at %s]\n", formatBytes(d.maxOutput)), nil
+	}
+	return buf.String(), nil
+}
+
+// readDiffLines returns the lines of a text file without their newlines, and
+// without a carriage return before them if stripCR is set.
+func readDiffLines(path string, stripCR bool) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	text := strings.TrimSuffix(string(data), "\n")
+	if text == "" {
+		return nil, nil
+	}
+	lines := strings.Split(text, "\n")
+	if stripCR {
+		for i, line := range lines {
+			lines[i] = strings.TrimSuffix(line, "\r")
+		}
+	}
+	return lines, nil
+}
+
+// modTime returns the modification time of path, or the zero time if it
+// cannot be read.
+func modTime(path string) time.Time {
+	if info, err := os.Stat(path); err == nil {
+		return info.ModTime()
+	}
+	return time.Time{}
+}
+
+// diffLines returns the edit script turning a into b, aligned by the longest
+// common subsequence of their lines after the lines they start and end with
+// alike are set aside. equal decides whether two lines match.
+func diffLines(a, b []string, equal func(x, y string) bool) ([]diffOp, error) {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && equal(a[prefix], b[prefix]) {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && equal(a[len(a)-1-suffix], b[len(b)-1-suffix]) {
+		suffix++
+	}
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if (len(midA)+1)*(len(midB)+1) > multiWayMaxCells {
+		return nil, fmt.Errorf("files too large for the built-in diff (%d and %d changed lines); install diff or set --diff-tool", len(midA), len(midB))
+	}
+
+	ops := make([]diffOp, 0, len(a)+len(b))
+	for i := 0; i < prefix; i++ {
+		ops = append(ops, diffOp{' ', a[i], b[i]})
+	}
+	edits, err := alignToBase(midA, midB, equal)
+	if err != nil {
+		return nil, err
+	}
+	j := 0
+	for i, edit := range edits {
+		for _, line := range edit.inserted {
+			ops = append(ops, diffOp{kind: '+', b: line})
+			j++
+		}
+		if i == len(midA) {
+			break
+		}
+		if edit.kept {
+			ops = append(ops, diffOp{' ', midA[i], midB[j]})
+			j++
+		} else {
+			ops = append(ops, diffOp{kind: '-', a: midA[i]})
+		}
+	}
+	for i := len(a) - suffix; i < len(a); i++ {
+		ops = append(ops, diffOp{' ', a[i], b[i-len(a)+len(b)]})
+	}
+	return ops, nil
+}
+
+// unifiedDiff renders ops like diff -u, with builtinDiffContext lines of
+// context, or returns "" if the files have no changes.
+func unifiedDiff(name1, name2 string, time1, time2 time.Time, ops []diffOp) string {
+	var changes []int
+	for i, op := range ops {
+		if op.kind != ' ' {
+			changes = append(changes, i)
+		}
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+
+	// lineA[i] and lineB[i] are the lines of each file before ops[i]
+	lineA, lineB := make([]int, len(ops)+1), make([]int, len(ops)+1)
+	for i, op := range ops {
+		lineA[i+1], lineB[i+1] = lineA[i], lineB[i]
+		if op.kind != '+' {
+			lineA[i+1]++
+		}
+		if op.kind != '-' {
+			lineB[i+1]++
+		}
+	}
+
+	const stamp = "2006-01-02 15:04:05.000000000 -0700"
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\t%s\n+++ %s\t%s\n", name1, time1.Format(stamp), name2, time2.Format(stamp))
+	for k := 0; k < len(changes); {
+		last := k
+		for last+1 < len(changes) && changes[last+1]-changes[last] <= 2*builtinDiffContext+1 {
+			last++
+		}
+		start := max(0, changes[k]-builtinDiffContext)
+		end := min(len(ops), changes[last]+1+builtinDiffContext)
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(lineA[start], lineA[end]-lineA[start]), hunkRange(lineB[start], lineB[end]-lineB[start]))
+		for _, op := range ops[start:end] {
+			switch op.kind {
+			case '+':
+				sb.WriteString("+" + op.b + "\n")
+			default:
+				sb.WriteString(string(op.kind) + op.a + "\n")
+			}
+		}
+		k = last + 1
+	}
+	return sb.String()
+}
+
+// hunkRange formats the lines of a hunk header that start after line start
+// and number count, the way diff does: "5" for one line, "4,0" for none.
+func hunkRange(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// sideBySideDiff renders ops like diff -y at the given width, with the
+// columns of sideBySideLayout: a removed and an added line of a block of
+// changes share a row marked |, and the rest are marked < or >. Tabs are
+// expanded, and lines too long for their column are cut.
+func sideBySideDiff(ops []diffOp, width int) string {
+	gutter, right := sideBySideLayout(width)
+	leftWidth, rightWidth := max(0, gutter-1), max(0, width-right)
+
+	var sb strings.Builder
+	row := func(left, mark, rightText string) {
+		line := padColumns(clipColumns(left, leftWidth), gutter) + mark
+		if rightText != "" {
+			line = padColumns(line, right) + clipColumns(rightText, rightWidth)
+		}
+		sb.WriteString(strings.TrimRight(line, " ") + "\n")
+	}
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			row(ops[i].a, " ", ops[i].b)
+			i++
+			continue
+		}
+		var removed, added []string
+		for ; i < len(ops) && ops[i].kind != ' '; i++ {
+			if ops[i].kind == '-' {
+				removed = append(removed, ops[i].a)
+			} else {
+				added = append(added, ops[i].b)
+			}
+		}
+		for k := 0; k < max(len(removed), len(added)); k++ {
+			switch {
+			case k >= len(removed):
+				row("", ">", added[k])
+			case k >= len(added):
+				row(removed[k], "<", "")
+			default:
+				row(removed[k], "|", added[k])
+			}
+		}
+	}
+	return sb.String()
+}
+
+// clipColumns returns s with tabs expanded to 8 columns, cut to width columns.
+func clipColumns(s string, width int) string {
+	var sb strings.Builder
+	col := 0
+	for _, r := range s {
+		n := 1
+		if r == '\t' {
+			n = 8 - col%8
+		}
+		if col+n > width {
+			break
+		}
+		if r == '\t' {
+			sb.WriteString(strings.Repeat(" ", n))
+		} else {
+			sb.WriteRune(r)
+		}
+		col += n
+	}
+	return sb.String()
+}
+
+// padColumns returns s padded with spaces to width columns, counting runes.
+func padColumns(s string, width int) string {
+	if n := len([]rune(s)); n < width {
+		return s + strings.Repeat(" ", width-n)
+	}
+	return s
+}
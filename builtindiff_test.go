@@ -0,0 +1,118 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// builtinDiffPairs are pairs of file contents the built-in diff is checked on.
+var builtinDiffPairs = []struct {
+	name, a, b string
+}{
+	{"Changed line", "one\ntwo\nthree\n", "one\n2\nthree\n"},
+	{"Added and removed", "a\nb\nc\nd\n", "b\nc\nx\nd\ne\n"},
+	{"Empty first file", "", "new\nlines\n"},
+	{"Far apart changes", "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n", "1\nchanged\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\nchanged\n15\n"},
+	{"Close changes", "1\n2\n3\n4\n5\n6\n7\n8\n9\n", "x\n2\n3\n4\n5\n6\n7\n8\nx\n"},
+	{"Tabs", "\tindented\nkey\tvalue\n", "\tindented more\nkey\tvalue\n"},
+}
+
+// TestBuiltinDiff_MatchesDiff tests that the built-in engine produces the same
+// hunks as diff -u and the same rows as diff -y.
+func TestBuiltinDiff_MatchesDiff(t *testing.T) {
+	if _, err := exec.LookPath("diff"); err != nil {
+		t.Skip("diff not installed")
+	}
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	for _, tt := range builtinDiffPairs {
+		t.Run(tt.name, func(t *testing.T) {
+			file1 := createFileWithContent(t, tmpDir, "a.txt", tt.a)
+			file2 := createFileWithContent(t, tmpDir, "b.txt", tt.b)
+			d := NewDiffExecutor("diff")
+			builtin := NewDiffExecutor("diff")
+			builtin.tool = diffToolBuiltin
+
+			want, _ := d.DiffUnified(file1, file2)
+			got, err := builtin.DiffUnified(file1, file2)
+			if err != nil {
+				t.Fatalf("DiffUnified() returned error: %v", err)
+			}
+			if hunks(got) != hunks(want) {
+				t.Errorf("built-in unified diff:\n%s\nexpected:\n%s", got, want)
+			}
+
+			want, _ = d.DiffSideBySide(file1, file2)
+			got, err = builtin.DiffSideBySide(file1, file2)
+			if err != nil {
+				t.Fatalf("DiffSideBySide() returned error: %v", err)
+			}
+			if expandRows(got) != expandRows(want) {
+				t.Errorf("built-in side-by-side diff:\n%s\nexpected:\n%s", got, want)
+			}
+		})
+	}
+}
+
+// hunks returns a unified diff without the file headers.
+func hunks(diff string) string {
+	if i := strings.Index(diff, "@@"); i >= 0 {
+		return diff[i:]
+	}
+	return diff
+}
+
+// expandRows returns side-by-side diff output with tabs expanded and trailing
+// spaces trimmed, as it looks.
+func expandRows(diff string) string {
+	lines := strings.Split(diff, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(clipColumns(line, len(line)*8), " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// TestBuiltinDiff_Options tests ignoring whitespace and line endings, and that
+// identical files give no unified diff.
+func TestBuiltinDiff_Options(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	file1 := createFileWithContent(t, tmpDir, "a.txt", "if x {\r\n  y()\r\n}\r\n")
+	file2 := createFileWithContent(t, tmpDir, "b.txt", "if x {\n\ty()\n}\n")
+
+	d := NewDiffExecutor("diff")
+	d.tool = diffToolBuiltin
+	if output, _ := d.DiffUnified(file1, file2); !strings.Contains(output, "-if x {\r\n") || !strings.Contains(output, "+\ty()\n") {
+		t.Errorf("DiffUnified() should show every line changed, got:\n%s", output)
+	}
+	d.SetIgnoreEOL(true)
+	d.SetIgnoreWhitespace(true)
+	if output, err := d.DiffUnified(file1, file2); err != nil || output != "" {
+		t.Errorf("DiffUnified() ignoring whitespace and line endings = %q, %v, expected no diff", output, err)
+	}
+	identical, err := d.FilesIdentical(file1, file2)
+	if err != nil || identical {
+		t.Errorf("FilesIdentical() = %v, %v, expected the bytes to differ", identical, err)
+	}
+}
+
+// TestHunkRange tests the line ranges of hunk headers.
+func TestHunkRange(t *testing.T) {
+	tests := []struct {
+		start, count int
+		expected     string
+	}{
+		{0, 0, "0,0"},
+		{4, 0, "4,0"},
+		{4, 1, "5"},
+		{0, 3, "1,3"},
+	}
+	for _, tt := range tests {
+		if got := hunkRange(tt.start, tt.count); got != tt.expected {
+			t.Errorf("hunkRange(%d, %d) = %q, expected %q", tt.start, tt.count, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// cacheEnvVar names the environment variable that overrides the hash cache location.
+const cacheEnvVar = "DOPPEL_CACHE"
+
+// cacheVersion is bumped when the cache format changes; older caches are discarded.
+const cacheVersion = 1
+
+// HashCache remembers file hashes between runs so unchanged files are not read
+// again. Entries are keyed by absolute path and are valid only while the file's
+// size and modification time are the same as when it was hashed. The cache is
+// stored in $DOPPEL_CACHE, or doppel/hashes.json in the user cache directory
+// (~/.cache on Linux).
+//
+// A nil *HashCache is valid and hashes every file.
+type HashCache struct {
+	path    string
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	dirty   bool
+}
+
+// cacheEntry holds the hashes known for one file. Either hash may be empty if
+// only the other was needed.
+type cacheEntry struct {
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"mtime"`
+	Hash    string `json:"sha256,omitempty"`
+	Prefix  string `json:"prefix_sha256,omitempty"`
+}
+
+// cacheFile is the on-disk format of a HashCache.
+type cacheFile struct {
+	Version int                   `json:"version"`
+	Files   map[string]cacheEntry `json:"files"`
+}
+
+// cachePath returns the location of the hash cache file.
+func cachePath() (string, error) {
+	if path := os.Getenv(cacheEnvVar); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "doppel", "hashes.json"), nil
+}
+
+// LoadHashCache reads the hash cache. A missing, unreadable, or outdated cache
+// file starts an empty cache, since every entry can be recomputed. With no known
+// cache directory, caching is disabled and nil is returned.
+func LoadHashCache() *HashCache {
+	path, err := cachePath()
+	if err != nil {
+		return nil
+	}
+	c := &HashCache{path: path, entries: make(map[string]cacheEntry)}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return c
+	}
+	var f cacheFile
+	if err := json.Unmarshal(data, &f); err == nil && f.Version == cacheVersion && f.Files != nil {
+		c.entries = f.Files
+	}
+	return c
+}
+
+// ClearHashCache deletes the hash cache file. A missing file is not an error.
+func ClearHashCache() error {
+	path, err := cachePath()
+	if err != nil {
+		return nil
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// lookup returns the cache key, file info, and cached entry for path. The entry
+// is only returned if it still matches the file's size and modification time.
+func (c *HashCache) lookup(path string) (string, os.FileInfo, cacheEntry, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", nil, cacheEntry{}, err
+	}
+	key, err := absolutePath(path)
+	if err != nil {
+		return "", nil, cacheEntry{}, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || entry.Size != info.Size() || entry.ModTime != info.ModTime().UnixNano() {
+		entry = cacheEntry{Size: info.Size(), ModTime: info.ModTime().UnixNano()}
+	}
+	return key, info, entry, nil
+}
+
+// store records an updated entry for key.
+func (c *HashCache) store(key string, entry cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = entry
+	c.dirty = true
+}
+
+// FullHash returns the SHA-256 of a file's content like hashFile, reading the
+// file only if it changed since it was last hashed.
+func (c *HashCache) FullHash(ctx context.Context, path string, progress *Progress) (string, error) {
+	if c == nil {
+		return hashFile(ctx, path, progress)
+	}
+	key, _, entry, err := c.lookup(path)
+	if err != nil {
+		return "", err
+	}
+	if entry.Hash != "" {
+		return entry.Hash, nil
+	}
+	entry.Hash, err = hashFile(ctx, path, progress)
+	if err != nil {
+		return "", err
+	}
+	c.store(key, entry)
+	return entry.Hash, nil
+}
+
+// PrefixHash returns the SHA-256 of the first partialHashSize bytes of a file
+// like hashPrefix, reading the file only if it changed since it was last hashed.
+func (c *HashCache) PrefixHash(ctx context.Context, path string, progress *Progress) (string, error) {
+	if c == nil {
+		return hashPrefix(ctx, path, partialHashSize, progress)
+	}
+	key, info, entry, err := c.lookup(path)
+	if err != nil {
+		return "", err
+	}
+	// The prefix of a small file is the whole file
+	if entry.Prefix == "" && info.Size() <= partialHashSize {
+		entry.Prefix = entry.Hash
+	}
+	if entry.Prefix != "" {
+		return entry.Prefix, nil
+	}
+	entry.Prefix, err = hashPrefix(ctx, path, partialHashSize, progress)
+	if err != nil {
+		return "", err
+	}
+	c.store(key, entry)
+	return entry.Prefix, nil
+}
+
+// Save writes the cache if any entry changed. The file is replaced atomically,
+// so an interrupted or concurrent run never leaves a truncated cache behind.
+func (c *HashCache) Save() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.dirty {
+		return nil
+	}
+
+	data, err := json.Marshal(cacheFile{Version: cacheVersion, Files: c.entries})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), ".hashes-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestHashCache tests that unchanged files are not read again after a reload,
+// and that a changed size or modification time invalidates the entry.
+func TestHashCache(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	t.Setenv(cacheEnvVar, filepath.Join(tmpDir, "cache", "hashes.json"))
+	path := createFileWithContent(t, tmpDir, "a.txt", "hello\n")
+	ctx := context.Background()
+
+	cache := LoadHashCache()
+	first, err := cache.FullHash(ctx, path, nil)
+	if err != nil {
+		t.Fatalf("FullHash() returned error: %v", err)
+	}
+	if err := cache.Save(); err != nil {
+		t.Fatalf("Save() returned error: %v", err)
+	}
+
+	cache = LoadHashCache()
+	progress := NewProgress("Hashing")
+	again, err := cache.FullHash(ctx, path, progress)
+	if err != nil {
+		t.Fatalf("FullHash() returned error: %v", err)
+	}
+	if again != first {
+		t.Errorf("FullHash() = %q after reload, expected %q", again, first)
+	}
+	if got := progress.bytes.Load(); got != 0 {
+		t.Errorf("an unchanged file was read again (%d bytes)", got)
+	}
+
+	// Same size, new content and modification time
+	if err := os.WriteFile(path, []byte("world\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	changed, err := cache.FullHash(ctx, path, progress)
+	if err != nil {
+		t.Fatalf("FullHash() returned error: %v", err)
+	}
+	if changed == first {
+		t.Error("FullHash() returned a stale hash for a modified file")
+	}
+}
+
+// TestHashCache_PrefixHash tests that the full hash of a small file doubles as its prefix hash.
+func TestHashCache_PrefixHash(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	t.Setenv(cacheEnvVar, filepath.Join(tmpDir, "hashes.json"))
+	path := createFileWithContent(t, tmpDir, "a.txt", "hello\n")
+	ctx := context.Background()
+
+	cache := LoadHashCache()
+	full, err := cache.FullHash(ctx, path, nil)
+	if err != nil {
+		t.Fatalf("FullHash() returned error: %v", err)
+	}
+	progress := NewProgress("Hashing")
+	prefix, err := cache.PrefixHash(ctx, path, progress)
+	if err != nil {
+		t.Fatalf("PrefixHash() returned error: %v", err)
+	}
+	if prefix != full || progress.bytes.Load() != 0 {
+		t.Errorf("PrefixHash() = %q reading %d bytes, expected the cached %q", prefix, progress.bytes.Load(), full)
+	}
+}
+
+// TestHashCache_Nil tests that a nil cache hashes directly and saves nothing.
+func TestHashCache_Nil(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	path := createFileWithContent(t, tmpDir, "a.txt", "hello\n")
+
+	var cache *HashCache
+	hash, err := cache.FullHash(context.Background(), path, nil)
+	if err != nil {
+		t.Fatalf("FullHash() returned error: %v", err)
+	}
+	expected, _ := hashFile(context.Background(), path, nil)
+	if hash != expected {
+		t.Errorf("FullHash() = %q, expected %q", hash, expected)
+	}
+	if err := cache.Save(); err != nil {
+		t.Errorf("Save() on a nil cache returned error: %v", err)
+	}
+}
+
+// TestClearHashCache tests that clearing removes the cache file and tolerates a missing one.
+func TestClearHashCache(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	cacheFile := filepath.Join(tmpDir, "hashes.json")
+	t.Setenv(cacheEnvVar, cacheFile)
+	path := createFileWithContent(t, tmpDir, "a.txt", "hello\n")
+
+	cache := LoadHashCache()
+	if _, err := cache.FullHash(context.Background(), path, nil); err != nil {
+		t.Fatalf("FullHash() returned error: %v", err)
+	}
+	if err := cache.Save(); err != nil {
+		t.Fatalf("Save() returned error: %v", err)
+	}
+
+	if err := ClearHashCache(); err != nil {
+		t.Fatalf("ClearHashCache() returned error: %v", err)
+	}
+	if _, err := os.Stat(cacheFile); !os.IsNotExist(err) {
+		t.Errorf("cache file still exists after ClearHashCache(): %v", err)
+	}
+	if err := ClearHashCache(); err != nil {
+		t.Errorf("ClearHashCache() without a cache file returned error: %v", err)
+	}
+}
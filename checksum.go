@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// hashAlgorithm is the checksum --hash shows next to each file. It implements
+// flag.Value; unset shows no checksums.
+type hashAlgorithm string
+
+const (
+	hashSHA256 hashAlgorithm = "sha256"
+	// hashXXH3 is much faster than SHA-256 on large files, but not
+	// cryptographic.
+	hashXXH3 hashAlgorithm = "xxh3"
+)
+
+// hashAlgorithms lists the algorithms in the order they are documented.
+var hashAlgorithms = []hashAlgorithm{hashSHA256, hashXXH3}
+
+// shortHashLen is how many hex digits of a checksum are shown.
+const shortHashLen = 12
+
+// String returns the algorithm name.
+func (a *hashAlgorithm) String() string {
+	return string(*a)
+}
+
+// Set parses an algorithm name.
+func (a *hashAlgorithm) Set(value string) error {
+	for _, algo := range hashAlgorithms {
+		if value == string(algo) {
+			*a = algo
+			return nil
+		}
+	}
+	names := make([]string, len(hashAlgorithms))
+	for i, algo := range hashAlgorithms {
+		names[i] = string(algo)
+	}
+	return fmt.Errorf("unknown hash algorithm %q (use %s)", value, strings.Join(names, " or "))
+}
+
+// newHash returns a hash computing the algorithm.
+func (a hashAlgorithm) newHash() hash.Hash {
+	if a == hashXXH3 {
+		return newXXH3()
+	}
+	return sha256.New()
+}
+
+// sum returns the hex-encoded checksum of a file's content.
+func (a hashAlgorithm) sum(ctx context.Context, path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := a.newHash()
+	if _, err := io.Copy(h, contextReader{ctx, f}); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Checksums remembers the checksum shown for each file with --hash, so that a
+// file can be hashed again right before it is deleted or replaced. Sync
+// clients such as Dropbox or Syncthing may rewrite a file between the moment
+// its checksum was read off the screen and the moment it is acted on. A nil
+// *Checksums shows nothing and verifies nothing.
+type Checksums struct {
+	algo hashAlgorithm
+	mu   sync.Mutex
+	sums map[string]string
+}
+
+// NewChecksums returns a Checksums using algo, or nil if algo is unset.
+func NewChecksums(algo hashAlgorithm) *Checksums {
+	if algo == "" {
+		return nil
+	}
+	return &Checksums{algo: algo, sums: make(map[string]string)}
+}
+
+// Short returns the first hex digits of the checksum of path, hashing the
+// file the first time it is asked for. It is empty if c is nil or the file
+// can't be read.
+func (c *Checksums) Short(path string) string {
+	if c == nil {
+		return ""
+	}
+	c.mu.Lock()
+	sum, ok := c.sums[path]
+	c.mu.Unlock()
+	if !ok {
+		var err error
+		if sum, err = c.algo.sum(context.Background(), path); err != nil {
+			return ""
+		}
+		c.mu.Lock()
+		c.sums[path] = sum
+		c.mu.Unlock()
+	}
+	return sum[:min(len(sum), shortHashLen)]
+}
+
+// Verify hashes the files again and fails if any of them no longer matches
+// the checksum that was shown for it. The new checksums are remembered, so the
+// file list shows what the files hold now. Files whose checksum was never
+// shown are not checked.
+func (c *Checksums) Verify(ctx context.Context, paths ...string) error {
+	if c == nil {
+		return nil
+	}
+	var changed, shown []string
+	for _, path := range paths {
+		c.mu.Lock()
+		old, ok := c.sums[path]
+		c.mu.Unlock()
+		if !ok {
+			continue
+		}
+		sum, err := c.algo.sum(ctx, path)
+		if err != nil {
+			return fmt.Errorf("%s can't be verified: %w", path, err)
+		}
+		if sum != old {
+			c.mu.Lock()
+			c.sums[path] = sum
+			c.mu.Unlock()
+			changed = append(changed, path)
+			shown = append(shown, old[:min(len(old), shortHashLen)])
+		}
+	}
+	switch len(changed) {
+	case 0:
+		return nil
+	case 1:
+		return fmt.Errorf("%s changed since its %s checksum %s was shown", changed[0], c.algo, shown[0])
+	}
+	return fmt.Errorf("%s changed since their %s checksums were shown", strings.Join(changed, " and "), c.algo)
+}
+
+// Forget drops every remembered checksum, after the files may have been
+// edited on purpose, such as in a merge tool.
+func (c *Checksums) Forget() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.sums = make(map[string]string)
+	c.mu.Unlock()
+}
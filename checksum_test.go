@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestHashAlgorithm_Set tests parsing --hash values.
+func TestHashAlgorithm_Set(t *testing.T) {
+	var algo hashAlgorithm
+	if err := algo.Set("xxh3"); err != nil || algo != hashXXH3 {
+		t.Errorf("Set(xxh3) = %v, algo = %q", err, algo)
+	}
+	if err := algo.Set("md5"); err == nil || !strings.Contains(err.Error(), "sha256 or xxh3") {
+		t.Errorf("Set(md5) = %v, expected an error listing the algorithms", err)
+	}
+	if NewChecksums("") != nil {
+		t.Error("NewChecksums() without an algorithm should return nil")
+	}
+}
+
+// TestChecksums_Short tests the checksums shown for files.
+func TestChecksums_Short(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	file := createFileWithContent(t, tmpDir, "notes.txt", "hello\n")
+
+	if got := NewChecksums(hashSHA256).Short(file); got != "5891b5b522d5" {
+		t.Errorf("sha256 Short() = %q, expected 5891b5b522d5", got)
+	}
+	if got, want := NewChecksums(hashXXH3).Short(file), fmt.Sprintf("%016x", xxh3Hash([]byte("hello\n")))[:shortHashLen]; got != want {
+		t.Errorf("xxh3 Short() = %q, expected %q", got, want)
+	}
+	if got := NewChecksums(hashSHA256).Short(file + ".missing"); got != "" {
+		t.Errorf("Short() of a missing file = %q, expected none", got)
+	}
+	var none *Checksums
+	if got := none.Short(file); got != "" {
+		t.Errorf("nil Short() = %q, expected none", got)
+	}
+}
+
+// TestChecksums_Verify tests that a file rewritten after its checksum was shown
+// fails verification once, and passes after the new checksum is shown.
+func TestChecksums_Verify(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	file := createFileWithContent(t, tmpDir, "notes.txt", "hello\n")
+	unseen := createFileWithContent(t, tmpDir, "other.txt", "other\n")
+
+	c := NewChecksums(hashXXH3)
+	shown := c.Short(file)
+	if err := c.Verify(context.Background(), file, unseen); err != nil {
+		t.Fatalf("Verify() of unchanged files returned error: %v", err)
+	}
+
+	createFileWithContent(t, tmpDir, "notes.txt", "hello, synced\n")
+	err := c.Verify(context.Background(), file)
+	if err == nil || !strings.Contains(err.Error(), "changed since its xxh3 checksum "+shown) {
+		t.Fatalf("Verify() of a rewritten file = %v, expected a change to be reported", err)
+	}
+	if c.Short(file) == shown {
+		t.Error("the new checksum should be shown after a failed verification")
+	}
+	if err := c.Verify(context.Background(), file); err != nil {
+		t.Errorf("Verify() after the new checksum was shown returned error: %v", err)
+	}
+}
+
+// TestWriteGroupListWithChecksums tests scan's listing with --hash.
+func TestWriteGroupListWithChecksums(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	file1 := createFileWithContent(t, tmpDir, "notes.txt", "hello\n")
+	file2 := createFileWithContent(t, tmpDir, "notes-1.txt", "hello\n")
+
+	var out bytes.Buffer
+	if err := writeGroupListWithChecksums(&out, tmpDir, [][]string{{file1, file2}}, NewChecksums(hashSHA256), nil, groupPage{}); err != nil {
+		t.Fatalf("writeGroupListWithChecksums() returned error: %v", err)
+	}
+	for _, file := range []string{file1, file2} {
+		if !strings.Contains(out.String(), "  "+file+"  5891b5b522d5\n") {
+			t.Errorf("listing should show the checksum after %s:\n%s", file, out.String())
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+)
+
+// cleanOptions controls what runClean does with redundant copies.
+type cleanOptions struct {
+	// force applies the plan immediately instead of doing a dry run.
+	force bool
+	// planPath, if set, writes the plan to this file instead of applying it, as
+	// YAML if it ends in .yaml or .yml and as JSON otherwise.
+	planPath string
+	// hardlink replaces redundant copies with hard links instead of removing them.
+	hardlink bool
+	// keep picks which of the identical files is kept; the leader by default.
+	keep Keeper
+	// identicalOnly leaves groups alone unless all their files are identical.
+	identicalOnly bool
+}
+
+// runClean builds a cleanup plan that keeps each group leader and removes (or
+// hardlinks) files that are byte-identical to it. Depending on cleanOpts the plan
+// is written to a file, applied, or only printed as a dry run. Every decision is
+// logged to w.
+func runClean(ctx context.Context, opts options, cleanOpts cleanOptions, w io.Writer) error {
+	progress := NewProgress("Scanning")
+	stop := startProgressPrinter(os.Stderr, progress)
+	groups, _, err := scanAndGroup(ctx, opts, progress)
+	var records []FileRecord
+	if err == nil {
+		records, err = buildFileRecords(ctx, groups, opts.hashCache, progress)
+	}
+	stop()
+	if err != nil {
+		return err
+	}
+	setFingerprints(opts.dir, records)
+	saveHashCache(opts.hashCache)
+
+	plan := buildCleanPlan(opts.dir, records, cleanOpts)
+	count, size := planSummary(plan)
+
+	if !cleanOpts.keep.isDefault() {
+		fmt.Fprintf(w, "Keeping %s of each set of identical copies\n", cleanOpts.keep.describe())
+	}
+	if cleanOpts.identicalOnly {
+		for _, group := range recordGroups(records) {
+			if mixedGroup(group) {
+				fmt.Fprintf(w, "left group %d (%s, %d files) alone: not all files are identical\n", group[0].Group, group[0].Path, len(group))
+			}
+		}
+	}
+
+	switch {
+	case cleanOpts.planPath != "":
+		f, err := os.Create(cleanOpts.planPath)
+		if err != nil {
+			return err
+		}
+		write := writePlan
+		if isYAMLPath(cleanOpts.planPath) {
+			write = writePlanYAML
+		}
+		if err := write(f, plan); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "Wrote plan for %d identical file(s), %s, to %s\n", count, formatBytes(size), cleanOpts.planPath)
+		fmt.Fprintf(w, "Review it, then run 'doppel apply %s'.\n", cleanOpts.planPath)
+		return nil
+
+	case cleanOpts.force:
+		if err := applyPlan(ctx, plan, opts.quarantine, w); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "Cleaned %d identical file(s), %s\n", count, formatBytes(size))
+		return nil
+	}
+
+	for _, e := range plan.Entries {
+		if e.Action != ActionKeep {
+			fmt.Fprintf(w, "would %s %s (identical to %s)\n", planVerb(e.Action, opts.quarantine), e.Path, e.Target)
+		}
+	}
+	fmt.Fprintf(w, "Would clean %d identical file(s), %s\n", count, formatBytes(size))
+	if count > 0 {
+		fmt.Fprintln(w, "Run with --force to apply, or --plan FILE to save the plan for review.")
+	}
+	return nil
+}
+
+// runApply applies a plan file written by "doppel clean --plan". If
+// quarantineDir is set, removed files are moved there instead, with paths
+// relative to the plan's directory.
+func runApply(ctx context.Context, planPath, quarantineDir string, w io.Writer) error {
+	plan, err := readPlan(planPath)
+	if err != nil {
+		return err
+	}
+	var q *Quarantine
+	if quarantineDir != "" {
+		if q, err = NewQuarantine(quarantineDir, plan.Dir); err != nil {
+			return err
+		}
+	}
+	if err := applyPlan(ctx, plan, q, w); err != nil {
+		return err
+	}
+	count, size := planSummary(plan)
+	fmt.Fprintf(w, "Applied %d action(s), %s\n", count, formatBytes(size))
+	return nil
+}
+
+// planVerb describes a pending action for dry-run output, where removals go
+// to q if it is set.
+func planVerb(a PlanAction, q *Quarantine) string {
+	switch a {
+	case ActionDelete:
+		if q != nil {
+			return "quarantine"
+		}
+		return "remove"
+	case ActionHardlink:
+		return "hardlink"
+	}
+	return string(a)
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestRunClean_DryRun tests that clean without --force only reports identical copies.
+func TestRunClean_DryRun(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	// Files are scanned in name order, so notes-1.txt is the group leader
+	createFileWithContent(t, tmpDir, "notes-1.txt", "same\n")
+	copyPath := createFileWithContent(t, tmpDir, "notes-2.txt", "same\n")
+	createFileWithContent(t, tmpDir, "notes-3.txt", "edited\n")
+
+	var out bytes.Buffer
+	if err := runClean(context.Background(), options{dir: tmpDir, minPrefix: 3}, cleanOptions{}, &out); err != nil {
+		t.Fatalf("runClean() returned error: %v", err)
+	}
+
+	if _, err := os.Stat(copyPath); err != nil {
+		t.Errorf("dry run removed %s", copyPath)
+	}
+	if !strings.Contains(out.String(), "would remove "+copyPath) {
+		t.Errorf("runClean() output missing planned removal:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "Would clean 1 identical file(s)") {
+		t.Errorf("runClean() output missing summary:\n%s", out.String())
+	}
+}
+
+// TestRunClean_Force tests that clean --force removes only identical copies.
+func TestRunClean_Force(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	createFileWithContent(t, tmpDir, "notes-1.txt", "same\n")
+	createFileWithContent(t, tmpDir, "notes-2.txt", "same\n")
+	createFileWithContent(t, tmpDir, "notes-3.txt", "edited\n")
+
+	var out bytes.Buffer
+	if err := runClean(context.Background(), options{dir: tmpDir, minPrefix: 3}, cleanOptions{force: true}, &out); err != nil {
+		t.Fatalf("runClean() returned error: %v", err)
+	}
+
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatalf("ReadDir() failed: %v", err)
+	}
+	var remaining []string
+	for _, e := range entries {
+		remaining = append(remaining, e.Name())
+	}
+	if strings.Join(remaining, ",") != "notes-1.txt,notes-3.txt" {
+		t.Errorf("remaining files = %v, expected notes-1.txt and notes-3.txt", remaining)
+	}
+	if !strings.Contains(out.String(), "removed "+filepath.Join(tmpDir, "notes-2.txt")) {
+		t.Errorf("runClean() output missing removal log:\n%s", out.String())
+	}
+}
+
+// TestRunClean_PlanThenApply tests writing a plan without touching files, then applying it.
+func TestRunClean_PlanThenApply(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	planDir := createTempDir(t)
+	defer os.RemoveAll(planDir)
+
+	createFileWithContent(t, tmpDir, "notes-1.txt", "same\n")
+	copyPath := createFileWithContent(t, tmpDir, "notes-2.txt", "same\n")
+	planPath := filepath.Join(planDir, "plan.json")
+
+	var out bytes.Buffer
+	if err := runClean(context.Background(), options{dir: tmpDir, minPrefix: 3}, cleanOptions{planPath: planPath}, &out); err != nil {
+		t.Fatalf("runClean() returned error: %v", err)
+	}
+	if _, err := os.Stat(copyPath); err != nil {
+		t.Fatal("runClean() with --plan should not touch files")
+	}
+
+	out.Reset()
+	if err := runApply(context.Background(), planPath, "", &out); err != nil {
+		t.Fatalf("runApply() returned error: %v", err)
+	}
+	if _, err := os.Stat(copyPath); !os.IsNotExist(err) {
+		t.Error("runApply() should remove the planned copy")
+	}
+}
+
+// TestRunClean_YAMLPlan tests writing a plan as YAML for a .yaml file and
+// applying it after a hand edit.
+func TestRunClean_YAMLPlan(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	planDir := createTempDir(t)
+	defer os.RemoveAll(planDir)
+
+	createFileWithContent(t, tmpDir, "notes-1.txt", "same\n")
+	copyPath := createFileWithContent(t, tmpDir, "notes-2.txt", "same\n")
+	planPath := filepath.Join(planDir, "plan.yaml")
+
+	var out bytes.Buffer
+	if err := runClean(context.Background(), options{dir: tmpDir, minPrefix: 3}, cleanOptions{planPath: planPath}, &out); err != nil {
+		t.Fatalf("runClean() returned error: %v", err)
+	}
+	data, err := os.ReadFile(planPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "version: 1\n") || !strings.Contains(string(data), "  - action: delete\n") {
+		t.Fatalf("plan should be YAML:\n%s", data)
+	}
+
+	edited := "# Keep both after all\n" + strings.Replace(string(data), "action: delete", "action: keep  # still needed", 1)
+	if err := os.WriteFile(planPath, []byte(edited), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if err := runApply(context.Background(), planPath, "", &out); err != nil {
+		t.Fatalf("runApply() returned error: %v", err)
+	}
+	if _, err := os.Stat(copyPath); err != nil {
+		t.Error("runApply() should follow the edited plan and keep the copy")
+	}
+}
+
+// TestRunClean_PathRules tests that the copy under a preferred directory is
+// kept even when it isn't the group leader.
+func TestRunClean_PathRules(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	for _, dir := range []string{"Downloads", "Notes"} {
+		if err := os.Mkdir(filepath.Join(tmpDir, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	download := createFileWithContent(t, tmpDir, filepath.Join("Downloads", "cv.txt"), "same\n")
+	note := createFileWithContent(t, tmpDir, filepath.Join("Notes", "cv.txt"), "same\n")
+
+	opts := options{dir: tmpDir, minPrefix: 2, recursive: true}
+	cleanOpts := cleanOptions{force: true, keep: Keeper{Prefer: []string{filepath.Join(tmpDir, "Notes")}}}
+	var out bytes.Buffer
+	if err := runClean(context.Background(), opts, cleanOpts, &out); err != nil {
+		t.Fatalf("runClean() returned error: %v", err)
+	}
+	if _, err := os.Stat(note); err != nil {
+		t.Errorf("the copy under the preferred directory should be kept: %v", err)
+	}
+	if _, err := os.Stat(download); !os.IsNotExist(err) {
+		t.Errorf("the other copy should be removed:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "preferring files under "+filepath.Join(tmpDir, "Notes")) {
+		t.Errorf("runClean() output should explain the choice:\n%s", out.String())
+	}
+}
+
+// TestRunClean_Symlink tests that clean never keeps a symbolic link in place of
+// a real copy, nor removes the file a link points to.
+func TestRunClean_Symlink(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	first := createFileWithContent(t, tmpDir, "notes-1.txt", "same\n")
+	target := createFileWithContent(t, tmpDir, "notes-2.txt", "same\n")
+	link := filepath.Join(tmpDir, "notes.txt")
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	cleanOpts := cleanOptions{force: true, keep: Keeper{Policy: KeepShortestName}}
+	var out bytes.Buffer
+	if err := runClean(context.Background(), options{dir: tmpDir, minPrefix: 3}, cleanOpts, &out); err != nil {
+		t.Fatalf("runClean() returned error: %v", err)
+	}
+	for _, path := range []string{link, target} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("%s should be kept: %v\n%s", path, err, out.String())
+		}
+	}
+	if _, err := os.Stat(first); !os.IsNotExist(err) {
+		t.Errorf("%s should be removed as a copy of the link's target:\n%s", first, out.String())
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// clipboardCommand returns the program, with arguments, that sets the system
+// clipboard to its standard input, or nil if none is installed.
+func clipboardCommand() []string {
+	var candidates [][]string
+	switch runtime.GOOS {
+	case "darwin":
+		candidates = [][]string{{"pbcopy"}}
+	case "windows":
+		candidates = [][]string{{"clip"}}
+	default:
+		if os.Getenv("WAYLAND_DISPLAY") != "" {
+			candidates = append(candidates, []string{"wl-copy"})
+		}
+		candidates = append(candidates,
+			[]string{"xclip", "-selection", "clipboard"},
+			[]string{"xsel", "--clipboard", "--input"})
+	}
+	for _, argv := range candidates {
+		if _, err := exec.LookPath(argv[0]); err == nil {
+			return argv
+		}
+	}
+	return nil
+}
+
+// osc52 returns the escape sequence asking the terminal to set its clipboard to
+// text. Inside tmux it is wrapped so tmux passes it on to the outer terminal.
+func osc52(text string) string {
+	seq := "\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte(text)) + "\a"
+	if os.Getenv("TMUX") != "" {
+		seq = "\x1bPtmux;" + strings.ReplaceAll(seq, "\x1b", "\x1b\x1b") + "\x1b\\"
+	}
+	return seq
+}
+
+// copyToClipboard copies text with the system clipboard program. Over SSH, where
+// that would fill the remote machine's clipboard, or when no program is
+// installed, it writes an OSC 52 sequence to term instead, which most terminal
+// emulators apply to the local clipboard. It returns how the text was copied.
+func copyToClipboard(text string, term io.Writer) (string, error) {
+	remote := os.Getenv("SSH_TTY") != "" || os.Getenv("SSH_CONNECTION") != ""
+	if argv := clipboardCommand(); argv != nil && !remote {
+		cmd := logCommand(exec.Command(argv[0], argv[1:]...))
+		cmd.Stdin = strings.NewReader(text)
+		if err := cmd.Run(); err != nil {
+			return "", fmt.Errorf("%s failed: %w", argv[0], err)
+		}
+		return argv[0], nil
+	}
+	if _, err := io.WriteString(term, osc52(text)); err != nil {
+		return "", err
+	}
+	return "OSC 52", nil
+}
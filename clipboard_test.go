@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// TestOSC52 tests the clipboard escape sequence, plain and wrapped for tmux.
+func TestOSC52(t *testing.T) {
+	t.Setenv("TMUX", "")
+	if got, want := osc52("/tmp/a.txt"), "\x1b]52;c;L3RtcC9hLnR4dA==\a"; got != want {
+		t.Errorf("osc52() = %q, expected %q", got, want)
+	}
+
+	t.Setenv("TMUX", "/tmp/tmux-1000/default,1,0")
+	if got, want := osc52("/tmp/a.txt"), "\x1bPtmux;\x1b\x1b]52;c;L3RtcC9hLnR4dA==\a\x1b\\"; got != want {
+		t.Errorf("osc52() in tmux = %q, expected %q", got, want)
+	}
+}
+
+// TestCopyToClipboard_SSH tests that OSC 52 is used over SSH.
+func TestCopyToClipboard_SSH(t *testing.T) {
+	t.Setenv("SSH_TTY", "/dev/pts/0")
+	t.Setenv("TMUX", "")
+
+	var term bytes.Buffer
+	method, err := copyToClipboard("/tmp/a.txt", &term)
+	if err != nil {
+		t.Fatalf("copyToClipboard() returned error: %v", err)
+	}
+	if method != "OSC 52" || term.String() != osc52("/tmp/a.txt") {
+		t.Errorf("copyToClipboard() = %q, wrote %q; expected an OSC 52 sequence", method, term.String())
+	}
+}
+
+// TestCopyToClipboard_Command tests that an installed clipboard program gets the text.
+func TestCopyToClipboard_Command(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("uses a shell script standing in for xclip")
+	}
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	out := filepath.Join(tmpDir, "clipboard")
+	script := "#!/bin/sh\ncat > '" + out + "'\n"
+	if err := os.WriteFile(filepath.Join(tmpDir, "xclip"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", tmpDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("WAYLAND_DISPLAY", "")
+	t.Setenv("SSH_TTY", "")
+	t.Setenv("SSH_CONNECTION", "")
+
+	var term bytes.Buffer
+	method, err := copyToClipboard("/tmp/a.txt", &term)
+	if err != nil {
+		t.Fatalf("copyToClipboard() returned error: %v", err)
+	}
+	if method != "xclip" || term.Len() != 0 {
+		t.Errorf("copyToClipboard() = %q, wrote %q to the terminal; expected xclip", method, term.String())
+	}
+	if got, _ := os.ReadFile(out); string(got) != "/tmp/a.txt" {
+		t.Errorf("clipboard holds %q, expected %q", got, "/tmp/a.txt")
+	}
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+const (
+	// shingleWords is the number of consecutive words hashed together as one
+	// shingle when comparing the content of files.
+	shingleWords = 3
+	// minHashSize is the number of hash functions in a MinHash signature.
+	minHashSize = 64
+	// minHashBands is the number of bands signatures are split into to find
+	// candidate pairs: files whose signatures agree on every row of any band.
+	// With 4 rows per band, pairs alike at 70% are found about 99% of the time.
+	minHashBands = 16
+	// clusterMinSimilarity is the estimated share of shingles two files must
+	// have in common to be clustered.
+	clusterMinSimilarity = 0.7
+	// clusterReadLimit caps how much of each file is read for clustering.
+	clusterReadLimit = 1 << 20
+)
+
+// ContentCluster is a set of files whose content is nearly the same although
+// their names don't put them in the same group.
+type ContentCluster struct {
+	Files []string
+	// Similarity is the average estimated share of shingles the pairs of
+	// files have in common.
+	Similarity float64
+}
+
+// minHash is the MinHash signature of the shingles of a text: for each of
+// minHashSize hash functions, the smallest hash of any shingle. The share of
+// positions two signatures agree on estimates the Jaccard similarity of the
+// shingle sets.
+type minHash [minHashSize]uint64
+
+// minHashSeeds seed the hash functions of minHash signatures.
+var minHashSeeds = func() (seeds [minHashSize]uint64) {
+	for i := range seeds {
+		seeds[i] = mix64(uint64(i+1) * 0x9e3779b97f4a7c15)
+	}
+	return seeds
+}()
+
+// mix64 scrambles the bits of x, the finalizer of SplitMix64.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	return x ^ x>>31
+}
+
+// newMinHash returns the signature of the shingles of text, whose words are
+// compared case-insensitively, ignoring how they are spaced. ok is false when
+// text has fewer than shingleWords words.
+func newMinHash(text string) (sig minHash, ok bool) {
+	words := strings.Fields(strings.ToLower(text))
+	if len(words) < shingleWords {
+		return sig, false
+	}
+	for i := range sig {
+		sig[i] = math.MaxUint64
+	}
+	h := fnv.New64a()
+	for i := 0; i+shingleWords <= len(words); i++ {
+		h.Reset()
+		for _, word := range words[i : i+shingleWords] {
+			h.Write([]byte(word))
+			h.Write([]byte{0})
+		}
+		shingle := h.Sum64()
+		for j, seed := range minHashSeeds {
+			sig[j] = min(sig[j], mix64(shingle^seed))
+		}
+	}
+	return sig, true
+}
+
+// similarity estimates the share of shingles two texts have in common.
+func (a *minHash) similarity(b *minHash) float64 {
+	same := 0
+	for i := range a {
+		if a[i] == b[i] {
+			same++
+		}
+	}
+	return float64(same) / minHashSize
+}
+
+// clusterByContent clusters files whose text is nearly the same, by the
+// MinHash signatures of their shingles, leaving out pairs that are already in
+// the same group. Binary files and files of fewer than shingleWords words are
+// skipped. Clusters are ordered by the position of their first file in files.
+func clusterByContent(ctx context.Context, files []string, groups [][]string, progress *Progress) ([]ContentCluster, error) {
+	progress.SetPhase("Clustering")
+
+	var paths []string
+	var sigs []minHash
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		text, ok := clusterText(ctx, file, progress)
+		if !ok {
+			continue
+		}
+		if sig, ok := newMinHash(text); ok {
+			paths = append(paths, file)
+			sigs = append(sigs, sig)
+		}
+	}
+
+	groupOf := make(map[string]int)
+	for i, group := range groups {
+		for _, file := range group {
+			groupOf[file] = i + 1
+		}
+	}
+	sameGroup := func(i, j int) bool {
+		g := groupOf[paths[i]]
+		return g != 0 && g == groupOf[paths[j]]
+	}
+
+	// Files whose signatures agree on a whole band are candidates
+	type bandKey struct {
+		band int
+		rows [minHashSize / minHashBands]uint64
+	}
+	buckets := make(map[bandKey][]int)
+	for i := range sigs {
+		for band := 0; band < minHashBands; band++ {
+			key := bandKey{band: band}
+			copy(key.rows[:], sigs[i][band*len(key.rows):])
+			buckets[key] = append(buckets[key], i)
+		}
+	}
+
+	parent := make([]int, len(paths))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	checked := make(map[[2]int]bool)
+	for _, bucket := range buckets {
+		for x, i := range bucket {
+			for _, j := range bucket[x+1:] {
+				if checked[[2]int{i, j}] || sameGroup(i, j) {
+					continue
+				}
+				checked[[2]int{i, j}] = true
+				if sigs[i].similarity(&sigs[j]) >= clusterMinSimilarity {
+					parent[find(j)] = find(i)
+				}
+			}
+		}
+	}
+
+	members := make(map[int][]int)
+	var roots []int
+	for i := range paths {
+		root := find(i)
+		if members[root] == nil {
+			roots = append(roots, root)
+		}
+		members[root] = append(members[root], i)
+	}
+	var clusters []ContentCluster
+	for _, root := range roots {
+		ids := members[root]
+		if len(ids) < 2 {
+			continue
+		}
+		cluster := ContentCluster{}
+		var total float64
+		for x, i := range ids {
+			cluster.Files = append(cluster.Files, paths[i])
+			for _, j := range ids[x+1:] {
+				total += sigs[i].similarity(&sigs[j])
+			}
+		}
+		cluster.Similarity = total / float64(len(ids)*(len(ids)-1)/2)
+		clusters = append(clusters, cluster)
+	}
+	return clusters, nil
+}
+
+// clusterText returns the text of a file for clustering: the extracted text
+// of a document, or the start of a text file, converted to UTF-8. ok is false
+// for binary files and files that can't be read.
+func clusterText(ctx context.Context, file string, progress *Progress) (string, bool) {
+	if isDocument(file) && !isArchiveMember(file) {
+		text, err := extractText(ctx, file)
+		if err != nil {
+			logger.Debug("not clustered", "path", file, "err", err)
+			return "", false
+		}
+		return text, true
+	}
+
+	var r io.ReadCloser
+	var err error
+	if isArchiveMember(file) {
+		r, _, err = openArchiveMember(file)
+	} else {
+		r, err = os.Open(file)
+	}
+	if err != nil {
+		logger.Debug("not clustered", "path", file, "err", err)
+		return "", false
+	}
+	defer r.Close()
+	data, err := io.ReadAll(io.LimitReader(r, clusterReadLimit+1))
+	progress.AddBytes(int64(len(data)))
+	if err != nil {
+		logger.Debug("not clustered", "path", file, "err", err)
+		return "", false
+	}
+	truncated := len(data) > clusterReadLimit
+	if truncated {
+		data = data[:clusterReadLimit]
+	}
+	enc, ok := detectEncoding(data, truncated)
+	if !ok {
+		return "", false
+	}
+	return decodeText(data, enc), true
+}
+
+// runClusterList is runList for "scan --content-clusters": it writes the
+// groups of opts.page with write and the note of the page, then the content
+// clusters among all scanned files.
+// Returns the number of groups and clusters found.
+func runClusterList(ctx context.Context, opts options, w io.Writer, write func(io.Writer, [][]string) error) (int, error) {
+	progress := NewProgress("Scanning")
+	stop := startProgressPrinter(os.Stderr, progress)
+	files, err := scanFiles(ctx, opts, opts.dir, progress)
+	var groups [][]string
+	if err == nil {
+		groups, err = groupFiles(ctx, opts, files, progress)
+	}
+	var clusters []ContentCluster
+	if err == nil {
+		clusters, err = clusterByContent(ctx, files, groups, progress)
+	}
+	stop()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := write(w, opts.page.slice(groups)); err != nil {
+		return 0, err
+	}
+	writePageNote(w, opts.page, len(groups))
+	return len(groups) + len(clusters), writeClusterList(w, clusters)
+}
+
+// writeClusterList writes the "Content clusters" section of the scan listing.
+func writeClusterList(w io.Writer, clusters []ContentCluster) error {
+	if len(clusters) == 0 {
+		_, err := fmt.Fprintln(w, "\nNo content clusters of similar files with unrelated names found.")
+		return err
+	}
+	fmt.Fprintf(w, "\nContent clusters: %d cluster(s) of files with similar content\n", len(clusters))
+	for i, cluster := range clusters {
+		fmt.Fprintf(w, "\nCluster %d: %d files, %.0f%% alike\n", i+1, len(cluster.Files), cluster.Similarity*100)
+		for _, file := range cluster.Files {
+			if _, err := fmt.Fprintln(w, "  "+file); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
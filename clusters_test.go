@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// words returns n distinct words numbered from from, so texts built from
+// overlapping ranges share shingles and others share none.
+func words(from, n int) string {
+	var s []string
+	for i := from; i < from+n; i++ {
+		s = append(s, fmt.Sprintf("word%d", i))
+	}
+	return strings.Join(s, " ")
+}
+
+// TestMinHash_Similarity tests estimating how alike two texts are.
+func TestMinHash_Similarity(t *testing.T) {
+	a, ok := newMinHash(words(0, 500))
+	if !ok {
+		t.Fatal("newMinHash() should sign a text of 500 words")
+	}
+	same, _ := newMinHash(strings.ToUpper(strings.ReplaceAll(words(0, 500), " ", "\n  ")))
+	if got := a.similarity(&same); got != 1 {
+		t.Errorf("texts differing in case and spacing should be alike, got %.2f", got)
+	}
+	edited, _ := newMinHash(words(0, 480) + " " + words(2000, 20))
+	if got := a.similarity(&edited); got < 0.8 || got == 1 {
+		t.Errorf("a slightly edited text should be mostly alike, got %.2f", got)
+	}
+	other, _ := newMinHash(words(500, 500))
+	if got := a.similarity(&other); got > 0.1 {
+		t.Errorf("unrelated texts should not be alike, got %.2f", got)
+	}
+	if _, ok := newMinHash("two words"); ok {
+		t.Errorf("newMinHash() should refuse texts shorter than a shingle")
+	}
+}
+
+// TestClusterByContent tests clustering near-duplicates with unrelated names.
+func TestClusterByContent(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	minutes := createFileWithContent(t, tmpDir, "minutes.md", words(0, 500))
+	summary := createFileWithContent(t, tmpDir, "summary-final.txt", words(0, 490)+" and one more line")
+	notes := createFileWithContent(t, tmpDir, "notes.md", words(1000, 300))
+	notesCopy := createFileWithContent(t, tmpDir, "notes (1).md", words(1000, 300))
+	other := createFileWithContent(t, tmpDir, "other.md", words(3000, 300))
+	binary := createFileWithContent(t, tmpDir, "minutes.bin", "\x00\x01"+words(0, 500))
+	files := []string{minutes, summary, notes, notesCopy, other, binary}
+	groups := [][]string{{notes, notesCopy}}
+
+	clusters, err := clusterByContent(context.Background(), files, groups, nil)
+	if err != nil {
+		t.Fatalf("clusterByContent() returned error: %v", err)
+	}
+	if len(clusters) != 1 {
+		t.Fatalf("clusterByContent() = %+v, expected one cluster", clusters)
+	}
+	if want := []string{minutes, summary}; !reflect.DeepEqual(clusters[0].Files, want) {
+		t.Errorf("cluster = %v, expected %v", clusters[0].Files, want)
+	}
+	if s := clusters[0].Similarity; s < clusterMinSimilarity {
+		t.Errorf("cluster similarity = %.2f", s)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := clusterByContent(ctx, files, nil, nil); err == nil {
+		t.Errorf("clusterByContent() should stop when cancelled")
+	}
+}
+
+// TestWriteClusterList tests the content clusters section of the scan listing.
+func TestWriteClusterList(t *testing.T) {
+	var buf bytes.Buffer
+	clusters := []ContentCluster{{Files: []string{"minutes.md", "summary.txt"}, Similarity: 0.875}}
+	if err := writeClusterList(&buf, clusters); err != nil {
+		t.Fatal(err)
+	}
+	want := "\nContent clusters: 1 cluster(s) of files with similar content\n\nCluster 1: 2 files, 88% alike\n  minutes.md\n  summary.txt\n"
+	if buf.String() != want {
+		t.Errorf("writeClusterList() = %q, expected %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,992 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"regexp"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const version = "0.1.0"
+
+// command is a doppel subcommand. Each command parses its own flags and returns
+// the process exit code. ctx is cancelled on SIGINT or SIGTERM.
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, args []string) int
+}
+
+// commands lists the subcommands in the order they appear in the usage text.
+// It is populated in init because printUsage refers back to it.
+var commands []command
+
+func init() {
+	commands = []command{
+		{"scan", "List groups of similar files", runScanCommand},
+		{"report", "Write a per-file report of grouped files (text or CSV)", runReportCommand},
+		{"clean", "Remove files that are byte-identical to their group leader", runCleanCommand},
+		{"apply", "Apply a cleanup plan written by 'clean --plan'", runApplyCommand},
+		{"restore", "Move files back out of a --quarantine directory", runRestoreCommand},
+		{"undo", "List or undo file operations of the last interactive session", runUndoCommand},
+		{"diff-scan", "Show groups and files that appeared or went away since a saved report", runDiffScanCommand},
+		{"explain", "Explain why two files are or aren't grouped", runExplainCommand},
+		{"serve", "Serve an HTTP JSON API to scan, list groups, diff, and resolve them", runServeCommand},
+		{"tui", "Compare files interactively (default when no command is given)", runTUICommand},
+	}
+}
+
+// runCommand dispatches to a subcommand. Arguments that don't start with a known
+// command name run the TUI, so "doppel DIR" keeps working.
+// An interrupt cancels the running command instead of killing the process, so
+// scans, hashing, and diff commands stop cleanly.
+func runCommand(args []string) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if len(args) > 0 {
+		switch args[0] {
+		case "--version", "-version", "version":
+			fmt.Printf("doppel version %s\n", version)
+			return 0
+		case "--help", "-help", "-h", "help":
+			printUsage(os.Stdout)
+			return 0
+		case "bench":
+			// For developers, so it is left out of the usage text
+			return runBenchCommand(ctx, args[1:])
+		}
+		for _, cmd := range commands {
+			if cmd.name == args[0] {
+				return cmd.run(ctx, args[1:])
+			}
+		}
+	}
+	return runTUICommand(ctx, args)
+}
+
+// printUsage writes the top-level usage text listing all subcommands.
+func printUsage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: doppel [command] [options] [directory]\n\n")
+	fmt.Fprintf(w, "Scans a directory for files with similar names and helps you compare them.\n\n")
+	fmt.Fprintf(w, "Commands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(w, "\nRun 'doppel <command> --help' for the options of a command.\n")
+	fmt.Fprintf(w, "'doppel [options] [directory]' is the same as 'doppel tui [options] [directory]'.\n")
+	fmt.Fprintf(w, "If no directory is specified, the current directory is used.\n")
+}
+
+// newFlagSet creates a flag set for a subcommand with a usage message that
+// describes the command and lists its flags.
+func newFlagSet(name, description string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: doppel %s [options] [directory]\n\n", name)
+		fmt.Fprintf(fs.Output(), "%s\n\n", description)
+		fmt.Fprintf(fs.Output(), "Options:\n")
+		fs.PrintDefaults()
+		fmt.Fprintf(fs.Output(), "\nIf no directory is specified, the current directory is used.\n")
+	}
+	return fs
+}
+
+// parseFlags parses args, returning ok=false and the exit code to use when
+// parsing fails or help was requested.
+func parseFlags(fs *flag.FlagSet, args []string) (code int, ok bool) {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0, false
+		}
+		return exitError, false
+	}
+	return 0, true
+}
+
+// matchFlags are the scanning and grouping flags shared by every subcommand.
+type matchFlags struct {
+	minPrefix       *int
+	prefixRatio     *float64
+	splitGroups     *float64
+	maxDivergence   *float64
+	suffixPattern   *string
+	datesAsVersions *bool
+	sameExtOnly     *bool
+	stripExt        *bool
+	byContent       *bool
+	groupByRegex    *string
+	copyNames       *bool
+	recursive       *bool
+	hidden          *bool
+	skipSymlinks    *bool
+	skipGitIgnored  *bool
+	scanArchives    *bool
+	statTimeout     *time.Duration
+	scanWorkers     *int
+	minSize         byteSizeFlag
+	maxMemory       byteSizeFlag
+	maxSize         byteSizeFlag
+	newerThan       timeBoundFlag
+	olderThan       timeBoundFlag
+	extensions      stringListFlag
+	excludes        globListFlag
+	profile         *string
+	includeIgnored  *bool
+	noAuto          *bool
+	baseline        *string
+	compare         *bool
+	noCache         *bool
+	clearCache      *bool
+	log             *logFlags
+	// allowRemote accepts an S3 or SSH URL for the directory; only commands
+	// that don't change or open files set it.
+	allowRemote bool
+	// allowArchives accepts --scan-archives; only commands that don't change
+	// files set it.
+	allowArchives bool
+}
+
+// addMatchFlags registers the shared scanning and grouping flags on fs.
+func addMatchFlags(fs *flag.FlagSet) *matchFlags {
+	f := &matchFlags{
+		minPrefix:       fs.Int("min-prefix", defaultMinPrefixLength, "Minimum prefix length for grouping files"),
+		prefixRatio:     fs.Float64("prefix-ratio", 0, "Also require the common prefix to cover at least this fraction of the shorter name, e.g. 0.6 (0 turns it off)"),
+		splitGroups:     fs.Float64("split-groups", 0, "Split groups into subgroups whose names are all at least this alike, from 0 to 1, e.g. 0.5 (0 turns it off)"),
+		maxDivergence:   fs.Float64("max-divergence", 0, "Leave out groups in which every two files differ in content by more than this fraction, e.g. 0.8 for less than 20% alike (0 turns it off)"),
+		suffixPattern:   fs.String("suffix", "", "Only consider files whose names match the indicated suffix pattern (regex)"),
+		datesAsVersions: fs.Bool("dates-as-versions", false, "With --suffix, keep date-suffixed names such as report-2024-01-30 as versions of report instead of excluding them"),
+		sameExtOnly:     fs.Bool("same-ext-only", false, "Only group files whose extensions match"),
+		stripExt:        fs.Bool("strip-ext", false, "Ignore file extensions when comparing names"),
+		byContent:       fs.Bool("by-content", false, "Group files with identical content, regardless of their names"),
+		groupByRegex:    fs.String("group-by-regex", "", "Group files whose names (without extension) yield the same base from this regex: the 'base' named group, the first group, or the whole match"),
+		copyNames:       fs.Bool("copy-names", true, "Strip duplicate markers such as 'Copy of' and ' - Copy' from names before grouping (extend in the config file's copy_prefixes and copy_suffixes)"),
+		recursive:       fs.Bool("recursive", false, "Also scan subdirectories"),
+		hidden:          fs.Bool("hidden", false, "Include dotfiles, dot-directories, and junk files such as .DS_Store, Thumbs.db, and desktop.ini"),
+		skipSymlinks:    fs.Bool("skip-symlinks", false, "Leave symbolic links out instead of following them (links to directories are followed only with --recursive)"),
+		skipGitIgnored:  fs.Bool("skip-git-ignored", false, "Leave out files that git ignores when the directory is inside a git repository"),
+		scanArchives:    fs.Bool("scan-archives", false, "Also scan the files inside .zip archives, listed as archive.zip!/name (scan, report, and tui only)"),
+		scanWorkers:     fs.Int("scan-workers", defaultScanWorkers, "Number of directories read concurrently in recursive scans"),
+		statTimeout:     fs.Duration("stat-timeout", 0, "Skip files whose size or link target, and directories whose entries, aren't read within this time, e.g. 5s, on slow phone or network mounts (0 waits)"),
+		includeIgnored:  fs.Bool("include-ignored", false, "Include groups previously ignored in the TUI (listed in DIR/.doppel/ignored.json)"),
+		noAuto:          fs.Bool("no-auto", false, "Don't apply the defaults of the directory's type, such as --skip-git-ignored in a git repository"),
+		baseline:        fs.String("baseline", "", "Leave out the groups of this earlier 'report --json' output, to show only what is new since"),
+		noCache:         fs.Bool("no-cache", false, "Hash every file instead of reusing hashes from earlier runs"),
+		clearCache:      fs.Bool("clear-cache", false, "Delete the hash cache before scanning"),
+		compare:         fs.Bool("compare", false, "Compare two directory trees, given as DIR and OTHER, matching files by relative path or name across them"),
+	}
+	fs.Var(&f.extensions, "ext", "Only scan files with these extensions, comma-separated (repeatable), e.g. md,txt")
+	fs.Var(&f.excludes, "exclude", "Leave out files and directories whose name matches one of these glob patterns, comma-separated (repeatable), e.g. '*.tmp,node_modules'")
+	f.profile = fs.String("profile", "", "Apply the flags of this profile from the config file")
+	fs.Var(&f.minSize, "min-size", "Skip files smaller than this size, e.g. 1 or 10k")
+	fs.Var(&f.maxSize, "max-size", "Skip files larger than this size, e.g. 5M or 2G")
+	fs.Var(&f.maxMemory, "max-memory", "Keep the tables of --by-content under about this size, spilling the rest to temporary files, and the TUI's diff cache under a quarter of it, e.g. 512M; the list of scanned paths is always kept in memory (default: no limit)")
+	fs.Var(&f.newerThan, "newer-than", "Skip files modified before this date or duration ago, e.g. 2024-01-30 or 7d")
+	fs.Var(&f.olderThan, "older-than", "Skip files modified after this date or duration ago, e.g. 2024-02-01 or 36h")
+	f.log = addLogFlags(fs)
+	return f
+}
+
+// options validates the shared flags and the directory argument of fs.
+func (f *matchFlags) options(fs *flag.FlagSet) (options, error) {
+	// Get directory from arguments or use current directory
+	dir := "."
+	if fs.NArg() > 0 {
+		dir = fs.Arg(0)
+	}
+	var compareDir string
+	if *f.compare && fs.NArg() == 2 {
+		compareDir = fs.Arg(1)
+	}
+	if err := f.autoProfile(fs, dir, compareDir); err != nil {
+		return options{}, err
+	}
+
+	// Checked once the profiles are applied, since they may set any of these flags
+	if *f.compare {
+		if fs.NArg() != 2 {
+			return options{}, errors.New("compare needs exactly two directories")
+		}
+		if *f.byContent || *f.groupByRegex != "" {
+			return options{}, errors.New("compare cannot be combined with by-content or group-by-regex")
+		}
+		compareDir = fs.Arg(1)
+	}
+
+	return f.optionsFor(dir, compareDir)
+}
+
+// autoProfile applies the profile named with --profile and, unless --no-auto
+// is set, those of the config's paths and of dirProfiles that dir matches to
+// the flags of fs not given on the command line; see scanProfiles. Remote
+// locations and compared trees get no automatic profile. The profiles applied
+// are noted on stderr, except with --quiet.
+func (f *matchFlags) autoProfile(fs *flag.FlagSet, dir, compareDir string) error {
+	auto := !*f.noAuto && compareDir == "" && !isRemoteLocation(dir)
+	if *f.profile == "" && !auto {
+		return nil
+	}
+	config, err := LoadConfig()
+	if err != nil {
+		return err
+	}
+	profiles, err := scanProfiles(config, dir, *f.profile, auto)
+	if err != nil {
+		return err
+	}
+	w := io.Writer(os.Stderr)
+	if f.log.quiet {
+		w = io.Discard
+	}
+	return applyProfiles(w, fs, profiles)
+}
+
+// optionsFor validates the shared flags for scanning dir, and with --compare
+// also compareDir.
+func (f *matchFlags) optionsFor(dir, compareDir string) (options, error) {
+	if err := f.log.apply(); err != nil {
+		return options{}, err
+	}
+
+	var storage Storage
+	if isRemoteLocation(dir) || isRemoteLocation(compareDir) {
+		if !f.allowRemote {
+			return options{}, errors.New("only scan and report can read remote locations")
+		}
+		if compareDir != "" || *f.byContent || *f.skipGitIgnored || *f.maxDivergence > 0 {
+			return options{}, errors.New("a remote location cannot be combined with compare, by-content, skip-git-ignored, or max-divergence")
+		}
+		var err error
+		if storage, err = OpenStorage(dir); err != nil {
+			return options{}, err
+		}
+	}
+
+	if *f.scanArchives {
+		if !f.allowArchives {
+			return options{}, errors.New("only scan, report, and tui can scan archives")
+		}
+		if compareDir != "" || *f.byContent || storage != nil {
+			return options{}, errors.New("scan-archives cannot be combined with compare, by-content, or a remote location")
+		}
+	}
+
+	// Validate directories exist
+	for _, d := range []string{dir, compareDir} {
+		if d == "" || storage != nil {
+			continue
+		}
+		info, err := os.Stat(d)
+		if err != nil {
+			return options{}, err
+		}
+		if !info.IsDir() {
+			return options{}, fmt.Errorf("%s is not a directory", d)
+		}
+	}
+
+	// Validate min prefix length
+	if *f.minPrefix < 1 {
+		return options{}, fmt.Errorf("min-prefix must be at least 1")
+	}
+	if *f.prefixRatio < 0 || *f.prefixRatio > 1 {
+		return options{}, fmt.Errorf("prefix-ratio must be between 0 and 1")
+	}
+	if *f.splitGroups < 0 || *f.splitGroups > 1 {
+		return options{}, fmt.Errorf("split-groups must be between 0 and 1")
+	}
+	if *f.maxDivergence < 0 || *f.maxDivergence > 1 {
+		return options{}, fmt.Errorf("max-divergence must be between 0 and 1")
+	}
+
+	if *f.scanWorkers < 1 {
+		return options{}, fmt.Errorf("scan-workers must be at least 1")
+	}
+
+	if *f.statTimeout < 0 {
+		return options{}, fmt.Errorf("stat-timeout must not be negative")
+	}
+
+	excludes := splitList(f.excludes.stringListFlag)
+
+	if f.maxSize > 0 && f.minSize > f.maxSize {
+		return options{}, fmt.Errorf("min-size must not be larger than max-size")
+	}
+
+	newerThan, olderThan := time.Time(f.newerThan), time.Time(f.olderThan)
+	if !newerThan.IsZero() && !olderThan.IsZero() && !newerThan.Before(olderThan) {
+		return options{}, fmt.Errorf("newer-than must be earlier than older-than")
+	}
+
+	// Compile suffix pattern if provided
+	var compiledPattern *regexp.Regexp
+	if *f.suffixPattern != "" {
+		// Anchor pattern to end of string (before extension)
+		// Only add $ if pattern doesn't already end with it to avoid double anchor
+		patternStr := *f.suffixPattern
+		if !strings.HasSuffix(patternStr, "$") {
+			patternStr = patternStr + "$"
+		}
+		pattern, err := regexp.Compile(patternStr)
+		if err != nil {
+			return options{}, fmt.Errorf("invalid suffix pattern: %w", err)
+		}
+		compiledPattern = pattern
+	}
+
+	matchOpts := MatcherOptions{SameExtOnly: *f.sameExtOnly, StripExt: *f.stripExt, PrefixRatio: *f.prefixRatio, SplitSimilarity: *f.splitGroups}
+	if *f.copyNames {
+		config, err := LoadConfig()
+		if err != nil {
+			return options{}, err
+		}
+		matchOpts.CopyNames, err = NewCopyNameRules(config.CopyPrefixes, config.CopySuffixes)
+		if err != nil {
+			return options{}, fmt.Errorf("invalid config: %w", err)
+		}
+	}
+	if *f.groupByRegex != "" {
+		if *f.byContent {
+			return options{}, errors.New("group-by-regex cannot be combined with by-content")
+		}
+		re, err := regexp.Compile(*f.groupByRegex)
+		if err != nil {
+			return options{}, fmt.Errorf("invalid group-by-regex pattern: %w", err)
+		}
+		matchOpts.BaseRegex = re
+	}
+
+	var baseline *Baseline
+	if *f.baseline != "" {
+		if compareDir != "" {
+			return options{}, errors.New("baseline cannot be combined with compare")
+		}
+		var err error
+		if baseline, err = LoadBaseline(*f.baseline); err != nil {
+			return options{}, err
+		}
+		if d := baseline.Dir(); d != dir && d != absPath(dir) {
+			logger.Warn("the baseline is a report of another directory, so its groups won't match", "baseline", baseline.Dir(), "dir", dir)
+		}
+	}
+
+	// Remote locations have no ignore list, and their files are not cached
+	var ignoreList *IgnoreList
+	if storage == nil {
+		var err error
+		if ignoreList, err = LoadIgnoreList(dir); err != nil {
+			return options{}, err
+		}
+	}
+
+	if *f.clearCache {
+		if err := ClearHashCache(); err != nil {
+			return options{}, fmt.Errorf("failed to clear hash cache: %w", err)
+		}
+	}
+	var hashCache *HashCache
+	if !*f.noCache && storage == nil {
+		hashCache = LoadHashCache()
+	}
+
+	return options{
+		dir:             dir,
+		compareDir:      compareDir,
+		minPrefix:       *f.minPrefix,
+		matchOpts:       matchOpts,
+		suffixPattern:   compiledPattern,
+		datesAsVersions: *f.datesAsVersions,
+		byContent:       *f.byContent,
+		recursive:       *f.recursive,
+		scanWorkers:     *f.scanWorkers,
+		minSize:         int64(f.minSize),
+		maxSize:         int64(f.maxSize),
+		newerThan:       newerThan,
+		extensions:      splitList(f.extensions),
+		excludes:        excludes,
+		includeHidden:   *f.hidden,
+		skipSymlinks:    *f.skipSymlinks,
+		skipGitIgnored:  *f.skipGitIgnored,
+		scanArchives:    *f.scanArchives,
+		statTimeout:     *f.statTimeout,
+		olderThan:       olderThan,
+		ignoreList:      ignoreList,
+		includeIgnored:  *f.includeIgnored,
+		baseline:        baseline,
+		maxDivergence:   *f.maxDivergence,
+		maxMemory:       int64(f.maxMemory),
+		hashCache:       hashCache,
+		storage:         storage,
+	}, nil
+}
+
+// pathRuleFlags are the --prefer and --avoid flags that rank directories for
+// choosing which copy to keep.
+type pathRuleFlags struct {
+	prefer stringListFlag
+	avoid  stringListFlag
+}
+
+// addPathRuleFlags registers --prefer and --avoid on fs.
+func addPathRuleFlags(fs *flag.FlagSet) *pathRuleFlags {
+	f := &pathRuleFlags{}
+	fs.Var(&f.prefer, "prefer", "Keep files under this directory over copies elsewhere (repeatable, most preferred first)")
+	fs.Var(&f.avoid, "avoid", "Keep files under this directory only if no copy is elsewhere (repeatable, most preferred first)")
+	return f
+}
+
+// keeper returns a Keeper with policy and the path rules of the flags
+// followed by those of the config file.
+func (f *pathRuleFlags) keeper(policy KeepPolicy) (Keeper, error) {
+	config, err := LoadConfig()
+	if err != nil {
+		return Keeper{}, err
+	}
+	return Keeper{
+		Policy: policy,
+		Prefer: append(slices.Clone(f.prefer), config.PreferPaths...),
+		Avoid:  append(slices.Clone(f.avoid), config.AvoidPaths...),
+	}, nil
+}
+
+// addQuarantineFlag registers --quarantine on fs for commands that remove files.
+func addQuarantineFlag(fs *flag.FlagSet) *string {
+	return fs.String("quarantine", "", "Move removed files into this directory, keeping their relative paths, instead of deleting them (undo with 'doppel restore DIR')")
+}
+
+// addOnlyDifferentFlag registers --only-different on fs for commands that list groups.
+func addOnlyDifferentFlag(fs *flag.FlagSet) *bool {
+	return fs.Bool("only-different", false, "Collapse files identical to an earlier file of their group, leaving out groups of only identical files, to list the files whose contents differ")
+}
+
+// onlyDifferentCopies returns the IdenticalCopies that collapse the groups for
+// --only-different, or nil if it isn't set.
+func onlyDifferentCopies(onlyDifferent bool, opts options) (*IdenticalCopies, error) {
+	if !onlyDifferent {
+		return nil, nil
+	}
+	if opts.byContent || opts.storage != nil {
+		// Groups by content hold only identical files, and remote files aren't hashed
+		return nil, errors.New("only-different cannot be combined with by-content or a remote location")
+	}
+	return NewIdenticalCopies(), nil
+}
+
+// exitWithError prints err and returns the error exit code.
+func exitWithError(err error) int {
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	return exitError
+}
+
+// checkExitCode returns the exit code for a batch command: with --check, finding
+// groups is reported as exitGroupsFound.
+func checkExitCode(check bool, groupCount int) int {
+	if check && groupCount > 0 {
+		return exitGroupsFound
+	}
+	return exitNoGroups
+}
+
+// runScanCommand implements "doppel scan".
+func runScanCommand(ctx context.Context, args []string) int {
+	fs := newFlagSet("scan", "Lists groups of files with similar names.")
+	mf := addMatchFlags(fs)
+	mf.allowRemote = true
+	mf.allowArchives = true
+	check := fs.Bool("check", false, "Exit with status 2 if any groups are found (0 if none, 1 on error)")
+	print0 := fs.Bool("print0", false, "Print file paths terminated by NUL, with an extra NUL after each group, for xargs -0")
+	var algo hashAlgorithm
+	fs.Var(&algo, "hash", "Show a checksum after each file: sha256, or xxh3 for speed on large files")
+	contentClusters := fs.Bool("content-clusters", false, "Also list clusters of files with nearly the same text whose names are unrelated")
+	onlyDifferent := addOnlyDifferentFlag(fs)
+	pf := addPageFlags(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	// Conflicts are checked after the profiles of mf.options have set their flags
+	opts, err := mf.options(fs)
+	if err != nil {
+		return exitWithError(err)
+	}
+	if *print0 && algo != "" {
+		return exitWithError(errors.New("hash and print0 cannot be combined"))
+	}
+	if *contentClusters && *print0 {
+		return exitWithError(errors.New("content-clusters and print0 cannot be combined"))
+	}
+	if *contentClusters && *onlyDifferent {
+		return exitWithError(errors.New("content-clusters and only-different cannot be combined"))
+	}
+	if algo != "" && opts.storage != nil {
+		return exitWithError(errors.New("hash cannot be used with a remote location; use 'doppel report' instead"))
+	}
+	if algo != "" && opts.scanArchives {
+		return exitWithError(errors.New("hash cannot be combined with scan-archives; use 'doppel report' instead"))
+	}
+	if *contentClusters && (opts.storage != nil || opts.compareDir != "") {
+		return exitWithError(errors.New("content-clusters cannot be combined with compare or a remote location"))
+	}
+	if opts.identical, err = onlyDifferentCopies(*onlyDifferent, opts); err != nil {
+		return exitWithError(err)
+	}
+	if opts.page, err = pf.page(); err != nil {
+		return exitWithError(err)
+	}
+	checksums := NewChecksums(algo)
+	write := func(w io.Writer, groups [][]string) error {
+		return writeGroupListWithChecksums(w, opts.dir, groups, checksums, opts.identical, opts.page)
+	}
+	notes := io.Writer(os.Stdout)
+	if *print0 {
+		write = writeGroupsNul
+		notes = os.Stderr
+	}
+	list := runList
+	if *contentClusters {
+		list = runClusterList
+	}
+	groupCount, err := list(ctx, opts, os.Stdout, write)
+	if err != nil {
+		return exitWithError(err)
+	}
+	if !*contentClusters {
+		writePageNote(notes, opts.page, groupCount)
+	}
+	writeIdenticalNote(notes, opts.identical)
+	return checkExitCode(*check, groupCount)
+}
+
+// runReportCommand implements "doppel report".
+func runReportCommand(ctx context.Context, args []string) int {
+	fs := newFlagSet("report", "Writes one entry per grouped file with its size, modification time,\nhash, and whether it is identical to the first file of its group.")
+	mf := addMatchFlags(fs)
+	mf.allowRemote = true
+	mf.allowArchives = true
+	csvOutput := fs.Bool("csv", false, "Write the report as CSV")
+	jsonOutput := fs.Bool("json", false, "Write the report as JSON, with a fingerprint of each group for --baseline")
+	yamlOutput := fs.Bool("yaml", false, "Write the report as YAML, with the fields of --json")
+	junitOutput := fs.Bool("junit", false, "Write the report as JUnit XML with a failed test case per group, for CI systems")
+	print0 := fs.Bool("print0", false, "Print only file paths, terminated by NUL, with an extra NUL after each group, for xargs -0")
+	sqlitePath := fs.String("sqlite", "", "Add the report, with a similarity score for each pair of files, to this SQLite database instead of printing it (needs the sqlite3 command)")
+	check := fs.Bool("check", false, "Exit with status 2 if any groups are found (0 if none, 1 on error)")
+	onlyDifferent := addOnlyDifferentFlag(fs)
+	pf := addPageFlags(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	// Conflicts are checked after the profiles of mf.options have set their flags
+	opts, err := mf.options(fs)
+	if err != nil {
+		return exitWithError(err)
+	}
+	if *csvOutput && *print0 {
+		return exitWithError(errors.New("csv and print0 cannot be combined"))
+	}
+	if *sqlitePath != "" && (*csvOutput || *print0) {
+		return exitWithError(errors.New("sqlite cannot be combined with csv or print0"))
+	}
+	if *jsonOutput && (*csvOutput || *print0 || *sqlitePath != "") {
+		return exitWithError(errors.New("json cannot be combined with csv, print0, or sqlite"))
+	}
+	if *yamlOutput && (*csvOutput || *jsonOutput || *print0 || *sqlitePath != "") {
+		return exitWithError(errors.New("yaml cannot be combined with csv, json, print0, or sqlite"))
+	}
+	if *junitOutput && (*csvOutput || *jsonOutput || *yamlOutput || *print0 || *sqlitePath != "") {
+		return exitWithError(errors.New("junit cannot be combined with csv, json, yaml, print0, or sqlite"))
+	}
+	if *sqlitePath != "" && opts.storage != nil {
+		return exitWithError(errors.New("sqlite cannot be used with a remote location"))
+	}
+	if *sqlitePath != "" && opts.scanArchives {
+		return exitWithError(errors.New("sqlite cannot be combined with scan-archives"))
+	}
+	if opts.identical, err = onlyDifferentCopies(*onlyDifferent, opts); err != nil {
+		return exitWithError(err)
+	}
+	if opts.page, err = pf.page(); err != nil {
+		return exitWithError(err)
+	}
+	if *sqlitePath != "" && opts.page != (groupPage{}) {
+		return exitWithError(errors.New("sqlite cannot be combined with offset or limit"))
+	}
+	write := func(w io.Writer, records []FileRecord) error {
+		return writeTextReportPage(w, records, opts.page)
+	}
+	notes := io.Writer(os.Stderr)
+	switch {
+	case *csvOutput:
+		write = withStderrSummary(opts.page, writeCSV)
+	case *print0:
+		write = withStderrSummary(opts.page, writeNulReport)
+	case *jsonOutput:
+		write = withStderrSummary(opts.page, func(w io.Writer, records []FileRecord) error {
+			return writeJSONReport(w, opts.dir, records)
+		})
+	case *yamlOutput:
+		write = withStderrSummary(opts.page, func(w io.Writer, records []FileRecord) error {
+			return writeYAMLReport(w, opts.dir, records)
+		})
+	case *junitOutput:
+		write = withStderrSummary(opts.page, func(w io.Writer, records []FileRecord) error {
+			return writeJUnitReport(w, opts.dir, records)
+		})
+	case *sqlitePath != "":
+		write = withStderrSummary(opts.page, func(_ io.Writer, records []FileRecord) error {
+			return writeSQLite(ctx, *sqlitePath, opts.dir, records)
+		})
+	}
+	if !*csvOutput && !*print0 && !*jsonOutput && !*yamlOutput && !*junitOutput && *sqlitePath == "" {
+		notes = os.Stdout
+	}
+	groupCount, err := runReport(ctx, opts, os.Stdout, write)
+	if err != nil {
+		return exitWithError(err)
+	}
+	writePageNote(notes, opts.page, groupCount)
+	writeIdenticalNote(notes, opts.identical)
+	return checkExitCode(*check, groupCount)
+}
+
+// runCleanCommand implements "doppel clean".
+func runCleanCommand(ctx context.Context, args []string) int {
+	fs := newFlagSet("clean", "Removes files whose content is byte-identical to the first file of their group.\nWithout --force, only prints what would be removed.")
+	mf := addMatchFlags(fs)
+	var cleanOpts cleanOptions
+	fs.BoolVar(&cleanOpts.force, "force", false, "Actually remove files (default is a dry run)")
+	fs.StringVar(&cleanOpts.planPath, "plan", "", "Write the cleanup plan as JSON (YAML for .yaml or .yml) to this file without touching any files")
+	fs.BoolVar(&cleanOpts.hardlink, "hardlink", false, "Replace identical copies with hard links to the kept file instead of removing them")
+	fs.Var(&cleanOpts.keep.Policy, "keep", "Which of the identical files to keep: first (the group leader), newest, oldest, largest, or shortest-name; --prefer and --avoid take precedence")
+	pathRules := addPathRuleFlags(fs)
+	fs.BoolVar(&cleanOpts.identicalOnly, "identical-only", false, "Only resolve groups whose files are all byte-identical, leaving groups with differing versions alone")
+	quarantine := addQuarantineFlag(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	opts, err := mf.options(fs)
+	if err != nil {
+		return exitWithError(err)
+	}
+	if opts.compareDir != "" {
+		// Removing "redundant" copies would delete the backup being verified
+		return exitWithError(errors.New("compare cannot be used with clean"))
+	}
+	if opts.quarantine, err = openQuarantine(*quarantine, opts); err != nil {
+		return exitWithError(err)
+	}
+	if cleanOpts.keep, err = pathRules.keeper(cleanOpts.keep.Policy); err != nil {
+		return exitWithError(err)
+	}
+	if err := runClean(ctx, opts, cleanOpts, os.Stdout); err != nil {
+		return exitWithError(err)
+	}
+	return 0
+}
+
+// runApplyCommand implements "doppel apply".
+func runApplyCommand(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("apply", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: doppel apply PLAN\n\n")
+		fmt.Fprintf(fs.Output(), "Applies a cleanup plan written by 'doppel clean --plan'. Each file is hashed\n")
+		fmt.Fprintf(fs.Output(), "again first, and files that changed since the plan was written are skipped.\n\n")
+		fs.PrintDefaults()
+	}
+	quarantine := addQuarantineFlag(fs)
+	logFlags := addLogFlags(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitError
+	}
+	if err := logFlags.apply(); err != nil {
+		return exitWithError(err)
+	}
+
+	if err := runApply(ctx, fs.Arg(0), *quarantine, os.Stdout); err != nil {
+		return exitWithError(err)
+	}
+	return 0
+}
+
+// runRestoreCommand implements "doppel restore".
+func runRestoreCommand(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: doppel restore DIR\n\n")
+		fmt.Fprintf(fs.Output(), "Moves every file quarantined in DIR by --quarantine back to where it was\n")
+		fmt.Fprintf(fs.Output(), "removed from. Files whose original path is taken again stay in DIR.\n")
+	}
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitError
+	}
+
+	if err := restoreQuarantine(fs.Arg(0), os.Stdout); err != nil {
+		return exitWithError(err)
+	}
+	return 0
+}
+
+// runUndoCommand implements "doppel undo".
+func runUndoCommand(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("undo", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: doppel undo [--last]\n\n")
+		fmt.Fprintf(fs.Output(), "Lists the deletions, renames, moves, and hard links of the last TUI session\n")
+		fmt.Fprintf(fs.Output(), "that can still be undone, newest first.\n\n")
+		fs.PrintDefaults()
+	}
+	last := fs.Bool("last", false, "Undo the newest operation")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return exitError
+	}
+
+	if err := runUndo(os.Stdout, *last); err != nil {
+		return exitWithError(err)
+	}
+	return 0
+}
+
+// runDiffScanCommand implements "doppel diff-scan".
+func runDiffScanCommand(ctx context.Context, args []string) int {
+	fs := newFlagSet("diff-scan", "Compares the groups found now with a report saved by 'report --csv' and lists\nnew groups, resolved groups, and files that newly joined a group.")
+	mf := addMatchFlags(fs)
+	previous := fs.String("previous", "", "CSV report of the earlier scan to compare against (required)")
+	save := fs.String("save", "", "Write the current analysis as a CSV report to this file, e.g. the same file as --previous")
+	check := fs.Bool("check", false, "Exit with status 2 if new groups or files appeared (0 if not, 1 on error)")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if *previous == "" {
+		return exitWithError(errors.New("diff-scan needs --previous"))
+	}
+
+	opts, err := mf.options(fs)
+	if err != nil {
+		return exitWithError(err)
+	}
+	changes, err := runDiffScan(ctx, opts, *previous, *save, os.Stdout)
+	if err != nil {
+		return exitWithError(err)
+	}
+	return checkExitCode(*check, len(changes.NewFiles))
+}
+
+// runExplainCommand implements "doppel explain".
+func runExplainCommand(ctx context.Context, args []string) int {
+	fs := newFlagSet("explain", "")
+	mf := addMatchFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: doppel explain [options] FILE1 FILE2\n\n")
+		fmt.Fprintf(fs.Output(), "Explains why scanning the directory of FILE1 and FILE2 with the same options\n")
+		fmt.Fprintf(fs.Output(), "groups them or not: whether the scan keeps them, what the suffix filter makes\n")
+		fmt.Fprintf(fs.Output(), "of them, and their common prefix against --min-prefix and --prefix-ratio.\n\n")
+		fmt.Fprintf(fs.Output(), "Options:\n")
+		fs.PrintDefaults()
+	}
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return exitError
+	}
+	var files [2]string
+	for i := range files {
+		file, err := absolutePath(fs.Arg(i))
+		if err != nil {
+			return exitWithError(err)
+		}
+		files[i] = file
+	}
+	dir, recursive, err := explainDir(files[0], files[1])
+	if err != nil {
+		return exitWithError(err)
+	}
+	if err := mf.autoProfile(fs, dir, ""); err != nil {
+		return exitWithError(err)
+	}
+	if *mf.compare {
+		return exitWithError(errors.New("compare cannot be used with explain"))
+	}
+	opts, err := mf.optionsFor(dir, "")
+	if err != nil {
+		return exitWithError(err)
+	}
+	if recursive && !opts.recursive {
+		fmt.Printf("The files are in different directories, so %s is scanned recursively.\n", dir)
+		opts.recursive = true
+	}
+	if err := runExplain(ctx, opts, files[0], files[1], os.Stdout); err != nil {
+		return exitWithError(err)
+	}
+	return 0
+}
+
+// runServeCommand implements "doppel serve".
+func runServeCommand(ctx context.Context, args []string) int {
+	fs := newFlagSet("serve", "Scans a directory and serves an HTTP JSON API to list groups, diff files,\nand delete, hard-link, or ignore duplicates, for web frontends and other tools.")
+	mf := addMatchFlags(fs)
+	addr := fs.String("addr", defaultServeAddr, "Address to listen on; use e.g. :8080 to accept connections from other hosts")
+	diffTool := fs.String("diff-tool", "", "Override default diff command, optionally with arguments and {1}/{2} file placeholders (default: 'diff')")
+	quarantine := addQuarantineFlag(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	opts, err := mf.options(fs)
+	if err != nil {
+		return exitWithError(err)
+	}
+	if opts.quarantine, err = openQuarantine(*quarantine, opts); err != nil {
+		return exitWithError(err)
+	}
+	opts.diffExec, err = NewDiffExecutorFromTemplate(*diffTool, nil)
+	if err != nil {
+		return exitWithError(fmt.Errorf("invalid diff tool: %w", err))
+	}
+	opts.diffExec.SetCacheLimit(diffCacheLimit(opts.maxMemory))
+
+	if err := runServe(ctx, opts, *addr); err != nil {
+		return exitWithError(err)
+	}
+	return 0
+}
+
+// runTUICommand implements "doppel tui", which is also the default command.
+func runTUICommand(ctx context.Context, args []string) int {
+	fs := newFlagSet("tui", "Scans a directory for files with similar names and provides an interactive interface\nto compare them using side-by-side diffs.")
+	mf := addMatchFlags(fs)
+	mf.allowArchives = true
+	diffTool := fs.String("diff-tool", "", "Override default diff command, optionally with arguments and {1}/{2} file placeholders (default: 'diff')")
+	mergeTool := fs.String("merge-tool", defaultMergeTool, "Interactive diff/merge tool opened with 'o' in the TUI (e.g. vimdiff, meld, kdiff3), optionally with {1}/{2} placeholders")
+	showIdentical := fs.Bool("show-identical", false, "In compare-all mode, stop at byte-identical pairs instead of skipping them")
+	imagePreview := fs.Bool("image-preview", false, "Let I in the diff view show low-resolution image previews in terminals that support the kitty graphics protocol")
+	syntax := fs.Bool("syntax", false, "Color the code in side-by-side and unified diffs of known file types (Go, Python, JavaScript, JSON, Markdown, and more)")
+	frontmatter := fs.Bool("frontmatter", false, "Compare the YAML frontmatter of Markdown notes key by key and diff only their bodies (Obsidian, Jekyll, Hugo)")
+	theme := fs.String("theme", "", "Color theme: "+strings.Join(themeNames(), ", ")+" (default: $DOPPEL_THEME, or monochrome if NO_COLOR is set or output is not a terminal)")
+	var diffArgs stringListFlag
+	fs.Var(&diffArgs, "diff-arg", "Extra argument to pass to the diff tool (repeatable)")
+	ignoreWS := fs.Bool("diff-ignore-ws", false, "Ignore whitespace differences in diffs, so reindented lines compare equal (toggle with 'w' in the diff view)")
+	ignoreEOL := fs.Bool("diff-ignore-eol", false, "Ignore CRLF vs LF line endings in diffs")
+	diffTimeout := fs.Duration("diff-timeout", defaultDiffTimeout, "Kill a diff command that runs longer than this (0 for no limit)")
+	diffMaxOutput := fs.Int64("diff-max-output", defaultDiffMaxOutput, "Truncate diff output after this many bytes (0 for no limit)")
+	fullPaths := fs.Bool("full-paths", false, "Show files as paths relative to the scanned directory instead of base names (toggle with p)")
+	suggest := fs.String("suggest", string(KeepNewest), "Mark the file of each group most likely worth keeping: newest, oldest, largest, shortest-name, or none")
+	pathRules := addPathRuleFlags(fs)
+	var algo hashAlgorithm
+	fs.Var(&algo, "hash", "Show a checksum next to each file, sha256 or xxh3, and hash files again before deleting or linking them to catch changes made meanwhile, e.g. by a sync client")
+	noTUI := fs.Bool("no-tui", false, "Use line-based prompts instead of the full-screen TUI (automatic when output is not a terminal or TERM=dumb)")
+	gitStatus := fs.Bool("git-status", false, "Show the git status of each file (modified, untracked, ...) when the directory is inside a git repository")
+	noAltScreen := fs.Bool("no-altscreen", false, "Render the TUI inline instead of on the alternate screen, so its last screen stays in the scrollback after quitting")
+	quarantine := addQuarantineFlag(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	opts, err := mf.options(fs)
+	if err != nil {
+		return exitWithError(err)
+	}
+	if opts.quarantine, err = openQuarantine(*quarantine, opts); err != nil {
+		return exitWithError(err)
+	}
+
+	// Build the diff executor from the tool command line and any extra arguments
+	opts.diffExec, err = NewDiffExecutorFromTemplate(*diffTool, diffArgs)
+	if err != nil {
+		return exitWithError(fmt.Errorf("invalid diff tool: %w", err))
+	}
+	opts.diffExec.SetIgnoreWhitespace(*ignoreWS)
+	opts.diffExec.SetIgnoreEOL(*ignoreEOL)
+	opts.diffExec.SetFrontmatter(*frontmatter)
+	if *diffTimeout < 0 || *diffMaxOutput < 0 {
+		return exitWithError(errors.New("diff-timeout and diff-max-output must not be negative"))
+	}
+	opts.diffExec.SetTimeout(*diffTimeout)
+	opts.diffExec.SetMaxOutput(*diffMaxOutput)
+	opts.diffExec.SetCacheLimit(diffCacheLimit(opts.maxMemory))
+	opts.mergeTool, err = NewMergeTool(*mergeTool)
+	if err != nil {
+		return exitWithError(fmt.Errorf("invalid merge tool: %w", err))
+	}
+	opts.imagePreview = *imagePreview
+	opts.syntax = *syntax
+	opts.showIdentical = *showIdentical
+	opts.fullPaths = *fullPaths
+	opts.noTUI = *noTUI || !isTerminal(os.Stdout) || os.Getenv("TERM") == "dumb"
+	if opts.noTUI && opts.scanArchives {
+		return exitWithError(errors.New("scan-archives needs the full-screen TUI"))
+	}
+	opts.noAltScreen = *noAltScreen
+	if opts.git, err = FindGitRepo(opts.dir); err != nil {
+		logger.Debug("git not available", "err", err)
+	}
+	opts.gitStatus = *gitStatus
+	if opts.gitStatus && opts.git == nil {
+		logger.Warn("not inside a git repository, so no git status is shown", "dir", opts.dir)
+	}
+	opts.journal = NewJournal()
+	opts.checksums = NewChecksums(algo)
+	if *suggest != "none" {
+		var policy KeepPolicy
+		if err := policy.Set(*suggest); err != nil {
+			return exitWithError(fmt.Errorf("invalid suggest policy: %w", err))
+		}
+		keeper, err := pathRules.keeper(policy)
+		if err != nil {
+			return exitWithError(err)
+		}
+		opts.keeper = &keeper
+	}
+	config, err := LoadConfig()
+	if err != nil {
+		return exitWithError(err)
+	}
+	if opts.actions, err = NewGroupActions(config.Actions); err != nil {
+		return exitWithError(fmt.Errorf("invalid config: %w", err))
+	}
+	palette, err := selectTheme(*theme)
+	if err != nil {
+		return exitWithError(err)
+	}
+	applyTheme(palette)
+
+	if err := run(ctx, opts); err != nil {
+		return exitWithError(err)
+	}
+	return 0
+}
+
+// splitList splits comma-separated flag values into their non-empty, trimmed items.
+func splitList(values []string) []string {
+	var items []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
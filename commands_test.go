@@ -0,0 +1,215 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRunCommand_ExitCodes tests dispatch to subcommands and their exit codes.
+func TestRunCommand_ExitCodes(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	createFile(t, tmpDir, "document.txt")
+	createFile(t, tmpDir, "document-1.txt")
+
+	emptyDir := createTempDir(t)
+	defer os.RemoveAll(emptyDir)
+
+	cacheDir := createTempDir(t)
+	defer os.RemoveAll(cacheDir)
+	t.Setenv(cacheEnvVar, filepath.Join(cacheDir, "hashes.json"))
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected int
+	}{
+		{"Version", []string{"--version"}, 0},
+		{"Help", []string{"--help"}, 0},
+		{"Subcommand help", []string{"scan", "--help"}, 0},
+		{"Unknown flag", []string{"scan", "--bogus"}, exitError},
+		{"Missing directory", []string{"scan", "/nonexistent/directory/path"}, exitError},
+		{"Invalid min-prefix", []string{"scan", "--min-prefix", "0", tmpDir}, exitError},
+		{"Scan without check", []string{"scan", tmpDir}, exitNoGroups},
+		{"Scan check with groups", []string{"scan", "--check", tmpDir}, exitGroupsFound},
+		{"Scan check without groups", []string{"scan", "--check", emptyDir}, exitNoGroups},
+		{"Scan by content with groups", []string{"scan", "--by-content", "--check", tmpDir}, exitGroupsFound},
+		{"Scan by regex with groups", []string{"scan", "--group-by-regex", `^(.+?)(-\d+)?$`, "--check", tmpDir}, exitGroupsFound},
+		{"Invalid group-by-regex", []string{"scan", "--group-by-regex", "(", tmpDir}, exitError},
+		{"Scan with size limits", []string{"scan", "--min-size", "1", "--max-size", "1k", "--check", tmpDir}, exitGroupsFound},
+		{"Invalid size", []string{"scan", "--max-size", "big", tmpDir}, exitError},
+		{"Min size above max size", []string{"scan", "--min-size", "2k", "--max-size", "1k", tmpDir}, exitError},
+		{"Scan with time window", []string{"scan", "--newer-than", "1w", "--older-than", "2099-01-01", "--check", tmpDir}, exitGroupsFound},
+		{"Scan outside time window", []string{"scan", "--older-than", "2000-01-01", "--check", tmpDir}, exitNoGroups},
+		{"Scan other extensions", []string{"scan", "--ext", "md,org", "--check", tmpDir}, exitNoGroups},
+		{"Scan matching extension", []string{"scan", "--ext", "md", "--ext", "txt", "--check", tmpDir}, exitGroupsFound},
+		{"Invalid time", []string{"scan", "--newer-than", "yesterday", tmpDir}, exitError},
+		{"Empty time window", []string{"scan", "--newer-than", "2024-02-01", "--older-than", "2024-01-01", tmpDir}, exitError},
+		{"Scan print0", []string{"scan", "--print0", tmpDir}, 0},
+		{"Report csv and print0", []string{"report", "--csv", "--print0", tmpDir}, exitError},
+		{"Report check with groups", []string{"report", "--csv", "--check", tmpDir}, exitGroupsFound},
+		{"Clean dry run", []string{"clean", tmpDir}, 0},
+		{"Diff-scan without previous", []string{"diff-scan", tmpDir}, exitError},
+		{"Diff-scan missing previous", []string{"diff-scan", "--previous", "/nonexistent/report.csv", tmpDir}, exitError},
+		{"Report without cache", []string{"report", "--no-cache", "--check", tmpDir}, exitGroupsFound},
+		{"Report after clearing cache", []string{"report", "--clear-cache", "--check", tmpDir}, exitGroupsFound},
+		{"Compare trees with matches", []string{"scan", "--compare", "--check", tmpDir, tmpDir}, exitGroupsFound},
+		{"Compare trees without matches", []string{"scan", "--compare", "--check", tmpDir, emptyDir}, exitNoGroups},
+		{"Compare one directory", []string{"scan", "--compare", tmpDir}, exitError},
+		{"Compare by content", []string{"scan", "--compare", "--by-content", tmpDir, emptyDir}, exitError},
+		{"Compare clean", []string{"clean", "--compare", tmpDir, emptyDir}, exitError},
+		{"Quarantine inside scanned directory", []string{"clean", "--quarantine", filepath.Join(tmpDir, "q"), tmpDir}, exitError},
+		{"Restore without directory", []string{"restore"}, exitError},
+		{"Restore from non-quarantine", []string{"restore", emptyDir}, exitError},
+		{"Clean remote location", []string{"clean", "s3://bucket/photos"}, exitError},
+		{"Scan remote by content", []string{"scan", "--by-content", "s3://bucket/photos"}, exitError},
+		{"Scan remote with hash", []string{"scan", "--hash", "sha256", "s3://bucket/photos"}, exitError},
+		{"Scan location without bucket", []string{"scan", "s3://"}, exitError},
+		{"Clean with scan-archives", []string{"clean", "--scan-archives", tmpDir}, exitError},
+		{"Scan archives by content", []string{"scan", "--scan-archives", "--by-content", tmpDir}, exitError},
+		{"Scan archives", []string{"scan", "--scan-archives", "--check", tmpDir}, exitGroupsFound},
+		{"Scan content clusters", []string{"scan", "--content-clusters", "--check", tmpDir}, exitGroupsFound},
+		{"Content clusters with print0", []string{"scan", "--content-clusters", "--print0", tmpDir}, exitError},
+		{"Only different leaves out identical groups", []string{"report", "--only-different", "--check", tmpDir}, exitNoGroups},
+		{"Only different by content", []string{"scan", "--only-different", "--by-content", tmpDir}, exitError},
+		{"Only different with content clusters", []string{"scan", "--only-different", "--content-clusters", tmpDir}, exitError},
+		{"Clean only different", []string{"clean", "--only-different", tmpDir}, exitError},
+		{"Content clusters of remote location", []string{"scan", "--content-clusters", "s3://bucket/notes"}, exitError},
+		{"Report as JSON", []string{"report", "--json", "--check", tmpDir}, exitGroupsFound},
+		{"JSON with csv", []string{"report", "--json", "--csv", tmpDir}, exitError},
+		{"Report as YAML", []string{"report", "--yaml", "--check", tmpDir}, exitGroupsFound},
+		{"Negative split similarity", []string{"scan", "--split-groups", "-0.5", tmpDir}, exitError},
+		{"Max divergence above 1", []string{"scan", "--max-divergence", "2", tmpDir}, exitError},
+		{"Max divergence of remote location", []string{"scan", "--max-divergence", "0.8", "s3://bucket/notes"}, exitError},
+		{"Max divergence", []string{"scan", "--max-divergence", "0.8", "--check", tmpDir}, exitGroupsFound},
+		{"Exclude", []string{"scan", "--exclude", "document*", "--check", tmpDir}, exitNoGroups},
+		{"Invalid exclude pattern", []string{"scan", "--exclude", "[", tmpDir}, exitError},
+		{"Invalid exclude pattern in list", []string{"scan", "--exclude", "*.tmp,[a-", tmpDir}, exitError},
+		{"Unknown profile", []string{"scan", "--profile", "missing", tmpDir}, exitError},
+		{"By content with max memory", []string{"scan", "--by-content", "--max-memory", "1k", "--check", tmpDir}, exitGroupsFound},
+		{"Invalid max memory", []string{"scan", "--max-memory", "lots", tmpDir}, exitError},
+		{"Bench", []string{"bench", "--files", "20", "--dirs", "2", "--runs", "1"}, 0},
+		{"Bench with every file a copy", []string{"bench", "--duplicates", "1"}, exitError},
+		{"Bench with a directory", []string{"bench", tmpDir}, exitError},
+		{"Prefix ratio above 1", []string{"scan", "--prefix-ratio", "1.5", tmpDir}, exitError},
+		{"Prefix ratio", []string{"scan", "--prefix-ratio", "0.6", "--check", tmpDir}, exitGroupsFound},
+		{"YAML with JSON", []string{"report", "--yaml", "--json", tmpDir}, exitError},
+		{"Report as JUnit", []string{"report", "--junit", "--check", tmpDir}, exitGroupsFound},
+		{"JUnit with CSV", []string{"report", "--junit", "--csv", tmpDir}, exitError},
+		{"Missing baseline", []string{"scan", "--baseline", filepath.Join(tmpDir, "missing.json"), tmpDir}, exitError},
+		{"Scan a page", []string{"scan", "--offset", "1", "--limit", "1", "--check", tmpDir}, exitGroupsFound},
+		{"Report past the last page", []string{"report", "--offset", "100", "--check", tmpDir}, exitGroupsFound},
+		{"Negative limit", []string{"report", "--limit", "-1", tmpDir}, exitError},
+		{"Page of sqlite report", []string{"report", "--limit", "1", "--sqlite", filepath.Join(tmpDir, "r.db"), tmpDir}, exitError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := runCommand(tt.args); got != tt.expected {
+				t.Errorf("runCommand(%v) = %d, expected %d", tt.args, got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestParseSize tests parsing sizes with and without units.
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"0", 0},
+		{"512", 512},
+		{"10k", 10 << 10},
+		{"10K", 10 << 10},
+		{"10KB", 10 << 10},
+		{"5M", 5 << 20},
+		{"5MiB", 5 << 20},
+		{"1.5G", 3 << 29},
+		{"2t", 2 << 40},
+	}
+	for _, tt := range tests {
+		got, err := parseSize(tt.input)
+		if err != nil {
+			t.Errorf("parseSize(%q) returned error: %v", tt.input, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("parseSize(%q) = %d, expected %d", tt.input, got, tt.expected)
+		}
+	}
+
+	for _, input := range []string{"", "k", "ten", "-1", "5X"} {
+		if _, err := parseSize(input); err == nil {
+			t.Errorf("parseSize(%q) should fail", input)
+		}
+	}
+}
+
+// TestParseTimeBound tests parsing dates and durations before now.
+func TestParseTimeBound(t *testing.T) {
+	now := time.Date(2024, 2, 10, 12, 0, 0, 0, time.Local)
+	tests := []struct {
+		input    string
+		expected time.Time
+	}{
+		{"2024-01-30", time.Date(2024, 1, 30, 0, 0, 0, 0, time.Local)},
+		{"2024-01-30 14:05", time.Date(2024, 1, 30, 14, 5, 0, 0, time.Local)},
+		{"2024-01-30T14:05:06", time.Date(2024, 1, 30, 14, 5, 6, 0, time.Local)},
+		{"2024-01-30T14:05:06Z", time.Date(2024, 1, 30, 14, 5, 6, 0, time.UTC)},
+		{"36h", now.Add(-36 * time.Hour)},
+		{"90m", now.Add(-90 * time.Minute)},
+		{"7d", now.AddDate(0, 0, -7)},
+		{"2w", now.AddDate(0, 0, -14)},
+	}
+	for _, tt := range tests {
+		got, err := parseTimeBound(tt.input, now)
+		if err != nil {
+			t.Errorf("parseTimeBound(%q) returned error: %v", tt.input, err)
+			continue
+		}
+		if !got.Equal(tt.expected) {
+			t.Errorf("parseTimeBound(%q) = %v, expected %v", tt.input, got, tt.expected)
+		}
+	}
+
+	for _, input := range []string{"", "yesterday", "-2d", "2024-13-01", "d"} {
+		if _, err := parseTimeBound(input, now); err == nil {
+			t.Errorf("parseTimeBound(%q) should fail", input)
+		}
+	}
+}
+
+// TestSplitList tests splitting comma-separated and repeated flag values.
+func TestSplitList(t *testing.T) {
+	got := splitList([]string{"md,txt", " org ", "a,,b,"})
+	want := []string{"md", "txt", "org", "a", "b"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("splitList() = %v, expected %v", got, want)
+	}
+}
+
+// TestOptionsFilters tests describing the active scan filters for the status bar.
+func TestOptionsFilters(t *testing.T) {
+	if got := (options{}).filters(); len(got) != 0 {
+		t.Errorf("filters() without filters = %v, expected none", got)
+	}
+	opts := options{
+		suffixPattern: regexp.MustCompile(` \d+$`),
+		extensions:    []string{"md", "txt"},
+		minSize:       1024,
+		maxSize:       5 << 20,
+		newerThan:     time.Date(2024, 1, 30, 0, 0, 0, 0, time.UTC),
+	}
+	want := []string{`suffix ' \d+$'`, "ext md,txt", "size 1.0 KB–5.0 MB", "modified after 2024-01-30"}
+	if got := opts.filters(); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("filters() = %q, expected %q", got, want)
+	}
+	if got := (options{maxSize: 100}).filters(); len(got) != 1 || got[0] != "size ≤ 100 B" {
+		t.Errorf("filters() with max-size only = %q", got)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// groupAcrossTrees matches the files of two directory trees for --compare.
+// A file in rootA is paired with the file at the same relative path in rootB.
+// Files left over on both sides are then grouped by identical name, so a file
+// moved to another folder in the copy is still found. Files with no
+// counterpart in the other tree are left out. Groups follow the order of
+// filesA, and each group lists its rootA files before its rootB files.
+func groupAcrossTrees(rootA string, filesA []string, rootB string, filesB []string) [][]string {
+	byRelB := make(map[string]string, len(filesB))
+	for _, file := range filesB {
+		if rel, err := filepath.Rel(rootB, file); err == nil {
+			byRelB[rel] = file
+		}
+	}
+
+	// Pair files by relative path first
+	pairs := make(map[string]string)
+	matchedB := make(map[string]bool)
+	for _, file := range filesA {
+		rel, err := filepath.Rel(rootA, file)
+		if err != nil {
+			continue
+		}
+		if other, ok := byRelB[rel]; ok {
+			pairs[file] = other
+			matchedB[other] = true
+		}
+	}
+
+	// Index what is left by name
+	restA := make(map[string][]string)
+	for _, file := range filesA {
+		if _, ok := pairs[file]; !ok {
+			name := filepath.Base(file)
+			restA[name] = append(restA[name], file)
+		}
+	}
+	restB := make(map[string][]string)
+	for _, file := range filesB {
+		if !matchedB[file] {
+			name := filepath.Base(file)
+			restB[name] = append(restB[name], file)
+		}
+	}
+
+	var groups [][]string
+	emitted := make(map[string]bool)
+	for _, file := range filesA {
+		if other, ok := pairs[file]; ok {
+			groups = append(groups, []string{file, other})
+			continue
+		}
+		name := filepath.Base(file)
+		if emitted[name] || len(restB[name]) == 0 {
+			continue
+		}
+		emitted[name] = true
+		group := append(append([]string{}, restA[name]...), restB[name]...)
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// commonParent returns the deepest directory containing both a and b, as an
+// absolute path, or "" if either cannot be made absolute.
+func commonParent(a, b string) string {
+	a, errA := absolutePath(a)
+	b, errB := absolutePath(b)
+	if errA != nil || errB != nil {
+		return ""
+	}
+	for {
+		rel, err := filepath.Rel(a, b)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return a
+		}
+		parent := filepath.Dir(a)
+		if parent == a {
+			return a
+		}
+		a = parent
+	}
+}
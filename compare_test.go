@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestGroupAcrossTrees tests matching by relative path, then by name.
+func TestGroupAcrossTrees(t *testing.T) {
+	rootA := filepath.Join("home", "notes")
+	rootB := filepath.Join("backup", "notes")
+	a := func(rel string) string { return filepath.Join(rootA, rel) }
+	b := func(rel string) string { return filepath.Join(rootB, rel) }
+
+	filesA := []string{a("todo.md"), a("2024/jan.md"), a("2024/feb.md"), a("draft/ideas.md"), a("only-here.md")}
+	filesB := []string{b("todo.md"), b("2024/jan.md"), b("archive/feb.md"), b("ideas.md"), b("old/ideas.md"), b("only-there.md")}
+
+	got := groupAcrossTrees(rootA, filesA, rootB, filesB)
+	expected := [][]string{
+		{a("todo.md"), b("todo.md")},
+		{a("2024/jan.md"), b("2024/jan.md")},
+		{a("2024/feb.md"), b("archive/feb.md")},
+		{a("draft/ideas.md"), b("ideas.md"), b("old/ideas.md")},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("groupAcrossTrees() = %v, expected %v", got, expected)
+	}
+}
+
+// TestGroupAcrossTrees_PathMatchWins tests that a file paired by relative path
+// is not also grouped by name with a copy elsewhere.
+func TestGroupAcrossTrees_PathMatchWins(t *testing.T) {
+	filesA := []string{filepath.Join("a", "x", "f.txt")}
+	filesB := []string{filepath.Join("b", "x", "f.txt"), filepath.Join("b", "y", "f.txt")}
+
+	got := groupAcrossTrees("a", filesA, "b", filesB)
+	expected := [][]string{{filepath.Join("a", "x", "f.txt"), filepath.Join("b", "x", "f.txt")}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("groupAcrossTrees() = %v, expected %v", got, expected)
+	}
+}
+
+// TestScanAndGroup_Compare tests that --compare scans both trees recursively.
+func TestScanAndGroup_Compare(t *testing.T) {
+	original := createTempDir(t)
+	defer os.RemoveAll(original)
+	backup := createTempDir(t)
+	defer os.RemoveAll(backup)
+
+	if err := os.MkdirAll(filepath.Join(original, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(backup, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	createFile(t, original, "sub/report.txt")
+	createFile(t, backup, "sub/report.txt")
+	createFile(t, original, "report-1.txt")
+
+	opts := options{dir: original, compareDir: backup, minPrefix: defaultMinPrefixLength, scanWorkers: 1}
+	groups, fileCount, err := scanAndGroup(context.Background(), opts, nil)
+	if err != nil {
+		t.Fatalf("scanAndGroup() returned error: %v", err)
+	}
+	if fileCount != 3 {
+		t.Errorf("scanAndGroup() counted %d files, expected 3", fileCount)
+	}
+	expected := [][]string{{filepath.Join(original, "sub", "report.txt"), filepath.Join(backup, "sub", "report.txt")}}
+	if !reflect.DeepEqual(groups, expected) {
+		t.Errorf("scanAndGroup() = %v, expected %v", groups, expected)
+	}
+}
+
+// TestCommonParent tests the display root shared by two trees.
+func TestCommonParent(t *testing.T) {
+	root := string(filepath.Separator)
+	tests := []struct {
+		a, b     string
+		expected string
+	}{
+		{"/home/me/notes", "/backup/notes", root},
+		{"/data/notes", "/data/notes-copy", "/data"},
+		{"/data", "/data/copy", "/data"},
+	}
+	for _, tt := range tests {
+		if got := commonParent(filepath.FromSlash(tt.a), filepath.FromSlash(tt.b)); got != filepath.FromSlash(tt.expected) {
+			t.Errorf("commonParent(%q, %q) = %q, expected %q", tt.a, tt.b, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// configEnvVar names the environment variable that overrides the config file location.
+const configEnvVar = "DOPPEL_CONFIG"
+
+// Config holds user settings that apply to every directory. It is read from
+// $DOPPEL_CONFIG, or doppel/config.json in the user config directory
+// (~/.config on Linux).
+type Config struct {
+	// CopyPrefixes and CopySuffixes are extra duplicate markers, as regular
+	// expressions, stripped from names in addition to the built-in English ones.
+	// They let users add the markers their file manager uses in other languages.
+	CopyPrefixes []string `json:"copy_prefixes"`
+	CopySuffixes []string `json:"copy_suffixes"`
+	// PreferPaths and AvoidPaths rank directories for choosing which copy to
+	// keep, after those given with --prefer and --avoid.
+	PreferPaths []string `json:"prefer_paths"`
+	AvoidPaths  []string `json:"avoid_paths"`
+	// Actions are commands offered in the TUI's action menu; see GroupAction.
+	Actions []ActionConfig `json:"actions"`
+	// Profiles are named sets of flag values, applied with --profile.
+	Profiles map[string]ProfileConfig `json:"profiles"`
+	// Paths apply flag values to scans of the directories under a path.
+	Paths []PathConfig `json:"paths"`
+}
+
+// ProfileConfig maps flag names, without dashes, to their values: true or
+// false for switches, a number or a string for other flags, and a list of
+// strings for flags that may be repeated, such as {"ext": ["md", "txt"]}.
+type ProfileConfig map[string]any
+
+// PathConfig is an entry of the paths list in the config file. It applies to
+// scans of path and of the directories under it, with the profile it names
+// and its own flags, which win over the profile's.
+type PathConfig struct {
+	Path    string        `json:"path"`
+	Profile string        `json:"profile"`
+	Flags   ProfileConfig `json:"flags"`
+}
+
+// flagValues returns the flag values of p sorted by flag name, with a value
+// for each item of a list.
+func (p ProfileConfig) flagValues() ([][2]string, error) {
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var values [][2]string
+	for _, name := range names {
+		items, ok := p[name].([]any)
+		if !ok {
+			items = []any{p[name]}
+		}
+		for _, item := range items {
+			var value string
+			switch v := item.(type) {
+			case bool:
+				value = strconv.FormatBool(v)
+			case float64:
+				value = strconv.FormatFloat(v, 'g', -1, 64)
+			case string:
+				value = v
+			default:
+				return nil, fmt.Errorf("flag %s has a value of an unsupported type", name)
+			}
+			values = append(values, [2]string{name, value})
+		}
+	}
+	return values, nil
+}
+
+// pathConfigs returns the entries of the paths list that apply to dir, the
+// deepest path first.
+func (c *Config) pathConfigs(dir string) []PathConfig {
+	abs := absPath(dir)
+	var matches []PathConfig
+	for _, p := range c.Paths {
+		root := absPath(expandHome(p.Path))
+		if pathWithin(root, abs) {
+			matches = append(matches, p)
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return len(absPath(expandHome(matches[i].Path))) > len(absPath(expandHome(matches[j].Path)))
+	})
+	return matches
+}
+
+// ActionConfig is an entry of the actions list in the config file: the name
+// shown in the action menu and the command line it runs.
+type ActionConfig struct {
+	Name    string `json:"name"`
+	Command string `json:"command"`
+}
+
+// configPath returns the location of the config file.
+func configPath() (string, error) {
+	if path := os.Getenv(configEnvVar); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "doppel", "config.json"), nil
+}
+
+// LoadConfig reads the config file. A missing file, or no known config
+// directory, is an empty config.
+func LoadConfig() (*Config, error) {
+	path, err := configPath()
+	if err != nil {
+		return &Config{}, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var c Config
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	return &c, nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestLoadConfig tests reading the config file named by $DOPPEL_CONFIG.
+func TestLoadConfig(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	path := createFileWithContent(t, tmpDir, "config.json", `{"copy_prefixes": ["Kopie von "], "copy_suffixes": [" - Kopie"], "prefer_paths": ["~/Notes"], "avoid_paths": ["~/Downloads"], "actions": [{"name": "git log", "command": "git log {file}"}]}`)
+	t.Setenv(configEnvVar, path)
+
+	config, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig() returned error: %v", err)
+	}
+	if !reflect.DeepEqual(config.CopyPrefixes, []string{"Kopie von "}) || !reflect.DeepEqual(config.CopySuffixes, []string{" - Kopie"}) {
+		t.Errorf("LoadConfig() = %+v, expected the German copy markers", config)
+	}
+	if !reflect.DeepEqual(config.PreferPaths, []string{"~/Notes"}) || !reflect.DeepEqual(config.AvoidPaths, []string{"~/Downloads"}) {
+		t.Errorf("LoadConfig() = %+v, expected the path rules", config)
+	}
+	if want := []ActionConfig{{Name: "git log", Command: "git log {file}"}}; !reflect.DeepEqual(config.Actions, want) {
+		t.Errorf("LoadConfig() actions = %+v, expected %+v", config.Actions, want)
+	}
+}
+
+// TestLoadConfig_Profiles tests reading profiles and paths, and the flag
+// values of a profile.
+func TestLoadConfig_Profiles(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	path := createFileWithContent(t, tmpDir, "config.json", `{
+  "profiles": {"photos": {"recursive": true, "prefix-ratio": 1, "exclude": ["*.xmp", "*.aae"], "diff-tool": "difft {1} {2}"}},
+  "paths": [{"path": "~/Pictures", "profile": "photos", "flags": {"min-size": "10k"}}]
+}`)
+	t.Setenv(configEnvVar, path)
+
+	config, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig() returned error: %v", err)
+	}
+	values, err := config.Profiles["photos"].flagValues()
+	if err != nil {
+		t.Fatalf("flagValues() returned error: %v", err)
+	}
+	expected := [][2]string{{"diff-tool", "difft {1} {2}"}, {"exclude", "*.xmp"}, {"exclude", "*.aae"}, {"prefix-ratio", "1"}, {"recursive", "true"}}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("flagValues() = %v, expected %v", values, expected)
+	}
+	if len(config.Paths) != 1 || config.Paths[0].Profile != "photos" || config.Paths[0].Flags["min-size"] != "10k" {
+		t.Errorf("LoadConfig() paths = %+v, expected the Pictures entry", config.Paths)
+	}
+	if _, err := (ProfileConfig{"ext": map[string]any{}}).flagValues(); err == nil {
+		t.Error("flagValues() should fail for an object value")
+	}
+}
+
+// TestLoadConfig_Missing tests that a missing config file is an empty config.
+func TestLoadConfig_Missing(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	t.Setenv(configEnvVar, filepath.Join(tmpDir, "missing.json"))
+
+	config, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig() returned error: %v", err)
+	}
+	if len(config.CopyPrefixes) != 0 || len(config.CopySuffixes) != 0 {
+		t.Errorf("LoadConfig() = %+v, expected an empty config", config)
+	}
+}
+
+// TestLoadConfig_Invalid tests that malformed JSON is reported with the file name.
+func TestLoadConfig_Invalid(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	path := createFileWithContent(t, tmpDir, "config.json", "{not json")
+	t.Setenv(configEnvVar, path)
+
+	_, err := LoadConfig()
+	if err == nil || !strings.Contains(err.Error(), path) {
+		t.Errorf("LoadConfig() error = %v, expected it to name %s", err, path)
+	}
+}
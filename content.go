@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// partialHashSize is how much of each file the second stage of content matching
+// hashes. Files that agree on size and on this prefix are hashed in full.
+const partialHashSize = 64 * 1024
+
+// groupByContent groups files with identical content, regardless of their names.
+// Hashing is expensive on large folders, so candidates are narrowed in stages: only
+// files sharing an exact size are considered, then only those whose first
+// partialHashSize bytes match are hashed in full. Empty files are not grouped.
+// Groups are ordered by the position of their first file in files. Hashes of
+// unchanged files are taken from cache, which may be nil. The table of files
+// by size spills to disk once it takes more than maxMemory bytes, unless
+// maxMemory is zero; see spillTable.
+func groupByContent(ctx context.Context, files []string, cache *HashCache, maxMemory int64, progress *Progress) ([][]string, error) {
+	bySize := newSpillTable(maxMemory)
+	defer func() {
+		if err := bySize.Close(); err != nil {
+			logger.Warn("failed to remove spilled table", "err", err)
+		}
+	}()
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+		if info.Size() > 0 {
+			if err := bySize.Add(strconv.FormatInt(info.Size(), 10), file); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	progress.SetPhase("Hashing")
+	var groups [][]string
+	err := bySize.Each(func(key string, candidates []string) error {
+		if len(candidates) < 2 {
+			return nil
+		}
+		size, err := strconv.ParseInt(key, 10, 64)
+		if err != nil {
+			return err
+		}
+		partial, err := bucketByHash(candidates, func(file string) (string, error) {
+			return cache.PrefixHash(ctx, file, progress)
+		})
+		if err != nil {
+			return err
+		}
+		for _, bucket := range partial {
+			// The prefix hash already covers the whole of small files
+			if size <= partialHashSize {
+				groups = append(groups, bucket)
+				continue
+			}
+			full, err := bucketByHash(bucket, func(file string) (string, error) {
+				return cache.FullHash(ctx, file, progress)
+			})
+			if err != nil {
+				return err
+			}
+			groups = append(groups, full...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the first files of groups need a position, which keeps the map small
+	position := make(map[string]int, len(groups))
+	for _, group := range groups {
+		position[group[0]] = 0
+	}
+	for i, file := range files {
+		if _, ok := position[file]; ok {
+			position[file] = i
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return position[groups[i][0]] < position[groups[j][0]]
+	})
+	return groups, nil
+}
+
+// bucketByHash splits files by the value of hash, returning only buckets with two
+// or more files. Files keep their relative order within a bucket.
+func bucketByHash(files []string, hash func(string) (string, error)) ([][]string, error) {
+	if len(files) < 2 {
+		return nil, nil
+	}
+
+	buckets := make(map[string][]string)
+	var keys []string
+	for _, file := range files {
+		sum, err := hash(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash %s: %w", file, err)
+		}
+		if _, seen := buckets[sum]; !seen {
+			keys = append(keys, sum)
+		}
+		buckets[sum] = append(buckets[sum], file)
+	}
+
+	var result [][]string
+	for _, key := range keys {
+		if len(buckets[key]) >= 2 {
+			result = append(result, buckets[key])
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestGroupByContent tests grouping identical files regardless of their names.
+func TestGroupByContent(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	big := strings.Repeat("x", partialHashSize+10)
+	files := []string{
+		createFileWithContent(t, tmpDir, "IMG_0001.jpg", "photo one"),
+		createFileWithContent(t, tmpDir, "holiday.jpg", "photo one"),
+		createFileWithContent(t, tmpDir, "other.jpg", "photo two"), // same size, different content
+		createFileWithContent(t, tmpDir, "big-a.bin", big+"a"),
+		createFileWithContent(t, tmpDir, "big-b.bin", big+"b"), // differs only after the prefix
+		createFileWithContent(t, tmpDir, "big-c.bin", big+"a"),
+		createFileWithContent(t, tmpDir, "empty-1.txt", ""),
+		createFileWithContent(t, tmpDir, "empty-2.txt", ""),
+	}
+
+	// A limit of 1 byte spills the table after every file
+	for _, maxMemory := range []int64{0, 1} {
+		groups, err := groupByContent(context.Background(), files, nil, maxMemory, nil)
+		if err != nil {
+			t.Fatalf("groupByContent() with max memory %d returned error: %v", maxMemory, err)
+		}
+
+		var got []string
+		for _, group := range groups {
+			var names []string
+			for _, file := range group {
+				names = append(names, filepath.Base(file))
+			}
+			got = append(got, strings.Join(names, ","))
+		}
+		want := []string{"IMG_0001.jpg,holiday.jpg", "big-a.bin,big-c.bin"}
+		if strings.Join(got, " ") != strings.Join(want, " ") {
+			t.Errorf("groupByContent() with max memory %d = %v, expected %v", maxMemory, got, want)
+		}
+	}
+}
+
+// TestGroupByContent_SizePrefilter tests that files with a unique size are never read.
+func TestGroupByContent_SizePrefilter(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	files := []string{
+		createFileWithContent(t, tmpDir, "a.txt", "1234"),
+		createFileWithContent(t, tmpDir, "b.txt", "12345"),
+		createFileWithContent(t, tmpDir, "c.txt", "123456"),
+	}
+
+	progress := NewProgress("Scanning")
+	groups, err := groupByContent(context.Background(), files, nil, 0, progress)
+	if err != nil {
+		t.Fatalf("groupByContent() returned error: %v", err)
+	}
+	if len(groups) != 0 {
+		t.Errorf("groupByContent() = %v, expected no groups", groups)
+	}
+	if n := progress.bytes.Load(); n != 0 {
+		t.Errorf("%d bytes were hashed, expected none", n)
+	}
+}
+
+// TestHashPrefix tests that only the requested prefix is hashed.
+func TestHashPrefix(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	a := createFileWithContent(t, tmpDir, "a.txt", "same start, then A")
+	b := createFileWithContent(t, tmpDir, "b.txt", "same start, then B")
+
+	hashA, err := hashPrefix(context.Background(), a, 10, nil)
+	if err != nil {
+		t.Fatalf("hashPrefix() returned error: %v", err)
+	}
+	hashB, err := hashPrefix(context.Background(), b, 10, nil)
+	if err != nil {
+		t.Fatalf("hashPrefix() returned error: %v", err)
+	}
+	if hashA != hashB {
+		t.Error("hashPrefix() should match for files sharing the prefix")
+	}
+
+	full, err := hashFile(context.Background(), a, nil)
+	if err != nil {
+		t.Fatalf("hashFile() returned error: %v", err)
+	}
+	whole, err := hashPrefix(context.Background(), a, 1000, nil)
+	if err != nil {
+		t.Fatalf("hashPrefix() returned error: %v", err)
+	}
+	if whole != full {
+		t.Error("hashPrefix() longer than the file should equal hashFile()")
+	}
+}
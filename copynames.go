@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Built-in duplicate markers, matched case-insensitively against names without
+// their extension. They cover Google Drive and older Windows ("Copy of foo",
+// "Copy (2) of foo"), Windows Explorer ("foo - Copy", "foo - Copy (2)"), and
+// macOS Finder ("foo copy", "foo copy 2").
+var (
+	defaultCopyPrefixes = []string{`copy(?: \(\d+\))? of `}
+	defaultCopySuffixes = []string{` - copy(?: \(\d+\))?`, ` copy(?: \d+)?`}
+)
+
+// CopyNameRules strips the markers file managers add to duplicated files, so
+// "Copy of report" and "report - Copy" are compared as "report".
+type CopyNameRules struct {
+	prefix *regexp.Regexp
+	suffix *regexp.Regexp
+}
+
+// NewCopyNameRules builds rules from the built-in markers plus extra prefix and
+// suffix patterns. Patterns are regular expressions, matched case-insensitively
+// and anchored to the start or end of the name.
+func NewCopyNameRules(extraPrefixes, extraSuffixes []string) (*CopyNameRules, error) {
+	prefix, err := compileCopyMarkers(append(append([]string{}, defaultCopyPrefixes...), extraPrefixes...), "^(?:%s)")
+	if err != nil {
+		return nil, err
+	}
+	suffix, err := compileCopyMarkers(append(append([]string{}, defaultCopySuffixes...), extraSuffixes...), "(?:%s)$")
+	if err != nil {
+		return nil, err
+	}
+	return &CopyNameRules{prefix: prefix, suffix: suffix}, nil
+}
+
+// compileCopyMarkers combines patterns into one case-insensitive alternation
+// wrapped by anchor, reporting the first invalid pattern by name.
+func compileCopyMarkers(patterns []string, anchor string) (*regexp.Regexp, error) {
+	for _, p := range patterns {
+		if _, err := regexp.Compile(p); err != nil {
+			return nil, fmt.Errorf("invalid copy marker %q: %w", p, err)
+		}
+	}
+	return regexp.Compile("(?i)" + fmt.Sprintf(anchor, strings.Join(patterns, "|")))
+}
+
+// Strip removes duplicate markers from stem, a name without its extension.
+// Markers are removed repeatedly, so "Copy of foo - Copy" becomes "foo". If
+// nothing would be left, stem is returned unchanged.
+func (r *CopyNameRules) Strip(stem string) string {
+	for {
+		stripped := r.prefix.ReplaceAllString(stem, "")
+		stripped = r.suffix.ReplaceAllString(stripped, "")
+		if stripped == stem || stripped == "" {
+			return stem
+		}
+		stem = stripped
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import "testing"
+
+// TestCopyNameRules_Strip tests removing the built-in duplicate markers.
+func TestCopyNameRules_Strip(t *testing.T) {
+	rules, err := NewCopyNameRules(nil, nil)
+	if err != nil {
+		t.Fatalf("NewCopyNameRules() returned error: %v", err)
+	}
+
+	tests := []struct {
+		stem     string
+		expected string
+	}{
+		{"Copy of report", "report"},
+		{"copy of report", "report"},
+		{"Copy (2) of report", "report"},
+		{"report - Copy", "report"},
+		{"report - Copy (3)", "report"},
+		{"report copy", "report"},
+		{"report copy 2", "report"},
+		{"Copy of report - Copy", "report"},
+		{"Copy of Copy of report", "report"},
+		{"report", "report"},
+		{"photocopy", "photocopy"},
+		{"Copy of ", "Copy of "},
+	}
+	for _, tt := range tests {
+		if got := rules.Strip(tt.stem); got != tt.expected {
+			t.Errorf("Strip(%q) = %q, expected %q", tt.stem, got, tt.expected)
+		}
+	}
+}
+
+// TestCopyNameRules_Extra tests markers added for other languages.
+func TestCopyNameRules_Extra(t *testing.T) {
+	rules, err := NewCopyNameRules([]string{"Kopie von ", `Copie de `}, []string{` - Kopie(?: \(\d+\))?`})
+	if err != nil {
+		t.Fatalf("NewCopyNameRules() returned error: %v", err)
+	}
+	for stem, expected := range map[string]string{
+		"Kopie von Bericht":   "Bericht",
+		"Bericht - Kopie (2)": "Bericht",
+		"Copie de rapport":    "rapport",
+		"Copy of report":      "report",
+		"Bericht - Kopiert":   "Bericht - Kopiert",
+	} {
+		if got := rules.Strip(stem); got != expected {
+			t.Errorf("Strip(%q) = %q, expected %q", stem, got, expected)
+		}
+	}
+
+	if _, err := NewCopyNameRules([]string{"("}, nil); err == nil {
+		t.Error("NewCopyNameRules() should reject an invalid pattern")
+	}
+}
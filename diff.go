@@ -1,30 +1,298 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
 )
 
+// Placeholders that may appear in a diff tool template. When present, they are
+// replaced with the paths of the first and second file respectively.
+const (
+	placeholderFile1 = "{1}"
+	placeholderFile2 = "{2}"
+)
+
+// Defaults for DiffExecutor limits. Diffing two huge files can take minutes and
+// produce more output than fits in memory, and the TUI waits on the result.
+const (
+	defaultDiffTimeout   = 30 * time.Second
+	defaultDiffMaxOutput = 16 << 20
+	// diffWaitDelay bounds how long a killed diff command's output is drained, in
+	// case a wrapper script left a child process holding the pipe open.
+	diffWaitDelay = time.Second
+)
+
+// diffTool is what an executor without a configured command diffs files with.
+type diffTool int
+
+const (
+	// diffToolDiff runs diff, where it is installed.
+	diffToolDiff diffTool = iota
+	// diffToolGit runs git diff --no-index for unified diffs and the built-in
+	// engine for side-by-side ones, which git can't show.
+	diffToolGit
+	// diffToolBuiltin diffs with the built-in engine, for systems with neither,
+	// such as Windows without Git.
+	diffToolBuiltin
+)
+
+// detectDiffTool returns the best diff tool on the PATH, looked up once.
+var detectDiffTool = sync.OnceValue(func() diffTool {
+	if _, err := exec.LookPath("diff"); err == nil {
+		return diffToolDiff
+	}
+	if _, err := exec.LookPath("git"); err == nil {
+		logger.Info("diff not found; using git diff and the built-in diff")
+		return diffToolGit
+	}
+	logger.Info("diff and git not found; using the built-in diff")
+	return diffToolBuiltin
+})
+
 // DiffExecutor executes system diff commands to compare files.
 type DiffExecutor struct {
-	diffCmd string
+	diffCmd  string
+	diffArgs []string
+	// tool is what diffs are run with when no command was configured; other
+	// commands always use diffToolDiff.
+	tool diffTool
+	// ignoreWhitespace and ignoreEOL add diff's -w and --strip-trailing-cr to the
+	// mode flags. Templates are run as written and are not affected.
+	ignoreWhitespace bool
+	ignoreEOL        bool
+	// ctx kills running diff commands when it is cancelled; nil means no cancellation.
+	ctx context.Context
+	// timeout and maxOutput bound each diff command; zero means no limit.
+	timeout   time.Duration
+	maxOutput int64
+	// width is the width of side-by-side diffs; zero means sideBySideWidth.
+	width int
+	// frontmatter compares the frontmatter of Markdown notes key by key and
+	// diffs only their bodies.
+	frontmatter bool
+	// cache holds the output of ComparePair for the session; it is shared by
+	// copies made with WithContext.
+	cache *diffCache
 }
 
 // NewDiffExecutor creates a new DiffExecutor with the specified diff command.
-// If diffCmd is empty, defaults to "diff".
-func NewDiffExecutor(diffCmd string) *DiffExecutor {
+// If diffCmd is empty, defaults to "diff", or where diff isn't installed, such
+// as on Windows, to git diff --no-index or the built-in engine.
+// Any extra args are passed to the diff command before the file paths; without
+// diffCmd they are options of diff, ignored where it isn't installed. If the
+// args contain the {1} and {2} placeholders, they are treated as a complete
+// template and no mode flags (such as -y or -u) are added.
+func NewDiffExecutor(diffCmd string, args ...string) *DiffExecutor {
+	tool := diffToolDiff
 	if diffCmd == "" {
 		diffCmd = "diff"
+		tool = detectDiffTool()
+		if tool != diffToolDiff && len(args) > 0 {
+			// The args are options of diff, which git and the built-in engine don't take
+			logger.Warn("diff not found; ignoring the extra diff arguments", "args", args)
+		}
+	}
+	return &DiffExecutor{
+		diffCmd:   diffCmd,
+		diffArgs:  args,
+		tool:      tool,
+		timeout:   defaultDiffTimeout,
+		maxOutput: defaultDiffMaxOutput,
+		cache:     newDiffCache(defaultDiffCacheSize),
 	}
-	return &DiffExecutor{diffCmd: diffCmd}
 }
 
-// DiffSideBySide executes a side-by-side diff between two files.
-// Returns the diff output as a string, or an error if the diff command fails.
-func (d *DiffExecutor) DiffSideBySide(file1, file2 string) (string, error) {
-	// Use diff -y for side-by-side output
-	cmd := exec.Command(d.diffCmd, "-y", "--width=120", file1, file2)
-	output, err := cmd.CombinedOutput()
+// NewDiffExecutorFromTemplate creates a DiffExecutor from a full command line such
+// as "delta --side-by-side {1} {2}". The command line is split into arguments
+// using shell-like quoting rules, and extraArgs are appended to it.
+func NewDiffExecutorFromTemplate(template string, extraArgs []string) (*DiffExecutor, error) {
+	argv, err := splitCommandLine(template)
+	if err != nil {
+		return nil, err
+	}
+	if len(argv) == 0 {
+		return NewDiffExecutor("", extraArgs...), nil
+	}
+	args := append(argv[1:], extraArgs...)
+	return NewDiffExecutor(argv[0], args...), nil
+}
+
+// SetIgnoreWhitespace sets whether diffs ignore all whitespace, so reindented
+// lines compare equal.
+func (d *DiffExecutor) SetIgnoreWhitespace(ignore bool) {
+	d.ignoreWhitespace = ignore
+}
+
+// IgnoresWhitespace reports whether diffs ignore whitespace.
+func (d *DiffExecutor) IgnoresWhitespace() bool {
+	return d.ignoreWhitespace
+}
+
+// SetIgnoreEOL sets whether diffs ignore CRLF vs LF line endings.
+func (d *DiffExecutor) SetIgnoreEOL(ignore bool) {
+	d.ignoreEOL = ignore
+}
+
+// SetFrontmatter sets whether Markdown notes with YAML frontmatter are compared
+// as frontmatter and body, so changed metadata such as a modified date is
+// listed by key instead of mixed into the diff.
+func (d *DiffExecutor) SetFrontmatter(compare bool) {
+	d.frontmatter = compare
+}
+
+// ComparesFrontmatter reports whether frontmatter is compared apart from the body.
+func (d *DiffExecutor) ComparesFrontmatter() bool {
+	return d.frontmatter
+}
+
+// SetTimeout sets how long a diff command may run before it is killed.
+// Zero disables the timeout.
+func (d *DiffExecutor) SetTimeout(timeout time.Duration) {
+	d.timeout = timeout
+}
+
+// SetMaxOutput sets how many bytes of diff output are kept. Longer output is
+// truncated with a notice and the command is stopped. Zero disables the limit.
+func (d *DiffExecutor) SetMaxOutput(n int64) {
+	d.maxOutput = n
+}
+
+// SetCacheLimit sets how many bytes of rendered diffs are kept to show pairs
+// again without running diff; see diffCacheLimit.
+func (d *DiffExecutor) SetCacheLimit(n int64) {
+	d.cache = newDiffCache(n)
+}
+
+// SetWidth sets the width of side-by-side diffs, usually the terminal's.
+// Zero restores the default of sideBySideWidth.
+func (d *DiffExecutor) SetWidth(width int) {
+	d.width = width
+}
+
+// Width returns the width of side-by-side diffs.
+func (d *DiffExecutor) Width() int {
+	if d.width <= 0 {
+		return sideBySideWidth
+	}
+	return max(d.width, minSideBySideWidth)
+}
+
+// WithContext returns a copy of d whose diff commands are killed when ctx is cancelled.
+func (d *DiffExecutor) WithContext(ctx context.Context) *DiffExecutor {
+	c := *d
+	c.ctx = ctx
+	return &c
+}
+
+// context returns the executor's context, or context.Background if none was set.
+func (d *DiffExecutor) context() context.Context {
+	if d.ctx == nil {
+		return context.Background()
+	}
+	return d.ctx
+}
+
+// commandContext returns the context for a single diff command, bounded by the
+// executor's timeout if one is set.
+func (d *DiffExecutor) commandContext() (context.Context, context.CancelFunc) {
+	if d.timeout > 0 {
+		return context.WithTimeout(d.context(), d.timeout)
+	}
+	return context.WithCancel(d.context())
+}
+
+// commandError converts the failure of a command run under ctx into an error
+// naming the cause, or returns nil if ctx didn't end the command.
+func (d *DiffExecutor) commandError(ctx context.Context) error {
+	if err := d.context().Err(); err != nil {
+		return err
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("diff command timed out after %s", d.timeout)
+	}
+	return nil
+}
+
+// isTemplate reports whether the configured args contain file placeholders.
+func (d *DiffExecutor) isTemplate() bool {
+	for _, arg := range d.diffArgs {
+		if strings.Contains(arg, placeholderFile1) || strings.Contains(arg, placeholderFile2) {
+			return true
+		}
+	}
+	return false
+}
+
+// buildArgs returns the argument list for comparing file1 and file2.
+// For templates, placeholders are substituted and modeFlags are ignored.
+// Otherwise the configured args, the mode flags, and both files are appended in order.
+func (d *DiffExecutor) buildArgs(modeFlags []string, file1, file2 string) []string {
+	if d.isTemplate() {
+		args := make([]string, len(d.diffArgs))
+		for i, arg := range d.diffArgs {
+			arg = strings.ReplaceAll(arg, placeholderFile1, file1)
+			arg = strings.ReplaceAll(arg, placeholderFile2, file2)
+			args[i] = arg
+		}
+		return args
+	}
+
+	args := append([]string{}, d.diffArgs...)
+	args = append(args, modeFlags...)
+	if d.ignoreWhitespace {
+		args = append(args, "-w")
+	}
+	if d.ignoreEOL {
+		args = append(args, "--strip-trailing-cr")
+	}
+	return append(args, file1, file2)
+}
+
+// run executes the diff command with the given mode flags and returns its combined output.
+// Output beyond the executor's limit is cut at a line boundary and ends with a notice.
+func (d *DiffExecutor) run(modeFlags []string, file1, file2 string) (string, error) {
+	return d.runCommand(d.diffCmd, d.buildArgs(modeFlags, file1, file2))
+}
+
+// runGit runs a unified diff of two files with git diff --no-index, with
+// git's equivalents of -w and --strip-trailing-cr.
+func (d *DiffExecutor) runGit(file1, file2 string) (string, error) {
+	args := []string{"diff", "--no-index", "--no-color", "--no-ext-diff"}
+	if d.ignoreWhitespace {
+		args = append(args, "--ignore-all-space")
+	}
+	if d.ignoreEOL {
+		args = append(args, "--ignore-cr-at-eol")
+	}
+	return d.runCommand("git", append(args, "--", file1, file2))
+}
+
+// runCommand runs name with args for run and runGit.
+func (d *DiffExecutor) runCommand(name string, args []string) (string, error) {
+	ctx, cancel := d.commandContext()
+	defer cancel()
+
+	output := &limitedBuffer{limit: d.maxOutput, full: cancel}
+	cmd := logCommand(exec.CommandContext(ctx, name, args...))
+	cmd.Stdout = output
+	cmd.Stderr = output
+	cmd.WaitDelay = diffWaitDelay
+	err := cmd.Run()
+	if output.truncated {
+		if err := d.context().Err(); err != nil {
+			return "", err
+		}
+		return output.String() + fmt.Sprintf("\n[diff output truncated at %s]\n", formatBytes(d.maxOutput)), nil
+	}
+	if err := d.commandError(ctx); err != nil {
+		return "", err
+	}
 	if err != nil {
 		// diff returns non-zero exit code when files differ, which is expected
 		// Only return error if command execution itself failed
@@ -32,29 +300,177 @@ func (d *DiffExecutor) DiffSideBySide(file1, file2 string) (string, error) {
 			return "", fmt.Errorf("failed to execute diff command: %w", err)
 		}
 	}
-	return string(output), nil
+	return output.String(), nil
+}
+
+// limitedBuffer collects command output up to limit bytes. Once the limit is
+// reached it keeps output up to the last complete line, calls full so the
+// command can be stopped, and discards the rest. A zero limit keeps everything.
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	limit     int64
+	full      func()
+	truncated bool
+}
+
+// Write implements io.Writer. It never fails, so the command isn't sent SIGPIPE
+// before full stops it.
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.truncated {
+		return len(p), nil
+	}
+	if b.limit > 0 && int64(b.buf.Len()+len(p)) > b.limit {
+		b.buf.Write(p[:b.limit-int64(b.buf.Len())])
+		if i := bytes.LastIndexByte(b.buf.Bytes(), '\n'); i >= 0 {
+			b.buf.Truncate(i + 1)
+		}
+		b.truncated = true
+		b.full()
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+// String returns the collected output.
+func (b *limitedBuffer) String() string {
+	return b.buf.String()
+}
+
+const (
+	// sideBySideWidth is the width of side-by-side diffs when none is set, such
+	// as when the terminal size is unknown.
+	sideBySideWidth = 120
+	// minSideBySideWidth keeps each column of a narrow terminal readable; wider
+	// lines are scrolled horizontally.
+	minSideBySideWidth = 40
+)
+
+// sideBySideLayout returns the columns, counting from 0 with tabs expanded, of
+// the mark (|, <, or >) that diff -y puts on changed lines and of the start of
+// the second file's text, for output of the given width. It follows GNU diff's
+// layout with 8-column tabs.
+func sideBySideLayout(width int) (gutter, right int) {
+	const tab, minGutter = 8, 3
+	offset := (width + tab + minGutter) / (2 * tab) * tab
+	half := max(0, min(offset-minGutter, width-offset))
+	right = width
+	if half > 0 {
+		right = offset
+	}
+	return (half + right - 1) / 2, right
+}
+
+// DiffSideBySide executes a side-by-side diff between two files.
+// Returns the diff output as a string, or an error if the diff command fails.
+func (d *DiffExecutor) DiffSideBySide(file1, file2 string) (string, error) {
+	if d.tool != diffToolDiff {
+		return d.builtinDiff(file1, file2, false)
+	}
+	// Use diff -y for side-by-side output
+	return d.run([]string{"-y", fmt.Sprintf("--width=%d", d.Width())}, file1, file2)
 }
 
 // DiffUnified executes a unified diff between two files.
 // Returns the diff output as a string, or an error if the diff command fails.
 func (d *DiffExecutor) DiffUnified(file1, file2 string) (string, error) {
-	cmd := exec.Command(d.diffCmd, "-u", file1, file2)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		// diff returns non-zero exit code when files differ, which is expected
-		// Only return error if command execution itself failed
-		if _, ok := err.(*exec.ExitError); !ok {
-			return "", fmt.Errorf("failed to execute diff command: %w", err)
+	switch d.tool {
+	case diffToolGit:
+		return d.runGit(file1, file2)
+	case diffToolBuiltin:
+		return d.builtinDiff(file1, file2, true)
+	}
+	return d.run([]string{"-u"}, file1, file2)
+}
+
+// ComparePair renders two files the way the diff view shows them, by the
+// handler of their kind: image metadata and similarity for two images, a
+// metadata table for two audio or video files, a diff of the listings of two
+// zip archives, a hex dump around the first difference for binary files, and
+// otherwise a side-by-side or unified diff of their text, converted to UTF-8
+// from the encoding each file is in. Results are cached until either file's size or modification time changes.
+func (d *DiffExecutor) ComparePair(file1, file2 string, unified bool) (string, error) {
+	stamp1, ok1 := stampFile(file1)
+	stamp2, ok2 := stampFile(file2)
+	if !ok1 || !ok2 {
+		return d.comparePair(file1, file2, unified)
+	}
+	key := diffKey{stamp1, stamp2, unified, d.ignoreWhitespace, d.ignoreEOL, d.Width(), d.frontmatter}
+	if output, ok := d.cache.get(key); ok {
+		return output, nil
+	}
+	output, err := d.comparePair(file1, file2, unified)
+	if err == nil {
+		d.cache.put(key, output)
+	}
+	return output, err
+}
+
+// comparePair renders two files for ComparePair without the cache.
+func (d *DiffExecutor) comparePair(file1, file2 string, unified bool) (string, error) {
+	var note string
+	if h := pairHandler(file1, file2); h.compare != nil {
+		output, ok, why, err := h.compare(d, file1, file2, unified)
+		if err != nil || ok {
+			return output, err
+		}
+		note = why
+	}
+	return d.compareText(file1, file2, unified, note)
+}
+
+// compareText diffs the text of two files, or shows where they differ if
+// either is binary, after note.
+func (d *DiffExecutor) compareText(file1, file2 string, unified bool, note string) (string, error) {
+	if text1, text2, cleanup, err := textPair(d.context(), file1, file2); err == nil {
+		defer cleanup()
+		file1, file2 = text1, text2
+	} else {
+		// Without their text, documents are compared byte by byte
+		note = fmt.Sprintf("Comparing as binary files: %v\n\n", err)
+	}
+	if output, binary, err := binaryDiff(file1, file2); err != nil {
+		return "", fmt.Errorf("failed to compare files: %w", err)
+	} else if binary {
+		return note + output, nil
+	}
+	if d.frontmatter {
+		if c, ok := compareFrontmatter(file1, file2); ok {
+			return d.frontmatterDiff(c, file1, file2, unified)
 		}
 	}
-	return string(output), nil
+	return d.diffText(file1, file2, unified)
+}
+
+// diffText runs a unified or side-by-side diff of two text files.
+func (d *DiffExecutor) diffText(file1, file2 string, unified bool) (string, error) {
+	if unified {
+		return d.DiffUnified(file1, file2)
+	}
+	return d.DiffSideBySide(file1, file2)
 }
 
 // FilesIdentical checks if two files are identical by comparing their content.
 // Returns true if files are identical, false if they differ, and an error if comparison fails.
+// Templates describe how to display a diff, not how to test equality, so plain
+// "diff -q" is used whenever a template is configured. Without diff, the files
+// are compared byte by byte.
 func (d *DiffExecutor) FilesIdentical(file1, file2 string) (bool, error) {
-	cmd := exec.Command(d.diffCmd, "-q", file1, file2)
-	err := cmd.Run()
+	if d.tool != diffToolDiff || (d.isTemplate() && detectDiffTool() != diffToolDiff) {
+		return filesByteIdentical(file1, file2)
+	}
+	ctx, cancel := d.commandContext()
+	defer cancel()
+	var cmd *exec.Cmd
+	if d.isTemplate() {
+		cmd = exec.CommandContext(ctx, "diff", "-q", file1, file2)
+	} else {
+		args := append(append([]string{}, d.diffArgs...), "-q", file1, file2)
+		cmd = exec.CommandContext(ctx, d.diffCmd, args...)
+	}
+	err := logCommand(cmd).Run()
+	if err := d.commandError(ctx); err != nil {
+		return false, err
+	}
 	if err == nil {
 		// Exit code 0 means files are identical
 		return true, nil
@@ -70,3 +486,121 @@ func (d *DiffExecutor) FilesIdentical(file1, file2 string) (bool, error) {
 	// Non-exit error indicates command execution failure
 	return false, fmt.Errorf("failed to execute diff command: %w", err)
 }
+
+// literalBackslashes keeps backslashes in command lines that don't escape a
+// quote, a space, or another backslash, so Windows paths such as
+// C:\Tools\diff.exe can be written unquoted.
+var literalBackslashes = runtime.GOOS == "windows"
+
+// splitCommandLine splits a command line into arguments using shell-like rules:
+// whitespace separates arguments, single quotes preserve text literally, double
+// quotes preserve text but allow backslash escapes, and a backslash outside
+// quotes escapes the next character, or on Windows only a quote, whitespace,
+// or another backslash.
+func splitCommandLine(s string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inArg := false
+	var quote rune
+
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else if r == '\\' && i+1 < len(runes) && (runes[i+1] == '"' || runes[i+1] == '\\') {
+				i++
+				current.WriteRune(runes[i])
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == '\\' && literalBackslashes && i+1 < len(runes) && !strings.ContainsRune("'\" \t\n\\", runes[i+1]):
+			current.WriteRune(r)
+			inArg = true
+		case r == '\\':
+			if i+1 >= len(runes) {
+				return nil, fmt.Errorf("trailing backslash in %q", s)
+			}
+			i++
+			current.WriteRune(runes[i])
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote in %q", quote, s)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+// hunkStarts returns the indexes of the lines of diff output where a block of
+// changes begins: the @@ lines of a unified diff, the first of each run of lines
+// marked in the gutter of a side-by-side diff of the given width, or with
+// columns, the first row and each ⋯ separator of a column view from multiWayDiff.
+func hunkStarts(output string, unified, columns bool, width int) []int {
+	gutter, _ := sideBySideLayout(width)
+	var starts []int
+	inChange := false
+	for i, line := range strings.Split(output, "\n") {
+		switch {
+		case columns:
+			if i == 1 || line == "⋯" {
+				starts = append(starts, i)
+			}
+		case unified:
+			if strings.HasPrefix(line, "@@") {
+				starts = append(starts, i)
+			}
+		default:
+			changed := sideBySideChanged(line, gutter)
+			if changed && !inChange {
+				starts = append(starts, i)
+			}
+			inChange = changed
+		}
+	}
+	return starts
+}
+
+// sideBySideChanged reports whether a line of a side-by-side diff has a change
+// mark in its gutter column.
+func sideBySideChanged(line string, gutter int) bool {
+	col := 0
+	for _, r := range line {
+		if col == gutter {
+			return r == '|' || r == '<' || r == '>'
+		}
+		if col > gutter {
+			return false
+		}
+		if r == '\t' {
+			col += 8 - col%8
+		} else {
+			col++
+		}
+	}
+	return false
+}
@@ -1,10 +1,16 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"reflect"
+	"runtime"
 	"strings"
 	"testing"
+	"time"
 )
 
 // TestDiffExecutor_DiffSideBySide_IdenticalFiles tests diffing two identical files.
@@ -180,6 +186,147 @@ func TestDiffExecutor_DefaultDiffCommand(t *testing.T) {
 	}
 }
 
+// TestDiffExecutor_ExtraArgs tests that extra args are passed before the mode flags and files.
+func TestDiffExecutor_ExtraArgs(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	file1 := createFileWithContent(t, tmpDir, "file1.txt", "line 1\nline 2\n")
+	file2 := createFileWithContent(t, tmpDir, "file2.txt", "line 1\nline  2\n")
+
+	executor := NewDiffExecutor("diff", "-b")
+	identical, err := executor.FilesIdentical(file1, file2)
+
+	if err != nil {
+		t.Fatalf("FilesIdentical() returned error: %v", err)
+	}
+	if !identical {
+		t.Error("FilesIdentical() with -b should ignore whitespace changes")
+	}
+
+	got := executor.buildArgs([]string{"-u"}, file1, file2)
+	want := []string{"-b", "-u", file1, file2}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("buildArgs() = %v, expected %v", got, want)
+	}
+}
+
+// TestDiffExecutor_Template tests that placeholders are substituted and mode flags are dropped.
+func TestDiffExecutor_Template(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	file1 := createFileWithContent(t, tmpDir, "file1.txt", "line 1\nline 2\n")
+	file2 := createFileWithContent(t, tmpDir, "file2.txt", "line 1\nline 3\n")
+
+	executor, err := NewDiffExecutorFromTemplate("diff --label left --label right -u {1} {2}", nil)
+	if err != nil {
+		t.Fatalf("NewDiffExecutorFromTemplate() returned error: %v", err)
+	}
+
+	output, err := executor.DiffSideBySide(file1, file2)
+	if err != nil {
+		t.Fatalf("DiffSideBySide() returned error: %v", err)
+	}
+	if !strings.Contains(output, "--- left") || !strings.Contains(output, "+++ right") {
+		t.Errorf("DiffSideBySide() should use the template's unified format, got:\n%s", output)
+	}
+
+	identical, err := executor.FilesIdentical(file1, file2)
+	if err != nil {
+		t.Fatalf("FilesIdentical() returned error: %v", err)
+	}
+	if identical {
+		t.Error("FilesIdentical() should return false for different files")
+	}
+}
+
+// TestNewDiffExecutor_ArgsWithoutDiff tests that --diff-arg without --diff-tool
+// falls back to the detected tool where diff isn't installed.
+func TestNewDiffExecutor_ArgsWithoutDiff(t *testing.T) {
+	old := detectDiffTool
+	detectDiffTool = func() diffTool { return diffToolBuiltin }
+	t.Cleanup(func() { detectDiffTool = old })
+
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	file1 := createFileWithContent(t, tmpDir, "a.txt", "one\n")
+	file2 := createFileWithContent(t, tmpDir, "b.txt", "two\n")
+
+	executor := NewDiffExecutor("", "--ignore-case")
+	if executor.tool != diffToolBuiltin {
+		t.Fatalf("tool = %v, expected the built-in engine", executor.tool)
+	}
+	output, err := executor.DiffUnified(file1, file2)
+	if err != nil || !strings.Contains(output, "+two") {
+		t.Errorf("DiffUnified() = %q, %v; expected the built-in diff", output, err)
+	}
+}
+
+// TestNewDiffExecutorFromTemplate_ExtraArgs tests that --diff-arg values follow the template words.
+func TestNewDiffExecutorFromTemplate_ExtraArgs(t *testing.T) {
+	executor, err := NewDiffExecutorFromTemplate("colordiff -w", []string{"--strip-trailing-cr"})
+	if err != nil {
+		t.Fatalf("NewDiffExecutorFromTemplate() returned error: %v", err)
+	}
+	if executor.diffCmd != "colordiff" {
+		t.Errorf("diffCmd = %q, expected %q", executor.diffCmd, "colordiff")
+	}
+	want := []string{"-w", "--strip-trailing-cr"}
+	if strings.Join(executor.diffArgs, " ") != strings.Join(want, " ") {
+		t.Errorf("diffArgs = %v, expected %v", executor.diffArgs, want)
+	}
+
+	executor, err = NewDiffExecutorFromTemplate("", nil)
+	if err != nil {
+		t.Fatalf("NewDiffExecutorFromTemplate() returned error: %v", err)
+	}
+	if executor.diffCmd != "diff" {
+		t.Errorf("empty template should default to diff, got %q", executor.diffCmd)
+	}
+}
+
+// TestSplitCommandLine tests shell-like argument splitting.
+func TestSplitCommandLine(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+		wantErr  bool
+	}{
+		{"Empty", "", nil, false},
+		{"Single word", "diff", []string{"diff"}, false},
+		{"Multiple words", "git diff --no-index {1} {2}", []string{"git", "diff", "--no-index", "{1}", "{2}"}, false},
+		{"Extra whitespace", "  delta   --side-by-side ", []string{"delta", "--side-by-side"}, false},
+		{"Single quotes", "difft --display 'side by side'", []string{"difft", "--display", "side by side"}, false},
+		{"Double quotes with escape", `tool "say \"hi\""`, []string{"tool", `say "hi"`}, false},
+		{"Backslash escape", `tool a\ b`, []string{"tool", "a b"}, false},
+		{"Empty quoted arg", "tool ''", []string{"tool", ""}, false},
+		{"Unterminated quote", "tool 'oops", nil, true},
+		{"Trailing backslash", `tool \`, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := splitCommandLine(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("splitCommandLine(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got) != len(tt.expected) {
+				t.Fatalf("splitCommandLine(%q) = %q, expected %q", tt.input, got, tt.expected)
+			}
+			for i := range got {
+				if got[i] != tt.expected[i] {
+					t.Errorf("splitCommandLine(%q)[%d] = %q, expected %q", tt.input, i, got[i], tt.expected[i])
+				}
+			}
+		})
+	}
+}
+
 // Helper functions
 
 func createFileWithContent(t *testing.T, dir, fileName, content string) string {
@@ -189,3 +336,199 @@ func createFileWithContent(t *testing.T, dir, fileName, content string) string {
 	}
 	return filePath
 }
+
+// TestDiffExecutor_IgnoreWhitespaceAndEOL tests that reindented CRLF copies show no changes.
+func TestDiffExecutor_IgnoreWhitespaceAndEOL(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	file1 := createFileWithContent(t, tmpDir, "file1.txt", "if x {\n  y()\n}\n")
+	file2 := createFileWithContent(t, tmpDir, "file2.txt", "if x {\r\n\ty()\r\n}\r\n")
+
+	executor := NewDiffExecutor("")
+	executor.SetIgnoreWhitespace(true)
+	executor.SetIgnoreEOL(true)
+
+	got := executor.buildArgs([]string{"-u"}, file1, file2)
+	want := []string{"-u", "-w", "--strip-trailing-cr", file1, file2}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("buildArgs() = %v, expected %v", got, want)
+	}
+
+	output, err := executor.DiffUnified(file1, file2)
+	if err != nil {
+		t.Fatalf("DiffUnified() returned error: %v", err)
+	}
+	if output != "" {
+		t.Errorf("DiffUnified() = %q, expected no differences", output)
+	}
+}
+
+// TestDiffExecutor_WithContext tests that diffs fail with the context's error once it is cancelled.
+func TestDiffExecutor_WithContext(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	file1 := createFileWithContent(t, tmpDir, "file1.txt", "one\n")
+	file2 := createFileWithContent(t, tmpDir, "file2.txt", "two\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	base := NewDiffExecutor("")
+	executor := base.WithContext(ctx)
+	if _, err := executor.DiffUnified(file1, file2); !errors.Is(err, context.Canceled) {
+		t.Errorf("DiffUnified() error = %v, expected context.Canceled", err)
+	}
+	if _, err := executor.FilesIdentical(file1, file2); !errors.Is(err, context.Canceled) {
+		t.Errorf("FilesIdentical() error = %v, expected context.Canceled", err)
+	}
+
+	// The original executor is unaffected
+	if _, err := base.DiffUnified(file1, file2); err != nil {
+		t.Errorf("DiffUnified() on original executor returned error: %v", err)
+	}
+}
+
+// TestDiffExecutor_MaxOutput tests that long diff output is cut at a line boundary with a notice.
+func TestDiffExecutor_MaxOutput(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	file1 := createFileWithContent(t, tmpDir, "file1.txt", strings.Repeat("old line\n", 1000))
+	file2 := createFileWithContent(t, tmpDir, "file2.txt", strings.Repeat("new line\n", 1000))
+
+	executor := NewDiffExecutor("")
+	executor.SetMaxOutput(100)
+	output, err := executor.DiffUnified(file1, file2)
+	if err != nil {
+		t.Fatalf("DiffUnified() returned error: %v", err)
+	}
+	body, notice, found := strings.Cut(output, "\n[diff output truncated at 100 B]")
+	if !found {
+		t.Fatalf("DiffUnified() output missing truncation notice:\n%s", output)
+	}
+	if len(body) > 100 || !strings.HasSuffix(body, "\n") {
+		t.Errorf("DiffUnified() kept %d bytes ending in %q, expected at most 100 ending at a line break", len(body), body[len(body)-1:])
+	}
+	if notice != "\n" {
+		t.Errorf("DiffUnified() has output after the notice: %q", notice)
+	}
+
+	executor.SetMaxOutput(0)
+	output, err = executor.DiffUnified(file1, file2)
+	if err != nil {
+		t.Fatalf("DiffUnified() returned error: %v", err)
+	}
+	if strings.Contains(output, "truncated") {
+		t.Error("DiffUnified() should not truncate without a limit")
+	}
+}
+
+// TestDiffExecutor_Timeout tests that a diff command running past the timeout is killed.
+func TestDiffExecutor_Timeout(t *testing.T) {
+	executor := NewDiffExecutor("sh", "-c", "sleep 10", "{1}", "{2}")
+	executor.SetTimeout(50 * time.Millisecond)
+
+	start := time.Now()
+	_, err := executor.DiffUnified("a", "b")
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("DiffUnified() error = %v, expected a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("DiffUnified() took %s, expected the command to be killed", elapsed)
+	}
+}
+
+// TestHunkStarts tests finding the blocks of changes in each kind of diff output.
+func TestHunkStarts(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	file1 := createFileWithContent(t, tmpDir, "a.txt", "one\ntwo\nthree\nfour\nfive\nsix\n")
+	file2 := createFileWithContent(t, tmpDir, "b.txt", "ONE\nTWO\nthree\nfour\nfive\nsix\nseven\n")
+	executor := NewDiffExecutor("")
+
+	sideBySide, err := executor.DiffSideBySide(file1, file2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := hunkStarts(sideBySide, false, false, sideBySideWidth), []int{0, 6}; !reflect.DeepEqual(got, want) {
+		t.Errorf("side-by-side hunkStarts = %v, expected %v", got, want)
+	}
+
+	unified := "--- a.txt\n+++ b.txt\n@@ -1,2 +1,2 @@\n-one\n+ONE\n@@ -9 +9 @@\n-x\n"
+	if got, want := hunkStarts(unified, true, false, sideBySideWidth), []int{2, 5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unified hunkStarts = %v, expected %v", got, want)
+	}
+
+	columns := "a.txt │ b.txt\n- one │ + ONE\n⋯\n- six │ + SIX\n"
+	if got, want := hunkStarts(columns, false, true, sideBySideWidth), []int{1, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("column hunkStarts = %v, expected %v", got, want)
+	}
+}
+
+// TestSideBySideLayout tests that the gutter and second column are found where
+// diff -y puts them for a range of widths.
+func TestSideBySideLayout(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	file1 := createFileWithContent(t, tmpDir, "a.txt", "left\n")
+	file2 := createFileWithContent(t, tmpDir, "b.txt", "right\n")
+	for _, width := range []int{40, 41, 79, 80, 100, 120, 121, 200} {
+		executor := NewDiffExecutor("")
+		executor.SetWidth(width)
+		output, err := executor.DiffSideBySide(file1, file2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		line := expandTabs(strings.Split(output, "\n")[0])
+		gutter, right := sideBySideLayout(width)
+		if got := strings.Index(line, "|"); got != gutter {
+			t.Errorf("width %d: gutter at %d, expected %d", width, got, gutter)
+		}
+		if got := strings.Index(line, "right"); got != right {
+			t.Errorf("width %d: second column at %d, expected %d", width, got, right)
+		}
+	}
+}
+
+// TestSplitCommandLine_LiteralBackslashes tests that on Windows backslashes in
+// paths are kept, while escaped spaces and quotes still work.
+func TestSplitCommandLine_LiteralBackslashes(t *testing.T) {
+	literalBackslashes = true
+	defer func() { literalBackslashes = runtime.GOOS == "windows" }()
+
+	got, err := splitCommandLine(`C:\Tools\diff.exe -u "C:\My Files\{1}" a\ b {2}`)
+	expected := []string{`C:\Tools\diff.exe`, "-u", `C:\My Files\{1}`, "a b", "{2}"}
+	if err != nil || !reflect.DeepEqual(got, expected) {
+		t.Errorf("splitCommandLine() = %q, %v, expected %q", got, err, expected)
+	}
+}
+
+// TestDiffExecutor_GitFallback tests unified diffs with git diff --no-index,
+// used where diff isn't installed.
+func TestDiffExecutor_GitFallback(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	file1 := createFileWithContent(t, tmpDir, "file1.txt", "line 1\nline 2\n")
+	file2 := createFileWithContent(t, tmpDir, "file2.txt", "line 1\nline 3\n")
+
+	d := NewDiffExecutor("")
+	d.tool = diffToolGit
+	output, err := d.DiffUnified(file1, file2)
+	if err != nil {
+		t.Fatalf("DiffUnified() returned error: %v", err)
+	}
+	if !strings.Contains(output, "-line 2\n+line 3\n") {
+		t.Errorf("DiffUnified() with git = %q, expected the changed line", output)
+	}
+	if output, err := d.DiffSideBySide(file1, file2); err != nil || !strings.Contains(output, "|") {
+		t.Errorf("DiffSideBySide() with git = %q, %v, expected the built-in side-by-side diff", output, err)
+	}
+	if identical, err := d.FilesIdentical(file1, file2); err != nil || identical {
+		t.Errorf("FilesIdentical() = %v, %v, expected false", identical, err)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"os"
+	"sync"
+)
+
+// defaultDiffCacheSize bounds the diff output kept by a diffCache. It holds a
+// few maximum-size diffs, or thousands of ordinary ones.
+const defaultDiffCacheSize = 64 << 20
+
+// diffCacheLimit returns the size of the diff cache for a --max-memory budget
+// of maxMemory bytes: a quarter of it, but no more than defaultDiffCacheSize.
+// Zero means no budget.
+func diffCacheLimit(maxMemory int64) int64 {
+	if maxMemory <= 0 {
+		return defaultDiffCacheSize
+	}
+	return min(max(maxMemory/4, 1), defaultDiffCacheSize)
+}
+
+// fileStamp identifies a version of a file by path, size, and modification time.
+type fileStamp struct {
+	path    string
+	size    int64
+	modTime int64
+}
+
+// stampFile returns the current stamp of path.
+func stampFile(path string) (fileStamp, bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}, false
+	}
+	return fileStamp{path: path, size: info.Size(), modTime: info.ModTime().UnixNano()}, true
+}
+
+// diffKey identifies a rendered comparison: the two file versions and every
+// setting that changes the output.
+type diffKey struct {
+	file1, file2     fileStamp
+	unified          bool
+	ignoreWhitespace bool
+	ignoreEOL        bool
+	width            int
+	frontmatter      bool
+}
+
+// diffCache remembers rendered comparisons for a session, so viewing a pair
+// again or switching back to a mode already shown doesn't run diff again.
+// Editing either file changes its stamp, so stale output is never returned.
+// Once more than limit bytes are held, the oldest entries are dropped.
+type diffCache struct {
+	mu      sync.Mutex
+	entries map[diffKey]string
+	order   []diffKey
+	size    int64
+	limit   int64
+}
+
+// newDiffCache returns an empty cache holding up to limit bytes of output.
+func newDiffCache(limit int64) *diffCache {
+	return &diffCache{entries: make(map[diffKey]string), limit: limit}
+}
+
+// get returns the cached output for key.
+func (c *diffCache) get(key diffKey) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	output, ok := c.entries[key]
+	return output, ok
+}
+
+// put stores output for key, dropping the oldest entries beyond the limit.
+func (c *diffCache) put(key diffKey, output string) {
+	if c == nil || int64(len(output)) > c.limit {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; ok {
+		return
+	}
+	c.entries[key] = output
+	c.order = append(c.order, key)
+	c.size += int64(len(output))
+	for c.size > c.limit {
+		oldest := c.order[0]
+		c.order = c.order[1:]
+		c.size -= int64(len(c.entries[oldest]))
+		delete(c.entries, oldest)
+	}
+}
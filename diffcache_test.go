@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestComparePair_Cache tests that a pair is diffed once per file version and
+// mode, and again after either file changes.
+func TestComparePair_Cache(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	file1 := createFileWithContent(t, tmpDir, "a.txt", "one\ntwo\n")
+	file2 := createFileWithContent(t, tmpDir, "b.txt", "one\nthree\n")
+	d := NewDiffExecutor("")
+
+	first, err := d.ComparePair(file1, file2, false)
+	if err != nil {
+		t.Fatalf("ComparePair() returned error: %v", err)
+	}
+
+	// A broken command can only be hidden by the cache
+	d.diffCmd = "/nonexistent/diff"
+	if cached, err := d.ComparePair(file1, file2, false); err != nil || cached != first {
+		t.Errorf("ComparePair() = %q, %v; expected the cached diff", cached, err)
+	}
+	if _, err := d.ComparePair(file1, file2, true); err == nil {
+		t.Error("a unified diff is not cached yet and should run the command")
+	}
+
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(file2, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.ComparePair(file1, file2, false); err == nil {
+		t.Error("ComparePair() should diff again after a file changed")
+	}
+}
+
+// TestDiffCache_Limit tests that the oldest entries are dropped beyond the limit.
+func TestDiffCache_Limit(t *testing.T) {
+	c := newDiffCache(10)
+	keys := []diffKey{{unified: true}, {ignoreWhitespace: true}, {ignoreEOL: true}}
+	for _, key := range keys {
+		c.put(key, strings.Repeat("x", 4))
+	}
+	if _, ok := c.get(keys[0]); ok {
+		t.Error("the oldest entry should be dropped")
+	}
+	for _, key := range keys[1:] {
+		if _, ok := c.get(key); !ok {
+			t.Errorf("entry %+v should be kept", key)
+		}
+	}
+	c.put(diffKey{}, strings.Repeat("x", 11))
+	if _, ok := c.get(diffKey{}); ok {
+		t.Error("output larger than the cache should not be stored")
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// scanChanges is what changed between two analyses of the same directory.
+type scanChanges struct {
+	// NewGroups are current groups that share no file with any previous group.
+	NewGroups [][]string
+	// ResolvedGroups are previous groups that share no file with any current group.
+	ResolvedGroups [][]string
+	// NewFiles are files in current groups that were in no previous group,
+	// including the members of NewGroups.
+	NewFiles []string
+}
+
+// empty reports whether nothing changed.
+func (c scanChanges) empty() bool {
+	return len(c.NewGroups) == 0 && len(c.ResolvedGroups) == 0 && len(c.NewFiles) == 0
+}
+
+// diffScans compares the groups of a previous analysis with the current ones.
+// Groups are matched by the files they share rather than by exact membership,
+// so a group that gained a copy is not reported as both new and resolved; the
+// added copy is listed in NewFiles instead.
+func diffScans(previous, current [][]string) scanChanges {
+	previousFiles := make(map[string]bool)
+	for _, group := range previous {
+		for _, file := range group {
+			previousFiles[file] = true
+		}
+	}
+	currentFiles := make(map[string]bool)
+	for _, group := range current {
+		for _, file := range group {
+			currentFiles[file] = true
+		}
+	}
+
+	var changes scanChanges
+	for _, group := range current {
+		isNew := true
+		for _, file := range group {
+			if previousFiles[file] {
+				isNew = false
+			} else {
+				changes.NewFiles = append(changes.NewFiles, file)
+			}
+		}
+		if isNew {
+			changes.NewGroups = append(changes.NewGroups, group)
+		}
+	}
+	for _, group := range previous {
+		resolved := true
+		for _, file := range group {
+			if currentFiles[file] {
+				resolved = false
+				break
+			}
+		}
+		if resolved {
+			changes.ResolvedGroups = append(changes.ResolvedGroups, group)
+		}
+	}
+	return changes
+}
+
+// readReportGroups reads the groups of a CSV report written by writeCSV. Paths
+// are made absolute, like those passed through absGroups, so reports taken with
+// a relative directory can be compared with later runs.
+func readReportGroups(r io.Reader) ([][]string, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("empty report")
+	}
+
+	groupCol, pathCol := -1, -1
+	for i, name := range rows[0] {
+		switch name {
+		case "group":
+			groupCol = i
+		case "path":
+			pathCol = i
+		}
+	}
+	if groupCol < 0 || pathCol < 0 {
+		return nil, errors.New("not a CSV report: missing group or path column")
+	}
+
+	var groups [][]string
+	index := make(map[int]int)
+	for _, row := range rows[1:] {
+		n, err := strconv.Atoi(row[groupCol])
+		if err != nil {
+			return nil, fmt.Errorf("invalid group number %q", row[groupCol])
+		}
+		i, ok := index[n]
+		if !ok {
+			i = len(groups)
+			index[n] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], absPath(row[pathCol]))
+	}
+	return groups, nil
+}
+
+// absGroups returns groups with every path made absolute.
+func absGroups(groups [][]string) [][]string {
+	result := make([][]string, len(groups))
+	for i, group := range groups {
+		result[i] = make([]string, len(group))
+		for j, file := range group {
+			result[i][j] = absPath(file)
+		}
+	}
+	return result
+}
+
+// absPath returns path made absolute, or unchanged if that fails.
+func absPath(path string) string {
+	if abs, err := absolutePath(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// runDiffScan scans and groups, then writes to w how the groups changed since
+// the CSV report at previousPath. If savePath is set, the current analysis is
+// written there as a CSV report for the next comparison; it may be the same
+// file as previousPath. Returns the changes found.
+func runDiffScan(ctx context.Context, opts options, previousPath, savePath string, w io.Writer) (scanChanges, error) {
+	f, err := os.Open(previousPath)
+	if err != nil {
+		return scanChanges{}, err
+	}
+	previous, err := readReportGroups(f)
+	f.Close()
+	if err != nil {
+		return scanChanges{}, fmt.Errorf("failed to read %s: %w", previousPath, err)
+	}
+
+	progress := NewProgress("Scanning")
+	stop := startProgressPrinter(os.Stderr, progress)
+	groups, _, err := scanAndGroup(ctx, opts, progress)
+	var records []FileRecord
+	if err == nil && savePath != "" {
+		records, err = buildFileRecords(ctx, groups, opts.hashCache, progress)
+	}
+	stop()
+	if err != nil {
+		return scanChanges{}, err
+	}
+
+	changes := diffScans(previous, absGroups(groups))
+	if err := writeScanChanges(w, opts.dir, changes); err != nil {
+		return scanChanges{}, err
+	}
+
+	if savePath != "" {
+		saveHashCache(opts.hashCache)
+		setFingerprints(opts.dir, records)
+		if err := saveReport(savePath, records); err != nil {
+			return scanChanges{}, err
+		}
+	}
+	return changes, nil
+}
+
+// saveReport writes records as a CSV report to path, replacing it only once
+// the new report is complete.
+func saveReport(path string, records []FileRecord) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".doppel-report-*.csv")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := writeCSV(tmp, records); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// writeScanChanges writes the changes as text, one section per kind of change.
+// Groups are shown with their fingerprints relative to the scanned directory dir.
+func writeScanChanges(w io.Writer, dir string, changes scanChanges) error {
+	if changes.empty() {
+		_, err := fmt.Fprintln(w, "No changes since the previous scan.")
+		return err
+	}
+
+	sections := []struct {
+		title  string
+		groups [][]string
+	}{
+		{"New groups", changes.NewGroups},
+		{"Resolved groups", changes.ResolvedGroups},
+	}
+	for _, section := range sections {
+		fmt.Fprintf(w, "%s: %d\n", section.title, len(section.groups))
+		for i, group := range section.groups {
+			fmt.Fprintf(w, "  Group %d: %d files (fingerprint %s)\n", i+1, len(group), groupFingerprint(dir, group))
+			for _, file := range group {
+				fmt.Fprintf(w, "    %s\n", file)
+			}
+		}
+		fmt.Fprintln(w)
+	}
+
+	fmt.Fprintf(w, "New suspect files: %d\n", len(changes.NewFiles))
+	for _, file := range changes.NewFiles {
+		if _, err := fmt.Fprintf(w, "  %s\n", file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
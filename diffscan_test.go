@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestDiffScans tests new, resolved, and grown groups.
+func TestDiffScans(t *testing.T) {
+	previous := [][]string{
+		{"/d/notes.txt", "/d/notes-1.txt"},
+		{"/d/cv.doc", "/d/cv-old.doc"},
+	}
+	current := [][]string{
+		{"/d/notes.txt", "/d/notes-1.txt", "/d/notes-2.txt"},
+		{"/d/photo.jpg", "/d/photo-1.jpg"},
+	}
+
+	got := diffScans(previous, current)
+	expected := scanChanges{
+		NewGroups:      [][]string{{"/d/photo.jpg", "/d/photo-1.jpg"}},
+		ResolvedGroups: [][]string{{"/d/cv.doc", "/d/cv-old.doc"}},
+		NewFiles:       []string{"/d/notes-2.txt", "/d/photo.jpg", "/d/photo-1.jpg"},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("diffScans() = %+v, expected %+v", got, expected)
+	}
+
+	if !diffScans(previous, previous).empty() {
+		t.Error("diffScans() of identical analyses should be empty")
+	}
+}
+
+// TestReadReportGroups tests reading groups back from a CSV report.
+func TestReadReportGroups(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	a := createFileWithContent(t, tmpDir, "a.txt", "x")
+	a1 := createFileWithContent(t, tmpDir, "a-1.txt", "x")
+	b := createFileWithContent(t, tmpDir, "b.txt", "y")
+	b1 := createFileWithContent(t, tmpDir, "b-1.txt", "z")
+
+	records, err := buildFileRecords(context.Background(), [][]string{{a, a1}, {b, b1}}, nil, nil)
+	if err != nil {
+		t.Fatalf("buildFileRecords() returned error: %v", err)
+	}
+	var report bytes.Buffer
+	if err := writeCSV(&report, records); err != nil {
+		t.Fatalf("writeCSV() returned error: %v", err)
+	}
+
+	groups, err := readReportGroups(&report)
+	if err != nil {
+		t.Fatalf("readReportGroups() returned error: %v", err)
+	}
+	if expected := [][]string{{a, a1}, {b, b1}}; !reflect.DeepEqual(groups, expected) {
+		t.Errorf("readReportGroups() = %v, expected %v", groups, expected)
+	}
+
+	if _, err := readReportGroups(strings.NewReader("name,size\na,1\n")); err == nil {
+		t.Error("readReportGroups() should reject CSV without group and path columns")
+	}
+}
+
+// TestRunDiffScan tests a weekly-style run that compares with and updates a saved report.
+func TestRunDiffScan(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	dir := filepath.Join(tmpDir, "sync")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	createFile(t, dir, "notes.txt")
+	createFile(t, dir, "notes-1.txt")
+
+	saved := filepath.Join(tmpDir, "last.csv")
+	if err := os.WriteFile(saved, []byte(strings.Join(csvHeader, ",")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts := options{dir: dir, minPrefix: defaultMinPrefixLength, scanWorkers: 1}
+
+	var out bytes.Buffer
+	changes, err := runDiffScan(context.Background(), opts, saved, saved, &out)
+	if err != nil {
+		t.Fatalf("runDiffScan() returned error: %v", err)
+	}
+	if len(changes.NewGroups) != 1 || len(changes.NewFiles) != 2 {
+		t.Errorf("first run found %+v, expected one new group of two files", changes)
+	}
+	if !strings.Contains(out.String(), "New groups: 1") {
+		t.Errorf("unexpected output:\n%s", out.String())
+	}
+
+	// The saved report now matches, so only the new copy is reported
+	createFile(t, dir, "notes-2.txt")
+	out.Reset()
+	changes, err = runDiffScan(context.Background(), opts, saved, "", &out)
+	if err != nil {
+		t.Fatalf("runDiffScan() returned error: %v", err)
+	}
+	expected := scanChanges{NewFiles: []string{filepath.Join(dir, "notes-2.txt")}}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("second run found %+v, expected %+v", changes, expected)
+	}
+}
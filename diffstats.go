@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+// diffStats summarizes how two text files differ.
+type diffStats struct {
+	// added and removed count the lines only in the second and only in the first file.
+	added, removed int
+	// similarity is the share of lines the files have in common; see lineSimilarity.
+	similarity float64
+}
+
+// computeDiffStats counts the changed lines of two text files, in any encoding
+// doppel reads, or the text of documents, with the built-in line alignment, or for files too large to align,
+// from the unified diff d produces. ok is false for binary files and when
+// neither way works.
+func computeDiffStats(d *DiffExecutor, file1, file2 string) (stats diffStats, ok bool) {
+	text1, text2, cleanup, err := textPair(d.context(), file1, file2)
+	if err != nil {
+		return diffStats{}, false
+	}
+	defer cleanup()
+	file1, file2 = text1, text2
+	if binary, err := anyBinary(file1, file2); err != nil || binary {
+		return diffStats{}, false
+	}
+	lines1, err := readLines(file1)
+	if err != nil {
+		return diffStats{}, false
+	}
+	lines2, err := readLines(file2)
+	if err != nil {
+		return diffStats{}, false
+	}
+
+	common, ok := commonLines(lines1, lines2)
+	if !ok {
+		// Templates print whatever their command does, which can't be counted
+		if d.isTemplate() {
+			return diffStats{}, false
+		}
+		diff, err := d.DiffUnified(file1, file2)
+		if err != nil {
+			return diffStats{}, false
+		}
+		_, removed := countUnifiedChanges(diff)
+		common = max(len(lines1)-removed, 0)
+	}
+
+	stats = diffStats{added: len(lines2) - common, removed: len(lines1) - common, similarity: 1}
+	if total := len(lines1) + len(lines2); total > 0 {
+		stats.similarity = 2 * float64(common) / float64(total)
+	}
+	return stats, true
+}
+
+// countUnifiedChanges counts the added and removed lines of a unified diff of
+// two files, skipping the file headers before the first hunk.
+func countUnifiedChanges(diff string) (added, removed int) {
+	inHunk := false
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			inHunk = true
+		case !inHunk:
+		case strings.HasPrefix(line, "+"):
+			added++
+		case strings.HasPrefix(line, "-"):
+			removed++
+		}
+	}
+	return added, removed
+}
+
+// diffSummary returns the line shown above a diff, such as
+// "+42 −17 lines, 93% similar, File 1 newer by 2d". Parts that can't be
+// computed, such as line counts for binary files, are left out.
+func diffSummary(d *DiffExecutor, file1, file2 string) string {
+	var parts []string
+	if d.frontmatter {
+		if c, ok := compareFrontmatter(file1, file2); ok && c.describe() != "" {
+			parts = append(parts, c.describe())
+		}
+	}
+	if stats, ok := computeDiffStats(d, file1, file2); ok {
+		parts = append(parts, fmt.Sprintf("+%d −%d lines", stats.added, stats.removed),
+			fmt.Sprintf("%d%% similar", int(math.Floor(stats.similarity*100))))
+	}
+	info1, err1 := os.Stat(file1)
+	info2, err2 := os.Stat(file2)
+	if err1 == nil && err2 == nil {
+		parts = append(parts, describeNewer(info1.ModTime(), info2.ModTime()))
+	}
+	if encodings := describeEncodings(file1, file2); encodings != "" {
+		parts = append(parts, encodings)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// describeNewer says which of two files was modified later, and by how much.
+func describeNewer(time1, time2 time.Time) string {
+	switch {
+	case time1.After(time2):
+		return "File 1 newer by " + formatAge(time1.Sub(time2))
+	case time2.After(time1):
+		return "File 2 newer by " + formatAge(time2.Sub(time1))
+	}
+	return "same modification time"
+}
+
+// formatAge formats a duration in its largest whole unit, such as 2d, 5h, or 30s.
+func formatAge(d time.Duration) string {
+	switch {
+	case d >= 24*time.Hour:
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d >= time.Minute:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	case d >= time.Second:
+		return fmt.Sprintf("%ds", d/time.Second)
+	}
+	return "under 1s"
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestComputeDiffStats tests counting changed lines with the built-in alignment.
+func TestComputeDiffStats(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	file1 := createFileWithContent(t, tmpDir, "a.txt", "one\ntwo\nthree\nfour\n")
+	file2 := createFileWithContent(t, tmpDir, "b.txt", "one\nTWO\nthree\nfour\nfive\n")
+
+	stats, ok := computeDiffStats(NewDiffExecutor(""), file1, file2)
+	if !ok {
+		t.Fatal("expected stats for two text files")
+	}
+	if stats.added != 2 || stats.removed != 1 {
+		t.Errorf("added, removed = %d, %d, expected 2, 1", stats.added, stats.removed)
+	}
+	if want := 6.0 / 9; stats.similarity != want {
+		t.Errorf("similarity = %v, expected %v", stats.similarity, want)
+	}
+
+	binary := filepath.Join(tmpDir, "c.bin")
+	if err := os.WriteFile(binary, []byte{0, 1, 2}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := computeDiffStats(NewDiffExecutor(""), file1, binary); ok {
+		t.Error("expected no stats for a binary file")
+	}
+}
+
+// TestCountUnifiedChanges tests that file headers are not counted as changes.
+func TestCountUnifiedChanges(t *testing.T) {
+	diff := "--- a.txt\n+++ b.txt\n@@ -1,3 +1,3 @@\n one\n-two\n--- dashes\n+TWO\n"
+	added, removed := countUnifiedChanges(diff)
+	if added != 1 || removed != 2 {
+		t.Errorf("added, removed = %d, %d, expected 1, 2", added, removed)
+	}
+}
+
+// TestDiffSummary tests the line shown above a diff.
+func TestDiffSummary(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	file1 := createFileWithContent(t, tmpDir, "a.txt", "one\ntwo\n")
+	file2 := createFileWithContent(t, tmpDir, "b.txt", "one\nthree\n")
+	now := time.Now()
+	if err := os.Chtimes(file1, now, now.Add(-50*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(file2, now, now); err != nil {
+		t.Fatal(err)
+	}
+
+	got := diffSummary(NewDiffExecutor(""), file1, file2)
+	if want := "+1 −1 lines, 50% similar, File 2 newer by 2d"; got != want {
+		t.Errorf("diffSummary = %q, expected %q", got, want)
+	}
+}
+
+// TestFormatAge tests rounding durations down to their largest unit.
+func TestFormatAge(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{500 * time.Millisecond, "under 1s"},
+		{90 * time.Second, "1m"},
+		{5*time.Hour + 59*time.Minute, "5h"},
+		{49 * time.Hour, "2d"},
+	}
+	for _, tt := range tests {
+		if got := formatAge(tt.d); got != tt.want {
+			t.Errorf("formatAge(%v) = %q, expected %q", tt.d, got, tt.want)
+		}
+	}
+}
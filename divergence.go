@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+)
+
+// maxDivergenceGroupSize caps the size of the groups checked by
+// --max-divergence, since every two files of a group may be compared.
+const maxDivergenceGroupSize = 100
+
+// filterDivergent returns groups without those in which every two files
+// diverge in content by more than maxDivergence, that is, are less than
+// 1-maxDivergence alike by pairSimilarity. Such groups are usually names that
+// collide by coincidence, such as notes.md of two unrelated projects. A group
+// with a pair that cannot be scored, such as two binary files that aren't
+// images, is kept, and so are groups larger than maxDivergenceGroupSize.
+func filterDivergent(ctx context.Context, groups [][]string, maxDivergence float64, progress *Progress) ([][]string, error) {
+	progress.SetPhase("Comparing")
+
+	var result [][]string
+	for _, group := range groups {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if len(group) > maxDivergenceGroupSize {
+			logger.Info("group too large to check for divergence", "path", group[0], "files", len(group))
+			result = append(result, group)
+			continue
+		}
+		if groupDiverges(group, maxDivergence) {
+			logger.Debug("left out a group", "path", group[0], "files", len(group), "reason", "every two of its files differ by more than --max-divergence")
+			continue
+		}
+		result = append(result, group)
+	}
+	if len(result) != len(groups) {
+		logger.Info("left out divergent groups", "groups", len(groups)-len(result))
+	}
+	return result, nil
+}
+
+// groupDiverges reports whether every two files of group were scored and are
+// less than 1-maxDivergence alike. It stops at the first pair that is alike
+// enough or cannot be scored.
+func groupDiverges(group []string, maxDivergence float64) bool {
+	for i := range group {
+		for j := i + 1; j < len(group); j++ {
+			score, _, ok := pairSimilarity(FileRecord{Path: group[i]}, FileRecord{Path: group[j]})
+			if !ok || 1-score <= maxDivergence {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// TestFilterDivergent tests leaving out groups whose files are all far apart
+// in content.
+func TestFilterDivergent(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	shopping := createFileWithContent(t, tmpDir, "notes.md", "shopping\nmilk\neggs\n")
+	meeting := createFileWithContent(t, tmpDir, "notes-2.md", "meeting\nagenda\nbudget\n")
+	todo := createFileWithContent(t, tmpDir, "todo.md", "call bank\nfix bike\nwater plants\n")
+	todoEdited := createFileWithContent(t, tmpDir, "todo-1.md", "call bank\nfix bike\nbook flights\n")
+	todoOther := createFileWithContent(t, tmpDir, "todo-2.md", "garden\n")
+	blob := createFileWithContent(t, tmpDir, "blob.bin", "\x00\x01")
+	blob2 := createFileWithContent(t, tmpDir, "blob-2.bin", "\x00\x02")
+
+	groups := [][]string{
+		{shopping, meeting},
+		{todo, todoEdited, todoOther},
+		{blob, blob2},
+	}
+	got, err := filterDivergent(context.Background(), groups, 0.8, nil)
+	if err != nil {
+		t.Fatalf("filterDivergent() returned error: %v", err)
+	}
+	// The todo lists share a pair 67% alike, and binary files can't be scored
+	expected := groups[1:]
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("filterDivergent() = %v, expected %v", got, expected)
+	}
+
+	if got, _ := filterDivergent(context.Background(), groups, 1, nil); len(got) != len(groups) {
+		t.Errorf("filterDivergent() with 1 should keep every group, got %v", got)
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// textEncoding is a character encoding doppel can read text files in.
+type textEncoding int
+
+const (
+	encodingUTF8 textEncoding = iota
+	encodingUTF8BOM
+	encodingUTF16LE
+	encodingUTF16BE
+	encodingLatin1
+)
+
+// String returns the name shown for the encoding.
+func (e textEncoding) String() string {
+	switch e {
+	case encodingUTF8BOM:
+		return "UTF-8 with BOM"
+	case encodingUTF16LE:
+		return "UTF-16LE"
+	case encodingUTF16BE:
+		return "UTF-16BE"
+	case encodingLatin1:
+		return "Latin-1"
+	}
+	return "UTF-8"
+}
+
+var (
+	utf8BOM    = []byte{0xEF, 0xBB, 0xBF}
+	utf16LEBOM = []byte{0xFF, 0xFE}
+	utf16BEBOM = []byte{0xFE, 0xFF}
+)
+
+// windows1252 holds the characters Windows-1252 puts at 0x80-0x9F, where
+// Latin-1 has control characters no text uses. Files exported as "Latin-1" or
+// "ANSI" on Windows are Windows-1252, so its curly quotes and dashes are kept.
+// Unassigned bytes map to themselves, as in Latin-1.
+var windows1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8D, 'Ž', 0x8F,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9D, 'ž', 'Ÿ',
+}
+
+// detectEncoding guesses the encoding of text from its first bytes: a byte
+// order mark, the NUL bytes UTF-16 gives ASCII characters, or else valid UTF-8.
+// Text that is none of these is taken to be Latin-1. truncated says the
+// sample was cut from a longer file. ok is false for data that looks binary
+// rather than like text in any of these encodings.
+func detectEncoding(sample []byte, truncated bool) (enc textEncoding, ok bool) {
+	switch {
+	case bytes.HasPrefix(sample, utf8BOM):
+		return encodingUTF8BOM, true
+	case bytes.HasPrefix(sample, utf16LEBOM):
+		return encodingUTF16LE, true
+	case bytes.HasPrefix(sample, utf16BEBOM):
+		return encodingUTF16BE, true
+	}
+	if enc, ok := guessUTF16(sample); ok {
+		return enc, true
+	}
+	if bytes.IndexByte(sample, 0) >= 0 {
+		return encodingUTF8, false
+	}
+	// A cut sample may end in the middle of a character
+	if truncated {
+		sample = sample[:max(len(sample)-utf8.UTFMax, 0)]
+	}
+	if utf8.Valid(sample) {
+		return encodingUTF8, true
+	}
+	// Text has few control characters
+	controls := 0
+	for _, b := range sample {
+		if isControl(rune(b)) {
+			controls++
+		}
+	}
+	return encodingLatin1, controls*100 < len(sample)
+}
+
+// isControl reports whether r is a control character other than whitespace
+// and the escape that starts terminal color codes.
+func isControl(r rune) bool {
+	return (r < 0x20 && !strings.ContainsRune("\t\n\v\f\r\x1b", r)) || r == 0x7F
+}
+
+// guessUTF16 recognizes UTF-16 without a byte order mark, as some exporters
+// write it, by the NUL byte that precedes or follows each ASCII character.
+// A few characters are too little to tell, so shorter samples are not guessed.
+func guessUTF16(sample []byte) (textEncoding, bool) {
+	if len(sample) < 8 {
+		return encodingUTF8, false
+	}
+	var even, odd int
+	for i, b := range sample[:len(sample)&^1] {
+		if b != 0 {
+			continue
+		}
+		if i%2 == 0 {
+			even++
+		} else {
+			odd++
+		}
+	}
+	units := len(sample) / 2
+	enc := encodingUTF8
+	switch {
+	case odd*2 > units && even*10 < units:
+		enc = encodingUTF16LE
+	case even*2 > units && odd*10 < units:
+		enc = encodingUTF16BE
+	default:
+		return encodingUTF8, false
+	}
+	// Binary data can have the same pattern of NULs, but not only characters
+	for _, r := range decodeText(sample[:units*2], enc) {
+		if isControl(r) {
+			return encodingUTF8, false
+		}
+	}
+	return enc, true
+}
+
+// decodeText converts text in enc to UTF-8, dropping any byte order mark.
+// Invalid sequences are kept as they are, to be shown as replacement
+// characters like in any other malformed file.
+func decodeText(data []byte, enc textEncoding) string {
+	switch enc {
+	case encodingUTF8BOM:
+		return string(bytes.TrimPrefix(data, utf8BOM))
+	case encodingUTF16LE, encodingUTF16BE:
+		var order binary.ByteOrder = binary.LittleEndian
+		bom := utf16LEBOM
+		if enc == encodingUTF16BE {
+			order, bom = binary.BigEndian, utf16BEBOM
+		}
+		data = bytes.TrimPrefix(data, bom)
+		units := make([]uint16, len(data)/2)
+		for i := range units {
+			units[i] = order.Uint16(data[2*i:])
+		}
+		return string(utf16.Decode(units))
+	case encodingLatin1:
+		var s strings.Builder
+		s.Grow(len(data))
+		for _, b := range data {
+			if b >= 0x80 && b < 0xA0 {
+				s.WriteRune(windows1252[b-0x80])
+			} else {
+				s.WriteRune(rune(b))
+			}
+		}
+		return s.String()
+	}
+	return string(data)
+}
+
+// decodeFile returns the text of data as UTF-8 and the encoding it was in.
+// Data that doesn't look like text is returned unchanged.
+func decodeFile(data []byte) (string, textEncoding) {
+	enc, ok := detectEncoding(data[:min(len(data), binarySniffLen)], len(data) > binarySniffLen)
+	if !ok {
+		return string(data), encodingUTF8
+	}
+	return decodeText(data, enc), enc
+}
+
+// fileEncoding returns the encoding of the text file at path. ok is false for
+// files that look binary.
+func fileEncoding(path string) (enc textEncoding, ok bool, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return encodingUTF8, false, err
+	}
+	defer f.Close()
+
+	buf := make([]byte, binarySniffLen)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return encodingUTF8, false, err
+	}
+	enc, ok = detectEncoding(buf[:n], n == len(buf))
+	return enc, ok, nil
+}
+
+// describeEncodings says which encodings two files are in when they differ,
+// such as "File 1 is UTF-16LE, File 2 is UTF-8", and is empty otherwise.
+func describeEncodings(file1, file2 string) string {
+	enc1, ok1, err1 := fileEncoding(file1)
+	enc2, ok2, err2 := fileEncoding(file2)
+	if err1 != nil || err2 != nil || !ok1 || !ok2 || enc1 == enc2 {
+		return ""
+	}
+	return "File 1 is " + enc1.String() + ", File 2 is " + enc2.String()
+}
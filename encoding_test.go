@@ -0,0 +1,127 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+// utf16LE encodes s as UTF-16LE, with a byte order mark if bom is set.
+func utf16LE(s string, bom bool) []byte {
+	var data []byte
+	if bom {
+		data = append(data, 0xFF, 0xFE)
+	}
+	for _, u := range utf16.Encode([]rune(s)) {
+		data = append(data, byte(u), byte(u>>8))
+	}
+	return data
+}
+
+// TestDetectEncoding tests recognizing encodings by byte order marks and content.
+func TestDetectEncoding(t *testing.T) {
+	tests := []struct {
+		name   string
+		sample []byte
+		want   textEncoding
+		ok     bool
+	}{
+		{"ascii", []byte("plain text\n"), encodingUTF8, true},
+		{"utf-8", []byte("café\n"), encodingUTF8, true},
+		{"utf-8 bom", []byte("\xEF\xBB\xBFcafé\n"), encodingUTF8BOM, true},
+		{"utf-16le bom", utf16LE("café\n", true), encodingUTF16LE, true},
+		{"utf-16le", utf16LE("shopping list\n", false), encodingUTF16LE, true},
+		{"utf-16be bom", []byte{0xFE, 0xFF, 0, 'h', 0, 'i'}, encodingUTF16BE, true},
+		{"latin-1", []byte("caf\xe9 cr\xe8me\n"), encodingLatin1, true},
+		{"binary", []byte{0x89, 'P', 'N', 'G', 0, 0, 0, 0x0d, 1, 2, 3, 4}, encodingUTF8, false},
+		{"binary without nul", []byte("\x01\x02\x03\x04\xff\xfe\x05"), encodingLatin1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := detectEncoding(tt.sample, false)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("detectEncoding() = %v, %v, expected %v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+// TestDetectEncoding_Truncated tests that a sample cut inside a character is still UTF-8.
+func TestDetectEncoding_Truncated(t *testing.T) {
+	sample := []byte("caf\xc3")
+	if enc, _ := detectEncoding(sample, true); enc != encodingUTF8 {
+		t.Errorf("detectEncoding() of a cut sample = %v, expected UTF-8", enc)
+	}
+	if enc, _ := detectEncoding(sample, false); enc != encodingLatin1 {
+		t.Errorf("detectEncoding() of a whole file = %v, expected Latin-1", enc)
+	}
+}
+
+// TestDecodeText tests converting each encoding to UTF-8.
+func TestDecodeText(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		enc  textEncoding
+		want string
+	}{
+		{"utf-8 bom", []byte("\xEF\xBB\xBFnote"), encodingUTF8BOM, "note"},
+		{"utf-16le", utf16LE("café 🙂", true), encodingUTF16LE, "café 🙂"},
+		{"utf-16be", []byte{0xFE, 0xFF, 0, 'h', 0, 0xE9}, encodingUTF16BE, "hé"},
+		{"latin-1", []byte("caf\xe9"), encodingLatin1, "café"},
+		{"windows-1252", []byte("\x93quoted\x94 \x96 \x80"), encodingLatin1, "“quoted” – €"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decodeText(tt.data, tt.enc); got != tt.want {
+				t.Errorf("decodeText() = %q, expected %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestComparePair_Encodings tests that a UTF-16 file is diffed as text against
+// its UTF-8 copy, and that the summary names both encodings.
+func TestComparePair_Encodings(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	file1 := filepath.Join(tmpDir, "notes.txt")
+	if err := os.WriteFile(file1, utf16LE("Crème brûlée\nmilk\n", true), 0644); err != nil {
+		t.Fatal(err)
+	}
+	file2 := createFileWithContent(t, tmpDir, "notes-1.txt", "Crème brûlée\nbread\n")
+
+	output, err := NewDiffExecutor("").ComparePair(file1, file2, true)
+	if err != nil {
+		t.Fatalf("ComparePair() returned error: %v", err)
+	}
+	if strings.Contains(output, "Binary files differ") || !strings.Contains(output, "-milk") ||
+		!strings.Contains(output, "+bread") || strings.Contains(output, "-Crème") {
+		t.Errorf("ComparePair() = %q, expected a text diff of the changed line", output)
+	}
+
+	summary := diffSummary(NewDiffExecutor(""), file1, file2)
+	if !strings.Contains(summary, "+1 −1 lines") || !strings.Contains(summary, "File 1 is UTF-16LE, File 2 is UTF-8") {
+		t.Errorf("diffSummary() = %q, expected line counts and both encodings", summary)
+	}
+}
+
+// TestFilePreview_Latin1 tests that previews show Latin-1 text as UTF-8.
+func TestFilePreview_Latin1(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	path := createFileWithContent(t, tmpDir, "recipe.txt", "Cr\xe8me br\xfbl\xe9e\n")
+	preview, err := filePreview(path, 5, 40)
+	if err != nil {
+		t.Fatalf("filePreview() returned error: %v", err)
+	}
+	if preview != "Crème brûlée" {
+		t.Errorf("filePreview() = %q, expected the decoded text", preview)
+	}
+}
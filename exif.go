@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"strings"
+)
+
+// EXIF/TIFF tag IDs used by doppel.
+const (
+	tagMake             = 0x010F
+	tagModel            = 0x0110
+	tagDateTime         = 0x0132
+	tagExifIFDPointer   = 0x8769
+	tagGPSIFDPointer    = 0x8825
+	tagDateTimeOriginal = 0x9003
+)
+
+// GPS IFD tag IDs.
+const (
+	tagGPSLatitudeRef  = 1
+	tagGPSLatitude     = 2
+	tagGPSLongitudeRef = 3
+	tagGPSLongitude    = 4
+)
+
+// TIFF field types.
+const (
+	tiffTypeByte     = 1
+	tiffTypeASCII    = 2
+	tiffTypeShort    = 3
+	tiffTypeLong     = 4
+	tiffTypeRational = 5
+)
+
+// maxEXIFScan bounds how much of a JPEG is read while looking for the EXIF segment.
+const maxEXIFScan = 1 << 20
+
+var errNoEXIF = errors.New("no EXIF data")
+
+// tiffEntry is a single IFD entry with its raw value bytes resolved.
+type tiffEntry struct {
+	typ   uint16
+	count uint32
+	value []byte
+}
+
+// exifData holds the parsed IFDs of an EXIF block.
+type exifData struct {
+	order binary.ByteOrder
+	ifd0  map[uint16]tiffEntry
+	exif  map[uint16]tiffEntry
+	gps   map[uint16]tiffEntry
+}
+
+// readEXIF extracts EXIF data from a JPEG file.
+// Returns errNoEXIF if the file has no EXIF segment.
+func readEXIF(path string) (*exifData, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, maxEXIFScan))
+	if err != nil {
+		return nil, err
+	}
+	tiff, err := findEXIFSegment(data)
+	if err != nil {
+		return nil, err
+	}
+	return parseTIFF(tiff)
+}
+
+// findEXIFSegment walks JPEG markers and returns the TIFF payload of the APP1 Exif segment.
+func findEXIFSegment(data []byte) ([]byte, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, errNoEXIF
+	}
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return nil, errNoEXIF
+		}
+		marker := data[pos+1]
+		// Start of scan or end of image: no more metadata segments
+		if marker == 0xDA || marker == 0xD9 {
+			return nil, errNoEXIF
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		end := pos + 2 + length
+		if length < 2 || end > len(data) {
+			return nil, errNoEXIF
+		}
+		segment := data[pos+4 : end]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return segment[6:], nil
+		}
+		pos = end
+	}
+	return nil, errNoEXIF
+}
+
+// parseTIFF parses IFD0 and the Exif and GPS sub-IFDs of a TIFF structure.
+func parseTIFF(tiff []byte) (*exifData, error) {
+	if len(tiff) < 8 {
+		return nil, errNoEXIF
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, errNoEXIF
+	}
+	if order.Uint16(tiff[2:]) != 42 {
+		return nil, errNoEXIF
+	}
+
+	d := &exifData{order: order}
+	d.ifd0 = parseIFD(tiff, order, order.Uint32(tiff[4:]))
+	if ptr, ok := d.ifd0[tagExifIFDPointer]; ok {
+		d.exif = parseIFD(tiff, order, d.uintValue(ptr))
+	}
+	if ptr, ok := d.ifd0[tagGPSIFDPointer]; ok {
+		d.gps = parseIFD(tiff, order, d.uintValue(ptr))
+	}
+	return d, nil
+}
+
+// parseIFD reads the entries of the IFD at offset. Malformed entries are skipped.
+func parseIFD(tiff []byte, order binary.ByteOrder, offset uint32) map[uint16]tiffEntry {
+	entries := make(map[uint16]tiffEntry)
+	if int(offset)+2 > len(tiff) {
+		return entries
+	}
+	n := int(order.Uint16(tiff[offset:]))
+	for i := 0; i < n; i++ {
+		start := int(offset) + 2 + i*12
+		if start+12 > len(tiff) {
+			break
+		}
+		tag := order.Uint16(tiff[start:])
+		typ := order.Uint16(tiff[start+2:])
+		count := order.Uint32(tiff[start+4:])
+		size := tiffTypeSize(typ) * int(count)
+		if size <= 0 {
+			continue
+		}
+		var value []byte
+		if size <= 4 {
+			value = tiff[start+8 : start+8+size]
+		} else {
+			valueOffset := int(order.Uint32(tiff[start+8:]))
+			if valueOffset < 0 || valueOffset+size > len(tiff) {
+				continue
+			}
+			value = tiff[valueOffset : valueOffset+size]
+		}
+		entries[tag] = tiffEntry{typ: typ, count: count, value: value}
+	}
+	return entries
+}
+
+// tiffTypeSize returns the byte size of one value of a TIFF field type.
+func tiffTypeSize(typ uint16) int {
+	switch typ {
+	case tiffTypeByte, tiffTypeASCII:
+		return 1
+	case tiffTypeShort:
+		return 2
+	case tiffTypeLong:
+		return 4
+	case tiffTypeRational:
+		return 8
+	}
+	return 0
+}
+
+// uintValue returns the first value of a SHORT or LONG entry.
+func (d *exifData) uintValue(e tiffEntry) uint32 {
+	switch e.typ {
+	case tiffTypeShort:
+		return uint32(d.order.Uint16(e.value))
+	case tiffTypeLong:
+		return d.order.Uint32(e.value)
+	}
+	return 0
+}
+
+// stringValue returns an ASCII entry with its NUL terminator and padding removed.
+func stringValue(e tiffEntry) string {
+	if e.typ != tiffTypeASCII {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimRight(string(e.value), "\x00"))
+}
+
+// CaptureTime returns DateTimeOriginal, falling back to the IFD0 DateTime.
+func (d *exifData) CaptureTime() string {
+	if e, ok := d.exif[tagDateTimeOriginal]; ok {
+		return stringValue(e)
+	}
+	if e, ok := d.ifd0[tagDateTime]; ok {
+		return stringValue(e)
+	}
+	return ""
+}
+
+// rationalValue returns the i-th value of a RATIONAL entry. ok is false if the
+// entry has no such value or its denominator is zero.
+func (d *exifData) rationalValue(e tiffEntry, i int) (float64, bool) {
+	if e.typ != tiffTypeRational || (i+1)*8 > len(e.value) {
+		return 0, false
+	}
+	num := d.order.Uint32(e.value[i*8:])
+	den := d.order.Uint32(e.value[i*8+4:])
+	if den == 0 {
+		return 0, false
+	}
+	return float64(num) / float64(den), true
+}
+
+// Camera returns the camera's make and model, such as "Apple iPhone 12".
+// The make is left out when the model already starts with it.
+func (d *exifData) Camera() string {
+	maker := stringValue(d.ifd0[tagMake])
+	model := stringValue(d.ifd0[tagModel])
+	if maker == "" || strings.HasPrefix(strings.ToLower(model), strings.ToLower(maker)) {
+		return model
+	}
+	return strings.TrimSpace(maker + " " + model)
+}
+
+// Location returns the GPS position in decimal degrees, negative for south
+// and west. ok is false if the image has no complete position.
+func (d *exifData) Location() (latitude, longitude float64, ok bool) {
+	latitude, ok1 := d.gpsCoordinate(tagGPSLatitude, tagGPSLatitudeRef, "S")
+	longitude, ok2 := d.gpsCoordinate(tagGPSLongitude, tagGPSLongitudeRef, "W")
+	return latitude, longitude, ok1 && ok2
+}
+
+// gpsCoordinate converts a GPS coordinate stored as degrees, minutes, and
+// seconds to decimal degrees, negated when its reference is negativeRef.
+func (d *exifData) gpsCoordinate(tag, refTag uint16, negativeRef string) (float64, bool) {
+	e, ok := d.gps[tag]
+	if !ok {
+		return 0, false
+	}
+	var value float64
+	for i, unit := range []float64{1, 60, 3600} {
+		part, ok := d.rationalValue(e, i)
+		if !ok {
+			return 0, false
+		}
+		value += part / unit
+	}
+	if stringValue(d.gps[refTag]) == negativeRef {
+		value = -value
+	}
+	return value, true
+}
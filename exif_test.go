@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"testing"
+)
+
+// testTag is an IFD entry used to build EXIF fixtures.
+type testTag struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	data  []byte
+}
+
+// asciiTag builds an ASCII entry with a NUL terminator.
+func asciiTag(tag uint16, value string) testTag {
+	return testTag{tag: tag, typ: tiffTypeASCII, count: uint32(len(value) + 1), data: append([]byte(value), 0)}
+}
+
+// buildTIFF lays out a little-endian TIFF structure with IFD0 and an optional Exif sub-IFD.
+func buildTIFF(ifd0, exif []testTag) []byte {
+	order := binary.LittleEndian
+	ifdSize := func(n int) int { return 2 + 12*n + 4 }
+
+	if len(exif) > 0 {
+		ifd0 = append(ifd0, testTag{tag: tagExifIFDPointer, typ: tiffTypeLong, count: 1})
+	}
+	ifd0Offset := 8
+	exifOffset := ifd0Offset + ifdSize(len(ifd0))
+	dataOffset := exifOffset
+	if len(exif) > 0 {
+		dataOffset += ifdSize(len(exif))
+	}
+
+	var data bytes.Buffer
+	writeIFD := func(buf *bytes.Buffer, tags []testTag) {
+		binary.Write(buf, order, uint16(len(tags)))
+		for _, tg := range tags {
+			binary.Write(buf, order, tg.tag)
+			binary.Write(buf, order, tg.typ)
+			if tg.tag == tagExifIFDPointer {
+				binary.Write(buf, order, uint32(1))
+				binary.Write(buf, order, uint32(exifOffset))
+				continue
+			}
+			binary.Write(buf, order, tg.count)
+			if len(tg.data) <= 4 {
+				padded := make([]byte, 4)
+				copy(padded, tg.data)
+				buf.Write(padded)
+			} else {
+				binary.Write(buf, order, uint32(dataOffset+data.Len()))
+				data.Write(tg.data)
+			}
+		}
+		binary.Write(buf, order, uint32(0))
+	}
+
+	var out bytes.Buffer
+	out.WriteString("II")
+	binary.Write(&out, order, uint16(42))
+	binary.Write(&out, order, uint32(ifd0Offset))
+	writeIFD(&out, ifd0)
+	if len(exif) > 0 {
+		writeIFD(&out, exif)
+	}
+	out.Write(data.Bytes())
+	return out.Bytes()
+}
+
+// buildEXIFJPEG wraps a TIFF structure in a minimal JPEG APP1 segment.
+func buildEXIFJPEG(tiff []byte) []byte {
+	var out bytes.Buffer
+	out.Write([]byte{0xFF, 0xD8, 0xFF, 0xE1})
+	binary.Write(&out, binary.BigEndian, uint16(len(tiff)+8))
+	out.WriteString("Exif\x00\x00")
+	out.Write(tiff)
+	out.Write([]byte{0xFF, 0xD9})
+	return out.Bytes()
+}
+
+// TestReadEXIF_CaptureTime tests reading DateTimeOriginal from the Exif sub-IFD.
+func TestReadEXIF_CaptureTime(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	tiff := buildTIFF(
+		[]testTag{asciiTag(tagDateTime, "2024:02:02 10:00:00")},
+		[]testTag{asciiTag(tagDateTimeOriginal, "2024:01:30 08:15:00")},
+	)
+	path := createFileWithContent(t, tmpDir, "photo.jpg", string(buildEXIFJPEG(tiff)))
+
+	exif, err := readEXIF(path)
+	if err != nil {
+		t.Fatalf("readEXIF() returned error: %v", err)
+	}
+	if got := exif.CaptureTime(); got != "2024:01:30 08:15:00" {
+		t.Errorf("CaptureTime() = %q, expected DateTimeOriginal", got)
+	}
+}
+
+// TestReadEXIF_FallbackDateTime tests falling back to the IFD0 DateTime tag.
+func TestReadEXIF_FallbackDateTime(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	tiff := buildTIFF([]testTag{asciiTag(tagDateTime, "2024:02:02 10:00:00")}, nil)
+	path := createFileWithContent(t, tmpDir, "photo.jpg", string(buildEXIFJPEG(tiff)))
+
+	exif, err := readEXIF(path)
+	if err != nil {
+		t.Fatalf("readEXIF() returned error: %v", err)
+	}
+	if got := exif.CaptureTime(); got != "2024:02:02 10:00:00" {
+		t.Errorf("CaptureTime() = %q, expected DateTime", got)
+	}
+}
+
+// TestReadEXIF_NoEXIF tests files without EXIF data.
+func TestReadEXIF_NoEXIF(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	tests := map[string]string{
+		"text.jpg":  "not a jpeg",
+		"plain.jpg": "\xFF\xD8\xFF\xDA\x00\x02",
+	}
+	for name, content := range tests {
+		path := createFileWithContent(t, tmpDir, name, content)
+		if _, err := readEXIF(path); err != errNoEXIF {
+			t.Errorf("readEXIF(%s) error = %v, expected errNoEXIF", name, err)
+		}
+	}
+}
+
+// TestReadEXIF_Camera tests combining the camera make and model.
+func TestReadEXIF_Camera(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	tests := []struct {
+		maker, model, want string
+	}{
+		{"Apple", "iPhone 12", "Apple iPhone 12"},
+		{"Canon", "Canon EOS R5", "Canon EOS R5"},
+		{"", "Pixel 8", "Pixel 8"},
+	}
+	for _, tt := range tests {
+		var tags []testTag
+		if tt.maker != "" {
+			tags = append(tags, asciiTag(tagMake, tt.maker))
+		}
+		tags = append(tags, asciiTag(tagModel, tt.model))
+		path := createFileWithContent(t, tmpDir, "photo.jpg", string(buildEXIFJPEG(buildTIFF(tags, nil))))
+
+		exif, err := readEXIF(path)
+		if err != nil {
+			t.Fatalf("readEXIF() returned error: %v", err)
+		}
+		if got := exif.Camera(); got != tt.want {
+			t.Errorf("Camera() = %q, expected %q", got, tt.want)
+		}
+	}
+}
+
+// TestEXIFLocation tests converting GPS degrees, minutes, and seconds to decimal degrees.
+func TestEXIFLocation(t *testing.T) {
+	order := binary.LittleEndian
+	rationals := func(values ...uint32) tiffEntry {
+		var data []byte
+		for i := 0; i < len(values); i += 2 {
+			data = order.AppendUint32(data, values[i])
+			data = order.AppendUint32(data, values[i+1])
+		}
+		return tiffEntry{typ: tiffTypeRational, count: uint32(len(values) / 2), value: data}
+	}
+	ascii := func(s string) tiffEntry {
+		return tiffEntry{typ: tiffTypeASCII, count: 2, value: []byte(s + "\x00")}
+	}
+
+	d := &exifData{order: order, gps: map[uint16]tiffEntry{
+		tagGPSLatitudeRef:  ascii("S"),
+		tagGPSLatitude:     rationals(33, 1, 51, 1, 3240, 100),
+		tagGPSLongitudeRef: ascii("E"),
+		tagGPSLongitude:    rationals(151, 1, 12, 1, 3600, 100),
+	}}
+	lat, lon, ok := d.Location()
+	if !ok {
+		t.Fatal("Location() found no position")
+	}
+	if math.Abs(lat-(-33.859)) > 1e-9 || math.Abs(lon-151.21) > 1e-9 {
+		t.Errorf("Location() = %v, %v, expected -33.859, 151.21", lat, lon)
+	}
+
+	delete(d.gps, tagGPSLongitude)
+	if _, _, ok := d.Location(); ok {
+		t.Error("Location() should need both coordinates")
+	}
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// explanation says step by step why two files are or aren't grouped: whether
+// the scan keeps them, what the suffix filter makes of them, and how their
+// names or contents compare.
+type explanation struct {
+	lines []string
+	// grouped is what the steps conclude. The ignore list isn't consulted.
+	grouped bool
+}
+
+// add appends a step, labelled with what was checked.
+func (e *explanation) add(label, format string, args ...any) {
+	e.lines = append(e.lines, fmt.Sprintf("%-8s %s", label+":", fmt.Sprintf(format, args...)))
+}
+
+// explainPair explains whether scanning with opts groups file1 and file2.
+// files are the other files the scan found, before the suffix filter, which
+// keeps a file without a version suffix only if a version of it is among them.
+func explainPair(opts options, file1, file2 string, files []string) explanation {
+	e := explanation{grouped: true}
+	name1, name2 := filepath.Base(file1), filepath.Base(file2)
+	if name1 == name2 {
+		// Tell the files apart by where they are
+		name1, name2 = file1, file2
+	}
+
+	scanner := opts.scanner(filepath.Dir(file1))
+	for _, f := range []struct{ path, name string }{{file1, name1}, {file2, name2}} {
+		reason := scanSkipReason(scanner, f.path)
+		if reason == "" && opts.skipGitIgnored && gitIgnored(f.path) {
+			reason = "git ignores it, and --skip-git-ignored leaves it out"
+		}
+		if reason != "" {
+			e.add("scan", "%s is skipped: %s", f.name, reason)
+			e.grouped = false
+		} else {
+			e.add("scan", "%s is scanned", f.name)
+		}
+	}
+
+	if opts.suffixPattern != nil {
+		for _, f := range []struct{ path, name string }{{file1, name1}, {file2, name2}} {
+			kept, reason := explainSuffix(f.path, opts.suffixPattern, opts.datesAsVersions, files)
+			e.add("suffix", "%s %s", f.name, reason)
+			e.grouped = e.grouped && kept
+		}
+	}
+
+	if opts.byContent {
+		identical, err := filesByteIdentical(file1, file2)
+		switch {
+		case err != nil:
+			e.add("content", "can't be compared: %v", err)
+			e.grouped = false
+		case !identical:
+			e.add("content", "the contents differ, and --by-content only groups identical files")
+			e.grouped = false
+		case fileSize(file1) == 0:
+			e.add("content", "both files are empty, and empty files are never grouped")
+			e.grouped = false
+		default:
+			e.add("content", "the contents are identical")
+		}
+		return e
+	}
+
+	m := opts.matcher()
+	match1, match2 := m.matchName(file1), m.matchName(file2)
+	for _, f := range []struct {
+		name  string
+		match matchName
+	}{{name1, match1}, {name2, match2}} {
+		if compared := f.match.filename; compared != filepath.Base(f.match.fullPath) {
+			e.add("names", "%s is compared as %q", f.name, compared)
+		}
+	}
+	if m.opts.SameExtOnly && match1.ext != match2.ext {
+		e.add("names", "the extensions %q and %q differ, and --same-ext-only keeps them apart", match1.ext, match2.ext)
+		e.grouped = false
+		return e
+	}
+
+	if re := m.opts.BaseRegex; re != nil {
+		base1, ok1 := regexBase(re, match1.stem)
+		base2, ok2 := regexBase(re, match2.stem)
+		switch {
+		case !ok1 || !ok2:
+			for _, f := range []struct {
+				name string
+				ok   bool
+			}{{name1, ok1}, {name2, ok2}} {
+				if !f.ok {
+					e.add("regex", "--group-by-regex doesn't match %s", f.name)
+				}
+			}
+			e.grouped = false
+		case base1 != base2:
+			e.add("regex", "the bases %q and %q differ", base1, base2)
+			e.grouped = false
+		default:
+			e.add("regex", "both have the base %q", base1)
+		}
+		return e
+	}
+
+	prefix := commonPrefix(match1.filename, match2.filename)
+	if len(prefix) >= m.minPrefixLength {
+		e.add("prefix", "the common prefix %q is %s, at least --min-prefix %d", prefix, plural(len(prefix), "byte"), m.minPrefixLength)
+	} else {
+		e.add("prefix", "the common prefix %q is %s, shorter than --min-prefix %d", prefix, plural(len(prefix), "byte"), m.minPrefixLength)
+		e.grouped = false
+	}
+	if ratio := m.opts.PrefixRatio; ratio > 0 && e.grouped {
+		shorter := min(len(match1.filename), len(match2.filename))
+		covered := float64(len(prefix)) / float64(shorter)
+		if m.prefixMatches(prefix, match1.filename, match2.filename) {
+			e.add("prefix", "it covers %.0f%% of the shorter name, at least --prefix-ratio %g", covered*100, ratio)
+		} else {
+			e.add("prefix", "it covers %.0f%% of the shorter name, less than --prefix-ratio %g", covered*100, ratio)
+			e.grouped = false
+		}
+	}
+	if split := m.opts.SplitSimilarity; split > 0 && e.grouped {
+		similarity := nameSimilarity(match1.stem, match2.stem)
+		if similarity >= split {
+			e.add("split", "the names are %.0f%% alike, at least --split-groups %g", similarity*100, split)
+		} else {
+			e.add("split", "the names are %.0f%% alike, less than --split-groups %g, so their group is split between them", similarity*100, split)
+			e.grouped = false
+		}
+	}
+	if divergence := opts.maxDivergence; divergence > 0 && e.grouped {
+		// Whether the group is left out depends on its other files too
+		score, _, ok := pairSimilarity(FileRecord{Path: file1}, FileRecord{Path: file2})
+		switch {
+		case !ok:
+			e.add("content", "their contents can't be scored, so --max-divergence %g keeps their group", divergence)
+		case 1-score > divergence:
+			e.add("content", "their contents are %.0f%% alike, further apart than --max-divergence %g, so their group is left out unless two of its files are closer", score*100, divergence)
+		default:
+			e.add("content", "their contents are %.0f%% alike, within --max-divergence %g, so their group is kept", score*100, divergence)
+		}
+	}
+	return e
+}
+
+// scanSkipReason returns why scanner leaves out path, or "" if it is scanned.
+func scanSkipReason(scanner *Scanner, path string) string {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err.Error()
+	}
+	if !scanner.includeHidden && isHidden(filepath.Base(path)) {
+		return "hidden files are left out without --hidden"
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		if scanner.skipSymlinks {
+			return "symbolic links are left out with --skip-symlinks"
+		}
+		if info, err = os.Stat(path); err != nil {
+			return "broken symbolic link"
+		}
+	}
+	if info.IsDir() {
+		return "it is a directory"
+	}
+	reason, err := scanner.skipReason(path, func() (os.FileInfo, error) { return info, nil })
+	if err != nil {
+		return err.Error()
+	}
+	return reason
+}
+
+// gitIgnored reports whether the repository file is in, if any, ignores it.
+func gitIgnored(file string) bool {
+	repo, err := FindGitRepo(filepath.Dir(file))
+	if err != nil || repo == nil {
+		return false
+	}
+	kept, err := repo.FilterIgnored(context.Background(), []string{file})
+	return err == nil && len(kept) == 0
+}
+
+// explainSuffix says whether the suffix filter keeps file and why, judging a
+// file without a version suffix by whether versions of it are among files.
+func explainSuffix(file string, pattern *regexp.Regexp, datesAsVersions bool, files []string) (bool, string) {
+	base, kind := matchVersionSuffix(file, pattern, datesAsVersions)
+	if kind == suffixVersion {
+		return true, fmt.Sprintf("is a version of %q", base)
+	}
+
+	stem := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	if i := slices.IndexFunc(files, func(f string) bool {
+		b, k := matchVersionSuffix(f, pattern, datesAsVersions)
+		return k == suffixVersion && b == stem && f != file
+	}); i >= 0 {
+		return true, fmt.Sprintf("is the base of the version %s", filepath.Base(files[i]))
+	}
+	if kind == suffixDate {
+		return false, "ends in a date, which is not taken for a version without --dates-as-versions, and has no versions"
+	}
+	return false, fmt.Sprintf("doesn't end in --suffix %s and has no versions", pattern)
+}
+
+// runExplain explains to w why file1 and file2 are or aren't grouped when the
+// directory holding them is scanned with opts, and then reports the groups an
+// actual scan puts them in.
+func runExplain(ctx context.Context, opts options, file1, file2 string, w io.Writer) error {
+	files, err := opts.scanner(opts.dir).ScanContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to scan directory: %w", err)
+	}
+	e := explainPair(opts, file1, file2, files)
+
+	groups, _, err := scanAndGroup(ctx, opts, nil)
+	if err != nil {
+		return err
+	}
+	i, j := groupOf(groups, file1), groupOf(groups, file2)
+	switch {
+	case i >= 0 && i == j:
+		e.add("result", "grouped together, in group %d of %d", i+1, len(groups))
+	case i >= 0 && j >= 0:
+		e.add("result", "in different groups, %d and %d", i+1, j+1)
+	default:
+		e.add("result", "not grouped together")
+	}
+	if i < 0 && opts.ignoreList != nil && !opts.includeIgnored {
+		opts.includeIgnored = true
+		all, _, err := scanAndGroup(ctx, opts, nil)
+		if err != nil {
+			return err
+		}
+		if k := groupOf(all, file1); k >= 0 && k == groupOf(all, file2) {
+			e.add("result", "their group is ignored; --include-ignored shows it")
+		}
+	}
+	for _, line := range e.lines {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// groupOf returns the index of the group holding file, or -1.
+func groupOf(groups [][]string, file string) int {
+	return slices.IndexFunc(groups, func(g []string) bool { return slices.Contains(g, file) })
+}
+
+// explainDir returns the directory explain scans for two files: the one they
+// are in, or the deepest one holding both, which has to be scanned recursively.
+func explainDir(file1, file2 string) (dir string, recursive bool, err error) {
+	for _, file := range []string{file1, file2} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return "", false, err
+		}
+		if info.IsDir() {
+			return "", false, fmt.Errorf("%s is a directory", file)
+		}
+	}
+	if file1 == file2 {
+		return "", false, errors.New("explain needs two different files")
+	}
+	dir1, dir2 := filepath.Dir(file1), filepath.Dir(file2)
+	if dir1 == dir2 {
+		return dir1, false, nil
+	}
+	return commonParent(dir1, dir2), true, nil
+}
+
+// renderExplanation renders the explanation E opens in the TUI, with lines cut
+// to width.
+func renderExplanation(lines []string, width int) string {
+	var s strings.Builder
+	s.WriteString(titleStyle.Render("Why these files are grouped"))
+	s.WriteString("\n\n")
+	for _, line := range lines {
+		s.WriteString(fitWidth(line, width))
+		s.WriteString("\n")
+	}
+	s.WriteString("\n")
+	s.WriteString(helpStyle.Render("Press any key to close"))
+	return s.String()
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// TestExplainPair tests the steps given for pairs that are and aren't grouped.
+func TestExplainPair(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	notes := createFileWithContent(t, tmpDir, "notes.md", "one\n")
+	notes2 := createFileWithContent(t, tmpDir, "notes 2.md", "two\n")
+	no := createFileWithContent(t, tmpDir, "no.md", "three\n")
+	hidden := createFileWithContent(t, tmpDir, ".notes.md", "four\n")
+	dated := createFileWithContent(t, tmpDir, "report-2024-01-30.txt", "five\n")
+	versioned := createFileWithContent(t, tmpDir, "report-v2.txt", "six\n")
+	files := []string{notes, notes2, no, dated, versioned}
+
+	opts := options{minPrefix: defaultMinPrefixLength}
+	suffixOpts := opts
+	suffixOpts.suffixPattern = regexp.MustCompile(`-v\d+$`)
+	datedOpts := suffixOpts
+	datedOpts.suffixPattern = regexp.MustCompile(`-\d+$`)
+	ratioOpts := opts
+	ratioOpts.matchOpts.PrefixRatio = 0.8
+	splitOpts := opts
+	splitOpts.matchOpts.SplitSimilarity = 0.9
+	divergenceOpts := opts
+	divergenceOpts.maxDivergence = 0.8
+
+	tests := []struct {
+		name         string
+		opts         options
+		file1, file2 string
+		grouped      bool
+		want         string
+	}{
+		{"shared prefix", opts, notes, notes2, true, `the common prefix "notes" is 5 bytes, at least --min-prefix 3`},
+		{"short prefix", opts, no, notes, false, `the common prefix "no" is 2 bytes, shorter than --min-prefix 3`},
+		{"prefix ratio", ratioOpts, notes, notes2, false, "it covers 62% of the shorter name, less than --prefix-ratio 0.8"},
+		{"split groups", splitOpts, notes, notes2, false, "the names are 80% alike, less than --split-groups 0.9, so their group is split between them"},
+		{"max divergence", divergenceOpts, notes, notes2, true, "their contents are 0% alike, further apart than --max-divergence 0.8, so their group is left out unless two of its files are closer"},
+		{"hidden", opts, hidden, notes, false, ".notes.md is skipped: hidden files are left out without --hidden"},
+		{"date suffix", datedOpts, dated, notes, false, "report-2024-01-30.txt ends in a date, which is not taken for a version without --dates-as-versions"},
+		{"version suffix", suffixOpts, versioned, notes, false, `report-v2.txt is a version of "report"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := explainPair(tt.opts, tt.file1, tt.file2, files)
+			if e.grouped != tt.grouped {
+				t.Errorf("grouped = %v, expected %v", e.grouped, tt.grouped)
+			}
+			if text := strings.Join(e.lines, "\n"); !strings.Contains(text, tt.want) {
+				t.Errorf("explanation should contain %q:\n%s", tt.want, text)
+			}
+		})
+	}
+}
+
+// TestExplainPair_ByContent tests that content grouping ignores names.
+func TestExplainPair_ByContent(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	a := createFileWithContent(t, tmpDir, "IMG_0001.jpg", "photo")
+	b := createFileWithContent(t, tmpDir, "holiday.jpg", "photo")
+
+	e := explainPair(options{minPrefix: defaultMinPrefixLength, byContent: true}, a, b, nil)
+	if !e.grouped || !strings.Contains(strings.Join(e.lines, "\n"), "the contents are identical") {
+		t.Errorf("identical files should be grouped by content:\n%s", strings.Join(e.lines, "\n"))
+	}
+}
+
+// TestRunExplain tests the result line against the groups of an actual scan.
+func TestRunExplain(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	notes := createFileWithContent(t, tmpDir, "notes.md", "one\n")
+	notes2 := createFileWithContent(t, tmpDir, "notes 2.md", "two\n")
+	other := createFileWithContent(t, tmpDir, "todo.md", "three\n")
+
+	opts := options{dir: tmpDir, minPrefix: defaultMinPrefixLength, scanWorkers: 1}
+	var out bytes.Buffer
+	if err := runExplain(t.Context(), opts, notes, notes2, &out); err != nil {
+		t.Fatalf("runExplain() returned error: %v", err)
+	}
+	if !strings.Contains(out.String(), "result:  grouped together, in group 1 of 1") {
+		t.Errorf("unexpected output:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := runExplain(t.Context(), opts, notes, other, &out); err != nil {
+		t.Fatalf("runExplain() returned error: %v", err)
+	}
+	if !strings.Contains(out.String(), "result:  not grouped together") {
+		t.Errorf("unexpected output:\n%s", out.String())
+	}
+}
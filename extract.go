@@ -0,0 +1,247 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// maxDocumentXML caps how much XML is read from a document, so a malformed or
+// malicious archive can't exhaust memory.
+const maxDocumentXML = 64 << 20
+
+// pdfTextTool is the external program used to extract the text of PDF files.
+// It comes with poppler-utils (Linux) and poppler (Homebrew).
+const pdfTextTool = "pdftotext"
+
+// textExtractors maps the lower-case extensions of documents whose text can be
+// compared to the function that extracts it.
+var textExtractors = map[string]func(ctx context.Context, path string) (string, error){
+	".docx": func(_ context.Context, path string) (string, error) {
+		return extractZippedXML(path, "word/document.xml", docxText)
+	},
+	".odt": func(_ context.Context, path string) (string, error) {
+		return extractZippedXML(path, "content.xml", odtText)
+	},
+	".pdf": extractPDF,
+}
+
+// isDocument reports whether the text of path can be extracted for diffing.
+func isDocument(path string) bool {
+	_, ok := textExtractors[strings.ToLower(filepath.Ext(path))]
+	return ok
+}
+
+// extractText returns the plain text of a document, one paragraph per line.
+func extractText(ctx context.Context, path string) (string, error) {
+	extract, ok := textExtractors[strings.ToLower(filepath.Ext(path))]
+	if !ok {
+		return "", fmt.Errorf("%s is not a document doppel can read", filepath.Base(path))
+	}
+	text, err := extract(ctx, path)
+	if err != nil {
+		return "", fmt.Errorf("could not extract the text of %s: %w", filepath.Base(path), err)
+	}
+	return text, nil
+}
+
+// extractZippedXML reads the XML file name from the zip archive at path, the
+// layout of Office Open XML and OpenDocument files, and converts it with toText.
+func extractZippedXML(path, name string, toText func(*xml.Decoder) (string, error)) (string, error) {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return "", err
+	}
+	defer archive.Close()
+
+	f, err := archive.Open(name)
+	if err != nil {
+		return "", fmt.Errorf("no %s in the archive", name)
+	}
+	defer f.Close()
+	return toText(xml.NewDecoder(io.LimitReader(f, maxDocumentXML)))
+}
+
+// docxText collects the text runs of a WordprocessingML body, ending each
+// paragraph with a newline.
+func docxText(d *xml.Decoder) (string, error) {
+	var s strings.Builder
+	inText := false
+	for {
+		token, err := d.Token()
+		if err == io.EOF {
+			return s.String(), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				s.WriteByte('\t')
+			case "br", "cr":
+				s.WriteByte('\n')
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				s.WriteByte('\n')
+			}
+		case xml.CharData:
+			if inText {
+				s.Write(t)
+			}
+		}
+	}
+}
+
+// odtText collects the text of the paragraphs and headings of an OpenDocument
+// body, ending each with a newline.
+func odtText(d *xml.Decoder) (string, error) {
+	var s strings.Builder
+	depth := 0 // nesting of text:p and text:h elements
+	for {
+		token, err := d.Token()
+		if err == io.EOF {
+			return s.String(), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "p", "h":
+				depth++
+			case "tab":
+				s.WriteByte('\t')
+			case "line-break":
+				s.WriteByte('\n')
+			case "s":
+				// Runs of spaces are stored as <text:s text:c="n"/>
+				count := 1
+				for _, attr := range t.Attr {
+					if attr.Name.Local == "c" {
+						if n, err := strconv.Atoi(attr.Value); err == nil && n > 0 {
+							count = n
+						}
+					}
+				}
+				s.WriteString(strings.Repeat(" ", count))
+			}
+		case xml.EndElement:
+			if t.Name.Local == "p" || t.Name.Local == "h" {
+				depth--
+				if depth == 0 {
+					s.WriteByte('\n')
+				}
+			}
+		case xml.CharData:
+			if depth > 0 {
+				s.Write(t)
+			}
+		}
+	}
+}
+
+// extractPDF runs pdfTextTool, keeping the layout of the page so columns and
+// tables stay on their lines.
+func extractPDF(ctx context.Context, path string) (string, error) {
+	tool, err := exec.LookPath(pdfTextTool)
+	if err != nil {
+		return "", fmt.Errorf("install %s (poppler) to compare the text of PDF files", pdfTextTool)
+	}
+	out, err := logCommand(exec.CommandContext(ctx, tool, "-layout", "-enc", "UTF-8", path, "-")).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("%s: %s", pdfTextTool, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", err
+	}
+	// pdftotext separates pages with form feeds
+	return strings.ReplaceAll(string(out), "\f", "\n"), nil
+}
+
+// textPair returns paths holding the UTF-8 text of file1 and file2, so the
+// diff command compares what a reader sees: for a document, its extracted
+// text, and for text in another encoding, such as UTF-16 or Latin-1, a UTF-8
+// copy, each in a temporary file named like the original. UTF-8 text and
+// binary files are used as they are. cleanup removes the temporary files.
+func textPair(ctx context.Context, file1, file2 string) (text1, text2 string, cleanup func(), err error) {
+	return writeTextPair(file1, file2, func(path string) (string, string, bool, error) {
+		return fileText(ctx, path)
+	})
+}
+
+// writeTextPair returns paths holding the text textOf gives for file1 and
+// file2, written to temporary files with the names it gives, or the file
+// itself where it returns ok false. cleanup removes the temporary files.
+func writeTextPair(file1, file2 string, textOf func(path string) (text, name string, ok bool, err error)) (text1, text2 string, cleanup func(), err error) {
+	var dir string
+	cleanup = func() {
+		if dir != "" {
+			os.RemoveAll(dir)
+		}
+	}
+
+	paths := []string{file1, file2}
+	for i, file := range paths {
+		text, name, ok, err := textOf(file)
+		if err != nil {
+			cleanup()
+			return "", "", nil, err
+		}
+		if !ok {
+			continue
+		}
+		if dir == "" {
+			if dir, err = os.MkdirTemp("", "doppel-text-"); err != nil {
+				return "", "", nil, err
+			}
+		}
+		// Each file gets its own directory, as both may have the same name
+		paths[i] = filepath.Join(dir, strconv.Itoa(i+1), name)
+		if err := os.MkdirAll(filepath.Dir(paths[i]), 0700); err != nil {
+			cleanup()
+			return "", "", nil, err
+		}
+		if err := os.WriteFile(paths[i], []byte(text), 0600); err != nil {
+			cleanup()
+			return "", "", nil, err
+		}
+	}
+	return paths[0], paths[1], cleanup, nil
+}
+
+// fileText returns the text of a document or of a text file not in UTF-8,
+// with the name of the file to write it to. ok is false for files that can be
+// compared as they are.
+func fileText(ctx context.Context, path string) (text, name string, ok bool, err error) {
+	if isDocument(path) {
+		text, err := extractText(ctx, path)
+		return text, filepath.Base(path) + ".txt", err == nil, err
+	}
+	enc, ok, err := fileEncoding(path)
+	if err != nil || !ok || enc == encodingUTF8 {
+		return "", "", false, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", false, err
+	}
+	return decodeText(data, enc), filepath.Base(path), true, nil
+}
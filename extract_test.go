@@ -0,0 +1,154 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// createZippedXML writes a zip archive at dir/name holding one XML file, the
+// shape of .docx and .odt documents.
+func createZippedXML(t *testing.T, dir, name, entry, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w := zip.NewWriter(f)
+	entryWriter, err := w.Create(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := entryWriter.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// docxXML wraps paragraphs in a minimal WordprocessingML document.
+func docxXML(paragraphs ...string) string {
+	var s strings.Builder
+	s.WriteString(`<?xml version="1.0"?><w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>`)
+	for _, p := range paragraphs {
+		s.WriteString(`<w:p><w:r><w:t>` + p + `</w:t></w:r></w:p>`)
+	}
+	s.WriteString(`</w:body></w:document>`)
+	return s.String()
+}
+
+// TestExtractText_Docx tests extracting paragraphs, tabs, and breaks from a .docx file.
+func TestExtractText_Docx(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	content := `<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>` +
+		`<w:p><w:r><w:t>Shopping</w:t></w:r><w:r><w:t xml:space="preserve"> list</w:t></w:r></w:p>` +
+		`<w:p><w:r><w:t>milk</w:t><w:tab/><w:t>2</w:t><w:br/><w:t>bread</w:t></w:r></w:p>` +
+		`</w:body></w:document>`
+	path := createZippedXML(t, tmpDir, "notes.docx", "word/document.xml", content)
+
+	text, err := extractText(context.Background(), path)
+	if err != nil {
+		t.Fatalf("extractText() returned error: %v", err)
+	}
+	if want := "Shopping list\nmilk\t2\nbread\n"; text != want {
+		t.Errorf("extractText() = %q, expected %q", text, want)
+	}
+}
+
+// TestExtractText_ODT tests extracting paragraphs, headings, and runs of spaces
+// from an .odt file.
+func TestExtractText_ODT(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	content := `<office:document-content xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0">` +
+		`<office:body><office:text><text:h>Notes</text:h>` +
+		`<text:p>a<text:s text:c="3"/>b<text:span> c</text:span></text:p>` +
+		`</office:text></office:body></office:document-content>`
+	path := createZippedXML(t, tmpDir, "notes.odt", "content.xml", content)
+
+	text, err := extractText(context.Background(), path)
+	if err != nil {
+		t.Fatalf("extractText() returned error: %v", err)
+	}
+	if want := "Notes\na   b c\n"; text != want {
+		t.Errorf("extractText() = %q, expected %q", text, want)
+	}
+}
+
+// TestExtractText_Invalid tests that a document that is not a zip archive is an error.
+func TestExtractText_Invalid(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	path := createFileWithContent(t, tmpDir, "broken.docx", "not a zip file")
+	if _, err := extractText(context.Background(), path); err == nil {
+		t.Error("extractText() should return error for a file that is not a document")
+	}
+}
+
+// TestComparePair_Documents tests that two .docx files are diffed by their text.
+func TestComparePair_Documents(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	file1 := createZippedXML(t, tmpDir, "report.docx", "word/document.xml", docxXML("Summary", "Sales rose"))
+	file2 := createZippedXML(t, tmpDir, "report-1.docx", "word/document.xml", docxXML("Summary", "Sales fell"))
+
+	output, err := NewDiffExecutor("").ComparePair(file1, file2, true)
+	if err != nil {
+		t.Fatalf("ComparePair() returned error: %v", err)
+	}
+	if !strings.Contains(output, "-Sales rose") || !strings.Contains(output, "+Sales fell") {
+		t.Errorf("ComparePair() = %q, expected a diff of the paragraphs", output)
+	}
+
+	stats, ok := computeDiffStats(NewDiffExecutor(""), file1, file2)
+	if !ok || stats.added != 1 || stats.removed != 1 {
+		t.Errorf("computeDiffStats() = %+v, %v, expected one changed line", stats, ok)
+	}
+}
+
+// TestComparePair_PDF tests diffing PDF files with pdftotext, and the fallback
+// to a byte comparison when it is not installed.
+func TestComparePair_PDF(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	file1 := createFileWithContent(t, tmpDir, "scan.pdf", "%PDF-1.4 first\x00")
+	file2 := createFileWithContent(t, tmpDir, "scan-1.pdf", "%PDF-1.4 second\x00")
+
+	t.Setenv("PATH", t.TempDir())
+	output, err := NewDiffExecutor("").ComparePair(file1, file2, true)
+	if err != nil {
+		t.Fatalf("ComparePair() returned error: %v", err)
+	}
+	if !strings.Contains(output, "install pdftotext") {
+		t.Errorf("ComparePair() = %q, expected a note that pdftotext is missing", output)
+	}
+
+	// A stand-in for pdftotext, called as "-layout -enc UTF-8 file -", that
+	// prints the text after the PDF header
+	bin := t.TempDir()
+	script := "#!/bin/sh\ncut -c10- \"$4\" | tr -d '\\000'\n"
+	if err := os.WriteFile(filepath.Join(bin, pdfTextTool), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+"/usr/bin:/bin")
+	output, err = NewDiffExecutor("").ComparePair(file1, file2, true)
+	if err != nil {
+		t.Fatalf("ComparePair() returned error: %v", err)
+	}
+	if !strings.Contains(output, "-first") || !strings.Contains(output, "+second") {
+		t.Errorf("ComparePair() = %q, expected a diff of the extracted text", output)
+	}
+}
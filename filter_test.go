@@ -1,6 +1,7 @@
 package main
 
 import (
+	"reflect"
 	"regexp"
 	"testing"
 )
@@ -47,9 +48,9 @@ func TestFilterFilesBySuffix_HyphenDigits(t *testing.T) {
 
 	// Should include: document-1.txt, document-2.txt, document.txt (base file)
 	expectedFiles := map[string]bool{
-		"/path/to/document.txt":     true,
+		"/path/to/document.txt":   true,
 		"/path/to/document-1.txt": true,
-		"/path/to/document-2.txt":  true,
+		"/path/to/document-2.txt": true,
 	}
 
 	if len(result) != len(expectedFiles) {
@@ -182,10 +183,10 @@ func TestFilterFilesBySuffix_DifferentPrefixes(t *testing.T) {
 
 	// Should include all files (both document and report groups)
 	expectedFiles := map[string]bool{
-		"/path/to/document.txt": true,
+		"/path/to/document.txt":   true,
 		"/path/to/document-1.txt": true,
-		"/path/to/report.txt": true,
-		"/path/to/report-1.txt": true,
+		"/path/to/report.txt":     true,
+		"/path/to/report-1.txt":   true,
 	}
 
 	if len(result) != len(expectedFiles) {
@@ -218,7 +219,7 @@ func TestFilterFilesBySuffix_DateExclusion(t *testing.T) {
 	// Should include: report.txt, report-1.txt
 	// Should exclude: report-2024.txt
 	expectedFiles := map[string]bool{
-		"/path/to/report.txt": true,
+		"/path/to/report.txt":   true,
 		"/path/to/report-1.txt": true,
 	}
 
@@ -255,7 +256,7 @@ func TestFilterFilesBySuffix_NoExtension(t *testing.T) {
 
 	// Should include: file, file-1, file-2
 	expectedFiles := map[string]bool{
-		"/path/to/file": true,
+		"/path/to/file":   true,
 		"/path/to/file-1": true,
 		"/path/to/file-2": true,
 	}
@@ -289,7 +290,7 @@ func TestFilterFilesBySuffix_MultipleDots(t *testing.T) {
 
 	// Should include: file.backup.txt, file.backup-1.txt, file.backup-2.txt
 	expectedFiles := map[string]bool{
-		"/path/to/file.backup.txt": true,
+		"/path/to/file.backup.txt":   true,
 		"/path/to/file.backup-1.txt": true,
 		"/path/to/file.backup-2.txt": true,
 	}
@@ -482,3 +483,33 @@ func TestIsLikelyDatePattern(t *testing.T) {
 		})
 	}
 }
+
+// TestFilterFilesBySuffix_DatesAsVersions tests that date-suffixed names are kept
+// as versions of the name before the date when requested.
+func TestFilterFilesBySuffix_DatesAsVersions(t *testing.T) {
+	files := []string{
+		"/notes/journal.md",
+		"/notes/journal-1.md",
+		"/notes/journal-2024-01-30.md",
+		"/notes/journal-2024-02-02.md",
+		"/notes/todo.md",
+		"/notes/unrelated.md",
+	}
+	pattern := regexp.MustCompile("-\\d{1,2}$")
+
+	excluded := filterFilesBySuffix(files, pattern)
+	if len(excluded) != 2 {
+		t.Errorf("filterFilesBySuffix() = %v, expected only journal.md and journal-1.md", excluded)
+	}
+
+	result := filterFilesBySuffixWithDates(files, pattern, true)
+	expected := []string{
+		"/notes/journal-1.md",
+		"/notes/journal-2024-01-30.md",
+		"/notes/journal-2024-02-02.md",
+		"/notes/journal.md",
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("filterFilesBySuffixWithDates() = %v, expected %v", result, expected)
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// markdownExts are the extensions of notes whose frontmatter --frontmatter
+// compares apart from the body.
+var markdownExts = map[string]bool{".md": true, ".markdown": true}
+
+// isMarkdown reports whether path is a Markdown note.
+func isMarkdown(path string) bool {
+	return markdownExts[strings.ToLower(filepath.Ext(path))]
+}
+
+// frontmatterField is a top-level key of YAML frontmatter and its value as
+// written, including the indented lines or list items that follow the key.
+type frontmatterField struct {
+	key, value string
+}
+
+// splitFrontmatter separates the YAML frontmatter of a note, the block between
+// a "---" first line and the next "---" or "..." line that Obsidian, Jekyll,
+// and Hugo read, from the body after it. ok is false if the note has none.
+// Only the top-level keys are parsed; nested values are compared as text.
+func splitFrontmatter(text string) (fields []frontmatterField, body string, ok bool) {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	rest, found := strings.CutPrefix(text, "---\n")
+	if !found {
+		return nil, text, false
+	}
+	for len(rest) > 0 {
+		line, after, _ := strings.Cut(rest, "\n")
+		rest = after
+		switch {
+		case line == "---" || line == "...":
+			return fields, rest, true
+		case line == "" || strings.HasPrefix(line, "#"):
+		case line[0] == ' ' || line[0] == '\t' || line[0] == '-':
+			if len(fields) > 0 {
+				last := &fields[len(fields)-1]
+				last.value = strings.TrimPrefix(last.value+"\n"+line, "\n")
+			}
+		default:
+			key, value, _ := strings.Cut(line, ":")
+			fields = append(fields, frontmatterField{key: strings.TrimSpace(key), value: strings.TrimSpace(value)})
+		}
+	}
+	// Without a closing line, the "---" was a rule in the body
+	return nil, text, false
+}
+
+// frontmatterChange is a frontmatter key whose value differs between two
+// notes. A key missing from a note has ok1 or ok2 unset.
+type frontmatterChange struct {
+	key            string
+	value1, value2 string
+	ok1, ok2       bool
+}
+
+// frontmatterComparison compares two notes' frontmatter and body separately.
+type frontmatterComparison struct {
+	changes      []frontmatterChange
+	body1, body2 string
+}
+
+// compareFrontmatter compares the frontmatter of two Markdown notes key by
+// key and their bodies as text. ok is false unless both files are Markdown
+// and at least one of them has frontmatter.
+func compareFrontmatter(file1, file2 string) (c frontmatterComparison, ok bool) {
+	if !isMarkdown(file1) || !isMarkdown(file2) {
+		return c, false
+	}
+	var fields [2][]frontmatterField
+	var found [2]bool
+	for i, file := range []string{file1, file2} {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return c, false
+		}
+		text, _ := decodeFile(data)
+		var body string
+		fields[i], body, found[i] = splitFrontmatter(text)
+		if i == 0 {
+			c.body1 = body
+		} else {
+			c.body2 = body
+		}
+	}
+	if !found[0] && !found[1] {
+		return c, false
+	}
+
+	values2 := make(map[string]string, len(fields[1]))
+	for _, field := range fields[1] {
+		values2[field.key] = field.value
+	}
+	seen := make(map[string]bool)
+	for _, field := range fields[0] {
+		if seen[field.key] {
+			continue
+		}
+		seen[field.key] = true
+		value2, ok2 := values2[field.key]
+		if !ok2 || value2 != field.value {
+			c.changes = append(c.changes, frontmatterChange{key: field.key, value1: field.value, value2: value2, ok1: true, ok2: ok2})
+		}
+	}
+	for _, field := range fields[1] {
+		if !seen[field.key] {
+			seen[field.key] = true
+			c.changes = append(c.changes, frontmatterChange{key: field.key, value2: field.value, ok2: true})
+		}
+	}
+	return c, true
+}
+
+// bodyEqual reports whether the notes have the same body.
+func (c frontmatterComparison) bodyEqual() bool {
+	return c.body1 == c.body2
+}
+
+// onlyFrontmatter reports whether the notes differ in their frontmatter alone,
+// such as a modified date an app updated on both sides of a sync conflict.
+func (c frontmatterComparison) onlyFrontmatter() bool {
+	return len(c.changes) > 0 && c.bodyEqual()
+}
+
+// describe summarizes the comparison for the line above a diff, such as
+// "only frontmatter differs (modified)". It is empty when the frontmatter is
+// the same.
+func (c frontmatterComparison) describe() string {
+	if len(c.changes) == 0 {
+		return ""
+	}
+	keys := make([]string, len(c.changes))
+	for i, change := range c.changes {
+		keys[i] = change.key
+	}
+	if c.bodyEqual() {
+		return fmt.Sprintf("only frontmatter differs (%s)", strings.Join(keys, ", "))
+	}
+	return fmt.Sprintf("frontmatter differs (%s)", strings.Join(keys, ", "))
+}
+
+// format lists the frontmatter changes, one key per line: "key: old → new",
+// or marked with + or - for keys only in File 2 or File 1. Values spanning
+// several lines are joined into one.
+func (c frontmatterComparison) format() string {
+	if len(c.changes) == 0 {
+		return "Frontmatter: identical\n"
+	}
+	flatten := func(value string) string {
+		return strings.Join(strings.Fields(value), " ")
+	}
+	var s strings.Builder
+	s.WriteString("Frontmatter:\n")
+	for _, change := range c.changes {
+		switch {
+		case !change.ok1:
+			fmt.Fprintf(&s, "  + %s: %s\n", change.key, flatten(change.value2))
+		case !change.ok2:
+			fmt.Fprintf(&s, "  - %s: %s\n", change.key, flatten(change.value1))
+		default:
+			fmt.Fprintf(&s, "  %s: %s → %s\n", change.key, flatten(change.value1), flatten(change.value2))
+		}
+	}
+	return s.String()
+}
+
+// frontmatterDiff renders a comparison as the diff view shows it: the
+// frontmatter changes, then a diff of the bodies alone.
+func (d *DiffExecutor) frontmatterDiff(c frontmatterComparison, file1, file2 string, unified bool) (string, error) {
+	if c.bodyEqual() {
+		return c.format() + "\nBody: identical\n", nil
+	}
+	bodies := map[string]string{file1: c.body1, file2: c.body2}
+	body1, body2, cleanup, err := writeTextPair(file1, file2, func(path string) (string, string, bool, error) {
+		return bodies[path], filepath.Base(path), true, nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to compare files: %w", err)
+	}
+	defer cleanup()
+
+	var output string
+	if unified {
+		output, err = d.DiffUnified(body1, body2)
+	} else {
+		output, err = d.DiffSideBySide(body1, body2)
+	}
+	if err != nil {
+		return "", err
+	}
+	return c.format() + "\nBody:\n" + output, nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestSplitFrontmatter tests separating frontmatter fields from the body of a note.
+func TestSplitFrontmatter(t *testing.T) {
+	text := "---\r\ntitle: Groceries\r\ntags:\r\n  - home\r\n  - todo\r\n# a comment\r\nmodified: 2024-01-30\r\n---\r\nmilk\r\n"
+	fields, body, ok := splitFrontmatter(text)
+	if !ok {
+		t.Fatal("splitFrontmatter() found no frontmatter")
+	}
+	want := []frontmatterField{
+		{key: "title", value: "Groceries"},
+		{key: "tags", value: "  - home\n  - todo"},
+		{key: "modified", value: "2024-01-30"},
+	}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("fields = %q, expected %q", fields, want)
+	}
+	if body != "milk\n" {
+		t.Errorf("body = %q, expected %q", body, "milk\n")
+	}
+
+	for _, text := range []string{"# Title\n---\nmilk\n", "---\nno closing line\n"} {
+		if _, body, ok := splitFrontmatter(text); ok || body != text {
+			t.Errorf("splitFrontmatter(%q) = %q, %v; expected the whole text as body", text, body, ok)
+		}
+	}
+}
+
+// TestCompareFrontmatter tests listing changed, added, and removed keys.
+func TestCompareFrontmatter(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	file1 := createFileWithContent(t, tmpDir, "note.md", "---\ntitle: Plan\nmodified: 2024-01-30\ndraft: true\n---\nBody\n")
+	file2 := createFileWithContent(t, tmpDir, "note-1.md", "---\ntitle: Plan\nmodified: 2024-02-02\naliases: [plan]\n---\nBody\n")
+
+	c, ok := compareFrontmatter(file1, file2)
+	if !ok {
+		t.Fatal("compareFrontmatter() should compare two notes with frontmatter")
+	}
+	if !c.onlyFrontmatter() {
+		t.Error("onlyFrontmatter() = false for notes with the same body")
+	}
+	if got, want := c.describe(), "only frontmatter differs (modified, draft, aliases)"; got != want {
+		t.Errorf("describe() = %q, expected %q", got, want)
+	}
+	want := "Frontmatter:\n  modified: 2024-01-30 → 2024-02-02\n  - draft: true\n  + aliases: [plan]\n"
+	if got := c.format(); got != want {
+		t.Errorf("format() = %q, expected %q", got, want)
+	}
+
+	plain := createFileWithContent(t, tmpDir, "plain.md", "Body\n")
+	text := createFileWithContent(t, tmpDir, "note.txt", "---\ntitle: Plan\n---\nBody\n")
+	plainCopy := createFileWithContent(t, tmpDir, "plain-1.md", "Body\n")
+	if _, ok := compareFrontmatter(file1, text); ok {
+		t.Error("compareFrontmatter() should only compare Markdown notes")
+	}
+	if _, ok := compareFrontmatter(plain, plainCopy); ok {
+		t.Error("compareFrontmatter() should need frontmatter in one of the notes")
+	}
+	// Frontmatter added to a note, as apps do when it is opened, is all that differs
+	if c, ok := compareFrontmatter(plain, file1); !ok || !c.onlyFrontmatter() || len(c.changes) != 3 {
+		t.Errorf("compareFrontmatter() with one note lacking frontmatter = %+v, %v", c, ok)
+	}
+}
+
+// TestComparePair_Frontmatter tests that the diff lists frontmatter changes and
+// diffs the bodies alone.
+func TestComparePair_Frontmatter(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	file1 := createFileWithContent(t, tmpDir, "note.md", "---\nmodified: 2024-01-30\n---\nmilk\neggs\n")
+	file2 := createFileWithContent(t, tmpDir, "note-1.md", "---\nmodified: 2024-02-02\n---\nmilk\nbread\n")
+	same := createFileWithContent(t, tmpDir, "note-2.md", "---\nmodified: 2024-03-01\n---\nmilk\neggs\n")
+
+	exec := NewDiffExecutor("")
+	exec.SetFrontmatter(true)
+	output, err := exec.ComparePair(file1, file2, true)
+	if err != nil {
+		t.Fatalf("ComparePair() returned error: %v", err)
+	}
+	for _, want := range []string{"modified: 2024-01-30 → 2024-02-02", "-eggs", "+bread"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("ComparePair() output missing %q:\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "-modified") {
+		t.Errorf("ComparePair() should not diff the frontmatter as lines:\n%s", output)
+	}
+
+	output, err = exec.ComparePair(file1, same, false)
+	if err != nil {
+		t.Fatalf("ComparePair() returned error: %v", err)
+	}
+	if !strings.Contains(output, "Body: identical") {
+		t.Errorf("ComparePair() = %q, expected the bodies to be reported identical", output)
+	}
+	summary := diffSummary(exec, file1, same)
+	if !strings.HasPrefix(summary, "only frontmatter differs (modified), ") {
+		t.Errorf("diffSummary() = %q, expected it to start with the frontmatter note", summary)
+	}
+
+	// Without the mode, notes are diffed as they are
+	output, err = NewDiffExecutor("").ComparePair(file1, same, true)
+	if err != nil {
+		t.Fatalf("ComparePair() returned error: %v", err)
+	}
+	if !strings.Contains(output, "-modified: 2024-01-30") {
+		t.Errorf("ComparePair() without frontmatter mode = %q, expected a plain diff", output)
+	}
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// GitRepo is the git repository a scanned directory is in. It skips files git
+// ignores, tells the status of files, and checks out committed versions of
+// files to compare them with. A nil *GitRepo is no repository: nothing is
+// ignored and no file has a status.
+type GitRepo struct {
+	root string
+	// status holds the porcelain status code of every changed or untracked
+	// file by its path relative to root; it is loaded on first use.
+	status map[string]string
+	// tempDir holds the committed versions checked out for comparing.
+	tempDir string
+}
+
+// FindGitRepo returns the repository dir is in, or nil if it isn't in one. It
+// fails if git isn't installed.
+func FindGitRepo(dir string) (*GitRepo, error) {
+	out, err := logCommand(exec.Command("git", "-C", dir, "rev-parse", "--show-toplevel")).Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &GitRepo{root: strings.TrimSpace(string(out))}, nil
+}
+
+// git returns a command running git with args in the root of the repository.
+func (r *GitRepo) git(ctx context.Context, args ...string) *exec.Cmd {
+	return logCommand(exec.CommandContext(ctx, "git", append([]string{"-C", r.root}, args...)...))
+}
+
+// rel returns the path of file relative to the root of the repository as git
+// writes it, with forward slashes, and false if file is outside it.
+func (r *GitRepo) rel(file string) (string, bool) {
+	path := absPath(file)
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	rel, err := filepath.Rel(r.root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// FilterIgnored returns files without those git ignores. Tracked files are
+// kept even if they match an ignore pattern, as git keeps tracking them.
+func (r *GitRepo) FilterIgnored(ctx context.Context, files []string) ([]string, error) {
+	if r == nil || len(files) == 0 {
+		return files, nil
+	}
+	var input bytes.Buffer
+	for _, file := range files {
+		if rel, ok := r.rel(file); ok {
+			input.WriteString(rel)
+			input.WriteByte(0)
+		}
+	}
+	cmd := r.git(ctx, "check-ignore", "-z", "--stdin")
+	cmd.Stdin = &input
+	out, err := cmd.Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		// No file is ignored
+		return files, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("git check-ignore: %w", err)
+	}
+
+	ignored := make(map[string]bool)
+	for _, rel := range strings.Split(string(out), "\x00") {
+		ignored[rel] = true
+	}
+	kept := files[:0:0]
+	for _, file := range files {
+		if rel, ok := r.rel(file); ok && ignored[rel] {
+			logger.Debug("skipped file", "path", file, "reason", "ignored by git")
+			continue
+		}
+		kept = append(kept, file)
+	}
+	return kept, nil
+}
+
+// Status returns the git status of file, such as "modified" or "untracked",
+// or "" if it is committed unchanged, ignored, or outside the repository.
+func (r *GitRepo) Status(file string) string {
+	if r == nil {
+		return ""
+	}
+	if r.status == nil {
+		r.status = make(map[string]string)
+		out, err := r.git(context.Background(), "status", "--porcelain=v1", "-z", "--untracked-files=all").Output()
+		if err != nil {
+			logger.Warn("failed to read git status", "repo", r.root, "err", err)
+		}
+		entries := strings.Split(string(out), "\x00")
+		for i := 0; i < len(entries); i++ {
+			entry := entries[i]
+			if len(entry) < 4 {
+				continue
+			}
+			code := entry[:2]
+			r.status[entry[3:]] = code
+			if code[0] == 'R' || code[0] == 'C' {
+				// The path it was renamed or copied from follows
+				i++
+			}
+		}
+	}
+	rel, ok := r.rel(file)
+	if !ok {
+		return ""
+	}
+	return describeGitStatus(r.status[rel])
+}
+
+// Forget drops the status read so far, after files may have changed.
+func (r *GitRepo) Forget() {
+	if r != nil {
+		r.status = nil
+	}
+}
+
+// describeGitStatus names a porcelain status code such as " M" or "??".
+func describeGitStatus(code string) string {
+	switch {
+	case code == "":
+		return ""
+	case code == "??":
+		return "untracked"
+	case strings.ContainsRune(code, 'U') || code == "AA" || code == "DD":
+		return "conflicted"
+	case code[0] == 'A':
+		return "added"
+	case code[0] == 'R':
+		return "renamed"
+	case strings.ContainsRune(code, 'D'):
+		return "deleted"
+	default:
+		return "modified"
+	}
+}
+
+// CommittedVersion writes the version of file at rev, such as HEAD, to a
+// temporary file and returns its path. The name keeps the extension and adds
+// the revision, so "notes.md" at HEAD becomes "notes@HEAD.md".
+func (r *GitRepo) CommittedVersion(ctx context.Context, file, rev string) (string, error) {
+	if r == nil {
+		return "", errors.New("not inside a git repository")
+	}
+	rel, ok := r.rel(file)
+	if !ok {
+		return "", fmt.Errorf("%s is outside the git repository", filepath.Base(file))
+	}
+	object := rev + ":" + rel
+	if err := r.git(ctx, "cat-file", "-e", object).Run(); err != nil {
+		return "", fmt.Errorf("%s is not in %s", filepath.Base(file), rev)
+	}
+	content, err := r.git(ctx, "show", object).Output()
+	if err != nil {
+		return "", fmt.Errorf("git show %s: %w", object, err)
+	}
+
+	if r.tempDir == "" {
+		if r.tempDir, err = os.MkdirTemp("", "doppel-git-"); err != nil {
+			return "", err
+		}
+	}
+	// Files of the same name in different directories each get a directory
+	dir, err := os.MkdirTemp(r.tempDir, "")
+	if err != nil {
+		return "", err
+	}
+	ext := filepath.Ext(file)
+	name := strings.TrimSuffix(filepath.Base(file), ext) + "@" + rev + ext
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// Cleanup removes the committed versions written for comparing.
+func (r *GitRepo) Cleanup() error {
+	if r == nil || r.tempDir == "" {
+		return nil
+	}
+	return os.RemoveAll(r.tempDir)
+}
+
+// maxRevisions is the number of recent revisions of a file offered for diffing.
+const maxRevisions = 20
+
+// GitRevision is a commit that changed a file.
+type GitRevision struct {
+	Hash    string
+	Date    string
+	Subject string
+}
+
+// Revisions returns up to limit of the latest commits that changed file, newest
+// first. A file that was never committed has none.
+func (r *GitRepo) Revisions(ctx context.Context, file string, limit int) ([]GitRevision, error) {
+	if r == nil {
+		return nil, errors.New("not inside a git repository")
+	}
+	rel, ok := r.rel(file)
+	if !ok {
+		return nil, nil
+	}
+	out, err := r.git(ctx, "log", fmt.Sprintf("-n%d", limit), "--date=short", "--format=%h%x1f%ad%x1f%s", "--", rel).Output()
+	if err != nil {
+		return nil, fmt.Errorf("git log: %w", err)
+	}
+	var revisions []GitRevision
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if fields := strings.SplitN(line, "\x1f", 3); len(fields) == 3 {
+			revisions = append(revisions, GitRevision{Hash: fields[0], Date: fields[1], Subject: fields[2]})
+		}
+	}
+	return revisions, nil
+}
+
+// revisionMenu is the list of revisions that L opens: those of source, each
+// of which can be diffed against file. source is file itself, or for a copy
+// never committed, the file of its group it was copied from.
+type revisionMenu struct {
+	file      string
+	source    string
+	revisions []GitRevision
+	cursor    int
+}
+
+// renderRevisionMenu renders the revision list for a terminal of the given
+// size, with names as shown by displayName.
+func renderRevisionMenu(menu *revisionMenu, displayName func(string) string, width, height int) string {
+	var s strings.Builder
+	title := "Revisions of " + displayName(menu.source)
+	if menu.source != menu.file {
+		title += " to compare with " + displayName(menu.file)
+	}
+	s.WriteString(titleStyle.Render(fitWidth(title, width)))
+	s.WriteString("\n\n")
+	// Leave room for the title and the hint, keeping the cursor in view
+	rows := max(height-4, 1)
+	start := min(max(menu.cursor-rows+1, 0), max(len(menu.revisions)-rows, 0))
+	end := min(start+rows, len(menu.revisions))
+	for i := start; i < end; i++ {
+		rev := menu.revisions[i]
+		prefix, style := "  ", normalStyle
+		if i == menu.cursor {
+			prefix, style = "> ", selectedStyle
+		}
+		s.WriteString(style.Render(fitWidth(fmt.Sprintf("%s%s  %s  %s", prefix, rev.Hash, rev.Date, rev.Subject), width)))
+		s.WriteString("\n")
+	}
+	s.WriteString("\n")
+	s.WriteString(helpStyle.Render(fitWidth("↑/↓: navigate  Enter: diff against this revision  Esc: close", width)))
+	return s.String()
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// gitTestRepo creates a repository in a temporary directory with notes.md and
+// a .gitignore for *.tmp committed, and returns its directory.
+func gitTestRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	createFileWithContent(t, dir, "notes.md", "committed\n")
+	createFileWithContent(t, dir, ".gitignore", "*.tmp\n")
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "notes.md", ".gitignore"},
+		{"-c", "user.name=doppel", "-c", "user.email=doppel@example.com", "commit", "-q", "-m", "notes"},
+	} {
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	return dir
+}
+
+// TestFindGitRepo tests finding the repository of a directory, and nil outside one.
+func TestFindGitRepo(t *testing.T) {
+	dir := gitTestRepo(t)
+	sub := filepath.Join(dir, "sub")
+	if err := os.Mkdir(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	repo, err := FindGitRepo(sub)
+	if err != nil || repo == nil {
+		t.Fatalf("FindGitRepo() = %v, %v; expected the repository", repo, err)
+	}
+	if _, ok := repo.rel(filepath.Join(sub, "a.md")); !ok {
+		t.Errorf("a file in a subdirectory should be inside the repository")
+	}
+
+	if repo, err := FindGitRepo(t.TempDir()); err != nil || repo != nil {
+		t.Errorf("FindGitRepo() outside a repository = %v, %v; expected nil", repo, err)
+	}
+}
+
+// TestGitRepo_FilterIgnored tests that ignored files are left out.
+func TestGitRepo_FilterIgnored(t *testing.T) {
+	dir := gitTestRepo(t)
+	notes := filepath.Join(dir, "notes.md")
+	copy := createFileWithContent(t, dir, "notes 2.md", "edited\n")
+	scratch := createFileWithContent(t, dir, "notes.tmp", "scratch\n")
+	repo, err := FindGitRepo(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kept, err := repo.FilterIgnored(context.Background(), []string{notes, copy, scratch})
+	if err != nil {
+		t.Fatalf("FilterIgnored() returned error: %v", err)
+	}
+	if want := []string{notes, copy}; !reflect.DeepEqual(kept, want) {
+		t.Errorf("FilterIgnored() = %v, expected %v", kept, want)
+	}
+
+	kept, err = repo.FilterIgnored(context.Background(), []string{notes, copy})
+	if err != nil || len(kept) != 2 {
+		t.Errorf("FilterIgnored() without ignored files = %v, %v", kept, err)
+	}
+}
+
+// TestGitRepo_Status tests the status of committed, changed, and untracked files.
+func TestGitRepo_Status(t *testing.T) {
+	dir := gitTestRepo(t)
+	notes := filepath.Join(dir, "notes.md")
+	copy := createFileWithContent(t, dir, "notes 2.md", "edited\n")
+	repo, err := FindGitRepo(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := repo.Status(notes); got != "" {
+		t.Errorf("Status() of a committed file = %q, expected none", got)
+	}
+	if got := repo.Status(copy); got != "untracked" {
+		t.Errorf("Status() of a new file = %q, expected untracked", got)
+	}
+	createFileWithContent(t, dir, "notes.md", "changed\n")
+	repo.Forget()
+	if got := repo.Status(notes); got != "modified" {
+		t.Errorf("Status() of an edited file = %q, expected modified", got)
+	}
+
+	var none *GitRepo
+	if got := none.Status(notes); got != "" {
+		t.Errorf("Status() without a repository = %q", got)
+	}
+}
+
+// TestGitRepo_CommittedVersion tests checking out the committed version of a file.
+func TestGitRepo_CommittedVersion(t *testing.T) {
+	dir := gitTestRepo(t)
+	notes := createFileWithContent(t, dir, "notes.md", "changed\n")
+	copy := createFileWithContent(t, dir, "notes 2.md", "edited\n")
+	repo, err := FindGitRepo(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Cleanup()
+
+	path, err := repo.CommittedVersion(context.Background(), notes, "HEAD")
+	if err != nil {
+		t.Fatalf("CommittedVersion() returned error: %v", err)
+	}
+	if filepath.Base(path) != "notes@HEAD.md" {
+		t.Errorf("CommittedVersion() = %s, expected a file named notes@HEAD.md", path)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != "committed\n" {
+		t.Errorf("committed version = %q, %v", data, err)
+	}
+
+	if _, err := repo.CommittedVersion(context.Background(), copy, "HEAD"); err == nil {
+		t.Errorf("CommittedVersion() of an untracked file should return an error")
+	}
+
+	if err := repo.Cleanup(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Cleanup() should remove the committed versions")
+	}
+}
+
+// TestDescribeGitStatus tests naming porcelain status codes.
+func TestDescribeGitStatus(t *testing.T) {
+	for code, want := range map[string]string{
+		"":   "",
+		"??": "untracked",
+		" M": "modified",
+		"MM": "modified",
+		"A ": "added",
+		"R ": "renamed",
+		" D": "deleted",
+		"UU": "conflicted",
+	} {
+		if got := describeGitStatus(code); got != want {
+			t.Errorf("describeGitStatus(%q) = %q, expected %q", code, got, want)
+		}
+	}
+}
+
+// TestScanFiles_SkipGitIgnored tests that --skip-git-ignored leaves ignored files out of the scan.
+func TestScanFiles_SkipGitIgnored(t *testing.T) {
+	dir := gitTestRepo(t)
+	createFileWithContent(t, dir, "notes.tmp", "scratch\n")
+	opts := options{dir: dir, minPrefix: defaultMinPrefixLength, scanWorkers: 1, skipGitIgnored: true}
+
+	files, err := scanFiles(context.Background(), opts, dir, nil)
+	if err != nil {
+		t.Fatalf("scanFiles() returned error: %v", err)
+	}
+	if want := []string{filepath.Join(dir, "notes.md")}; !reflect.DeepEqual(files, want) {
+		t.Errorf("scanFiles() = %v, expected %v", files, want)
+	}
+}
+
+// TestGitRepo_Revisions tests listing the commits that changed a file.
+func TestGitRepo_Revisions(t *testing.T) {
+	dir := gitTestRepo(t)
+	notes := createFileWithContent(t, dir, "notes.md", "second\n")
+	cmd := exec.Command("git", "-C", dir, "-c", "user.name=doppel", "-c", "user.email=doppel@example.com", "commit", "-q", "-am", "more notes")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git commit: %v\n%s", err, out)
+	}
+	repo, err := FindGitRepo(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	revisions, err := repo.Revisions(context.Background(), notes, maxRevisions)
+	if err != nil {
+		t.Fatalf("Revisions() returned error: %v", err)
+	}
+	if len(revisions) != 2 || revisions[0].Subject != "more notes" || revisions[1].Subject != "notes" {
+		t.Fatalf("Revisions() = %+v, expected both commits, newest first", revisions)
+	}
+	if revisions, err := repo.Revisions(context.Background(), notes, 1); err != nil || len(revisions) != 1 {
+		t.Errorf("Revisions() with a limit of 1 = %+v, %v", revisions, err)
+	}
+	copy := createFileWithContent(t, dir, "notes 2.md", "edited\n")
+	if revisions, err := repo.Revisions(context.Background(), copy, maxRevisions); err != nil || len(revisions) != 0 {
+		t.Errorf("Revisions() of an untracked file = %+v, %v; expected none", revisions, err)
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// fileHandler compares and previews one kind of file.
+type fileHandler struct {
+	// name describes the kind of file in previews, such as "image".
+	name string
+	// compare renders two files of this kind for the diff view. ok is false
+	// when the pair should be compared as text instead, with note saying why
+	// when that is worth telling. A nil compare always compares as text.
+	compare func(d *DiffExecutor, file1, file2 string, unified bool) (output string, ok bool, note string, err error)
+	// preview shows a file of this kind in up to maxLines lines of maxWidth
+	// columns. A nil preview describes the file by its kind and size.
+	preview func(path string, maxLines, maxWidth int) (string, error)
+}
+
+var (
+	// textHandler compares files with the diff command and previews their
+	// first lines. It handles every file no other handler claims; binary
+	// content is noticed when the file is read.
+	textHandler  = &fileHandler{name: "text", preview: filePreview}
+	imageHandler = &fileHandler{name: "image", compare: compareImages, preview: previewImage}
+	// mediaHandler isn't previewed, as reading the metadata of a media file
+	// may run ffprobe, which is too slow to do while moving through a list.
+	mediaHandler    = &fileHandler{name: "audio or video file", compare: compareMedia}
+	documentHandler = &fileHandler{name: "document", preview: previewDocument}
+	// pdfHandler compares the text of PDF files like documentHandler, but
+	// doesn't preview it, as extracting it runs pdftotext.
+	pdfHandler     = &fileHandler{name: "PDF document"}
+	archiveHandler = &fileHandler{name: "zip archive", compare: compareArchives, preview: previewArchive}
+)
+
+// handlersByExt maps lower-case extensions to the handler of their files.
+var handlersByExt = map[string]*fileHandler{}
+
+// handlersByMIME maps MIME types, or a top-level type followed by a slash, to
+// the handler of files whose extension isn't in handlersByExt, by the type
+// their content is sniffed as.
+var handlersByMIME = map[string]*fileHandler{
+	"image/":          imageHandler,
+	"audio/":          mediaHandler,
+	"video/":          mediaHandler,
+	"application/zip": archiveHandler,
+}
+
+func init() {
+	register := func(h *fileHandler, exts map[string]bool) {
+		for ext := range exts {
+			handlersByExt[ext] = h
+		}
+	}
+	register(imageHandler, imageExtensions)
+	register(mediaHandler, mediaExtensions)
+	for ext := range textExtractors {
+		handlersByExt[ext] = documentHandler
+	}
+	handlersByExt[".pdf"] = pdfHandler
+	handlersByExt[".zip"] = archiveHandler
+}
+
+// handlerFor returns the handler of the file at path, by its extension, or by
+// the MIME type of its first bytes when the extension is unknown.
+func handlerFor(path string) *fileHandler {
+	if h, ok := handlersByExt[strings.ToLower(filepath.Ext(path))]; ok {
+		return h
+	}
+	mimeType := sniffMIME(path)
+	if h, ok := handlersByMIME[mimeType]; ok {
+		return h
+	}
+	if top, _, ok := strings.Cut(mimeType, "/"); ok {
+		if h, ok := handlersByMIME[top+"/"]; ok {
+			return h
+		}
+	}
+	return textHandler
+}
+
+// sniffMIME returns the MIME type of the content at path, without
+// parameters, or "" if the file can't be read.
+func sniffMIME(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	// DetectContentType considers at most 512 bytes
+	buf := make([]byte, 512)
+	n, err := io.ReadFull(f, buf)
+	if n == 0 || (err != nil && err != io.ErrUnexpectedEOF) {
+		return ""
+	}
+	mimeType, _, err := mime.ParseMediaType(http.DetectContentType(buf[:n]))
+	if err != nil {
+		return ""
+	}
+	return mimeType
+}
+
+// pairHandler returns the handler that compares file1 and file2: theirs when
+// both are of the same kind, and textHandler otherwise.
+func pairHandler(file1, file2 string) *fileHandler {
+	h := handlerFor(file1)
+	if handlerFor(file2) != h {
+		return textHandler
+	}
+	return h
+}
+
+// previewFile shows the file at path for the preview pane the way its
+// handler does.
+func previewFile(path string, maxLines, maxWidth int) (string, error) {
+	h := handlerFor(path)
+	if h.preview == nil {
+		return describeFile(path, h.name)
+	}
+	return h.preview(path, maxLines, maxWidth)
+}
+
+// describeFile returns a one-line preview naming the kind and size of a file.
+func describeFile(path, kind string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("(%s, %s)", kind, formatBytes(info.Size())), nil
+}
+
+// compareImages compares two images by their metadata and perceptual hash.
+func compareImages(_ *DiffExecutor, file1, file2 string, _ bool) (string, bool, string, error) {
+	output, ok := imageDiff(file1, file2)
+	return output, ok, "", nil
+}
+
+// compareMedia compares two audio or video files by their metadata.
+func compareMedia(d *DiffExecutor, file1, file2 string, _ bool) (string, bool, string, error) {
+	output, ok, note := mediaDiff(d.context(), file1, file2)
+	return output, ok, note, nil
+}
+
+// compareArchives diffs the listings of two zip archives, so copies that
+// differ only in how they were compressed show no changes.
+func compareArchives(d *DiffExecutor, file1, file2 string, unified bool) (string, bool, string, error) {
+	list1, list2, cleanup, err := writeTextPair(file1, file2, func(path string) (string, string, bool, error) {
+		listing, err := archiveListing(path)
+		return listing, filepath.Base(path) + ".txt", err == nil, err
+	})
+	if err != nil {
+		return "", false, fmt.Sprintf("Comparing as binary files: %v\n\n", err), nil
+	}
+	defer cleanup()
+
+	output, err := d.diffText(list1, list2, unified)
+	if err != nil {
+		return "", false, "", err
+	}
+	if output == "" {
+		output = "Both archives hold the same files, with the same sizes and checksums.\n"
+	}
+	return "Archive contents\n\n" + output, true, "", nil
+}
+
+// previewImage describes an image by its format and dimensions, which are
+// read from its header without decoding it.
+func previewImage(path string, _, _ int) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	config, format, err := image.DecodeConfig(f)
+	if err != nil {
+		// Formats the standard library can't read, such as HEIC
+		return describeFile(path, "image")
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("(%s image, %dx%d, %s)", strings.ToUpper(format), config.Width, config.Height, formatBytes(info.Size())), nil
+}
+
+// previewDocument shows the first paragraphs of the text of a document.
+func previewDocument(path string, maxLines, maxWidth int) (string, error) {
+	text, err := extractText(context.Background(), path)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(text) == "" {
+		return "(document without text)", nil
+	}
+	return previewLines(text, maxLines, maxWidth), nil
+}
+
+// previewArchive lists the files inside a zip archive.
+func previewArchive(path string, maxLines, maxWidth int) (string, error) {
+	listing, err := archiveListing(path)
+	if err != nil {
+		return "", err
+	}
+	if listing == "" {
+		return "(empty zip archive)", nil
+	}
+	return previewLines(listing, maxLines, maxWidth), nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestHandlerFor tests picking handlers by extension and by sniffed content.
+func TestHandlerFor(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	png := createPNG(t, tmpDir, "photo.png", 8, 8, gradient(8))
+	unnamed := filepath.Join(tmpDir, "photo")
+	if err := os.Rename(createPNG(t, tmpDir, "copy.png", 8, 8, gradient(8)), unnamed); err != nil {
+		t.Fatal(err)
+	}
+	backup := createZip(t, tmpDir, "backup.zip", map[string]string{"notes.md": "one"})
+	unnamedZip := filepath.Join(tmpDir, "backup")
+	if err := os.Rename(createZip(t, tmpDir, "copy.zip", map[string]string{"notes.md": "one"}), unnamedZip); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want *fileHandler
+	}{
+		{png, imageHandler},
+		{unnamed, imageHandler},
+		{backup, archiveHandler},
+		{unnamedZip, archiveHandler},
+		{createFileWithContent(t, tmpDir, "notes.md", "one"), textHandler},
+		{createFileWithContent(t, tmpDir, "song.MP3", "not probed"), mediaHandler},
+		{filepath.Join(tmpDir, "report.docx"), documentHandler},
+		{filepath.Join(tmpDir, "report.pdf"), pdfHandler},
+		{filepath.Join(tmpDir, "missing"), textHandler},
+	}
+	for _, tt := range tests {
+		if got := handlerFor(tt.path); got != tt.want {
+			t.Errorf("handlerFor(%s) = %s, expected %s", filepath.Base(tt.path), got.name, tt.want.name)
+		}
+	}
+	if got := pairHandler(png, backup); got != textHandler {
+		t.Errorf("files of different kinds should be compared as text or bytes, got %s", got.name)
+	}
+}
+
+// TestPreviewFile tests the previews of images, archives, and other files.
+func TestPreviewFile(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	tests := []struct {
+		path, want string
+	}{
+		{createPNG(t, tmpDir, "photo.png", 16, 8, gradient(16)), "(PNG image, 16x8, "},
+		{createZip(t, tmpDir, "backup.zip", map[string]string{"notes.md": "one", "2024/todo.md": "todo"}), "2024/todo.md  4 B  crc32 "},
+		{createFileWithContent(t, tmpDir, "song.mp3", "0123456789"), "(audio or video file, 10 B)"},
+		{createFileWithContent(t, tmpDir, "notes.md", "one\ntwo\n"), "one\ntwo"},
+	}
+	for _, tt := range tests {
+		got, err := previewFile(tt.path, 5, 80)
+		if err != nil {
+			t.Errorf("previewFile(%s) returned error: %v", filepath.Base(tt.path), err)
+		} else if !strings.HasPrefix(got, tt.want) {
+			t.Errorf("previewFile(%s) = %q, expected it to start with %q", filepath.Base(tt.path), got, tt.want)
+		}
+	}
+}
+
+// TestComparePair_Archives tests comparing zip archives by the files they hold.
+func TestComparePair_Archives(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	backup := createZip(t, tmpDir, "backup.zip", map[string]string{"notes.md": "one", "todo.md": "todo"})
+	copy := createZip(t, tmpDir, "backup (1).zip", map[string]string{"notes.md": "one", "todo.md": "todo, and more"})
+	same := createZip(t, tmpDir, "backup (2).zip", map[string]string{"todo.md": "todo", "notes.md": "one"})
+	d := NewDiffExecutor("")
+
+	output, err := d.ComparePair(backup, copy, true)
+	if err != nil {
+		t.Fatalf("ComparePair() returned error: %v", err)
+	}
+	if !strings.HasPrefix(output, "Archive contents") || !strings.Contains(output, "-todo.md  4 B") || strings.Contains(output, "-notes.md") {
+		t.Errorf("ComparePair() should diff the listings of the archives:\n%s", output)
+	}
+
+	output, err = d.ComparePair(backup, same, true)
+	if err != nil || !strings.Contains(output, "Both archives hold the same files") {
+		t.Errorf("ComparePair() of archives holding the same files = %q, %v", output, err)
+	}
+}
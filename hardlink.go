@@ -0,0 +1,68 @@
+package main
+
+import "os"
+
+// sameFile reports whether two paths are hard links to the same file (same device
+// and inode), so they already share storage. A symbolic link is a file of its
+// own, not the one it points to. Errors count as not the same.
+func sameFile(file1, file2 string) bool {
+	info1, err := os.Lstat(file1)
+	if err != nil {
+		return false
+	}
+	info2, err := os.Lstat(file2)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(info1, info2)
+}
+
+// hardlinkPeers returns, for each file of a group, the index of the first earlier
+// file it is a hard link to, or -1 if it isn't linked to an earlier file.
+// Symbolic links aren't hard links to the files they point to.
+func hardlinkPeers(group []string) []int {
+	infos := make([]os.FileInfo, len(group))
+	for i, file := range group {
+		infos[i], _ = os.Lstat(file)
+	}
+
+	peers := make([]int, len(group))
+	for i := range group {
+		peers[i] = -1
+		if infos[i] == nil {
+			continue
+		}
+		for j := 0; j < i; j++ {
+			if infos[j] != nil && os.SameFile(infos[i], infos[j]) {
+				peers[i] = j
+				break
+			}
+		}
+	}
+	return peers
+}
+
+// isSymlink reports whether path is a symbolic link. Errors count as not a link.
+func isSymlink(path string) bool {
+	info, err := os.Lstat(path)
+	return err == nil && info.Mode()&os.ModeSymlink != 0
+}
+
+// symlinkTo returns the first file of files other than file that is a symbolic
+// link resolving to file, or "" if there is none. Removing file would leave
+// such a link dangling, and with it, possibly the last copy of the data.
+func symlinkTo(files []string, file string) string {
+	info, err := os.Lstat(file)
+	if err != nil {
+		return ""
+	}
+	for _, f := range files {
+		if f == file || !isSymlink(f) {
+			continue
+		}
+		if target, err := os.Stat(f); err == nil && os.SameFile(info, target) {
+			return f
+		}
+	}
+	return ""
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestSameFile tests detecting hard links to the same file.
+func TestSameFile(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	original := createFileWithContent(t, tmpDir, "photo.jpg", "pixels")
+	copyPath := createFileWithContent(t, tmpDir, "photo-1.jpg", "pixels")
+	link := filepath.Join(tmpDir, "photo-2.jpg")
+	if err := os.Link(original, link); err != nil {
+		t.Skipf("hard links not supported: %v", err)
+	}
+
+	if !sameFile(original, link) {
+		t.Error("sameFile() should be true for hard links")
+	}
+	if sameFile(original, copyPath) {
+		t.Error("sameFile() should be false for separate copies")
+	}
+	if sameFile(original, filepath.Join(tmpDir, "missing.jpg")) {
+		t.Error("sameFile() should be false for a missing file")
+	}
+
+	peers := hardlinkPeers([]string{copyPath, original, link})
+	if !reflect.DeepEqual(peers, []int{-1, -1, 1}) {
+		t.Errorf("hardlinkPeers() = %v, expected [-1 -1 1]", peers)
+	}
+}
+
+// TestSameFile_Symlink tests that a symbolic link is neither the same file as
+// its target nor a hard link of it, and that symlinkTo finds it.
+func TestSameFile_Symlink(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	original := createFileWithContent(t, tmpDir, "photo.jpg", "pixels")
+	copyPath := createFileWithContent(t, tmpDir, "photo-1.jpg", "pixels")
+	link := filepath.Join(tmpDir, "photo-2.jpg")
+	if err := os.Symlink(original, link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	if sameFile(original, link) {
+		t.Error("sameFile() should be false for a symbolic link and its target")
+	}
+	if peers := hardlinkPeers([]string{original, link}); !reflect.DeepEqual(peers, []int{-1, -1}) {
+		t.Errorf("hardlinkPeers() = %v, expected [-1 -1]", peers)
+	}
+	group := []string{copyPath, original, link}
+	if got := symlinkTo(group, original); got != link {
+		t.Errorf("symlinkTo(original) = %q, expected %q", got, link)
+	}
+	if got := symlinkTo(group, copyPath); got != "" {
+		t.Errorf("symlinkTo(copy) = %q, expected none", got)
+	}
+}
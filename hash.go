@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+)
+
+// hashFile returns the hex-encoded SHA-256 of a file's content, reporting the
+// bytes read to progress. Hashing stops early if ctx is cancelled.
+func hashFile(ctx context.Context, path string, progress *Progress) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, contextReader{ctx, f})
+	progress.AddBytes(n)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashPrefix returns the hex-encoded SHA-256 of at most the first n bytes of a
+// file, reporting the bytes read to progress.
+func hashPrefix(ctx context.Context, path string, n int64, progress *Progress) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	read, err := io.CopyN(h, contextReader{ctx, f}, n)
+	progress.AddBytes(read)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// contextReader fails reads once ctx is cancelled, so long copies can be interrupted.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+// Read reads from the underlying reader unless the context is done.
+func (c contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
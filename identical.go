@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+)
+
+// IdenticalCopies collapses the files of each group that are byte-identical
+// to an earlier file of the group, for --only-different, and remembers them
+// so the listing can note them. A nil *IdenticalCopies collapses nothing.
+type IdenticalCopies struct {
+	// copies maps each listed file to the identical copies collapsed into it.
+	copies map[string][]string
+	// count is the number of collapsed copies.
+	count int
+	// dropped is the number of groups left out because all their files were
+	// identical.
+	dropped int
+}
+
+// NewIdenticalCopies returns an empty IdenticalCopies.
+func NewIdenticalCopies() *IdenticalCopies {
+	return &IdenticalCopies{copies: make(map[string][]string)}
+}
+
+// Collapse returns groups with each file that is identical to an earlier file
+// of its group left out, and without the groups that have fewer than two
+// files left. Only files whose size another file of the group shares are
+// hashed, with hashes of unchanged files taken from cache, which may be nil.
+func (c *IdenticalCopies) Collapse(ctx context.Context, groups [][]string, cache *HashCache, progress *Progress) ([][]string, error) {
+	if c == nil {
+		return groups, nil
+	}
+	progress.SetPhase("Hashing")
+
+	var result [][]string
+	for _, group := range groups {
+		sizes := make([]int64, len(group))
+		sizeCount := make(map[int64]int)
+		for i, file := range group {
+			stat := os.Stat
+			if isArchiveMember(file) {
+				stat = statArchiveMember
+			}
+			info, err := stat(file)
+			if err != nil {
+				return nil, err
+			}
+			sizes[i] = info.Size()
+			sizeCount[sizes[i]]++
+		}
+
+		var kept []string
+		first := make(map[string]string)
+		for i, file := range group {
+			if sizeCount[sizes[i]] < 2 {
+				kept = append(kept, file)
+				continue
+			}
+			var hash string
+			var err error
+			if isArchiveMember(file) {
+				hash, err = hashArchiveMember(ctx, file, progress)
+			} else {
+				hash, err = cache.FullHash(ctx, file, progress)
+			}
+			if err != nil {
+				return nil, err
+			}
+			key := fmt.Sprintf("%d:%s", sizes[i], hash)
+			if original, ok := first[key]; ok {
+				c.copies[original] = append(c.copies[original], file)
+				c.count++
+				continue
+			}
+			first[key] = file
+			kept = append(kept, file)
+		}
+
+		if len(kept) < 2 {
+			logger.Debug("left out a group of identical files", "path", group[0], "files", len(group))
+			c.dropped++
+			continue
+		}
+		result = append(result, kept)
+	}
+	logger.Info("collapsed identical files", "copies", c.count, "groups left out", c.dropped)
+	return result, nil
+}
+
+// Of returns the identical copies collapsed into file.
+func (c *IdenticalCopies) Of(file string) []string {
+	if c == nil {
+		return nil
+	}
+	return c.copies[file]
+}
+
+// Note returns a line saying how many copies and groups were collapsed, or ""
+// if none were.
+func (c *IdenticalCopies) Note() string {
+	if c == nil || c.count == 0 {
+		return ""
+	}
+	note := fmt.Sprintf("Collapsed %s identical to an earlier file of their group", plural(c.count, "file"))
+	if c.dropped > 0 {
+		note += fmt.Sprintf(" and left out %s of only identical files", plural(c.dropped, "group"))
+	}
+	return note + "; run without --only-different to list them."
+}
+
+// writeIdenticalNote writes the note of identical to w, if any.
+func writeIdenticalNote(w io.Writer, identical *IdenticalCopies) {
+	if note := identical.Note(); note != "" {
+		fmt.Fprintln(w, note)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestIdenticalCopies_Collapse tests collapsing identical copies into the
+// first of them and leaving out groups of only identical files.
+func TestIdenticalCopies_Collapse(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	notes := createFileWithContent(t, tmpDir, "notes.md", "draft")
+	notesCopy := createFileWithContent(t, tmpDir, "notes (1).md", "draft")
+	notesFinal := createFileWithContent(t, tmpDir, "notes-final.md", "final")
+	notesOther := createFileWithContent(t, tmpDir, "notes-old.md", "older draft")
+	todo := createFileWithContent(t, tmpDir, "todo.md", "milk")
+	todoCopy := createFileWithContent(t, tmpDir, "todo-1.md", "milk")
+
+	identical := NewIdenticalCopies()
+	groups, err := identical.Collapse(context.Background(), [][]string{
+		{notes, notesCopy, notesFinal, notesOther},
+		{todo, todoCopy},
+	}, nil, nil)
+	if err != nil {
+		t.Fatalf("Collapse() returned error: %v", err)
+	}
+	expected := [][]string{{notes, notesFinal, notesOther}}
+	if !reflect.DeepEqual(groups, expected) {
+		t.Errorf("Collapse() = %v, expected %v", groups, expected)
+	}
+	if copies := identical.Of(notes); !reflect.DeepEqual(copies, []string{notesCopy}) {
+		t.Errorf("Of(%s) = %v, expected the copy", filepath.Base(notes), copies)
+	}
+	if copies := identical.Of(notesFinal); copies != nil {
+		t.Errorf("Of(%s) = %v, expected none", filepath.Base(notesFinal), copies)
+	}
+
+	note := "Collapsed 2 files identical to an earlier file of their group and left out 1 group of only identical files; run without --only-different to list them."
+	if got := identical.Note(); got != note {
+		t.Errorf("Note() = %q, expected %q", got, note)
+	}
+}
+
+// TestIdenticalCopies_Nil tests that a nil IdenticalCopies keeps every group.
+func TestIdenticalCopies_Nil(t *testing.T) {
+	var identical *IdenticalCopies
+	groups := [][]string{{"/missing/a.md", "/missing/a-1.md"}}
+	got, err := identical.Collapse(context.Background(), groups, nil, nil)
+	if err != nil || !reflect.DeepEqual(got, groups) {
+		t.Errorf("Collapse() = %v, %v, expected the groups unchanged", got, err)
+	}
+	if identical.Of("/missing/a.md") != nil || identical.Note() != "" {
+		t.Error("a nil IdenticalCopies should have no copies and no note")
+	}
+	if NewIdenticalCopies().Note() != "" {
+		t.Error("Note() should be empty when nothing was collapsed")
+	}
+}
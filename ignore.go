@@ -0,0 +1,126 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// stateDirName is the per-directory folder where doppel keeps its state.
+const stateDirName = ".doppel"
+
+// ignoreFileName is the file inside stateDirName that lists ignored groups.
+const ignoreFileName = "ignored.json"
+
+// groupFingerprint returns a stable identifier for a group: a hash of its members'
+// paths relative to dir, sorted so that scan order doesn't matter.
+func groupFingerprint(dir string, group []string) string {
+	rel := make([]string, len(group))
+	for i, file := range group {
+		r, err := filepath.Rel(dir, file)
+		if err != nil {
+			// A relative dir with absolute paths, or the other way round
+			r, err = filepath.Rel(absPath(dir), absPath(file))
+		}
+		if err == nil {
+			file = r
+		}
+		rel[i] = filepath.ToSlash(file)
+	}
+	sort.Strings(rel)
+
+	h := sha256.New()
+	for _, r := range rel {
+		h.Write([]byte(r))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// IgnoreList is the set of group fingerprints the user asked never to see again
+// for a directory. It is stored in DIR/.doppel/ignored.json.
+type IgnoreList struct {
+	dir          string
+	fingerprints map[string]bool
+}
+
+// ignoreFile is the on-disk format of an IgnoreList.
+type ignoreFile struct {
+	Ignored []string `json:"ignored"`
+}
+
+// LoadIgnoreList reads the ignore list for dir. A missing file is an empty list.
+func LoadIgnoreList(dir string) (*IgnoreList, error) {
+	list := &IgnoreList{dir: dir, fingerprints: make(map[string]bool)}
+
+	data, err := os.ReadFile(list.path())
+	if errors.Is(err, os.ErrNotExist) {
+		return list, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var f ignoreFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("invalid ignore list %s: %w", list.path(), err)
+	}
+	for _, fp := range f.Ignored {
+		list.fingerprints[fp] = true
+	}
+	return list, nil
+}
+
+// path returns the location of the ignore list file.
+func (l *IgnoreList) path() string {
+	return filepath.Join(l.dir, stateDirName, ignoreFileName)
+}
+
+// Fingerprint returns the fingerprint of a group relative to the list's directory.
+func (l *IgnoreList) Fingerprint(group []string) string {
+	return groupFingerprint(l.dir, group)
+}
+
+// Contains reports whether a group is ignored.
+func (l *IgnoreList) Contains(group []string) bool {
+	return l.fingerprints[l.Fingerprint(group)]
+}
+
+// Add ignores a group. Call Save to persist the change.
+func (l *IgnoreList) Add(group []string) {
+	l.fingerprints[l.Fingerprint(group)] = true
+}
+
+// Save writes the ignore list, creating the state directory if needed.
+func (l *IgnoreList) Save() error {
+	f := ignoreFile{Ignored: make([]string, 0, len(l.fingerprints))}
+	for fp := range l.fingerprints {
+		f.Ignored = append(f.Ignored, fp)
+	}
+	sort.Strings(f.Ignored)
+
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(l.path()), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(l.path(), append(data, '\n'), 0644)
+}
+
+// Filter returns the groups that are not ignored.
+func (l *IgnoreList) Filter(groups [][]string) [][]string {
+	var result [][]string
+	for _, group := range groups {
+		if !l.Contains(group) {
+			result = append(result, group)
+		}
+	}
+	return result
+}
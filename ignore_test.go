@@ -0,0 +1,91 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestGroupFingerprint tests that fingerprints ignore member order and the scan root.
+func TestGroupFingerprint(t *testing.T) {
+	a := groupFingerprint("/notes", []string{"/notes/todo.md", "/notes/todo 2.md"})
+	b := groupFingerprint("/notes", []string{"/notes/todo 2.md", "/notes/todo.md"})
+	c := groupFingerprint("/backup/notes", []string{"/backup/notes/todo.md", "/backup/notes/todo 2.md"})
+	d := groupFingerprint("/notes", []string{"/notes/todo.md", "/notes/todo 3.md"})
+
+	if a != b {
+		t.Error("fingerprint should not depend on member order")
+	}
+	if a != c {
+		t.Error("fingerprint should use paths relative to the scanned directory")
+	}
+	if a == d {
+		t.Error("different members should produce different fingerprints")
+	}
+	if len(a) != 16 {
+		t.Errorf("fingerprint %q has length %d, expected 16", a, len(a))
+	}
+
+	// Members listed by absolute path still count from a relative scan root
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rel := groupFingerprint("notes", []string{"notes/todo.md", "notes/todo 2.md"})
+	abs := groupFingerprint("notes", []string{filepath.Join(wd, "notes/todo.md"), filepath.Join(wd, "notes/todo 2.md")})
+	if rel != a || abs != a {
+		t.Errorf("fingerprints %s and %s should match %s", rel, abs, a)
+	}
+}
+
+// TestIgnoreList_SaveAndLoad tests persisting ignored groups to .doppel/ignored.json.
+func TestIgnoreList_SaveAndLoad(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	ignored := []string{filepath.Join(tmpDir, "a.txt"), filepath.Join(tmpDir, "a-1.txt")}
+	kept := []string{filepath.Join(tmpDir, "b.txt"), filepath.Join(tmpDir, "b-1.txt")}
+
+	list, err := LoadIgnoreList(tmpDir)
+	if err != nil {
+		t.Fatalf("LoadIgnoreList() on a fresh directory returned error: %v", err)
+	}
+	list.Add(ignored)
+	if err := list.Save(); err != nil {
+		t.Fatalf("Save() returned error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, ".doppel", "ignored.json")); err != nil {
+		t.Fatalf("Save() did not create the ignore file: %v", err)
+	}
+
+	reloaded, err := LoadIgnoreList(tmpDir)
+	if err != nil {
+		t.Fatalf("LoadIgnoreList() returned error: %v", err)
+	}
+	if !reloaded.Contains(ignored) {
+		t.Error("reloaded list should contain the ignored group")
+	}
+	if reloaded.Contains(kept) {
+		t.Error("reloaded list should not contain other groups")
+	}
+
+	filtered := reloaded.Filter([][]string{ignored, kept})
+	if len(filtered) != 1 || filtered[0][0] != kept[0] {
+		t.Errorf("Filter() = %v, expected only the kept group", filtered)
+	}
+}
+
+// TestLoadIgnoreList_Invalid tests that a corrupt ignore file is reported.
+func TestLoadIgnoreList_Invalid(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	if err := os.Mkdir(filepath.Join(tmpDir, ".doppel"), 0755); err != nil {
+		t.Fatalf("Mkdir() failed: %v", err)
+	}
+	createFileWithContent(t, filepath.Join(tmpDir, ".doppel"), "ignored.json", "{not json")
+
+	if _, err := LoadIgnoreList(tmpDir); err == nil {
+		t.Error("LoadIgnoreList() should return error for invalid JSON")
+	}
+}
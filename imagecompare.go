@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// imageExtensions lists the extensions treated as images. HEIC files are recognized
+// but cannot be decoded with the standard library, so they fall back to binary comparison.
+var imageExtensions = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+	".gif":  true,
+	".heic": true,
+}
+
+// previewSize is the maximum width and height in pixels of a terminal preview.
+const previewSize = 160
+
+// ImageInfo describes a decoded image.
+type ImageInfo struct {
+	Format      string
+	Width       int
+	Height      int
+	CaptureTime string
+	Camera      string
+	Hash        uint64
+	img         image.Image
+	// Latitude and Longitude are the GPS position in decimal degrees, set
+	// when HasLocation is.
+	Latitude, Longitude float64
+	HasLocation         bool
+}
+
+// isImageFile reports whether a path has a known image extension.
+func isImageFile(path string) bool {
+	return imageExtensions[strings.ToLower(filepath.Ext(path))]
+}
+
+// loadImageInfo decodes an image and collects its dimensions, EXIF capture
+// time, camera, and location, and perceptual hash.
+func loadImageInfo(path string) (*ImageInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, format, err := image.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &ImageInfo{
+		Format: format,
+		Width:  img.Bounds().Dx(),
+		Height: img.Bounds().Dy(),
+		Hash:   differenceHash(img),
+		img:    img,
+	}
+	if exif, err := readEXIF(path); err == nil {
+		info.CaptureTime = exif.CaptureTime()
+		info.Camera = exif.Camera()
+		info.Latitude, info.Longitude, info.HasLocation = exif.Location()
+	}
+	return info, nil
+}
+
+// differenceHash computes a 64-bit dHash: the image is reduced to a 9x8 grayscale
+// grid and each bit records whether a cell is brighter than its right neighbour.
+// Visually similar images produce hashes with a small Hamming distance.
+func differenceHash(img image.Image) uint64 {
+	const cols, rows = 9, 8
+	var grid [rows][cols]float64
+
+	b := img.Bounds()
+	for y := 0; y < rows; y++ {
+		for x := 0; x < cols; x++ {
+			x0 := b.Min.X + x*b.Dx()/cols
+			x1 := b.Min.X + (x+1)*b.Dx()/cols
+			y0 := b.Min.Y + y*b.Dy()/rows
+			y1 := b.Min.Y + (y+1)*b.Dy()/rows
+			grid[y][x] = averageLuminance(img, x0, y0, x1, y1)
+		}
+	}
+
+	var hash uint64
+	for y := 0; y < rows; y++ {
+		for x := 0; x < cols-1; x++ {
+			hash <<= 1
+			if grid[y][x] > grid[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// averageLuminance samples up to 8x8 points of a rectangle and returns their mean luminance.
+func averageLuminance(img image.Image, x0, y0, x1, y1 int) float64 {
+	if x1 <= x0 {
+		x1 = x0 + 1
+	}
+	if y1 <= y0 {
+		y1 = y0 + 1
+	}
+	stepX := max((x1-x0)/8, 1)
+	stepY := max((y1-y0)/8, 1)
+
+	var sum float64
+	var n int
+	for y := y0; y < y1; y += stepY {
+		for x := x0; x < x1; x += stepX {
+			r, g, b, _ := img.At(x, y).RGBA()
+			sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+			n++
+		}
+	}
+	return sum / float64(n)
+}
+
+// hashSimilarity returns the fraction of matching bits between two perceptual hashes.
+func hashSimilarity(a, b uint64) float64 {
+	return 1 - float64(bits.OnesCount64(a^b))/64
+}
+
+// imageDiff returns a formatted image comparison if both files are decodable images.
+// The boolean result is false when the pair should be compared some other way.
+func imageDiff(file1, file2 string) (string, bool) {
+	info1, err := loadImageInfo(file1)
+	if err != nil {
+		return "", false
+	}
+	info2, err := loadImageInfo(file2)
+	if err != nil {
+		return "", false
+	}
+	return FormatImageComparison(info1, info2), true
+}
+
+// FormatImageComparison renders two images' metadata as a table, with rows
+// that differ marked, followed by their perceptual similarity.
+func FormatImageComparison(info1, info2 *ImageInfo) string {
+	var s strings.Builder
+
+	s.WriteString("Image comparison\n\n")
+	rows := [][3]string{{"", "File 1", "File 2"}}
+	field := func(name string, value func(*ImageInfo) string) {
+		rows = append(rows, [3]string{name, value(info1), value(info2)})
+	}
+	field("Format", func(info *ImageInfo) string { return info.Format })
+	field("Dimensions", func(info *ImageInfo) string { return fmt.Sprintf("%dx%d", info.Width, info.Height) })
+	field("Captured", func(info *ImageInfo) string { return orUnknown(info.CaptureTime) })
+	field("Camera", func(info *ImageInfo) string { return orUnknown(info.Camera) })
+	field("Location", func(info *ImageInfo) string { return orUnknown(formatLocation(info)) })
+	field("Perceptual hash", func(info *ImageInfo) string { return fmt.Sprintf("%016x", info.Hash) })
+
+	s.WriteString(formatMetadataTable(rows))
+
+	similarity := hashSimilarity(info1.Hash, info2.Hash)
+	fmt.Fprintf(&s, "\nPerceptual similarity: %.0f%%", similarity*100)
+	switch {
+	case similarity == 1:
+		s.WriteString(" (visually identical)")
+	case similarity >= 0.9:
+		s.WriteString(" (very likely the same picture)")
+	case similarity < 0.7:
+		s.WriteString(" (likely different pictures)")
+	}
+	s.WriteString("\n")
+	return s.String()
+}
+
+// formatMetadataTable lays out rows of a name and two files' values in
+// columns, marking the rows whose values differ with ≠. The first row is the
+// heading and is never marked.
+func formatMetadataTable(rows [][3]string) string {
+	var width [2]int
+	for _, row := range rows {
+		width[0] = max(width[0], utf8.RuneCountInString(row[0]))
+		width[1] = max(width[1], utf8.RuneCountInString(row[1]))
+	}
+	pad := func(value string, width int) string {
+		return value + strings.Repeat(" ", width-utf8.RuneCountInString(value))
+	}
+	var s strings.Builder
+	for i, row := range rows {
+		line := pad(row[0], width[0]) + "  " + pad(row[1], width[1]) + "  " + row[2]
+		if i > 0 && row[1] != row[2] {
+			line += "  ≠"
+		}
+		s.WriteString(strings.TrimRight(line, " ") + "\n")
+	}
+	return s.String()
+}
+
+// orUnknown returns value, or "unknown" if it is empty.
+func orUnknown(value string) string {
+	if value == "" {
+		return "unknown"
+	}
+	return value
+}
+
+// formatLocation formats an image's GPS position to four decimal places,
+// about 10 m, such as "52.5200° N, 13.4050° E", or "" if it has none.
+func formatLocation(info *ImageInfo) string {
+	if !info.HasLocation {
+		return ""
+	}
+	ns, ew := "N", "E"
+	if info.Latitude < 0 {
+		ns = "S"
+	}
+	if info.Longitude < 0 {
+		ew = "W"
+	}
+	return fmt.Sprintf("%.4f° %s, %.4f° %s", math.Abs(info.Latitude), ns, math.Abs(info.Longitude), ew)
+}
+
+// supportsKittyGraphics reports whether the terminal advertises the kitty graphics protocol.
+func supportsKittyGraphics() bool {
+	if os.Getenv("KITTY_WINDOW_ID") != "" {
+		return true
+	}
+	term := os.Getenv("TERM")
+	return strings.Contains(term, "kitty") || os.Getenv("TERM_PROGRAM") == "WezTerm"
+}
+
+// kittyImage encodes a downscaled copy of img as a kitty graphics protocol escape sequence.
+func kittyImage(img image.Image) string {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, downscale(img, previewSize)); err != nil {
+		return ""
+	}
+	payload := base64.StdEncoding.EncodeToString(buf.Bytes())
+
+	// Payloads are sent in chunks of at most 4096 bytes; m=1 marks more chunks to follow
+	var s strings.Builder
+	for i := 0; i < len(payload); i += 4096 {
+		end := min(i+4096, len(payload))
+		more := 0
+		if end < len(payload) {
+			more = 1
+		}
+		if i == 0 {
+			fmt.Fprintf(&s, "\x1b_Ga=T,f=100,m=%d;%s\x1b\\", more, payload[i:end])
+		} else {
+			fmt.Fprintf(&s, "\x1b_Gm=%d;%s\x1b\\", more, payload[i:end])
+		}
+	}
+	return s.String()
+}
+
+// kittyPreview shows low-resolution previews of an image pair while the TUI is
+// suspended. Escapes written from View would be redrawn and cut like text, so
+// the images are drawn outside the TUI frame; sixel is not supported.
+type kittyPreview struct {
+	files  [2]string
+	stdin  io.Reader
+	stdout io.Writer
+}
+
+func (k *kittyPreview) SetStdin(r io.Reader)  { k.stdin = r }
+func (k *kittyPreview) SetStdout(w io.Writer) { k.stdout = w }
+func (k *kittyPreview) SetStderr(io.Writer)   {}
+
+// Run draws both images, waits for Enter, and deletes the images again.
+func (k *kittyPreview) Run() error {
+	for _, file := range k.files {
+		info, err := loadImageInfo(file)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(k.stdout, "%s\r\n%s\r\n", file, kittyImage(info.img))
+	}
+	fmt.Fprint(k.stdout, "Press Enter to return")
+	_, err := bufio.NewReader(k.stdin).ReadString('\n')
+	fmt.Fprint(k.stdout, "\x1b_Ga=d\x1b\\")
+	if err == io.EOF {
+		err = nil
+	}
+	return err
+}
+
+// downscale returns a nearest-neighbour copy of img that fits within size x size pixels.
+func downscale(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	scale := max(b.Dx(), b.Dy())
+	if scale <= size {
+		return img
+	}
+	w := max(b.Dx()*size/scale, 1)
+	h := max(b.Dy()*size/scale, 1)
+	out := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			out.Set(x, y, img.At(b.Min.X+x*b.Dx()/w, b.Min.Y+y*b.Dy()/h))
+		}
+	}
+	return out
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// createPNG writes a w x h PNG whose pixels are produced by fill.
+func createPNG(t *testing.T, dir, name string, w, h int, fill func(x, y int) color.Color) string {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, fill(x, y))
+		}
+	}
+	path := filepath.Join(dir, name)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create %q: %v", path, err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatalf("Failed to encode %q: %v", path, err)
+	}
+	return path
+}
+
+// gradient returns a horizontal grayscale gradient fill.
+func gradient(w int) func(x, y int) color.Color {
+	return func(x, y int) color.Color {
+		return color.Gray{Y: uint8(x * 255 / w)}
+	}
+}
+
+// TestIsImageFile tests extension-based image detection.
+func TestIsImageFile(t *testing.T) {
+	for path, expected := range map[string]bool{
+		"photo.JPG":   true,
+		"photo.jpeg":  true,
+		"shot.png":    true,
+		"IMG_1.HEIC":  true,
+		"notes.txt":   false,
+		"archive.zip": false,
+	} {
+		if got := isImageFile(path); got != expected {
+			t.Errorf("isImageFile(%q) = %v, expected %v", path, got, expected)
+		}
+	}
+}
+
+// TestImageDiff_SimilarImages tests that a resized copy scores as highly similar.
+func TestImageDiff_SimilarImages(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	file1 := createPNG(t, tmpDir, "photo.png", 90, 60, gradient(90))
+	file2 := createPNG(t, tmpDir, "photo (1).png", 45, 30, gradient(45))
+
+	output, ok := imageDiff(file1, file2)
+	if !ok {
+		t.Fatal("imageDiff() should handle decodable image pairs")
+	}
+	for _, want := range []string{"Dimensions       90x60             45x30  ≠", "Format           png               png\n", "Perceptual similarity: 100%"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("imageDiff() output missing %q:\n%s", want, output)
+		}
+	}
+}
+
+// TestKittyPreview_Run tests that both images are drawn outside the diff output
+// and deleted again once Enter is pressed.
+func TestKittyPreview_Run(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	file1 := createPNG(t, tmpDir, "photo.png", 90, 60, gradient(90))
+	file2 := createPNG(t, tmpDir, "photo (1).png", 45, 30, gradient(45))
+
+	if output, _ := imageDiff(file1, file2); strings.Contains(output, "\x1b_G") {
+		t.Error("imageDiff() should not embed kitty graphics escapes")
+	}
+
+	var out bytes.Buffer
+	preview := &kittyPreview{files: [2]string{file1, file2}}
+	preview.SetStdin(strings.NewReader("\n"))
+	preview.SetStdout(&out)
+	if err := preview.Run(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if got := strings.Count(out.String(), "\x1b_Ga=T"); got != 2 {
+		t.Errorf("Run() drew %d images, want 2", got)
+	}
+	if !strings.HasSuffix(out.String(), "\x1b_Ga=d\x1b\\") {
+		t.Error("Run() should delete the images before returning")
+	}
+}
+
+// TestImageDiff_DifferentImages tests that unrelated images score as dissimilar.
+func TestImageDiff_DifferentImages(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	file1 := createPNG(t, tmpDir, "a.png", 90, 80, gradient(90))
+	file2 := createPNG(t, tmpDir, "b.png", 90, 80, func(x, y int) color.Color {
+		return color.Gray{Y: uint8(255 - x*255/90)}
+	})
+
+	info1, err := loadImageInfo(file1)
+	if err != nil {
+		t.Fatalf("loadImageInfo() returned error: %v", err)
+	}
+	info2, err := loadImageInfo(file2)
+	if err != nil {
+		t.Fatalf("loadImageInfo() returned error: %v", err)
+	}
+	if sim := hashSimilarity(info1.Hash, info2.Hash); sim > 0.5 {
+		t.Errorf("hashSimilarity() = %.2f for mirrored gradients, expected <= 0.5", sim)
+	}
+}
+
+// TestImageDiff_Undecodable tests that non-image content falls back to other comparisons.
+func TestImageDiff_Undecodable(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	file1 := createFileWithContent(t, tmpDir, "image.png", "placeholder\n")
+	file2 := createFileWithContent(t, tmpDir, "image-1.png", "placeholder 2\n")
+
+	if _, ok := imageDiff(file1, file2); ok {
+		t.Error("imageDiff() should decline files that cannot be decoded")
+	}
+}
+
+// TestHashSimilarity tests the bit-agreement score.
+func TestHashSimilarity(t *testing.T) {
+	if got := hashSimilarity(0, 0); got != 1 {
+		t.Errorf("hashSimilarity(0, 0) = %v, expected 1", got)
+	}
+	if got := hashSimilarity(0, ^uint64(0)); got != 0 {
+		t.Errorf("hashSimilarity(0, ~0) = %v, expected 0", got)
+	}
+	if got := hashSimilarity(0, 0xFFFF); got != 0.75 {
+		t.Errorf("hashSimilarity(0, 0xFFFF) = %v, expected 0.75", got)
+	}
+}
+
+// TestFormatImageComparison tests the metadata table of two photos.
+func TestFormatImageComparison(t *testing.T) {
+	info1 := &ImageInfo{Format: "jpeg", Width: 4032, Height: 3024, CaptureTime: "2024:01:30 08:15:00",
+		Camera: "Apple iPhone 12", Latitude: 52.52, Longitude: -13.405, HasLocation: true}
+	info2 := &ImageInfo{Format: "jpeg", Width: 2016, Height: 1512, CaptureTime: "2024:01:30 08:15:00",
+		Camera: "Apple iPhone 12"}
+
+	want := []string{
+		"                 File 1                  File 2",
+		"Format           jpeg                    jpeg",
+		"Dimensions       4032x3024               2016x1512  ≠",
+		"Captured         2024:01:30 08:15:00     2024:01:30 08:15:00",
+		"Camera           Apple iPhone 12         Apple iPhone 12",
+		"Location         52.5200° N, 13.4050° W  unknown  ≠",
+		"Perceptual hash  0000000000000000        0000000000000000",
+	}
+	output := FormatImageComparison(info1, info2)
+	for _, line := range want {
+		if !strings.Contains(output, line+"\n") {
+			t.Errorf("FormatImageComparison() missing line %q:\n%s", line, output)
+		}
+	}
+}
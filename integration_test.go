@@ -1,9 +1,14 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"io"
 	"os"
 	"path/filepath"
+	"reflect"
 	"regexp"
+	"strings"
 	"testing"
 )
 
@@ -229,7 +234,7 @@ func TestIntegration_SuffixFilter_BaseFileInclusion(t *testing.T) {
 		"document-1.txt",
 		"document-2.txt",
 		"document-2026-01-30.txt", // Date file - should be excluded
-		"report-2024.txt",           // Different prefix, date - should be excluded
+		"report-2024.txt",         // Different prefix, date - should be excluded
 	}
 
 	for _, fileName := range filesToCreate {
@@ -249,9 +254,9 @@ func TestIntegration_SuffixFilter_BaseFileInclusion(t *testing.T) {
 
 	// Should include: document.txt, document-1.txt, document-2.txt
 	expectedFiles := map[string]bool{
-		"document.txt":     true,
-		"document-1.txt":   true,
-		"document-2.txt":  true,
+		"document.txt":   true,
+		"document-1.txt": true,
+		"document-2.txt": true,
 	}
 
 	if len(filteredFiles) != len(expectedFiles) {
@@ -395,7 +400,7 @@ func TestIntegration_SuffixFilter_SpacePattern(t *testing.T) {
 
 	// Should include: file.txt, file 1.txt, file 2.txt
 	expectedFiles := map[string]bool{
-		"file.txt":  true,
+		"file.txt":   true,
 		"file 1.txt": true,
 		"file 2.txt": true,
 	}
@@ -469,3 +474,82 @@ func TestIntegration_SuffixFilter_NoMatches(t *testing.T) {
 		t.Errorf("Group() returned groups for empty filtered list, expected nil")
 	}
 }
+
+// TestIntegration_RunList tests the non-interactive listing used by --check.
+func TestIntegration_RunList(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	for _, name := range []string{"document.txt", "document-1.txt", "unrelated.txt"} {
+		createFile(t, tmpDir, name)
+	}
+
+	writeList := func(w io.Writer, groups [][]string) error {
+		return writeGroupList(w, tmpDir, groups)
+	}
+	var out bytes.Buffer
+	count, err := runList(context.Background(), options{dir: tmpDir, minPrefix: 3}, &out, writeList)
+	if err != nil {
+		t.Fatalf("runList() failed: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("runList() returned %d groups, expected 1", count)
+	}
+	if !strings.Contains(out.String(), "Group 1: 2 files") {
+		t.Errorf("runList() output missing group header:\n%s", out.String())
+	}
+	fingerprint := groupFingerprint(tmpDir, []string{filepath.Join(tmpDir, "document.txt"), filepath.Join(tmpDir, "document-1.txt")})
+	if !strings.Contains(out.String(), "(fingerprint "+fingerprint+")") {
+		t.Errorf("runList() output should show the fingerprint %s:\n%s", fingerprint, out.String())
+	}
+
+	out.Reset()
+	count, err = runList(context.Background(), options{dir: tmpDir, minPrefix: 20}, &out, writeList)
+	if err != nil {
+		t.Fatalf("runList() failed: %v", err)
+	}
+	if count != 0 || !strings.Contains(out.String(), "No groups") {
+		t.Errorf("runList() = %d groups, output %q; expected none", count, out.String())
+	}
+}
+
+// TestIntegration_RunListPrint0 tests NUL-delimited output for names with spaces and newlines.
+func TestIntegration_RunListPrint0(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	names := []string{"my notes.txt", "my notes\n(1).txt", "résumé.txt", "résumé-1.txt"}
+	for _, name := range names {
+		createFile(t, tmpDir, name)
+	}
+
+	var out bytes.Buffer
+	count, err := runList(context.Background(), options{dir: tmpDir, minPrefix: 3}, &out, writeGroupsNul)
+	if err != nil {
+		t.Fatalf("runList() failed: %v", err)
+	}
+	if count != 2 {
+		t.Fatalf("runList() returned %d groups, expected 2", count)
+	}
+
+	var groups [][]string
+	for _, block := range strings.Split(strings.TrimSuffix(out.String(), "\x00\x00"), "\x00\x00") {
+		groups = append(groups, strings.Split(block, "\x00"))
+	}
+	want := [][]string{
+		{filepath.Join(tmpDir, "my notes\n(1).txt"), filepath.Join(tmpDir, "my notes.txt")},
+		{filepath.Join(tmpDir, "résumé-1.txt"), filepath.Join(tmpDir, "résumé.txt")},
+	}
+	if !reflect.DeepEqual(groups, want) {
+		t.Errorf("runList() output = %q, expected groups %q", out.String(), want)
+	}
+
+	records := []FileRecord{{Group: 1, Path: "a b"}, {Group: 1, Path: "a b-1"}, {Group: 2, Path: "c"}, {Group: 2, Path: "c-1"}}
+	out.Reset()
+	if err := writeNulReport(&out, records); err != nil {
+		t.Fatalf("writeNulReport() returned error: %v", err)
+	}
+	if out.String() != "a b\x00a b-1\x00\x00c\x00c-1\x00\x00" {
+		t.Errorf("writeNulReport() = %q", out.String())
+	}
+}
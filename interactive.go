@@ -2,31 +2,68 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
-	"path/filepath"
+	"os/exec"
+	"slices"
+	"strings"
 )
 
-// InteractiveCLI provides an interactive interface for navigating file groups and viewing diffs.
+// errQuit ends an interactive session at the user's request.
+var errQuit = errors.New("user quit")
+
+// InteractiveCLI provides a line-based interface for navigating file groups and
+// viewing diffs. It is used instead of the TUI with --no-tui, or when output is
+// not a terminal that can show the TUI, and offers the same actions: comparing
+// pairs, compare all, the column view against a base file, resolving identical
+// files, opening a merge tool, and ignoring groups.
 type InteractiveCLI struct {
-	groups    [][]string
-	diffExec  *DiffExecutor
-	scanner   *bufio.Scanner
-	writer    io.Writer
+	groups     [][]string
+	diffExec   *DiffExecutor
+	mergeTool  *MergeTool
+	ignoreList *IgnoreList
+	quarantine *Quarantine
+	journal    *Journal
+	// keeper suggests which file of each group to keep; nil for none.
+	keeper *Keeper
+	// checksums shows a checksum after each file with --hash; nil for none.
+	checksums *Checksums
+	// matcher labels groups with the name their files share.
+	matcher *Matcher
+	// fullPaths shows paths relative to displayRoot instead of base names.
+	fullPaths   bool
+	displayRoot string
+	// unified shows unified diffs instead of side-by-side ones.
+	unified bool
+	// skipIdentical passes over byte-identical pairs in compare-all mode.
+	skipIdentical bool
+	ctx           context.Context
+	scanner       *bufio.Scanner
+	lines         chan string
+	wants         chan struct{}
+	inputEnded    bool
+	writer        io.Writer
+	// pager shows diffs taller than the terminal; nil writes them directly.
+	pager []string
 }
 
 // NewInteractiveCLI creates a new InteractiveCLI instance.
 func NewInteractiveCLI(groups [][]string, diffExec *DiffExecutor) *InteractiveCLI {
 	return &InteractiveCLI{
-		groups:   groups,
-		diffExec: diffExec,
-		scanner:  bufio.NewScanner(os.Stdin),
-		writer:   os.Stdout,
+		groups:        groups,
+		diffExec:      diffExec,
+		skipIdentical: true,
+		ctx:           context.Background(),
+		scanner:       bufio.NewScanner(os.Stdin),
+		writer:        os.Stdout,
 	}
 }
 
-// Run starts the interactive CLI session.
+// Run starts the interactive CLI session. It returns nil when the user quits or
+// input ends, and the context's error if it is cancelled.
 func (cli *InteractiveCLI) Run() error {
 	if len(cli.groups) == 0 {
 		fmt.Fprintf(cli.writer, "No groups of similar files found.\n")
@@ -36,7 +73,11 @@ func (cli *InteractiveCLI) Run() error {
 	fmt.Fprintf(cli.writer, "Found %d group(s) of similar files.\n\n", len(cli.groups))
 
 	for i, group := range cli.groups {
-		if err := cli.handleGroup(i+1, group); err != nil {
+		err := cli.handleGroup(i+1, group)
+		if errors.Is(err, errQuit) {
+			return nil
+		}
+		if err != nil {
 			return err
 		}
 	}
@@ -44,104 +85,411 @@ func (cli *InteractiveCLI) Run() error {
 	return nil
 }
 
-// handleGroup handles interaction for a single group of files.
+// readLine reads the next line of input. ok is false at the end of input or once
+// the context is cancelled, so an interrupt ends the session even while waiting.
+func (cli *InteractiveCLI) readLine() (line string, ok bool) {
+	if cli.inputEnded {
+		return "", false
+	}
+	if cli.lines == nil {
+		lines, wants := make(chan string), make(chan struct{}, 1)
+		cli.lines, cli.wants = lines, wants
+		go func() {
+			defer close(lines)
+			// Lines are read only when one is wanted, so the terminal is left
+			// to the pager or merge tool while they run
+			for range wants {
+				if !cli.scanner.Scan() {
+					return
+				}
+				lines <- cli.scanner.Text()
+			}
+		}()
+	}
+	select {
+	case cli.wants <- struct{}{}:
+	default:
+		// A line is already wanted from an earlier interrupted wait
+	}
+	select {
+	case line, ok = <-cli.lines:
+		cli.inputEnded = !ok
+		return strings.TrimSpace(line), ok
+	case <-cli.ctx.Done():
+		return "", false
+	}
+}
+
+// prompt writes question and reads the answer. At the end of input it returns
+// ok=false with the context's error, which is nil unless interrupted.
+func (cli *InteractiveCLI) prompt(question string) (answer string, ok bool, err error) {
+	fmt.Fprint(cli.writer, question)
+	answer, ok = cli.readLine()
+	if !ok {
+		fmt.Fprintln(cli.writer)
+		return "", false, cli.ctx.Err()
+	}
+	return answer, true, nil
+}
+
+// handleGroup handles interaction for a single group of files until the user
+// moves on to the next group.
 func (cli *InteractiveCLI) handleGroup(groupNum int, group []string) error {
-	fmt.Fprintf(cli.writer, "=== Group %d: %d files ===\n", groupNum, len(group))
-	for i, file := range group {
-		filename := filepath.Base(file)
-		fmt.Fprintf(cli.writer, "  %d. %s\n", i+1, filename)
+	for {
+		fmt.Fprintf(cli.writer, "=== %s ===\n", groupTitle(cli.matcher, groupNum, group))
+		hardlinks := hardlinkPeers(group)
+		var keeper string
+		if cli.keeper != nil {
+			keeper = cli.keeper.Suggest(group)
+		}
+		for i, file := range group {
+			fmt.Fprintf(cli.writer, "  %d. %s", i+1, cli.displayName(file))
+			if sum := cli.checksums.Short(file); sum != "" {
+				fmt.Fprintf(cli.writer, "  %s", sum)
+			}
+			if i < len(hardlinks) && hardlinks[i] >= 0 {
+				fmt.Fprintf(cli.writer, "  (hard link of %s)", cli.displayName(group[hardlinks[i]]))
+			}
+			if file == keeper {
+				fmt.Fprintf(cli.writer, "  * likely keeper")
+			}
+			fmt.Fprintf(cli.writer, "\n")
+		}
+		fmt.Fprintf(cli.writer, "\n")
+
+		// Generate all pairs in the group
+		pairs := cli.generatePairs(group)
+		if len(pairs) == 0 {
+			fmt.Fprintf(cli.writer, "No pairs to compare in this group.\n\n")
+			return nil
+		}
+
+		fmt.Fprintf(cli.writer, "Available pairs to compare:\n")
+		for i, pair := range pairs {
+			fmt.Fprintf(cli.writer, "  %d. File %d vs File %d (%s vs %s)\n",
+				i+1, cli.findFileIndex(group, pair[0])+1, cli.findFileIndex(group, pair[1])+1,
+				cli.displayName(pair[0]), cli.displayName(pair[1]))
+		}
+		fmt.Fprintf(cli.writer, "\n")
+
+		choices := "'a' to compare all pairs"
+		if _, ok := groupBase(group); ok {
+			choices += ", '3' to compare every file with the base"
+		}
+		if cli.ignoreList != nil {
+			choices += ", 'i' to ignore this group"
+		}
+		input, ok, err := cli.prompt(fmt.Sprintf("Enter pair number (1-%d), file numbers (e.g., '2-3'), %s, 'u' to undo, 'p' to toggle full paths, 'n' for next group, 'q' to quit: ", len(pairs), choices))
+		if !ok {
+			return err
+		}
+
+		var removed string
+		switch input {
+		case "q", "Q":
+			return errQuit
+		case "n", "N", "":
+			return nil
+		case "p", "P":
+			cli.fullPaths = !cli.fullPaths
+			continue
+		case "u", "U":
+			group = cli.undo(group)
+			continue
+		case "i", "I":
+			if cli.ignoreList == nil {
+				fmt.Fprintf(cli.writer, "Ignoring groups is not available.\n\n")
+				continue
+			}
+			cli.ignoreList.Add(group)
+			if err := cli.ignoreList.Save(); err != nil {
+				fmt.Fprintf(cli.writer, "Error saving ignore list: %v\n\n", err)
+				continue
+			}
+			fmt.Fprintf(cli.writer, "Group ignored; it will be hidden on future runs (use --include-ignored to show it).\n\n")
+			return nil
+		case "a", "A":
+			group, err = cli.compareAll(group)
+			if err != nil {
+				return err
+			}
+			continue
+		case "3":
+			if err := cli.showBaseColumns(group); err != nil {
+				return err
+			}
+			continue
+		default:
+			file1, file2, ok := cli.parsePair(input, group, pairs)
+			if !ok {
+				fmt.Fprintf(cli.writer, "Invalid input. Please enter a pair number (1-%d) or file numbers (e.g., '2-3').\n\n", len(pairs))
+				continue
+			}
+			removed, _, err = cli.comparePair(group, file1, file2, false)
+			if err != nil {
+				return err
+			}
+		}
+		if removed != "" {
+			group = removeFile(group, removed)
+		}
 	}
-	fmt.Fprintf(cli.writer, "\n")
+}
 
-	// Generate all pairs in the group
-	pairs := cli.generatePairs(group)
-	if len(pairs) == 0 {
-		fmt.Fprintf(cli.writer, "No pairs to compare in this group.\n\n")
-		return nil
+// parsePair resolves input, a pair number or two file numbers such as "2-3",
+// to the files to compare.
+func (cli *InteractiveCLI) parsePair(input string, group []string, pairs [][]string) (file1, file2 string, ok bool) {
+	var file1Num, file2Num int
+	if n, _ := fmt.Sscanf(input, "%d-%d", &file1Num, &file2Num); n == 2 {
+		if file1Num < 1 || file1Num > len(group) || file2Num < 1 || file2Num > len(group) || file1Num == file2Num {
+			return "", "", false
+		}
+		return group[file1Num-1], group[file2Num-1], true
 	}
 
-	// Create mapping from file indices to pair index
-	pairMap := cli.createPairMap(group, pairs)
+	var pairNum int
+	if _, err := fmt.Sscanf(input, "%d", &pairNum); err != nil || pairNum < 1 || pairNum > len(pairs) {
+		return "", "", false
+	}
+	return pairs[pairNum-1][0], pairs[pairNum-1][1], true
+}
 
-	fmt.Fprintf(cli.writer, "Available pairs to compare:\n")
-	for i, pair := range pairs {
-		file1Idx := cli.findFileIndex(group, pair[0])
-		file2Idx := cli.findFileIndex(group, pair[1])
-		fmt.Fprintf(cli.writer, "  %d. File %d vs File %d (%s vs %s)\n",
-			i+1, file1Idx+1, file2Idx+1,
-			filepath.Base(pair[0]), filepath.Base(pair[1]))
+// removeFile returns group without file.
+func removeFile(group []string, file string) []string {
+	remaining := make([]string, 0, len(group))
+	for _, f := range group {
+		if f != file {
+			remaining = append(remaining, f)
+		}
 	}
-	fmt.Fprintf(cli.writer, "\n")
-	fmt.Fprintf(cli.writer, "Enter pair number (1-%d), file numbers (e.g., '2-3'), 'n' for next group, 'q' to quit: ", len(pairs))
+	return remaining
+}
+
+// compareAll walks every pair of the group, passing over byte-identical pairs
+// unless skipIdentical is off, and pairs that are hard links to the same data.
+// It returns the group without any files deleted along the way.
+func (cli *InteractiveCLI) compareAll(group []string) ([]string, error) {
+	var skippedIdentical, skippedLinked int
+	for _, pair := range cli.generatePairs(group) {
+		if cli.findFileIndex(group, pair[0]) < 0 || cli.findFileIndex(group, pair[1]) < 0 {
+			// One of the files was deleted earlier in this walk
+			continue
+		}
+		if sameFile(pair[0], pair[1]) {
+			skippedLinked++
+			continue
+		}
+		if cli.skipIdentical {
+			if identical, err := filesByteIdentical(pair[0], pair[1]); err == nil && identical {
+				skippedIdentical++
+				continue
+			}
+		}
 
-	if !cli.scanner.Scan() {
-		return cli.scanner.Err()
+		removed, stop, err := cli.comparePair(group, pair[0], pair[1], true)
+		if err != nil {
+			return group, err
+		}
+		if removed != "" {
+			group = removeFile(group, removed)
+		}
+		if stop {
+			return group, nil
+		}
 	}
 
-	input := cli.scanner.Text()
-	switch input {
-	case "q", "Q":
-		return fmt.Errorf("user quit")
-	case "n", "N":
-		return nil
-	default:
-		// Try to parse as file numbers (e.g., "2-3")
-		var file1Num, file2Num int
-		if n, _ := fmt.Sscanf(input, "%d-%d", &file1Num, &file2Num); n == 2 {
-			// User specified file numbers
-			if file1Num < 1 || file1Num > len(group) || file2Num < 1 || file2Num > len(group) || file1Num == file2Num {
-				fmt.Fprintf(cli.writer, "Invalid file numbers. Please enter two different numbers between 1 and %d.\n\n", len(group))
-				return cli.handleGroup(groupNum, group)
-			}
-			// Find the pair index
-			key := fmt.Sprintf("%d-%d", file1Num-1, file2Num-1)
-			if pairIdx, ok := pairMap[key]; ok {
-				pair := pairs[pairIdx]
-				if err := cli.showDiff(pair[0], pair[1]); err != nil {
-					return err
-				}
+	fmt.Fprintf(cli.writer, "All pairs compared")
+	if skippedIdentical > 0 {
+		fmt.Fprintf(cli.writer, " (%d identical skipped)", skippedIdentical)
+	}
+	if skippedLinked > 0 {
+		fmt.Fprintf(cli.writer, " (%d already hard-linked)", skippedLinked)
+	}
+	fmt.Fprintf(cli.writer, ".\n\n")
+	return group, nil
+}
+
+// comparePair shows two files and offers the actions of the TUI's diff view
+// until the user moves on. Identical files can be deleted or hard-linked; for
+// others the diff mode can be changed or a merge tool opened. In compare-all
+// mode, stop reports that the user asked to leave the walk. removed is the file
+// deleted, if any. group is the group the files belong to.
+func (cli *InteractiveCLI) comparePair(group []string, file1, file2 string, compareAll bool) (removed string, stop bool, err error) {
+	next := "Enter to go back"
+	if compareAll {
+		next = "Enter for the next pair, 'b' to go back"
+	}
+
+	for {
+		hardlinked := sameFile(file1, file2)
+		identical := hardlinked
+		if !identical {
+			identical, _ = filesByteIdentical(file1, file2)
+		}
+
+		var question string
+		if identical {
+			fmt.Fprintf(cli.writer, "\n--- Comparing ---\n")
+			fmt.Fprintf(cli.writer, "File 1: %s\n", cli.displayName(file1))
+			fmt.Fprintf(cli.writer, "File 2: %s\n", cli.displayName(file2))
+			fmt.Fprintf(cli.writer, "---\n\n")
+			if hardlinked {
+				fmt.Fprintf(cli.writer, "Files are hard links to the same data; they are already deduplicated.\n\n")
+				question = next + ": "
 			} else {
-				// Try reverse order
-				key = fmt.Sprintf("%d-%d", file2Num-1, file1Num-1)
-				if pairIdx, ok := pairMap[key]; ok {
-					pair := pairs[pairIdx]
-					if err := cli.showDiff(pair[1], pair[0]); err != nil {
-						return err
-					}
-				} else {
-					fmt.Fprintf(cli.writer, "No pair found for files %d and %d.\n\n", file1Num, file2Num)
-					return cli.handleGroup(groupNum, group)
-				}
+				fmt.Fprintf(cli.writer, "Files are identical.\n\n")
+				question = fmt.Sprintf("'d' to delete File 2 (%s), 'D' to delete File 1 (%s), 'h' to replace File 2 with a hard link to File 1, %s: ",
+					cli.displayName(file2), cli.displayName(file1), next)
 			}
 		} else {
-			// Try to parse as pair number
-			var pairNum int
-			if _, err := fmt.Sscanf(input, "%d", &pairNum); err != nil || pairNum < 1 || pairNum > len(pairs) {
-				fmt.Fprintf(cli.writer, "Invalid input. Please enter a pair number (1-%d) or file numbers (e.g., '2-3').\n\n", len(pairs))
-				return cli.handleGroup(groupNum, group)
+			if err := cli.showDiff(file1, file2); err != nil {
+				return "", false, err
 			}
-
-			pair := pairs[pairNum-1]
-			if err := cli.showDiff(pair[0], pair[1]); err != nil {
-				return err
+			mode := "'u' for a unified diff"
+			if cli.unified {
+				mode = "'s' for a side-by-side diff"
 			}
+			whitespace := "'w' to ignore whitespace"
+			if cli.diffExec.IgnoresWhitespace() {
+				whitespace = "'w' to show whitespace"
+			}
+			question = mode + ", " + whitespace + ", 'o' to open in " + cli.mergeToolName() + ", " + next + ": "
 		}
 
-		fmt.Fprintf(cli.writer, "\nPress Enter to continue...")
-		cli.scanner.Scan()
+		input, ok, err := cli.prompt(question)
+		if !ok {
+			return "", true, err
+		}
+		switch {
+		case input == "":
+			return "", false, nil
+		case input == "b" && compareAll:
+			return "", true, nil
+		case identical && !hardlinked && (input == "d" || input == "D" || input == "h"):
+			keep, remove := file1, file2
+			if input == "D" {
+				keep, remove = file2, file1
+			}
+			status, changed := cli.resolveIdentical(group, keep, remove, input == "h")
+			fmt.Fprintf(cli.writer, "%s\n\n", status)
+			if !changed {
+				continue
+			}
+			if input == "h" {
+				return "", false, nil
+			}
+			return remove, false, nil
+		case !identical && (input == "u" || input == "s"):
+			cli.unified = input == "u"
+		case !identical && input == "w":
+			cli.diffExec.SetIgnoreWhitespace(!cli.diffExec.IgnoresWhitespace())
+		case !identical && input == "o":
+			cli.openMergeTool(file1, file2)
+		default:
+			fmt.Fprintf(cli.writer, "Unknown choice %q.\n", input)
+		}
+	}
+}
 
-		// Ask if user wants to see another pair in this group
-		fmt.Fprintf(cli.writer, "View another pair in this group? (y/n): ")
-		if !cli.scanner.Scan() {
-			return cli.scanner.Err()
+// resolveIdentical deletes remove, or with hardlink replaces it with a hard link
+// to keep, after checking again that the files are identical and, with --hash,
+// that their checksums are the ones shown. It returns a status message and
+// whether anything was changed.
+func (cli *InteractiveCLI) resolveIdentical(group []string, keep, remove string, hardlink bool) (string, bool) {
+	if err := cli.checksums.Verify(cli.ctx, keep, remove); err != nil {
+		return fmt.Sprintf("%v; nothing was changed.", err), false
+	}
+	if identical, err := filesByteIdentical(keep, remove); err != nil || !identical {
+		return "Files are no longer identical; nothing was changed.", false
+	}
+	if link := symlinkTo(group, remove); link != "" {
+		return fmt.Sprintf("%s is a symbolic link to %s; nothing was changed.", cli.displayName(link), cli.displayName(remove)), false
+	}
+	if hardlink {
+		info, err := os.Stat(remove)
+		if err == nil {
+			err = replaceWithHardlink(keep, remove)
 		}
-		response := cli.scanner.Text()
-		if response == "y" || response == "Y" {
-			return cli.handleGroup(groupNum, group)
+		if err != nil {
+			return fmt.Sprintf("Error creating hard link: %v", err), false
 		}
+		status := fmt.Sprintf("Replaced %s with a hard link to %s.", cli.displayName(remove), cli.displayName(keep))
+		return status + cli.record(hardlinkEntry(keep, remove, info, group)), true
+	}
+	dest, err := cli.quarantine.Remove(remove)
+	if err != nil {
+		return fmt.Sprintf("Error deleting file: %v", err), false
 	}
+	status := cli.quarantine.Describe(cli.displayName(remove)) + "."
+	return status + cli.record(removalEntry(remove, dest, cli.quarantine, group)), true
+}
 
-	return nil
+// record adds e to the session journal. It returns a note for the status line
+// if that failed, since the operation then can't be undone.
+func (cli *InteractiveCLI) record(e JournalEntry) string {
+	if err := cli.journal.Record(e); err != nil {
+		return fmt.Sprintf(" It can't be undone: %v.", err)
+	}
+	return ""
+}
+
+// undo reverses the last operation in the session journal. A restored file
+// rejoins group if it was removed from it; the group is returned either way.
+func (cli *InteractiveCLI) undo(group []string) []string {
+	e, err := cli.journal.Undo()
+	if errors.Is(err, errNothingToUndo) {
+		fmt.Fprintf(cli.writer, "Nothing to undo.\n\n")
+		return group
+	}
+	if err != nil {
+		fmt.Fprintf(cli.writer, "Could not undo: %v.\n\n", err)
+		return group
+	}
+	fmt.Fprintf(cli.writer, "Undone: %s.\n\n", e)
+	if e.Action != JournalHardlink && slices.ContainsFunc(e.Group, func(f string) bool { return f != e.Path && slices.Contains(group, f) }) {
+		group = rejoinGroup(group, e.Path, e.Group)
+	}
+	return group
+}
+
+// mergeToolName returns the name of the configured merge tool.
+func (cli *InteractiveCLI) mergeToolName() string {
+	if cli.mergeTool == nil {
+		return defaultMergeTool
+	}
+	return cli.mergeTool.Name()
+}
+
+// openMergeTool runs the merge tool on the pair in the foreground.
+func (cli *InteractiveCLI) openMergeTool(file1, file2 string) {
+	tool := cli.mergeTool
+	if tool == nil {
+		tool, _ = NewMergeTool("")
+	}
+	cmd := tool.Command(file1, file2)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	err := cmd.Run()
+	fmt.Fprintf(cli.writer, "%s\n", describeMergeToolResult(tool.Name(), err))
+	cli.checksums.Forget()
+}
+
+// showBaseColumns shows every other file of the group in columns next to the
+// group's base file, like the TUI's three-way view.
+func (cli *InteractiveCLI) showBaseColumns(group []string) error {
+	base, ok := groupBase(group)
+	if !ok {
+		fmt.Fprintf(cli.writer, "This group has no base file whose name the others extend.\n\n")
+		return nil
+	}
+	output, err := multiWayDiff(base, removeFile(group, base), 120, cli.diffExec.IgnoresWhitespace())
+	if err != nil {
+		fmt.Fprintf(cli.writer, "\n--- Comparing with base %s ---\n\n", cli.displayName(base))
+		fmt.Fprintf(cli.writer, "Error generating diff: %v\n\n", err)
+	} else {
+		cli.page(fmt.Sprintf("\n--- Comparing with base %s ---\n\n%s\n", cli.displayName(base), output))
+	}
+	_, _, err = cli.prompt("Press Enter to continue...")
+	return err
 }
 
 // generatePairs generates all unique pairs from a group of files.
@@ -155,25 +503,6 @@ func (cli *InteractiveCLI) generatePairs(group []string) [][]string {
 	return pairs
 }
 
-// createPairMap creates a mapping from file index pairs to pair index.
-// Key format: "i-j" where i < j are file indices.
-func (cli *InteractiveCLI) createPairMap(group []string, pairs [][]string) map[string]int {
-	pairMap := make(map[string]int)
-	for pairIdx, pair := range pairs {
-		file1Idx := cli.findFileIndex(group, pair[0])
-		file2Idx := cli.findFileIndex(group, pair[1])
-		// Ensure i < j for consistent key format
-		if file1Idx < file2Idx {
-			key := fmt.Sprintf("%d-%d", file1Idx, file2Idx)
-			pairMap[key] = pairIdx
-		} else {
-			key := fmt.Sprintf("%d-%d", file2Idx, file1Idx)
-			pairMap[key] = pairIdx
-		}
-	}
-	return pairMap
-}
-
 // findFileIndex finds the index of a file in the group.
 func (cli *InteractiveCLI) findFileIndex(group []string, file string) int {
 	for i, f := range group {
@@ -184,18 +513,40 @@ func (cli *InteractiveCLI) findFileIndex(group []string, file string) int {
 	return -1
 }
 
-// showDiff displays a side-by-side diff between two files.
+// showDiff displays a diff between two files, side by side unless unified is set.
 func (cli *InteractiveCLI) showDiff(file1, file2 string) error {
-	fmt.Fprintf(cli.writer, "\n--- Comparing ---\n")
-	fmt.Fprintf(cli.writer, "File 1: %s\n", filepath.Base(file1))
-	fmt.Fprintf(cli.writer, "File 2: %s\n", filepath.Base(file2))
-	fmt.Fprintf(cli.writer, "---\n\n")
+	var s strings.Builder
+	fmt.Fprintf(&s, "\n--- Comparing ---\n")
+	fmt.Fprintf(&s, "File 1: %s\n", cli.displayName(file1))
+	fmt.Fprintf(&s, "File 2: %s\n", cli.displayName(file2))
+	if summary := diffSummary(cli.diffExec, file1, file2); summary != "" {
+		fmt.Fprintf(&s, "%s\n", summary)
+	}
+	fmt.Fprintf(&s, "---\n\n")
 
-	diff, err := cli.diffExec.DiffSideBySide(file1, file2)
+	diff, err := cli.diffExec.ComparePair(file1, file2, cli.unified)
 	if err != nil {
 		return fmt.Errorf("failed to generate diff: %w", err)
 	}
 
-	fmt.Fprintf(cli.writer, "%s\n", diff)
+	fmt.Fprintf(&s, "%s\n", diff)
+	cli.page(s.String())
 	return nil
 }
+
+// page writes text, through the pager when one is set and the text is taller
+// than the terminal. If the pager cannot be started, text is written directly.
+func (cli *InteractiveCLI) page(text string) {
+	if cli.pager != nil && strings.Count(text, "\n") >= pageHeight() {
+		var exitErr *exec.ExitError
+		if err := runPager(cli.ctx, cli.pager, text); err == nil || errors.As(err, &exitErr) {
+			return
+		}
+	}
+	fmt.Fprint(cli.writer, text)
+}
+
+// displayName returns how file is labelled; see displayPath.
+func (cli *InteractiveCLI) displayName(file string) string {
+	return displayPath(file, cli.displayRoot, cli.fullPaths)
+}
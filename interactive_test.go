@@ -3,6 +3,9 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"errors"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -173,3 +176,133 @@ func TestInteractiveCLI_HandleGroup_SingleFileGroup(t *testing.T) {
 func newScannerFromReader(r *strings.Reader) *bufio.Scanner {
 	return bufio.NewScanner(r)
 }
+
+// TestInteractiveCLI_Run_Quit tests that 'q' ends the session without an error.
+func TestInteractiveCLI_Run_Quit(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	groups := [][]string{
+		{createFileWithContent(t, tmpDir, "a.txt", "one\n"), createFileWithContent(t, tmpDir, "a_copy.txt", "two\n")},
+		{createFileWithContent(t, tmpDir, "b.txt", "one\n"), createFileWithContent(t, tmpDir, "b_copy.txt", "two\n")},
+	}
+
+	cli := NewInteractiveCLI(groups, NewDiffExecutor(""))
+	var output bytes.Buffer
+	cli.writer = &output
+	cli.scanner = newScannerFromReader(strings.NewReader("q\n"))
+
+	if err := cli.Run(); err != nil {
+		t.Fatalf("Run() returned error: %v", err)
+	}
+	if strings.Contains(output.String(), "Group 2") {
+		t.Error("Run() should stop after 'q'")
+	}
+}
+
+// TestInteractiveCLI_HandleGroup_DeleteIdentical tests deleting the second of two identical files.
+func TestInteractiveCLI_HandleGroup_DeleteIdentical(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	file1 := createFileWithContent(t, tmpDir, "report.txt", "same\n")
+	file2 := createFileWithContent(t, tmpDir, "report_copy.txt", "same\n")
+
+	cli := NewInteractiveCLI(nil, NewDiffExecutor(""))
+	var output bytes.Buffer
+	cli.writer = &output
+	cli.scanner = newScannerFromReader(strings.NewReader("1\nd\n"))
+
+	if err := cli.handleGroup(1, []string{file1, file2}); err != nil {
+		t.Fatalf("handleGroup() returned error: %v", err)
+	}
+	if _, err := os.Stat(file2); !os.IsNotExist(err) {
+		t.Errorf("%s should have been deleted", file2)
+	}
+	if _, err := os.Stat(file1); err != nil {
+		t.Errorf("%s should be kept: %v", file1, err)
+	}
+	if !strings.Contains(output.String(), "No pairs") {
+		t.Error("handleGroup() should report no pairs left after the deletion")
+	}
+}
+
+// TestInteractiveCLI_HandleGroup_Ignore tests ignoring a group with 'i'.
+func TestInteractiveCLI_HandleGroup_Ignore(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	group := []string{
+		createFileWithContent(t, tmpDir, "notes.txt", "one\n"),
+		createFileWithContent(t, tmpDir, "notes_old.txt", "two\n"),
+	}
+	ignoreList, err := LoadIgnoreList(tmpDir)
+	if err != nil {
+		t.Fatalf("LoadIgnoreList() returned error: %v", err)
+	}
+
+	cli := NewInteractiveCLI(nil, NewDiffExecutor(""))
+	cli.ignoreList = ignoreList
+	var output bytes.Buffer
+	cli.writer = &output
+	cli.scanner = newScannerFromReader(strings.NewReader("i\n"))
+
+	if err := cli.handleGroup(1, group); err != nil {
+		t.Fatalf("handleGroup() returned error: %v", err)
+	}
+	if !ignoreList.Contains(group) {
+		t.Error("group should be in the ignore list")
+	}
+	if _, err := os.Stat(ignoreList.path()); err != nil {
+		t.Errorf("ignore list should be saved: %v", err)
+	}
+}
+
+// TestInteractiveCLI_CompareAll_SkipsIdentical tests that compare all passes
+// over identical pairs and shows the others.
+func TestInteractiveCLI_CompareAll_SkipsIdentical(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	group := []string{
+		createFileWithContent(t, tmpDir, "data.txt", "alpha\n"),
+		createFileWithContent(t, tmpDir, "data_copy.txt", "alpha\n"),
+		createFileWithContent(t, tmpDir, "data_new.txt", "beta\n"),
+	}
+
+	cli := NewInteractiveCLI(nil, NewDiffExecutor(""))
+	var output bytes.Buffer
+	cli.writer = &output
+	cli.scanner = newScannerFromReader(strings.NewReader("\n\n"))
+
+	remaining, err := cli.compareAll(group)
+	if err != nil {
+		t.Fatalf("compareAll() returned error: %v", err)
+	}
+	if len(remaining) != len(group) {
+		t.Errorf("compareAll() removed files: %v", remaining)
+	}
+	if strings.Contains(output.String(), "Files are identical") {
+		t.Error("compareAll() should skip the identical pair")
+	}
+}
+
+// TestInteractiveCLI_Run_Cancelled tests that cancelling the context ends a
+// session waiting for input.
+func TestInteractiveCLI_Run_Cancelled(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	groups := [][]string{{
+		createFileWithContent(t, tmpDir, "a.txt", "one\n"),
+		createFileWithContent(t, tmpDir, "a_copy.txt", "two\n"),
+	}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	cli := NewInteractiveCLI(groups, NewDiffExecutor(""))
+	cli.ctx = ctx
+	cli.writer = io.Discard
+	reader, writer := io.Pipe()
+	defer writer.Close()
+	cli.scanner = bufio.NewScanner(reader)
+
+	if err := cli.Run(); !errors.Is(err, context.Canceled) {
+		t.Errorf("Run() = %v, want context.Canceled", err)
+	}
+}
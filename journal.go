@@ -0,0 +1,339 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// journalEnvVar names the environment variable that overrides the directory
+// holding session journals.
+const journalEnvVar = "DOPPEL_JOURNAL"
+
+// errNothingToUndo is returned by Undo when the journal is empty.
+var errNothingToUndo = errors.New("nothing to undo")
+
+// JournalAction is a file operation recorded in a journal.
+type JournalAction string
+
+const (
+	JournalDelete     JournalAction = "delete"
+	JournalQuarantine JournalAction = "quarantine"
+	JournalRename     JournalAction = "rename"
+	JournalMove       JournalAction = "move"
+	JournalHardlink   JournalAction = "hardlink"
+)
+
+// JournalEntry records one operation on a file, with what is needed to undo it.
+type JournalEntry struct {
+	Action JournalAction `json:"action"`
+	// Path is the file as it was before the operation.
+	Path string `json:"path"`
+	// Target is where the file went for rename, move, and quarantine, and the
+	// kept file it was linked to for hardlink.
+	Target string `json:"target,omitempty"`
+	// Quarantine is the quarantine directory Target is in.
+	Quarantine string `json:"quarantine,omitempty"`
+	// Mode and ModTime are the file's own attributes before it was hard-linked.
+	Mode    fs.FileMode `json:"mode,omitempty"`
+	ModTime time.Time   `json:"mtime,omitzero"`
+	// Group lists the file's group, so a restored file can rejoin it.
+	Group []string  `json:"group,omitempty"`
+	Time  time.Time `json:"time"`
+}
+
+// String describes the operation for status lines, e.g. "renamed a.txt to b.txt".
+func (e JournalEntry) String() string {
+	switch e.Action {
+	case JournalDelete:
+		return "deleted " + e.Path
+	case JournalQuarantine:
+		return "quarantined " + e.Path
+	case JournalRename:
+		return fmt.Sprintf("renamed %s to %s", e.Path, filepath.Base(e.Target))
+	case JournalMove:
+		return fmt.Sprintf("moved %s to %s", e.Path, filepath.Dir(e.Target))
+	case JournalHardlink:
+		return fmt.Sprintf("hard-linked %s to %s", e.Path, e.Target)
+	}
+	return fmt.Sprintf("%s %s", e.Action, e.Path)
+}
+
+// undo reverses the operation. Permanent deletions can't be undone.
+func (e JournalEntry) undo() error {
+	switch e.Action {
+	case JournalDelete:
+		return fmt.Errorf("%s was deleted permanently; use --quarantine to make deletions undoable", e.Path)
+	case JournalQuarantine:
+		return unquarantine(e.Quarantine, e.Target)
+	case JournalRename, JournalMove:
+		return moveFile(e.Target, e.Path)
+	case JournalHardlink:
+		return unlinkCopy(e.Path, e.Mode, e.ModTime)
+	}
+	return fmt.Errorf("unknown action %q", e.Action)
+}
+
+// removalEntry records removing path from group, where q moved it to dest, or
+// deleted it if dest is "".
+func removalEntry(path, dest string, q *Quarantine, group []string) JournalEntry {
+	if dest == "" {
+		return JournalEntry{Action: JournalDelete, Path: path, Group: group}
+	}
+	return JournalEntry{Action: JournalQuarantine, Path: path, Target: dest, Quarantine: q.Dir(), Group: group}
+}
+
+// hardlinkEntry records replacing path, a file of group with the attributes
+// info, by a hard link to keep.
+func hardlinkEntry(keep, path string, info fs.FileInfo, group []string) JournalEntry {
+	return JournalEntry{Action: JournalHardlink, Path: path, Target: keep, Mode: info.Mode(), ModTime: info.ModTime(), Group: group}
+}
+
+// unlinkCopy gives path, a hard link, its own copy of the data again with the
+// given mode and modification time.
+func unlinkCopy(path string, mode fs.FileMode, modTime time.Time) error {
+	tmp := filepath.Join(filepath.Dir(path), fmt.Sprintf(".doppel-unlink-%d", time.Now().UnixNano()))
+	if err := copyFile(path, tmp); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Chmod(tmp, mode.Perm()); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Chtimes(tmp, modTime, modTime); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// Journal records the file operations of one session, so the last one can be
+// undone in the session with u or afterwards with "doppel undo --last".
+// Journals are stored in $DOPPEL_JOURNAL, or doppel/journal in the user cache
+// directory, one JSON Lines file per session, created on the first operation.
+//
+// A nil *Journal is valid and records nothing.
+type Journal struct {
+	path string
+	mu   sync.Mutex
+}
+
+// journalDir returns the directory holding session journals.
+func journalDir() (string, error) {
+	if dir := os.Getenv(journalEnvVar); dir != "" {
+		return dir, nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "doppel", "journal"), nil
+}
+
+// NewJournal returns the journal for a new session. With no known cache
+// directory, journaling is disabled and nil is returned.
+func NewJournal() *Journal {
+	dir, err := journalDir()
+	if err != nil {
+		return nil
+	}
+	// Names sort by start time, so the newest session sorts last
+	name := fmt.Sprintf("%s-%d.jsonl", time.Now().UTC().Format("20060102T150405.000000000"), os.Getpid())
+	return &Journal{path: filepath.Join(dir, name)}
+}
+
+// LastJournal returns the newest session journal that still has operations to
+// undo, or errNothingToUndo if there is none.
+func LastJournal() (*Journal, error) {
+	dir, err := journalDir()
+	if err != nil {
+		return nil, err
+	}
+	names, err := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(names)
+	for _, path := range slices.Backward(names) {
+		j := &Journal{path: path}
+		if entries, err := j.Entries(); err == nil && len(entries) > 0 {
+			return j, nil
+		}
+	}
+	return nil, errNothingToUndo
+}
+
+// Record appends e to the journal, stamping it with the current time.
+func (j *Journal) Record(e JournalEntry) error {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	e.Time = time.Now().UTC()
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(j.path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(j.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Entries returns the recorded operations, oldest first.
+func (j *Journal) Entries() ([]JournalEntry, error) {
+	if j == nil {
+		return nil, nil
+	}
+	f, err := os.Open(j.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []JournalEntry
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var e JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("invalid journal line %d: %w", line, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// Undo reverses the last recorded operation and removes it from the journal.
+// An operation that fails to undo, e.g. because its original path is taken
+// again, stays in the journal to be retried, except for permanent deletions,
+// which are dropped so earlier operations can still be undone.
+func (j *Journal) Undo() (JournalEntry, error) {
+	if j == nil {
+		return JournalEntry{}, errNothingToUndo
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entries, err := j.Entries()
+	if err != nil {
+		return JournalEntry{}, err
+	}
+	if len(entries) == 0 {
+		return JournalEntry{}, errNothingToUndo
+	}
+	last := entries[len(entries)-1]
+	undoErr := last.undo()
+	if undoErr != nil && last.Action != JournalDelete {
+		return last, undoErr
+	}
+	if err := j.write(entries[:len(entries)-1]); err != nil {
+		return last, err
+	}
+	return last, undoErr
+}
+
+// write replaces the journal with entries, removing the file once none are left.
+func (j *Journal) write(entries []JournalEntry) error {
+	if len(entries) == 0 {
+		err := os.Remove(j.path)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(j.path), ".journal-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	enc := json.NewEncoder(tmp)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), j.path)
+}
+
+// rejoinGroup returns group with file added back in the position it had in
+// original, the group as it was when the file was removed.
+func rejoinGroup(group []string, file string, original []string) []string {
+	var joined []string
+	for _, f := range original {
+		if f == file || slices.Contains(group, f) {
+			joined = append(joined, f)
+		}
+	}
+	for _, f := range group {
+		if !slices.Contains(joined, f) {
+			joined = append(joined, f)
+		}
+	}
+	return joined
+}
+
+// runUndo lists the operations of the newest session journal, newest first, or
+// with last undoes the newest of them.
+func runUndo(w io.Writer, last bool) error {
+	j, err := LastJournal()
+	if errors.Is(err, errNothingToUndo) {
+		fmt.Fprintln(w, "Nothing to undo.")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if last {
+		e, err := j.Undo()
+		if err != nil {
+			return fmt.Errorf("could not undo: %w", err)
+		}
+		fmt.Fprintf(w, "Undone: %s\n", e)
+		return nil
+	}
+
+	entries, err := j.Entries()
+	if err != nil {
+		return err
+	}
+	for _, e := range slices.Backward(entries) {
+		fmt.Fprintf(w, "%s  %s\n", e.Time.Local().Format("2006-01-02 15:04:05"), e)
+	}
+	fmt.Fprintln(w, "Run 'doppel undo --last' to undo the first of these.")
+	return nil
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestJournal_UndoRename tests that the last operation is undone first and
+// that an empty journal has nothing to undo.
+func TestJournal_UndoRename(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	t.Setenv(journalEnvVar, filepath.Join(tmpDir, "journal"))
+
+	path := createFileWithContent(t, tmpDir, "a.txt", "a\n")
+	renamed := filepath.Join(tmpDir, "b.txt")
+	moved := filepath.Join(tmpDir, "sub", "b.txt")
+	j := NewJournal()
+	for _, step := range []JournalEntry{
+		{Action: JournalRename, Path: path, Target: renamed},
+		{Action: JournalMove, Path: renamed, Target: moved},
+	} {
+		if err := moveFile(step.Path, step.Target); err != nil {
+			t.Fatal(err)
+		}
+		if err := j.Record(step); err != nil {
+			t.Fatalf("Record() returned error: %v", err)
+		}
+	}
+
+	e, err := j.Undo()
+	if err != nil || e.Action != JournalMove {
+		t.Fatalf("Undo() = %v, %v; expected the move", e, err)
+	}
+	if _, err := os.Stat(renamed); err != nil {
+		t.Errorf("undoing the move should bring back %s: %v", renamed, err)
+	}
+	if _, err := j.Undo(); err != nil {
+		t.Fatalf("Undo() returned error: %v", err)
+	}
+	if content, _ := os.ReadFile(path); string(content) != "a\n" {
+		t.Errorf("content after undoing the rename = %q, expected %q", content, "a\n")
+	}
+	if _, err := j.Undo(); !errors.Is(err, errNothingToUndo) {
+		t.Errorf("Undo() on an empty journal = %v, expected errNothingToUndo", err)
+	}
+}
+
+// TestJournal_UndoQuarantine tests restoring a quarantined file.
+func TestJournal_UndoQuarantine(t *testing.T) {
+	root := createTempDir(t)
+	defer os.RemoveAll(root)
+	qDir := createTempDir(t)
+	defer os.RemoveAll(qDir)
+	t.Setenv(journalEnvVar, filepath.Join(qDir, "journal"))
+
+	path := createFileWithContent(t, root, "a.txt", "a\n")
+	q, err := NewQuarantine(filepath.Join(qDir, "q"), root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dest, err := q.Remove(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j := NewJournal()
+	if err := j.Record(removalEntry(path, dest, q, nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := j.Undo(); err != nil {
+		t.Fatalf("Undo() returned error: %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("undo should restore %s: %v", path, err)
+	}
+	if _, err := os.Stat(filepath.Join(q.Dir(), quarantineManifest)); !os.IsNotExist(err) {
+		t.Error("undo should drop the file from the quarantine manifest")
+	}
+}
+
+// TestJournal_UndoHardlink tests giving a hard-linked file its own data back.
+func TestJournal_UndoHardlink(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	t.Setenv(journalEnvVar, filepath.Join(tmpDir, "journal"))
+
+	keep := createFileWithContent(t, tmpDir, "a.txt", "same\n")
+	path := createFileWithContent(t, tmpDir, "b.txt", "same\n")
+	mtime := time.Date(2024, 1, 30, 12, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := replaceWithHardlink(keep, path); err != nil {
+		t.Fatal(err)
+	}
+	j := NewJournal()
+	if err := j.Record(hardlinkEntry(keep, path, info, nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := j.Undo(); err != nil {
+		t.Fatalf("Undo() returned error: %v", err)
+	}
+	if sameFile(keep, path) {
+		t.Error("undo should break the hard link")
+	}
+	if info, err := os.Stat(path); err != nil || !info.ModTime().Equal(mtime) {
+		t.Errorf("restored file = %v, %v; expected mtime %v", info, err, mtime)
+	}
+}
+
+// TestJournal_UndoDelete tests that a permanent deletion is reported and
+// dropped, so earlier operations can still be undone.
+func TestJournal_UndoDelete(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	t.Setenv(journalEnvVar, filepath.Join(tmpDir, "journal"))
+
+	j := NewJournal()
+	if err := j.Record(removalEntry(filepath.Join(tmpDir, "gone.txt"), "", nil, nil)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := j.Undo(); err == nil || !strings.Contains(err.Error(), "--quarantine") {
+		t.Errorf("Undo() error = %v, expected a hint to use --quarantine", err)
+	}
+	if entries, _ := j.Entries(); len(entries) != 0 {
+		t.Errorf("entries = %v, expected the deletion dropped", entries)
+	}
+}
+
+// TestRunUndo tests listing and undoing the last session after it ended.
+func TestRunUndo(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	t.Setenv(journalEnvVar, filepath.Join(tmpDir, "journal"))
+
+	var out bytes.Buffer
+	if err := runUndo(&out, true); err != nil || !strings.Contains(out.String(), "Nothing to undo") {
+		t.Errorf("runUndo() without journals = %v, output %q", err, out.String())
+	}
+
+	path := createFileWithContent(t, tmpDir, "a.txt", "a\n")
+	renamed := filepath.Join(tmpDir, "b.txt")
+	if err := os.Rename(path, renamed); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewJournal().Record(JournalEntry{Action: JournalRename, Path: path, Target: renamed}); err != nil {
+		t.Fatal(err)
+	}
+
+	out.Reset()
+	if err := runUndo(&out, false); err != nil || !strings.Contains(out.String(), "renamed "+path+" to b.txt") {
+		t.Errorf("runUndo() listing = %v, output %q", err, out.String())
+	}
+	out.Reset()
+	if err := runUndo(&out, true); err != nil {
+		t.Fatalf("runUndo() returned error: %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("undo --last should rename the file back: %v", err)
+	}
+}
+
+// TestRejoinGroup tests that a restored file gets its old position back.
+func TestRejoinGroup(t *testing.T) {
+	original := []string{"a", "b", "c"}
+	if got := rejoinGroup([]string{"a", "c", "d"}, "b", original); !reflect.DeepEqual(got, []string{"a", "b", "c", "d"}) {
+		t.Errorf("rejoinGroup() = %v, expected [a b c d]", got)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// junitTestSuites is the root element of a JUnit XML report.
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+// junitTestSuite holds one test case per group.
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+// junitTestCase is a group, or the passing case of a scan without groups.
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+// junitFailure describes a group: a one-line message and its files.
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",cdata"`
+}
+
+// junitClassName is the classname of every test case, under which CI systems
+// list the groups.
+const junitClassName = "doppel"
+
+// writeJUnitReport writes the records of a scan of dir as a JUnit XML report
+// with a failed test case per group, named after its leader, so CI systems
+// can fail a build when similar files are introduced and list the groups in
+// their test views. A scan without groups has a single passing test case.
+func writeJUnitReport(w io.Writer, dir string, records []FileRecord) error {
+	suite := junitTestSuite{Name: "similar files " + dir}
+	root := dir
+	if !isRemoteLocation(dir) {
+		root = absPath(dir)
+	}
+	for _, group := range recordGroups(records) {
+		leader := group[0]
+		identical := 0
+		var text strings.Builder
+		for _, r := range group {
+			status := "different"
+			if r.Leader {
+				status = "leader"
+			} else if r.HardlinkToLeader {
+				status = "hardlink"
+			} else if r.IdenticalToLeader {
+				status = "identical"
+				identical++
+			}
+			fmt.Fprintf(&text, "%-9s %10s  %s\n", status, formatBytes(r.Size), r.Path)
+		}
+		message := fmt.Sprintf("%d similar files", len(group))
+		if identical > 0 {
+			message += fmt.Sprintf(", %d identical to the leader", identical)
+		}
+		if leader.Fingerprint != "" {
+			message += " (fingerprint " + leader.Fingerprint + ")"
+		}
+		suite.Cases = append(suite.Cases, junitTestCase{
+			Name:      fmt.Sprintf("Group %d: %s", leader.Group, displayPath(leader.Path, root, true)),
+			ClassName: junitClassName,
+			Failure:   &junitFailure{Message: message, Type: "similar files", Text: text.String()},
+		})
+	}
+	suite.Tests, suite.Failures = len(suite.Cases), len(suite.Cases)
+	if len(suite.Cases) == 0 {
+		suite.Cases = []junitTestCase{{Name: "No groups of similar files", ClassName: junitClassName}}
+		suite.Tests = 1
+	}
+
+	report := junitTestSuites{
+		Name:     "doppel",
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Suites:   []junitTestSuite{suite},
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestWriteJUnitReport tests a failed test case per group.
+func TestWriteJUnitReport(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	records := []FileRecord{
+		{Group: 1, Path: filepath.Join(tmpDir, "notes.md"), Size: 3, Hash: "aa", Leader: true, Fingerprint: "af021d6738113403"},
+		{Group: 1, Path: filepath.Join(tmpDir, "notes (1).md"), Size: 3, Hash: "aa", IdenticalToLeader: true, Fingerprint: "af021d6738113403"},
+		{Group: 2, Path: filepath.Join(tmpDir, "a&b.md"), Size: 5, Hash: "bb", Leader: true},
+		{Group: 2, Path: filepath.Join(tmpDir, "a&b-1.md"), Size: 9, Hash: "cc"},
+	}
+
+	var out bytes.Buffer
+	if err := writeJUnitReport(&out, tmpDir, records); err != nil {
+		t.Fatalf("writeJUnitReport() returned error: %v", err)
+	}
+	var report junitTestSuites
+	if err := xml.Unmarshal(out.Bytes(), &report); err != nil {
+		t.Fatalf("output is not valid XML: %v\n%s", err, out.String())
+	}
+	if report.Tests != 2 || report.Failures != 2 || len(report.Suites) != 1 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+
+	cases := report.Suites[0].Cases
+	if cases[0].Name != "Group 1: notes.md" || cases[1].Name != "Group 2: a&b.md" {
+		t.Errorf("test cases should be named after their leaders: %q, %q", cases[0].Name, cases[1].Name)
+	}
+	if f := cases[0].Failure; f == nil || f.Message != "2 similar files, 1 identical to the leader (fingerprint af021d6738113403)" {
+		t.Errorf("unexpected failure: %+v", f)
+	}
+	if f := cases[1].Failure; f == nil || !strings.Contains(f.Text, "different        9 B  "+records[3].Path+"\n") {
+		t.Errorf("failure should list the files of the group: %+v", f)
+	}
+}
+
+// TestWriteJUnitReport_NoGroups tests that a clean scan passes.
+func TestWriteJUnitReport_NoGroups(t *testing.T) {
+	var out bytes.Buffer
+	if err := writeJUnitReport(&out, "/notes", nil); err != nil {
+		t.Fatalf("writeJUnitReport() returned error: %v", err)
+	}
+	var report junitTestSuites
+	if err := xml.Unmarshal(out.Bytes(), &report); err != nil {
+		t.Fatalf("output is not valid XML: %v", err)
+	}
+	if report.Tests != 1 || report.Failures != 0 || report.Suites[0].Cases[0].Failure != nil {
+		t.Errorf("a scan without groups should have one passing test case: %s", out.String())
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// KeepPolicy decides which of several byte-identical files is kept when the
+// others are removed or hard-linked. It implements flag.Value.
+type KeepPolicy string
+
+const (
+	// KeepFirst keeps the group leader, the first file in group order.
+	KeepFirst KeepPolicy = "first"
+	// KeepNewest keeps the most recently modified file.
+	KeepNewest KeepPolicy = "newest"
+	// KeepOldest keeps the least recently modified file, usually the original.
+	KeepOldest KeepPolicy = "oldest"
+	// KeepLargest keeps the largest file, which for differing versions is
+	// often the one with the most content.
+	KeepLargest KeepPolicy = "largest"
+	// KeepShortestName keeps the file with the shortest base name, which is
+	// rarely the one with a "copy" or "-1" marker.
+	KeepShortestName KeepPolicy = "shortest-name"
+)
+
+// keepPolicies lists the policies in the order they are documented.
+var keepPolicies = []KeepPolicy{KeepFirst, KeepNewest, KeepOldest, KeepLargest, KeepShortestName}
+
+// String returns the policy name, "first" if unset.
+func (p *KeepPolicy) String() string {
+	if *p == "" {
+		return string(KeepFirst)
+	}
+	return string(*p)
+}
+
+// Set parses a policy name.
+func (p *KeepPolicy) Set(value string) error {
+	for _, policy := range keepPolicies {
+		if value == string(policy) {
+			*p = policy
+			return nil
+		}
+	}
+	names := make([]string, len(keepPolicies))
+	for i, policy := range keepPolicies {
+		names[i] = string(policy)
+	}
+	return fmt.Errorf("unknown keep policy %q (use %s)", value, strings.Join(names, ", "))
+}
+
+// pick returns the index of the file to keep among files, or -1 if all of
+// them are symbolic links. Links are never kept in place of a real file. Ties
+// go to the file that comes first, so KeepFirst and unset policies keep the
+// first real file.
+func (p KeepPolicy) pick(files []FileRecord) int {
+	return Keeper{Policy: p}.pick(files)
+}
+
+// better reports whether a should be kept rather than b.
+func (p KeepPolicy) better(a, b FileRecord) bool {
+	switch p {
+	case KeepNewest:
+		return a.ModTime.After(b.ModTime)
+	case KeepOldest:
+		return a.ModTime.Before(b.ModTime)
+	case KeepLargest:
+		return a.Size > b.Size
+	case KeepShortestName:
+		return utf8.RuneCountInString(filepath.Base(a.Path)) < utf8.RuneCountInString(filepath.Base(b.Path))
+	}
+	return false
+}
+
+// describe explains the choice in logs, e.g. "the newest file".
+func (p KeepPolicy) describe() string {
+	switch p {
+	case KeepNewest:
+		return "the newest file"
+	case KeepOldest:
+		return "the oldest file"
+	case KeepLargest:
+		return "the largest file"
+	case KeepShortestName:
+		return "the file with the shortest name"
+	}
+	return "the first file of the group"
+}
+
+// Keeper decides which file of a group to keep. Files under an earlier
+// directory of Prefer are kept over files under a later one, then files under
+// none of Prefer or Avoid, then files under Avoid, again in order. Among
+// equally ranked files, Policy decides.
+type Keeper struct {
+	Policy KeepPolicy
+	Prefer []string
+	Avoid  []string
+}
+
+// pathRank ranks path by the path rules; lower ranks are kept first.
+func (k Keeper) pathRank(path string) int {
+	path = absPath(path)
+	for i, dir := range k.Prefer {
+		if pathWithin(absPath(expandHome(dir)), path) {
+			return i
+		}
+	}
+	for i, dir := range k.Avoid {
+		if pathWithin(absPath(expandHome(dir)), path) {
+			return len(k.Prefer) + 1 + i
+		}
+	}
+	return len(k.Prefer)
+}
+
+// describe explains the choice in logs, e.g. "the newest file, preferring
+// files under ~/Notes".
+func (k Keeper) describe() string {
+	s := k.Policy.describe()
+	if len(k.Prefer) > 0 {
+		s += ", preferring files under " + strings.Join(k.Prefer, ", then ")
+	}
+	if len(k.Avoid) > 0 {
+		s += ", avoiding files under " + strings.Join(k.Avoid, ", then ")
+	}
+	return s
+}
+
+// isDefault reports whether k keeps the group leader, as clean did before
+// keep policies.
+func (k Keeper) isDefault() bool {
+	return (k.Policy == "" || k.Policy == KeepFirst) && len(k.Prefer) == 0 && len(k.Avoid) == 0
+}
+
+// pick returns the index of the file to keep among files, or -1 if all of
+// them are symbolic links. Links are left out before the path rules and the
+// policy run, so neither keeps a link and removes the file it points to. A
+// file a link points to has to stay anyway, so when there is one, only such
+// files are considered.
+func (k Keeper) pick(files []FileRecord) int {
+	targets := linkTargets(files)
+	best := -1
+	for i, f := range files {
+		if f.Symlink || (len(targets) > 0 && !targets[f.Path]) {
+			continue
+		}
+		if best < 0 {
+			best = i
+			continue
+		}
+		ri, rb := k.pathRank(f.Path), k.pathRank(files[best].Path)
+		if ri < rb || (ri == rb && k.Policy.better(f, files[best])) {
+			best = i
+		}
+	}
+	return best
+}
+
+// linkTargets returns the paths of the files of group that a symbolic link in
+// group points to, compared with os.SameFile so any member counts, not just
+// the leader. Such files must stay, or the link would be left dangling.
+func linkTargets(group []FileRecord) map[string]bool {
+	var resolved []os.FileInfo
+	for _, r := range group {
+		if r.Symlink {
+			if info, err := os.Stat(r.Path); err == nil {
+				resolved = append(resolved, info)
+			}
+		}
+	}
+	targets := make(map[string]bool)
+	if len(resolved) == 0 {
+		return targets
+	}
+	for _, r := range group {
+		if r.Symlink {
+			continue
+		}
+		info, err := os.Lstat(r.Path)
+		if err != nil {
+			continue
+		}
+		for _, target := range resolved {
+			if os.SameFile(info, target) {
+				targets[r.Path] = true
+			}
+		}
+	}
+	return targets
+}
+
+// Suggest returns the file of group to keep, or "" if a file can't be read or
+// every file is a symbolic link.
+func (k Keeper) Suggest(group []string) string {
+	files := make([]FileRecord, len(group))
+	for i, path := range group {
+		info, err := os.Stat(path)
+		if err != nil {
+			return ""
+		}
+		link, err := os.Lstat(path)
+		if err != nil {
+			return ""
+		}
+		files[i] = FileRecord{Path: path, Size: info.Size(), ModTime: info.ModTime(), Symlink: link.Mode()&os.ModeSymlink != 0}
+	}
+	if i := k.pick(files); i >= 0 {
+		return group[i]
+	}
+	return ""
+}
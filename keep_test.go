@@ -0,0 +1,123 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestKeepPolicy_Pick tests which file each policy keeps, with ties going to
+// the first file.
+func TestKeepPolicy_Pick(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+	files := []FileRecord{
+		{Path: "/notes/notes-1.txt", Size: 10, ModTime: day(2)},
+		{Path: "/notes/notes.txt", ModTime: day(3)},
+		{Path: "/notes/copy/notes.txt", ModTime: day(1)},
+		{Path: "/notes/notes (2).txt", ModTime: day(3)},
+	}
+
+	tests := []struct {
+		policy   KeepPolicy
+		expected int
+	}{
+		{"", 0},
+		{KeepFirst, 0},
+		{KeepNewest, 1},
+		{KeepOldest, 2},
+		{KeepLargest, 0},
+		{KeepShortestName, 1},
+	}
+	for _, tt := range tests {
+		if got := tt.policy.pick(files); got != tt.expected {
+			t.Errorf("%q.pick() = %d, expected %d", tt.policy, got, tt.expected)
+		}
+	}
+}
+
+// TestKeepPolicy_Set tests parsing policy names.
+func TestKeepPolicy_Set(t *testing.T) {
+	var p KeepPolicy
+	if p.String() != "first" {
+		t.Errorf("String() = %q for an unset policy, expected first", p.String())
+	}
+	if err := p.Set("newest"); err != nil || p != KeepNewest {
+		t.Errorf("Set(newest) = %v, policy %q", err, p)
+	}
+	if err := p.Set("biggest"); err == nil {
+		t.Error("Set() should reject unknown policies")
+	}
+}
+
+// TestKeeper_Pick tests that preferred directories come before the policy.
+func TestKeeper_Pick(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+	files := []FileRecord{
+		{Path: "/home/me/Downloads/cv.pdf", ModTime: day(3)},
+		{Path: "/home/me/Notes/old/cv.pdf", ModTime: day(1)},
+		{Path: "/home/me/Notes/cv.pdf", ModTime: day(2)},
+	}
+
+	k := Keeper{Policy: KeepNewest}
+	if got := k.pick(files); got != 0 {
+		t.Errorf("pick() without paths = %d, expected the newest file", got)
+	}
+	k.Prefer = []string{"/home/me/Notes", "/home/me/Downloads"}
+	if got := k.pick(files); got != 2 {
+		t.Errorf("pick() = %d, expected the newest file under the preferred directory", got)
+	}
+
+	// Avoided directories rank after files under no rule
+	files = append(files, FileRecord{Path: "/home/me/Desktop/cv.pdf", ModTime: day(1)})
+	k = Keeper{Policy: KeepNewest, Avoid: []string{"/home/me/Downloads", "/home/me/Notes"}}
+	if got := k.pick(files); got != 3 {
+		t.Errorf("pick() with avoided directories = %d, expected the file outside them", got)
+	}
+}
+
+// TestKeeper_PickSymlink tests that symbolic links are left out before the
+// path rules run, so a link under a preferred directory isn't kept in place
+// of the file it points to.
+func TestKeeper_PickSymlink(t *testing.T) {
+	files := []FileRecord{
+		{Path: "/home/me/Downloads/cv.pdf"},
+		{Path: "/home/me/Notes/cv.pdf", Symlink: true},
+	}
+	k := Keeper{Prefer: []string{"/home/me/Notes"}}
+	if got := k.pick(files); got != 0 {
+		t.Errorf("pick() = %d, expected the real file rather than the preferred link", got)
+	}
+	k = Keeper{Avoid: []string{"/home/me/Downloads"}}
+	if got := k.pick(files); got != 0 {
+		t.Errorf("pick() = %d, expected the avoided real file rather than the link", got)
+	}
+	if got := k.pick(files[1:]); got != -1 {
+		t.Errorf("pick() of only links = %d, expected -1", got)
+	}
+}
+
+// TestKeeper_SuggestSymlink tests that Suggest never suggests keeping a link.
+func TestKeeper_SuggestSymlink(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	for _, dir := range []string{"Downloads", "Notes"} {
+		if err := os.Mkdir(filepath.Join(tmpDir, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	target := createFileWithContent(t, tmpDir, filepath.Join("Downloads", "cv.txt"), "same\n")
+	link := filepath.Join(tmpDir, "Notes", "cv.txt")
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	other := createFileWithContent(t, tmpDir, "cv.txt", "same\n")
+
+	// The link's target stays for the link, so it is suggested over the
+	// preferred link and the copy with the shorter path
+	k := Keeper{Policy: KeepShortestName, Prefer: []string{filepath.Join(tmpDir, "Notes")}}
+	if got := k.Suggest([]string{other, target, link}); got != target {
+		t.Errorf("Suggest() = %q, expected the link's target %q", got, target)
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// keyBinding is a key of the TUI and what it does, as listed by the help
+// screen that ? opens.
+type keyBinding struct {
+	keys, action string
+}
+
+// keySection lists the keys of one screen. A section without states applies
+// everywhere.
+type keySection struct {
+	title    string
+	states   []TUIState
+	bindings []keyBinding
+}
+
+// keySections is every key binding of the TUI, by screen. The one-line hint
+// under each screen only names the most common of them.
+var keySections = []keySection{
+	{
+		title:  "Group list",
+		states: []TUIState{stateSelectGroup},
+		bindings: []keyBinding{
+			{"Enter", "open the highlighted group"},
+			{"Tab", "expand or collapse its file list"},
+			{"z", "expand or collapse every group"},
+			{"a", "compare all pairs of the group"},
+			{"n", "move to the next group"},
+			{"v", "toggle the reviewed marker"},
+			{"i", "ignore the group on future runs"},
+			{"J", "pick the group to merge, then merge it into another"},
+			{"A", "run a custom action on the group's first file"},
+		},
+	},
+	{
+		title:  "File selection",
+		states: []TUIState{stateSelectFirstFile, stateSelectSecondFile},
+		bindings: []keyBinding{
+			{"Enter", "pick the file to compare"},
+			{"Space", "select the file for a bulk action"},
+			{"d", "delete the selected files"},
+			{"M", "move the selected files, or the highlighted one"},
+			{"S", "split the selected files, or the highlighted one, off into a group of their own"},
+			{"h", "hard-link the selected files to the highlighted one"},
+			{"m", "mark the file for a multi-file comparison"},
+			{"c", "compare the marked files in columns"},
+			{"a", "compare all pairs of the group"},
+			{"3", "diff against the group's base file"},
+			{"H", "diff the file against its last committed version (git)"},
+			{"L", "diff the file against a recent revision (git)"},
+			{"o", "open the pair in the merge tool (second file)"},
+			{"E", "explain why the pair is grouped (second file)"},
+			{"e", "edit the file in $EDITOR"},
+			{"r", "rename the file"},
+			{"x", "open the file in the system viewer"},
+			{"y", "copy the file's path"},
+			{"A", "run a custom action on the file"},
+		},
+	},
+	{
+		title:  "Diff view",
+		states: []TUIState{stateViewDiff},
+		bindings: []keyBinding{
+			{"↑/↓ PgUp/PgDn g/G", "scroll the diff"},
+			{"←/→", "scroll sideways"},
+			{"n/p", "next or previous change"},
+			{"/", "search; then n/N for the next or previous match"},
+			{"u/s", "unified or side-by-side diff"},
+			{"w", "ignore or show whitespace"},
+			{"3", "diff against the group's base file"},
+			{"o", "open the pair in the merge tool"},
+			{"E", "explain why the pair is grouped"},
+			{"d/D", "delete File 2 or File 1 (identical files, or frontmatter-only changes)"},
+			{"h", "replace File 2 with a hard link to File 1 (identical files)"},
+			{"Enter", "next pair, or pick another pair"},
+		},
+	},
+	{
+		title: "Everywhere",
+		bindings: []keyBinding{
+			{"↑/↓ j/k", "move the cursor"},
+			{"PgUp/PgDn", "move a page"},
+			{"g/G Home/End", "jump to the first or last item"},
+			{"u", "undo the last deletion, rename, move, or hard link"},
+			{"p", "switch between base names and full paths"},
+			{"Esc", "go back, or clear the selection"},
+			{"?", "show this help"},
+			{"q", "quit"},
+		},
+	},
+}
+
+// helpOverlayMinSideBySide is the terminal width from which the help screen
+// lays its sections out in two columns.
+const helpOverlayMinSideBySide = 100
+
+// renderKeyHelp renders the help screen for a terminal of the given size,
+// starting with the keys of the screen in state.
+func renderKeyHelp(state TUIState, width, height int) string {
+	var sections, others []keySection
+	for _, section := range keySections {
+		if slices.Contains(section.states, state) {
+			sections = append(sections, section)
+		} else {
+			others = append(others, section)
+		}
+	}
+	sections = append(sections, others...)
+
+	columns := 1
+	if width >= helpOverlayMinSideBySide {
+		columns = 2
+	}
+	colWidth := width / columns
+	blocks := make([][]string, len(sections))
+	total := 0
+	for i, section := range sections {
+		blocks[i] = renderKeySection(section, colWidth-2)
+		total += len(blocks[i]) + 1
+	}
+
+	// Fill the first column with about half of the lines, keeping sections whole
+	var cols [2][]string
+	col := 0
+	for _, block := range blocks {
+		if columns == 2 && col == 0 && len(cols[0]) > 0 && len(cols[0])+len(block) > total/2+1 {
+			col = 1
+		}
+		cols[col] = append(cols[col], block...)
+		cols[col] = append(cols[col], "")
+	}
+
+	var s strings.Builder
+	s.WriteString(titleStyle.Render("Keys"))
+	s.WriteString("\n\n")
+	body := strings.Join(cols[0], "\n")
+	if columns == 2 {
+		left := lipgloss.NewStyle().Width(colWidth).Render(body)
+		body = lipgloss.JoinHorizontal(lipgloss.Top, left, strings.Join(cols[1], "\n"))
+	}
+	lines := strings.Split(strings.TrimRight(body, "\n"), "\n")
+	// Leave room for the title and the closing hint
+	if room := max(height-4, 1); len(lines) > room {
+		lines = append(lines[:room-1], helpStyle.Render("… (enlarge the terminal to see all keys)"))
+	}
+	s.WriteString(strings.Join(lines, "\n"))
+	s.WriteString("\n\n")
+	s.WriteString(helpStyle.Render("Press any key to close this help"))
+	return s.String()
+}
+
+// renderKeySection renders a section title and its keys in aligned columns,
+// cutting lines to width.
+func renderKeySection(section keySection, width int) []string {
+	keyWidth := 0
+	for _, b := range section.bindings {
+		keyWidth = max(keyWidth, lipgloss.Width(b.keys))
+	}
+	lines := []string{titleStyle.Render(section.title)}
+	for _, b := range section.bindings {
+		line := fmt.Sprintf("  %s%s  %s", b.keys, strings.Repeat(" ", keyWidth-lipgloss.Width(b.keys)), b.action)
+		lines = append(lines, truncateLine(line, width))
+	}
+	return lines
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestRenderKeyHelp tests that the help screen starts with the keys of the
+// current screen and fits the terminal.
+func TestRenderKeyHelp(t *testing.T) {
+	help := renderKeyHelp(stateViewDiff, 80, 100)
+	diff, group := strings.Index(help, "Diff view"), strings.Index(help, "Group list")
+	if diff < 0 || group < 0 || diff > group {
+		t.Errorf("the diff view's keys should come first:\n%s", help)
+	}
+	for _, want := range []string{"Everywhere", "search; then n/N", "Press any key to close this help"} {
+		if !strings.Contains(help, want) {
+			t.Errorf("help should contain %q:\n%s", want, help)
+		}
+	}
+
+	// Wide terminals get two columns, so fewer lines
+	narrow := strings.Count(renderKeyHelp(stateSelectGroup, 80, 100), "\n")
+	wide := strings.Count(renderKeyHelp(stateSelectGroup, 140, 100), "\n")
+	if wide >= narrow {
+		t.Errorf("help has %d lines at 140 columns and %d at 80; expected two columns to be shorter", wide, narrow)
+	}
+
+	short := renderKeyHelp(stateSelectGroup, 80, 12)
+	if lines := strings.Count(short, "\n") + 1; lines > 12 || !strings.Contains(short, "enlarge the terminal") {
+		t.Errorf("help is %d lines for a 12-line terminal:\n%s", lines, short)
+	}
+}
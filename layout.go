@@ -0,0 +1,46 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// narrowWidth is the terminal width below which the TUI switches to its narrow
+// layout: diffs are shown unified, since two columns of under 40 characters
+// hardly fit a line of text.
+const narrowWidth = 80
+
+// fitWidth cuts a rendered line to width columns, ending it with an ellipsis,
+// so that the terminal doesn't wrap it onto the next line. Colors are kept. A
+// width below 1 leaves the line as it is.
+func fitWidth(line string, width int) string {
+	if width < 1 || lipgloss.Width(line) <= width {
+		return line
+	}
+	return ansi.Truncate(line, width, "…")
+}
+
+// wrapHelp breaks a hint line such as "↑/↓: navigate  q: quit" into lines of at
+// most width columns, between its two-space separated entries rather than
+// inside one. An entry wider than width gets a line of its own, cut to fit.
+func wrapHelp(help string, width int) []string {
+	if width < 1 || lipgloss.Width(help) <= width {
+		return []string{help}
+	}
+	var lines []string
+	line := ""
+	for _, entry := range strings.Split(help, "  ") {
+		switch {
+		case line == "":
+			line = entry
+		case lipgloss.Width(line)+2+lipgloss.Width(entry) <= width:
+			line += "  " + entry
+		default:
+			lines = append(lines, fitWidth(line, width))
+			line = entry
+		}
+	}
+	return append(lines, fitWidth(line, width))
+}
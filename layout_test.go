@@ -0,0 +1,40 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// TestFitWidth tests cutting rendered lines to the terminal width.
+func TestFitWidth(t *testing.T) {
+	if got := fitWidth("notes.md", 20); got != "notes.md" {
+		t.Errorf("fitWidth() of a short line = %q", got)
+	}
+	if got := fitWidth("/home/me/notes/notes 2.md", 10); got != "/home/me/…" {
+		t.Errorf("fitWidth() = %q, expected /home/me/…", got)
+	}
+	styled := titleStyle.Render("/home/me/notes/notes 2.md")
+	if got := fitWidth(styled, 10); lipgloss.Width(got) != 10 {
+		t.Errorf("fitWidth() of a styled line is %d columns wide, expected 10", lipgloss.Width(got))
+	}
+	if got := fitWidth("notes.md", 0); got != "notes.md" {
+		t.Errorf("fitWidth() without a width = %q", got)
+	}
+}
+
+// TestWrapHelp tests that hint lines wrap between their entries.
+func TestWrapHelp(t *testing.T) {
+	help := "↑/↓: navigate  Enter: select file  Esc: back  q: quit"
+	if got := wrapHelp(help, 80); !reflect.DeepEqual(got, []string{help}) {
+		t.Errorf("wrapHelp() of a line that fits = %q", got)
+	}
+	want := []string{"↑/↓: navigate", "Enter: select file", "Esc: back  q: quit"}
+	if got := wrapHelp(help, 20); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrapHelp() = %q, expected %q", got, want)
+	}
+	if got := wrapHelp(help, 10); got[1] != "Enter: se…" {
+		t.Errorf("an entry wider than the terminal should be cut, got %q", got)
+	}
+}
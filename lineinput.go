@@ -0,0 +1,76 @@
+package main
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// lineInput is a single-line text field for prompts inside the TUI, such as the
+// new name of a file being renamed.
+type lineInput struct {
+	prompt string
+	value  []rune
+	// pos is the cursor position, an index into value.
+	pos int
+}
+
+// newLineInput returns a field showing prompt, pre-filled with value and the
+// cursor at its end.
+func newLineInput(prompt, value string) lineInput {
+	runes := []rune(value)
+	return lineInput{prompt: prompt, value: runes, pos: len(runes)}
+}
+
+// Value returns the text entered.
+func (in lineInput) Value() string {
+	return string(in.value)
+}
+
+// update applies an editing key: typing, Backspace and Delete, moving with
+// ←/→ and Home/End (or Ctrl+A/Ctrl+E), and Ctrl+U to clear before the cursor.
+// Other keys are ignored.
+func (in lineInput) update(msg tea.KeyMsg) lineInput {
+	switch msg.Type {
+	case tea.KeyRunes, tea.KeySpace:
+		runes := msg.Runes
+		if msg.Type == tea.KeySpace {
+			runes = []rune{' '}
+		}
+		value := make([]rune, 0, len(in.value)+len(runes))
+		value = append(value, in.value[:in.pos]...)
+		value = append(value, runes...)
+		in.value = append(value, in.value[in.pos:]...)
+		in.pos += len(runes)
+	case tea.KeyBackspace:
+		if in.pos > 0 {
+			in.value = append(in.value[:in.pos-1:in.pos-1], in.value[in.pos:]...)
+			in.pos--
+		}
+	case tea.KeyDelete:
+		if in.pos < len(in.value) {
+			in.value = append(in.value[:in.pos:in.pos], in.value[in.pos+1:]...)
+		}
+	case tea.KeyLeft:
+		in.pos = max(in.pos-1, 0)
+	case tea.KeyRight:
+		in.pos = min(in.pos+1, len(in.value))
+	case tea.KeyHome, tea.KeyCtrlA:
+		in.pos = 0
+	case tea.KeyEnd, tea.KeyCtrlE:
+		in.pos = len(in.value)
+	case tea.KeyCtrlU:
+		in.value = in.value[in.pos:]
+		in.pos = 0
+	}
+	return in
+}
+
+// View renders the prompt and the text with the cursor shown in reverse video.
+func (in lineInput) View() string {
+	under := " "
+	rest := ""
+	if in.pos < len(in.value) {
+		under = string(in.value[in.pos])
+		rest = string(in.value[in.pos+1:])
+	}
+	return titleStyle.Render(in.prompt) + string(in.value[:in.pos]) + cursorStyle.Render(under) + rest
+}
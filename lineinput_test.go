@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// TestLineInput tests editing a pre-filled value.
+func TestLineInput(t *testing.T) {
+	runes := func(s string) tea.KeyMsg { return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)} }
+	key := func(k tea.KeyType) tea.KeyMsg { return tea.KeyMsg{Type: k} }
+
+	tests := []struct {
+		name string
+		keys []tea.KeyMsg
+		want string
+	}{
+		{"Pre-filled", nil, "notes 2.txt"},
+		{"Type at end", []tea.KeyMsg{runes(".bak")}, "notes 2.txt.bak"},
+		{"Backspace", []tea.KeyMsg{key(tea.KeyBackspace), key(tea.KeyBackspace)}, "notes 2.t"},
+		{"Edit in the middle", []tea.KeyMsg{key(tea.KeyHome), key(tea.KeyRight), key(tea.KeyRight), key(tea.KeyRight), key(tea.KeyRight), key(tea.KeyRight), key(tea.KeyDelete), key(tea.KeyDelete), key(tea.KeyBackspace), runes("e")}, "notee.txt"},
+		{"Space", []tea.KeyMsg{key(tea.KeyHome), runes("a"), key(tea.KeySpace)}, "a notes 2.txt"},
+		{"Clear before cursor", []tea.KeyMsg{key(tea.KeyLeft), key(tea.KeyLeft), key(tea.KeyLeft), key(tea.KeyLeft), key(tea.KeyCtrlU), runes("notes")}, "notes.txt"},
+		{"Multi-byte", []tea.KeyMsg{key(tea.KeyCtrlE), runes("é"), key(tea.KeyLeft), key(tea.KeyBackspace)}, "notes 2.txé"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := newLineInput("Rename to: ", "notes 2.txt")
+			for _, k := range tt.keys {
+				in = in.update(k)
+			}
+			if got := in.Value(); got != tt.want {
+				t.Errorf("Value() = %q, expected %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"sync"
+)
+
+// logger records what doppel decides and runs: files skipped while scanning
+// and why, files the suffix filter excludes, files the matcher merges or
+// leaves out of every group, and the external commands it executes. Warnings
+// and errors are shown by default; --verbose adds the commands and totals,
+// --debug every decision about a file, and -q keeps only errors.
+var logger = slog.New(slog.NewTextHandler(logOutput, &slog.HandlerOptions{Level: logLevel, ReplaceAttr: dropTimeOnStderr}))
+
+var (
+	logLevel  = newLevelVar(slog.LevelWarn)
+	logOutput = &heldWriter{w: os.Stderr}
+)
+
+// newLevelVar returns a LevelVar set to level.
+func newLevelVar(level slog.Level) *slog.LevelVar {
+	v := new(slog.LevelVar)
+	v.Set(level)
+	return v
+}
+
+// dropTimeOnStderr leaves the time out of records read off the terminal as
+// they happen; a log file keeps it.
+func dropTimeOnStderr(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) == 0 && a.Key == slog.TimeKey && logOutput.toStderr() {
+		return slog.Attr{}
+	}
+	return a
+}
+
+// heldWriter writes log records to w, or while held, keeps them until release,
+// so that records logged to stderr don't scribble over the full-screen TUI.
+type heldWriter struct {
+	mu   sync.Mutex
+	w    io.Writer
+	held *bytes.Buffer
+}
+
+// Write writes p to the log, or keeps it while the writer is held.
+func (h *heldWriter) Write(p []byte) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.held != nil {
+		return h.held.Write(p)
+	}
+	return h.w.Write(p)
+}
+
+// toStderr reports whether the log goes to stderr rather than a file.
+func (h *heldWriter) toStderr() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.w == os.Stderr
+}
+
+// hold keeps log records back until the returned function is called, which
+// writes them out. A log written to a file isn't held.
+func (h *heldWriter) hold() (release func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.w != os.Stderr || h.held != nil {
+		return func() {}
+	}
+	h.held = new(bytes.Buffer)
+	return func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.w.Write(h.held.Bytes())
+		h.held = nil
+	}
+}
+
+// logFlags are the verbosity flags shared by every subcommand.
+type logFlags struct {
+	verbose *bool
+	debug   *bool
+	quiet   bool
+	file    *string
+}
+
+// addLogFlags registers --verbose, --debug, -q/--quiet, and --log-file on fs.
+func addLogFlags(fs *flag.FlagSet) *logFlags {
+	f := &logFlags{
+		verbose: fs.Bool("verbose", false, "Log the commands run and scan totals to stderr"),
+		debug:   fs.Bool("debug", false, "Log every file skipped, excluded, grouped, or left out of all groups, and why, in addition to --verbose"),
+		file:    fs.String("log-file", "", "Append the log to this file instead of writing it to stderr"),
+	}
+	fs.BoolVar(&f.quiet, "quiet", false, "Log only errors, not warnings")
+	fs.BoolVar(&f.quiet, "q", false, "Shorthand for --quiet")
+	return f
+}
+
+// apply sets the log level and output the flags ask for.
+func (f *logFlags) apply() error {
+	if f.quiet && (*f.verbose || *f.debug) {
+		return errors.New("quiet cannot be combined with verbose or debug")
+	}
+	switch {
+	case *f.debug:
+		logLevel.Set(slog.LevelDebug)
+	case *f.verbose:
+		logLevel.Set(slog.LevelInfo)
+	case f.quiet:
+		logLevel.Set(slog.LevelError)
+	default:
+		logLevel.Set(slog.LevelWarn)
+	}
+	var w io.Writer = os.Stderr
+	if *f.file != "" {
+		file, err := os.OpenFile(*f.file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return err
+		}
+		w = file
+	}
+	logOutput.mu.Lock()
+	if old, ok := logOutput.w.(*os.File); ok && old != os.Stderr {
+		old.Close()
+	}
+	logOutput.w = w
+	logOutput.mu.Unlock()
+	return nil
+}
+
+// logCommand logs the command line of cmd, which is about to run, and returns
+// cmd.
+func logCommand(cmd *exec.Cmd) *exec.Cmd {
+	logger.Info("running command", "args", cmd.Args)
+	return cmd
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// applyLogFlags parses args as the logging flags and applies them, restoring
+// the default log when the test ends.
+func applyLogFlags(t *testing.T, args ...string) error {
+	t.Helper()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	f := addLogFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		t.Fatalf("Parse(%q) returned error: %v", args, err)
+	}
+	t.Cleanup(func() {
+		if err := addLogFlags(flag.NewFlagSet("reset", flag.ContinueOnError)).apply(); err != nil {
+			t.Errorf("resetting the log returned error: %v", err)
+		}
+	})
+	return f.apply()
+}
+
+// TestLogFlags_Debug tests that --debug logs why files are left out of every
+// group, and that --log-file receives the log.
+func TestLogFlags_Debug(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	createFileWithContent(t, tmpDir, "notes.md", "one\n")
+	createFileWithContent(t, tmpDir, "notes 2.md", "two\n")
+	createFileWithContent(t, tmpDir, "todo.txt", "three\n")
+	createFileWithContent(t, tmpDir, ".hidden", "four\n")
+	logFile := filepath.Join(t.TempDir(), "doppel.log")
+
+	if err := applyLogFlags(t, "--debug", "--log-file", logFile); err != nil {
+		t.Fatalf("apply() returned error: %v", err)
+	}
+	files, err := scanFiles(t.Context(), options{}, tmpDir, nil)
+	if err != nil {
+		t.Fatalf("scanFiles() returned error: %v", err)
+	}
+	NewMatcher(3).Group(files)
+
+	data, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("reading the log file: %v", err)
+	}
+	for _, want := range []string{
+		`msg="skipped hidden file" path=` + filepath.Join(tmpDir, ".hidden"),
+		`msg="in no group" path=` + filepath.Join(tmpDir, "todo.txt"),
+		"merged=true",
+		"time=",
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("log should contain %q:\n%s", want, data)
+		}
+	}
+}
+
+// TestLogFlags_Levels tests which records each verbosity lets through.
+func TestLogFlags_Levels(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "doppel.log")
+	for _, tt := range []struct {
+		args []string
+		want []string
+	}{
+		{nil, []string{"warning"}},
+		{[]string{"-q"}, nil},
+		{[]string{"--verbose"}, []string{"info", "warning"}},
+	} {
+		os.Remove(logFile)
+		if err := applyLogFlags(t, append(tt.args, "--log-file", logFile)...); err != nil {
+			t.Fatalf("apply(%q) returned error: %v", tt.args, err)
+		}
+		logger.Debug("debug")
+		logger.Info("info")
+		logger.Warn("warning")
+		data, _ := os.ReadFile(logFile)
+		var got []string
+		for _, msg := range []string{"debug", "info", "warning"} {
+			if strings.Contains(string(data), "msg="+msg) {
+				got = append(got, msg)
+			}
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("with %q the log holds %q, expected %q", tt.args, got, tt.want)
+		}
+	}
+
+	if err := applyLogFlags(t, "-q", "--debug"); err == nil {
+		t.Error("-q with --debug should be refused")
+	}
+}
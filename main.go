@@ -1,12 +1,17 @@
 package main
 
 import (
-	"flag"
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 )
@@ -15,138 +20,674 @@ const (
 	defaultMinPrefixLength = 3
 )
 
+// Exit codes. With --check, finding groups exits with exitGroupsFound, like grep
+// exiting 0 on a match, so scripts can detect suspected duplicates.
+const (
+	exitNoGroups    = 0
+	exitError       = 1
+	exitGroupsFound = 2
+)
+
 func main() {
-	var (
-		diffTool      = flag.String("diff-tool", "", "Override default diff command (default: 'diff')")
-		minPrefix     = flag.Int("min-prefix", defaultMinPrefixLength, "Minimum prefix length for grouping files")
-		suffixPattern = flag.String("suffix", "", "Only consider files whose names match the indicated suffix pattern (regex)")
-		showHelp      = flag.Bool("help", false, "Show usage information")
-		showVersion   = flag.Bool("version", false, "Show version information")
-	)
-	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: %s [options] [directory]\n\n", os.Args[0])
-		fmt.Fprintf(os.Stderr, "Scans a directory for files with similar names and provides an interactive interface\n")
-		fmt.Fprintf(os.Stderr, "to compare them using side-by-side diffs.\n\n")
-		fmt.Fprintf(os.Stderr, "Options:\n")
-		flag.PrintDefaults()
-		fmt.Fprintf(os.Stderr, "\nIf no directory is specified, the current directory is used.\n")
-	}
-
-	flag.Parse()
-
-	if *showVersion {
-		fmt.Println("doppel version 0.1.0")
-		return
+	os.Exit(runCommand(os.Args[1:]))
+}
+
+// options holds the validated command-line configuration for a run.
+type options struct {
+	dir string
+	// compareDir, if set, is a second tree matched against dir by relative path or name.
+	compareDir    string
+	minPrefix     int
+	matchOpts     MatcherOptions
+	suffixPattern *regexp.Regexp
+	// datesAsVersions keeps date-suffixed names in the suffix filter instead of excluding them.
+	datesAsVersions bool
+	byContent       bool
+	recursive       bool
+	scanWorkers     int
+	// minSize and maxSize skip files outside the range at scan time; zero means no limit.
+	minSize int64
+	maxSize int64
+	// newerThan and olderThan skip files modified outside the window; zero means no limit.
+	newerThan time.Time
+	olderThan time.Time
+	// extensions limits scanning to files with these extensions; empty means all files.
+	extensions []string
+	// excludes are glob patterns of names of files and directories left out.
+	excludes []string
+	// includeHidden scans dotfiles and junk files such as .DS_Store.
+	includeHidden bool
+	// skipSymlinks leaves symbolic links out of the scan instead of following them.
+	skipSymlinks bool
+	// skipGitIgnored leaves out files git ignores, if the directory is in a repository.
+	skipGitIgnored bool
+	// scanArchives also scans the files inside zip archives; see splitArchivePath.
+	scanArchives bool
+	// statTimeout skips files whose stat takes longer; zero means no limit.
+	statTimeout time.Duration
+	// hashCache supplies hashes of files unchanged since an earlier run; nil with --no-cache.
+	hashCache *HashCache
+	// baseline holds the groups of an earlier JSON report, which are filtered out.
+	baseline *Baseline
+	// maxMemory bounds the tables of content grouping and the diff cache; zero means no limit.
+	maxMemory int64
+	// maxDivergence leaves out groups whose files all differ in content by more than it; zero means no limit.
+	maxDivergence float64
+	// identical collapses identical copies within groups for --only-different; nil keeps them.
+	identical *IdenticalCopies
+	// page selects the groups scan and report write.
+	page groupPage
+	// ignoreList holds groups the user ignored; they are filtered out unless includeIgnored is set.
+	ignoreList     *IgnoreList
+	includeIgnored bool
+	diffExec       *DiffExecutor
+	mergeTool      *MergeTool
+	imagePreview   bool
+	syntax         bool
+	showIdentical  bool
+	noTUI          bool
+	fullPaths      bool
+	// noAltScreen renders the TUI inline, so its last screen stays in the
+	// terminal's scrollback after it quits.
+	noAltScreen bool
+	// quarantine receives removed files instead of deleting them; nil deletes.
+	quarantine *Quarantine
+	// journal records file operations of an interactive session for undo.
+	journal *Journal
+	// keeper suggests which file of each group to keep; nil for no suggestions.
+	keeper *Keeper
+	// checksums shows a checksum next to each file and verifies it before a
+	// file is deleted or linked; nil without --hash.
+	checksums *Checksums
+	// actions are the custom actions of the config file for the TUI.
+	actions []GroupAction
+	// git is the repository the directory is in, for diffing against committed
+	// versions in the TUI, and with gitStatus for showing the status of files;
+	// nil outside a repository.
+	git       *GitRepo
+	gitStatus bool
+	// storage is the remote location dir names, such as an S3 bucket, that
+	// is scanned instead of a local directory; nil for a local directory.
+	storage Storage
+}
+
+// filters describes the scan filters in effect, such as "ext md,txt" or
+// "size 1.0 KB–5.0 MB", for the TUI's status bar.
+func (o options) filters() []string {
+	var filters []string
+	if o.suffixPattern != nil {
+		filters = append(filters, fmt.Sprintf("suffix '%s'", o.suffixPattern))
+	}
+	if len(o.extensions) > 0 {
+		filters = append(filters, "ext "+strings.Join(o.extensions, ","))
+	}
+	if len(o.excludes) > 0 {
+		filters = append(filters, "exclude "+strings.Join(o.excludes, ","))
+	}
+	switch {
+	case o.minSize > 0 && o.maxSize > 0:
+		filters = append(filters, fmt.Sprintf("size %s–%s", formatBytes(o.minSize), formatBytes(o.maxSize)))
+	case o.minSize > 0:
+		filters = append(filters, "size ≥ "+formatBytes(o.minSize))
+	case o.maxSize > 0:
+		filters = append(filters, "size ≤ "+formatBytes(o.maxSize))
+	}
+	if !o.newerThan.IsZero() {
+		filters = append(filters, "modified after "+o.newerThan.Format(time.DateOnly))
 	}
+	if !o.olderThan.IsZero() {
+		filters = append(filters, "modified before "+o.olderThan.Format(time.DateOnly))
+	}
+	if o.skipGitIgnored {
+		filters = append(filters, "no git-ignored files")
+	}
+	return filters
+}
 
-	if *showHelp {
-		flag.Usage()
-		return
+// matcher returns the Matcher that groups files by name with these options.
+func (o options) matcher() *Matcher {
+	return NewMatcherWithOptions(o.minPrefix, o.matchOpts)
+}
+
+// run executes the main workflow: scan, match, and interact.
+// Scanning happens inside the TUI so a loading screen is shown on large directories.
+// Quitting cancels the background scan and any running diff command.
+func run(ctx context.Context, opts options) error {
+	if opts.noTUI {
+		return runInteractive(ctx, opts)
 	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-	// Get directory from arguments or use current directory
-	dir := "."
-	if flag.NArg() > 0 {
-		dir = flag.Arg(0)
+	load := func(p *Progress) scanResult {
+		groups, fileCount, err := scanAndGroup(ctx, opts, p)
+		return scanResult{groups: groups, fileCount: fileCount, err: err}
 	}
 
-	// Validate directory exists
-	info, err := os.Stat(dir)
+	m := loadingModel(load, opts.diffExec.WithContext(ctx), opts.mergeTool)
+	m.imagePreview = opts.imagePreview
+	m.syntax = opts.syntax
+	m.ignoreList = opts.ignoreList
+	m.quarantine = opts.quarantine
+	m.journal = opts.journal
+	m.keeper = opts.keeper
+	m.checksums = opts.checksums
+	m.matcher = opts.matcher()
+	m.scanOpts = opts
+	m.actions = opts.actions
+	m.git = opts.git
+	m.gitStatus = opts.gitStatus
+	defer opts.git.Cleanup()
+	if opts.scanArchives {
+		m.archives = NewArchiveFiles()
+		defer m.archives.Cleanup()
+	}
+	m.displayRoot, m.fullPaths = displayRoot(opts)
+	m.filters = opts.filters()
+	m.skipIdentical = !opts.showIdentical
+	programOpts := []tea.ProgramOption{tea.WithContext(ctx)}
+	if !opts.noAltScreen {
+		programOpts = append(programOpts, tea.WithAltScreen())
+	}
+	p := tea.NewProgram(m, programOpts...)
+
+	// Log records would scribble over the screen, so they wait until it quits
+	release := logOutput.hold()
+	final, err := p.Run()
+	release()
+	if errors.Is(err, tea.ErrProgramKilled) && ctx.Err() != nil {
+		return ctx.Err()
+	}
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		return err
 	}
-	if !info.IsDir() {
-		fmt.Fprintf(os.Stderr, "Error: %s is not a directory\n", dir)
-		os.Exit(1)
+
+	result := final.(model)
+	if !result.scanDone {
+		// Quit before the scan finished
+		return nil
+	}
+	if result.scan.err != nil {
+		return result.scan.err
+	}
+	if result.scan.fileCount < 2 {
+		fmt.Println("Not enough files found to compare (need at least 2).")
+		return nil
+	}
+	if len(result.scan.groups) == 0 {
+		fmt.Println("No groups of similar files found.")
+		return nil
 	}
 
-	// Validate min prefix length
-	if *minPrefix < 1 {
-		fmt.Fprintf(os.Stderr, "Error: min-prefix must be at least 1\n")
-		os.Exit(1)
+	// Leave a record of the session in the terminal
+	return result.session.WriteSummary(os.Stdout, result.reviewedGroups())
+}
+
+// runInteractive is run for terminals that can't show the TUI: it scans with a
+// progress line on stderr, then walks the groups with line-based prompts.
+func runInteractive(ctx context.Context, opts options) error {
+	progress := NewProgress("Scanning")
+	stop := startProgressPrinter(os.Stderr, progress)
+	groups, fileCount, err := scanAndGroup(ctx, opts, progress)
+	stop()
+	if err != nil {
+		return err
+	}
+	if fileCount < 2 {
+		fmt.Println("Not enough files found to compare (need at least 2).")
+		return nil
 	}
 
-	// Compile suffix pattern if provided
-	var compiledPattern *regexp.Regexp
-	if *suffixPattern != "" {
-		// Anchor pattern to end of string (before extension)
-		// Only add $ if pattern doesn't already end with it to avoid double anchor
-		patternStr := *suffixPattern
-		if !strings.HasSuffix(patternStr, "$") {
-			patternStr = patternStr + "$"
+	diffExec := opts.diffExec.WithContext(ctx)
+	diffExec.SetWidth(envColumns())
+	cli := NewInteractiveCLI(groups, diffExec)
+	cli.ctx = ctx
+	cli.mergeTool = opts.mergeTool
+	cli.ignoreList = opts.ignoreList
+	cli.quarantine = opts.quarantine
+	cli.journal = opts.journal
+	cli.keeper = opts.keeper
+	cli.checksums = opts.checksums
+	cli.matcher = opts.matcher()
+	cli.displayRoot, cli.fullPaths = displayRoot(opts)
+	cli.skipIdentical = !opts.showIdentical
+	if isTerminal(os.Stdout) {
+		if cli.pager, err = pagerCommand(); err != nil {
+			return fmt.Errorf("invalid PAGER: %w", err)
 		}
-		pattern, err := regexp.Compile(patternStr)
+	}
+	return cli.Run()
+}
+
+// envColumns returns the terminal width exported in $COLUMNS, or 0 if unset.
+func envColumns() int {
+	columns, err := strconv.Atoi(os.Getenv("COLUMNS"))
+	if err != nil || columns < 0 {
+		return 0
+	}
+	return columns
+}
+
+// displayRoot returns the directory that full paths are shown relative to, and
+// whether to show them from the start.
+func displayRoot(opts options) (string, bool) {
+	if opts.compareDir != "" {
+		// Both trees hold the same names, so show where each file lives
+		return commonParent(opts.dir, opts.compareDir), true
+	}
+	return absPath(opts.dir), opts.fullPaths
+}
+
+// runReport scans and groups without the TUI and writes a per-file report of
+// the groups of opts.page to w using the given writer. Progress is shown on
+// stderr while files are scanned and hashed. Returns the number of groups found.
+func runReport(ctx context.Context, opts options, w io.Writer, write func(io.Writer, []FileRecord) error) (int, error) {
+	progress := NewProgress("Scanning")
+	stop := startProgressPrinter(os.Stderr, progress)
+
+	groups, _, err := scanAndGroup(ctx, opts, progress)
+	total := len(groups)
+	groups = opts.page.slice(groups)
+	var records []FileRecord
+	if err == nil && opts.storage != nil {
+		records, err = buildStoredRecords(ctx, opts.storage, groups, progress)
+	} else if err == nil {
+		records, err = buildFileRecords(ctx, groups, opts.hashCache, progress)
+	}
+	stop()
+	if err != nil {
+		return 0, err
+	}
+	saveHashCache(opts.hashCache)
+
+	// Groups keep their numbers across pages
+	for i := range records {
+		records[i].Group += opts.page.offset
+	}
+	setFingerprints(opts.dir, records)
+	return total, write(w, records)
+}
+
+// runList scans and groups without the TUI and writes the groups of opts.page
+// to w using the given writer. Returns the number of groups found.
+func runList(ctx context.Context, opts options, w io.Writer, write func(io.Writer, [][]string) error) (int, error) {
+	progress := NewProgress("Scanning")
+	stop := startProgressPrinter(os.Stderr, progress)
+	groups, _, err := scanAndGroup(ctx, opts, progress)
+	stop()
+	if err != nil {
+		return 0, err
+	}
+
+	return len(groups), write(w, opts.page.slice(groups))
+}
+
+// writeGroupList writes a plain-text listing of the groups of a scan of dir,
+// each with its fingerprint.
+func writeGroupList(w io.Writer, dir string, groups [][]string) error {
+	return writeGroupListWithChecksums(w, dir, groups, nil, nil, groupPage{})
+}
+
+// writeGroupListWithChecksums writes the listing of writeGroupList, with the
+// checksum of each file after its path unless checksums is nil, and the
+// number of identical copies collapsed into it by identical. groups are those
+// of page, and keep their numbers across pages.
+func writeGroupListWithChecksums(w io.Writer, dir string, groups [][]string, checksums *Checksums, identical *IdenticalCopies, page groupPage) error {
+	// A page leaves the count of all groups to its note
+	if page == (groupPage{}) {
+		if len(groups) == 0 {
+			_, err := fmt.Fprintln(w, "No groups of similar files found.")
+			return err
+		}
+		fmt.Fprintf(w, "Found %d group(s) of similar files\n", len(groups))
+	}
+	for i, group := range groups {
+		if i > 0 || page == (groupPage{}) {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "Group %d: %d files (fingerprint %s)\n", page.offset+1+i, len(group), groupFingerprint(dir, group))
+		for _, file := range group {
+			line := "  " + file
+			if sum := checksums.Short(file); sum != "" {
+				line += "  " + sum
+			}
+			if n := len(identical.Of(file)); n > 0 {
+				line += fmt.Sprintf("  (+%d identical)", n)
+			}
+			if _, err := fmt.Fprintln(w, line); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeGroupsNul writes every file path terminated by a NUL byte, with an extra
+// NUL after each group, so paths containing spaces, newlines, or any other
+// character survive "xargs -0". Nothing is written when there are no groups.
+func writeGroupsNul(w io.Writer, groups [][]string) error {
+	bw := bufio.NewWriter(w)
+	for _, group := range groups {
+		for _, file := range group {
+			bw.WriteString(file)
+			bw.WriteByte(0)
+		}
+		bw.WriteByte(0)
+	}
+	return bw.Flush()
+}
+
+// scanAndGroup scans the directory, applies filters, and groups similar files.
+// Returns the groups and the number of files considered for grouping. Cancelling
+// ctx stops the scan, grouping, and hashing early with ctx's error.
+func scanAndGroup(ctx context.Context, opts options, progress *Progress) ([][]string, int, error) {
+	var groups [][]string
+	var fileCount int
+	if opts.compareDir != "" {
+		var err error
+		if groups, fileCount, err = compareTrees(ctx, opts, progress); err != nil {
+			return nil, 0, err
+		}
+	} else {
+		// Step 1: Scan directory, keeping only files that match the suffix pattern
+		files, err := scanFiles(ctx, opts, opts.dir, progress)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: invalid suffix pattern: %v\n", err)
-			os.Exit(1)
+			return nil, 0, err
+		}
+
+		// Step 2: Group the files
+		if groups, err = groupFiles(ctx, opts, files, progress); err != nil {
+			return nil, 0, err
 		}
-		compiledPattern = pattern
+		fileCount = len(files)
 	}
 
-	// Execute the workflow
-	if err := run(dir, *diffTool, *minPrefix, compiledPattern); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+	if opts.maxDivergence > 0 {
+		var err error
+		if groups, err = filterDivergent(ctx, groups, opts.maxDivergence, progress); err != nil {
+			return nil, 0, err
+		}
+	}
+	if opts.identical != nil {
+		var err error
+		if groups, err = opts.identical.Collapse(ctx, groups, opts.hashCache, progress); err != nil {
+			return nil, 0, fmt.Errorf("failed to compare file contents: %w", err)
+		}
+		saveHashCache(opts.hashCache)
 	}
+	return groups, fileCount, nil
 }
 
-// run executes the main workflow: scan, match, and interact.
-func run(dir, diffTool string, minPrefix int, suffixPattern *regexp.Regexp) error {
-	// Step 1: Scan directory
+// groupFiles groups scanned files by prefix, or by identical content, and
+// drops the groups the user chose to ignore on a previous run and those of
+// the baseline report.
+func groupFiles(ctx context.Context, opts options, files []string, progress *Progress) ([][]string, error) {
+	if len(files) < 2 {
+		return nil, nil
+	}
+
+	progress.SetPhase("Grouping")
+	var groups [][]string
+	var err error
+	if opts.byContent {
+		groups, err = groupByContent(ctx, files, opts.hashCache, opts.maxMemory, progress)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compare file contents: %w", err)
+		}
+		saveHashCache(opts.hashCache)
+	} else {
+		groups, err = opts.matcher().GroupContext(ctx, files)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.ignoreList != nil && !opts.includeIgnored {
+		groups = opts.ignoreList.Filter(groups)
+	}
+	return opts.baseline.Filter(opts.dir, groups), nil
+}
+
+// saveHashCache writes the hash cache, logging a warning if that fails: the
+// results are still valid, only the next run will be slower.
+func saveHashCache(cache *HashCache) {
+	if err := cache.Save(); err != nil {
+		logger.Warn("failed to save hash cache", "err", err)
+	}
+}
+
+// scanner returns a Scanner for dir with the scanning options of o.
+func (o options) scanner(dir string) *Scanner {
 	scanner := NewScanner(dir)
-	files, err := scanner.Scan()
+	scanner.SetRecursive(o.recursive, o.scanWorkers)
+	scanner.SetSizeLimits(o.minSize, o.maxSize)
+	scanner.SetTimeWindow(o.newerThan, o.olderThan)
+	scanner.SetExtensions(o.extensions)
+	scanner.SetExcludes(o.excludes)
+	scanner.SetIncludeHidden(o.includeHidden)
+	scanner.SetSkipSymlinks(o.skipSymlinks)
+	scanner.SetStatTimeout(o.statTimeout)
+	scanner.SetScanArchives(o.scanArchives)
+	if dir == o.dir {
+		scanner.SetStorage(o.storage)
+	}
+	return scanner
+}
+
+// scanFiles scans dir with the scanning options of opts and applies the suffix filter.
+func scanFiles(ctx context.Context, opts options, dir string, progress *Progress) ([]string, error) {
+	scanner := opts.scanner(dir)
+	scanner.SetProgress(progress)
+	files, err := scanner.ScanContext(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to scan directory: %w", err)
+		return nil, fmt.Errorf("failed to scan directory: %w", err)
 	}
+	logger.Info("scanned directory", "dir", dir, "files", len(files))
+	reportUnreadable(dir, scanner.Unreadable())
 
-	// Step 1.5: Filter files by suffix pattern if provided
-	if suffixPattern != nil {
-		files = filterFilesBySuffix(files, suffixPattern)
+	if opts.skipGitIgnored {
+		repo, err := FindGitRepo(dir)
+		if err != nil {
+			return nil, fmt.Errorf("skip-git-ignored needs git: %w", err)
+		}
+		if repo == nil {
+			logger.Warn("not inside a git repository, so no files are skipped as git-ignored", "dir", dir)
+		}
+		if files, err = repo.FilterIgnored(ctx, files); err != nil {
+			return nil, err
+		}
 	}
 
-	if len(files) < 2 {
-		fmt.Println("Not enough files found to compare (need at least 2).")
-		return nil
+	if opts.suffixPattern != nil {
+		files = filterFilesBySuffixWithDates(files, opts.suffixPattern, opts.datesAsVersions)
+		logger.Info("applied suffix filter", "pattern", opts.suffixPattern, "files", len(files))
+	}
+	return files, nil
+}
+
+// reportUnreadable warns about each path the scan of dir skipped because it
+// couldn't be read, once the scan is done, so they can be fixed or excluded.
+func reportUnreadable(dir string, unreadable []UnreadablePath) {
+	if len(unreadable) == 0 {
+		return
+	}
+	for _, u := range unreadable {
+		logger.Warn("skipped unreadable path", "path", u.Path, "reason", u.Reason)
 	}
+	logger.Warn("some paths could not be read and were left out of the scan", "dir", dir, "count", len(unreadable))
+}
 
-	// Step 2: Group files by prefix
-	matcher := NewMatcher(minPrefix)
-	groups := matcher.Group(files)
+// compareTrees scans opts.dir and opts.compareDir recursively and groups files
+// that appear in both; see groupAcrossTrees.
+func compareTrees(ctx context.Context, opts options, progress *Progress) ([][]string, int, error) {
+	opts.recursive = true
+	filesA, err := scanFiles(ctx, opts, opts.dir, progress)
+	if err != nil {
+		return nil, 0, err
+	}
+	filesB, err := scanFiles(ctx, opts, opts.compareDir, progress)
+	if err != nil {
+		return nil, 0, err
+	}
 
-	if len(groups) == 0 {
-		fmt.Println("No groups of similar files found.")
-		return nil
+	progress.SetPhase("Grouping")
+	groups := groupAcrossTrees(opts.dir, filesA, opts.compareDir, filesB)
+	if opts.ignoreList != nil && !opts.includeIgnored {
+		groups = opts.ignoreList.Filter(groups)
 	}
+	return groups, len(filesA) + len(filesB), nil
+}
+
+// stringListFlag is a flag.Value that collects every occurrence of a repeatable flag.
+type stringListFlag []string
 
-	// Step 3: Interactive TUI
-	diffExec := NewDiffExecutor(diffTool)
-	m := initialModel(groups, diffExec)
-	p := tea.NewProgram(m, tea.WithAltScreen())
+// String returns the collected values joined by spaces.
+func (f *stringListFlag) String() string {
+	return strings.Join(*f, " ")
+}
+
+// Set appends a value each time the flag is given.
+func (f *stringListFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// globListFlag is a stringListFlag of comma-separated glob patterns. Each is
+// checked when the flag is set, on the command line or by a profile, so a
+// malformed pattern is a usage error rather than one that never matches.
+type globListFlag struct {
+	stringListFlag
+}
+
+// Set checks the patterns of value and appends it.
+func (f *globListFlag) Set(value string) error {
+	for _, pattern := range splitList([]string{value}) {
+		if _, err := filepath.Match(pattern, pattern); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	return f.stringListFlag.Set(value)
+}
+
+// byteSizeFlag is a flag.Value for a size in bytes, written as a number with an
+// optional binary unit such as 10k, 5M, or 2GiB.
+type byteSizeFlag int64
+
+// String returns the size with a unit, or an empty string for zero.
+func (f *byteSizeFlag) String() string {
+	if *f == 0 {
+		return ""
+	}
+	return formatBytes(int64(*f))
+}
 
-	if _, err := p.Run(); err != nil {
+// Set parses a size.
+func (f *byteSizeFlag) Set(value string) error {
+	n, err := parseSize(value)
+	if err != nil {
 		return err
 	}
+	*f = byteSizeFlag(n)
+	return nil
+}
+
+// parseSize parses a size such as "512", "10k", "5M", or "1.5GiB". Units are
+// powers of 1024 and case-insensitive; a trailing "B" or "iB" is optional.
+func parseSize(s string) (int64, error) {
+	value := strings.TrimSpace(s)
+	upper := strings.ToUpper(value)
+	upper = strings.TrimSuffix(strings.TrimSuffix(upper, "B"), "I")
+
+	multiplier := int64(1)
+	if n := len(upper); n > 0 {
+		if i := strings.IndexByte("KMGT", upper[n-1]); i >= 0 {
+			multiplier = int64(1) << (10 * (i + 1))
+			upper = upper[:n-1]
+		}
+	}
 
+	number, err := strconv.ParseFloat(strings.TrimSpace(upper), 64)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("invalid size %q (use a number with an optional unit, e.g. 10k or 5M)", s)
+	}
+	return int64(number * float64(multiplier)), nil
+}
+
+// timeBoundFlag is a flag.Value for a point in time, given either as a date or
+// as a duration before now; see parseTimeBound.
+type timeBoundFlag time.Time
+
+// String returns the time, or an empty string for the zero time.
+func (f *timeBoundFlag) String() string {
+	t := time.Time(*f)
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// Set parses a time bound relative to the current time.
+func (f *timeBoundFlag) Set(value string) error {
+	t, err := parseTimeBound(value, time.Now())
+	if err != nil {
+		return err
+	}
+	*f = timeBoundFlag(t)
 	return nil
 }
 
+// timeBoundLayouts are the date formats accepted by parseTimeBound, in local time
+// unless the value carries a zone.
+var timeBoundLayouts = []string{
+	"2006-01-02",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	time.RFC3339,
+}
+
+// parseTimeBound parses a date such as "2024-01-30" or "2024-01-30 14:00", or a
+// duration before now such as "36h", "7d", or "2w" (d and w are days and weeks).
+func parseTimeBound(s string, now time.Time) (time.Time, error) {
+	value := strings.TrimSpace(s)
+	for _, layout := range timeBoundLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	if n := len(value); n > 1 && (value[n-1] == 'd' || value[n-1] == 'w') {
+		count, err := strconv.ParseFloat(value[:n-1], 64)
+		if err == nil && count >= 0 {
+			day := 24 * time.Hour
+			if value[n-1] == 'w' {
+				day *= 7
+			}
+			return now.Add(-time.Duration(count * float64(day))), nil
+		}
+	} else if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q (use a date like 2024-01-30 or a duration like 36h or 7d)", s)
+}
+
 // isLikelyDatePattern checks if a filename base (without extension)
 // appears to be a date pattern rather than a version pattern.
 //
 // This function uses heuristics to distinguish date patterns (e.g., "file-2026-01-30")
 // from version patterns (e.g., "file-1", "file-2"). The heuristics are:
 //
-// 1. Trailing hyphen+digits after suffix removal: If removing the matched suffix
-//    leaves trailing hyphen+digits, it's likely part of a date pattern.
-//    Example: "file-2026-01-30" where pattern matches "-30" leaves "file-2026-01"
-//    which ends with "-01", indicating a date pattern.
+//  1. Trailing hyphen+digits after suffix removal: If removing the matched suffix
+//     leaves trailing hyphen+digits, it's likely part of a date pattern.
+//     Example: "file-2026-01-30" where pattern matches "-30" leaves "file-2026-01"
+//     which ends with "-01", indicating a date pattern.
 //
-// 2. Multiple hyphen+digit sequences: If the filename has 3+ hyphen+digit sequences,
-//    it's likely a date pattern (e.g., "2026-01-30" has three sequences).
+//  2. Multiple hyphen+digit sequences: If the filename has 3+ hyphen+digit sequences,
+//     it's likely a date pattern (e.g., "2026-01-30" has three sequences).
 //
-// 3. Long digit sequences: If any hyphen+digit sequence has 4+ digits, it's likely
-//    a year (e.g., "-2024" indicates a year, not a version number).
+//  3. Long digit sequences: If any hyphen+digit sequence has 4+ digits, it's likely
+//     a year (e.g., "-2024" indicates a year, not a version number).
 //
 // Parameters:
 //   - baseFilename: The full base filename (without extension) being checked
@@ -201,11 +742,68 @@ func isLikelyDatePattern(baseFilename, baseName string) bool {
 	return false
 }
 
+// trailingDateSuffix matches the hyphen-separated numbers ending a date-like
+// name, such as "-2024-01-30" in "report-2024-01-30".
+var trailingDateSuffix = regexp.MustCompile(`(?:-\d+)+$`)
+
+// suffixKind is how matchVersionSuffix classifies a file name.
+type suffixKind int
+
+const (
+	// suffixNone means the name doesn't end in the suffix pattern.
+	suffixNone suffixKind = iota
+	// suffixVersion means the name ends in a version suffix.
+	suffixVersion
+	// suffixDate means the suffix looks like a date rather than a version, and
+	// dates aren't taken as versions.
+	suffixDate
+)
+
+// matchVersionSuffix checks whether the name of file, without its extension,
+// ends in a match of pattern, and returns the base name before the suffix if
+// it is a version. See filterFilesBySuffixWithDates for how dates are treated.
+func matchVersionSuffix(file string, pattern *regexp.Regexp, datesAsVersions bool) (string, suffixKind) {
+	filename := filepath.Base(file)
+	ext := filepath.Ext(filename)
+	baseFilename := filename[:len(filename)-len(ext)]
+
+	// Check if pattern matches at end of base filename
+	// Use FindStringIndex to verify match is anchored at end
+	match := pattern.FindStringIndex(baseFilename)
+	if match == nil || match[1] != len(baseFilename) {
+		return "", suffixNone
+	}
+	// Extract base name by removing the matched suffix
+	baseName := pattern.ReplaceAllString(baseFilename, "")
+
+	// Check if this appears to be a date pattern rather than a version pattern
+	if isLikelyDatePattern(baseFilename, baseName) {
+		if !datesAsVersions {
+			return "", suffixDate
+		}
+		// Treat the whole date as the version suffix
+		baseName = trailingDateSuffix.ReplaceAllString(baseFilename, "")
+		if baseName == "" {
+			return "", suffixNone
+		}
+	}
+	return baseName, suffixVersion
+}
+
 // filterFilesBySuffix filters files to include:
 // 1. Files whose filename ends with a match to the given pattern
 // 2. Base files (without the suffix pattern) that correspond to matching files
 // If pattern is nil, returns all files (backward compatibility).
+// Date-like names are excluded; see filterFilesBySuffixWithDates.
 func filterFilesBySuffix(files []string, pattern *regexp.Regexp) []string {
+	return filterFilesBySuffixWithDates(files, pattern, false)
+}
+
+// filterFilesBySuffixWithDates is filterFilesBySuffix with control over date-like
+// names. If datesAsVersions is false they are excluded, as isLikelyDatePattern
+// decides. If it is true they are kept as versions of the name before the date,
+// so "report-2024-01-30" and "report-2024-02-02" both belong with "report".
+func filterFilesBySuffixWithDates(files []string, pattern *regexp.Regexp, datesAsVersions bool) []string {
 	if pattern == nil {
 		return files
 	}
@@ -217,32 +815,18 @@ func filterFilesBySuffix(files []string, pattern *regexp.Regexp) []string {
 	}
 	var matchingFiles []fileMatch
 	baseNames := make(map[string]bool) // Track unique base names
+	dated := make(map[string]bool)     // Files excluded for a date suffix, for the log
 
 	for _, file := range files {
-		filename := filepath.Base(file)
-		ext := filepath.Ext(filename)
-		baseFilename := filename[:len(filename)-len(ext)]
-
-		// Check if pattern matches at end of base filename
-		// Use FindStringIndex to verify match is anchored at end
-		match := pattern.FindStringIndex(baseFilename)
-		if match != nil && match[1] == len(baseFilename) {
-			// Match is anchored at end - proceed
-			// Extract base name by removing the matched suffix
-			// Use ReplaceAllString to remove the matched portion
-			baseName := pattern.ReplaceAllString(baseFilename, "")
-			
-			// Check if this appears to be a date pattern rather than a version pattern
-			if isLikelyDatePattern(baseFilename, baseName) {
-				// This is likely a date pattern - exclude it
-				continue
-			}
-			
+		switch baseName, kind := matchVersionSuffix(file, pattern, datesAsVersions); kind {
+		case suffixVersion:
 			matchingFiles = append(matchingFiles, fileMatch{
 				file:     file,
 				baseName: baseName,
 			})
 			baseNames[baseName] = true
+		case suffixDate:
+			dated[file] = true
 		}
 	}
 
@@ -273,6 +857,10 @@ func filterFilesBySuffix(files []string, pattern *regexp.Regexp) []string {
 		if baseNames[baseFilename] {
 			result = append(result, file)
 			included[file] = true
+		} else if dated[file] {
+			logger.Debug("excluded by suffix filter", "path", file, "reason", "date suffix (see --dates-as-versions)")
+		} else {
+			logger.Debug("excluded by suffix filter", "path", file, "reason", "no version suffix and no versions of it")
 		}
 	}
 
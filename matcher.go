@@ -1,12 +1,47 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
 )
 
+// cancelCheckInterval is how many files are merged between checks for cancellation.
+const cancelCheckInterval = 1024
+
+// MatcherOptions controls how filenames are compared when grouping.
+type MatcherOptions struct {
+	// SameExtOnly only groups files whose extensions match (case-insensitive).
+	SameExtOnly bool
+	// StripExt removes extensions before comparing names, so they never contribute to the prefix.
+	StripExt bool
+	// BaseRegex, if set, replaces prefix matching: it is matched against each name
+	// without its extension, and files with the same captured base form a group.
+	// The base is the "base" named group, else the first group, else the whole match.
+	// Files the pattern doesn't match are not grouped.
+	BaseRegex *regexp.Regexp
+	// CopyNames, if set, strips duplicate markers such as "Copy of" from names
+	// before they are compared.
+	CopyNames *CopyNameRules
+	// PrefixRatio, if above zero, also requires the common prefix to cover at
+	// least this fraction of the shorter of the two names, so long names that
+	// only share a leading word stay apart.
+	PrefixRatio float64
+	// SplitSimilarity, if above zero, splits groups into subgroups whose
+	// names are pairwise at least this alike; see splitGroups.
+	SplitSimilarity float64
+}
+
 // Matcher groups files by common prefix.
 type Matcher struct {
 	minPrefixLength int
+	opts            MatcherOptions
 }
 
 // NewMatcher creates a new Matcher with the specified minimum prefix length.
@@ -14,72 +49,346 @@ func NewMatcher(minPrefixLength int) *Matcher {
 	return &Matcher{minPrefixLength: minPrefixLength}
 }
 
+// NewMatcherWithOptions creates a new Matcher with the specified minimum prefix length and options.
+func NewMatcherWithOptions(minPrefixLength int, opts MatcherOptions) *Matcher {
+	return &Matcher{minPrefixLength: minPrefixLength, opts: opts}
+}
+
 // Group groups files by their common prefix.
 // Returns a slice of groups, where each group contains files that share a common prefix.
 // Only groups with 2 or more files are returned.
 func (m *Matcher) Group(files []string) [][]string {
+	groups, _ := m.GroupContext(context.Background(), files)
+	return groups
+}
+
+// GroupContext is like Group but stops early with ctx's error if ctx is cancelled.
+func (m *Matcher) GroupContext(ctx context.Context, files []string) ([][]string, error) {
 	if len(files) < 2 {
-		return nil
+		return nil, nil
 	}
 
+	// Logging every pair is costly in large folders, so check once
+	debug := logger.Enabled(ctx, slog.LevelDebug)
+
 	// Extract just the filenames (without directory path) for prefix matching
-	type fileInfo struct {
-		filename string
-		fullPath string
-	}
-	var fileInfos []fileInfo
+	var fileInfos []matchName
 	for _, file := range files {
-		filename := filepath.Base(file)
-		fileInfos = append(fileInfos, fileInfo{filename: filename, fullPath: file})
-	}
-
-	// Build groups: files that share a prefix of sufficient length belong to the same group
-	// Use a union-find approach: each file starts in its own group, then merge groups
-	// that share a common prefix
-	groupID := make([]int, len(fileInfos))
-	for i := range groupID {
-		groupID[i] = i
-	}
-
-	// Find all pairs that share a prefix and merge their groups
-	for i := 0; i < len(fileInfos); i++ {
-		for j := i + 1; j < len(fileInfos); j++ {
-			prefix := commonPrefix(fileInfos[i].filename, fileInfos[j].filename)
-			if len(prefix) >= m.minPrefixLength {
-				// Merge groups: make j's group point to i's group
-				rootI := findRoot(groupID, i)
-				rootJ := findRoot(groupID, j)
-				if rootI != rootJ {
-					groupID[rootJ] = rootI
+		fileInfos = append(fileInfos, m.matchName(file))
+	}
+
+	if m.opts.BaseRegex != nil {
+		keys := make([]string, len(fileInfos))
+		for i, info := range fileInfos {
+			base, ok := regexBase(m.opts.BaseRegex, info.stem)
+			if !ok {
+				if debug {
+					logger.Debug("in no group", "path", info.fullPath, "reason", "--group-by-regex does not match")
 				}
+				continue
+			}
+			// Prefix the key with a marker so unmatched files (empty key) never group
+			keys[i] = "+" + base
+			if m.opts.SameExtOnly {
+				keys[i] += "\x00" + info.ext
 			}
 		}
+		return groupByKey(files, keys), nil
 	}
 
-	// Collect files by their group
+	// Build groups: files that share a prefix of sufficient length belong to the same group.
+	// Sharing the first minPrefixLength bytes is transitive, so after sorting by name
+	// (and extension, when it must match) every file that belongs with another sits
+	// next to one. Only adjacent entries need comparing, which makes grouping
+	// O(n log n) instead of comparing every pair. PrefixRatio is checked pairwise
+	// too, through the span of neighbours each name would group with; see
+	// coveredGaps.
+	order := make([]int, len(fileInfos))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool {
+		x, y := fileInfos[order[a]], fileInfos[order[b]]
+		if m.opts.SameExtOnly && x.ext != y.ext {
+			return x.ext < y.ext
+		}
+		return x.filename < y.filename
+	})
+
+	// Use a union-find approach: each file starts in its own group, then merge the
+	// groups of neighbours that share a common prefix
+	prefixes := make([]string, len(order))
+	gaps := make([]int, len(order))
+	needs := make([]int, len(order))
+	for k, i := range order {
+		needs[k] = m.prefixNeed(fileInfos[i].filename)
+		if k == 0 {
+			continue
+		}
+		h := order[k-1]
+		if m.opts.SameExtOnly && fileInfos[h].ext != fileInfos[i].ext {
+			gaps[k] = -1
+			continue
+		}
+		prefixes[k] = commonPrefix(fileInfos[h].filename, fileInfos[i].filename)
+		gaps[k] = len(prefixes[k])
+	}
+	covered := coveredGaps(needs, gaps)
+	sets := newUnionFind(len(fileInfos))
+	for k := 1; k < len(order); k++ {
+		if k%cancelCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		i, j := order[k-1], order[k]
+		if m.opts.SameExtOnly && fileInfos[i].ext != fileInfos[j].ext {
+			continue
+		}
+		prefix, merged := prefixes[k], covered[k]
+		if merged {
+			sets.union(i, j)
+		}
+		if debug {
+			logger.Debug("compared neighbouring names", "a", fileInfos[i].fullPath, "b", fileInfos[j].fullPath,
+				"prefix", prefix, "merged", merged)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// Collect files by their group, remembering the order in which groups first appear
+	// so output is deterministic
 	groups := make(map[int][]string)
+	var roots []int
 	for i, fileInfo := range fileInfos {
-		root := findRoot(groupID, i)
+		root := sets.find(i)
+		if _, seen := groups[root]; !seen {
+			roots = append(roots, root)
+		}
 		groups[root] = append(groups[root], fileInfo.fullPath)
 	}
 
 	// Filter to only groups with 2+ files and convert to slice
 	var result [][]string
-	for _, group := range groups {
-		if len(group) >= 2 {
+	for _, root := range roots {
+		if group := groups[root]; len(group) >= 2 {
 			result = append(result, group)
+		} else if debug {
+			reason := fmt.Sprintf("shares fewer than --min-prefix %d characters with its neighbours", m.minPrefixLength)
+			if m.opts.PrefixRatio > 0 {
+				reason += fmt.Sprintf(", or less than --prefix-ratio %g of the shorter name", m.opts.PrefixRatio)
+			}
+			logger.Debug("in no group", "path", group[0], "reason", reason)
+		}
+	}
+	if m.opts.SplitSimilarity > 0 {
+		result = m.splitGroups(result)
+	}
+	logger.Info("grouped files by name", "files", len(files), "groups", len(result))
+
+	return result, nil
+}
+
+// prefixNeed returns how many leading bytes name must share with a name no
+// shorter than it to group with it: minPrefixLength, or with PrefixRatio that
+// fraction of name if more. It agrees with prefixMatches.
+func (m *Matcher) prefixNeed(name string) int {
+	return max(m.minPrefixLength, int(math.Ceil(m.opts.PrefixRatio*float64(len(name)))))
+}
+
+// coveredGaps returns, for each k of a sorted list of names, whether names
+// k-1 and k belong to the same group. needs[k] is the prefixNeed of name k,
+// and gaps[k] the length of the common prefix of names k-1 and k, or -1 if
+// they may never group.
+//
+// Two names group when they share the need of the shorter one, since the
+// longer one's need is no smaller. The names sharing need bytes with a name
+// sit in one span of sorted neighbours around it, and grouping it with all of
+// them merges every gap of the span. A gap is covered when the span of some
+// name to its left or right reaches across it. Sweeping from each side only
+// has to track the smallest need still reaching, since a larger one would
+// stop no later.
+func coveredGaps(needs, gaps []int) []bool {
+	covered := make([]bool, len(gaps))
+	reach := math.MaxInt
+	for k := 1; k < len(gaps); k++ {
+		reach = min(reach, needs[k-1])
+		if gaps[k] >= reach {
+			covered[k] = true
+		} else {
+			reach = math.MaxInt
 		}
 	}
+	reach = math.MaxInt
+	for k := len(gaps) - 1; k >= 1; k-- {
+		reach = min(reach, needs[k])
+		if gaps[k] >= reach {
+			covered[k] = true
+		} else {
+			reach = math.MaxInt
+		}
+	}
+	return covered
+}
 
+// prefixMatches reports whether prefix, the common prefix of the compared
+// names a and b, is long enough to group them: at least minPrefixLength bytes
+// and, with PrefixRatio, that fraction of the shorter name.
+func (m *Matcher) prefixMatches(prefix, a, b string) bool {
+	if len(prefix) < m.minPrefixLength {
+		return false
+	}
+	return float64(len(prefix)) >= m.opts.PrefixRatio*float64(min(len(a), len(b)))
+}
+
+// matchName is a file's name as the matcher compares it.
+type matchName struct {
+	// filename is the name that prefixes are taken from; stem is the name
+	// without its extension and ext the lowercased extension.
+	filename string
+	stem     string
+	ext      string
+	fullPath string
+}
+
+// matchName returns the name of file as it is compared, with duplicate markers
+// stripped and the extension dropped if the options say so.
+func (m *Matcher) matchName(file string) matchName {
+	ext := filepath.Ext(file)
+	stem := strings.TrimSuffix(filepath.Base(file), ext)
+	if m.opts.CopyNames != nil {
+		stem = m.opts.CopyNames.Strip(stem)
+	}
+	filename := stem + ext
+	if m.opts.StripExt {
+		filename = stem
+	}
+	return matchName{filename: filename, stem: stem, ext: strings.ToLower(ext), fullPath: file}
+}
+
+// labelTrim holds the separators trimmed from the end of a group label, so
+// "notes-1" and "notes-2" are labelled "notes" rather than "notes-".
+const labelTrim = " -_.([{"
+
+// Label returns the name the files of group have in common, for showing as the
+// group's title: the base captured by BaseRegex, or else the longest prefix of
+// the compared names, without trailing separators. It returns "" if the names
+// share nothing, as files grouped by content may not. A nil Matcher labels
+// groups as one with default options would.
+func (m *Matcher) Label(group []string) string {
+	if len(group) == 0 {
+		return ""
+	}
+	if m == nil {
+		m = &Matcher{}
+	}
+	first := m.matchName(group[0])
+	if m.opts.BaseRegex != nil {
+		if base, ok := regexBase(m.opts.BaseRegex, first.stem); ok {
+			return strings.TrimSpace(base)
+		}
+		return ""
+	}
+	prefix := first.filename
+	for _, file := range group[1:] {
+		prefix = commonPrefix(prefix, m.matchName(file).filename)
+	}
+	if prefix == first.filename && !m.opts.StripExt {
+		// Every name is the same, as in compare mode, so leave the extension off
+		prefix = first.stem
+	}
+	// A byte-wise prefix can end partway through a multi-byte character
+	for !utf8.ValidString(prefix) {
+		prefix = prefix[:len(prefix)-1]
+	}
+	return strings.TrimRight(prefix, labelTrim)
+}
+
+// regexBase returns the base name captured by re in name, and whether re matched.
+func regexBase(re *regexp.Regexp, name string) (string, bool) {
+	match := re.FindStringSubmatch(name)
+	if match == nil {
+		return "", false
+	}
+	if i := re.SubexpIndex("base"); i >= 0 {
+		return match[i], true
+	}
+	if len(match) > 1 {
+		return match[1], true
+	}
+	return match[0], true
+}
+
+// groupByKey groups files[i] by keys[i], skipping empty keys. Groups are ordered by
+// the position of their first file and only groups of 2 or more files are returned.
+func groupByKey(files, keys []string) [][]string {
+	groups := make(map[string][]string)
+	var order []string
+	for i, key := range keys {
+		if key == "" {
+			continue
+		}
+		if _, seen := groups[key]; !seen {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], files[i])
+	}
+
+	var result [][]string
+	for _, key := range order {
+		if group := groups[key]; len(group) >= 2 {
+			result = append(result, group)
+		}
+	}
 	return result
 }
 
-// findRoot finds the root of a group using path compression.
-func findRoot(groupID []int, x int) int {
-	if groupID[x] != x {
-		groupID[x] = findRoot(groupID, groupID[x])
+// unionFind is a disjoint-set forest over the integers 0..n-1.
+type unionFind struct {
+	parent []int
+	rank   []uint8
+}
+
+// newUnionFind returns a unionFind in which every element is its own set.
+func newUnionFind(n int) *unionFind {
+	u := &unionFind{parent: make([]int, n), rank: make([]uint8, n)}
+	for i := range u.parent {
+		u.parent[i] = i
+	}
+	return u
+}
+
+// find returns the root of x's set. It walks up iteratively, then points every
+// element on the path straight at the root, so long chains never recurse deeply.
+func (u *unionFind) find(x int) int {
+	root := x
+	for u.parent[root] != root {
+		root = u.parent[root]
+	}
+	for u.parent[x] != root {
+		u.parent[x], x = root, u.parent[x]
+	}
+	return root
+}
+
+// union merges the sets containing x and y, attaching the shallower tree under
+// the deeper one to keep trees short.
+func (u *unionFind) union(x, y int) {
+	rx, ry := u.find(x), u.find(y)
+	if rx == ry {
+		return
+	}
+	switch {
+	case u.rank[rx] < u.rank[ry]:
+		u.parent[rx] = ry
+	case u.rank[rx] > u.rank[ry]:
+		u.parent[ry] = rx
+	default:
+		u.parent[ry] = rx
+		u.rank[rx]++
 	}
-	return groupID[x]
 }
 
 // commonPrefix returns the common prefix of two strings.
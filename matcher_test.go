@@ -1,7 +1,14 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"path/filepath"
 	"reflect"
+	"regexp"
+	"strings"
 	"testing"
 )
 
@@ -94,7 +101,7 @@ func TestMatcher_Group_ThreeSimilarFiles(t *testing.T) {
 
 // TestMatcher_Group_MinPrefixLength tests that minimum prefix length is respected.
 func TestMatcher_Group_MinPrefixLength(t *testing.T) {
-	matcher := NewMatcher(5) // Require at least 5 characters
+	matcher := NewMatcher(5)                                    // Require at least 5 characters
 	files := []string{"/path/to/doc.txt", "/path/to/doc-1.txt"} // "doc" is only 3 chars
 	groups := matcher.Group(files)
 
@@ -103,6 +110,27 @@ func TestMatcher_Group_MinPrefixLength(t *testing.T) {
 	}
 }
 
+// TestMatcher_Group_PrefixRatio tests that --prefix-ratio keeps names that
+// share only a leading word apart.
+func TestMatcher_Group_PrefixRatio(t *testing.T) {
+	files := []string{
+		"/vault/Obsidian Daily Notes.md",
+		"/vault/Obsidian Daily Notes 2.md",
+		"/vault/Obsidian Importer plugin.md",
+	}
+
+	if groups := NewMatcher(3).Group(files); len(groups) != 1 || len(groups[0]) != 3 {
+		t.Fatalf("without a ratio, Group() = %v, expected one group of all files", groups)
+	}
+
+	matcher := NewMatcherWithOptions(3, MatcherOptions{PrefixRatio: 0.6})
+	groups := matcher.Group(files)
+	expected := [][]string{{files[0], files[1]}}
+	if !reflect.DeepEqual(groups, expected) {
+		t.Errorf("Group() = %v, expected %v", groups, expected)
+	}
+}
+
 // TestMatcher_Group_NoCommonPrefix tests files with no common prefix.
 func TestMatcher_Group_NoCommonPrefix(t *testing.T) {
 	matcher := NewMatcher(3)
@@ -245,3 +273,372 @@ func TestMatcher_Group_WithDifferentExtensions(t *testing.T) {
 		t.Errorf("Group() files mismatch. Expected %v, got %v", expectedFiles, actualFiles)
 	}
 }
+
+// TestMatcher_Group_SameExtOnly tests that --same-ext-only splits groups by extension.
+func TestMatcher_Group_SameExtOnly(t *testing.T) {
+	matcher := NewMatcherWithOptions(3, MatcherOptions{SameExtOnly: true})
+	files := []string{
+		"/path/to/document.txt",
+		"/path/to/document-1.TXT",
+		"/path/to/document.pdf",
+	}
+	groups := matcher.Group(files)
+
+	if len(groups) != 1 {
+		t.Fatalf("Group() returned %d groups, expected 1", len(groups))
+	}
+	expected := map[string]bool{"/path/to/document.txt": true, "/path/to/document-1.TXT": true}
+	actual := make(map[string]bool)
+	for _, f := range groups[0] {
+		actual[f] = true
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Group() files mismatch. Expected %v, got %v", expected, actual)
+	}
+}
+
+// TestMatcher_Group_StripExt tests that extensions no longer contribute to the common prefix.
+func TestMatcher_Group_StripExt(t *testing.T) {
+	files := []string{"/path/to/ab.txt", "/path/to/ab.tex"}
+
+	// With extensions, "ab.tex" and "ab.txt" share the 4-character prefix "ab.t"
+	if groups := NewMatcher(4).Group(files); len(groups) != 1 {
+		t.Fatalf("Group() without --strip-ext returned %d groups, expected 1", len(groups))
+	}
+
+	// Without extensions, only "ab" is shared, which is below the minimum
+	matcher := NewMatcherWithOptions(4, MatcherOptions{StripExt: true})
+	if groups := matcher.Group(files); len(groups) != 0 {
+		t.Errorf("Group() with --strip-ext returned %d groups, expected 0", len(groups))
+	}
+}
+
+// TestMatcher_Group_DeterministicOrder tests that groups are ordered by their first file's input position.
+func TestMatcher_Group_DeterministicOrder(t *testing.T) {
+	matcher := NewMatcher(3)
+	files := []string{
+		"/path/to/zebra.txt",
+		"/path/to/apple.txt",
+		"/path/to/zebra-1.txt",
+		"/path/to/apple-1.txt",
+	}
+
+	for run := 0; run < 10; run++ {
+		groups := matcher.Group(files)
+		if len(groups) != 2 {
+			t.Fatalf("Group() returned %d groups, expected 2", len(groups))
+		}
+		if groups[0][0] != "/path/to/zebra.txt" || groups[1][0] != "/path/to/apple.txt" {
+			t.Fatalf("Group() order = %v, expected zebra group first", groups)
+		}
+	}
+}
+
+// TestMatcher_GroupContext_Cancelled tests that a cancelled context stops grouping.
+func TestMatcher_GroupContext_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	matcher := NewMatcher(3)
+	groups, err := matcher.GroupContext(ctx, []string{"document.txt", "document-1.txt"})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("GroupContext() error = %v, expected context.Canceled", err)
+	}
+	if groups != nil {
+		t.Errorf("GroupContext() = %v, expected no groups", groups)
+	}
+}
+
+// groupPairwise is the reference grouping: it compares every pair of files and
+// merges those sharing a long enough prefix, by --min-prefix and --prefix-ratio.
+// Group must produce the same groups.
+func groupPairwise(m *Matcher, files []string) [][]string {
+	names := make([]string, len(files))
+	exts := make([]string, len(files))
+	for i, file := range files {
+		names[i] = filepath.Base(file)
+		exts[i] = strings.ToLower(filepath.Ext(names[i]))
+		if m.opts.StripExt {
+			names[i] = strings.TrimSuffix(names[i], filepath.Ext(names[i]))
+		}
+	}
+
+	sets := newUnionFind(len(files))
+	for i := range files {
+		for j := i + 1; j < len(files); j++ {
+			if m.opts.SameExtOnly && exts[i] != exts[j] {
+				continue
+			}
+			if m.prefixMatches(commonPrefix(names[i], names[j]), names[i], names[j]) {
+				sets.union(i, j)
+			}
+		}
+	}
+
+	groups := make(map[int][]string)
+	var roots []int
+	for i, file := range files {
+		root := sets.find(i)
+		if _, seen := groups[root]; !seen {
+			roots = append(roots, root)
+		}
+		groups[root] = append(groups[root], file)
+	}
+	var result [][]string
+	for _, root := range roots {
+		if len(groups[root]) >= 2 {
+			result = append(result, groups[root])
+		}
+	}
+	return result
+}
+
+// randomFileNames returns n file names built from a small alphabet, so many of
+// them share prefixes of various lengths.
+func randomFileNames(rng *rand.Rand, n int) []string {
+	exts := []string{".txt", ".TXT", ".md", ".go", ""}
+	files := make([]string, n)
+	for i := range files {
+		name := make([]byte, 1+rng.Intn(8))
+		for k := range name {
+			name[k] = "abc-1"[rng.Intn(5)]
+		}
+		files[i] = filepath.Join("/dir", string(name)+exts[rng.Intn(len(exts))])
+	}
+	return files
+}
+
+// TestMatcher_Group_MatchesPairwise tests that grouping gives the same groups as
+// comparing every pair of files, across prefix lengths and options.
+func TestMatcher_Group_MatchesPairwise(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for run := 0; run < 200; run++ {
+		files := randomFileNames(rng, 2+rng.Intn(60))
+		m := NewMatcherWithOptions(1+rng.Intn(5), MatcherOptions{
+			SameExtOnly: rng.Intn(2) == 0,
+			StripExt:    rng.Intn(2) == 0,
+			PrefixRatio: []float64{0, 0, 0.5, 0.6, 0.8, 1}[rng.Intn(6)],
+		})
+		got := m.Group(files)
+		want := groupPairwise(m, files)
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("Group(%v) with min prefix %d and %+v =\n%v\nexpected\n%v", files, m.minPrefixLength, m.opts, got, want)
+		}
+	}
+}
+
+// TestMatcher_Group_PrefixRatioPairwise tests that --prefix-ratio holds for
+// every pair rather than only for neighbours in name order: abcd shares 60% of
+// itself with both longer names, which share too little with each other.
+func TestMatcher_Group_PrefixRatioPairwise(t *testing.T) {
+	files := []string{"/dir/abcd", "/dir/abcdaaaaaaaaaa", "/dir/abcdefghij"}
+	m := NewMatcherWithOptions(3, MatcherOptions{PrefixRatio: 0.6})
+	got := m.Group(files)
+	if want := groupPairwise(m, files); !reflect.DeepEqual(got, want) {
+		t.Errorf("Group() = %v, expected %v", got, want)
+	}
+	if len(got) != 1 || len(got[0]) != 3 {
+		t.Errorf("Group() = %v, expected all three files in one group", got)
+	}
+}
+
+// benchmarkFiles returns n file names shaped like a large directory of versioned documents.
+func benchmarkFiles(n int) []string {
+	rng := rand.New(rand.NewSource(1))
+	files := make([]string, n)
+	for i := range files {
+		files[i] = fmt.Sprintf("/data/report-%05d-v%d.txt", rng.Intn(n), rng.Intn(3))
+	}
+	return files
+}
+
+// BenchmarkMatcher_Group measures grouping a directory of 20,000 files.
+func BenchmarkMatcher_Group(b *testing.B) {
+	files := benchmarkFiles(20000)
+	m := NewMatcher(12)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.Group(files)
+	}
+}
+
+// BenchmarkMatcher_GroupPairwise measures the pairwise reference on the same files,
+// for comparison with BenchmarkMatcher_Group.
+func BenchmarkMatcher_GroupPairwise(b *testing.B) {
+	files := benchmarkFiles(20000)
+	m := NewMatcher(12)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		groupPairwise(m, files)
+	}
+}
+
+// TestUnionFind tests merging sets, including a long chain of merges.
+func TestUnionFind(t *testing.T) {
+	const n = 100000
+	sets := newUnionFind(n)
+
+	// Chain every element onto the next, the worst case for a naive forest
+	for i := n - 1; i > 0; i-- {
+		sets.union(i, i-1)
+	}
+	root := sets.find(0)
+	for _, x := range []int{1, n / 2, n - 1} {
+		if sets.find(x) != root {
+			t.Fatalf("find(%d) = %d, expected %d", x, sets.find(x), root)
+		}
+	}
+
+	// Unrelated elements stay separate
+	other := newUnionFind(4)
+	other.union(0, 1)
+	other.union(2, 3)
+	if other.find(0) == other.find(2) {
+		t.Error("union() merged unrelated sets")
+	}
+	if other.find(1) != other.find(0) || other.find(3) != other.find(2) {
+		t.Error("union() did not merge related sets")
+	}
+}
+
+// TestMatcher_Group_LargeGroup tests grouping 100,000 files that all share a
+// prefix, which merges them into one long chain of sets.
+func TestMatcher_Group_LargeGroup(t *testing.T) {
+	files := make([]string, 100000)
+	for i := range files {
+		files[i] = fmt.Sprintf("/data/report-%06d.txt", i)
+	}
+	groups := NewMatcher(6).Group(files)
+	if len(groups) != 1 || len(groups[0]) != len(files) {
+		t.Fatalf("Group() returned %d groups, expected 1 group of %d files", len(groups), len(files))
+	}
+	if groups[0][0] != files[0] {
+		t.Errorf("Group() first file = %s, expected %s", groups[0][0], files[0])
+	}
+}
+
+// BenchmarkMatcher_Group_100k measures grouping 100,000 files into one large group.
+func BenchmarkMatcher_Group_100k(b *testing.B) {
+	files := make([]string, 100000)
+	for i := range files {
+		files[i] = fmt.Sprintf("/data/report-%06d.txt", i)
+	}
+	m := NewMatcher(6)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.Group(files)
+	}
+}
+
+// TestMatcher_Group_BaseRegex tests grouping by the base name captured by a regex.
+func TestMatcher_Group_BaseRegex(t *testing.T) {
+	files := []string{
+		"/dir/report.txt",
+		"/dir/report copy.txt",
+		"/dir/report (2).txt",
+		"/dir/report-3.md",
+		"/dir/reporting.txt",
+		"/dir/notes.txt",
+		"/dir/notes copy.txt",
+	}
+	re := regexp.MustCompile(`^(?P<base>.+?)( copy| \(\d+\)|-\d+)?$`)
+
+	groups := NewMatcherWithOptions(3, MatcherOptions{BaseRegex: re}).Group(files)
+	want := [][]string{
+		{"/dir/report.txt", "/dir/report copy.txt", "/dir/report (2).txt", "/dir/report-3.md"},
+		{"/dir/notes.txt", "/dir/notes copy.txt"},
+	}
+	if !reflect.DeepEqual(groups, want) {
+		t.Errorf("Group() = %v, expected %v", groups, want)
+	}
+
+	groups = NewMatcherWithOptions(3, MatcherOptions{BaseRegex: re, SameExtOnly: true}).Group(files)
+	if len(groups) != 2 || len(groups[0]) != 3 {
+		t.Errorf("Group() with same-ext-only = %v, expected report-3.md left out", groups)
+	}
+}
+
+// TestRegexBase tests which part of a match is used as the base name.
+func TestRegexBase(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		name     string
+		expected string
+		ok       bool
+	}{
+		{`^(?P<base>[a-z]+)_(\d+)$`, "scan_001", "scan", true},
+		{`^(\d+)-(.+)$`, "2024-notes", "2024", true},
+		{`^[a-z]+`, "draft2", "draft", true},
+		{`^[a-z]+$`, "draft2", "", false},
+	}
+	for _, tt := range tests {
+		base, ok := regexBase(regexp.MustCompile(tt.pattern), tt.name)
+		if base != tt.expected || ok != tt.ok {
+			t.Errorf("regexBase(%q, %q) = %q, %v; expected %q, %v", tt.pattern, tt.name, base, ok, tt.expected, tt.ok)
+		}
+	}
+}
+
+// TestMatcher_Group_CopyNames tests that duplicate markers don't keep copies apart.
+func TestMatcher_Group_CopyNames(t *testing.T) {
+	files := []string{
+		"/dir/cv.docx",
+		"/dir/Copy of cv.docx",
+		"/dir/cv - Copy.docx",
+		"/dir/Copy of notes.txt",
+		"/dir/notes.TXT",
+	}
+	rules, err := NewCopyNameRules(nil, nil)
+	if err != nil {
+		t.Fatalf("NewCopyNameRules() returned error: %v", err)
+	}
+
+	groups := NewMatcherWithOptions(3, MatcherOptions{CopyNames: rules}).Group(files)
+	want := [][]string{
+		{"/dir/cv.docx", "/dir/Copy of cv.docx", "/dir/cv - Copy.docx"},
+		{"/dir/Copy of notes.txt", "/dir/notes.TXT"},
+	}
+	if !reflect.DeepEqual(groups, want) {
+		t.Errorf("Group() = %v, expected %v", groups, want)
+	}
+
+	// Without the rules, only the two "Copy of" names share a long enough prefix
+	groups = NewMatcher(3).Group(files)
+	want = [][]string{{"/dir/Copy of cv.docx", "/dir/Copy of notes.txt"}}
+	if !reflect.DeepEqual(groups, want) {
+		t.Errorf("Group() without copy names = %v, expected %v", groups, want)
+	}
+}
+
+// TestMatcher_Label tests the names groups are labelled with.
+func TestMatcher_Label(t *testing.T) {
+	rules, err := NewCopyNameRules(nil, nil)
+	if err != nil {
+		t.Fatalf("NewCopyNameRules() returned error: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		matcher *Matcher
+		group   []string
+		want    string
+	}{
+		{"Shared prefix", NewMatcher(3), []string{"/d/Obsidian Daily Notes 2024.md", "/d/Obsidian Daily Notes (1).md"}, "Obsidian Daily Notes"},
+		{"Trailing separator", NewMatcher(3), []string{"/d/report-1.txt", "/d/report-2.txt"}, "report"},
+		{"One name is the prefix", NewMatcher(3), []string{"/d/document.txt", "/d/document_copy.txt"}, "document"},
+		{"Same name", NewMatcher(3), []string{"/a/notes.txt", "/b/notes.txt"}, "notes"},
+		{"Copy markers", NewMatcherWithOptions(3, MatcherOptions{CopyNames: rules}), []string{"/d/cv.docx", "/d/Copy of cv.docx"}, "cv"},
+		{"Base regex", NewMatcherWithOptions(3, MatcherOptions{BaseRegex: regexp.MustCompile(`^(.+?)(-\d+)?$`)}), []string{"/d/a-1.txt", "/d/a-22.txt"}, "a"},
+		{"Nothing shared", NewMatcher(3), []string{"/d/alpha.txt", "/d/beta.txt"}, ""},
+		{"Multi-byte character", NewMatcher(3), []string{"/d/café.txt", "/d/cafè.txt"}, "caf"},
+		{"Nil matcher", nil, []string{"/d/notes-1.txt", "/d/notes-2.txt"}, "notes"},
+		{"Empty group", NewMatcher(3), nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.matcher.Label(tt.group); got != tt.want {
+				t.Errorf("Label() = %q, expected %q", got, tt.want)
+			}
+		})
+	}
+}
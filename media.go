@@ -0,0 +1,340 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// mediaExtensions lists the extensions of audio and video files, which are
+// compared by their metadata instead of their bytes.
+var mediaExtensions = map[string]bool{
+	".mp3": true, ".m4a": true, ".aac": true, ".flac": true, ".wav": true,
+	".ogg": true, ".opus": true, ".wma": true,
+	".mp4": true, ".m4v": true, ".mov": true, ".mkv": true, ".webm": true,
+	".avi": true, ".wmv": true,
+}
+
+// mediaProbeTool is the external program used to read the metadata of media
+// files. It comes with FFmpeg.
+const mediaProbeTool = "ffprobe"
+
+// maxMediaTagChunk caps how much of a WAV file's tag chunk is read.
+const maxMediaTagChunk = 64 << 10
+
+// errCannotProbe is returned by a mediaProber that can't read a file, so the
+// next one is tried.
+var errCannotProbe = errors.New("unsupported media file")
+
+// MediaInfo describes an audio or video file.
+type MediaInfo struct {
+	Format   string
+	Duration time.Duration
+	// Bitrate is the overall bit rate in bits per second; zero if unknown.
+	Bitrate int64
+	// Video and Audio describe the first stream of each kind, such as
+	// "h264 1920x1080" or "aac, 2 channels, 48 kHz"; empty if there is none.
+	Video, Audio string
+	// Tags holds embedded tags such as title and artist, by lower-case name.
+	Tags map[string]string
+}
+
+// mediaProber reads the metadata of a media file. It returns errCannotProbe
+// when it can't read this kind of file or its tool isn't installed.
+type mediaProber func(ctx context.Context, path string) (*MediaInfo, error)
+
+// mediaProbers are tried in order until one reads a file: ffprobe for every
+// format it knows, and a built-in reader for WAV files when it isn't installed.
+var mediaProbers = []mediaProber{probeFFprobe, probeWAV}
+
+// isMediaFile reports whether a path has a known audio or video extension.
+func isMediaFile(path string) bool {
+	return mediaExtensions[strings.ToLower(filepath.Ext(path))]
+}
+
+// probeMedia reads the metadata of a media file with the first prober that can.
+func probeMedia(ctx context.Context, path string) (*MediaInfo, error) {
+	for _, probe := range mediaProbers {
+		info, err := probe(ctx, path)
+		if !errors.Is(err, errCannotProbe) {
+			return info, err
+		}
+	}
+	return nil, fmt.Errorf("install %s (FFmpeg) to compare the metadata of %s files", mediaProbeTool, strings.ToLower(filepath.Ext(path)))
+}
+
+// mediaDiff returns a formatted metadata comparison of two media files. The
+// boolean result is false when the pair should be compared some other way;
+// note then says why the metadata couldn't be read.
+func mediaDiff(ctx context.Context, file1, file2 string) (output string, ok bool, note string) {
+	info1, err := probeMedia(ctx, file1)
+	if err != nil {
+		return "", false, fmt.Sprintf("Comparing as binary files: %v\n\n", err)
+	}
+	info2, err := probeMedia(ctx, file2)
+	if err != nil {
+		return "", false, fmt.Sprintf("Comparing as binary files: %v\n\n", err)
+	}
+	return FormatMediaComparison(file1, file2, info1, info2), true, ""
+}
+
+// FormatMediaComparison renders two media files' metadata as a table, with
+// rows that differ marked.
+func FormatMediaComparison(file1, file2 string, info1, info2 *MediaInfo) string {
+	var s strings.Builder
+	s.WriteString("Media comparison\n\n")
+
+	size := func(file string) string {
+		if stat, err := os.Stat(file); err == nil {
+			return formatBytes(stat.Size())
+		}
+		return "unknown"
+	}
+	rows := [][3]string{{"", "File 1", "File 2"}, {"Size", size(file1), size(file2)}}
+	field := func(name string, value func(*MediaInfo) string) {
+		rows = append(rows, [3]string{name, orUnknown(value(info1)), orUnknown(value(info2))})
+	}
+	field("Format", func(info *MediaInfo) string { return info.Format })
+	field("Duration", func(info *MediaInfo) string { return formatMediaDuration(info.Duration) })
+	field("Bitrate", func(info *MediaInfo) string {
+		if info.Bitrate <= 0 {
+			return ""
+		}
+		return fmt.Sprintf("%d kb/s", (info.Bitrate+500)/1000)
+	})
+	if info1.Video != "" || info2.Video != "" {
+		field("Video", func(info *MediaInfo) string { return info.Video })
+	}
+	if info1.Audio != "" || info2.Audio != "" {
+		field("Audio", func(info *MediaInfo) string { return info.Audio })
+	}
+
+	// Tags of either file, in alphabetical order
+	seen := make(map[string]bool)
+	var tags []string
+	for _, info := range []*MediaInfo{info1, info2} {
+		for tag := range info.Tags {
+			if !seen[tag] {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+	sort.Strings(tags)
+	for _, tag := range tags {
+		rows = append(rows, [3]string{"Tag " + tag, orNone(info1.Tags[tag]), orNone(info2.Tags[tag])})
+	}
+
+	s.WriteString(formatMetadataTable(rows))
+	return s.String()
+}
+
+// orNone returns value, or "-" if it is empty.
+func orNone(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// formatMediaDuration formats a duration as minutes and seconds, such as
+// 3:25.4, with hours for longer media, such as 1:02:03.
+func formatMediaDuration(d time.Duration) string {
+	if d <= 0 {
+		return ""
+	}
+	if d >= time.Hour {
+		d = d.Round(time.Second)
+		return fmt.Sprintf("%d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+	}
+	d = d.Round(100 * time.Millisecond)
+	return fmt.Sprintf("%d:%04.1f", int(d.Minutes()), d.Seconds()-float64(int(d.Minutes())*60))
+}
+
+// ffprobeOutput is the part of ffprobe's JSON output doppel reads.
+type ffprobeOutput struct {
+	Format struct {
+		FormatName     string            `json:"format_name"`
+		FormatLongName string            `json:"format_long_name"`
+		Duration       string            `json:"duration"`
+		BitRate        string            `json:"bit_rate"`
+		Tags           map[string]string `json:"tags"`
+	} `json:"format"`
+	Streams []struct {
+		CodecType  string `json:"codec_type"`
+		CodecName  string `json:"codec_name"`
+		Width      int    `json:"width"`
+		Height     int    `json:"height"`
+		Channels   int    `json:"channels"`
+		SampleRate string `json:"sample_rate"`
+	} `json:"streams"`
+}
+
+// probeFFprobe reads any format FFmpeg knows with ffprobe.
+func probeFFprobe(ctx context.Context, path string) (*MediaInfo, error) {
+	tool, err := exec.LookPath(mediaProbeTool)
+	if err != nil {
+		return nil, errCannotProbe
+	}
+	out, err := logCommand(exec.CommandContext(ctx, tool, "-v", "error", "-print_format", "json",
+		"-show_format", "-show_streams", path)).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return nil, fmt.Errorf("%s: %s", mediaProbeTool, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, err
+	}
+	var probe ffprobeOutput
+	if err := json.Unmarshal(out, &probe); err != nil {
+		return nil, fmt.Errorf("%s: %w", mediaProbeTool, err)
+	}
+
+	info := &MediaInfo{Format: probe.Format.FormatLongName, Tags: make(map[string]string)}
+	if info.Format == "" {
+		info.Format = probe.Format.FormatName
+	}
+	if seconds, err := strconv.ParseFloat(probe.Format.Duration, 64); err == nil {
+		info.Duration = time.Duration(seconds * float64(time.Second))
+	}
+	info.Bitrate, _ = strconv.ParseInt(probe.Format.BitRate, 10, 64)
+	for tag, value := range probe.Format.Tags {
+		info.Tags[strings.ToLower(tag)] = value
+	}
+	for _, stream := range probe.Streams {
+		switch {
+		case stream.CodecType == "video" && info.Video == "":
+			info.Video = stream.CodecName
+			if stream.Width > 0 && stream.Height > 0 {
+				info.Video += fmt.Sprintf(" %dx%d", stream.Width, stream.Height)
+			}
+		case stream.CodecType == "audio" && info.Audio == "":
+			rate, _ := strconv.Atoi(stream.SampleRate)
+			info.Audio = describeAudio(stream.CodecName, stream.Channels, rate)
+		}
+	}
+	return info, nil
+}
+
+// describeAudio describes an audio stream, such as "aac, 2 channels, 48 kHz".
+func describeAudio(codec string, channels, sampleRate int) string {
+	parts := []string{codec}
+	switch {
+	case channels == 1:
+		parts = append(parts, "mono")
+	case channels > 1:
+		parts = append(parts, fmt.Sprintf("%d channels", channels))
+	}
+	if sampleRate > 0 {
+		parts = append(parts, strconv.FormatFloat(float64(sampleRate)/1000, 'f', -1, 64)+" kHz")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// wavInfoTags maps the RIFF INFO chunk IDs of WAV files to tag names.
+var wavInfoTags = map[string]string{
+	"INAM": "title",
+	"IART": "artist",
+	"IPRD": "album",
+	"ICRD": "date",
+	"IGNR": "genre",
+	"ICMT": "comment",
+	"ISFT": "encoder",
+}
+
+// probeWAV reads the format, length, and INFO tags of a WAV file from its
+// RIFF chunks without external tools.
+func probeWAV(_ context.Context, path string) (*MediaInfo, error) {
+	if strings.ToLower(filepath.Ext(path)) != ".wav" {
+		return nil, errCannotProbe
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var header [12]byte
+	if _, err := io.ReadFull(f, header[:]); err != nil || string(header[:4]) != "RIFF" || string(header[8:]) != "WAVE" {
+		return nil, errors.New("not a RIFF WAVE file")
+	}
+
+	info := &MediaInfo{Format: "WAV / WAVE (Waveform Audio)", Tags: make(map[string]string)}
+	var byteRate, dataSize uint32
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(f, chunk[:]); err != nil {
+			break
+		}
+		id, size := string(chunk[:4]), binary.LittleEndian.Uint32(chunk[4:])
+		// Chunks are padded to an even size
+		next := int64(size) + int64(size%2)
+		switch {
+		case id == "fmt " && size >= 16:
+			var format [16]byte
+			if _, err := io.ReadFull(f, format[:]); err != nil {
+				return nil, err
+			}
+			next -= 16
+			codec := binary.LittleEndian.Uint16(format[0:])
+			channels := int(binary.LittleEndian.Uint16(format[2:]))
+			sampleRate := int(binary.LittleEndian.Uint32(format[4:]))
+			byteRate = binary.LittleEndian.Uint32(format[8:])
+			bits := binary.LittleEndian.Uint16(format[14:])
+			name := fmt.Sprintf("format 0x%04x", codec)
+			switch codec {
+			case 1:
+				name = fmt.Sprintf("PCM %d-bit", bits)
+			case 3:
+				name = fmt.Sprintf("PCM %d-bit float", bits)
+			}
+			info.Audio = describeAudio(name, channels, sampleRate)
+		case id == "data":
+			dataSize = size
+		case id == "LIST" && size >= 4 && size <= maxMediaTagChunk:
+			list := make([]byte, size)
+			if _, err := io.ReadFull(f, list); err != nil {
+				return nil, err
+			}
+			next -= int64(size)
+			if string(list[:4]) == "INFO" {
+				readWAVInfo(list[4:], info.Tags)
+			}
+		}
+		if _, err := f.Seek(next, io.SeekCurrent); err != nil {
+			return nil, err
+		}
+	}
+	if byteRate > 0 {
+		info.Bitrate = int64(byteRate) * 8
+		info.Duration = time.Duration(float64(dataSize) / float64(byteRate) * float64(time.Second))
+	}
+	return info, nil
+}
+
+// readWAVInfo adds the tags of a RIFF INFO list to tags.
+func readWAVInfo(list []byte, tags map[string]string) {
+	for len(list) >= 8 {
+		id, size := string(list[:4]), int(binary.LittleEndian.Uint32(list[4:]))
+		list = list[8:]
+		if size > len(list) {
+			return
+		}
+		if name, ok := wavInfoTags[id]; ok {
+			if value := strings.TrimSpace(strings.TrimRight(string(list[:size]), "\x00")); value != "" {
+				tags[name] = value
+			}
+		}
+		list = list[min(size+size%2, len(list)):]
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// buildWAV returns a 16-bit PCM WAV file with seconds of silence at
+// sampleRate Hz and an INFO list holding title.
+func buildWAV(channels, sampleRate int, seconds float64, title string) []byte {
+	byteRate := sampleRate * channels * 2
+	data := make([]byte, int(float64(byteRate)*seconds))
+
+	var info bytes.Buffer
+	if title != "" {
+		value := append([]byte(title), 0)
+		info.WriteString("INFO")
+		info.WriteString("INAM")
+		binary.Write(&info, binary.LittleEndian, uint32(len(value)))
+		info.Write(value)
+		if len(value)%2 == 1 {
+			info.WriteByte(0)
+		}
+	}
+
+	var body bytes.Buffer
+	body.WriteString("WAVE")
+	body.WriteString("fmt ")
+	binary.Write(&body, binary.LittleEndian, uint32(16))
+	binary.Write(&body, binary.LittleEndian, uint16(1))
+	binary.Write(&body, binary.LittleEndian, uint16(channels))
+	binary.Write(&body, binary.LittleEndian, uint32(sampleRate))
+	binary.Write(&body, binary.LittleEndian, uint32(byteRate))
+	binary.Write(&body, binary.LittleEndian, uint16(channels*2))
+	binary.Write(&body, binary.LittleEndian, uint16(16))
+	body.WriteString("data")
+	binary.Write(&body, binary.LittleEndian, uint32(len(data)))
+	body.Write(data)
+	if info.Len() > 0 {
+		body.WriteString("LIST")
+		binary.Write(&body, binary.LittleEndian, uint32(info.Len()))
+		body.Write(info.Bytes())
+	}
+
+	var out bytes.Buffer
+	out.WriteString("RIFF")
+	binary.Write(&out, binary.LittleEndian, uint32(body.Len()))
+	out.Write(body.Bytes())
+	return out.Bytes()
+}
+
+// TestProbeWAV tests reading the format, length, and tags of a WAV file.
+func TestProbeWAV(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	path := createFileWithContent(t, tmpDir, "take.wav", string(buildWAV(2, 8000, 1.5, "Take 1")))
+	info, err := probeWAV(context.Background(), path)
+	if err != nil {
+		t.Fatalf("probeWAV() returned error: %v", err)
+	}
+	if info.Duration != 1500*time.Millisecond {
+		t.Errorf("Duration = %v, expected 1.5s", info.Duration)
+	}
+	if info.Bitrate != 256000 {
+		t.Errorf("Bitrate = %d, expected 256000", info.Bitrate)
+	}
+	if want := "PCM 16-bit, 2 channels, 8 kHz"; info.Audio != want {
+		t.Errorf("Audio = %q, expected %q", info.Audio, want)
+	}
+	if info.Tags["title"] != "Take 1" {
+		t.Errorf("Tags = %v, expected the title", info.Tags)
+	}
+
+	notWAV := createFileWithContent(t, tmpDir, "song.mp3", "ID3")
+	if _, err := probeWAV(context.Background(), notWAV); err != errCannotProbe {
+		t.Errorf("probeWAV() on an MP3 returned %v, expected errCannotProbe", err)
+	}
+	broken := createFileWithContent(t, tmpDir, "broken.wav", "not a wav")
+	if _, err := probeWAV(context.Background(), broken); err == nil || err == errCannotProbe {
+		t.Errorf("probeWAV() on a broken file returned %v, expected an error", err)
+	}
+}
+
+// TestComparePair_Media tests the metadata table of two WAV files without ffprobe.
+func TestComparePair_Media(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	t.Setenv("PATH", t.TempDir())
+
+	file1 := createFileWithContent(t, tmpDir, "take.wav", string(buildWAV(2, 8000, 1.5, "Take 1")))
+	file2 := createFileWithContent(t, tmpDir, "take (1).wav", string(buildWAV(1, 8000, 1, "")))
+
+	output, err := NewDiffExecutor("").ComparePair(file1, file2, false)
+	if err != nil {
+		t.Fatalf("ComparePair() returned error: %v", err)
+	}
+	for _, want := range []string{
+		"Media comparison",
+		"Duration   0:01.5                         0:01.0  ≠",
+		"Audio      PCM 16-bit, 2 channels, 8 kHz  PCM 16-bit, mono, 8 kHz  ≠",
+		"Tag title  Take 1                         -  ≠",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("ComparePair() output missing %q:\n%s", want, output)
+		}
+	}
+
+	// Other formats need ffprobe, and are compared as binary files without it
+	mp3 := createFileWithContent(t, tmpDir, "song.mp3", "ID3\x00one")
+	mp3Copy := createFileWithContent(t, tmpDir, "song-1.mp3", "ID3\x00two")
+	output, err = NewDiffExecutor("").ComparePair(mp3, mp3Copy, false)
+	if err != nil {
+		t.Fatalf("ComparePair() returned error: %v", err)
+	}
+	if !strings.Contains(output, "install ffprobe (FFmpeg)") || !strings.Contains(output, "Binary files differ") {
+		t.Errorf("ComparePair() = %q, expected a note and a binary comparison", output)
+	}
+}
+
+// TestProbeFFprobe tests reading ffprobe's JSON output.
+func TestProbeFFprobe(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	bin := t.TempDir()
+	script := `#!/bin/sh
+echo '{"streams": [
+  {"codec_type": "video", "codec_name": "h264", "width": 1920, "height": 1080},
+  {"codec_type": "audio", "codec_name": "aac", "channels": 2, "sample_rate": "48000"}
+ ],
+ "format": {"format_name": "mov,mp4", "format_long_name": "QuickTime / MOV",
+  "duration": "3725.000000", "bit_rate": "8000000", "tags": {"TITLE": "Holiday"}}}'
+`
+	if err := os.WriteFile(filepath.Join(bin, mediaProbeTool), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin)
+
+	path := createFileWithContent(t, tmpDir, "holiday.mp4", "")
+	info, err := probeMedia(context.Background(), path)
+	if err != nil {
+		t.Fatalf("probeMedia() returned error: %v", err)
+	}
+	if info.Format != "QuickTime / MOV" || info.Video != "h264 1920x1080" || info.Audio != "aac, 2 channels, 48 kHz" {
+		t.Errorf("probeMedia() = %+v, expected the format and streams", info)
+	}
+	if formatMediaDuration(info.Duration) != "1:02:05" || info.Bitrate != 8000000 || info.Tags["title"] != "Holiday" {
+		t.Errorf("probeMedia() = %+v, expected duration, bitrate, and tags", info)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// defaultMergeTool is the interactive tool used when none is configured.
+const defaultMergeTool = "vimdiff"
+
+// MergeTool launches an external interactive diff/merge tool (vimdiff, meld, kdiff3, ...)
+// on a pair of files.
+type MergeTool struct {
+	toolCmd  string
+	toolArgs []string
+}
+
+// NewMergeTool creates a MergeTool from a command line such as "meld" or
+// "kdiff3 --auto {1} {2}". If the command line has no {1}/{2} placeholders,
+// the two files are appended to it. If it is empty, defaults to vimdiff.
+func NewMergeTool(commandLine string) (*MergeTool, error) {
+	argv, err := splitCommandLine(commandLine)
+	if err != nil {
+		return nil, err
+	}
+	if len(argv) == 0 {
+		argv = []string{defaultMergeTool}
+	}
+	return &MergeTool{toolCmd: argv[0], toolArgs: argv[1:]}, nil
+}
+
+// Name returns the program name of the tool, for display.
+func (t *MergeTool) Name() string {
+	return t.toolCmd
+}
+
+// Command builds the command that opens file1 and file2 in the tool.
+func (t *MergeTool) Command(file1, file2 string) *exec.Cmd {
+	var args []string
+	hasPlaceholder := false
+	for _, arg := range t.toolArgs {
+		if strings.Contains(arg, placeholderFile1) || strings.Contains(arg, placeholderFile2) {
+			hasPlaceholder = true
+		}
+		arg = strings.ReplaceAll(arg, placeholderFile1, file1)
+		arg = strings.ReplaceAll(arg, placeholderFile2, file2)
+		args = append(args, arg)
+	}
+	if !hasPlaceholder {
+		args = append(args, file1, file2)
+	}
+	return logCommand(exec.Command(t.toolCmd, args...))
+}
+
+// mergeToolFinishedMsg is sent to the TUI when the external tool exits.
+type mergeToolFinishedMsg struct {
+	err error
+}
+
+// describeMergeToolResult returns a status line describing how the tool exited.
+func describeMergeToolResult(name string, err error) string {
+	if err != nil {
+		return fmt.Sprintf("%s exited with error: %v", name, err)
+	}
+	return fmt.Sprintf("Returned from %s", name)
+}
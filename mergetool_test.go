@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// TestNewMergeTool_Default tests that an empty command line defaults to vimdiff.
+func TestNewMergeTool_Default(t *testing.T) {
+	tool, err := NewMergeTool("")
+	if err != nil {
+		t.Fatalf("NewMergeTool() returned error: %v", err)
+	}
+	if tool.Name() != defaultMergeTool {
+		t.Errorf("Name() = %q, expected %q", tool.Name(), defaultMergeTool)
+	}
+}
+
+// TestMergeTool_Command_AppendsFiles tests that files are appended when no placeholders are used.
+func TestMergeTool_Command_AppendsFiles(t *testing.T) {
+	tool, err := NewMergeTool("kdiff3 --auto")
+	if err != nil {
+		t.Fatalf("NewMergeTool() returned error: %v", err)
+	}
+
+	cmd := tool.Command("/tmp/a.txt", "/tmp/b.txt")
+	got := strings.Join(cmd.Args, " ")
+	want := "kdiff3 --auto /tmp/a.txt /tmp/b.txt"
+	if got != want {
+		t.Errorf("Command().Args = %q, expected %q", got, want)
+	}
+}
+
+// TestMergeTool_Command_Placeholders tests placeholder substitution.
+func TestMergeTool_Command_Placeholders(t *testing.T) {
+	tool, err := NewMergeTool("meld {2} {1} --newtab")
+	if err != nil {
+		t.Fatalf("NewMergeTool() returned error: %v", err)
+	}
+
+	cmd := tool.Command("/tmp/a.txt", "/tmp/b.txt")
+	got := strings.Join(cmd.Args, " ")
+	want := "meld /tmp/b.txt /tmp/a.txt --newtab"
+	if got != want {
+		t.Errorf("Command().Args = %q, expected %q", got, want)
+	}
+}
+
+// TestNewMergeTool_InvalidCommandLine tests that quoting errors are reported.
+func TestNewMergeTool_InvalidCommandLine(t *testing.T) {
+	if _, err := NewMergeTool("meld 'unterminated"); err == nil {
+		t.Error("NewMergeTool() should return error for unterminated quote")
+	}
+}
+
+// TestDescribeMergeToolResult tests the status line shown after the tool exits.
+func TestDescribeMergeToolResult(t *testing.T) {
+	if got := describeMergeToolResult("meld", nil); got != "Returned from meld" {
+		t.Errorf("describeMergeToolResult() = %q", got)
+	}
+	got := describeMergeToolResult("meld", errors.New("exit status 2"))
+	if !strings.Contains(got, "exit status 2") {
+		t.Errorf("describeMergeToolResult() = %q, expected error text", got)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"unicode/utf8"
+)
+
+// moveFile moves path to target, creating target's directory if needed. It
+// refuses to replace an existing file. Across file systems, where renaming
+// fails, the file is copied with its mode and modification time and the
+// original removed once the copy is complete.
+func moveFile(path, target string) error {
+	if _, err := os.Lstat(target); err == nil {
+		return fmt.Errorf("%s already exists", target)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+
+	err := os.Rename(path, target)
+	if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+	if err := copyFile(path, target); err != nil {
+		os.Remove(target)
+		return err
+	}
+	return os.Remove(path)
+}
+
+// copyFile copies the content, mode, and modification time of path to target,
+// which must not exist.
+func copyFile(path, target string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	dst, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(target, info.ModTime(), info.ModTime())
+}
+
+// expandHome replaces a leading "~" in path with the user's home directory.
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~"+string(filepath.Separator)) && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
+
+// completeDir completes the last element of a directory path being typed. It
+// returns the completed input, extended to the longest prefix shared by the
+// matching subdirectories with a trailing separator once only one matches, and
+// the names of the matches.
+func completeDir(input string) (string, []string) {
+	dir, partial := filepath.Split(input)
+	parent := expandHome(cmp.Or(dir, "."))
+	entries, err := os.ReadDir(parent)
+	if err != nil {
+		return input, nil
+	}
+
+	var matches []string
+	for _, e := range entries {
+		name := e.Name()
+		// Hidden directories are only offered once a dot is typed
+		if !strings.HasPrefix(name, partial) || (strings.HasPrefix(name, ".") && !strings.HasPrefix(partial, ".")) {
+			continue
+		}
+		// Stat follows symbolic links to directories
+		if info, err := os.Stat(filepath.Join(parent, name)); err == nil && info.IsDir() {
+			matches = append(matches, name)
+		}
+	}
+	sort.Strings(matches)
+
+	switch len(matches) {
+	case 0:
+		return input, nil
+	case 1:
+		return dir + matches[0] + string(filepath.Separator), matches
+	}
+	prefix := matches[0]
+	for _, m := range matches[1:] {
+		prefix = commonPrefix(prefix, m)
+	}
+	for !utf8.ValidString(prefix) {
+		prefix = prefix[:len(prefix)-1]
+	}
+	return dir + prefix, matches
+}
+
+// pathWithin reports whether path is root or lies below it. Both must be absolute.
+func pathWithin(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestMoveFile tests moving into a new directory and refusing to overwrite.
+func TestMoveFile(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	path := createFileWithContent(t, tmpDir, "notes 2.txt", "copy\n")
+	mtime := time.Date(2024, 1, 30, 12, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join(tmpDir, "quarantine", "sub", "notes 2.txt")
+
+	if err := moveFile(path, target); err != nil {
+		t.Fatalf("moveFile() returned error: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("the original should be gone")
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		t.Fatalf("target should exist: %v", err)
+	}
+	if !info.ModTime().Equal(mtime) {
+		t.Errorf("mtime = %v, expected %v", info.ModTime(), mtime)
+	}
+
+	other := createFileWithContent(t, tmpDir, "notes 2.txt", "other\n")
+	if err := moveFile(other, target); err == nil {
+		t.Error("moveFile() should refuse to replace an existing file")
+	}
+	if content, _ := os.ReadFile(target); string(content) != "copy\n" {
+		t.Errorf("target content = %q, expected it unchanged", content)
+	}
+}
+
+// TestCopyFile tests the copy used when moving across file systems.
+func TestCopyFile(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	path := createFileWithContent(t, tmpDir, "a.txt", "data\n")
+	if err := os.Chmod(path, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join(tmpDir, "b.txt")
+	if err := copyFile(path, target); err != nil {
+		t.Fatalf("copyFile() returned error: %v", err)
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("mode = %v, expected 0600", info.Mode().Perm())
+	}
+	if err := copyFile(path, target); err == nil {
+		t.Error("copyFile() should refuse to replace an existing file")
+	}
+}
+
+// TestCompleteDir tests completing directory names.
+func TestCompleteDir(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	for _, dir := range []string{"quarantine", "quotes", "reports", ".trash"} {
+		if err := os.Mkdir(filepath.Join(tmpDir, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	createFile(t, tmpDir, "queue.txt")
+	sep := string(filepath.Separator)
+
+	tests := []struct {
+		input   string
+		want    string
+		matches []string
+	}{
+		{tmpDir + sep + "r", tmpDir + sep + "reports" + sep, []string{"reports"}},
+		{tmpDir + sep + "q", tmpDir + sep + "qu", []string{"quarantine", "quotes"}},
+		{tmpDir + sep + "qua", tmpDir + sep + "quarantine" + sep, []string{"quarantine"}},
+		{tmpDir + sep + ".", tmpDir + sep + ".trash" + sep, []string{".trash"}},
+		{tmpDir + sep + "x", tmpDir + sep + "x", nil},
+		{filepath.Join(tmpDir, "missing", "q"), filepath.Join(tmpDir, "missing", "q"), nil},
+	}
+	for _, tt := range tests {
+		got, matches := completeDir(tt.input)
+		if got != tt.want || !reflect.DeepEqual(matches, tt.matches) {
+			t.Errorf("completeDir(%q) = %q, %v; expected %q, %v", tt.input, got, matches, tt.want, tt.matches)
+		}
+	}
+}
+
+// TestPathWithin tests the check for paths inside a directory.
+func TestPathWithin(t *testing.T) {
+	root := filepath.FromSlash("/scan")
+	for path, want := range map[string]bool{
+		"/scan":            true,
+		"/scan/a/b.txt":    true,
+		"/scanned/b.txt":   false,
+		"/other/b.txt":     false,
+		"/scan/../b.txt":   false,
+		"/scan/..hidden/b": true,
+	} {
+		if got := pathWithin(root, filepath.FromSlash(path)); got != want {
+			t.Errorf("pathWithin(%q, %q) = %v, expected %v", root, path, got, want)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// multiWayMaxCells caps the size of the line alignment table (base lines times
+	// variant lines) so huge files don't exhaust memory.
+	multiWayMaxCells = 4_000_000
+	// multiWayContext is the number of unchanged rows shown around each change.
+	multiWayContext = 2
+)
+
+// readLines returns the lines of a text file without line endings. An empty
+// file has no lines.
+func readLines(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	decoded, _ := decodeFile(data)
+	text := strings.TrimSuffix(strings.ReplaceAll(decoded, "\r\n", "\n"), "\n")
+	if text == "" {
+		return nil, nil
+	}
+	return strings.Split(text, "\n"), nil
+}
+
+// groupBase returns the group member whose name (without extension) is a prefix of
+// every other member's name, such as "notes.txt" for "notes-1.txt" and "notes-2.txt".
+// ok is false when no member is a clear base.
+func groupBase(group []string) (base string, ok bool) {
+	stem := func(file string) string {
+		name := filepath.Base(file)
+		return strings.TrimSuffix(name, filepath.Ext(name))
+	}
+
+	for _, candidate := range group {
+		prefix := stem(candidate)
+		isBase := true
+		for _, other := range group {
+			if other == candidate {
+				continue
+			}
+			if s := stem(other); s == prefix || !strings.HasPrefix(s, prefix) {
+				isBase = false
+				break
+			}
+		}
+		if isBase {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+// lineEdit is how a variant treats one base line, plus the lines it inserts before it.
+type lineEdit struct {
+	kept     bool
+	inserted []string
+}
+
+// alignToBase aligns variant against base using the longest common subsequence of
+// lines. The result has one entry per base line plus a final entry holding lines
+// appended after the last base line. equal decides whether two lines match.
+func alignToBase(base, variant []string, equal func(a, b string) bool) ([]lineEdit, error) {
+	n, m := len(base), len(variant)
+	if (n+1)*(m+1) > multiWayMaxCells {
+		return nil, fmt.Errorf("files too large for a side-by-side column view (%d and %d lines)", n, m)
+	}
+
+	// lcs[i][j] is the LCS length of base[i:] and variant[j:]
+	lcs := make([][]int32, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if equal(base[i], variant[j]) {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	edits := make([]lineEdit, n+1)
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && equal(base[i], variant[j]):
+			edits[i].kept = true
+			i++
+			j++
+		case j < m && (i == n || lcs[i][j+1] > lcs[i+1][j]):
+			edits[i].inserted = append(edits[i].inserted, variant[j])
+			j++
+		default:
+			i++
+		}
+	}
+	return edits, nil
+}
+
+// multiWayRow is one row of a column view: column 0 is the base file and the others
+// are variants. Each mark is ' ' for a line equal to the base, '+' for an added line,
+// '-' for a removed base line, and 0 when the column is empty in this row.
+type multiWayRow struct {
+	text    []string
+	marks   []byte
+	changed bool
+}
+
+// multiWayRows merges the alignments of several variants against the same base into rows.
+func multiWayRows(base []string, variants [][]lineEdit) []multiWayRow {
+	newRow := func() multiWayRow {
+		return multiWayRow{text: make([]string, len(variants)+1), marks: make([]byte, len(variants)+1)}
+	}
+
+	var rows []multiWayRow
+	for i := 0; i <= len(base); i++ {
+		// Lines inserted before base line i, paired up across the variants
+		inserted := 0
+		for _, edits := range variants {
+			inserted = max(inserted, len(edits[i].inserted))
+		}
+		for k := 0; k < inserted; k++ {
+			row := newRow()
+			row.changed = true
+			for v, edits := range variants {
+				if k < len(edits[i].inserted) {
+					row.text[v+1], row.marks[v+1] = edits[i].inserted[k], '+'
+				}
+			}
+			rows = append(rows, row)
+		}
+		if i == len(base) {
+			break
+		}
+
+		row := newRow()
+		row.text[0], row.marks[0] = base[i], ' '
+		for v, edits := range variants {
+			if edits[i].kept {
+				row.text[v+1], row.marks[v+1] = base[i], ' '
+			} else {
+				row.marks[v+1] = '-'
+				row.changed = true
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// multiWayDiff renders each variant in its own column next to the base file, showing
+// only changed rows and a little context, so it is clear which variant holds which
+// edits. When ignoreWhitespace is set, lines that differ only in whitespace are equal.
+func multiWayDiff(baseFile string, variantFiles []string, width int, ignoreWhitespace bool) (string, error) {
+	files := append([]string{baseFile}, variantFiles...)
+	lines := make([][]string, len(files))
+	for k, file := range files {
+		var err error
+		lines[k], err = readLines(file)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	equal := func(a, b string) bool { return a == b }
+	if ignoreWhitespace {
+		equal = func(a, b string) bool {
+			return strings.Join(strings.Fields(a), "") == strings.Join(strings.Fields(b), "")
+		}
+	}
+	variants := make([][]lineEdit, len(variantFiles))
+	for v := range variantFiles {
+		edits, err := alignToBase(lines[0], lines[v+1], equal)
+		if err != nil {
+			return "", err
+		}
+		variants[v] = edits
+	}
+	rows := multiWayRows(lines[0], variants)
+
+	// Each column has a mark and a space, and columns are separated by " │ "
+	colWidth := max((width-3*(len(files)-1))/len(files)-2, 10)
+	cell := func(text string, mark byte) string {
+		if mark == 0 {
+			return strings.Repeat(" ", colWidth+2)
+		}
+		text = truncateLine(text, colWidth)
+		return string(mark) + " " + text + strings.Repeat(" ", colWidth-len([]rune(text)))
+	}
+	render := func(text []string, marks []byte) string {
+		cells := make([]string, len(text))
+		for k := range text {
+			cells[k] = cell(text[k], marks[k])
+		}
+		return strings.TrimRight(strings.Join(cells, " │ "), " ")
+	}
+
+	var s strings.Builder
+	header := make([]string, len(files))
+	headerMarks := make([]byte, len(files))
+	for k, file := range files {
+		header[k], headerMarks[k] = filepath.Base(file), ' '
+	}
+	s.WriteString(render(header, headerMarks))
+	s.WriteString("\n")
+
+	changes := 0
+	lastShown := -1
+	for i, row := range rows {
+		if row.changed {
+			changes++
+		}
+		if !nearChange(rows, i) {
+			continue
+		}
+		if i > lastShown+1 {
+			s.WriteString("⋯\n")
+		}
+		lastShown = i
+		s.WriteString(render(row.text, row.marks))
+		s.WriteString("\n")
+	}
+	if changes == 0 {
+		s.WriteString("All files are identical to the base.\n")
+	}
+	return s.String(), nil
+}
+
+// nearChange reports whether row i is a change or within multiWayContext rows of one.
+func nearChange(rows []multiWayRow, i int) bool {
+	for k := max(0, i-multiWayContext); k <= min(len(rows)-1, i+multiWayContext); k++ {
+		if rows[k].changed {
+			return true
+		}
+	}
+	return false
+}
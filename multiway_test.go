@@ -0,0 +1,166 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestGroupBase tests detection of the file the other members are variants of.
+func TestGroupBase(t *testing.T) {
+	tests := []struct {
+		name  string
+		group []string
+		want  string
+	}{
+		{"base with numbered variants", []string{"/d/notes-1.txt", "/d/notes.txt", "/d/notes-2.txt"}, "/d/notes.txt"},
+		{"base with a different extension", []string{"/d/report.md", "/d/report-final.txt"}, "/d/report.md"},
+		{"no common base", []string{"/d/notes-1.txt", "/d/notes-2.txt"}, ""},
+		{"two candidates with the same name", []string{"/d/notes.txt", "/d/notes.md", "/d/notes-1.txt"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := groupBase(tt.group)
+			if got != tt.want || ok != (tt.want != "") {
+				t.Errorf("groupBase() = %q, %v; expected %q", got, ok, tt.want)
+			}
+		})
+	}
+}
+
+// TestMultiWayDiff tests that each variant's edits appear in its own column.
+func TestMultiWayDiff(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	base := createFileWithContent(t, tmpDir, "list.txt", "apples\nbread\ncheese\n")
+	left := createFileWithContent(t, tmpDir, "list-1.txt", "apples\nbread\ncheese\ndates\n")
+	right := createFileWithContent(t, tmpDir, "list-2.txt", "apples\ncheese\n")
+
+	output, err := multiWayDiff(base, []string{left, right}, 120, false)
+	if err != nil {
+		t.Fatalf("multiWayDiff() returned error: %v", err)
+	}
+
+	lines := strings.Split(output, "\n")
+	if !strings.Contains(lines[0], "list.txt") || !strings.Contains(lines[0], "list-2.txt") {
+		t.Errorf("header = %q, expected all three file names", lines[0])
+	}
+
+	var breadRow, datesRow string
+	for _, line := range lines {
+		if strings.Contains(line, "bread") {
+			breadRow = line
+		}
+		if strings.Contains(line, "dates") {
+			datesRow = line
+		}
+	}
+	// bread is kept by list-1 and removed by list-2
+	if cols := strings.Split(breadRow, " │ "); len(cols) != 3 || !strings.HasPrefix(cols[1], "  bread") || !strings.HasPrefix(cols[2], "-") {
+		t.Errorf("bread row = %q, expected it kept in list-1 and removed in list-2", breadRow)
+	}
+	// dates is only added by list-1
+	if cols := strings.Split(datesRow, " │ "); len(cols) < 2 || !strings.HasPrefix(cols[1], "+ dates") {
+		t.Errorf("dates row = %q, expected it added in list-1", datesRow)
+	}
+}
+
+// TestMultiWayDiff_Identical tests the message shown when neither variant has edits.
+func TestMultiWayDiff_Identical(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	var files []string
+	for _, name := range []string{"a.txt", "a-1.txt", "a-2.txt"} {
+		files = append(files, createFileWithContent(t, tmpDir, name, "same\r\ncontent\n"))
+	}
+
+	output, err := multiWayDiff(files[0], files[1:], 80, false)
+	if err != nil {
+		t.Fatalf("multiWayDiff() returned error: %v", err)
+	}
+	if !strings.Contains(output, "identical to the base") {
+		t.Errorf("multiWayDiff() = %q, expected the identical message", output)
+	}
+}
+
+// TestTUI_ThreeWay tests opening the 3-way view from the first file selection.
+func TestTUI_ThreeWay(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	var group []string
+	for _, name := range []string{"list.txt", "list-1.txt", "list-2.txt"} {
+		group = append(group, createFileWithContent(t, tmpDir, name, name+"\n"))
+	}
+
+	m := initialModel([][]string{group}, NewDiffExecutor(""), nil)
+	m.width, m.height = 120, 40
+	m = sendKey(t, m, "enter")
+	m = sendKey(t, m, "3")
+
+	if m.state != stateViewDiff || m.baseFile != filepath.Join(tmpDir, "list.txt") {
+		t.Fatalf("state = %v, base = %q; expected the 3-way view against list.txt", m.state, m.baseFile)
+	}
+	if !strings.Contains(m.View(), "Base:") {
+		t.Error("View() should name the base file")
+	}
+
+	m = sendKey(t, m, "esc")
+	if m.baseFile != "" {
+		t.Error("leaving the diff view should clear the base file")
+	}
+}
+
+// TestTUI_CompareMarked tests comparing several marked files against the first one.
+func TestTUI_CompareMarked(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	var group []string
+	for _, name := range []string{"note-1.md", "note-2.md", "note-3.md", "note-4.md"} {
+		group = append(group, createFileWithContent(t, tmpDir, name, "shared\n"+name+"\n"))
+	}
+
+	m := initialModel([][]string{group}, NewDiffExecutor(""), nil)
+	m.width, m.height = 160, 40
+	m = sendKey(t, m, "enter")
+
+	m = sendKey(t, m, "c")
+	if m.state != stateSelectFirstFile || m.status == "" {
+		t.Fatal("c without marked files should only report a status")
+	}
+
+	// Mark note-3 first so it becomes the reference, then note-1 and note-4
+	for _, key := range []string{"down", "down", "m", "home", "m", "end", "m", "c"} {
+		m = sendKey(t, m, key)
+	}
+	if m.state != stateViewDiff {
+		t.Fatalf("state = %v, expected the diff view", m.state)
+	}
+	if m.baseFile != group[2] || len(m.variants) != 2 || m.variants[0] != group[0] || m.variants[1] != group[3] {
+		t.Errorf("base = %q, variants = %v; expected note-3.md against note-1.md and note-4.md", m.baseFile, m.variants)
+	}
+	header := strings.SplitN(m.diffOutput, "\n", 2)[0]
+	if strings.Count(header, "│") != 2 {
+		t.Errorf("header = %q, expected three columns", header)
+	}
+}
+
+// TestTUI_ToggleMark tests that marking a file twice unmarks it.
+func TestTUI_ToggleMark(t *testing.T) {
+	m := initialModel(testGroups(1), NewDiffExecutor(""), nil)
+	m.width, m.height = 80, 40
+	m = sendKey(t, m, "enter")
+
+	m = sendKey(t, m, "m")
+	if !strings.Contains(m.View(), "[1]") {
+		t.Error("View() should show the mark position")
+	}
+	m = sendKey(t, m, "m")
+	if len(m.marked) != 0 {
+		t.Errorf("marked = %v, expected none after toggling twice", m.marked)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// defaultEditor is used when neither $VISUAL nor $EDITOR is set.
+const defaultEditor = "vi"
+
+// editorCommand builds the command that opens file in the user's editor, taken from
+// $VISUAL or $EDITOR. The variable may include arguments, e.g. "code --wait".
+func editorCommand(file string) (*exec.Cmd, error) {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	argv, err := splitCommandLine(editor)
+	if err != nil {
+		return nil, fmt.Errorf("invalid $EDITOR: %w", err)
+	}
+	if len(argv) == 0 {
+		argv = []string{defaultEditor}
+	}
+	return logCommand(exec.Command(argv[0], append(argv[1:], file)...)), nil
+}
+
+// systemOpenCommand builds the command that opens file in the platform's default
+// application for its type.
+func systemOpenCommand(file string) *exec.Cmd {
+	argv := systemOpenArgs(runtime.GOOS, file)
+	return logCommand(exec.Command(argv[0], argv[1:]...))
+}
+
+// systemOpenArgs returns the command line that opens file on goos. On Windows
+// it is handed to the shell's file handler directly rather than to cmd's
+// start, which would run a name such as a&calc.exe as two commands.
+func systemOpenArgs(goos, file string) []string {
+	switch goos {
+	case "darwin":
+		return []string{"open", file}
+	case "windows":
+		return []string{"rundll32", "url.dll,FileProtocolHandler", file}
+	}
+	return []string{"xdg-open", file}
+}
+
+// fileOpenedMsg is sent to the TUI when an editor or system viewer exits, with the
+// status line to show.
+type fileOpenedMsg struct {
+	status string
+}
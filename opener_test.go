@@ -0,0 +1,64 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestEditorCommand tests that $VISUAL takes precedence and may include arguments.
+func TestEditorCommand(t *testing.T) {
+	t.Setenv("VISUAL", "code --wait")
+	t.Setenv("EDITOR", "nano")
+
+	cmd, err := editorCommand("/tmp/a.txt")
+	if err != nil {
+		t.Fatalf("editorCommand() returned error: %v", err)
+	}
+	got := strings.Join(cmd.Args, " ")
+	if got != "code --wait /tmp/a.txt" {
+		t.Errorf("editorCommand().Args = %q, expected %q", got, "code --wait /tmp/a.txt")
+	}
+}
+
+// TestEditorCommand_Default tests the fallback when no editor is configured.
+func TestEditorCommand_Default(t *testing.T) {
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", "")
+
+	cmd, err := editorCommand("/tmp/a.txt")
+	if err != nil {
+		t.Fatalf("editorCommand() returned error: %v", err)
+	}
+	if cmd.Args[0] != defaultEditor {
+		t.Errorf("editorCommand() ran %q, expected %q", cmd.Args[0], defaultEditor)
+	}
+}
+
+// TestEditorCommand_Invalid tests that quoting errors in $EDITOR are reported.
+func TestEditorCommand_Invalid(t *testing.T) {
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", "vim 'unterminated")
+
+	if _, err := editorCommand("/tmp/a.txt"); err == nil {
+		t.Error("editorCommand() should return error for unterminated quote")
+	}
+}
+
+// TestSystemOpenCommand tests that the file is passed as the last argument.
+func TestSystemOpenCommand(t *testing.T) {
+	cmd := systemOpenCommand("/tmp/a.txt")
+	if last := cmd.Args[len(cmd.Args)-1]; last != "/tmp/a.txt" {
+		t.Errorf("systemOpenCommand() last argument = %q, expected the file", last)
+	}
+}
+
+// TestSystemOpenArgs_Windows tests that Windows opens files without cmd, whose
+// start command would treat & in a name as a command separator.
+func TestSystemOpenArgs_Windows(t *testing.T) {
+	argv := systemOpenArgs("windows", `C:\Users\me\a&calc.exe`)
+	expected := []string{"rundll32", "url.dll,FileProtocolHandler", `C:\Users\me\a&calc.exe`}
+	if !slices.Equal(argv, expected) {
+		t.Errorf("systemOpenArgs() = %q, expected %q", argv, expected)
+	}
+}
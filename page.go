@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+)
+
+// groupPage selects the groups of one page of output for --offset and
+// --limit, so wrappers can read the groups of a large scan a page at a time.
+// The zero value selects every group.
+type groupPage struct {
+	offset int
+	// limit is the most groups on the page; zero means no limit.
+	limit int
+}
+
+// bounds returns the indexes of the first group on the page and of the group
+// after the last, out of total.
+func (p groupPage) bounds(total int) (start, end int) {
+	start = min(p.offset, total)
+	end = total
+	if p.limit > 0 {
+		end = min(start+p.limit, total)
+	}
+	return start, end
+}
+
+// slice returns the groups on the page.
+func (p groupPage) slice(groups [][]string) [][]string {
+	start, end := p.bounds(len(groups))
+	return groups[start:end]
+}
+
+// note returns a line saying which of total groups the page holds and where
+// the next page starts, or "" if it holds them all.
+func (p groupPage) note(total int) string {
+	start, end := p.bounds(total)
+	switch {
+	case start == 0 && end == total:
+		return ""
+	case start+1 == end && end < total:
+		return fmt.Sprintf("Showing group %d of %d; the next page starts at --offset %d.", end, total, end)
+	case start+1 == end:
+		return fmt.Sprintf("Showing group %d of %d.", end, total)
+	case start == end:
+		return fmt.Sprintf("No groups on this page: --offset %d is past the last of %s.", p.offset, plural(total, "group"))
+	case end < total:
+		return fmt.Sprintf("Showing groups %d-%d of %d; the next page starts at --offset %d.", start+1, end, total, end)
+	}
+	return fmt.Sprintf("Showing groups %d-%d of %d.", start+1, end, total)
+}
+
+// summaryLabel names the summary line of a report of the groups on the page.
+func (p groupPage) summaryLabel() string {
+	if p == (groupPage{}) {
+		return "Total"
+	}
+	return "On this page"
+}
+
+// writePageNote writes the note of page to w, if there is one. Text output
+// takes it at the end, and machine-readable output leaves it to stderr.
+func writePageNote(w io.Writer, page groupPage, total int) {
+	if note := page.note(total); note != "" {
+		fmt.Fprintln(w, note)
+	}
+}
+
+// pageFlags are the --offset and --limit flags of commands that list groups.
+type pageFlags struct {
+	offset *int
+	limit  *int
+}
+
+// addPageFlags registers --offset and --limit on fs.
+func addPageFlags(fs *flag.FlagSet) *pageFlags {
+	return &pageFlags{
+		offset: fs.Int("offset", 0, "Skip this many groups, to read the output in pages with --limit"),
+		limit:  fs.Int("limit", 0, "List at most this many groups (0 lists all), noting the total when there are more"),
+	}
+}
+
+// page validates the flags.
+func (f *pageFlags) page() (groupPage, error) {
+	if *f.offset < 0 || *f.limit < 0 {
+		return groupPage{}, errors.New("offset and limit must not be negative")
+	}
+	return groupPage{offset: *f.offset, limit: *f.limit}, nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestGroupPage tests selecting a page of groups and noting the total.
+func TestGroupPage(t *testing.T) {
+	groups := [][]string{{"a", "a-1"}, {"b", "b-1"}, {"c", "c-1"}, {"d", "d-1"}, {"e", "e-1"}}
+	tests := []struct {
+		page groupPage
+		want [][]string
+		note string
+	}{
+		{groupPage{}, groups, ""},
+		{groupPage{limit: 5}, groups, ""},
+		{groupPage{limit: 2}, groups[:2], "Showing groups 1-2 of 5; the next page starts at --offset 2."},
+		{groupPage{offset: 2, limit: 2}, groups[2:4], "Showing groups 3-4 of 5; the next page starts at --offset 4."},
+		{groupPage{offset: 4, limit: 2}, groups[4:], "Showing group 5 of 5."},
+		{groupPage{offset: 3}, groups[3:], "Showing groups 4-5 of 5."},
+		{groupPage{offset: 1, limit: 1}, groups[1:2], "Showing group 2 of 5; the next page starts at --offset 2."},
+		{groupPage{offset: 7, limit: 2}, groups[5:], "No groups on this page: --offset 7 is past the last of 5 groups."},
+	}
+	for _, tt := range tests {
+		if got := tt.page.slice(groups); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%+v: slice() = %v, expected %v", tt.page, got, tt.want)
+		}
+		if got := tt.page.note(len(groups)); got != tt.note {
+			t.Errorf("%+v: note() = %q, expected %q", tt.page, got, tt.note)
+		}
+	}
+	if note := (groupPage{offset: 2}).note(0); note != "" {
+		t.Errorf("a scan without groups should have no note, got %q", note)
+	}
+}
+
+// TestRunReport_Page tests that a page of a report keeps the numbers of its
+// groups and counts every group found.
+func TestRunReport_Page(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	for _, name := range []string{"alpha.md", "alpha-1.md", "bravo.md", "bravo-1.md", "charlie.md", "charlie-1.md"} {
+		createFileWithContent(t, tmpDir, name, name)
+	}
+
+	var records []FileRecord
+	opts := options{dir: tmpDir, minPrefix: 3, page: groupPage{offset: 1, limit: 1}}
+	total, err := runReport(context.Background(), opts, io.Discard, func(_ io.Writer, r []FileRecord) error {
+		records = r
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("runReport() returned error: %v", err)
+	}
+	if total != 3 {
+		t.Errorf("runReport() = %d groups, expected all 3", total)
+	}
+	if len(records) != 2 || records[0].Group != 2 || filepath.Base(records[0].Path) != "bravo-1.md" {
+		t.Errorf("runReport() wrote %+v, expected the second group only", records)
+	}
+}
+
+// TestWriteGroupList_Page tests that a page of the listing leaves the count
+// of all groups to the note of the page instead of counting only its own.
+func TestWriteGroupList_Page(t *testing.T) {
+	groups := [][]string{{"bravo.md", "bravo-1.md"}}
+
+	var out bytes.Buffer
+	if err := writeGroupListWithChecksums(&out, ".", groups, nil, nil, groupPage{offset: 1, limit: 1}); err != nil {
+		t.Fatalf("writeGroupListWithChecksums() returned error: %v", err)
+	}
+	if strings.Contains(out.String(), "Found") || !strings.HasPrefix(out.String(), "Group 2: 2 files") {
+		t.Errorf("writeGroupListWithChecksums() = %q, expected the second group without a count", out.String())
+	}
+
+	out.Reset()
+	if err := writeGroupListWithChecksums(&out, ".", nil, nil, nil, groupPage{offset: 5}); err != nil || out.Len() != 0 {
+		t.Errorf("writeGroupListWithChecksums() = %q, %v; expected an empty page to be left to its note", out.String(), err)
+	}
+
+	var records []FileRecord
+	for _, path := range groups[0] {
+		records = append(records, FileRecord{Group: 2, Path: path, Hash: "0123456789abcdef"})
+	}
+	out.Reset()
+	if err := writeTextReportPage(&out, records, groupPage{offset: 1, limit: 1}); err != nil {
+		t.Fatalf("writeTextReportPage() returned error: %v", err)
+	}
+	if !strings.Contains(out.String(), "On this page: 1 group") || strings.Contains(out.String(), "Total:") {
+		t.Errorf("writeTextReportPage() should label the totals as those of the page:\n%s", out.String())
+	}
+}
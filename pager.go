@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// defaultPager is used when $PAGER is not set. -R passes colors through, -F
+// exits at once when the text fits on one screen, and -X leaves the text on
+// screen after exiting so it is still visible at the next prompt.
+var defaultPager = []string{"less", "-FRX"}
+
+// defaultPageHeight is assumed for the terminal when $LINES is not exported.
+const defaultPageHeight = 24
+
+// pagerCommand returns the command line of the pager: $PAGER, or defaultPager.
+func pagerCommand() ([]string, error) {
+	pager := strings.TrimSpace(os.Getenv("PAGER"))
+	if pager == "" {
+		return defaultPager, nil
+	}
+	return splitCommandLine(pager)
+}
+
+// pageHeight returns the number of lines that fit on the terminal without
+// paging: $LINES if it is exported, or defaultPageHeight.
+func pageHeight() int {
+	lines, err := strconv.Atoi(os.Getenv("LINES"))
+	if err != nil || lines <= 0 {
+		return defaultPageHeight
+	}
+	return lines
+}
+
+// runPager shows text in the pager, which reads its keys from the terminal, and
+// returns once the user leaves it.
+func runPager(ctx context.Context, pager []string, text string) error {
+	cmd := logCommand(exec.CommandContext(ctx, pager[0], pager[1:]...))
+	cmd.Stdin = strings.NewReader(text)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	return cmd.Run()
+}
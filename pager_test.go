@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestPagerCommand tests choosing the pager from $PAGER.
+func TestPagerCommand(t *testing.T) {
+	t.Setenv("PAGER", "")
+	if got, err := pagerCommand(); err != nil || !reflect.DeepEqual(got, defaultPager) {
+		t.Errorf("pagerCommand() = %v, %v, expected the default pager", got, err)
+	}
+
+	t.Setenv("PAGER", "most -s")
+	if got, err := pagerCommand(); err != nil || !reflect.DeepEqual(got, []string{"most", "-s"}) {
+		t.Errorf("pagerCommand() = %v, %v, expected $PAGER split into arguments", got, err)
+	}
+}
+
+// TestInteractiveCLI_Page tests that only text taller than the terminal goes
+// through the pager.
+func TestInteractiveCLI_Page(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	t.Setenv("LINES", "5")
+
+	paged := filepath.Join(tmpDir, "paged.txt")
+	cli := NewInteractiveCLI(nil, NewDiffExecutor(""))
+	cli.pager = []string{"sh", "-c", "cat > " + paged}
+	var output bytes.Buffer
+	cli.writer = &output
+
+	cli.page("short\n")
+	if output.String() != "short\n" {
+		t.Errorf("short text written as %q, expected it directly", output.String())
+	}
+
+	output.Reset()
+	long := strings.Repeat("line\n", 10)
+	cli.page(long)
+	if output.Len() != 0 {
+		t.Errorf("long text was also written directly: %q", output.String())
+	}
+	if data, err := os.ReadFile(paged); err != nil || string(data) != long {
+		t.Errorf("pager got %q, %v, expected the long text", data, err)
+	}
+
+	// A pager that can't be started falls back to writing directly
+	cli.pager = []string{filepath.Join(tmpDir, "no-such-pager")}
+	cli.page(long)
+	if output.String() != long {
+		t.Errorf("without a working pager, wrote %q, expected the long text", output.String())
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// absolutePath returns path made absolute and cleaned, with the drive letter
+// of a Windows path in upper case, so that a file has the same path however
+// it was typed: C:\Notes and c:\notes\..\Notes name the same directory. The
+// path is otherwise left in the platform's form, with backslashes on Windows.
+func absolutePath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	return upperDriveLetter(abs), nil
+}
+
+// upperDriveLetter returns path with a leading drive letter such as "c:" in
+// upper case.
+func upperDriveLetter(path string) string {
+	if len(path) >= 2 && path[1] == ':' && ('a' <= path[0] && path[0] <= 'z') {
+		return strings.ToUpper(path[:1]) + path[1:]
+	}
+	return path
+}
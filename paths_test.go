@@ -0,0 +1,20 @@
+package main
+
+import "testing"
+
+// TestUpperDriveLetter tests that Windows drive letters are upper-cased and
+// other paths are left alone.
+func TestUpperDriveLetter(t *testing.T) {
+	tests := map[string]string{
+		`c:\Users\notes`: `C:\Users\notes`,
+		`D:\backup`:      `D:\backup`,
+		`/home/c:`:       `/home/c:`,
+		`\\server\share`: `\\server\share`,
+		"":               "",
+	}
+	for path, expected := range tests {
+		if got := upperDriveLetter(path); got != expected {
+			t.Errorf("upperDriveLetter(%q) = %q, expected %q", path, got, expected)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// planVersion is the format version written to plan files.
+const planVersion = 1
+
+// PlanAction is a cleanup decision for one file.
+type PlanAction string
+
+const (
+	ActionKeep     PlanAction = "keep"
+	ActionDelete   PlanAction = "delete"
+	ActionHardlink PlanAction = "hardlink"
+)
+
+// PlanEntry is one decision in a cleanup plan. For delete and hardlink actions,
+// Target is the kept file whose content Path duplicates.
+type PlanEntry struct {
+	Action PlanAction `json:"action"`
+	Path   string     `json:"path"`
+	Size   int64      `json:"size"`
+	Hash   string     `json:"sha256"`
+	Target string     `json:"target,omitempty"`
+	// Group is the fingerprint of the file's group; see groupFingerprint.
+	Group string `json:"group,omitempty"`
+}
+
+// Plan is a machine-readable list of cleanup decisions that can be reviewed
+// before being applied.
+type Plan struct {
+	Version int         `json:"version"`
+	Created time.Time   `json:"created"`
+	Dir     string      `json:"dir"`
+	Entries []PlanEntry `json:"entries"`
+}
+
+// buildCleanPlan keeps one of the files identical to each group leader, chosen
+// by cleanOpts.keep (the leader itself by default), and removes (or hardlinks,
+// with cleanOpts.hardlink) the others. Files that differ from their leader are
+// kept, as are hard links to it, which are already deduplicated, symbolic
+// links, and the files a symbolic link of the group points to. With
+// cleanOpts.identicalOnly, groups holding any differing file are kept whole.
+func buildCleanPlan(dir string, records []FileRecord, cleanOpts cleanOptions) *Plan {
+	plan := &Plan{Version: planVersion, Created: time.Now().UTC(), Dir: dir}
+
+	redundant := ActionDelete
+	if cleanOpts.hardlink {
+		redundant = ActionHardlink
+	}
+
+	for _, group := range recordGroups(records) {
+		var copies []FileRecord
+		for _, r := range group {
+			if r.Leader || (r.IdenticalToLeader && !r.HardlinkToLeader) {
+				copies = append(copies, r)
+			}
+		}
+		resolve := !cleanOpts.identicalOnly || !mixedGroup(group)
+		targets := linkTargets(group)
+		i := cleanOpts.keep.pick(copies)
+		if i < 0 {
+			resolve = false
+			i = 0
+		}
+		keeper := copies[i]
+
+		for _, r := range group {
+			entry := PlanEntry{Action: ActionKeep, Path: r.Path, Size: r.Size, Hash: r.Hash, Group: r.Fingerprint}
+			if r.Symlink || targets[r.Path] {
+				// Removing a link frees nothing, and removing its target
+				// leaves it dangling
+				plan.Entries = append(plan.Entries, entry)
+				continue
+			}
+			if resolve && r.Path != keeper.Path && (r.Leader || (r.IdenticalToLeader && !r.HardlinkToLeader)) {
+				entry.Action = redundant
+				entry.Target = keeper.Path
+			}
+			plan.Entries = append(plan.Entries, entry)
+		}
+	}
+	return plan
+}
+
+// recordGroups splits records into their groups, which are consecutive and
+// start with the leader.
+func recordGroups(records []FileRecord) [][]FileRecord {
+	var groups [][]FileRecord
+	for i, r := range records {
+		if i == 0 || r.Group != records[i-1].Group {
+			groups = append(groups, nil)
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], r)
+	}
+	return groups
+}
+
+// mixedGroup reports whether any file of group differs from its leader.
+func mixedGroup(group []FileRecord) bool {
+	for _, r := range group {
+		if !r.Leader && !r.IdenticalToLeader {
+			return true
+		}
+	}
+	return false
+}
+
+// writePlan writes a plan as indented JSON.
+func writePlan(w io.Writer, plan *Plan) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(plan)
+}
+
+// writePlanYAML writes a plan as YAML, for plans that are edited by hand.
+func writePlanYAML(w io.Writer, plan *Plan) error {
+	return writeYAML(w, plan)
+}
+
+// readPlan loads a plan written by writePlan or writePlanYAML.
+func readPlan(path string) (*Plan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var plan Plan
+	if err := decodeJSONOrYAML(data, &plan); err != nil {
+		return nil, fmt.Errorf("invalid plan %s: %w", path, err)
+	}
+	if plan.Version != planVersion {
+		return nil, fmt.Errorf("unsupported plan version %d", plan.Version)
+	}
+	return &plan, nil
+}
+
+// planSummary counts the redundant entries of a plan and their total size.
+func planSummary(plan *Plan) (count int, size int64) {
+	for _, e := range plan.Entries {
+		if e.Action != ActionKeep {
+			count++
+			size += e.Size
+		}
+	}
+	return count, size
+}
+
+// applyPlan executes the delete and hardlink decisions of a plan. Before acting on
+// an entry, the hashes of both the file and its target are re-checked against the
+// plan, so files changed since the plan was written are skipped rather than lost.
+// Deleted files are moved into q instead if it is set. Every action is logged
+// to w. Returns an error if any entry was skipped, or if ctx
+// was cancelled, in which case the remaining entries are left untouched.
+func applyPlan(ctx context.Context, plan *Plan, q *Quarantine, w io.Writer) error {
+	var skipped int
+	for _, e := range plan.Entries {
+		if e.Action == ActionKeep {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := verifyEntry(ctx, e); err != nil {
+			fmt.Fprintf(w, "skipped %s: %v\n", e.Path, err)
+			skipped++
+			continue
+		}
+
+		var err error
+		switch e.Action {
+		case ActionDelete:
+			_, err = q.Remove(e.Path)
+		case ActionHardlink:
+			err = replaceWithHardlink(e.Target, e.Path)
+		default:
+			err = fmt.Errorf("unknown action %q", e.Action)
+		}
+		if err != nil {
+			fmt.Fprintf(w, "skipped %s: %v\n", e.Path, err)
+			skipped++
+			continue
+		}
+		fmt.Fprintf(w, "%s %s (identical to %s)\n", pastTense(e.Action, q), e.Path, e.Target)
+	}
+
+	if skipped > 0 {
+		return fmt.Errorf("%d plan entries skipped", skipped)
+	}
+	return nil
+}
+
+// verifyEntry checks that an entry's file and target are different files that
+// still have the planned content. A hand-edited plan could otherwise name a file,
+// or a link to it, as its own target and remove the only copy.
+func verifyEntry(ctx context.Context, e PlanEntry) error {
+	if e.Target == "" {
+		return errors.New("the entry has no target")
+	}
+	same, err := resolvesToSameFile(e.Path, e.Target)
+	if err != nil {
+		return err
+	}
+	if same {
+		return fmt.Errorf("the target %s is the same file", e.Target)
+	}
+	for _, path := range []string{e.Path, e.Target} {
+		hash, err := hashFile(ctx, path, nil)
+		if err != nil {
+			return err
+		}
+		if hash != e.Hash {
+			return fmt.Errorf("%s changed since the plan was written", path)
+		}
+	}
+	return nil
+}
+
+// resolvesToSameFile reports whether two paths name the same file once symbolic
+// links are followed, including hard links to one file.
+func resolvesToSameFile(path1, path2 string) (bool, error) {
+	var infos [2]os.FileInfo
+	for i, path := range []string{path1, path2} {
+		resolved, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			return false, err
+		}
+		if infos[i], err = os.Stat(resolved); err != nil {
+			return false, err
+		}
+	}
+	return os.SameFile(infos[0], infos[1]), nil
+}
+
+// replaceWithHardlink atomically replaces path with a hard link to target, or
+// to the file target points to if it is a symbolic link.
+func replaceWithHardlink(target, path string) error {
+	// os.Link links a symbolic link itself rather than the file it points to
+	target, err := filepath.EvalSymlinks(target)
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(filepath.Dir(path), fmt.Sprintf(".doppel-link-%d", time.Now().UnixNano()))
+	if err := os.Link(target, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// pastTense describes a completed action for logs.
+func pastTense(a PlanAction, q *Quarantine) string {
+	switch a {
+	case ActionDelete:
+		if q != nil {
+			return "quarantined"
+		}
+		return "removed"
+	case ActionHardlink:
+		return "hardlinked"
+	}
+	return string(a)
+}
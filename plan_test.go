@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// planFixture creates a leader, an identical copy, and an edited copy, and returns their records.
+func planFixture(t *testing.T, dir string) []FileRecord {
+	leader := createFileWithContent(t, dir, "notes-1.txt", "same\n")
+	copyPath := createFileWithContent(t, dir, "notes-2.txt", "same\n")
+	edited := createFileWithContent(t, dir, "notes-3.txt", "edited\n")
+
+	records, err := buildFileRecords(context.Background(), [][]string{{leader, copyPath, edited}}, nil, nil)
+	if err != nil {
+		t.Fatalf("buildFileRecords() returned error: %v", err)
+	}
+	return records
+}
+
+// TestBuildCleanPlan tests keep/delete/hardlink decisions.
+func TestBuildCleanPlan(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	records := planFixture(t, tmpDir)
+
+	plan := buildCleanPlan(tmpDir, records, cleanOptions{})
+	expected := []PlanAction{ActionKeep, ActionDelete, ActionKeep}
+	for i, want := range expected {
+		if plan.Entries[i].Action != want {
+			t.Errorf("Entries[%d].Action = %q, expected %q", i, plan.Entries[i].Action, want)
+		}
+	}
+	if plan.Entries[1].Target != records[0].Path {
+		t.Errorf("Entries[1].Target = %q, expected the leader", plan.Entries[1].Target)
+	}
+
+	plan = buildCleanPlan(tmpDir, records, cleanOptions{hardlink: true})
+	if plan.Entries[1].Action != ActionHardlink {
+		t.Errorf("with hardlink, Entries[1].Action = %q, expected %q", plan.Entries[1].Action, ActionHardlink)
+	}
+
+	count, size := planSummary(plan)
+	if count != 1 || size != 5 {
+		t.Errorf("planSummary() = (%d, %d), expected (1, 5)", count, size)
+	}
+}
+
+// TestBuildCleanPlan_Keep tests keeping a file other than the leader and
+// leaving groups with differing files alone.
+func TestBuildCleanPlan_Keep(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	records := planFixture(t, tmpDir)
+	records[1].ModTime = records[0].ModTime.Add(time.Hour)
+
+	plan := buildCleanPlan(tmpDir, records, cleanOptions{keep: Keeper{Policy: KeepNewest}})
+	expected := []PlanAction{ActionDelete, ActionKeep, ActionKeep}
+	for i, want := range expected {
+		if plan.Entries[i].Action != want {
+			t.Errorf("Entries[%d].Action = %q, expected %q", i, plan.Entries[i].Action, want)
+		}
+	}
+	if plan.Entries[0].Target != records[1].Path {
+		t.Errorf("Entries[0].Target = %q, expected the newest copy", plan.Entries[0].Target)
+	}
+
+	plan = buildCleanPlan(tmpDir, records, cleanOptions{identicalOnly: true})
+	if count, _ := planSummary(plan); count != 0 {
+		t.Errorf("with identical-only, a group holding an edited file should be left alone, got %d actions", count)
+	}
+	plan = buildCleanPlan(tmpDir, records[:2], cleanOptions{identicalOnly: true})
+	if count, _ := planSummary(plan); count != 1 {
+		t.Errorf("with identical-only, a group of copies should be resolved, got %d actions", count)
+	}
+}
+
+// TestPlan_RoundTrip tests that a written plan reads back unchanged.
+func TestPlan_RoundTrip(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	plan := buildCleanPlan(tmpDir, planFixture(t, tmpDir), cleanOptions{})
+
+	var buf bytes.Buffer
+	if err := writePlan(&buf, plan); err != nil {
+		t.Fatalf("writePlan() returned error: %v", err)
+	}
+	planPath := createFileWithContent(t, tmpDir, "plan.json", buf.String())
+
+	loaded, err := readPlan(planPath)
+	if err != nil {
+		t.Fatalf("readPlan() returned error: %v", err)
+	}
+	if len(loaded.Entries) != len(plan.Entries) || loaded.Entries[1] != plan.Entries[1] {
+		t.Errorf("readPlan() = %+v, expected %+v", loaded.Entries, plan.Entries)
+	}
+}
+
+// TestApplyPlan_Delete tests that apply removes planned duplicates.
+func TestApplyPlan_Delete(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	records := planFixture(t, tmpDir)
+	plan := buildCleanPlan(tmpDir, records, cleanOptions{})
+
+	var out bytes.Buffer
+	if err := applyPlan(context.Background(), plan, nil, &out); err != nil {
+		t.Fatalf("applyPlan() returned error: %v", err)
+	}
+	if _, err := os.Stat(records[1].Path); !os.IsNotExist(err) {
+		t.Errorf("applyPlan() did not remove %s", records[1].Path)
+	}
+	for _, kept := range []string{records[0].Path, records[2].Path} {
+		if _, err := os.Stat(kept); err != nil {
+			t.Errorf("applyPlan() removed kept file %s", kept)
+		}
+	}
+}
+
+// TestApplyPlan_Hardlink tests that apply replaces duplicates with hard links.
+func TestApplyPlan_Hardlink(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	records := planFixture(t, tmpDir)
+	plan := buildCleanPlan(tmpDir, records, cleanOptions{hardlink: true})
+
+	var out bytes.Buffer
+	if err := applyPlan(context.Background(), plan, nil, &out); err != nil {
+		t.Fatalf("applyPlan() returned error: %v", err)
+	}
+	leaderInfo, err := os.Stat(records[0].Path)
+	if err != nil {
+		t.Fatalf("Stat() failed: %v", err)
+	}
+	copyInfo, err := os.Stat(records[1].Path)
+	if err != nil {
+		t.Fatalf("Stat() failed: %v", err)
+	}
+	if !os.SameFile(leaderInfo, copyInfo) {
+		t.Error("applyPlan() should leave the copy hardlinked to the leader")
+	}
+	leftovers, _ := filepath.Glob(filepath.Join(tmpDir, ".doppel-link-*"))
+	if len(leftovers) != 0 {
+		t.Errorf("applyPlan() left temporary links: %v", leftovers)
+	}
+}
+
+// TestApplyPlan_SkipsChangedFiles tests that files edited after planning are not touched.
+func TestApplyPlan_SkipsChangedFiles(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	records := planFixture(t, tmpDir)
+	plan := buildCleanPlan(tmpDir, records, cleanOptions{})
+
+	// A sync client edits the copy after the plan was reviewed
+	if err := os.WriteFile(records[1].Path, []byte("new edits\n"), 0644); err != nil {
+		t.Fatalf("WriteFile() failed: %v", err)
+	}
+
+	var out bytes.Buffer
+	err := applyPlan(context.Background(), plan, nil, &out)
+	if err == nil {
+		t.Fatal("applyPlan() should report skipped entries")
+	}
+	if _, err := os.Stat(records[1].Path); err != nil {
+		t.Error("applyPlan() removed a file that changed since planning")
+	}
+	if !strings.Contains(out.String(), "changed since the plan was written") {
+		t.Errorf("applyPlan() output missing skip reason:\n%s", out.String())
+	}
+}
+
+// TestApplyPlan_SkipsSelfTargets tests that entries whose target is the file
+// itself, a link to it, or missing are skipped rather than removing the only copy.
+func TestApplyPlan_SkipsSelfTargets(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	records := planFixture(t, tmpDir)
+	path := records[0].Path
+	link := filepath.Join(tmpDir, "link.txt")
+	if err := os.Symlink(path, link); err != nil {
+		t.Fatalf("Symlink() failed: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		want   string
+	}{
+		{"self", path, "is the same file"},
+		{"symlink", link, "is the same file"},
+		{"empty", "", "has no target"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := &Plan{Entries: []PlanEntry{{Path: path, Action: ActionDelete, Target: tt.target, Hash: records[0].Hash}}}
+			var out bytes.Buffer
+			if err := applyPlan(context.Background(), plan, nil, &out); err == nil {
+				t.Error("applyPlan() should report the skipped entry")
+			}
+			if _, err := os.Stat(path); err != nil {
+				t.Fatalf("applyPlan() removed the only copy: %v", err)
+			}
+			if !strings.Contains(out.String(), tt.want) {
+				t.Errorf("applyPlan() output = %q, want it to contain %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+// TestReadPlan_Invalid tests rejecting malformed plans.
+func TestReadPlan_Invalid(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	for name, content := range map[string]string{
+		"garbage.json": "not json",
+		"future.json":  `{"version": 99}`,
+	} {
+		path := createFileWithContent(t, tmpDir, name, content)
+		if _, err := readPlan(path); err == nil {
+			t.Errorf("readPlan(%s) should return error", name)
+		}
+	}
+}
+
+// TestApplyPlan_Cancelled tests that a cancelled context stops a plan before any file is touched.
+func TestApplyPlan_Cancelled(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	leader := createFileWithContent(t, tmpDir, "report.txt", "same")
+	copyPath := createFileWithContent(t, tmpDir, "report-1.txt", "same")
+
+	records, err := buildFileRecords(context.Background(), [][]string{{leader, copyPath}}, nil, nil)
+	if err != nil {
+		t.Fatalf("buildFileRecords() returned error: %v", err)
+	}
+	plan := buildCleanPlan(tmpDir, records, cleanOptions{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var out bytes.Buffer
+	if err := applyPlan(ctx, plan, nil, &out); !errors.Is(err, context.Canceled) {
+		t.Errorf("applyPlan() error = %v, expected context.Canceled", err)
+	}
+	if _, err := os.Stat(copyPath); err != nil {
+		t.Errorf("applyPlan() touched %s after cancellation", copyPath)
+	}
+}
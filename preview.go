@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// previewReadLimit caps how much of a file is read to build a preview.
+	previewReadLimit = 64 * 1024
+	// previewTabWidth is the number of spaces a tab expands to in previews.
+	previewTabWidth = 4
+	// previewSideMinWidth is the terminal width from which the preview is shown
+	// beside the file list instead of below it.
+	previewSideMinWidth = 100
+	// previewMinHeight is the terminal height below which no preview is shown.
+	previewMinHeight = 16
+)
+
+// filePreview returns up to maxLines lines from the start of a file, each cut to
+// maxWidth columns. Binary files are described instead of shown.
+func filePreview(path string, maxLines, maxWidth int) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	buf := make([]byte, previewReadLimit)
+	n, _ := f.Read(buf)
+	data := buf[:n]
+	enc, text := detectEncoding(data, info.Size() > int64(n))
+	if !text {
+		return fmt.Sprintf("(binary file, %s)", formatBytes(info.Size())), nil
+	}
+	if len(data) == 0 {
+		return "(empty file)", nil
+	}
+
+	return previewLines(decodeText(data, enc), maxLines, maxWidth), nil
+}
+
+// previewLines returns up to maxLines lines from the start of text, each cut
+// to maxWidth columns.
+func previewLines(text string, maxLines, maxWidth int) string {
+	var lines []string
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	scanner.Buffer(make([]byte, previewReadLimit), previewReadLimit)
+	for len(lines) < maxLines && scanner.Scan() {
+		lines = append(lines, truncateLine(scanner.Text(), maxWidth))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// truncateLine expands tabs and cuts a line to at most width runes, replacing
+// invalid UTF-8 and control characters so previews never garble the terminal.
+func truncateLine(line string, width int) string {
+	line = strings.ReplaceAll(line, "\t", strings.Repeat(" ", previewTabWidth))
+	line = strings.ToValidUTF8(line, "�")
+	line = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return '�'
+		}
+		return r
+	}, line)
+	if width < 1 || utf8.RuneCountInString(line) <= width {
+		return line
+	}
+	runes := []rune(line)
+	return string(runes[:width-1]) + "…"
+}
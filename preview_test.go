@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestFilePreview tests that previews are limited in lines and width.
+func TestFilePreview(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	createFileWithContent(t, tmpDir, "notes.txt", "first\n\tindented\na very long line that will not fit\nfourth\n")
+
+	got, err := filePreview(filepath.Join(tmpDir, "notes.txt"), 3, 12)
+	if err != nil {
+		t.Fatalf("filePreview() returned error: %v", err)
+	}
+	want := "first\n    indented\na very long…"
+	if got != want {
+		t.Errorf("filePreview() = %q, expected %q", got, want)
+	}
+}
+
+// TestFilePreview_Special tests the descriptions of empty and binary files.
+func TestFilePreview_Special(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	createFileWithContent(t, tmpDir, "empty.txt", "")
+	createFileWithContent(t, tmpDir, "data.bin", "ab\x00cd")
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"empty.txt", "(empty file)"},
+		{"data.bin", "(binary file, 5 B)"},
+	}
+	for _, tt := range tests {
+		got, err := filePreview(filepath.Join(tmpDir, tt.name), 10, 80)
+		if err != nil {
+			t.Fatalf("filePreview(%s) returned error: %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("filePreview(%s) = %q, expected %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestTruncateLine_ControlCharacters tests that escape sequences are neutralized.
+func TestTruncateLine_ControlCharacters(t *testing.T) {
+	got := truncateLine("red\x1b[31mtext", 80)
+	if strings.ContainsRune(got, '\x1b') {
+		t.Errorf("truncateLine() = %q, expected the escape character to be replaced", got)
+	}
+}
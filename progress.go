@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// progressInterval is how often progress displays are refreshed.
+const progressInterval = 100 * time.Millisecond
+
+// spinnerFrames are the animation frames shown while work is in progress.
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// Progress counts work done by long-running phases (scanning, hashing).
+// It is safe for concurrent use; a nil *Progress ignores all updates.
+type Progress struct {
+	files atomic.Int64
+	bytes atomic.Int64
+	phase atomic.Value
+}
+
+// NewProgress creates a Progress starting in the given phase.
+func NewProgress(phase string) *Progress {
+	p := &Progress{}
+	p.SetPhase(phase)
+	return p
+}
+
+// SetPhase records a short description of the current phase (e.g. "Scanning").
+func (p *Progress) SetPhase(phase string) {
+	if p == nil {
+		return
+	}
+	p.phase.Store(phase)
+}
+
+// AddFiles records n more files processed.
+func (p *Progress) AddFiles(n int) {
+	if p == nil {
+		return
+	}
+	p.files.Add(int64(n))
+}
+
+// AddBytes records n more bytes processed.
+func (p *Progress) AddBytes(n int64) {
+	if p == nil {
+		return
+	}
+	p.bytes.Add(n)
+}
+
+// String returns a one-line description such as "Scanning: 1204 files, 3.1 MB".
+func (p *Progress) String() string {
+	phase, _ := p.phase.Load().(string)
+	line := fmt.Sprintf("%s: %d files", phase, p.files.Load())
+	if b := p.bytes.Load(); b > 0 {
+		line += ", " + formatBytes(b)
+	}
+	return line
+}
+
+// formatBytes renders a byte count with a binary unit suffix.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// isTerminal reports whether f is attached to a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// startProgressPrinter redraws a spinner and the progress line on w until the
+// returned stop function is called, which clears the line. Nothing is printed
+// when stderr is not a terminal, so redirected output stays clean.
+func startProgressPrinter(w io.Writer, p *Progress) (stop func()) {
+	if !isTerminal(os.Stderr) {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for frame := 0; ; frame++ {
+			select {
+			case <-done:
+				fmt.Fprint(w, "\r\033[K")
+				return
+			case <-ticker.C:
+				fmt.Fprintf(w, "\r\033[K%s %s", spinnerFrames[frame%len(spinnerFrames)], p)
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-finished
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+// TestProgress_String tests the progress summary line.
+func TestProgress_String(t *testing.T) {
+	p := NewProgress("Scanning")
+	p.AddFiles(3)
+	if got := p.String(); got != "Scanning: 3 files" {
+		t.Errorf("String() = %q, expected %q", got, "Scanning: 3 files")
+	}
+
+	p.SetPhase("Hashing")
+	p.AddBytes(2048)
+	if got := p.String(); got != "Hashing: 3 files, 2.0 KB" {
+		t.Errorf("String() = %q, expected %q", got, "Hashing: 3 files, 2.0 KB")
+	}
+}
+
+// TestProgress_Nil tests that a nil Progress ignores updates.
+func TestProgress_Nil(t *testing.T) {
+	var p *Progress
+	p.AddFiles(1)
+	p.AddBytes(1)
+	p.SetPhase("Scanning")
+}
+
+// TestFormatBytes tests human-readable byte counts.
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		input    int64
+		expected string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KB"},
+		{1536, "1.5 KB"},
+		{5 * 1024 * 1024, "5.0 MB"},
+		{3 * 1024 * 1024 * 1024, "3.0 GB"},
+	}
+	for _, tt := range tests {
+		if got := formatBytes(tt.input); got != tt.expected {
+			t.Errorf("formatBytes(%d) = %q, expected %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+// TestStartProgressPrinter_NotTerminal tests that nothing is printed when stderr is redirected.
+func TestStartProgressPrinter_NotTerminal(t *testing.T) {
+	if isTerminal(os.Stderr) {
+		t.Skip("stderr is a terminal")
+	}
+	var out bytes.Buffer
+	stop := startProgressPrinter(&out, NewProgress("Scanning"))
+	stop()
+	if out.Len() != 0 {
+		t.Errorf("startProgressPrinter() wrote %q, expected nothing", out.String())
+	}
+}
+
+// TestScanner_Scan_ReportsProgress tests that the scanner counts files into Progress.
+func TestScanner_Scan_ReportsProgress(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		createFile(t, tmpDir, name)
+	}
+
+	p := NewProgress("Scanning")
+	scanner := NewScanner(tmpDir)
+	scanner.SetProgress(p)
+	if _, err := scanner.Scan(); err != nil {
+		t.Fatalf("Scan() returned error: %v", err)
+	}
+	if got := p.files.Load(); got != 3 {
+		t.Errorf("Progress counted %d files, expected 3", got)
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// quarantineManifest is the file inside a quarantine directory that records
+// where each quarantined file came from, one JSON object per line.
+const quarantineManifest = ".doppel-quarantine.jsonl"
+
+// QuarantineEntry records one file moved into a quarantine directory.
+type QuarantineEntry struct {
+	// Path is the file's location relative to the quarantine directory.
+	Path string `json:"path"`
+	// Original is the absolute path the file was moved from.
+	Original    string    `json:"original"`
+	Quarantined time.Time `json:"quarantined"`
+}
+
+// Quarantine moves removed files into a directory instead of deleting them,
+// keeping their paths relative to the scanned directory, so a cleanup can be
+// undone with "doppel restore". A nil Quarantine deletes files.
+type Quarantine struct {
+	dir  string
+	root string
+	// mu serializes moves and manifest appends, for the HTTP API.
+	mu sync.Mutex
+}
+
+// NewQuarantine returns a Quarantine that moves files into dir, creating it if
+// needed, and mirrors their paths relative to root. dir must not be inside any
+// of the scanned directories (root if none are given), or later scans would
+// find the quarantined copies again.
+func NewQuarantine(dir, root string, scanned ...string) (*Quarantine, error) {
+	dir, root = absPath(dir), absPath(root)
+	if len(scanned) == 0 {
+		scanned = []string{root}
+	}
+	for _, s := range scanned {
+		if pathWithin(absPath(s), dir) {
+			return nil, fmt.Errorf("quarantine directory %s must be outside the scanned directory %s", dir, s)
+		}
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Quarantine{dir: dir, root: root}, nil
+}
+
+// openQuarantine returns the Quarantine for --quarantine dir with the scan
+// options opts, or nil if dir is empty. In compare mode paths are mirrored
+// relative to the common parent of both trees.
+func openQuarantine(dir string, opts options) (*Quarantine, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	root, _ := displayRoot(opts)
+	scanned := []string{opts.dir}
+	if opts.compareDir != "" {
+		scanned = append(scanned, opts.compareDir)
+	}
+	return NewQuarantine(dir, root, scanned...)
+}
+
+// Remove moves path into the quarantine and records it in the manifest, or
+// deletes it if q is nil. A file already quarantined under the same name is
+// kept, and the new one gets a numbered suffix. Returns where the file was
+// moved, or "" if it was deleted.
+func (q *Quarantine) Remove(path string) (string, error) {
+	if q == nil {
+		return "", os.Remove(path)
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	original := absPath(path)
+	rel, err := filepath.Rel(q.root, original)
+	if err != nil || !pathWithin(q.root, original) {
+		// Keep the whole path for files from outside the scanned directory
+		rel = strings.TrimLeft(strings.TrimPrefix(original, filepath.VolumeName(original)), string(filepath.Separator))
+	}
+	target := filepath.Join(q.dir, rel)
+	for n := 1; ; n++ {
+		if _, err := os.Lstat(target); errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		target = filepath.Join(q.dir, rel) + "." + strconv.Itoa(n)
+	}
+	if err := moveFile(original, target); err != nil {
+		return "", err
+	}
+
+	rel, _ = filepath.Rel(q.dir, target)
+	entry := QuarantineEntry{Path: filepath.ToSlash(rel), Original: original, Quarantined: time.Now().UTC()}
+	if err := appendQuarantineEntry(q.dir, entry); err != nil {
+		// Without a record the file couldn't be restored, so put it back
+		moveFile(target, original)
+		return "", fmt.Errorf("failed to record quarantined file: %w", err)
+	}
+	return target, nil
+}
+
+// Dir returns the quarantine directory.
+func (q *Quarantine) Dir() string {
+	return q.dir
+}
+
+// Describe returns how a removal is reported, e.g. "Deleted a.txt" or
+// "Quarantined a.txt".
+func (q *Quarantine) Describe(name string) string {
+	if q == nil {
+		return "Deleted " + name
+	}
+	return "Quarantined " + name
+}
+
+// appendQuarantineEntry adds entry to the manifest of dir.
+func appendQuarantineEntry(dir string, entry QuarantineEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, quarantineManifest), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readQuarantineManifest returns the entries recorded in dir.
+func readQuarantineManifest(dir string) ([]QuarantineEntry, error) {
+	f, err := os.Open(filepath.Join(dir, quarantineManifest))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%s is not a quarantine directory: no %s", dir, quarantineManifest)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []QuarantineEntry
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var e QuarantineEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("invalid quarantine manifest line %d: %w", line, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// writeQuarantineManifest replaces the manifest of dir with entries, removing
+// it once no entries are left.
+func writeQuarantineManifest(dir string, entries []QuarantineEntry) error {
+	path := filepath.Join(dir, quarantineManifest)
+	if len(entries) == 0 {
+		return os.Remove(path)
+	}
+	tmp, err := os.CreateTemp(dir, ".doppel-quarantine-*.jsonl")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	enc := json.NewEncoder(tmp)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// unquarantine moves the file quarantined at path in dir back to where it came
+// from and drops it from the manifest.
+func unquarantine(dir, path string) error {
+	entries, err := readQuarantineManifest(dir)
+	if err != nil {
+		return err
+	}
+	for i, e := range entries {
+		if filepath.Join(dir, filepath.FromSlash(e.Path)) != path {
+			continue
+		}
+		if err := moveFile(path, e.Original); err != nil {
+			return err
+		}
+		return writeQuarantineManifest(dir, slices.Delete(entries, i, i+1))
+	}
+	return fmt.Errorf("%s is not listed in the quarantine manifest", path)
+}
+
+// restoreQuarantine moves the files quarantined in dir back to where they came
+// from, logging each to w. Files whose original location is taken again are
+// skipped and stay in the quarantine, listed in its manifest. Returns an error
+// if any file was skipped.
+func restoreQuarantine(dir string, w io.Writer) error {
+	entries, err := readQuarantineManifest(dir)
+	if err != nil {
+		return err
+	}
+
+	var remaining []QuarantineEntry
+	for _, e := range entries {
+		path := filepath.Join(dir, filepath.FromSlash(e.Path))
+		if err := moveFile(path, e.Original); err != nil {
+			fmt.Fprintf(w, "skipped %s: %v\n", e.Original, err)
+			remaining = append(remaining, e)
+			continue
+		}
+		fmt.Fprintf(w, "restored %s\n", e.Original)
+	}
+	if err := writeQuarantineManifest(dir, remaining); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "Restored %d of %d file(s)\n", len(entries)-len(remaining), len(entries))
+	if len(remaining) > 0 {
+		return fmt.Errorf("%d file(s) left in quarantine", len(remaining))
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestQuarantine_Remove tests that removed files keep their relative paths and
+// that name clashes get a numbered suffix.
+func TestQuarantine_Remove(t *testing.T) {
+	root := createTempDir(t)
+	defer os.RemoveAll(root)
+	qDir := createTempDir(t)
+	defer os.RemoveAll(qDir)
+
+	if err := os.Mkdir(filepath.Join(root, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	first := createFileWithContent(t, root, filepath.Join("sub", "notes-1.txt"), "first\n")
+
+	q, err := NewQuarantine(qDir, root)
+	if err != nil {
+		t.Fatalf("NewQuarantine() returned error: %v", err)
+	}
+	if _, err := q.Remove(first); err != nil {
+		t.Fatalf("Remove() returned error: %v", err)
+	}
+	if _, err := os.Stat(first); !os.IsNotExist(err) {
+		t.Error("Remove() should move the file away")
+	}
+	if content, _ := os.ReadFile(filepath.Join(qDir, "sub", "notes-1.txt")); string(content) != "first\n" {
+		t.Errorf("quarantined content = %q, expected %q", content, "first\n")
+	}
+
+	second := createFileWithContent(t, root, filepath.Join("sub", "notes-1.txt"), "second\n")
+	if _, err := q.Remove(second); err != nil {
+		t.Fatalf("Remove() returned error: %v", err)
+	}
+	if content, _ := os.ReadFile(filepath.Join(qDir, "sub", "notes-1.txt.1")); string(content) != "second\n" {
+		t.Errorf("clashing file content = %q, expected %q", content, "second\n")
+	}
+
+	entries, err := readQuarantineManifest(qDir)
+	if err != nil {
+		t.Fatalf("readQuarantineManifest() returned error: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Path != "sub/notes-1.txt" || entries[1].Path != "sub/notes-1.txt.1" || entries[1].Original != second {
+		t.Errorf("manifest entries = %+v", entries)
+	}
+}
+
+// TestQuarantine_Nil tests that a nil Quarantine deletes files.
+func TestQuarantine_Nil(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	path := createFileWithContent(t, tmpDir, "a.txt", "a\n")
+	var q *Quarantine
+	if _, err := q.Remove(path); err != nil {
+		t.Fatalf("Remove() returned error: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("Remove() on a nil Quarantine should delete the file")
+	}
+	if got := q.Describe("a.txt"); got != "Deleted a.txt" {
+		t.Errorf("Describe() = %q, expected %q", got, "Deleted a.txt")
+	}
+}
+
+// TestNewQuarantine_InsideScan tests refusing a quarantine that later scans would find.
+func TestNewQuarantine_InsideScan(t *testing.T) {
+	root := createTempDir(t)
+	defer os.RemoveAll(root)
+
+	if _, err := NewQuarantine(filepath.Join(root, "quarantine"), root); err == nil {
+		t.Error("NewQuarantine() should refuse a directory inside the scanned one")
+	}
+}
+
+// TestRestoreQuarantine tests moving files back and keeping those whose
+// original path was taken again.
+func TestRestoreQuarantine(t *testing.T) {
+	root := createTempDir(t)
+	defer os.RemoveAll(root)
+	qDir := createTempDir(t)
+	defer os.RemoveAll(qDir)
+
+	kept := createFileWithContent(t, root, "a.txt", "a\n")
+	restored := createFileWithContent(t, root, "b.txt", "b\n")
+	q, err := NewQuarantine(qDir, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{kept, restored} {
+		if _, err := q.Remove(path); err != nil {
+			t.Fatalf("Remove() returned error: %v", err)
+		}
+	}
+	createFileWithContent(t, root, "a.txt", "new\n")
+
+	var out bytes.Buffer
+	if err := restoreQuarantine(qDir, &out); err == nil {
+		t.Error("restoreQuarantine() should report files left in quarantine")
+	}
+	if content, _ := os.ReadFile(restored); string(content) != "b\n" {
+		t.Errorf("restored content = %q, expected %q", content, "b\n")
+	}
+	if content, _ := os.ReadFile(kept); string(content) != "new\n" {
+		t.Errorf("restore replaced a newer file: content = %q", content)
+	}
+	if !strings.Contains(out.String(), "skipped "+kept) || !strings.Contains(out.String(), "restored "+restored) {
+		t.Errorf("restoreQuarantine() output:\n%s", out.String())
+	}
+
+	entries, err := readQuarantineManifest(qDir)
+	if err != nil || len(entries) != 1 || entries[0].Original != kept {
+		t.Errorf("manifest after restore = %+v, %v; expected only %s", entries, err, kept)
+	}
+
+	// Once the path is free again, restoring finishes and removes the manifest
+	if err := os.Remove(kept); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if err := restoreQuarantine(qDir, &out); err != nil {
+		t.Fatalf("restoreQuarantine() returned error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(qDir, quarantineManifest)); !os.IsNotExist(err) {
+		t.Error("restoreQuarantine() should remove an empty manifest")
+	}
+}
+
+// TestRunClean_Quarantine tests that clean --force --quarantine moves copies away.
+func TestRunClean_Quarantine(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	qDir := createTempDir(t)
+	defer os.RemoveAll(qDir)
+
+	createFileWithContent(t, tmpDir, "notes-1.txt", "same\n")
+	copyPath := createFileWithContent(t, tmpDir, "notes-2.txt", "same\n")
+
+	opts := options{dir: tmpDir, minPrefix: 3}
+	q, err := openQuarantine(qDir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.quarantine = q
+
+	var out bytes.Buffer
+	if err := runClean(context.Background(), opts, cleanOptions{force: true}, &out); err != nil {
+		t.Fatalf("runClean() returned error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(qDir, "notes-2.txt")); err != nil {
+		t.Errorf("copy should be quarantined: %v", err)
+	}
+	if !strings.Contains(out.String(), "quarantined "+copyPath) {
+		t.Errorf("runClean() output missing quarantine log:\n%s", out.String())
+	}
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+// FileRecord describes one grouped file in batch reports.
+type FileRecord struct {
+	Group   int
+	Path    string
+	Size    int64
+	ModTime time.Time
+	Hash    string
+	// Leader marks the first file of a group, which the others are compared against.
+	Leader bool
+	// IdenticalToLeader reports whether the file's content matches the group leader's.
+	IdenticalToLeader bool
+	// HardlinkToLeader reports whether the file is a hard link to the group leader,
+	// so the two already share storage.
+	HardlinkToLeader bool
+	// Symlink reports whether the path is a symbolic link, whose size, time,
+	// and hash are those of the file it points to.
+	Symlink bool
+	// Fingerprint identifies the file's group by its members; see groupFingerprint.
+	// It is set by setFingerprints.
+	Fingerprint string
+}
+
+// setFingerprints sets the fingerprint of every record, relative to the
+// scanned directory dir.
+func setFingerprints(dir string, records []FileRecord) {
+	i := 0
+	for _, group := range recordGroups(records) {
+		paths := make([]string, len(group))
+		for j, r := range group {
+			paths[j] = r.Path
+		}
+		fingerprint := groupFingerprint(dir, paths)
+		for range group {
+			records[i].Fingerprint = fingerprint
+			i++
+		}
+	}
+}
+
+// buildFileRecords stats and hashes every grouped file. Groups are numbered from 1
+// in the order given. Hard links to the group leader reuse its hash, and hashes
+// of unchanged files are taken from cache, which may be nil. Files inside
+// archives are decompressed to hash them, and never cached.
+func buildFileRecords(ctx context.Context, groups [][]string, cache *HashCache, progress *Progress) ([]FileRecord, error) {
+	progress.SetPhase("Hashing")
+
+	var records []FileRecord
+	for i, group := range groups {
+		var leaderHash string
+		var leaderInfo os.FileInfo
+		for j, file := range group {
+			member := isArchiveMember(file)
+			stat := os.Stat
+			if member {
+				stat = statArchiveMember
+			}
+			info, err := stat(file)
+			if err != nil {
+				return nil, err
+			}
+			// Links are told apart by the link itself, so a symbolic link to
+			// the leader isn't taken for a hard link
+			var linkInfo os.FileInfo = info
+			if !member {
+				if linkInfo, err = os.Lstat(file); err != nil {
+					return nil, err
+				}
+			}
+			symlink := linkInfo.Mode()&os.ModeSymlink != 0
+			linked := j > 0 && !member && !symlink && os.SameFile(linkInfo, leaderInfo)
+			hash := leaderHash
+			switch {
+			case member:
+				hash, err = hashArchiveMember(ctx, file, progress)
+			case !linked:
+				hash, err = cache.FullHash(ctx, file, progress)
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			if j == 0 {
+				leaderHash, leaderInfo = hash, linkInfo
+			}
+			records = append(records, FileRecord{
+				Group:             i + 1,
+				Path:              file,
+				Size:              info.Size(),
+				ModTime:           info.ModTime(),
+				Hash:              hash,
+				Leader:            j == 0,
+				IdenticalToLeader: j > 0 && hash == leaderHash,
+				HardlinkToLeader:  linked,
+				Symlink:           symlink,
+			})
+		}
+	}
+	return records, nil
+}
+
+// csvHeader lists the columns written by writeCSV.
+var csvHeader = []string{"group", "path", "size", "mtime", "sha256", "identical_to_leader", "hardlink_to_leader", "fingerprint"}
+
+// writeCSV writes one row per file. The identical_to_leader column is "leader"
+// for the first file of each group and "true" or "false" for the others.
+// hardlink_to_leader is "true" for files that already share the leader's storage.
+// fingerprint identifies the group across runs.
+func writeCSV(w io.Writer, records []FileRecord) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, r := range records {
+		identical := strconv.FormatBool(r.IdenticalToLeader)
+		if r.Leader {
+			identical = "leader"
+		}
+		row := []string{
+			strconv.Itoa(r.Group),
+			r.Path,
+			strconv.FormatInt(r.Size, 10),
+			r.ModTime.Format(time.RFC3339),
+			r.Hash,
+			identical,
+			strconv.FormatBool(r.HardlinkToLeader),
+			r.Fingerprint,
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+	return nil
+}
+
+// jsonReport is the format of "report --json" and "report --yaml", which
+// --baseline reads back.
+type jsonReport struct {
+	// Dir is the scanned directory, which fingerprints are relative to.
+	Dir    string      `json:"dir"`
+	Groups []jsonGroup `json:"groups"`
+	Total  jsonTotal   `json:"total"`
+}
+
+// jsonGroup is a group of a JSON report.
+type jsonGroup struct {
+	Group int `json:"group"`
+	// Fingerprint identifies the group by its members; see groupFingerprint.
+	Fingerprint string     `json:"fingerprint"`
+	Files       []jsonFile `json:"files"`
+}
+
+// jsonFile is a file of a JSON report, with the fields of its FileRecord.
+type jsonFile struct {
+	Path              string    `json:"path"`
+	Size              int64     `json:"size"`
+	ModTime           time.Time `json:"mtime"`
+	Hash              string    `json:"sha256"`
+	Leader            bool      `json:"leader"`
+	IdenticalToLeader bool      `json:"identical_to_leader"`
+	HardlinkToLeader  bool      `json:"hardlink_to_leader"`
+}
+
+// jsonTotal is the summary of a JSON report.
+type jsonTotal struct {
+	Groups      int   `json:"groups"`
+	Files       int   `json:"files"`
+	Reclaimable int64 `json:"reclaimable_bytes"`
+}
+
+// writeJSONReport writes the records of a scan of dir as a JSON report, with
+// the fingerprint of each group for --baseline.
+func writeJSONReport(w io.Writer, dir string, records []FileRecord) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(newJSONReport(dir, records))
+}
+
+// writeYAMLReport writes the report of writeJSONReport as YAML.
+func writeYAMLReport(w io.Writer, dir string, records []FileRecord) error {
+	return writeYAML(w, newJSONReport(dir, records))
+}
+
+// newJSONReport returns the report of the records of a scan of dir.
+func newJSONReport(dir string, records []FileRecord) jsonReport {
+	summary := summarizeRecords(records)
+	report := jsonReport{
+		Dir:    dir,
+		Groups: []jsonGroup{},
+		Total:  jsonTotal{Groups: summary.Groups, Files: summary.Files, Reclaimable: summary.Reclaimable},
+	}
+	for i, r := range records {
+		if i == 0 || r.Group != records[i-1].Group {
+			report.Groups = append(report.Groups, jsonGroup{Group: r.Group})
+		}
+		g := &report.Groups[len(report.Groups)-1]
+		g.Files = append(g.Files, jsonFile{
+			Path:              r.Path,
+			Size:              r.Size,
+			ModTime:           r.ModTime,
+			Hash:              r.Hash,
+			Leader:            r.Leader,
+			IdenticalToLeader: r.IdenticalToLeader,
+			HardlinkToLeader:  r.HardlinkToLeader,
+		})
+	}
+	for i, g := range report.Groups {
+		paths := make([]string, len(g.Files))
+		for j, f := range g.Files {
+			paths[j] = f.Path
+		}
+		report.Groups[i].Fingerprint = groupFingerprint(dir, paths)
+	}
+	if !isRemoteLocation(dir) {
+		report.Dir = absPath(dir)
+	}
+	return report
+}
+
+// writeNulReport writes the reported files in the format of writeGroupsNul.
+func writeNulReport(w io.Writer, records []FileRecord) error {
+	var groups [][]string
+	for i, r := range records {
+		if i == 0 || r.Group != records[i-1].Group {
+			groups = append(groups, nil)
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], r.Path)
+	}
+	return writeGroupsNul(w, groups)
+}
+
+// writeTextReport writes a human-readable report: one block per group, headed
+// by its fingerprint when set, with a line per file showing its status, size, modification time, and short hash,
+// followed by the totals of summarizeRecords.
+func writeTextReport(w io.Writer, records []FileRecord) error {
+	return writeTextReportPage(w, records, groupPage{})
+}
+
+// writeTextReportPage is writeTextReport for the groups of page, whose totals
+// cover only the page. An empty page is left to the note of the page.
+func writeTextReportPage(w io.Writer, records []FileRecord, page groupPage) error {
+	if len(records) == 0 {
+		if page != (groupPage{}) {
+			return nil
+		}
+		_, err := fmt.Fprintln(w, "No groups of similar files found.")
+		return err
+	}
+
+	group := 0
+	for _, r := range records {
+		if r.Group != group {
+			if group != 0 {
+				fmt.Fprintln(w)
+			}
+			group = r.Group
+			if r.Fingerprint != "" {
+				fmt.Fprintf(w, "Group %d (fingerprint %s):\n", group, r.Fingerprint)
+			} else {
+				fmt.Fprintf(w, "Group %d:\n", group)
+			}
+		}
+		status := "different"
+		if r.Leader {
+			status = "leader"
+		} else if r.HardlinkToLeader {
+			status = "hardlink"
+		} else if r.IdenticalToLeader {
+			status = "identical"
+		}
+		if _, err := fmt.Fprintf(w, "  %-9s %10s  %s  %s  %s\n", status, formatBytes(r.Size),
+			r.ModTime.Format("2006-01-02 15:04"), r.Hash[:12], r.Path); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "\n%s: %s\n", page.summaryLabel(), summarizeRecords(records))
+	return err
+}
+
+// withStderrSummary wraps a machine-readable report writer so the summary line
+// of the groups of page is written to stderr once the report is done, keeping
+// the output parseable.
+func withStderrSummary(page groupPage, write func(io.Writer, []FileRecord) error) func(io.Writer, []FileRecord) error {
+	return func(w io.Writer, records []FileRecord) error {
+		if err := write(w, records); err != nil {
+			return err
+		}
+		if len(records) > 0 {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", page.summaryLabel(), summarizeRecords(records))
+		}
+		return nil
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestHashFile tests SHA-256 hashing and byte progress reporting.
+func TestHashFile(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	path := createFileWithContent(t, tmpDir, "a.txt", "hello\n")
+	progress := NewProgress("Hashing")
+
+	hash, err := hashFile(context.Background(), path, progress)
+	if err != nil {
+		t.Fatalf("hashFile() returned error: %v", err)
+	}
+	expected := "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03"
+	if hash != expected {
+		t.Errorf("hashFile() = %q, expected %q", hash, expected)
+	}
+	if got := progress.bytes.Load(); got != 6 {
+		t.Errorf("progress recorded %d bytes, expected 6", got)
+	}
+}
+
+// TestBuildFileRecords tests group numbering, leaders, and identical flags.
+func TestBuildFileRecords(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	doc := createFileWithContent(t, tmpDir, "doc.txt", "same\n")
+	docCopy := createFileWithContent(t, tmpDir, "doc-1.txt", "same\n")
+	docEdit := createFileWithContent(t, tmpDir, "doc-2.txt", "edited\n")
+	img := createFileWithContent(t, tmpDir, "img.png", "a")
+	imgCopy := createFileWithContent(t, tmpDir, "img-1.png", "b")
+
+	records, err := buildFileRecords(context.Background(), [][]string{{doc, docCopy, docEdit}, {img, imgCopy}}, nil, nil)
+	if err != nil {
+		t.Fatalf("buildFileRecords() returned error: %v", err)
+	}
+	if len(records) != 5 {
+		t.Fatalf("buildFileRecords() returned %d records, expected 5", len(records))
+	}
+
+	expected := []struct {
+		group     int
+		path      string
+		leader    bool
+		identical bool
+	}{
+		{1, doc, true, false},
+		{1, docCopy, false, true},
+		{1, docEdit, false, false},
+		{2, img, true, false},
+		{2, imgCopy, false, false},
+	}
+	for i, want := range expected {
+		r := records[i]
+		if r.Group != want.group || r.Path != want.path || r.Leader != want.leader || r.IdenticalToLeader != want.identical {
+			t.Errorf("records[%d] = {group %d, %s, leader %v, identical %v}, expected {group %d, %s, leader %v, identical %v}",
+				i, r.Group, r.Path, r.Leader, r.IdenticalToLeader, want.group, want.path, want.leader, want.identical)
+		}
+	}
+	if records[0].Size != 5 {
+		t.Errorf("records[0].Size = %d, expected 5", records[0].Size)
+	}
+}
+
+// TestWriteCSV tests the CSV header and row layout.
+func TestWriteCSV(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	file1 := createFileWithContent(t, tmpDir, "notes, final.txt", "x\n")
+	file2 := createFileWithContent(t, tmpDir, "notes, final-1.txt", "x\n")
+
+	records, err := buildFileRecords(context.Background(), [][]string{{file1, file2}}, nil, nil)
+	if err != nil {
+		t.Fatalf("buildFileRecords() returned error: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := writeCSV(&out, records); err != nil {
+		t.Fatalf("writeCSV() returned error: %v", err)
+	}
+
+	rows, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatalf("output is not valid CSV: %v", err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("writeCSV() wrote %d rows, expected 3", len(rows))
+	}
+	if rows[0][0] != "group" || rows[0][5] != "identical_to_leader" {
+		t.Errorf("unexpected header: %v", rows[0])
+	}
+	if rows[1][1] != file1 || rows[1][5] != "leader" {
+		t.Errorf("unexpected leader row: %v", rows[1])
+	}
+	if rows[2][1] != file2 || rows[2][5] != "true" {
+		t.Errorf("unexpected second row: %v", rows[2])
+	}
+}
+
+// TestHashFile_Cancelled tests that hashing stops with the context's error.
+func TestHashFile_Cancelled(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	path := createFileWithContent(t, tmpDir, "file.txt", "content")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := hashFile(ctx, path, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("hashFile() error = %v, expected context.Canceled", err)
+	}
+}
+
+// TestBuildFileRecords_Hardlink tests that hard links to the leader are flagged.
+func TestBuildFileRecords_Hardlink(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	leader := createFileWithContent(t, tmpDir, "notes.txt", "same")
+	copyPath := createFileWithContent(t, tmpDir, "notes-1.txt", "same")
+	link := filepath.Join(tmpDir, "notes-2.txt")
+	if err := os.Link(leader, link); err != nil {
+		t.Skipf("hard links not supported: %v", err)
+	}
+
+	records, err := buildFileRecords(context.Background(), [][]string{{leader, copyPath, link}}, nil, nil)
+	if err != nil {
+		t.Fatalf("buildFileRecords() returned error: %v", err)
+	}
+	if records[1].HardlinkToLeader || !records[2].HardlinkToLeader {
+		t.Errorf("HardlinkToLeader = %v, %v; expected only the link flagged", records[1].HardlinkToLeader, records[2].HardlinkToLeader)
+	}
+	if !records[2].IdenticalToLeader || records[2].Hash != records[0].Hash {
+		t.Error("a hard link should be identical to the leader with the same hash")
+	}
+
+	plan := buildCleanPlan(tmpDir, records, cleanOptions{})
+	if plan.Entries[1].Action != ActionDelete || plan.Entries[2].Action != ActionKeep {
+		t.Errorf("plan actions = %s, %s; expected the copy removed and the link kept", plan.Entries[1].Action, plan.Entries[2].Action)
+	}
+
+	var out bytes.Buffer
+	if err := writeTextReport(&out, records); err != nil {
+		t.Fatalf("writeTextReport() returned error: %v", err)
+	}
+	if !strings.Contains(out.String(), "hardlink") {
+		t.Errorf("writeTextReport() should label the hard link:\n%s", out.String())
+	}
+}
+
+// TestWriteJSONReport tests the JSON report and its group fingerprints.
+func TestWriteJSONReport(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	file1 := createFileWithContent(t, tmpDir, "notes.txt", "x\n")
+	file2 := createFileWithContent(t, tmpDir, "notes-1.txt", "x\n")
+	records, err := buildFileRecords(context.Background(), [][]string{{file1, file2}}, nil, nil)
+	if err != nil {
+		t.Fatalf("buildFileRecords() returned error: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := writeJSONReport(&out, tmpDir, records); err != nil {
+		t.Fatalf("writeJSONReport() returned error: %v", err)
+	}
+	var report jsonReport
+	if err := json.Unmarshal(out.Bytes(), &report); err != nil {
+		t.Fatalf("output is not valid JSON: %v", err)
+	}
+	if report.Dir != tmpDir || len(report.Groups) != 1 || len(report.Groups[0].Files) != 2 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	g := report.Groups[0]
+	if g.Fingerprint != groupFingerprint(tmpDir, []string{file2, file1}) {
+		t.Errorf("fingerprint = %s, expected that of the group", g.Fingerprint)
+	}
+	if !g.Files[0].Leader || !g.Files[1].IdenticalToLeader || g.Files[1].Hash != records[1].Hash {
+		t.Errorf("unexpected files: %+v", g.Files)
+	}
+	if report.Total.Files != 2 || report.Total.Reclaimable != 2 {
+		t.Errorf("unexpected total: %+v", report.Total)
+	}
+
+	out.Reset()
+	if err := writeJSONReport(&out, tmpDir, nil); err != nil || !bytes.Contains(out.Bytes(), []byte(`"groups": []`)) {
+		t.Errorf("a report without groups should list none, got %s, %v", out.String(), err)
+	}
+}
+
+// TestSetFingerprints tests that the text and CSV reports identify groups by
+// the fingerprint the ignore list uses.
+func TestSetFingerprints(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	notes := []string{
+		createFileWithContent(t, tmpDir, "notes.txt", "x\n"),
+		createFileWithContent(t, tmpDir, "notes-1.txt", "x\n"),
+	}
+	todo := []string{
+		createFileWithContent(t, tmpDir, "todo.txt", "y\n"),
+		createFileWithContent(t, tmpDir, "todo-1.txt", "z\n"),
+	}
+	records, err := buildFileRecords(context.Background(), [][]string{notes, todo}, nil, nil)
+	if err != nil {
+		t.Fatalf("buildFileRecords() returned error: %v", err)
+	}
+	setFingerprints(tmpDir, records)
+	want := []string{groupFingerprint(tmpDir, notes), groupFingerprint(tmpDir, notes), groupFingerprint(tmpDir, todo), groupFingerprint(tmpDir, todo)}
+	for i, r := range records {
+		if r.Fingerprint != want[i] {
+			t.Errorf("record %d fingerprint = %q, expected %q", i, r.Fingerprint, want[i])
+		}
+	}
+
+	var out bytes.Buffer
+	if err := writeTextReport(&out, records); err != nil {
+		t.Fatalf("writeTextReport() returned error: %v", err)
+	}
+	if !strings.Contains(out.String(), "Group 2 (fingerprint "+want[2]+"):\n") {
+		t.Errorf("writeTextReport() should show the fingerprint of each group:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := writeCSV(&out, records); err != nil {
+		t.Fatalf("writeCSV() returned error: %v", err)
+	}
+	rows, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatalf("output is not valid CSV: %v", err)
+	}
+	if last := len(rows[0]) - 1; rows[0][last] != "fingerprint" || rows[1][last] != want[0] || rows[3][last] != want[2] {
+		t.Errorf("unexpected fingerprint column: %v", rows)
+	}
+}
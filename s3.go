@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// emptyPayloadHash is the SHA-256 of an empty request body, which every
+// request to S3 has here.
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// s3Storage lists and downloads the objects under a prefix of an S3 bucket,
+// or of any service with the S3 API. It is configured like the AWS CLI:
+// credentials come from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, and
+// AWS_SESSION_TOKEN, the region from AWS_REGION, and AWS_ENDPOINT_URL points
+// at another service such as MinIO, which is then addressed by path. Without
+// credentials, requests are unsigned, for public buckets.
+type s3Storage struct {
+	objectIndex
+	client   *http.Client
+	endpoint *url.URL
+	// pathStyle puts the bucket in the path rather than the host name.
+	pathStyle bool
+	bucket    string
+	// prefix is the key prefix of the location, ending in a slash unless empty.
+	prefix       string
+	region       string
+	accessKey    string
+	secretKey    string
+	sessionToken string
+}
+
+// newS3Storage returns the storage of an s3://bucket/prefix URL.
+func newS3Storage(u *url.URL) (*s3Storage, error) {
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid location %s: missing bucket", u)
+	}
+	s := &s3Storage{
+		client:       http.DefaultClient,
+		bucket:       u.Host,
+		prefix:       strings.TrimPrefix(u.Path, "/"),
+		region:       firstEnv("AWS_REGION", "AWS_DEFAULT_REGION"),
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if s.prefix != "" && !strings.HasSuffix(s.prefix, "/") {
+		s.prefix += "/"
+	}
+	if s.region == "" {
+		s.region = "us-east-1"
+	}
+	endpoint := fmt.Sprintf("https://%s.s3.%s.amazonaws.com", s.bucket, s.region)
+	if custom := firstEnv("AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL"); custom != "" {
+		endpoint, s.pathStyle = strings.TrimSuffix(custom, "/"), true
+	}
+	var err error
+	if s.endpoint, err = url.Parse(endpoint); err != nil {
+		return nil, fmt.Errorf("invalid S3 endpoint %s: %w", endpoint, err)
+	}
+	return s, nil
+}
+
+// firstEnv returns the value of the first of the environment variables that is set.
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// name returns the URL of the object with key.
+func (s *s3Storage) name(key string) string {
+	return "s3://" + s.bucket + "/" + key
+}
+
+// key returns the key of the object named by the URL name.
+func (s *s3Storage) key(name string) (string, bool) {
+	return strings.CutPrefix(name, "s3://"+s.bucket+"/")
+}
+
+// s3ListResult is the response to a ListObjectsV2 request.
+type s3ListResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// List returns the objects under the prefix, reading as many pages as the
+// bucket returns. Keys ending in a slash, which consoles create as folders,
+// are left out. Unless recursive, only the objects directly under the prefix
+// are listed.
+func (s *s3Storage) List(ctx context.Context, recursive bool) ([]StoredObject, error) {
+	query := url.Values{"list-type": {"2"}, "prefix": {s.prefix}}
+	if !recursive {
+		query.Set("delimiter", "/")
+	}
+
+	var objects []StoredObject
+	for {
+		resp, err := s.do(ctx, "", query)
+		if err != nil {
+			return nil, err
+		}
+		var result s3ListResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("invalid S3 listing of %s: %w", s.name(s.prefix), err)
+		}
+		for _, c := range result.Contents {
+			if !strings.HasSuffix(c.Key, "/") {
+				objects = append(objects, StoredObject{Name: s.name(c.Key), Size: c.Size, ModTime: c.LastModified})
+			}
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+	s.remember(objects)
+	return objects, nil
+}
+
+// Open streams the object named by the URL name.
+func (s *s3Storage) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	key, ok := s.key(name)
+	if !ok {
+		return nil, fmt.Errorf("%s is not in bucket %s", name, s.bucket)
+	}
+	resp, err := s.do(ctx, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// s3Error is the body of an error response.
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// do sends a GET request for the object key, or for the bucket if key is
+// empty, and returns the response if it succeeded.
+func (s *s3Storage) do(ctx context.Context, key string, query url.Values) (*http.Response, error) {
+	host, uri := s.endpoint.Host, strings.TrimSuffix(s.endpoint.EscapedPath(), "/")+"/"
+	if s.pathStyle {
+		uri += s3Escape(s.bucket, false) + "/"
+	}
+	uri += s3Escape(key, true)
+	rawQuery := canonicalQuery(query)
+	target := s.endpoint.Scheme + "://" + host + uri
+	if rawQuery != "" {
+		target += "?" + rawQuery
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	s.sign(req, host, uri, rawQuery, time.Now())
+	logger.Debug("S3 request", "url", target)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusOK {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	var e s3Error
+	if xml.NewDecoder(resp.Body).Decode(&e) == nil && e.Code != "" {
+		return nil, fmt.Errorf("S3 request for %s failed: %s: %s", s.name(key), e.Code, e.Message)
+	}
+	return nil, fmt.Errorf("S3 request for %s failed: %s", s.name(key), resp.Status)
+}
+
+// sign adds the headers of AWS Signature Version 4 to req, for the given
+// canonical URI and query. Without credentials, req is left unsigned.
+func (s *s3Storage) sign(req *http.Request, host, uri, rawQuery string, now time.Time) {
+	if s.accessKey == "" || s.secretKey == "" {
+		return
+	}
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	headers := map[string]string{
+		"host":                 host,
+		"x-amz-content-sha256": emptyPayloadHash,
+		"x-amz-date":           amzDate,
+	}
+	if s.sessionToken != "" {
+		headers["x-amz-security-token"] = s.sessionToken
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+		if name != "host" {
+			req.Header.Set(name, headers[name])
+		}
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{req.Method, uri, rawQuery, canonicalHeaders.String(), signedHeaders, emptyPayloadHash}, "\n")
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := []byte("AWS4" + s.secretKey)
+	for _, part := range []string{date, s.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signedHeaders, signature))
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data with key.
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery encodes query sorted by name, as Signature Version 4 expects.
+func canonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var pairs []string
+	for _, name := range names {
+		for _, value := range query[name] {
+			pairs = append(pairs, s3Escape(name, false)+"="+s3Escape(value, false))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// s3Escape percent-encodes every byte of s except the unreserved characters
+// of RFC 3986, and slashes if keepSlash is set.
+func s3Escape(s string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && keepSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -1,34 +1,467 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 )
 
+// defaultScanWorkers bounds how many directories are read at once in recursive
+// scans. Directory reads are I/O bound, so this is independent of the CPU count
+// and mainly helps on network filesystems, where each read has high latency.
+const defaultScanWorkers = 8
+
+// junkFileNames lists, in lowercase, metadata files that operating systems drop
+// into folders. They are skipped with hidden files.
+var junkFileNames = map[string]bool{
+	".ds_store":   true,
+	"thumbs.db":   true,
+	"ehthumbs.db": true,
+	"desktop.ini": true,
+}
+
+// isHidden reports whether name is a dotfile or a known junk file.
+func isHidden(name string) bool {
+	return strings.HasPrefix(name, ".") || junkFileNames[strings.ToLower(name)]
+}
+
 // Scanner scans a directory and collects all files.
 type Scanner struct {
-	dir string
+	dir       string
+	progress  *Progress
+	recursive bool
+	workers   int
+	// minSize and maxSize skip files outside the range; zero means no limit.
+	minSize int64
+	maxSize int64
+	// newerThan and olderThan skip files modified outside the window; zero means no limit.
+	newerThan time.Time
+	olderThan time.Time
+	// extensions, if set, holds the lowercase extensions (without the dot) of the
+	// only files kept.
+	extensions map[string]bool
+	// excludes are glob patterns of the names of files and directories left out.
+	excludes []string
+	// includeHidden keeps dotfiles, dot-directories, and junk files, which are skipped by default.
+	includeHidden bool
+	// skipSymlinks leaves out symbolic links. Otherwise links to files are scanned
+	// like files and, in recursive scans, links to directories are followed.
+	skipSymlinks bool
+	// storage, if set, is listed instead of the local directory dir, which is
+	// then its URL.
+	storage Storage
+	// scanArchives lists the files inside zip archives, by virtual paths such
+	// as backup.zip!/notes.md, in addition to the archives themselves.
+	scanArchives bool
+	// statTimeout gives up on reading the size and type of a file, or the
+	// entries of a directory, after this long; zero waits as long as it takes.
+	statTimeout time.Duration
+
+	mu sync.Mutex
+	// unreadable lists the paths left out because they couldn't be read.
+	unreadable []UnreadablePath
 }
 
+// UnreadablePath is a file or directory a scan left out because it couldn't
+// be read, and why.
+type UnreadablePath struct {
+	Path   string
+	Reason string
+}
+
+// errStatTimeout is returned for files whose stat takes longer than the
+// scanner's stat timeout.
+var errStatTimeout = errors.New("stat timed out")
+
+// errReadDirTimeout is returned for directories whose entries take longer than
+// the scanner's stat timeout to read.
+var errReadDirTimeout = errors.New("reading the directory timed out")
+
+// osReadDir reads the entries of a directory; tests replace it to stand in
+// for a hanging mount.
+var osReadDir = os.ReadDir
+
 // NewScanner creates a new Scanner for the given directory.
 func NewScanner(dir string) *Scanner {
-	return &Scanner{dir: dir}
+	return &Scanner{dir: dir, workers: defaultScanWorkers}
+}
+
+// SetProgress makes the scanner report each file it finds to p.
+func (s *Scanner) SetProgress(p *Progress) {
+	s.progress = p
+}
+
+// SetRecursive makes the scanner descend into subdirectories, reading up to
+// workers directories concurrently. The .doppel state directory is skipped.
+func (s *Scanner) SetRecursive(recursive bool, workers int) {
+	s.recursive = recursive
+	if workers > 0 {
+		s.workers = workers
+	}
+}
+
+// SetSizeLimits makes the scanner skip files smaller than minSize or larger than
+// maxSize bytes. Zero disables a limit.
+func (s *Scanner) SetSizeLimits(minSize, maxSize int64) {
+	s.minSize = minSize
+	s.maxSize = maxSize
+}
+
+// SetTimeWindow makes the scanner skip files modified before newerThan or at or
+// after olderThan. A zero time disables that bound.
+func (s *Scanner) SetTimeWindow(newerThan, olderThan time.Time) {
+	s.newerThan = newerThan
+	s.olderThan = olderThan
+}
+
+// SetIncludeHidden sets whether dotfiles, directories starting with a dot, and
+// junk files such as .DS_Store and Thumbs.db are scanned.
+func (s *Scanner) SetIncludeHidden(include bool) {
+	s.includeHidden = include
+}
+
+// SetSkipSymlinks sets whether symbolic links are left out of the scan instead of
+// being followed.
+func (s *Scanner) SetSkipSymlinks(skip bool) {
+	s.skipSymlinks = skip
+}
+
+// SetStatTimeout makes the scanner skip files whose size or link target isn't
+// read within timeout, and directories whose entries aren't, as happens on
+// slow phone (MTP) and network mounts. Zero disables the timeout.
+func (s *Scanner) SetStatTimeout(timeout time.Duration) {
+	s.statTimeout = timeout
+}
+
+// Unreadable returns the paths the last scan skipped because they couldn't be
+// read, such as directories without permission, sorted by path.
+func (s *Scanner) Unreadable() []UnreadablePath {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unreadable := append([]UnreadablePath(nil), s.unreadable...)
+	sort.Slice(unreadable, func(i, j int) bool { return unreadable[i].Path < unreadable[j].Path })
+	return unreadable
+}
+
+// skipUnreadable records path as unreadable if err is one that leaves out
+// only that path, such as a permission error, and reports whether it did.
+func (s *Scanner) skipUnreadable(path string, err error) bool {
+	reason, ok := s.unreadableReason(err)
+	if !ok {
+		return false
+	}
+	logger.Debug("skipped unreadable path", "path", path, "reason", reason)
+	s.mu.Lock()
+	s.unreadable = append(s.unreadable, UnreadablePath{Path: path, Reason: reason})
+	s.mu.Unlock()
+	return true
+}
+
+// unreadableReason describes err if it means a single file or directory can't
+// be read, rather than that the scan can't go on.
+func (s *Scanner) unreadableReason(err error) (string, bool) {
+	switch {
+	case errors.Is(err, fs.ErrPermission):
+		return "permission denied", true
+	case errors.Is(err, errStatTimeout):
+		return fmt.Sprintf("stat timed out after %s", s.statTimeout), true
+	case errors.Is(err, errReadDirTimeout):
+		return fmt.Sprintf("reading the directory timed out after %s", s.statTimeout), true
+	case errors.Is(err, syscall.EIO):
+		return "input/output error", true
+	}
+	return "", false
+}
+
+// stat calls info, giving up with errStatTimeout after the scanner's stat
+// timeout.
+func (s *Scanner) stat(info func() (os.FileInfo, error)) (os.FileInfo, error) {
+	return withTimeout(s.statTimeout, errStatTimeout, info)
+}
+
+// readDirEntries reads the entries of dir, giving up with errReadDirTimeout
+// after the scanner's stat timeout.
+func (s *Scanner) readDirEntries(dir string) ([]os.DirEntry, error) {
+	return withTimeout(s.statTimeout, errReadDirTimeout, func() ([]os.DirEntry, error) { return osReadDir(dir) })
 }
 
-// Scan collects all files in the directory (non-recursive).
+// withTimeout calls f, giving up with errTimeout after timeout; zero waits as
+// long as it takes. A call that hangs is left running in the background, since
+// file system calls can't be interrupted.
+func withTimeout[T any](timeout time.Duration, errTimeout error, f func() (T, error)) (T, error) {
+	if timeout <= 0 {
+		return f()
+	}
+	type result struct {
+		v   T
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		v, err := f()
+		done <- result{v, err}
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return r.v, r.err
+	case <-timer.C:
+		var zero T
+		return zero, errTimeout
+	}
+}
+
+// SetScanArchives sets whether the files inside zip archives are scanned as
+// if the archives were directories; see splitArchivePath.
+func (s *Scanner) SetScanArchives(scan bool) {
+	s.scanArchives = scan
+}
+
+// SetStorage makes the scanner list the files of storage, the location at the
+// scanner's directory, instead of reading a local directory.
+func (s *Scanner) SetStorage(storage Storage) {
+	s.storage = storage
+}
+
+// SetExtensions makes the scanner keep only files with one of the given
+// extensions, compared case-insensitively and with or without a leading dot.
+// An empty list keeps every file.
+func (s *Scanner) SetExtensions(exts []string) {
+	s.extensions = nil
+	for _, ext := range exts {
+		if s.extensions == nil {
+			s.extensions = make(map[string]bool)
+		}
+		s.extensions[strings.ToLower(strings.TrimPrefix(ext, "."))] = true
+	}
+}
+
+// SetExcludes makes the scanner leave out files and directories whose name
+// matches one of the glob patterns, such as "*.tmp" or "node_modules".
+func (s *Scanner) SetExcludes(patterns []string) {
+	s.excludes = patterns
+}
+
+// excluded reports whether name matches one of the scanner's exclude patterns.
+// The --exclude flag rejects malformed patterns, so Match fails for none of them.
+func (s *Scanner) excluded(name string) bool {
+	for _, pattern := range s.excludes {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Scan collects all files in the directory, and in its subdirectories if the
+// scanner is recursive.
 // Returns a slice of file paths relative to the scanned directory.
 func (s *Scanner) Scan() ([]string, error) {
-	entries, err := os.ReadDir(s.dir)
+	return s.ScanContext(context.Background())
+}
+
+// ScanContext is like Scan but stops with ctx's error if ctx is cancelled.
+func (s *Scanner) ScanContext(ctx context.Context) ([]string, error) {
+	s.mu.Lock()
+	s.unreadable = nil
+	s.mu.Unlock()
+	if s.storage != nil {
+		return s.list(ctx)
+	}
+	if !s.recursive {
+		return s.readDir(s.dir, nil)
+	}
+	return s.walk(ctx)
+}
+
+// readDir lists the files in dir, passing each subdirectory to subdir if it is set.
+// Symbolic links are resolved unless the scanner skips them; broken links are ignored.
+func (s *Scanner) readDir(dir string, subdir func(string)) ([]string, error) {
+	entries, err := s.readDirEntries(dir)
 	if err != nil {
 		return nil, err
 	}
 
 	var files []string
 	for _, entry := range entries {
-		if !entry.IsDir() {
-			files = append(files, filepath.Join(s.dir, entry.Name()))
+		name := entry.Name()
+		path := filepath.Join(dir, name)
+		if !s.includeHidden && isHidden(name) {
+			logger.Debug("skipped hidden file", "path", path)
+			continue
 		}
+		if s.excluded(name) {
+			logger.Debug("skipped file", "path", path, "reason", "matches --exclude")
+			continue
+		}
+		info := func() (os.FileInfo, error) { return s.stat(entry.Info) }
+		isDir := entry.IsDir()
+		if entry.Type()&os.ModeSymlink != 0 {
+			if s.skipSymlinks {
+				logger.Debug("skipped symbolic link", "path", path)
+				continue
+			}
+			target, err := s.stat(func() (os.FileInfo, error) { return os.Stat(path) })
+			if errors.Is(err, errStatTimeout) {
+				s.skipUnreadable(path, err)
+				continue
+			}
+			if err != nil {
+				logger.Debug("skipped broken symbolic link", "path", path, "err", err)
+				continue
+			}
+			info = func() (os.FileInfo, error) { return target, nil }
+			isDir = target.IsDir()
+		}
+
+		if isDir {
+			if subdir != nil && name != stateDirName {
+				subdir(path)
+			}
+			continue
+		}
+		if s.scanArchives && isArchive(name) {
+			files = append(files, s.scanArchive(path)...)
+		}
+		skip, err := s.skipReason(name, info)
+		if err != nil && s.skipUnreadable(path, err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if skip != "" {
+			logger.Debug("skipped file", "path", path, "reason", skip)
+			continue
+		}
+		files = append(files, path)
+		s.progress.AddFiles(1)
 	}
 
 	return files, nil
 }
+
+// skipReason says which of the scanner's limits the extension of name or the size
+// and modification time from info are outside of, or returns "" if the file is
+// kept. info is only called when a size or time limit is set. Files removed since
+// the directory was read are skipped.
+func (s *Scanner) skipReason(name string, info func() (os.FileInfo, error)) (string, error) {
+	if s.extensions != nil {
+		ext := strings.TrimPrefix(filepath.Ext(name), ".")
+		if !s.extensions[strings.ToLower(ext)] {
+			return "extension not in --ext", nil
+		}
+	}
+	if s.minSize == 0 && s.maxSize == 0 && s.newerThan.IsZero() && s.olderThan.IsZero() {
+		return "", nil
+	}
+	fi, err := info()
+	if errors.Is(err, os.ErrNotExist) {
+		return "removed while scanning", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if size := fi.Size(); size < s.minSize {
+		return fmt.Sprintf("%s is below --min-size", formatBytes(size)), nil
+	} else if s.maxSize > 0 && size > s.maxSize {
+		return fmt.Sprintf("%s is above --max-size", formatBytes(size)), nil
+	}
+	modTime := fi.ModTime()
+	if !s.newerThan.IsZero() && modTime.Before(s.newerThan) {
+		return "modified before --newer-than", nil
+	}
+	if !s.olderThan.IsZero() && !modTime.Before(s.olderThan) {
+		return "modified after --older-than", nil
+	}
+	return "", nil
+}
+
+// walk scans the directory tree, reading subdirectories in parallel as they are
+// found. Subdirectories that can't be read are skipped and recorded as
+// unreadable; any other error, or cancellation of ctx, stops the walk. Files are
+// returned sorted so the result doesn't depend on scheduling.
+// When symlinks are followed, each directory is read once by its resolved path, so
+// links back up the tree don't loop forever.
+func (s *Scanner) walk(ctx context.Context) ([]string, error) {
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		files    []string
+		firstErr error
+		visited  = make(map[string]bool)
+	)
+	sem := make(chan struct{}, s.workers)
+
+	var visit func(dir string)
+	visit = func(dir string) {
+		defer wg.Done()
+
+		key := dir
+		if !s.skipSymlinks {
+			if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+				key = resolved
+			}
+		}
+
+		mu.Lock()
+		stopped := firstErr != nil || visited[key]
+		visited[key] = true
+		mu.Unlock()
+		if stopped {
+			return
+		}
+
+		// Hold a worker slot only while reading, so waiting on subdirectories
+		// never blocks other reads
+		var found []string
+		err := ctx.Err()
+		if err == nil {
+			select {
+			case sem <- struct{}{}:
+				found, err = s.readDir(dir, func(sub string) {
+					wg.Add(1)
+					go visit(sub)
+				})
+				<-sem
+			case <-ctx.Done():
+				err = ctx.Err()
+			}
+		}
+
+		if err != nil && dir != s.dir && ctx.Err() == nil && s.skipUnreadable(dir, err) {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			if firstErr == nil {
+				if dir != s.dir && ctx.Err() == nil {
+					err = fmt.Errorf("failed to read %s: %w", dir, err)
+				}
+				firstErr = err
+			}
+			return
+		}
+		files = append(files, found...)
+	}
+
+	wg.Add(1)
+	visit(s.dir)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	sort.Strings(files)
+	return files, nil
+}
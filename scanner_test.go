@@ -1,9 +1,15 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 )
 
 // TestScanner_Scan_EmptyDirectory tests scanning an empty directory.
@@ -128,3 +134,345 @@ func createFile(t *testing.T, dir, fileName string) {
 		t.Fatalf("Failed to create file %q: %v", filePath, err)
 	}
 }
+
+// TestScanner_Scan_Recursive tests that subdirectories are scanned and the state directory is skipped.
+func TestScanner_Scan_Recursive(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	var expected []string
+	for _, dir := range []string{"a", "a/b", "a/b/c", "d", stateDirName} {
+		if err := os.MkdirAll(filepath.Join(tmpDir, dir), 0755); err != nil {
+			t.Fatalf("Failed to create subdirectory: %v", err)
+		}
+		createFile(t, filepath.Join(tmpDir, dir), "file.txt")
+		if dir != stateDirName {
+			expected = append(expected, filepath.Join(tmpDir, dir, "file.txt"))
+		}
+	}
+	createFile(t, tmpDir, "top.txt")
+	expected = append(expected, filepath.Join(tmpDir, "top.txt"))
+	sort.Strings(expected)
+
+	for _, workers := range []int{1, 4} {
+		scanner := NewScanner(tmpDir)
+		scanner.SetRecursive(true, workers)
+		files, err := scanner.Scan()
+		if err != nil {
+			t.Fatalf("Scan() returned error: %v", err)
+		}
+		if strings.Join(files, "\n") != strings.Join(expected, "\n") {
+			t.Errorf("workers=%d: Scan() = %v, expected %v", workers, files, expected)
+		}
+	}
+}
+
+// TestScanner_Scan_RecursiveUnreadable tests that an unreadable subdirectory is
+// skipped and reported instead of failing the scan.
+func TestScanner_Scan_RecursiveUnreadable(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("permissions are not enforced for root")
+	}
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	file := createFileWithContent(t, tmpDir, "file.txt", "data")
+
+	locked := filepath.Join(tmpDir, "locked")
+	if err := os.Mkdir(locked, 0); err != nil {
+		t.Fatalf("Failed to create subdirectory: %v", err)
+	}
+	defer os.Chmod(locked, 0755)
+
+	scanner := NewScanner(tmpDir)
+	scanner.SetRecursive(true, 2)
+	files, err := scanner.Scan()
+	if err != nil {
+		t.Fatalf("Scan() returned error: %v", err)
+	}
+	if len(files) != 1 || files[0] != file {
+		t.Errorf("Scan() = %v, expected only %s", files, file)
+	}
+	want := []UnreadablePath{{Path: locked, Reason: "permission denied"}}
+	if got := scanner.Unreadable(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Unreadable() = %v, expected %v", got, want)
+	}
+}
+
+// TestScanner_Stat_Timeout tests that a stat taking longer than the stat
+// timeout is given up on, and the file reported as unreadable.
+func TestScanner_Stat_Timeout(t *testing.T) {
+	scanner := NewScanner(".")
+	scanner.SetStatTimeout(10 * time.Millisecond)
+	release := make(chan struct{})
+	defer close(release)
+	_, err := scanner.stat(func() (os.FileInfo, error) {
+		<-release
+		return nil, nil
+	})
+	if !errors.Is(err, errStatTimeout) {
+		t.Fatalf("stat() error = %v, expected a timeout", err)
+	}
+	if !scanner.skipUnreadable("slow.jpg", err) {
+		t.Fatal("a timed out stat should leave out only the file")
+	}
+	want := []UnreadablePath{{Path: "slow.jpg", Reason: "stat timed out after 10ms"}}
+	if got := scanner.Unreadable(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Unreadable() = %v, expected %v", got, want)
+	}
+
+	if _, err := scanner.stat(func() (os.FileInfo, error) { return nil, os.ErrNotExist }); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("stat() error = %v, expected the error of a quick stat", err)
+	}
+	if scanner.skipUnreadable("other", errors.New("disk on fire")) {
+		t.Errorf("other errors should still fail the scan")
+	}
+}
+
+// TestScanner_ReadDir_Timeout tests that a directory whose entries take longer
+// than the stat timeout to read is left out of a recursive scan and reported
+// as unreadable, while the rest of the tree is scanned.
+func TestScanner_ReadDir_Timeout(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	slow := filepath.Join(tmpDir, "phone")
+	if err := os.Mkdir(slow, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	createFile(t, slow, "img.jpg")
+	kept := createFileWithContent(t, tmpDir, "notes.txt", "notes")
+
+	release := make(chan struct{})
+	defer close(release)
+	osReadDir = func(dir string) ([]os.DirEntry, error) {
+		if dir == slow {
+			<-release
+		}
+		return os.ReadDir(dir)
+	}
+	defer func() { osReadDir = os.ReadDir }()
+
+	scanner := NewScanner(tmpDir)
+	scanner.SetRecursive(true, 2)
+	scanner.SetStatTimeout(10 * time.Millisecond)
+	files, err := scanner.Scan()
+	if err != nil {
+		t.Fatalf("Scan() returned error: %v", err)
+	}
+	if !reflect.DeepEqual(files, []string{kept}) {
+		t.Errorf("Scan() = %v, expected only %s", files, kept)
+	}
+	want := []UnreadablePath{{Path: slow, Reason: "reading the directory timed out after 10ms"}}
+	if got := scanner.Unreadable(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Unreadable() = %v, expected %v", got, want)
+	}
+}
+
+// TestScanner_ScanContext_Cancelled tests that a cancelled context stops a recursive scan.
+func TestScanner_ScanContext_Cancelled(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	createFile(t, tmpDir, "file.txt")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	scanner := NewScanner(tmpDir)
+	scanner.SetRecursive(true, 2)
+	if _, err := scanner.ScanContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("ScanContext() error = %v, expected context.Canceled", err)
+	}
+}
+
+// TestScanner_Scan_SizeLimits tests that files outside the size range are skipped.
+func TestScanner_Scan_SizeLimits(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	createFileWithContent(t, tmpDir, "empty.txt", "")
+	small := createFileWithContent(t, tmpDir, "small.txt", "12345")
+	createFileWithContent(t, tmpDir, "large.txt", strings.Repeat("x", 100))
+
+	scanner := NewScanner(tmpDir)
+	scanner.SetSizeLimits(1, 10)
+	files, err := scanner.Scan()
+	if err != nil {
+		t.Fatalf("Scan() returned error: %v", err)
+	}
+	if len(files) != 1 || files[0] != small {
+		t.Errorf("Scan() = %v, expected only %s", files, small)
+	}
+
+	scanner.SetSizeLimits(0, 0)
+	if files, _ := scanner.Scan(); len(files) != 3 {
+		t.Errorf("Scan() without limits found %d files, expected 3", len(files))
+	}
+}
+
+// TestScanner_Scan_TimeWindow tests that files modified outside the window are skipped.
+func TestScanner_Scan_TimeWindow(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	incident := time.Date(2024, 1, 30, 12, 0, 0, 0, time.Local)
+	for name, modTime := range map[string]time.Time{
+		"before.txt": incident.Add(-48 * time.Hour),
+		"during.txt": incident,
+		"after.txt":  incident.Add(48 * time.Hour),
+	} {
+		path := createFileWithContent(t, tmpDir, name, name)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatalf("Chtimes(%s) failed: %v", path, err)
+		}
+	}
+
+	scanner := NewScanner(tmpDir)
+	scanner.SetTimeWindow(incident.Add(-time.Hour), incident.Add(time.Hour))
+	files, err := scanner.Scan()
+	if err != nil {
+		t.Fatalf("Scan() returned error: %v", err)
+	}
+	if len(files) != 1 || filepath.Base(files[0]) != "during.txt" {
+		t.Errorf("Scan() = %v, expected only during.txt", files)
+	}
+
+	scanner.SetTimeWindow(incident, time.Time{})
+	if files, _ := scanner.Scan(); len(files) != 2 {
+		t.Errorf("Scan() with only a lower bound = %v, expected during.txt and after.txt", files)
+	}
+}
+
+// TestScanner_Scan_Extensions tests that only files with the given extensions are kept.
+func TestScanner_Scan_Extensions(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	for _, name := range []string{"notes.md", "todo.TXT", "photo.jpg", "README"} {
+		createFile(t, tmpDir, name)
+	}
+
+	scanner := NewScanner(tmpDir)
+	scanner.SetExtensions([]string{"md", ".txt"})
+	files, err := scanner.Scan()
+	if err != nil {
+		t.Fatalf("Scan() returned error: %v", err)
+	}
+	var names []string
+	for _, file := range files {
+		names = append(names, filepath.Base(file))
+	}
+	sort.Strings(names)
+	if strings.Join(names, " ") != "notes.md todo.TXT" {
+		t.Errorf("Scan() = %v, expected notes.md and todo.TXT", names)
+	}
+
+	scanner.SetExtensions(nil)
+	if files, _ := scanner.Scan(); len(files) != 4 {
+		t.Errorf("Scan() without extensions found %d files, expected 4", len(files))
+	}
+}
+
+// TestScanner_Scan_Excludes tests leaving out files and directories by name.
+func TestScanner_Scan_Excludes(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	for _, name := range []string{"notes.md", "notes.tmp", "todo.md"} {
+		createFile(t, tmpDir, name)
+	}
+	if err := os.Mkdir(filepath.Join(tmpDir, "node_modules"), 0755); err != nil {
+		t.Fatalf("failed to create node_modules: %v", err)
+	}
+	createFile(t, filepath.Join(tmpDir, "node_modules"), "notes.md")
+
+	scanner := NewScanner(tmpDir)
+	scanner.SetRecursive(true, 2)
+	scanner.SetExcludes([]string{"*.tmp", "node_modules"})
+	files, err := scanner.Scan()
+	if err != nil {
+		t.Fatalf("Scan() returned error: %v", err)
+	}
+	expected := []string{filepath.Join(tmpDir, "notes.md"), filepath.Join(tmpDir, "todo.md")}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("Scan() = %v, expected %v", files, expected)
+	}
+}
+
+// TestScanner_Scan_Hidden tests that dotfiles and junk files are skipped unless requested.
+func TestScanner_Scan_Hidden(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	for _, name := range []string{"notes.md", ".notes.md.swp", ".DS_Store", "Thumbs.db", "desktop.ini"} {
+		createFile(t, tmpDir, name)
+	}
+	if err := os.Mkdir(filepath.Join(tmpDir, ".git"), 0755); err != nil {
+		t.Fatalf("failed to create .git: %v", err)
+	}
+	createFile(t, filepath.Join(tmpDir, ".git"), "HEAD")
+
+	scanner := NewScanner(tmpDir)
+	scanner.SetRecursive(true, 2)
+	files, err := scanner.Scan()
+	if err != nil {
+		t.Fatalf("Scan() returned error: %v", err)
+	}
+	if len(files) != 1 || filepath.Base(files[0]) != "notes.md" {
+		t.Errorf("Scan() = %v, expected only notes.md", files)
+	}
+
+	scanner.SetIncludeHidden(true)
+	if files, _ := scanner.Scan(); len(files) != 6 {
+		t.Errorf("Scan() with hidden files = %v, expected 6 files", files)
+	}
+}
+
+// TestScanner_Scan_Symlinks tests following and skipping symbolic links.
+func TestScanner_Scan_Symlinks(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	target := createTempDir(t)
+	defer os.RemoveAll(target)
+
+	createFile(t, tmpDir, "notes.md")
+	createFile(t, target, "linked.md")
+	links := map[string]string{
+		"notes-link.md": filepath.Join(tmpDir, "notes.md"),
+		"other":         target,
+		"loop":          tmpDir,
+		"broken.md":     filepath.Join(tmpDir, "missing.md"),
+	}
+	for name, to := range links {
+		if err := os.Symlink(to, filepath.Join(tmpDir, name)); err != nil {
+			t.Skipf("symlinks not supported: %v", err)
+		}
+	}
+
+	scanner := NewScanner(tmpDir)
+	files, err := scanner.Scan()
+	if err != nil {
+		t.Fatalf("Scan() returned error: %v", err)
+	}
+	if len(files) != 2 {
+		t.Errorf("Scan() = %v, expected notes.md and notes-link.md", files)
+	}
+
+	// Recursive scans follow directory links but read each directory only once
+	scanner.SetRecursive(true, 2)
+	files, err = scanner.Scan()
+	if err != nil {
+		t.Fatalf("Scan() returned error: %v", err)
+	}
+	expected := []string{
+		filepath.Join(tmpDir, "notes-link.md"),
+		filepath.Join(tmpDir, "notes.md"),
+		filepath.Join(tmpDir, "other", "linked.md"),
+	}
+	if strings.Join(files, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Scan() = %v, expected %v", files, expected)
+	}
+
+	scanner.SetSkipSymlinks(true)
+	files, err = scanner.Scan()
+	if err != nil {
+		t.Fatalf("Scan() returned error: %v", err)
+	}
+	if len(files) != 1 || filepath.Base(files[0]) != "notes.md" {
+		t.Errorf("Scan() skipping symlinks = %v, expected only notes.md", files)
+	}
+}
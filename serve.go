@@ -0,0 +1,418 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultServeAddr only accepts local connections. Listening on other
+// interfaces has to be asked for, since the API can delete files.
+const defaultServeAddr = "127.0.0.1:8080"
+
+// serveShutdownTimeout is how long running requests get to finish on shutdown.
+const serveShutdownTimeout = 5 * time.Second
+
+// server holds the state behind the HTTP API of "doppel serve": the result of
+// the latest scan, which requests read and resolutions update.
+//
+// Clients refer to groups by their 1-based position in the latest group list
+// and to files by path. Every path in a request must belong to the named group,
+// so the API can only touch files the scan found. Resolving a group can shift
+// the positions of later groups; a request naming files that are no longer in
+// the group fails with 409 Conflict and the client should list groups again.
+//
+// Every request must carry the token printed at startup as a bearer token,
+// and name the server by IP address or localhost, so neither another site a
+// browser visits nor a page that rebinds its domain to this address can use
+// the API.
+type server struct {
+	ctx   context.Context
+	opts  options
+	token string
+
+	// mu guards the fields below and the ignore list in opts.
+	mu        sync.Mutex
+	scanning  bool
+	scanned   bool
+	progress  *Progress
+	groups    [][]string
+	fileCount int
+	scanErr   error
+}
+
+// newServer creates a server that scans with opts. Scans and diff commands are
+// cancelled when ctx is done.
+func newServer(ctx context.Context, opts options) *server {
+	opts.diffExec = opts.diffExec.WithContext(ctx)
+	token := make([]byte, 16)
+	rand.Read(token)
+	return &server{ctx: ctx, opts: opts, token: hex.EncodeToString(token)}
+}
+
+// handler returns the API routes.
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/scan", s.handleScan)
+	mux.HandleFunc("GET /api/status", s.handleStatus)
+	mux.HandleFunc("GET /api/groups", s.handleGroups)
+	mux.HandleFunc("GET /api/diff", s.handleDiff)
+	mux.HandleFunc("POST /api/resolve", s.handleResolve)
+	return s.authorize(mux)
+}
+
+// authorize rejects requests that name the server by a domain name other
+// than localhost, come from a page of another origin, or lack the token.
+func (s *server) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !allowedHost(r.Host) {
+			writeJSONError(w, http.StatusForbidden, fmt.Errorf("host %q not allowed; use the server's IP address or localhost", r.Host))
+			return
+		}
+		if origin := r.Header.Get("Origin"); origin != "" {
+			if u, err := url.Parse(origin); err != nil || u.Host != r.Host {
+				writeJSONError(w, http.StatusForbidden, fmt.Errorf("origin %q not allowed", origin))
+				return
+			}
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			writeJSONError(w, http.StatusUnauthorized, errors.New("missing or wrong token; send the one printed at startup as Authorization: Bearer <token>"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allowedHost reports whether host, the Host header of a request, names the
+// server by IP address or as localhost. Any other name may be a domain that a
+// page rebound to this address to reach it from the browser.
+func allowedHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	return strings.EqualFold(host, "localhost") || net.ParseIP(host) != nil
+}
+
+// startScan scans in the background, replacing the current groups when done.
+// It returns false if a scan is already running.
+func (s *server) startScan() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.scanning {
+		return false
+	}
+	s.scanning = true
+	s.progress = NewProgress("Scanning")
+
+	go func(progress *Progress) {
+		groups, fileCount, err := scanAndGroup(s.ctx, s.opts, progress)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.scanning, s.scanned = false, true
+		s.scanErr = err
+		if err == nil {
+			s.groups, s.fileCount = groups, fileCount
+		}
+	}(s.progress)
+	return true
+}
+
+// handleScan starts a scan. The client polls /api/status until it finishes.
+func (s *server) handleScan(w http.ResponseWriter, r *http.Request) {
+	if !s.startScan() {
+		writeJSONError(w, http.StatusConflict, errors.New("a scan is already running"))
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]string{"state": "scanning"})
+}
+
+// statusResponse is the body of GET /api/status.
+type statusResponse struct {
+	// State is "idle" before the first scan, then "scanning", "done", or "error".
+	State    string `json:"state"`
+	Progress string `json:"progress,omitempty"`
+	Files    int    `json:"files"`
+	Groups   int    `json:"groups"`
+	Error    string `json:"error,omitempty"`
+}
+
+// handleStatus reports whether a scan is running and how the last one went.
+func (s *server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resp := statusResponse{State: "idle", Files: s.fileCount, Groups: len(s.groups)}
+	switch {
+	case s.scanning:
+		resp.State = "scanning"
+		resp.Progress = s.progress.String()
+	case s.scanErr != nil:
+		resp.State = "error"
+		resp.Error = s.scanErr.Error()
+	case s.scanned:
+		resp.State = "done"
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// groupJSON is one group in the body of GET /api/groups.
+type groupJSON struct {
+	ID int `json:"id"`
+	// Fingerprint identifies the group across scans; see groupFingerprint.
+	Fingerprint string     `json:"fingerprint"`
+	Files       []fileJSON `json:"files"`
+}
+
+// fileJSON describes one file of a group.
+type fileJSON struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+}
+
+// handleGroups lists the groups of the latest scan.
+func (s *server) handleGroups(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	groups, scanned := s.groups, s.scanned
+	s.mu.Unlock()
+	if !scanned {
+		writeJSONError(w, http.StatusConflict, errors.New("no scan has finished yet"))
+		return
+	}
+
+	resp := struct {
+		Groups []groupJSON `json:"groups"`
+	}{Groups: []groupJSON{}}
+	for i, group := range groups {
+		g := groupJSON{ID: i + 1, Fingerprint: groupFingerprint(s.opts.dir, group)}
+		for _, file := range group {
+			f := fileJSON{Path: file}
+			if info, err := os.Stat(file); err == nil {
+				f.Size, f.ModTime = info.Size(), info.ModTime()
+			}
+			g.Files = append(g.Files, f)
+		}
+		resp.Groups = append(resp.Groups, g)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// groupFiles returns the group with the given 1-based id after checking that
+// every path belongs to it.
+func (s *server) groupFiles(id string, paths ...string) ([]string, error) {
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid group %q", id)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n < 1 || n > len(s.groups) {
+		return nil, fmt.Errorf("no group %d", n)
+	}
+	group := s.groups[n-1]
+	for _, path := range paths {
+		if !slices.Contains(group, path) {
+			return nil, fmt.Errorf("%s is not in group %d", path, n)
+		}
+	}
+	return group, nil
+}
+
+// handleDiff compares two files of a group, as the TUI diff view would.
+// Query parameters: group, file1, file2, and optionally format=unified.
+func (s *server) handleDiff(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	file1, file2 := q.Get("file1"), q.Get("file2")
+	if file1 == "" || file2 == "" || file1 == file2 {
+		writeJSONError(w, http.StatusBadRequest, errors.New("file1 and file2 must name two files"))
+		return
+	}
+	if _, err := s.groupFiles(q.Get("group"), file1, file2); err != nil {
+		writeJSONError(w, http.StatusConflict, err)
+		return
+	}
+
+	identical, err := filesByteIdentical(file1, file2)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	resp := struct {
+		Identical bool   `json:"identical"`
+		Diff      string `json:"diff"`
+	}{Identical: identical}
+	if !identical {
+		resp.Diff, err = s.opts.diffExec.ComparePair(file1, file2, q.Get("format") == "unified")
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// resolveRequest is the body of POST /api/resolve.
+type resolveRequest struct {
+	Group int `json:"group"`
+	// Action is "delete" or "hardlink", which remove or hard-link Remove after
+	// checking it is byte-identical to Keep, or "ignore", which hides the whole
+	// group on future scans.
+	Action string `json:"action"`
+	Keep   string `json:"keep"`
+	Remove string `json:"remove"`
+}
+
+// handleResolve acts on a group, with the same safety checks as the TUI.
+// The body must be sent as application/json, which a page of another site
+// can't do without the browser asking the server first.
+func (s *server) handleResolve(w http.ResponseWriter, r *http.Request) {
+	if ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); ct != "application/json" {
+		writeJSONError(w, http.StatusUnsupportedMediaType, errors.New("the body must be sent as application/json"))
+		return
+	}
+	var req resolveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Errorf("invalid request: %w", err))
+		return
+	}
+	id := strconv.Itoa(req.Group)
+
+	switch req.Action {
+	case "ignore":
+		group, err := s.groupFiles(id)
+		if err != nil {
+			writeJSONError(w, http.StatusConflict, err)
+			return
+		}
+		if s.opts.ignoreList == nil {
+			writeJSONError(w, http.StatusInternalServerError, errors.New("no ignore list"))
+			return
+		}
+		s.mu.Lock()
+		s.opts.ignoreList.Add(group)
+		err = s.opts.ignoreList.Save()
+		s.mu.Unlock()
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, fmt.Errorf("failed to save ignore list: %w", err))
+			return
+		}
+		s.updateGroup(group, nil)
+		writeJSON(w, http.StatusOK, map[string]string{"status": "Group ignored"})
+
+	case "delete", "hardlink":
+		if req.Keep == "" || req.Remove == "" || req.Keep == req.Remove {
+			writeJSONError(w, http.StatusBadRequest, errors.New("keep and remove must name two files"))
+			return
+		}
+		group, err := s.groupFiles(id, req.Keep, req.Remove)
+		if err != nil {
+			writeJSONError(w, http.StatusConflict, err)
+			return
+		}
+		if identical, err := filesByteIdentical(req.Keep, req.Remove); err != nil || !identical {
+			writeJSONError(w, http.StatusConflict, errors.New("files are not identical; nothing was changed"))
+			return
+		}
+		if link := symlinkTo(group, req.Remove); link != "" {
+			writeJSONError(w, http.StatusConflict, fmt.Errorf("%s is a symbolic link to %s; nothing was changed", link, req.Remove))
+			return
+		}
+
+		var status string
+		if req.Action == "hardlink" {
+			if sameFile(req.Keep, req.Remove) {
+				writeJSONError(w, http.StatusConflict, errors.New("files are already hard links to the same data"))
+				return
+			}
+			if err := replaceWithHardlink(req.Keep, req.Remove); err != nil {
+				writeJSONError(w, http.StatusInternalServerError, err)
+				return
+			}
+			status = fmt.Sprintf("Replaced %s with a hard link to %s", req.Remove, req.Keep)
+		} else {
+			if _, err := s.opts.quarantine.Remove(req.Remove); err != nil {
+				writeJSONError(w, http.StatusInternalServerError, err)
+				return
+			}
+			status = s.opts.quarantine.Describe(req.Remove)
+			remaining := slices.DeleteFunc(slices.Clone(group), func(f string) bool { return f == req.Remove })
+			s.updateGroup(group, remaining)
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": status})
+
+	default:
+		writeJSONError(w, http.StatusBadRequest, fmt.Errorf("unknown action %q", req.Action))
+	}
+}
+
+// updateGroup replaces group with remaining in the group list, removing it if
+// fewer than two files remain. It does nothing if a new scan replaced the list.
+func (s *server) updateGroup(group, remaining []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, g := range s.groups {
+		if slices.Equal(g, group) {
+			groups := slices.Clone(s.groups)
+			if len(remaining) >= 2 {
+				groups[i] = remaining
+			} else {
+				groups = slices.Delete(groups, i, i+1)
+			}
+			s.groups = groups
+			return
+		}
+	}
+}
+
+// writeJSON writes v as the JSON response body with the given status code.
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeJSONError writes err as {"error": "..."} with the given status code.
+func writeJSONError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
+
+// runServe starts a scan and serves the API on addr until ctx is cancelled.
+func runServe(ctx context.Context, opts options, addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s := newServer(ctx, opts)
+	s.startScan()
+	srv := &http.Server{Handler: s.handler(), ReadHeaderTimeout: 10 * time.Second}
+
+	fmt.Fprintf(os.Stderr, "Serving the doppel API for %s on http://%s\n", opts.dir, ln.Addr())
+	fmt.Fprintf(os.Stderr, "Send this token with every request as Authorization: Bearer %s\n", s.token)
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(ln) }()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), serveShutdownTimeout)
+		defer cancel()
+		return srv.Shutdown(shutdownCtx)
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestServer scans dir with a server and waits for the scan to finish.
+func newTestServer(t *testing.T, dir string) (*server, *httptest.Server) {
+	t.Helper()
+	ignoreList, err := LoadIgnoreList(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := options{dir: dir, minPrefix: defaultMinPrefixLength, scanWorkers: 1,
+		ignoreList: ignoreList, diffExec: NewDiffExecutor("diff")}
+	s := newServer(context.Background(), opts)
+	ts := httptest.NewServer(s.handler())
+	t.Cleanup(ts.Close)
+
+	if !s.startScan() {
+		t.Fatal("startScan() refused the first scan")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var status statusResponse
+		getJSON(t, s.token, ts.URL+"/api/status", http.StatusOK, &status)
+		if status.State == "done" {
+			break
+		}
+		if status.State == "error" || time.Now().After(deadline) {
+			t.Fatalf("scan did not finish: %+v", status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return s, ts
+}
+
+// getJSON fetches url with token, checks the status code, and decodes the body
+// into v.
+func getJSON(t *testing.T, token, url string, code int, v any) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != code {
+		t.Fatalf("GET %s returned %d, expected %d", url, resp.StatusCode, code)
+	}
+	if v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatalf("GET %s returned invalid JSON: %v", url, err)
+		}
+	}
+}
+
+// postResolve posts a resolution and checks the status code.
+func postResolve(t *testing.T, s *server, ts *httptest.Server, req resolveRequest, code int) {
+	t.Helper()
+	body, _ := json.Marshal(req)
+	r, err := http.NewRequest(http.MethodPost, ts.URL+"/api/resolve", strings.NewReader(string(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Authorization", "Bearer "+s.token)
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != code {
+		t.Fatalf("resolve %+v returned %d, expected %d", req, resp.StatusCode, code)
+	}
+}
+
+// TestServe_GroupsAndDiff tests listing groups and diffing a pair.
+func TestServe_GroupsAndDiff(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	notes := createFileWithContent(t, tmpDir, "notes.txt", "a\nb\n")
+	notesEdit := createFileWithContent(t, tmpDir, "notes-1.txt", "a\nc\n")
+	s, ts := newTestServer(t, tmpDir)
+
+	var groups struct {
+		Groups []groupJSON `json:"groups"`
+	}
+	getJSON(t, s.token, ts.URL+"/api/groups", http.StatusOK, &groups)
+	if len(groups.Groups) != 1 || groups.Groups[0].ID != 1 || len(groups.Groups[0].Files) != 2 {
+		t.Fatalf("GET /api/groups = %+v, expected one group of two files", groups)
+	}
+	if groups.Groups[0].Files[0].Size != 4 {
+		t.Errorf("file size = %d, expected 4", groups.Groups[0].Files[0].Size)
+	}
+
+	query := url.Values{"group": {"1"}, "file1": {notes}, "file2": {notesEdit}, "format": {"unified"}}
+	var diff struct {
+		Identical bool   `json:"identical"`
+		Diff      string `json:"diff"`
+	}
+	getJSON(t, s.token, ts.URL+"/api/diff?"+query.Encode(), http.StatusOK, &diff)
+	if diff.Identical || !strings.Contains(diff.Diff, "+c") {
+		t.Errorf("GET /api/diff = %+v, expected a unified diff", diff)
+	}
+
+	// Paths outside the group are refused
+	query.Set("file2", "/etc/passwd")
+	getJSON(t, s.token, ts.URL+"/api/diff?"+query.Encode(), http.StatusConflict, nil)
+}
+
+// TestServe_Resolve tests that only identical files are deleted and that
+// ignoring a group removes it from the list.
+func TestServe_Resolve(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	doc := createFileWithContent(t, tmpDir, "doc.txt", "same")
+	docCopy := createFileWithContent(t, tmpDir, "doc-1.txt", "same")
+	report := createFileWithContent(t, tmpDir, "report.txt", "one")
+	reportEdit := createFileWithContent(t, tmpDir, "report-1.txt", "two")
+	s, ts := newTestServer(t, tmpDir)
+
+	postResolve(t, s, ts, resolveRequest{Group: 2, Action: "delete", Keep: report, Remove: reportEdit}, http.StatusConflict)
+	postResolve(t, s, ts, resolveRequest{Group: 1, Action: "delete", Keep: doc, Remove: filepath.Join(tmpDir, "other.txt")}, http.StatusConflict)
+	postResolve(t, s, ts, resolveRequest{Group: 1, Action: "rename"}, http.StatusBadRequest)
+
+	postResolve(t, s, ts, resolveRequest{Group: 1, Action: "delete", Keep: doc, Remove: docCopy}, http.StatusOK)
+	if _, err := os.Stat(docCopy); !os.IsNotExist(err) {
+		t.Errorf("%s should have been deleted", docCopy)
+	}
+	if _, err := os.Stat(reportEdit); err != nil {
+		t.Errorf("%s should have been kept: %v", reportEdit, err)
+	}
+	if len(s.groups) != 1 || !slices.Contains(s.groups[0], report) {
+		t.Fatalf("groups after delete = %v, expected only the report group", s.groups)
+	}
+
+	postResolve(t, s, ts, resolveRequest{Group: 1, Action: "ignore"}, http.StatusOK)
+	if len(s.groups) != 0 {
+		t.Errorf("groups after ignore = %v, expected none", s.groups)
+	}
+	if !s.opts.ignoreList.Contains([]string{report, reportEdit}) {
+		t.Error("the ignored group should be saved in the ignore list")
+	}
+}
+
+// TestServe_ResolveSymlinkTarget tests that the file a symbolic link of the
+// group points to isn't deleted, since that would remove the last real copy.
+func TestServe_ResolveSymlinkTarget(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	doc := createFileWithContent(t, tmpDir, "doc.txt", "same")
+	link := filepath.Join(tmpDir, "doc-1.txt")
+	if err := os.Symlink(doc, link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	s, ts := newTestServer(t, tmpDir)
+
+	postResolve(t, s, ts, resolveRequest{Group: 1, Action: "delete", Keep: link, Remove: doc}, http.StatusConflict)
+	if _, err := os.Stat(doc); err != nil {
+		t.Errorf("%s should have been kept: %v", doc, err)
+	}
+}
+
+// TestServe_Authorization tests that requests without the token, from another
+// origin, naming the server by a domain, or posting a form are refused.
+func TestServe_Authorization(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	doc := createFileWithContent(t, tmpDir, "doc.txt", "same")
+	docCopy := createFileWithContent(t, tmpDir, "doc-1.txt", "same")
+	s, ts := newTestServer(t, tmpDir)
+
+	body := `{"group": 1, "action": "delete", "keep": "` + doc + `", "remove": "` + docCopy + `"}`
+	tests := []struct {
+		name   string
+		header map[string]string
+		host   string
+		code   int
+	}{
+		{"no token", map[string]string{"Content-Type": "application/json"}, "", http.StatusUnauthorized},
+		{"wrong token", map[string]string{"Content-Type": "application/json", "Authorization": "Bearer nope"}, "", http.StatusUnauthorized},
+		{"form post", map[string]string{"Content-Type": "text/plain", "Authorization": "Bearer " + s.token}, "", http.StatusUnsupportedMediaType},
+		{"other origin", map[string]string{"Content-Type": "application/json", "Authorization": "Bearer " + s.token, "Origin": "http://evil.example"}, "", http.StatusForbidden},
+		{"rebound domain", map[string]string{"Content-Type": "application/json", "Authorization": "Bearer " + s.token}, "evil.example:8080", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, ts.URL+"/api/resolve", strings.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			if tt.host != "" {
+				req.Host = tt.host
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.code {
+				t.Errorf("status = %d, expected %d", resp.StatusCode, tt.code)
+			}
+		})
+	}
+	if _, err := os.Stat(docCopy); err != nil {
+		t.Errorf("no refused request should delete %s: %v", docCopy, err)
+	}
+
+	// The same origin is allowed
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/api/groups", nil)
+	req.Header.Set("Authorization", "Bearer "+s.token)
+	req.Header.Set("Origin", ts.URL)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("same-origin request returned %d, expected 200", resp.StatusCode)
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// SessionStats totals what was done in a TUI session, for the summary printed
+// when it quits: the groups opened, the pairs compared, every file operation
+// with the space it freed, and the groups corrected by hand. Operations undone
+// with u are taken off again. A nil *SessionStats records nothing.
+type SessionStats struct {
+	opened   map[string]bool
+	compared map[[2]string]bool
+	ops      []sessionOp
+	edits    []string
+}
+
+// sessionOp is a file operation of the session and the size of the file it
+// removed or replaced, zero for renames and moves.
+type sessionOp struct {
+	entry JournalEntry
+	size  int64
+}
+
+// NewSessionStats returns empty session totals.
+func NewSessionStats() *SessionStats {
+	return &SessionStats{opened: make(map[string]bool), compared: make(map[[2]string]bool)}
+}
+
+// OpenGroup counts group as opened. Opening it again doesn't count twice.
+func (s *SessionStats) OpenGroup(group []string) {
+	if s == nil {
+		return
+	}
+	s.opened[groupFingerprint("", group)] = true
+}
+
+// ComparePair counts the pair as compared, in either order.
+func (s *SessionStats) ComparePair(file1, file2 string) {
+	if s == nil {
+		return
+	}
+	if file2 < file1 {
+		file1, file2 = file2, file1
+	}
+	s.compared[[2]string{file1, file2}] = true
+}
+
+// Record adds a file operation; size is what deleting or hard-linking the file
+// freed.
+func (s *SessionStats) Record(e JournalEntry, size int64) {
+	if s == nil {
+		return
+	}
+	s.ops = append(s.ops, sessionOp{e, size})
+}
+
+// Undo takes off the last recorded operation matching e, after it was undone.
+func (s *SessionStats) Undo(e JournalEntry) {
+	if s == nil {
+		return
+	}
+	for i := len(s.ops) - 1; i >= 0; i-- {
+		if op := s.ops[i].entry; op.Action == e.Action && op.Path == e.Path && op.Target == e.Target {
+			s.ops = append(s.ops[:i:i], s.ops[i+1:]...)
+			return
+		}
+	}
+}
+
+// SplitGroup records files split off their group into a group of their own.
+func (s *SessionStats) SplitGroup(files []string) {
+	if s == nil {
+		return
+	}
+	own := "its"
+	if len(files) > 1 {
+		own = "their"
+	}
+	s.edits = append(s.edits, fmt.Sprintf("split %s off into a group of %s own", strings.Join(files, ", "), own))
+}
+
+// MergeGroups records the group whose first file is from merged into the
+// group whose first file is into.
+func (s *SessionStats) MergeGroups(from, into string) {
+	if s == nil {
+		return
+	}
+	s.edits = append(s.edits, fmt.Sprintf("merged the group of %s into the group of %s", from, into))
+}
+
+// WriteSummary writes the totals and then each file operation, e.g.
+//
+//	Session summary
+//	  Groups opened:    4 (2 marked reviewed)
+//	  Pairs compared:   7
+//	  Files changed:    2 deleted, 1 renamed
+//	  Space reclaimed:  12.3 MB
+//	  Groups edited:    1
+//
+// The groups edited line is left out when no group was split or merged.
+// reviewed is the number of groups marked reviewed when the session ended.
+// Quarantined files count as reclaimed space only once the quarantine is
+// emptied, so their size is given apart.
+func (s *SessionStats) WriteSummary(w io.Writer, reviewed int) error {
+	if s == nil {
+		return nil
+	}
+	counts := make(map[JournalAction]int)
+	var reclaimed, quarantined int64
+	for _, op := range s.ops {
+		counts[op.entry.Action]++
+		if op.entry.Action == JournalQuarantine {
+			quarantined += op.size
+		} else {
+			reclaimed += op.size
+		}
+	}
+	var changed []string
+	for _, c := range []struct {
+		action JournalAction
+		verb   string
+	}{
+		{JournalDelete, "deleted"},
+		{JournalQuarantine, "quarantined"},
+		{JournalRename, "renamed"},
+		{JournalMove, "moved"},
+		{JournalHardlink, "hard-linked"},
+	} {
+		if n := counts[c.action]; n > 0 {
+			changed = append(changed, fmt.Sprintf("%d %s", n, c.verb))
+		}
+	}
+	if len(changed) == 0 {
+		changed = []string{"none"}
+	}
+
+	var b strings.Builder
+	b.WriteString("Session summary\n")
+	fmt.Fprintf(&b, "  Groups opened:    %d", len(s.opened))
+	if reviewed > 0 {
+		fmt.Fprintf(&b, " (%d marked reviewed)", reviewed)
+	}
+	fmt.Fprintf(&b, "\n  Pairs compared:   %d\n", len(s.compared))
+	fmt.Fprintf(&b, "  Files changed:    %s\n", strings.Join(changed, ", "))
+	fmt.Fprintf(&b, "  Space reclaimed:  %s", formatBytes(reclaimed))
+	if quarantined > 0 {
+		fmt.Fprintf(&b, " (%s more once the quarantine is emptied)", formatBytes(quarantined))
+	}
+	b.WriteString("\n")
+	if len(s.edits) > 0 {
+		fmt.Fprintf(&b, "  Groups edited:    %d\n", len(s.edits))
+	}
+	for _, op := range s.ops {
+		fmt.Fprintf(&b, "  %s\n", op.entry)
+	}
+	for _, edit := range s.edits {
+		fmt.Fprintf(&b, "  %s\n", edit)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// fileSize returns the size of path, or 0 if it can't be read, for the space
+// an operation on it frees.
+func fileSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
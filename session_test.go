@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// TestSessionStats_WriteSummary tests the totals printed when the TUI quits.
+func TestSessionStats_WriteSummary(t *testing.T) {
+	s := NewSessionStats()
+	s.OpenGroup([]string{"/notes/a.md", "/notes/a 2.md"})
+	s.OpenGroup([]string{"/notes/a.md", "/notes/a 2.md"})
+	s.ComparePair("/notes/a.md", "/notes/a 2.md")
+	s.ComparePair("/notes/a 2.md", "/notes/a.md")
+	s.Record(JournalEntry{Action: JournalDelete, Path: "/notes/a 2.md"}, 2048)
+	s.Record(JournalEntry{Action: JournalHardlink, Path: "/notes/b 2.md", Target: "/notes/b.md"}, 1024)
+	s.Record(JournalEntry{Action: JournalQuarantine, Path: "/notes/c 2.md", Target: "/q/c 2.md"}, 512)
+	s.Record(JournalEntry{Action: JournalRename, Path: "/notes/d.md", Target: "/notes/e.md"}, 0)
+	s.SplitGroup([]string{"/notes/f.md"})
+
+	var out bytes.Buffer
+	if err := s.WriteSummary(&out, 1); err != nil {
+		t.Fatalf("WriteSummary() returned error: %v", err)
+	}
+	for _, want := range []string{
+		"Groups opened:    1 (1 marked reviewed)\n",
+		"Pairs compared:   1\n",
+		"Files changed:    1 deleted, 1 quarantined, 1 renamed, 1 hard-linked\n",
+		"Space reclaimed:  3.0 KB (512 B more once the quarantine is emptied)\n",
+		"  deleted /notes/a 2.md\n",
+		"  renamed /notes/d.md to e.md\n",
+		"Groups edited:    1\n",
+		"  split /notes/f.md off into a group of its own\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("summary should contain %q:\n%s", want, out.String())
+		}
+	}
+}
+
+// TestSessionStats_Undo tests that undone operations leave the totals.
+func TestSessionStats_Undo(t *testing.T) {
+	s := NewSessionStats()
+	deleted := JournalEntry{Action: JournalDelete, Path: "/notes/a 2.md"}
+	s.Record(deleted, 2048)
+	s.Undo(deleted)
+
+	var out bytes.Buffer
+	if err := s.WriteSummary(&out, 0); err != nil {
+		t.Fatalf("WriteSummary() returned error: %v", err)
+	}
+	if !strings.Contains(out.String(), "Files changed:    none\n") || !strings.Contains(out.String(), "Space reclaimed:  0 B\n") {
+		t.Errorf("an undone deletion should not be counted:\n%s", out.String())
+	}
+
+	var none *SessionStats
+	none.Record(deleted, 1)
+	if err := none.WriteSummary(&out, 0); err != nil {
+		t.Errorf("nil WriteSummary() returned error: %v", err)
+	}
+}
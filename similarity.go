@@ -0,0 +1,116 @@
+package main
+
+// Methods used to score a pair of files; see pairSimilarity.
+const (
+	similarityIdentical = "identical"
+	similarityImage     = "image"
+	similarityLines     = "lines"
+)
+
+// PairRecord scores how alike two files of the same group are.
+type PairRecord struct {
+	Group        int
+	Path1, Path2 string
+	// Similarity ranges from 0 (nothing in common) to 1 (same content).
+	Similarity float64
+	// Method names how Similarity was computed: identical hashes, perceptual
+	// image hashes, or shared lines.
+	Method string
+}
+
+// buildPairRecords scores every pair of files within each group of records.
+// Pairs that cannot be scored, such as non-image binary files or text files too
+// large to align, are left out.
+func buildPairRecords(records []FileRecord) []PairRecord {
+	var pairs []PairRecord
+	for start := 0; start < len(records); {
+		end := start + 1
+		for end < len(records) && records[end].Group == records[start].Group {
+			end++
+		}
+		group := records[start:end]
+		for i := range group {
+			for j := i + 1; j < len(group); j++ {
+				score, method, ok := pairSimilarity(group[i], group[j])
+				if ok {
+					pairs = append(pairs, PairRecord{
+						Group:      group[i].Group,
+						Path1:      group[i].Path,
+						Path2:      group[j].Path,
+						Similarity: score,
+						Method:     method,
+					})
+				}
+			}
+		}
+		start = end
+	}
+	return pairs
+}
+
+// pairSimilarity scores two files: 1 if their hashes match, the perceptual hash
+// similarity for two images, or the share of lines two text files have in common.
+func pairSimilarity(a, b FileRecord) (float64, string, bool) {
+	if a.Hash != "" && a.Hash == b.Hash {
+		return 1, similarityIdentical, true
+	}
+
+	if isImageFile(a.Path) && isImageFile(b.Path) {
+		info1, err1 := loadImageInfo(a.Path)
+		info2, err2 := loadImageInfo(b.Path)
+		if err1 == nil && err2 == nil {
+			return hashSimilarity(info1.Hash, info2.Hash), similarityImage, true
+		}
+	}
+
+	if binary, err := anyBinary(a.Path, b.Path); err != nil || binary {
+		return 0, "", false
+	}
+	lines1, err := readLines(a.Path)
+	if err != nil {
+		return 0, "", false
+	}
+	lines2, err := readLines(b.Path)
+	if err != nil {
+		return 0, "", false
+	}
+	score, ok := lineSimilarity(lines1, lines2)
+	return score, similarityLines, ok
+}
+
+// lineSimilarity returns twice the number of lines in the longest common
+// subsequence of a and b, divided by their total number of lines. ok is false
+// when the files are too large to align within multiWayMaxCells.
+func lineSimilarity(a, b []string) (float64, bool) {
+	if len(a)+len(b) == 0 {
+		return 1, true
+	}
+	common, ok := commonLines(a, b)
+	if !ok {
+		return 0, false
+	}
+	return 2 * float64(common) / float64(len(a)+len(b)), true
+}
+
+// commonLines returns the number of lines in the longest common subsequence of
+// a and b. ok is false when they are too large to align within multiWayMaxCells.
+func commonLines(a, b []string) (int, bool) {
+	if (len(a)+1)*(len(b)+1) > multiWayMaxCells {
+		return 0, false
+	}
+
+	// Two rows of the LCS table are enough to get its length
+	prev := make([]int32, len(b)+1)
+	cur := make([]int32, len(b)+1)
+	for i := range a {
+		for j := range b {
+			if a[i] == b[j] {
+				cur[j+1] = prev[j] + 1
+			} else {
+				cur[j+1] = max(prev[j+1], cur[j])
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return int(prev[len(b)]), true
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+// TestLineSimilarity tests the shared-line ratio of two texts.
+func TestLineSimilarity(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     []string
+		expected float64
+	}{
+		{"Both empty", nil, nil, 1},
+		{"One empty", []string{"a"}, nil, 0},
+		{"Same lines", []string{"a", "b"}, []string{"a", "b"}, 1},
+		{"One line changed", []string{"a", "b", "c", "d"}, []string{"a", "x", "c", "d"}, 0.75},
+		{"Line appended", []string{"a", "b", "c"}, []string{"a", "b", "c", "d"}, 6.0 / 7},
+		{"Nothing shared", []string{"a"}, []string{"b"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := lineSimilarity(tt.a, tt.b)
+			if !ok || got != tt.expected {
+				t.Errorf("lineSimilarity() = %v, %v; expected %v", got, ok, tt.expected)
+			}
+		})
+	}
+}
+
+// TestBuildPairRecords tests that every pair within a group is scored and
+// unscorable binary pairs are left out.
+func TestBuildPairRecords(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	notes := createFileWithContent(t, tmpDir, "notes.txt", "a\nb\nc\nd\n")
+	notesCopy := createFileWithContent(t, tmpDir, "notes-1.txt", "a\nb\nc\nd\n")
+	notesEdit := createFileWithContent(t, tmpDir, "notes-2.txt", "a\nx\nc\nd\n")
+	blob := createFileWithContent(t, tmpDir, "blob.bin", "\x00\x01")
+	blobEdit := createFileWithContent(t, tmpDir, "blob-1.bin", "\x00\x02")
+
+	records, err := buildFileRecords(context.Background(), [][]string{{notes, notesCopy, notesEdit}, {blob, blobEdit}}, nil, nil)
+	if err != nil {
+		t.Fatalf("buildFileRecords() returned error: %v", err)
+	}
+	pairs := buildPairRecords(records)
+
+	expected := []PairRecord{
+		{1, notes, notesCopy, 1, similarityIdentical},
+		{1, notes, notesEdit, 0.75, similarityLines},
+		{1, notesCopy, notesEdit, 0.75, similarityLines},
+	}
+	if len(pairs) != len(expected) {
+		t.Fatalf("buildPairRecords() = %v, expected %v", pairs, expected)
+	}
+	for i := range expected {
+		if pairs[i] != expected[i] {
+			t.Errorf("pairs[%d] = %v, expected %v", i, pairs[i], expected[i])
+		}
+	}
+}
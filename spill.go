@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// spillPartitions is the number of files a spillTable spreads its entries
+// over; each is read back on its own, so about this fraction of the table is
+// in memory at a time.
+const spillPartitions = 64
+
+// spillEntryOverhead approximates the memory an entry takes besides its key
+// and value: string headers, slice growth, and map buckets.
+const spillEntryOverhead = 48
+
+// spillTable is a multimap from keys to values that moves its entries to
+// temporary files once they take more than limit bytes in memory, for the
+// hash tables of scans of millions of files. Entries are partitioned by key,
+// so each partition read back holds every value of its keys, in the order
+// they were added. A limit of zero keeps everything in memory.
+type spillTable struct {
+	limit int64
+	size  int64
+	mem   map[string][]string
+	// dir holds the partition files, created on the first spill.
+	dir   string
+	parts []*os.File
+}
+
+// newSpillTable returns an empty table that spills once it holds more than
+// limit bytes.
+func newSpillTable(limit int64) *spillTable {
+	return &spillTable{limit: limit, mem: make(map[string][]string)}
+}
+
+// Add adds value under key, spilling the table to disk if that takes it over
+// its limit.
+func (t *spillTable) Add(key, value string) error {
+	t.mem[key] = append(t.mem[key], value)
+	t.size += int64(len(key)+len(value)) + spillEntryOverhead
+	if t.limit > 0 && t.size > t.limit {
+		return t.spill()
+	}
+	return nil
+}
+
+// Spilled reports whether any entries were moved to disk.
+func (t *spillTable) Spilled() bool {
+	return t.dir != ""
+}
+
+// spill appends the entries in memory to their partition files and empties
+// the table in memory.
+func (t *spillTable) spill() error {
+	if t.dir == "" {
+		dir, err := os.MkdirTemp("", "doppel-spill-")
+		if err != nil {
+			return fmt.Errorf("failed to create spill directory: %w", err)
+		}
+		t.dir = dir
+		t.parts = make([]*os.File, spillPartitions)
+		for i := range t.parts {
+			f, err := os.Create(filepath.Join(dir, fmt.Sprintf("part%02d", i)))
+			if err != nil {
+				return err
+			}
+			t.parts[i] = f
+		}
+		logger.Info("spilling table to disk", "dir", dir, "limit", formatBytes(t.limit))
+	}
+
+	writers := make([]*bufio.Writer, len(t.parts))
+	for i, f := range t.parts {
+		writers[i] = bufio.NewWriter(f)
+	}
+	for key, values := range t.mem {
+		w := writers[spillPartition(key)]
+		for _, value := range values {
+			writeSpillString(w, key)
+			writeSpillString(w, value)
+		}
+	}
+	for _, w := range writers {
+		if err := w.Flush(); err != nil {
+			return fmt.Errorf("failed to spill table: %w", err)
+		}
+	}
+	t.mem = make(map[string][]string)
+	t.size = 0
+	return nil
+}
+
+// Each calls fn for every key with all its values, a partition at a time,
+// with the keys of each partition in sorted order. It stops at the first
+// error fn returns.
+func (t *spillTable) Each(fn func(key string, values []string) error) error {
+	if !t.Spilled() {
+		return eachSorted(t.mem, fn)
+	}
+	if err := t.spill(); err != nil {
+		return err
+	}
+	for _, f := range t.parts {
+		part, err := readSpillPartition(f)
+		if err != nil {
+			return fmt.Errorf("failed to read spilled table: %w", err)
+		}
+		if err := eachSorted(part, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close removes the partition files, if any.
+func (t *spillTable) Close() error {
+	if t.dir == "" {
+		return nil
+	}
+	var errs []error
+	for _, f := range t.parts {
+		if f != nil {
+			errs = append(errs, f.Close())
+		}
+	}
+	errs = append(errs, os.RemoveAll(t.dir))
+	return errors.Join(errs...)
+}
+
+// eachSorted calls fn for the keys of table in sorted order.
+func eachSorted(table map[string][]string, fn func(key string, values []string) error) error {
+	keys := make([]string, 0, len(table))
+	for key := range table {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := fn(key, table[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// spillPartition returns the partition of key.
+func spillPartition(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % spillPartitions)
+}
+
+// writeSpillString writes s prefixed with its length, so keys and values may
+// hold any byte, newlines in file names included.
+func writeSpillString(w *bufio.Writer, s string) {
+	var n [binary.MaxVarintLen64]byte
+	w.Write(n[:binary.PutUvarint(n[:], uint64(len(s)))])
+	w.WriteString(s)
+}
+
+// readSpillPartition reads the entries of a partition file into a table.
+func readSpillPartition(f *os.File) (map[string][]string, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	r := bufio.NewReader(f)
+	table := make(map[string][]string)
+	for {
+		key, err := readSpillString(r)
+		if err == io.EOF {
+			return table, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		value, err := readSpillString(r)
+		if err != nil {
+			return nil, io.ErrUnexpectedEOF
+		}
+		table[key] = append(table[key], value)
+	}
+}
+
+// readSpillString reads a string written by writeSpillString.
+func readSpillString(r *bufio.Reader) (string, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return "", err
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return "", io.ErrUnexpectedEOF
+	}
+	return string(b), nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+// TestSpillTable tests that a table spilled to disk gives back every value of
+// each key in the order added, whatever bytes they hold.
+func TestSpillTable(t *testing.T) {
+	entries := [][2]string{
+		{"5", "/notes/a.md"},
+		{"7", "/notes/b.md"},
+		{"5", "/notes/line\nbreak.md"},
+		{"", "/notes/empty key.md"},
+		{"7", "/notes/b 2.md"},
+		{"5", "/notes/a 2.md"},
+	}
+	expected := map[string][]string{
+		"5": {"/notes/a.md", "/notes/line\nbreak.md", "/notes/a 2.md"},
+		"7": {"/notes/b.md", "/notes/b 2.md"},
+		"":  {"/notes/empty key.md"},
+	}
+
+	for _, limit := range []int64{0, 1, 120} {
+		table := newSpillTable(limit)
+		for _, e := range entries {
+			if err := table.Add(e[0], e[1]); err != nil {
+				t.Fatalf("Add() with limit %d returned error: %v", limit, err)
+			}
+		}
+		if table.Spilled() != (limit > 0) {
+			t.Errorf("Spilled() with limit %d = %v", limit, table.Spilled())
+		}
+
+		got := make(map[string][]string)
+		err := table.Each(func(key string, values []string) error {
+			if _, seen := got[key]; seen {
+				t.Errorf("Each() with limit %d gave key %q twice", limit, key)
+			}
+			got[key] = values
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Each() with limit %d returned error: %v", limit, err)
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("Each() with limit %d gave %q, expected %q", limit, got, expected)
+		}
+
+		dir := table.dir
+		if err := table.Close(); err != nil {
+			t.Errorf("Close() returned error: %v", err)
+		}
+		if _, err := os.Stat(dir); dir != "" && !os.IsNotExist(err) {
+			t.Errorf("Close() should remove %s", dir)
+		}
+	}
+}
+
+// TestDiffCacheLimit tests the share of --max-memory given to the diff cache.
+func TestDiffCacheLimit(t *testing.T) {
+	tests := []struct {
+		maxMemory, expected int64
+	}{
+		{0, defaultDiffCacheSize},
+		{4 << 20, 1 << 20},
+		{4 << 30, defaultDiffCacheSize},
+		{2, 1},
+	}
+	for _, tt := range tests {
+		if got := diffCacheLimit(tt.maxMemory); got != tt.expected {
+			t.Errorf("diffCacheLimit(%d) = %d, expected %d", tt.maxMemory, got, tt.expected)
+		}
+	}
+}
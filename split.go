@@ -0,0 +1,114 @@
+package main
+
+import (
+	"strings"
+)
+
+// maxSplitGroupSize caps the size of the groups split by name similarity,
+// since each file is compared with the files of every subgroup before it.
+const maxSplitGroupSize = 2000
+
+// splitGroups splits each group whose names are not all at least
+// SplitSimilarity alike into tighter subgroups. Prefix matching chains files
+// together: when A shares a prefix with B and B with C, A and C end up in one
+// group although they may have little in common. Files alone in their
+// subgroup leave the groups.
+func (m *Matcher) splitGroups(groups [][]string) [][]string {
+	var result [][]string
+	for _, group := range groups {
+		if len(group) > maxSplitGroupSize {
+			logger.Info("group too large to split by similarity", "label", m.Label(group), "files", len(group))
+			result = append(result, group)
+			continue
+		}
+		result = append(result, m.splitGroup(group)...)
+	}
+	if len(result) != len(groups) {
+		logger.Info("split groups by name similarity", "groups", len(groups), "subgroups", len(result))
+	}
+	return result
+}
+
+// splitGroup splits group into subgroups whose names are pairwise at least
+// SplitSimilarity alike. Each file joins the first subgroup all of whose
+// files it is alike enough, or else starts a new one, so files keep their
+// order and subgroups are ordered by their first file.
+func (m *Matcher) splitGroup(group []string) [][]string {
+	grams := make([]map[string]int, len(group))
+	for i, file := range group {
+		grams[i] = nameBigrams(m.matchName(file).stem)
+	}
+
+	var subgroups [][]int
+	for i := range group {
+		placed := false
+		for s, members := range subgroups {
+			alike := true
+			for _, j := range members {
+				if bigramSimilarity(grams[i], grams[j]) < m.opts.SplitSimilarity {
+					alike = false
+					break
+				}
+			}
+			if alike {
+				subgroups[s] = append(members, i)
+				placed = true
+				break
+			}
+		}
+		if !placed {
+			subgroups = append(subgroups, []int{i})
+		}
+	}
+
+	var result [][]string
+	for _, members := range subgroups {
+		if len(members) < 2 {
+			logger.Debug("in no group", "path", group[members[0]], "reason", "its name is not alike enough to any other of its group for --split-groups")
+			continue
+		}
+		sub := make([]string, len(members))
+		for k, i := range members {
+			sub[k] = group[i]
+		}
+		result = append(result, sub)
+	}
+	return result
+}
+
+// nameSimilarity returns how alike two names are, from 0 to 1: the Dice
+// coefficient of their case-insensitive character bigrams.
+func nameSimilarity(a, b string) float64 {
+	return bigramSimilarity(nameBigrams(a), nameBigrams(b))
+}
+
+// nameBigrams returns the multiset of the pairs of adjacent characters of
+// name, lowercased. A name of one character is its own bigram.
+func nameBigrams(name string) map[string]int {
+	runes := []rune(strings.ToLower(name))
+	grams := make(map[string]int)
+	if len(runes) == 1 {
+		grams[string(runes)]++
+	}
+	for i := 1; i < len(runes); i++ {
+		grams[string(runes[i-1:i+1])]++
+	}
+	return grams
+}
+
+// bigramSimilarity returns the Dice coefficient of two bigram multisets:
+// twice the bigrams they share over their total. Two empty names are alike.
+func bigramSimilarity(a, b map[string]int) float64 {
+	total, shared := 0, 0
+	for gram, n := range a {
+		total += n
+		shared += min(n, b[gram])
+	}
+	for _, n := range b {
+		total += n
+	}
+	if total == 0 {
+		return 1
+	}
+	return float64(2*shared) / float64(total)
+}
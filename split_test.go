@@ -0,0 +1,64 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestNameSimilarity tests the Dice coefficient of name bigrams.
+func TestNameSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		min, max float64
+	}{
+		{"notes", "NOTES", 1, 1},
+		{"notes", "notes 2", 0.8, 0.9},
+		{"Obsidian Daily Notes", "Obsidian Importer plugin", 0.3, 0.5},
+		{"a", "a", 1, 1},
+		{"a", "b", 0, 0},
+		{"", "", 1, 1},
+	}
+	for _, tt := range tests {
+		if got := nameSimilarity(tt.a, tt.b); got < tt.min || got > tt.max {
+			t.Errorf("nameSimilarity(%q, %q) = %.2f, expected %.2f-%.2f", tt.a, tt.b, got, tt.min, tt.max)
+		}
+	}
+}
+
+// TestMatcher_Group_SplitSimilarity tests splitting a group that prefix
+// matching chained together into subgroups of alike names.
+func TestMatcher_Group_SplitSimilarity(t *testing.T) {
+	files := []string{
+		"/vault/Obsidian Daily Notes.md",
+		"/vault/Obsidian Importer plugin.md",
+		"/vault/Obsidian Daily Notes 2.md",
+		"/vault/Obsidian Sync.md",
+		"/vault/Obsidian Importer plugin (1).md",
+	}
+	if groups := NewMatcher(3).Group(files); len(groups) != 1 {
+		t.Fatalf("without splitting, Group() = %v, expected one group", groups)
+	}
+
+	matcher := NewMatcherWithOptions(3, MatcherOptions{SplitSimilarity: 0.6})
+	groups := matcher.Group(files)
+	expected := [][]string{
+		{files[0], files[2]},
+		{files[1], files[4]},
+	}
+	if !reflect.DeepEqual(groups, expected) {
+		t.Errorf("Group() = %v, expected %v", groups, expected)
+	}
+}
+
+// TestMatcher_SplitGroups_TooLarge tests that groups over the size limit are
+// left whole.
+func TestMatcher_SplitGroups_TooLarge(t *testing.T) {
+	group := make([]string, maxSplitGroupSize+1)
+	for i := range group {
+		group[i] = "/notes/a" + string(rune('a'+i%26))
+	}
+	m := NewMatcherWithOptions(1, MatcherOptions{SplitSimilarity: 1})
+	if got := m.splitGroups([][]string{group}); len(got) != 1 || len(got[0]) != len(group) {
+		t.Errorf("splitGroups() should leave a group of %d files alone, got %d groups", len(group), len(got))
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sqliteCommand is the SQLite command-line shell that --sqlite pipes its SQL into.
+const sqliteCommand = "sqlite3"
+
+// sqliteSchema creates the export tables. Every run adds a row to scans, and the
+// other tables refer to it by scan_id, so one database can hold scans of the
+// same directory taken over time.
+const sqliteSchema = `CREATE TABLE IF NOT EXISTS scans (
+  id INTEGER PRIMARY KEY,
+  created TEXT NOT NULL,
+  dir TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS groups (
+  scan_id INTEGER NOT NULL REFERENCES scans(id),
+  group_id INTEGER NOT NULL,
+  file_count INTEGER NOT NULL,
+  fingerprint TEXT NOT NULL DEFAULT '',
+  PRIMARY KEY (scan_id, group_id)
+);
+CREATE TABLE IF NOT EXISTS files (
+  scan_id INTEGER NOT NULL REFERENCES scans(id),
+  group_id INTEGER NOT NULL,
+  path TEXT NOT NULL,
+  size INTEGER NOT NULL,
+  mtime TEXT NOT NULL,
+  sha256 TEXT NOT NULL,
+  leader INTEGER NOT NULL,
+  identical_to_leader INTEGER NOT NULL,
+  hardlink_to_leader INTEGER NOT NULL,
+  PRIMARY KEY (scan_id, path)
+);
+CREATE INDEX IF NOT EXISTS files_sha256 ON files (sha256);
+CREATE TABLE IF NOT EXISTS scan_totals (
+  scan_id INTEGER PRIMARY KEY REFERENCES scans(id),
+  group_count INTEGER NOT NULL,
+  file_count INTEGER NOT NULL,
+  reclaimable_bytes INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS pairs (
+  scan_id INTEGER NOT NULL REFERENCES scans(id),
+  group_id INTEGER NOT NULL,
+  path1 TEXT NOT NULL,
+  path2 TEXT NOT NULL,
+  similarity REAL NOT NULL,
+  method TEXT NOT NULL
+);
+`
+
+// sqliteMigration adds the groups.fingerprint column to databases created
+// before it existed.
+const sqliteMigration = "ALTER TABLE groups ADD COLUMN fingerprint TEXT NOT NULL DEFAULT '';\n"
+
+// sqliteNeedsMigrationQuery prints 1 if the database has a groups table
+// without the fingerprint column.
+const sqliteNeedsMigrationQuery = `SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'groups')
+  AND NOT EXISTS (SELECT 1 FROM pragma_table_info('groups') WHERE name = 'fingerprint');`
+
+// writeSQLite appends a scan of dir with its records, and the similarity of
+// every pair of files within a group, to the SQLite database at path. The
+// database is created if needed. It runs the sqlite3 shell, since SQLite itself
+// is not linked into doppel.
+func writeSQLite(ctx context.Context, path, dir string, records []FileRecord) error {
+	bin, err := exec.LookPath(sqliteCommand)
+	if err != nil {
+		return fmt.Errorf("sqlite export needs the %s command: %w", sqliteCommand, err)
+	}
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	migrate := false
+	if _, err := os.Stat(path); err == nil {
+		out, err := runSQLite(ctx, bin, path, sqliteNeedsMigrationQuery)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		migrate = strings.TrimSpace(out) == "1"
+	}
+	script := sqliteScript(dir, time.Now().UTC(), records, buildPairRecords(records), migrate)
+
+	if _, err := runSQLite(ctx, bin, path, script); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// runSQLite runs the SQL in script on the database at path and returns what
+// it printed. The error holds the message of the sqlite3 shell, if any.
+func runSQLite(ctx context.Context, bin, path, script string) (string, error) {
+	cmd := logCommand(exec.CommandContext(ctx, bin, "-bail", path))
+	cmd.Stdin = strings.NewReader(script)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", errors.New(msg)
+		}
+		return "", err
+	}
+	return stdout.String(), nil
+}
+
+// sqliteScript returns the SQL that records one scan in a single transaction.
+// With migrate, it first brings a database of an earlier version up to date.
+func sqliteScript(dir string, created time.Time, records []FileRecord, pairs []PairRecord, migrate bool) string {
+	var s strings.Builder
+	s.WriteString("BEGIN IMMEDIATE;\n")
+	s.WriteString(sqliteSchema)
+	if migrate {
+		s.WriteString(sqliteMigration)
+	}
+	fmt.Fprintf(&s, "INSERT INTO scans (created, dir) VALUES (%s, %s);\n",
+		sqlQuote(created.Format(time.RFC3339)), sqlQuote(dir))
+	// The new scan is the one with the highest id while the transaction holds the lock
+	const scanID = "(SELECT max(id) FROM scans)"
+
+	for i, r := range records {
+		if i == 0 || r.Group != records[i-1].Group {
+			count := 0
+			for _, other := range records[i:] {
+				if other.Group != r.Group {
+					break
+				}
+				count++
+			}
+			fmt.Fprintf(&s, "INSERT INTO groups VALUES (%s, %d, %d, %s);\n", scanID, r.Group, count, sqlQuote(r.Fingerprint))
+		}
+		fmt.Fprintf(&s, "INSERT INTO files VALUES (%s, %d, %s, %d, %s, %s, %d, %d, %d);\n",
+			scanID, r.Group, sqlQuote(r.Path), r.Size, sqlQuote(r.ModTime.Format(time.RFC3339)),
+			sqlQuote(r.Hash), sqlBool(r.Leader), sqlBool(r.IdenticalToLeader), sqlBool(r.HardlinkToLeader))
+	}
+	summary := summarizeRecords(records)
+	fmt.Fprintf(&s, "INSERT INTO scan_totals VALUES (%s, %d, %d, %d);\n",
+		scanID, summary.Groups, summary.Files, summary.Reclaimable)
+	for _, p := range pairs {
+		fmt.Fprintf(&s, "INSERT INTO pairs VALUES (%s, %d, %s, %s, %s, %s);\n",
+			scanID, p.Group, sqlQuote(p.Path1), sqlQuote(p.Path2),
+			strconv.FormatFloat(p.Similarity, 'f', -1, 64), sqlQuote(p.Method))
+	}
+	s.WriteString("COMMIT;\n")
+	return s.String()
+}
+
+// sqlQuote returns s as an SQL string literal.
+func sqlQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// sqlBool returns the SQLite representation of b.
+func sqlBool(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSQLiteScript tests quoting and that every row refers to the new scan.
+func TestSQLiteScript(t *testing.T) {
+	records := []FileRecord{
+		{Group: 1, Path: "/notes/it's.txt", Size: 3, Hash: "aa", Leader: true, Fingerprint: "af021d6738113403"},
+		{Group: 1, Path: "/notes/it's-1.txt", Size: 3, Hash: "aa", IdenticalToLeader: true, Fingerprint: "af021d6738113403"},
+	}
+	pairs := []PairRecord{{1, records[0].Path, records[1].Path, 1, similarityIdentical}}
+	script := sqliteScript("/notes", time.Date(2024, 1, 30, 0, 0, 0, 0, time.UTC), records, pairs, false)
+
+	for _, want := range []string{
+		"BEGIN IMMEDIATE;\n",
+		"INSERT INTO scans (created, dir) VALUES ('2024-01-30T00:00:00Z', '/notes');\n",
+		"INSERT INTO groups VALUES ((SELECT max(id) FROM scans), 1, 2, 'af021d6738113403');\n",
+		"'/notes/it''s-1.txt', 3, '0001-01-01T00:00:00Z', 'aa', 0, 1, 0);\n",
+		"INSERT INTO pairs VALUES ((SELECT max(id) FROM scans), 1, '/notes/it''s.txt', '/notes/it''s-1.txt', 1, 'identical');\n",
+		"INSERT INTO scan_totals VALUES ((SELECT max(id) FROM scans), 1, 2, 3);\n",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("sqliteScript() is missing %q:\n%s", want, script)
+		}
+	}
+	if !strings.HasSuffix(script, "COMMIT;\n") {
+		t.Error("sqliteScript() should end the transaction")
+	}
+	if strings.Contains(script, "ALTER TABLE") {
+		t.Error("sqliteScript() should not migrate unless asked to")
+	}
+}
+
+// TestWriteSQLite tests that repeated exports add scans to the same database.
+func TestWriteSQLite(t *testing.T) {
+	if _, err := exec.LookPath(sqliteCommand); err != nil {
+		t.Skipf("%s not installed", sqliteCommand)
+	}
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	file1 := createFileWithContent(t, tmpDir, "notes.txt", "a\nb\n")
+	file2 := createFileWithContent(t, tmpDir, "notes-1.txt", "a\nc\n")
+	records, err := buildFileRecords(context.Background(), [][]string{{file1, file2}}, nil, nil)
+	if err != nil {
+		t.Fatalf("buildFileRecords() returned error: %v", err)
+	}
+
+	db := filepath.Join(tmpDir, "out.db")
+	for range 2 {
+		if err := writeSQLite(context.Background(), db, tmpDir, records); err != nil {
+			t.Fatalf("writeSQLite() returned error: %v", err)
+		}
+	}
+
+	out, err := exec.Command(sqliteCommand, db,
+		"SELECT count(*) FROM scans; SELECT count(*) FROM files; SELECT similarity, method FROM pairs WHERE scan_id = 2;").Output()
+	if err != nil {
+		t.Fatalf("querying the database failed: %v", err)
+	}
+	if got, expected := string(out), "2\n4\n0.5|lines\n"; got != expected {
+		t.Errorf("query output = %q, expected %q", got, expected)
+	}
+}
+
+// TestWriteSQLite_Migration tests adding the fingerprint column to a database
+// created before it existed.
+func TestWriteSQLite_Migration(t *testing.T) {
+	if _, err := exec.LookPath(sqliteCommand); err != nil {
+		t.Skipf("%s not installed", sqliteCommand)
+	}
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	db := filepath.Join(tmpDir, "out.db")
+	old := "CREATE TABLE scans (id INTEGER PRIMARY KEY, created TEXT NOT NULL, dir TEXT NOT NULL);" +
+		"CREATE TABLE groups (scan_id INTEGER NOT NULL, group_id INTEGER NOT NULL, file_count INTEGER NOT NULL, PRIMARY KEY (scan_id, group_id));" +
+		"INSERT INTO scans VALUES (1, '2024-01-30T00:00:00Z', '/notes');" +
+		"INSERT INTO groups VALUES (1, 1, 2);"
+	if out, err := exec.Command(sqliteCommand, db, old).CombinedOutput(); err != nil {
+		t.Fatalf("creating the old database failed: %v: %s", err, out)
+	}
+
+	file1 := createFileWithContent(t, tmpDir, "notes.txt", "a\n")
+	file2 := createFileWithContent(t, tmpDir, "notes-1.txt", "a\n")
+	records, err := buildFileRecords(context.Background(), [][]string{{file1, file2}}, nil, nil)
+	if err != nil {
+		t.Fatalf("buildFileRecords() returned error: %v", err)
+	}
+	setFingerprints(tmpDir, records)
+	for range 2 {
+		if err := writeSQLite(context.Background(), db, tmpDir, records); err != nil {
+			t.Fatalf("writeSQLite() returned error: %v", err)
+		}
+	}
+
+	out, err := exec.Command(sqliteCommand, db, "SELECT scan_id, fingerprint FROM groups ORDER BY scan_id;").Output()
+	if err != nil {
+		t.Fatalf("querying the database failed: %v", err)
+	}
+	fp := records[0].Fingerprint
+	if got, expected := string(out), "1|\n2|"+fp+"\n3|"+fp+"\n"; got != expected {
+		t.Errorf("query output = %q, expected %q", got, expected)
+	}
+}
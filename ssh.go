@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sshCommand is the program that runs commands on SSH hosts.
+var sshCommand = "ssh"
+
+// sshStorage lists and downloads the files in a directory of an SSH host by
+// running find and cat through ssh, so the host, port, keys, and agent of
+// ~/.ssh/config apply. It doesn't speak SFTP: the account needs a shell, and
+// find must be GNU find for its -printf.
+type sshStorage struct {
+	objectIndex
+	// host is the user@host of the location and port its port, if given.
+	host string
+	port string
+	dir  string
+}
+
+// newSSHStorage returns the storage of an ssh://[user@]host[:port]/path URL.
+// The path is absolute; ssh://host/~/path is relative to the home directory.
+func newSSHStorage(u *url.URL) (*sshStorage, error) {
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid location %s: missing host", u)
+	}
+	s := &sshStorage{host: u.Hostname(), port: u.Port(), dir: u.Path}
+	if u.User != nil {
+		s.host = u.User.Username() + "@" + s.host
+	}
+	if s.dir == "" {
+		s.dir = "/"
+	}
+	return s, nil
+}
+
+// command returns a command running the shell command line remote on the host.
+// The host follows --, so a host starting with - isn't taken for an option.
+func (s *sshStorage) command(ctx context.Context, remote string) *exec.Cmd {
+	var args []string
+	if s.port != "" {
+		args = append(args, "-p", s.port)
+	}
+	args = append(args, "--", s.host, remote)
+	return logCommand(exec.CommandContext(ctx, sshCommand, args...))
+}
+
+// remotePath quotes the path p of a file of the host for its shell, leaving
+// a leading ~/ unquoted so it expands to the home directory.
+func remotePath(p string) string {
+	if rest, ok := strings.CutPrefix(p, "/~/"); ok {
+		return "~/" + shellQuote(rest)
+	}
+	return shellQuote(p)
+}
+
+// shellQuote quotes s as a single word for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// List returns the files in the directory, and with recursive those in its
+// subdirectories too.
+func (s *sshStorage) List(ctx context.Context, recursive bool) ([]StoredObject, error) {
+	remote := "cd " + remotePath(s.dir) + " && find . "
+	if !recursive {
+		remote += "-maxdepth 1 "
+	}
+	remote += `-type f -printf '%s %T@ %P\0'`
+	cmd := s.command(ctx, remote)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("failed to list %s: %s", s.name(""), msg)
+		}
+		return nil, fmt.Errorf("failed to list %s: %w", s.name(""), err)
+	}
+
+	objects, err := parseFindOutput(out, s.name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", s.name(""), err)
+	}
+	s.remember(objects)
+	return objects, nil
+}
+
+// parseFindOutput parses the NUL-terminated "size mtime path" entries that
+// find -printf writes, naming each file by the URL name returns for its path.
+func parseFindOutput(out []byte, name func(string) string) ([]StoredObject, error) {
+	var objects []StoredObject
+	for _, entry := range strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00") {
+		if entry == "" {
+			continue
+		}
+		fields := strings.SplitN(entry, " ", 3)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("unexpected find output %q", entry)
+		}
+		size, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected find output %q", entry)
+		}
+		seconds, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected find output %q", entry)
+		}
+		modTime := time.Unix(0, int64(seconds*float64(time.Second)))
+		objects = append(objects, StoredObject{Name: name(fields[2]), Size: size, ModTime: modTime})
+	}
+	return objects, nil
+}
+
+// name returns the URL of the file at rel, a path relative to the directory.
+func (s *sshStorage) name(rel string) string {
+	host := s.host
+	if s.port != "" {
+		host += ":" + s.port
+	}
+	return "ssh://" + host + strings.TrimSuffix(path.Join(s.dir, rel), "/")
+}
+
+// Open streams the file named by the URL name with cat.
+func (s *sshStorage) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	rel, ok := strings.CutPrefix(name, s.name("")+"/")
+	if !ok {
+		return nil, fmt.Errorf("%s is not in %s", name, s.name(""))
+	}
+	cmd := s.command(ctx, "cat -- "+remotePath(path.Join(s.dir, rel)))
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	r := &commandReader{cmd: cmd, stdout: stdout}
+	cmd.Stderr = &r.stderr
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// commandReader reads the output of a started command. Reaching the end
+// fails if the command did, so a failed download isn't taken for a short
+// file.
+type commandReader struct {
+	cmd    *exec.Cmd
+	stdout io.ReadCloser
+	stderr bytes.Buffer
+	done   bool
+}
+
+// Read reads the output, waiting for the command at the end of it.
+func (c *commandReader) Read(p []byte) (int, error) {
+	n, err := c.stdout.Read(p)
+	if err == io.EOF && !c.done {
+		c.done = true
+		if werr := c.cmd.Wait(); werr != nil {
+			if msg := strings.TrimSpace(c.stderr.String()); msg != "" {
+				return n, errors.New(msg)
+			}
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+// Close stops the command if its output wasn't read to the end.
+func (c *commandReader) Close() error {
+	if c.done {
+		return nil
+	}
+	c.done = true
+	c.stdout.Close()
+	c.cmd.Process.Kill()
+	c.cmd.Wait()
+	return nil
+}
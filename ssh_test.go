@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSSH points sshCommand at a script that runs the remote command line
+// locally, ignoring the host, and returns the storage of dir on "host".
+func fakeSSH(t *testing.T, dir string) *sshStorage {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+	if out, err := exec.Command("find", ".", "-maxdepth", "0", "-printf", "%P").CombinedOutput(); err != nil {
+		t.Skipf("needs GNU find: %v %s", err, out)
+	}
+	script := filepath.Join(t.TempDir(), "ssh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\nwhile [ \"$1\" != -- ]; do shift; done\nshift 2\nexec sh -c \"$1\"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	old := sshCommand
+	sshCommand = script
+	t.Cleanup(func() { sshCommand = old })
+
+	s, err := newSSHStorage(&url.URL{Scheme: "ssh", Host: "host", Path: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// TestSSHStorage tests listing and downloading files through ssh.
+func TestSSHStorage(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	createFileWithContent(t, tmpDir, "notes.md", "one")
+	createFileWithContent(t, tmpDir, "it's (1).md", "quoted")
+	if err := os.Mkdir(filepath.Join(tmpDir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	createFileWithContent(t, filepath.Join(tmpDir, "sub"), "todo.md", "todo")
+	s := fakeSSH(t, tmpDir)
+
+	listed, err := s.List(context.Background(), false)
+	if err != nil {
+		t.Fatalf("List() returned error: %v", err)
+	}
+	var names []string
+	for _, obj := range listed {
+		names = append(names, obj.Name)
+	}
+	want := []string{"ssh://host" + tmpDir + "/it's (1).md", "ssh://host" + tmpDir + "/notes.md"}
+	if slices.Sort(names); !reflect.DeepEqual(names, want) {
+		t.Errorf("List() = %v, expected %v", names, want)
+	}
+	info, err := s.Stat("ssh://host" + tmpDir + "/notes.md")
+	if err != nil || info.Size() != 3 || time.Since(info.ModTime()) > time.Hour {
+		t.Errorf("Stat() = %v, %v; expected the size and time of the file", info, err)
+	}
+
+	if recursive, err := s.List(context.Background(), true); err != nil || len(recursive) != 3 {
+		t.Errorf("recursive List() = %+v, %v; expected 3 files", recursive, err)
+	}
+
+	r, err := s.Open(context.Background(), want[0])
+	if err != nil {
+		t.Fatalf("Open() returned error: %v", err)
+	}
+	data, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || string(data) != "quoted" {
+		t.Errorf("Open() read %q, %v", data, err)
+	}
+
+	r, err = s.Open(context.Background(), "ssh://host"+tmpDir+"/gone.md")
+	if err != nil {
+		t.Fatalf("Open() returned error: %v", err)
+	}
+	if _, err := io.ReadAll(r); err == nil {
+		t.Errorf("reading a missing file should fail")
+	}
+	r.Close()
+}
+
+// TestParseFindOutput tests parsing the entries that find -printf writes.
+func TestParseFindOutput(t *testing.T) {
+	name := func(rel string) string { return "ssh://host/data/" + rel }
+	objects, err := parseFindOutput([]byte("3 1706608800.5000000000 notes.md\x0012 1706608801.0000000000 a b/c d.md\x00"), name)
+	if err != nil {
+		t.Fatalf("parseFindOutput() returned error: %v", err)
+	}
+	want := []StoredObject{
+		{Name: "ssh://host/data/notes.md", Size: 3, ModTime: time.Unix(1706608800, 5e8)},
+		{Name: "ssh://host/data/a b/c d.md", Size: 12, ModTime: time.Unix(1706608801, 0)},
+	}
+	if len(objects) != 2 || objects[0].Name != want[0].Name || objects[1].Name != want[1].Name ||
+		objects[1].Size != 12 || objects[0].ModTime.Sub(want[0].ModTime).Abs() > time.Millisecond {
+		t.Errorf("parseFindOutput() = %+v, expected %+v", objects, want)
+	}
+
+	if _, err := parseFindOutput([]byte("garbage\x00"), name); err == nil {
+		t.Errorf("parseFindOutput() should reject unexpected output")
+	}
+}
+
+// TestSSHStorage_Names tests the URLs of files for user, port, and home paths.
+func TestSSHStorage_Names(t *testing.T) {
+	u, _ := url.Parse("ssh://me@host:2222/~/sync")
+	s, err := newSSHStorage(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.name("notes.md"); got != "ssh://me@host:2222/~/sync/notes.md" {
+		t.Errorf("name() = %q", got)
+	}
+	if got := remotePath("/~/sync/it's"); got != `~/'sync/it'\''s'` {
+		t.Errorf("remotePath() = %q", got)
+	}
+	cmd := s.command(context.Background(), "true")
+	if args := strings.Join(cmd.Args[1:], " "); args != "-p 2222 -- me@host true" {
+		t.Errorf("ssh arguments = %q", args)
+	}
+
+	// SSH hosts are read through a shell, so sftp:// isn't taken for them
+	if _, err := OpenStorage("sftp://host/srv/sync"); err == nil || !strings.Contains(err.Error(), "use ssh://") {
+		t.Errorf("OpenStorage() = %v for an sftp:// location, expected a pointer to ssh://", err)
+	}
+}
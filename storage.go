@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Storage is a remote location that files are scanned from instead of a local
+// directory, such as an S3 bucket or a directory on an SSH server. Its files
+// are named by URL, like s3://bucket/photos/IMG_0001.jpg, and read by
+// streaming them.
+type Storage interface {
+	// List returns the files at the location, and with recursive those under
+	// it too.
+	List(ctx context.Context, recursive bool) ([]StoredObject, error)
+	// Stat returns the size and modification time of a file found by List.
+	Stat(name string) (os.FileInfo, error)
+	// Open streams the content of a file found by List.
+	Open(ctx context.Context, name string) (io.ReadCloser, error)
+}
+
+// StoredObject is a file of a Storage.
+type StoredObject struct {
+	// Name is the URL of the file.
+	Name    string
+	Size    int64
+	ModTime time.Time
+}
+
+// objectInfo is the os.FileInfo of a stored file, so the scanner's filters
+// apply to it like to a local file.
+type objectInfo struct {
+	obj StoredObject
+}
+
+func (i objectInfo) Name() string       { return path.Base(i.obj.Name) }
+func (i objectInfo) Size() int64        { return i.obj.Size }
+func (i objectInfo) Mode() os.FileMode  { return 0o444 }
+func (i objectInfo) ModTime() time.Time { return i.obj.ModTime }
+func (i objectInfo) IsDir() bool        { return false }
+func (i objectInfo) Sys() any           { return nil }
+
+// objectIndex remembers the files a Storage listed, to stat them later
+// without another request.
+type objectIndex struct {
+	mu      sync.Mutex
+	objects map[string]StoredObject
+}
+
+// remember adds listed files to the index.
+func (x *objectIndex) remember(objects []StoredObject) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.objects == nil {
+		x.objects = make(map[string]StoredObject)
+	}
+	for _, obj := range objects {
+		x.objects[obj.Name] = obj
+	}
+}
+
+// Stat returns the info of a listed file, or an error wrapping
+// os.ErrNotExist for any other name.
+func (x *objectIndex) Stat(name string) (os.FileInfo, error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	obj, ok := x.objects[name]
+	if !ok {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+	return objectInfo{obj}, nil
+}
+
+// isRemoteLocation reports whether dir is the URL of a Storage rather than a
+// local directory.
+func isRemoteLocation(dir string) bool {
+	return strings.HasPrefix(dir, "s3://") || strings.HasPrefix(dir, "sftp://") || strings.HasPrefix(dir, "ssh://")
+}
+
+// OpenStorage returns the Storage for a location such as s3://bucket/prefix
+// or ssh://user@host/path.
+func OpenStorage(location string) (Storage, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("invalid location %s: %w", location, err)
+	}
+	switch u.Scheme {
+	case "s3":
+		return newS3Storage(u)
+	case "ssh":
+		return newSSHStorage(u)
+	case "sftp":
+		return nil, fmt.Errorf("unsupported location %s: doppel doesn't speak SFTP; use ssh://, which needs a shell and GNU find on the host", location)
+	}
+	return nil, fmt.Errorf("unsupported location %s", location)
+}
+
+// relativeName returns the part of the URL name after the location dir, with
+// forward slashes.
+func relativeName(dir, name string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(name, strings.TrimSuffix(dir, "/")), "/")
+}
+
+// list is the ScanContext of a scanner of a Storage. Files in hidden
+// directories and the .doppel state directory are left out like in local
+// scans, and the filters apply to the size and time the listing reports.
+func (s *Scanner) list(ctx context.Context) ([]string, error) {
+	objects, err := s.storage.List(ctx, s.recursive)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, obj := range objects {
+		parts := strings.Split(relativeName(s.dir, obj.Name), "/")
+		if slices.Contains(parts[:len(parts)-1], stateDirName) {
+			continue
+		}
+		if !s.includeHidden && slices.ContainsFunc(parts, isHidden) {
+			logger.Debug("skipped hidden file", "path", obj.Name)
+			continue
+		}
+		if slices.ContainsFunc(parts, s.excluded) {
+			logger.Debug("skipped file", "path", obj.Name, "reason", "matches --exclude")
+			continue
+		}
+		skip, err := s.skipReason(obj.Name, func() (os.FileInfo, error) { return objectInfo{obj}, nil })
+		if err != nil {
+			return nil, err
+		}
+		if skip != "" {
+			logger.Debug("skipped file", "path", obj.Name, "reason", skip)
+			continue
+		}
+		files = append(files, obj.Name)
+		s.progress.AddFiles(1)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// hashStored returns the hex-encoded SHA-256 of a stored file like hashFile,
+// streaming it from storage.
+func hashStored(ctx context.Context, storage Storage, name string, progress *Progress) (string, error) {
+	r, err := storage.Open(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, contextReader{ctx, r})
+	progress.AddBytes(n)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// buildStoredRecords is buildFileRecords for the files of storage. Sizes and
+// modification times come from the listing, and every file is downloaded to
+// hash it, since stored files can be neither cached nor hard linked.
+func buildStoredRecords(ctx context.Context, storage Storage, groups [][]string, progress *Progress) ([]FileRecord, error) {
+	progress.SetPhase("Downloading")
+
+	var records []FileRecord
+	for i, group := range groups {
+		var leaderHash string
+		for j, file := range group {
+			info, err := storage.Stat(file)
+			if err != nil {
+				return nil, err
+			}
+			hash, err := hashStored(ctx, storage, file, progress)
+			if err != nil {
+				return nil, err
+			}
+			if j == 0 {
+				leaderHash = hash
+			}
+			records = append(records, FileRecord{
+				Group:             i + 1,
+				Path:              file,
+				Size:              info.Size(),
+				ModTime:           info.ModTime(),
+				Hash:              hash,
+				Leader:            j == 0,
+				IdenticalToLeader: j > 0 && hash == leaderHash,
+			})
+		}
+	}
+	return records, nil
+}
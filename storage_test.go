@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// memoryStorage is a Storage of files held in memory, by URL.
+type memoryStorage struct {
+	objectIndex
+	files map[string]string
+	// nested lists the names in subdirectories, which need recursive.
+	nested map[string]bool
+}
+
+func (m *memoryStorage) List(ctx context.Context, recursive bool) ([]StoredObject, error) {
+	var objects []StoredObject
+	for name, content := range m.files {
+		if recursive || !m.nested[name] {
+			objects = append(objects, StoredObject{Name: name, Size: int64(len(content)), ModTime: time.Date(2024, 1, 30, 0, 0, 0, 0, time.UTC)})
+		}
+	}
+	m.remember(objects)
+	return objects, nil
+}
+
+func (m *memoryStorage) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	content, ok := m.files[name]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return io.NopCloser(strings.NewReader(content)), nil
+}
+
+// TestScanner_Storage tests scanning a storage instead of a local directory.
+func TestScanner_Storage(t *testing.T) {
+	storage := &memoryStorage{
+		files: map[string]string{
+			"s3://bucket/sync/notes.md":          "one",
+			"s3://bucket/sync/notes (1).md":      "one",
+			"s3://bucket/sync/photo.jpg":         "photo data",
+			"s3://bucket/sync/.hidden/notes.md":  "two",
+			"s3://bucket/sync/.doppel/state.md":  "{}",
+			"s3://bucket/sync/archive/notes.md":  "three",
+			"s3://bucket/sync/archive/Thumbs.db": "junk",
+		},
+		nested: map[string]bool{
+			"s3://bucket/sync/.hidden/notes.md":  true,
+			"s3://bucket/sync/.doppel/state.md":  true,
+			"s3://bucket/sync/archive/notes.md":  true,
+			"s3://bucket/sync/archive/Thumbs.db": true,
+		},
+	}
+	scanner := NewScanner("s3://bucket/sync")
+	scanner.SetStorage(storage)
+
+	files, err := scanner.Scan()
+	if err != nil {
+		t.Fatalf("Scan() returned error: %v", err)
+	}
+	want := []string{"s3://bucket/sync/notes (1).md", "s3://bucket/sync/notes.md", "s3://bucket/sync/photo.jpg"}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("Scan() = %v, expected %v", files, want)
+	}
+
+	scanner.SetRecursive(true, 1)
+	scanner.SetExtensions([]string{"md"})
+	scanner.SetSizeLimits(0, 3)
+	files, err = scanner.Scan()
+	if err != nil {
+		t.Fatalf("Scan() returned error: %v", err)
+	}
+	want = []string{"s3://bucket/sync/notes (1).md", "s3://bucket/sync/notes.md"}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("recursive Scan() with filters = %v, expected %v", files, want)
+	}
+}
+
+// TestBuildStoredRecords tests reporting stored files by streaming them.
+func TestBuildStoredRecords(t *testing.T) {
+	storage := &memoryStorage{files: map[string]string{
+		"s3://bucket/notes.md":     "one",
+		"s3://bucket/notes (1).md": "one",
+		"s3://bucket/notes (2).md": "two",
+	}}
+	if _, err := storage.List(context.Background(), false); err != nil {
+		t.Fatal(err)
+	}
+	group := []string{"s3://bucket/notes.md", "s3://bucket/notes (1).md", "s3://bucket/notes (2).md"}
+
+	records, err := buildStoredRecords(context.Background(), storage, [][]string{group}, nil)
+	if err != nil {
+		t.Fatalf("buildStoredRecords() returned error: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records, got %d", len(records))
+	}
+	if !records[0].Leader || !records[1].IdenticalToLeader || records[2].IdenticalToLeader {
+		t.Errorf("unexpected records: %+v", records)
+	}
+	if records[0].Size != 3 || records[0].ModTime.Year() != 2024 {
+		t.Errorf("size and time should come from the listing: %+v", records[0])
+	}
+
+	if _, err := buildStoredRecords(context.Background(), storage, [][]string{{"s3://bucket/gone.md"}}, nil); !os.IsNotExist(err) {
+		t.Errorf("a file that wasn't listed should not exist, got %v", err)
+	}
+}
+
+// TestRelativeName tests the path of a stored file within the scanned location.
+func TestRelativeName(t *testing.T) {
+	for _, tt := range []struct{ dir, name, want string }{
+		{"s3://bucket/sync", "s3://bucket/sync/a/b.md", "a/b.md"},
+		{"s3://bucket/sync/", "s3://bucket/sync/b.md", "b.md"},
+		{"s3://bucket", "s3://bucket/b.md", "b.md"},
+	} {
+		if got := relativeName(tt.dir, tt.name); got != tt.want {
+			t.Errorf("relativeName(%q, %q) = %q, expected %q", tt.dir, tt.name, got, tt.want)
+		}
+	}
+}
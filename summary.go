@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// groupSummary totals the groups of a run: how many there are, how many files
+// they hold, and how many bytes removing redundant copies could free.
+type groupSummary struct {
+	Groups int
+	Files  int
+	// Reclaimable is the size of every file that has the same content as an
+	// earlier file of its group without already sharing its storage.
+	Reclaimable int64
+}
+
+// String returns a line such as "42 groups, 113 files, up to 1.8 GB reclaimable".
+// Reclaimable space is an upper bound, since groups are matched by name and
+// only identical copies are worth removing.
+func (s groupSummary) String() string {
+	return fmt.Sprintf("%s, %s, up to %s reclaimable",
+		plural(s.Groups, "group"), plural(s.Files, "file"), formatBytes(s.Reclaimable))
+}
+
+// plural returns n followed by noun, with an "s" unless n is 1.
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// summarizeRecords totals report records using their hashes: within a group, a
+// file with the same hash as an earlier file counts as a redundant copy unless
+// it is a hard link to the leader.
+func summarizeRecords(records []FileRecord) groupSummary {
+	var s groupSummary
+	var seen map[string]bool
+	for i, r := range records {
+		if i == 0 || r.Group != records[i-1].Group {
+			s.Groups++
+			seen = make(map[string]bool)
+		}
+		s.Files++
+		if seen[r.Hash] && !r.HardlinkToLeader {
+			s.Reclaimable += r.Size
+		}
+		seen[r.Hash] = true
+	}
+	return s
+}
+
+// summarizeGroups totals groups without reading file contents, for the TUI,
+// which does not hash every file: within a group, a file counts as a redundant
+// copy if an earlier file has the same size and none is a hard link to it. This
+// overestimates reclaimable space when same-sized files differ.
+func summarizeGroups(groups [][]string) groupSummary {
+	s := groupSummary{Groups: len(groups)}
+	for _, group := range groups {
+		s.Files += len(group)
+		var earlier []os.FileInfo
+		for _, file := range group {
+			info, err := os.Stat(file)
+			if err != nil {
+				continue
+			}
+			linked, sameSize := false, false
+			for _, e := range earlier {
+				linked = linked || os.SameFile(e, info)
+				sameSize = sameSize || e.Size() == info.Size()
+			}
+			if sameSize && !linked {
+				s.Reclaimable += info.Size()
+			}
+			earlier = append(earlier, info)
+		}
+	}
+	return s
+}
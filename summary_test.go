@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestGroupSummary_String tests the summary line.
+func TestGroupSummary_String(t *testing.T) {
+	tests := []struct {
+		summary  groupSummary
+		expected string
+	}{
+		{groupSummary{Groups: 42, Files: 113, Reclaimable: 1932735283}, "42 groups, 113 files, up to 1.8 GB reclaimable"},
+		{groupSummary{Groups: 1, Files: 2}, "1 group, 2 files, up to 0 B reclaimable"},
+	}
+	for _, tt := range tests {
+		if got := tt.summary.String(); got != tt.expected {
+			t.Errorf("String() = %q, expected %q", got, tt.expected)
+		}
+	}
+}
+
+// TestSummarizeRecords tests that only repeated content counts as reclaimable,
+// and hard links to the leader don't.
+func TestSummarizeRecords(t *testing.T) {
+	records := []FileRecord{
+		{Group: 1, Path: "a.txt", Size: 100, Hash: "aa", Leader: true},
+		{Group: 1, Path: "a-1.txt", Size: 100, Hash: "aa", IdenticalToLeader: true},
+		{Group: 1, Path: "a-2.txt", Size: 50, Hash: "bb"},
+		{Group: 1, Path: "a-3.txt", Size: 50, Hash: "bb"},
+		{Group: 2, Path: "b.txt", Size: 10, Hash: "cc", Leader: true},
+		{Group: 2, Path: "b-1.txt", Size: 10, Hash: "cc", IdenticalToLeader: true, HardlinkToLeader: true},
+		{Group: 3, Path: "c.txt", Size: 7, Hash: "aa", Leader: true},
+		{Group: 3, Path: "c-1.txt", Size: 8, Hash: "dd"},
+	}
+
+	got := summarizeRecords(records)
+	expected := groupSummary{Groups: 3, Files: 8, Reclaimable: 150}
+	if got != expected {
+		t.Errorf("summarizeRecords() = %+v, expected %+v", got, expected)
+	}
+}
+
+// TestSummarizeGroups tests the size-based estimate used by the TUI.
+func TestSummarizeGroups(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	original := createFileWithContent(t, tmpDir, "photo.jpg", "12345")
+	dup := createFileWithContent(t, tmpDir, "photo-1.jpg", "12345")
+	link := filepath.Join(tmpDir, "photo-2.jpg")
+	if err := os.Link(original, link); err != nil {
+		t.Skipf("hard links not supported: %v", err)
+	}
+	other := createFileWithContent(t, tmpDir, "photo-3.jpg", "123")
+
+	got := summarizeGroups([][]string{{original, dup, link, other}})
+	expected := groupSummary{Groups: 1, Files: 4, Reclaimable: 5}
+	if got != expected {
+		t.Errorf("summarizeGroups() = %+v, expected %+v", got, expected)
+	}
+}
+
+// TestWriteTextReport_Summary tests that the text report ends with the totals.
+func TestWriteTextReport_Summary(t *testing.T) {
+	records := []FileRecord{
+		{Group: 1, Path: "a.txt", Size: 2048, Hash: "aaaaaaaaaaaaaaaa", Leader: true},
+		{Group: 1, Path: "a-1.txt", Size: 2048, Hash: "aaaaaaaaaaaaaaaa", IdenticalToLeader: true},
+	}
+	var out bytes.Buffer
+	if err := writeTextReport(&out, records); err != nil {
+		t.Fatalf("writeTextReport() returned error: %v", err)
+	}
+	if !strings.HasSuffix(out.String(), "\nTotal: 1 group, 2 files, up to 2.0 KB reclaimable\n") {
+		t.Errorf("writeTextReport() should end with the summary:\n%s", out.String())
+	}
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The highlighter is built in rather than chroma, which would pull a large
+// dependency into an optional view; it tokenizes each line on its own.
+
+// syntaxLang describes how to color the code of one language: its keywords,
+// the markers that start a comment running to the end of the line, and the
+// characters that quote strings. Markdown is colored by line instead: headings,
+// code fences, and inline code.
+type syntaxLang struct {
+	keywords map[string]bool
+	comments []string
+	quotes   string
+	markdown bool
+}
+
+// newSyntaxLang builds a syntaxLang from a space-separated list of keywords.
+func newSyntaxLang(keywords string, comments []string, quotes string) *syntaxLang {
+	l := &syntaxLang{keywords: make(map[string]bool), comments: comments, quotes: quotes}
+	for _, keyword := range strings.Fields(keywords) {
+		l.keywords[keyword] = true
+	}
+	return l
+}
+
+var (
+	slashComments = []string{"//"}
+	hashComments  = []string{"#"}
+
+	goSyntax = newSyntaxLang("break case chan const continue default defer else fallthrough for func go goto if import interface map package range return select struct switch type var nil true false iota",
+		slashComments, "\"'`")
+	pythonSyntax = newSyntaxLang("and as assert async await break class continue def del elif else except finally for from global if import in is lambda nonlocal not or pass raise return try while with yield None True False self",
+		hashComments, "\"'")
+	jsSyntax = newSyntaxLang("async await break case catch class const continue debugger default delete do else export extends finally for function if import in instanceof let new of return static super switch this throw try typeof var void while yield null undefined true false interface type enum implements",
+		slashComments, "\"'`")
+	jsonSyntax  = newSyntaxLang("true false null", nil, "\"")
+	shellSyntax = newSyntaxLang("if then else elif fi for while until do done case esac in function return local export readonly",
+		hashComments, "\"'")
+	yamlSyntax = newSyntaxLang("true false null yes no", hashComments, "\"'")
+	rustSyntax = newSyntaxLang("as async await break const continue crate else enum extern false fn for if impl in let loop match mod move mut pub ref return self Self static struct super trait true type unsafe use where while",
+		slashComments, "\"")
+	cSyntax = newSyntaxLang("auto break case char class const continue default do double else enum extern final float for if import int long new package private protected public return short sizeof static struct switch this throw throws try typedef union unsigned void volatile while null true false",
+		slashComments, "\"'")
+	markdownSyntax = &syntaxLang{markdown: true}
+)
+
+// syntaxLangs maps lower-case file extensions to the language they hold.
+var syntaxLangs = map[string]*syntaxLang{
+	".go":       goSyntax,
+	".py":       pythonSyntax,
+	".js":       jsSyntax,
+	".jsx":      jsSyntax,
+	".mjs":      jsSyntax,
+	".ts":       jsSyntax,
+	".tsx":      jsSyntax,
+	".json":     jsonSyntax,
+	".sh":       shellSyntax,
+	".bash":     shellSyntax,
+	".zsh":      shellSyntax,
+	".yaml":     yamlSyntax,
+	".yml":      yamlSyntax,
+	".toml":     yamlSyntax,
+	".rs":       rustSyntax,
+	".c":        cSyntax,
+	".h":        cSyntax,
+	".cpp":      cSyntax,
+	".hpp":      cSyntax,
+	".java":     cSyntax,
+	".cs":       cSyntax,
+	".md":       markdownSyntax,
+	".markdown": markdownSyntax,
+}
+
+// syntaxFor returns the language of file by its extension, or nil if it is not
+// one that is colored.
+func syntaxFor(file string) *syntaxLang {
+	return syntaxLangs[strings.ToLower(filepath.Ext(file))]
+}
+
+// tokenKind is what a piece of a line of code is, which decides its color.
+type tokenKind int
+
+const (
+	tokenPlain tokenKind = iota
+	tokenKeyword
+	tokenString
+	tokenComment
+	tokenNumber
+)
+
+// syntaxToken is a piece of a line of code.
+type syntaxToken struct {
+	kind tokenKind
+	text string
+}
+
+// renderTokens renders tokens with syntax colors. Plain text keeps the diff color.
+func renderTokens(tokens []syntaxToken) string {
+	var s strings.Builder
+	for _, token := range tokens {
+		style := diffStyle
+		switch token.kind {
+		case tokenKeyword:
+			style = keywordStyle
+		case tokenString:
+			style = stringStyle
+		case tokenComment:
+			style = commentStyle
+		case tokenNumber:
+			style = numberStyle
+		}
+		s.WriteString(style.Render(token.text))
+	}
+	return s.String()
+}
+
+// skipTokenColumns drops the first n characters of tokens.
+func skipTokenColumns(tokens []syntaxToken, n int) []syntaxToken {
+	for len(tokens) > 0 && n > 0 {
+		length := utf8.RuneCountInString(tokens[0].text)
+		if length > n {
+			tokens[0].text = string([]rune(tokens[0].text)[n:])
+			break
+		}
+		n -= length
+		tokens = tokens[1:]
+	}
+	return tokens
+}
+
+// tokenize splits a line of code into tokens; plain text between the others is
+// kept in single tokens. Strings and comments are not followed across lines.
+func (l *syntaxLang) tokenize(line string) []syntaxToken {
+	if l.markdown {
+		return tokenizeMarkdown(line)
+	}
+
+	var tokens []syntaxToken
+	plain := 0
+	add := func(kind tokenKind, start, end int) {
+		if start > plain {
+			tokens = append(tokens, syntaxToken{tokenPlain, line[plain:start]})
+		}
+		tokens = append(tokens, syntaxToken{kind, line[start:end]})
+		plain = end
+	}
+	for i := 0; i < len(line); {
+		r, size := utf8.DecodeRuneInString(line[i:])
+		switch {
+		case l.startsComment(line[i:]):
+			add(tokenComment, i, len(line))
+			return tokens
+		case strings.ContainsRune(l.quotes, r):
+			add(tokenString, i, quotedEnd(line, i, r))
+		case unicode.IsDigit(r) && (i == 0 || !isWordByte(line[i-1])):
+			add(tokenNumber, i, wordEnd(line, i))
+		case isWordByte(line[i]) && (i == 0 || !isWordByte(line[i-1])):
+			end := wordEnd(line, i)
+			if l.keywords[line[i:end]] {
+				add(tokenKeyword, i, end)
+			}
+			i = end
+			continue
+		default:
+			i += size
+			continue
+		}
+		i = plain
+	}
+	if plain < len(line) {
+		tokens = append(tokens, syntaxToken{tokenPlain, line[plain:]})
+	}
+	return tokens
+}
+
+// startsComment reports whether text begins with one of the language's comment markers.
+func (l *syntaxLang) startsComment(text string) bool {
+	for _, marker := range l.comments {
+		if strings.HasPrefix(text, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// quotedEnd returns the index just past the string that starts with quote at
+// start, or the end of the line if it is not closed there.
+func quotedEnd(line string, start int, quote rune) int {
+	for i := start + 1; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && quote != '`':
+			i++
+		case rune(line[i]) == quote:
+			return i + 1
+		}
+	}
+	return len(line)
+}
+
+// wordEnd returns the index just past the identifier or number starting at start.
+func wordEnd(line string, start int) int {
+	end := start
+	for end < len(line) && (isWordByte(line[end]) || line[end] == '.' && unicode.IsDigit(rune(line[start]))) {
+		end++
+	}
+	return end
+}
+
+// isWordByte reports whether b can be part of an identifier.
+func isWordByte(b byte) bool {
+	return b == '_' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9'
+}
+
+// tokenizeMarkdown marks headings as keywords and code fences as comments, and
+// inline code within other lines as strings.
+func tokenizeMarkdown(line string) []syntaxToken {
+	trimmed := strings.TrimSpace(line)
+	switch {
+	case strings.HasPrefix(trimmed, "#"):
+		return []syntaxToken{{tokenKeyword, line}}
+	case strings.HasPrefix(trimmed, "```"), strings.HasPrefix(trimmed, "~~~"):
+		return []syntaxToken{{tokenComment, line}}
+	}
+
+	var tokens []syntaxToken
+	for {
+		start := strings.IndexByte(line, '`')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(line[start+1:], '`')
+		if end < 0 {
+			break
+		}
+		end += start + 2
+		if start > 0 {
+			tokens = append(tokens, syntaxToken{tokenPlain, line[:start]})
+		}
+		tokens = append(tokens, syntaxToken{tokenString, line[start:end]})
+		line = line[end:]
+	}
+	if line != "" {
+		tokens = append(tokens, syntaxToken{tokenPlain, line})
+	}
+	return tokens
+}
+
+// highlightDiffLine colors the file contents in one line of diff output with
+// its tabs expanded: both columns of a side-by-side diff of the given width, or
+// the text after the +, -, or space of a unified diff. Headers and gutter marks
+// keep the diff color, and lines that already hold escape sequences, e.g. from a
+// custom diff command, are not colored. The first skip columns are left out, for
+// horizontal scrolling.
+func highlightDiffLine(line string, lang *syntaxLang, unified bool, width, skip int) string {
+	if strings.Contains(line, "\x1b") {
+		return skipColumns(line, skip)
+	}
+
+	var tokens []syntaxToken
+	switch {
+	case unified:
+		if line == "" || strings.HasPrefix(line, "+++") || strings.HasPrefix(line, "---") || !strings.ContainsRune("+- ", rune(line[0])) {
+			tokens = []syntaxToken{{tokenPlain, line}}
+			break
+		}
+		tokens = append([]syntaxToken{{tokenPlain, line[:1]}}, lang.tokenize(line[1:])...)
+	default:
+		gutter, right := sideBySideLayout(width)
+		runes := []rune(line)
+		tokens = lang.tokenize(string(runes[:min(gutter, len(runes))]))
+		if len(runes) > gutter {
+			tokens = append(tokens, syntaxToken{tokenPlain, string(runes[gutter:min(right, len(runes))])})
+		}
+		if len(runes) > right {
+			tokens = append(tokens, lang.tokenize(string(runes[right:]))...)
+		}
+	}
+	return renderTokens(skipTokenColumns(tokens, skip))
+}
+
+// expandTabs replaces tabs with spaces up to the next multiple of 8 columns.
+func expandTabs(line string) string {
+	if !strings.Contains(line, "\t") {
+		return line
+	}
+	var s strings.Builder
+	col := 0
+	for _, r := range line {
+		if r == '\t' {
+			n := 8 - col%8
+			s.WriteString(strings.Repeat(" ", n))
+			col += n
+			continue
+		}
+		s.WriteRune(r)
+		col++
+	}
+	return s.String()
+}
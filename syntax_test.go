@@ -0,0 +1,84 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestSyntaxFor tests choosing a language by file extension.
+func TestSyntaxFor(t *testing.T) {
+	tests := []struct {
+		file string
+		want *syntaxLang
+	}{
+		{"main.go", goSyntax},
+		{"NOTES.MD", markdownSyntax},
+		{"data.json", jsonSyntax},
+		{"photo.jpg", nil},
+		{"Makefile", nil},
+	}
+	for _, tt := range tests {
+		if got := syntaxFor(tt.file); got != tt.want {
+			t.Errorf("syntaxFor(%q) returned the wrong language", tt.file)
+		}
+	}
+}
+
+// TestTokenize tests splitting lines of code into colored tokens.
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		lang *syntaxLang
+		line string
+		want []syntaxToken
+	}{
+		{goSyntax, `return fmt.Sprintf("%d items", 42) // count`, []syntaxToken{
+			{tokenKeyword, "return"},
+			{tokenPlain, " fmt.Sprintf("},
+			{tokenString, `"%d items"`},
+			{tokenPlain, ", "},
+			{tokenNumber, "42"},
+			{tokenPlain, ") "},
+			{tokenComment, "// count"},
+		}},
+		// Keywords within words and strings are left alone
+		{goSyntax, `returned := "for \" if"`, []syntaxToken{
+			{tokenPlain, "returned := "},
+			{tokenString, `"for \" if"`},
+		}},
+		{pythonSyntax, "x1 = 3.14  # pi", []syntaxToken{
+			{tokenPlain, "x1 = "},
+			{tokenNumber, "3.14"},
+			{tokenPlain, "  "},
+			{tokenComment, "# pi"},
+		}},
+		{markdownSyntax, "## Notes", []syntaxToken{{tokenKeyword, "## Notes"}}},
+		{markdownSyntax, "run `make` first", []syntaxToken{
+			{tokenPlain, "run "},
+			{tokenString, "`make`"},
+			{tokenPlain, " first"},
+		}},
+	}
+	for _, tt := range tests {
+		if got := tt.lang.tokenize(tt.line); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("tokenize(%q) = %v, expected %v", tt.line, got, tt.want)
+		}
+	}
+}
+
+// TestHighlightDiffLine tests that the columns of a side-by-side diff stay
+// aligned when colored.
+func TestHighlightDiffLine(t *testing.T) {
+	line := "x := 1\t\t\t\t\t\t\t   |\tx := 2"
+	got := highlightDiffLine(expandTabs(line), goSyntax, false, sideBySideWidth, 0)
+	if got != expandTabs(line) {
+		t.Errorf("highlightDiffLine = %q, expected %q", got, expandTabs(line))
+	}
+	if gutter, _ := sideBySideLayout(sideBySideWidth); strings.Index(got, "|") != gutter {
+		t.Errorf("gutter mark at column %d, expected %d", strings.Index(got, "|"), gutter)
+	}
+
+	if got := highlightDiffLine("+return nil", goSyntax, true, sideBySideWidth, 2); got != "eturn nil" {
+		t.Errorf("highlightDiffLine = %q, expected the unified line scrolled by 2 columns", got)
+	}
+}
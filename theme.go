@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// defaultTheme is the theme used when none is selected and color output is allowed.
+const defaultTheme = "default"
+
+// monochromeTheme is used when NO_COLOR is set or stdout is not a terminal.
+const monochromeTheme = "monochrome"
+
+// palette holds the colors of a theme. A nil color leaves the terminal's default.
+type palette struct {
+	title    lipgloss.TerminalColor
+	selected lipgloss.TerminalColor
+	help     lipgloss.TerminalColor
+	diff     lipgloss.TerminalColor
+	suggest  lipgloss.TerminalColor
+	// keyword, str, comment, and number color code in diffs with --syntax.
+	keyword lipgloss.TerminalColor
+	str     lipgloss.TerminalColor
+	comment lipgloss.TerminalColor
+	number  lipgloss.TerminalColor
+}
+
+// themes maps the names accepted by --theme to their palettes.
+var themes = map[string]palette{
+	"default": {
+		title:    lipgloss.Color("62"),
+		selected: lipgloss.Color("212"),
+		help:     lipgloss.Color("241"),
+		diff:     lipgloss.Color("240"),
+		suggest:  lipgloss.Color("78"),
+		keyword:  lipgloss.Color("141"),
+		str:      lipgloss.Color("114"),
+		comment:  lipgloss.Color("102"),
+		number:   lipgloss.Color("215"),
+	},
+	"light": {
+		title:    lipgloss.Color("25"),
+		selected: lipgloss.Color("161"),
+		help:     lipgloss.Color("243"),
+		diff:     lipgloss.Color("236"),
+		suggest:  lipgloss.Color("28"),
+		keyword:  lipgloss.Color("90"),
+		str:      lipgloss.Color("28"),
+		comment:  lipgloss.Color("245"),
+		number:   lipgloss.Color("130"),
+	},
+	"high-contrast": {
+		title:    lipgloss.Color("14"),
+		selected: lipgloss.Color("11"),
+		help:     lipgloss.Color("15"),
+		diff:     lipgloss.Color("15"),
+		suggest:  lipgloss.Color("10"),
+		keyword:  lipgloss.Color("13"),
+		str:      lipgloss.Color("10"),
+		comment:  lipgloss.Color("7"),
+		number:   lipgloss.Color("11"),
+	},
+	monochromeTheme: {},
+}
+
+// Styles shared by all TUI views. They are set by applyTheme.
+var (
+	titleStyle    lipgloss.Style
+	selectedStyle lipgloss.Style
+	normalStyle   lipgloss.Style
+	helpStyle     lipgloss.Style
+	diffStyle     lipgloss.Style
+	previewStyle  lipgloss.Style
+	cursorStyle   lipgloss.Style
+	suggestStyle  lipgloss.Style
+	matchStyle    lipgloss.Style
+	keywordStyle  lipgloss.Style
+	stringStyle   lipgloss.Style
+	commentStyle  lipgloss.Style
+	numberStyle   lipgloss.Style
+	// statusBarStyle is the bar above the help line with the scan context.
+	statusBarStyle lipgloss.Style
+)
+
+func init() {
+	applyTheme(themes[defaultTheme])
+}
+
+// applyTheme rebuilds the TUI styles from a palette.
+func applyTheme(p palette) {
+	titleStyle = withForeground(lipgloss.NewStyle().Bold(true).Align(lipgloss.Left), p.title)
+	selectedStyle = withForeground(lipgloss.NewStyle().Bold(true), p.selected)
+	normalStyle = lipgloss.NewStyle()
+	helpStyle = withForeground(lipgloss.NewStyle(), p.help)
+	diffStyle = withForeground(lipgloss.NewStyle(), p.diff)
+	previewStyle = lipgloss.NewStyle().BorderStyle(lipgloss.NormalBorder()).BorderLeft(true).PaddingLeft(1)
+	cursorStyle = lipgloss.NewStyle().Reverse(true)
+	suggestStyle = withForeground(lipgloss.NewStyle().Bold(true), p.suggest)
+	matchStyle = lipgloss.NewStyle().Reverse(true)
+	keywordStyle = withForeground(lipgloss.NewStyle().Bold(true), p.keyword)
+	stringStyle = withForeground(lipgloss.NewStyle(), p.str)
+	commentStyle = withForeground(lipgloss.NewStyle().Italic(true), p.comment)
+	numberStyle = withForeground(lipgloss.NewStyle(), p.number)
+	statusBarStyle = lipgloss.NewStyle().Reverse(true)
+}
+
+// withForeground sets the foreground color of s unless c is nil.
+func withForeground(s lipgloss.Style, c lipgloss.TerminalColor) lipgloss.Style {
+	if c == nil {
+		return s
+	}
+	return s.Foreground(c)
+}
+
+// themeNames returns the available theme names, sorted.
+func themeNames() []string {
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// selectTheme returns the palette to use. An explicit name (from --theme or
+// $DOPPEL_THEME) always wins; otherwise colors are dropped when NO_COLOR is set
+// or stdout is not a terminal.
+func selectTheme(name string) (palette, error) {
+	if name == "" {
+		name = os.Getenv("DOPPEL_THEME")
+	}
+	if name == "" {
+		name = defaultTheme
+		if os.Getenv("NO_COLOR") != "" || !isTerminal(os.Stdout) {
+			name = monochromeTheme
+		}
+	}
+
+	p, ok := themes[name]
+	if !ok {
+		return palette{}, fmt.Errorf("unknown theme %q (available: %s)", name, strings.Join(themeNames(), ", "))
+	}
+	return p, nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// TestSelectTheme tests precedence between --theme, $DOPPEL_THEME, and NO_COLOR.
+func TestSelectTheme(t *testing.T) {
+	tests := []struct {
+		name    string
+		flag    string
+		env     string
+		noColor string
+		want    string
+	}{
+		{"flag wins over environment", "light", "high-contrast", "1", "light"},
+		{"environment wins over NO_COLOR", "", "high-contrast", "1", "high-contrast"},
+		{"NO_COLOR drops colors", "", "", "1", monochromeTheme},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DOPPEL_THEME", tt.env)
+			t.Setenv("NO_COLOR", tt.noColor)
+
+			got, err := selectTheme(tt.flag)
+			if err != nil {
+				t.Fatalf("selectTheme(%q) returned error: %v", tt.flag, err)
+			}
+			if got != themes[tt.want] {
+				t.Errorf("selectTheme(%q) = %+v, expected the %s theme", tt.flag, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSelectTheme_Unknown tests that unknown theme names are rejected.
+func TestSelectTheme_Unknown(t *testing.T) {
+	if _, err := selectTheme("solarized"); err == nil {
+		t.Error("selectTheme() should return error for an unknown theme")
+	}
+}
+
+// TestApplyTheme_Monochrome tests that the monochrome theme sets no colors.
+func TestApplyTheme_Monochrome(t *testing.T) {
+	defer applyTheme(themes[defaultTheme])
+
+	applyTheme(themes[monochromeTheme])
+	styles := map[string]lipgloss.Style{
+		"title":    titleStyle,
+		"selected": selectedStyle,
+		"help":     helpStyle,
+		"diff":     diffStyle,
+		"keyword":  keywordStyle,
+		"string":   stringStyle,
+		"comment":  commentStyle,
+		"number":   numberStyle,
+	}
+	for name, style := range styles {
+		if _, isNoColor := style.GetForeground().(lipgloss.NoColor); !isNoColor {
+			t.Errorf("%s style has foreground %v, expected none", name, style.GetForeground())
+		}
+	}
+}
@@ -1,27 +1,27 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io/fs"
+	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 )
 
-var (
-	titleStyle    = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("62")).Align(lipgloss.Left)
-	selectedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("212")).Bold(true)
-	normalStyle   = lipgloss.NewStyle()
-	helpStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
-	diffStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
-)
-
 // TUIState represents the current state of the TUI
 type TUIState int
 
 const (
-	stateSelectGroup TUIState = iota
+	stateLoading TUIState = iota
+	stateSelectGroup
 	stateSelectFirstFile
 	stateSelectSecondFile
 	stateViewDiff
@@ -29,48 +29,429 @@ const (
 
 // model represents the TUI model
 type model struct {
-	groups      [][]string
+	groups       [][]string
 	currentGroup int
-	state       TUIState
-	cursor      int
-	firstFile   string
-	secondFile  string
-	diffOutput  string
-	diffExec    *DiffExecutor
-	width       int
-	height      int
+	state        TUIState
+	cursor       int
+	firstFile    string
+	secondFile   string
+	baseFile     string
+	variants     []string
+	marked       []string
+	// selected holds the files picked with Space in the file list for a bulk
+	// delete, move, or hard link.
+	selected   []string
+	diffOutput string
+	// diffJob is the diff being generated in the background for the diff view,
+	// shown as a spinner until it finishes; Esc cancels it. diffSeq numbers the
+	// jobs so that the output of a replaced job is dropped, and ticking is set
+	// while spinner ticks are scheduled.
+	diffJob *diffJob
+	diffSeq int
+	ticking bool
+	// diffSummary counts the changed lines and says which file is newer, shown
+	// above the diff; see diffSummary.
+	diffSummary string
+	// frontmatterOnly is set when the pair differs only in the frontmatter of
+	// Markdown notes; d and D then delete one of them as for identical files.
+	frontmatterOnly bool
+	// diffScroll is the first line of diffOutput shown, and diffHunks the lines
+	// where its blocks of changes begin, which n and p jump between.
+	diffScroll int
+	diffHunks  []int
+	// diffWidth is the width side-by-side diffs were made for, and diffHScroll
+	// the number of columns scrolled off to the left of lines that still overflow.
+	diffWidth   int
+	diffHScroll int
+	// diffSearch is the text searched for with /, highlighted in the diff, and
+	// diffMatches the lines holding it; diffMatch is the one jumped to last.
+	// While a search is active, n and N move between matches.
+	diffSearch  string
+	diffMatches []int
+	diffMatch   int
+	// syntax colors the code in side-by-side and unified diffs of known file
+	// types; see syntaxFor.
+	syntax bool
+	// identical is set when the compared pair is byte-identical; the diff view then
+	// offers to delete or hardlink one of the files instead of showing an empty diff.
+	identical bool
+	// hardlinked is set when the compared files are hard links to the same data,
+	// which is already deduplicated.
+	hardlinked bool
+	// hardlinks holds, for each file of the current group, the index of an earlier
+	// file it is a hard link to, or -1; see hardlinkPeers.
+	hardlinks []int
+	// comparePairs is set while walking every pair of a group with "compare all";
+	// pairIndex is the pair shown and skippedIdentical counts pairs passed over.
+	comparePairs     [][2]string
+	pairIndex        int
+	skippedIdentical int
+	skippedLinked    int
+	skipIdentical    bool
+	unified          bool
+	diffExec         *DiffExecutor
+	mergeTool        *MergeTool
+	imagePreview     bool
+	// fullPaths shows files as paths relative to displayRoot, the scanned
+	// directory, instead of base names; see displayName.
+	fullPaths   bool
+	displayRoot string
+	ignoreList  *IgnoreList
+	quarantine  *Quarantine
+	// filters describes the active scan filters for the status bar.
+	filters []string
+	// journal records deletions, renames, moves, and hard links so u can undo them.
+	journal *Journal
+	// session totals the groups opened, pairs compared, and file operations
+	// for the summary printed on quit.
+	session *SessionStats
+	// keeper suggests the file of each group to keep, marked with ★; nil shows
+	// no suggestions. suggested caches its choice by group fingerprint.
+	keeper    *Keeper
+	suggested map[string]string
+	// previews caches the previews of the file list, loaded by previewCmd
+	// when the highlight moves rather than read on every View. previewLoading
+	// is the one being loaded, if any.
+	previews       map[previewKey]string
+	previewLoading previewKey
+	// checksums shows a checksum next to each file with --hash and is checked
+	// again before a file is deleted or linked; nil shows none.
+	checksums *Checksums
+	// matcher labels groups with the name their files share; see groupTitle.
+	matcher *Matcher
+	// summary totals the groups for the group list header; it is recomputed
+	// whenever files are removed, linked, or ignored.
+	summary  groupSummary
+	reviewed map[string]bool
+	// lastMoveDir is the destination last entered for a move, offered again.
+	lastMoveDir string
+	// showKeys is set while the help screen listing every key is open; any
+	// key closes it.
+	showKeys bool
+	// explanation holds the lines E shows on why the compared pair is
+	// grouped, in the way of the help screen; any key closes it.
+	explanation []string
+	// scanOpts are the options the groups were scanned with, which E
+	// explains the grouping by.
+	scanOpts options
+	// actions are the custom actions of the config file, offered by the menu
+	// that A opens; actionMenu is set while it is open.
+	actions    []GroupAction
+	actionMenu *actionMenu
+	// git is the repository the files are in, whose committed versions H
+	// diffs against, and with gitStatus set shows the status of each file;
+	// nil outside one. revisionFile is set while a committed version, a
+	// temporary file, is compared as File 1; it can't be deleted or linked.
+	git          *GitRepo
+	gitStatus    bool
+	revisionFile string
+	// revisionMenu is set while the list of revisions that L opens is shown.
+	revisionMenu *revisionMenu
+	// archives extracts the files inside archives listed with --scan-archives
+	// when they are compared; nil without it. Extracted files are shown by
+	// their virtual paths and can't be changed.
+	archives *ArchiveFiles
+	// pending is set while a line of text is being entered, such as a new name
+	// for a file; keys go to its input until Enter or Esc.
+	pending *pendingInput
+	// mergeSource is the fingerprint of the group J picked, which J on another
+	// group merges into it; "" when no group is picked.
+	mergeSource string
+	// expanded holds the fingerprints of groups whose file list is shown in
+	// the group list; groups are collapsed to their title otherwise.
+	expanded map[string]bool
+	load     func(*Progress) scanResult
+	progress *Progress
+	spinner  int
+	scan     scanResult
+	scanDone bool
+	status   string
+	width    int
+	height   int
 }
 
 // initialModel creates a new model with initial state
-func initialModel(groups [][]string, diffExec *DiffExecutor) model {
+func initialModel(groups [][]string, diffExec *DiffExecutor, mergeTool *MergeTool) model {
 	return model{
-		groups:      groups,
-		currentGroup: 0,
-		state:       stateSelectGroup,
-		cursor:      0,
-		diffExec:    diffExec,
+		groups:        groups,
+		currentGroup:  0,
+		state:         stateSelectGroup,
+		cursor:        0,
+		diffExec:      diffExec,
+		mergeTool:     mergeTool,
+		reviewed:      make(map[string]bool),
+		expanded:      make(map[string]bool),
+		suggested:     make(map[string]string),
+		previews:      make(map[previewKey]string),
+		summary:       summarizeGroups(groups),
+		session:       NewSessionStats(),
+		scanOpts:      options{minPrefix: defaultMinPrefixLength},
+		skipIdentical: true,
+	}
+}
+
+// suggestedKeeper returns the file of group that m.keeper suggests keeping, or
+// "" if there is no suggestion.
+func (m model) suggestedKeeper(group []string) string {
+	if m.keeper == nil || len(group) < 2 {
+		return ""
+	}
+	key := groupFingerprint("", group)
+	file, ok := m.suggested[key]
+	if !ok {
+		file = m.keeper.Suggest(group)
+		m.suggested[key] = file
+	}
+	return file
+}
+
+// displayName returns how file is labelled on screen; see displayPath.
+func (m model) displayName(file string) string {
+	return displayPath(m.archives.Virtual(file), m.displayRoot, m.fullPaths)
+}
+
+// displayPath returns file's base name, or with full set, its path relative to
+// root, falling back to the path as given if root is empty or unrelated.
+func displayPath(file, root string, full bool) string {
+	if !full {
+		return filepath.Base(file)
+	}
+	if root != "" {
+		if abs, err := absolutePath(file); err == nil {
+			if rel, err := filepath.Rel(root, abs); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				return rel
+			}
+		}
+	}
+	return file
+}
+
+// groupTitle returns the heading of the i-th group (from 0), such as
+// "Group 12 — 'Obsidian Daily Notes' (3 files)", or "Group 12: 3 files" when
+// the names have nothing in common.
+func (m model) groupTitle(i int) string {
+	return groupTitle(m.matcher, i+1, m.groups[i])
+}
+
+// groupTitle returns the heading of the group numbered n, labelled by matcher.
+func groupTitle(matcher *Matcher, n int, group []string) string {
+	if label := matcher.Label(group); label != "" {
+		return fmt.Sprintf("Group %d — '%s' (%d files)", n, label, len(group))
 	}
+	return fmt.Sprintf("Group %d: %d files", n, len(group))
+}
+
+// scanResult is the outcome of the scan and grouping that precede the group list.
+type scanResult struct {
+	groups    [][]string
+	fileCount int
+	err       error
+}
+
+// scanDoneMsg is sent when the background scan finishes.
+type scanDoneMsg scanResult
+
+// progressTickMsg triggers a redraw of the loading screen.
+type progressTickMsg struct{}
+
+// loadingModel creates a model that shows a loading screen while load runs in
+// the background, then switches to group selection.
+func loadingModel(load func(*Progress) scanResult, diffExec *DiffExecutor, mergeTool *MergeTool) model {
+	m := initialModel(nil, diffExec, mergeTool)
+	m.state = stateLoading
+	m.load = load
+	m.progress = NewProgress("Scanning")
+	return m
+}
+
+// progressTick schedules the next loading screen redraw.
+func progressTick() tea.Cmd {
+	return tea.Tick(progressInterval, func(time.Time) tea.Msg {
+		return progressTickMsg{}
+	})
 }
 
-// Init initializes the model
+// Init initializes the model, starting the background scan if one is pending
 func (m model) Init() tea.Cmd {
-	return nil
+	if m.state != stateLoading {
+		return nil
+	}
+	load, progress := m.load, m.progress
+	return tea.Batch(func() tea.Msg {
+		return scanDoneMsg(load(progress))
+	}, progressTick())
+}
+
+// diffJob is a diff run in the background by a tea.Cmd so that slow diff
+// commands don't block the UI.
+type diffJob struct {
+	seq     int
+	run     func() diffDoneMsg
+	cancel  context.CancelFunc
+	started bool
 }
 
-// Update handles messages and updates the model
+// diffDoneMsg delivers the output and summary of the diff job numbered seq.
+type diffDoneMsg struct {
+	seq             int
+	output          string
+	summary         string
+	hunks           []int
+	width           int
+	frontmatterOnly bool
+}
+
+// cmd returns the command that runs the job.
+func (j *diffJob) cmd() tea.Cmd {
+	run := j.run
+	return func() tea.Msg {
+		return run()
+	}
+}
+
+// Update handles messages and updates the model, then starts any diff the
+// message requested
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	updated, cmd := m.update(msg)
+	um, ok := updated.(model)
+	if !ok {
+		return updated, cmd
+	}
+	if load := um.previewCmd(); load != nil {
+		um.previewLoading, _ = um.previewKey()
+		cmd = tea.Batch(cmd, load)
+	}
+	if um.diffJob == nil || um.diffJob.started {
+		return um, cmd
+	}
+	um.diffJob.started = true
+	cmds := []tea.Cmd{cmd, um.diffJob.cmd()}
+	if !um.ticking {
+		um.ticking = true
+		cmds = append(cmds, progressTick())
+	}
+	return um, tea.Batch(cmds...)
+}
+
+func (m model) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
+		wasUnified := m.diffUnified()
 		m.width = msg.Width
 		m.height = msg.Height
+		// Side-by-side diffs are laid out for the width, and become unified on
+		// a narrow terminal, so make them again
+		if m.state == stateViewDiff && !m.identical && m.baseFile == "" &&
+			(m.diffUnified() != wasUnified || !m.diffUnified() && m.diffWidth != m.sideBySideWidth()) {
+			m = m.requestDiff()
+		}
+		return m, nil
+
+	case progressTickMsg:
+		if m.state != stateLoading && m.diffJob == nil {
+			m.ticking = false
+			return m, nil
+		}
+		m.spinner++
+		return m, progressTick()
+
+	case previewMsg:
+		m.previews[msg.key] = msg.text
+		if m.previewLoading == msg.key {
+			m.previewLoading = previewKey{}
+		}
+		return m, nil
+
+	case diffDoneMsg:
+		if m.diffJob == nil || msg.seq != m.diffJob.seq {
+			return m, nil
+		}
+		m.diffJob.cancel()
+		m.diffJob = nil
+		m.diffOutput = msg.output
+		m.diffSummary = msg.summary
+		m.frontmatterOnly = msg.frontmatterOnly
+		m.diffHunks = msg.hunks
+		m.diffWidth = msg.width
+		m.diffScroll = 0
+		m.diffHScroll = 0
+		if m.diffSearch != "" {
+			m = m.searchDiff("", m.diffSearch)
+		}
+		return m, nil
+
+	case scanDoneMsg:
+		m.scan = scanResult(msg)
+		m.scanDone = true
+		if m.scan.err != nil || len(m.scan.groups) == 0 {
+			return m, tea.Quit
+		}
+		m.groups = m.scan.groups
+		m.summary = summarizeGroups(m.groups)
+		m.state = stateSelectGroup
+		m.cursor = 0
+		return m, nil
+
+	case mergeToolFinishedMsg:
+		m.status = describeMergeToolResult(m.mergeTool.Name(), msg.err)
+		// Edits change modification times and sizes, which suggestions go by
+		m.suggested = make(map[string]string)
+		m.previews = make(map[previewKey]string)
+		m.checksums.Forget()
+		m.git.Forget()
+		// The tool may have edited either file, so refresh the diff
+		if m.state == stateViewDiff && !m.identical {
+			m = m.requestDiff()
+		}
+		return m, nil
+
+	case fileOpenedMsg:
+		m.status = msg.status
+		m.suggested = make(map[string]string)
+		m.previews = make(map[previewKey]string)
+		m.checksums.Forget()
+		m.git.Forget()
 		return m, nil
 
 	case tea.KeyMsg:
+		m.status = ""
+		if m.pending != nil {
+			return m.updatePending(msg)
+		}
+		if m.showKeys {
+			m.showKeys = false
+			if msg.String() == "ctrl+c" {
+				return m, tea.Quit
+			}
+			return m, nil
+		}
+		if m.explanation != nil {
+			m.explanation = nil
+			if msg.String() == "ctrl+c" {
+				return m, tea.Quit
+			}
+			return m, nil
+		}
+		if m.actionMenu != nil {
+			return m.updateActionMenu(msg)
+		}
+		if m.revisionMenu != nil {
+			return m.updateRevisionMenu(msg)
+		}
 		switch msg.String() {
 		case "ctrl+c", "q":
 			return m, tea.Quit
 
+		case "?":
+			if m.state != stateLoading {
+				m.showKeys = true
+			}
+			return m, nil
+
 		case "up", "k":
+			if m.state == stateViewDiff {
+				return m.scrollDiff(m.diffScroll - 1), nil
+			}
 			if m.cursor > 0 {
 				m.cursor--
 				// If selecting second file and cursor lands on first file, skip it
@@ -100,7 +481,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			case stateSelectFirstFile, stateSelectSecondFile:
 				max = len(m.getCurrentGroup()) - 1
 			case stateViewDiff:
-				return m, nil
+				return m.scrollDiff(m.diffScroll + 1), nil
 			}
 			if m.cursor < max {
 				m.cursor++
@@ -123,94 +504,1883 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 			return m, nil
 
-		case "enter", " ":
-			return m.handleEnter()
+		case "left", "right":
+			if m.state == stateViewDiff && !m.identical {
+				step := max(m.width/4, 8)
+				if msg.String() == "left" {
+					step = -step
+				}
+				return m.scrollDiffSideways(step), nil
+			}
+			return m, nil
+
+		case "pgdown", "ctrl+f":
+			if m.state == stateViewDiff {
+				return m.scrollDiff(m.diffScroll + m.diffPageSize()), nil
+			}
+			return m.jumpCursor(m.cursor+m.pageSize(), 1), nil
+
+		case "pgup", "ctrl+b":
+			if m.state == stateViewDiff {
+				return m.scrollDiff(m.diffScroll - m.diffPageSize()), nil
+			}
+			return m.jumpCursor(m.cursor-m.pageSize(), -1), nil
+
+		case "home", "g":
+			if m.state == stateViewDiff {
+				return m.scrollDiff(0), nil
+			}
+			return m.jumpCursor(0, 1), nil
+
+		case "end", "G":
+			if m.state == stateViewDiff {
+				return m.scrollDiff(m.diffLineCount()), nil
+			}
+			return m.jumpCursor(m.listLen()-1, -1), nil
+
+		case "enter", " ":
+			if msg.String() == " " && m.state == stateSelectFirstFile {
+				return m.toggleSelected(), nil
+			}
+			return m.handleEnter()
+
+		case "esc":
+			return m.handleEscape()
+
+		case "o":
+			return m.openMergeTool()
+
+		case "E":
+			return m.explainPair(), nil
+
+		case "A":
+			return m.openActionMenu(), nil
+
+		case "H":
+			return m.diffCommitted(), nil
+
+		case "L":
+			return m.openRevisionMenu(), nil
+
+		case "u", "s":
+			// Outside a diff there is nothing to render differently, so u undoes
+			if msg.String() == "u" && (m.state != stateViewDiff || m.identical) {
+				return m.undo(), nil
+			}
+			// Switch between unified and side-by-side rendering of the same pair
+			if m.state == stateViewDiff && m.unified != (msg.String() == "u") {
+				m.unified = !m.unified
+				if !m.narrow() {
+					m = m.requestDiff()
+				}
+			}
+			if m.state == stateViewDiff && m.narrow() && !m.unified {
+				// The diff stays unified until the terminal gets wider
+				m.status = fmt.Sprintf("Side-by-side diffs need a terminal of %d columns", narrowWidth)
+			}
+			return m, nil
+
+		case "w":
+			if m.state == stateViewDiff {
+				m.diffExec.SetIgnoreWhitespace(!m.diffExec.IgnoresWhitespace())
+				if !m.identical {
+					m = m.requestDiff()
+				}
+			}
+			return m, nil
+
+		case "3":
+			return m.openThreeWay(), nil
+
+		case "a":
+			return m.startCompareAll(), nil
+
+		case "m":
+			return m.toggleMark(), nil
+
+		case "c":
+			return m.compareMarked(), nil
+
+		case "d", "D", "h":
+			// Bulk actions on the files selected with Space
+			if m.state == stateSelectFirstFile && len(m.selected) > 0 {
+				switch msg.String() {
+				case "d":
+					return m.startDeleteSelected(), nil
+				case "h":
+					return m.hardlinkSelected(), nil
+				}
+			}
+			// Quick actions on the identical-files screen, and deleting either of
+			// two notes whose frontmatter alone differs
+			if m.state == stateViewDiff && m.revisionFile != "" {
+				m.status = "A committed version can't be deleted or linked"
+				return m, nil
+			}
+			if m.state == stateViewDiff && m.hasArchiveMember(m.firstFile, m.secondFile) {
+				m.status = archiveMemberRefusal
+				return m, nil
+			}
+			if m.state == stateViewDiff && m.frontmatterOnly && m.diffJob == nil {
+				switch msg.String() {
+				case "d":
+					return m.resolveIdentical(m.secondFile, false), nil
+				case "D":
+					return m.resolveIdentical(m.firstFile, false), nil
+				}
+			}
+			if m.state == stateViewDiff && m.identical {
+				switch msg.String() {
+				case "d":
+					return m.resolveIdentical(m.secondFile, false), nil
+				case "D":
+					return m.resolveIdentical(m.firstFile, false), nil
+				case "h":
+					return m.resolveIdentical(m.secondFile, true), nil
+				}
+			}
+			return m, nil
+
+		case "e":
+			return m.openInEditor()
+
+		case "x":
+			return m.openInViewer()
+
+		case "I":
+			return m.showImagePreviews()
+
+		case "y":
+			return m.copyPath()
+
+		case "r":
+			return m.startRename(), nil
+
+		case "M":
+			return m.startMove(), nil
+
+		case "v":
+			if m.state == stateSelectGroup && m.cursor < len(m.groups) {
+				key := groupFingerprint("", m.groups[m.cursor])
+				m.reviewed[key] = !m.reviewed[key]
+			}
+			return m, nil
+
+		case "i":
+			if m.state == stateSelectGroup {
+				return m.ignoreGroup(), nil
+			}
+			return m, nil
+
+		case "S":
+			return m.splitOffFiles(), nil
+
+		case "J":
+			return m.mergeGroup(), nil
+
+		case "/":
+			if m.state == stateViewDiff && !m.identical && m.diffJob == nil {
+				m.pending = &pendingInput{
+					input:  newLineInput("Search: ", m.diffSearch),
+					submit: model.searchDiff,
+				}
+			}
+			return m, nil
+
+		case "N":
+			if m.state == stateViewDiff && m.diffSearch != "" {
+				return m.jumpToMatch(-1), nil
+			}
+			return m, nil
+
+		case "p":
+			if m.state == stateViewDiff && !m.identical {
+				return m.jumpToChange(-1), nil
+			}
+			// Base names can collide when files come from different folders
+			m.fullPaths = !m.fullPaths
+			return m, nil
+
+		case "tab":
+			return m.toggleExpanded(), nil
+
+		case "z":
+			return m.toggleExpandAll(), nil
+
+		case "n":
+			if m.state == stateViewDiff && m.diffSearch != "" {
+				return m.jumpToMatch(1), nil
+			}
+			if m.state == stateViewDiff && !m.identical {
+				return m.jumpToChange(1), nil
+			}
+			if m.state == stateSelectGroup {
+				if m.currentGroup < len(m.groups)-1 {
+					m.currentGroup++
+					m.cursor = 0
+				}
+				return m, nil
+			}
+			return m, nil
+		}
+	}
+
+	return m, nil
+}
+
+// splitOffFiles moves the files selected with Space, or the highlighted file,
+// out of the current group into a group of their own right after it, for
+// files the heuristics grouped by mistake. The correction lasts for the
+// session and is listed in its summary.
+func (m model) splitOffFiles() model {
+	group := m.getCurrentGroup()
+	if m.state != stateSelectFirstFile || m.cursor >= len(group) {
+		return m
+	}
+	files := m.selected
+	if len(files) == 0 {
+		files = []string{group[m.cursor]}
+	}
+	if len(files) == len(group) {
+		m.status = "Leave at least one file in the group to split the others off"
+		return m
+	}
+
+	var kept, split []string
+	for _, f := range group {
+		if slices.Contains(files, f) {
+			split = append(split, f)
+		} else {
+			kept = append(kept, f)
+		}
+	}
+	groups := slices.Clone(m.groups)
+	groups[m.currentGroup] = kept
+	m.groups = slices.Insert(groups, m.currentGroup+1, split)
+	m.summary = summarizeGroups(m.groups)
+	m.hardlinks = hardlinkPeers(kept)
+	m.marked = slices.DeleteFunc(slices.Clone(m.marked), func(f string) bool { return slices.Contains(split, f) })
+	m.selected = nil
+	m.cursor = min(m.cursor, len(kept)-1)
+	m.session.SplitGroup(split)
+	m.status = fmt.Sprintf("Split %s off into group %d", plural(len(split), "file"), m.currentGroup+2)
+	return m
+}
+
+// mergeGroup merges two groups the heuristics kept apart: the first J picks
+// the highlighted group, and J on another group moves the picked group's
+// files to the end of it. Like splitOffFiles, the correction lasts for the
+// session and is listed in its summary.
+func (m model) mergeGroup() model {
+	if m.state != stateSelectGroup || m.cursor >= len(m.groups) {
+		return m
+	}
+	if m.mergeSource == "" {
+		m.mergeSource = groupFingerprint("", m.groups[m.cursor])
+		m.status = "Press J on the group to merge this one into, or Esc to cancel"
+		return m
+	}
+
+	source := slices.IndexFunc(m.groups, func(g []string) bool { return groupFingerprint("", g) == m.mergeSource })
+	m.mergeSource = ""
+	if source < 0 || source == m.cursor {
+		m.status = "Merge cancelled"
+		return m
+	}
+	from, into := m.groups[source], m.groups[m.cursor]
+	groups := slices.Clone(m.groups)
+	groups[m.cursor] = append(slices.Clone(into), from...)
+	m.groups = slices.Delete(groups, source, source+1)
+	if source < m.cursor {
+		m.cursor--
+	}
+	m.currentGroup = m.cursor
+	m.summary = summarizeGroups(m.groups)
+	m.session.MergeGroups(from[0], into[0])
+	m.status = fmt.Sprintf("Merged %s into group %d", plural(len(from), "file"), m.cursor+1)
+	return m
+}
+
+// ignoreGroup hides the highlighted group and records it in the ignore list so it
+// is filtered out on future runs of the same directory
+func (m model) ignoreGroup() model {
+	if m.ignoreList == nil || m.cursor >= len(m.groups) {
+		return m
+	}
+
+	m.ignoreList.Add(m.groups[m.cursor])
+	if err := m.ignoreList.Save(); err != nil {
+		m.status = fmt.Sprintf("Error saving ignore list: %v", err)
+		return m
+	}
+
+	groups := make([][]string, 0, len(m.groups)-1)
+	groups = append(groups, m.groups[:m.cursor]...)
+	m.groups = append(groups, m.groups[m.cursor+1:]...)
+	m.summary = summarizeGroups(m.groups)
+	if m.cursor >= len(m.groups) && m.cursor > 0 {
+		m.cursor--
+	}
+	m.status = "Group ignored; it will be hidden on future runs (use --include-ignored to show it)"
+	return m
+}
+
+// diffLineCount returns the number of lines of diff output.
+func (m model) diffLineCount() int {
+	return len(strings.Split(m.diffOutput, "\n"))
+}
+
+// diffPageSize returns the number of lines of diff output shown at once.
+func (m model) diffPageSize() int {
+	size := m.height - 16 - m.extraHelpLines() // Leave room for header, status bar, and help
+	if m.diffSummary != "" {
+		size -= 2
+	}
+	if size < 1 {
+		size = 10
+	}
+	return size
+}
+
+// scrollDiff scrolls the diff view to show line pos first, clamped so that the
+// last page stays full.
+func (m model) scrollDiff(pos int) model {
+	m.diffScroll = max(0, min(pos, m.diffLineCount()-m.diffPageSize()))
+	return m
+}
+
+// jumpToChange scrolls the diff view to the next block of changes below the top
+// line, or with dir -1 the previous one above it.
+func (m model) jumpToChange(dir int) model {
+	if len(m.diffHunks) == 0 {
+		m.status = "No changes to jump to"
+		return m
+	}
+	target := -1
+	if dir > 0 {
+		last := max(0, m.diffLineCount()-m.diffPageSize())
+		for i, start := range m.diffHunks {
+			if start > m.diffScroll && m.diffScroll < last {
+				target = i
+				break
+			}
+		}
+	} else {
+		for i := len(m.diffHunks) - 1; i >= 0; i-- {
+			if m.diffHunks[i] < m.diffScroll {
+				target = i
+				break
+			}
+		}
+	}
+	if target < 0 {
+		if dir > 0 {
+			m.status = "No more changes below"
+		} else {
+			m.status = "No more changes above"
+		}
+		return m
+	}
+	m = m.scrollDiff(m.diffHunks[target])
+	m.status = fmt.Sprintf("Change %d of %d", target+1, len(m.diffHunks))
+	return m
+}
+
+// searchDiff finds the lines of the diff holding query and jumps to the first
+// one from the top line down, wrapping around to the start. An empty query ends
+// the search.
+func (m model) searchDiff(_, query string) model {
+	m.diffSearch, m.diffMatches = query, nil
+	if query == "" {
+		return m
+	}
+	for i, line := range strings.Split(m.diffOutput, "\n") {
+		if len(findMatches(line, query)) > 0 {
+			m.diffMatches = append(m.diffMatches, i)
+		}
+	}
+	if len(m.diffMatches) == 0 {
+		m.status = fmt.Sprintf("No matches for %q", query)
+		return m
+	}
+	m.diffMatch = 0
+	for i, line := range m.diffMatches {
+		if line >= m.diffScroll {
+			m.diffMatch = i
+			break
+		}
+	}
+	return m.showMatch("")
+}
+
+// jumpToMatch moves to the next match of the search, or with dir -1 the
+// previous one, wrapping around at either end.
+func (m model) jumpToMatch(dir int) model {
+	if len(m.diffMatches) == 0 {
+		m.status = fmt.Sprintf("No matches for %q", m.diffSearch)
+		return m
+	}
+	next := m.diffMatch + dir
+	wrapped := ""
+	if next < 0 || next >= len(m.diffMatches) {
+		next = (next + len(m.diffMatches)) % len(m.diffMatches)
+		wrapped = " (wrapped)"
+	}
+	m.diffMatch = next
+	return m.showMatch(wrapped)
+}
+
+// showMatch scrolls the diff view to the current match.
+func (m model) showMatch(note string) model {
+	m = m.scrollDiff(m.diffMatches[m.diffMatch])
+	m.status = fmt.Sprintf("Match %d of %d%s", m.diffMatch+1, len(m.diffMatches), note)
+	return m
+}
+
+// renderDiffLine renders one line of diff output, scrolled horizontally by
+// diffHScroll columns, with search matches or else syntax colors highlighted.
+func (m model) renderDiffLine(line string) string {
+	// lipgloss would turn each tab into 4 spaces, which misaligns the columns
+	line = expandTabs(line)
+	if m.diffSearch != "" {
+		// Matches stand out better without syntax colors around them
+		return highlightMatches(skipColumns(line, m.diffHScroll), m.diffSearch)
+	}
+	if lang := syntaxFor(m.firstFile); m.syntax && lang != nil && m.baseFile == "" {
+		return highlightDiffLine(line, lang, m.diffUnified(), m.diffWidth, m.diffHScroll)
+	}
+	return diffStyle.Render(skipColumns(line, m.diffHScroll))
+}
+
+// skipColumns drops the first n characters of line.
+func skipColumns(line string, n int) string {
+	if n <= 0 {
+		return line
+	}
+	runes := []rune(line)
+	return string(runes[min(n, len(runes)):])
+}
+
+// scrollDiffSideways scrolls the diff view by cols columns, up to the point
+// where the longest line shown ends at the right edge.
+func (m model) scrollDiffSideways(cols int) model {
+	widest := 0
+	for _, line := range strings.Split(m.diffOutput, "\n") {
+		widest = max(widest, utf8.RuneCountInString(expandTabs(line)))
+	}
+	m.diffHScroll = max(0, min(m.diffHScroll+cols, widest-m.width))
+	return m
+}
+
+// findMatches returns the byte ranges of the occurrences of query in line. The
+// search ignores case unless query has an upper-case letter.
+func findMatches(line, query string) [][2]int {
+	haystack := line
+	if query == strings.ToLower(query) {
+		// Lowering can change the length of some characters, which would
+		// misplace the ranges; those lines are searched as they are
+		if lower := strings.ToLower(line); len(lower) == len(line) {
+			haystack = lower
+		}
+	}
+	var matches [][2]int
+	for start := 0; query != ""; {
+		i := strings.Index(haystack[start:], query)
+		if i < 0 {
+			break
+		}
+		matches = append(matches, [2]int{start + i, start + i + len(query)})
+		start += i + len(query)
+	}
+	return matches
+}
+
+// highlightMatches renders a line of diff output with the occurrences of query
+// highlighted.
+func highlightMatches(line, query string) string {
+	var s strings.Builder
+	last := 0
+	for _, match := range findMatches(line, query) {
+		s.WriteString(diffStyle.Render(line[last:match[0]]))
+		s.WriteString(matchStyle.Render(line[match[0]:match[1]]))
+		last = match[1]
+	}
+	s.WriteString(diffStyle.Render(line[last:]))
+	return s.String()
+}
+
+// listLen returns the number of items in the current list, or 0 if the state has no list
+func (m model) listLen() int {
+	switch m.state {
+	case stateSelectGroup:
+		return len(m.groups)
+	case stateSelectFirstFile, stateSelectSecondFile:
+		return len(m.getCurrentGroup())
+	}
+	return 0
+}
+
+// pageSize returns how many list items fit on one screen
+func (m model) pageSize() int {
+	// Leave room for the title, status bar, and help lines
+	linesPerItem, reserved := 1, 7
+	if m.state == stateSelectGroup {
+		// A collapsed group takes a title line and a blank line, and the
+		// breadcrumb and summary sit above the groups; see groupRange
+		linesPerItem, reserved = 2, 10
+	}
+	size := (m.height - reserved - m.extraHelpLines()) / linesPerItem
+	// and for the preview pane when it is shown below the file list
+	if m.state == stateSelectFirstFile || m.state == stateSelectSecondFile {
+		if side, lines, _ := m.previewLayout(); !side && lines > 0 {
+			size -= lines + 2
+		}
+	}
+	if size < 1 {
+		size = 1
+	}
+	return size
+}
+
+// jumpCursor moves the cursor to pos, clamped to the list bounds. When selecting the
+// second file and pos lands on the first file, the cursor steps once more in direction
+// dir, or against it if already at the edge of the list.
+func (m model) jumpCursor(pos, dir int) model {
+	n := m.listLen()
+	if n == 0 {
+		return m
+	}
+	pos = max(0, min(pos, n-1))
+
+	if m.state == stateSelectSecondFile && m.getCurrentGroup()[pos] == m.firstFile {
+		if next := pos + dir; next >= 0 && next < n {
+			pos = next
+		} else if prev := pos - dir; prev >= 0 && prev < n {
+			pos = prev
+		}
+	}
+	m.cursor = pos
+	return m
+}
+
+// handleEnter handles the enter key press
+func (m model) handleEnter() (tea.Model, tea.Cmd) {
+	switch m.state {
+	case stateSelectGroup:
+		if len(m.groups) == 0 {
+			return m, tea.Quit
+		}
+		// Update currentGroup to match the selected group (cursor position)
+		m.currentGroup = m.cursor
+		m.hardlinks = hardlinkPeers(m.getCurrentGroup())
+		m.session.OpenGroup(m.getCurrentGroup())
+		m.state = stateSelectFirstFile
+		m.cursor = 0
+		return m, nil
+
+	case stateSelectFirstFile:
+		group := m.getCurrentGroup()
+		if len(group) < 2 {
+			// Left by splitting files off with S
+			m.status = "The group has no other file to compare with; merge it into another group with J"
+			return m, nil
+		}
+		if m.cursor < len(group) {
+			m.firstFile = group[m.cursor]
+			m.state = stateSelectSecondFile
+			// Set cursor to first available file (skip the selected first file)
+			m.cursor = 0
+			if m.cursor < len(group) && group[m.cursor] == m.firstFile {
+				if len(group) > 1 {
+					m.cursor = 1
+				}
+			}
+		}
+		return m, nil
+
+	case stateSelectSecondFile:
+		group := m.getCurrentGroup()
+		if m.cursor < len(group) {
+			selectedFile := group[m.cursor]
+			if selectedFile == m.firstFile {
+				// Can't select the same file
+				return m, nil
+			}
+			return m.showPair(m.firstFile, selectedFile), nil
+		}
+		return m, nil
+
+	case stateViewDiff:
+		if m.comparePairs != nil {
+			return m.nextPair(), nil
+		}
+		// After viewing diff, go back to selecting first file
+		m = m.closeDiff()
+		m.state = stateSelectFirstFile
+		m.firstFile = ""
+		m.secondFile = ""
+		m.baseFile = ""
+		m.variants = nil
+		m.diffOutput = ""
+		m.identical = false
+		m.cursor = 0
+		return m, nil
+	}
+
+	return m, nil
+}
+
+// requestDiff cancels any diff still running and queues one for the selected pair;
+// Update starts it in the background and diffDoneMsg delivers its output.
+func (m model) requestDiff() model {
+	m = m.cancelDiff()
+	ctx, cancel := context.WithCancel(m.diffExec.context())
+	exec := m.diffExec.WithContext(ctx)
+	if width := m.sideBySideWidth(); width > 0 {
+		exec.SetWidth(width)
+	}
+	view := m
+	m.diffSeq++
+	seq := m.diffSeq
+	m.diffJob = &diffJob{seq: seq, cancel: cancel, run: func() diffDoneMsg {
+		msg := diffDoneMsg{seq: seq, output: view.generateDiff(exec), width: exec.Width()}
+		if view.baseFile == "" {
+			msg.summary = diffSummary(exec, view.firstFile, view.secondFile)
+			if exec.ComparesFrontmatter() {
+				c, ok := compareFrontmatter(view.firstFile, view.secondFile)
+				msg.frontmatterOnly = ok && c.onlyFrontmatter()
+			}
+		}
+		msg.hunks = hunkStarts(msg.output, view.diffUnified(), view.baseFile != "", msg.width)
+		return msg
+	}}
+	m.diffOutput = ""
+	m.diffSummary = ""
+	m.frontmatterOnly = false
+	m.diffHunks = nil
+	m.diffScroll = 0
+	return m
+}
+
+// narrow reports whether the terminal is narrower than narrowWidth.
+func (m model) narrow() bool {
+	return m.width > 0 && m.width < narrowWidth
+}
+
+// diffUnified reports whether the diff is shown unified: when u asked for it,
+// or when the terminal is too narrow for side-by-side columns.
+func (m model) diffUnified() bool {
+	return m.unified || m.narrow()
+}
+
+// sideBySideWidth returns the width side-by-side diffs are made for: the
+// terminal's, or 0 before its size is known.
+func (m model) sideBySideWidth() int {
+	if m.width <= 0 {
+		return 0
+	}
+	return max(m.width, minSideBySideWidth)
+}
+
+// closeDiff cancels the diff being generated, ends any search, and forgets
+// whether the pair differed only in frontmatter or was a committed version,
+// for leaving the diff view or moving on to another pair.
+func (m model) closeDiff() model {
+	m = m.cancelDiff()
+	m.diffSearch, m.diffMatches = "", nil
+	m.frontmatterOnly = false
+	m.revisionFile = ""
+	return m
+}
+
+// cancelDiff stops the diff being generated, if any; its output is dropped.
+func (m model) cancelDiff() model {
+	if m.diffJob != nil {
+		m.diffJob.cancel()
+		m.diffJob = nil
+	}
+	return m
+}
+
+// generateDiff runs the side-by-side or unified diff for the selected pair, or the column
+// view of the variants when a base file is set. Image pairs get a metadata and perceptual-hash comparison,
+// and other binary files a byte-level comparison.
+// Errors are rendered into the output so they are visible in the diff view.
+func (m model) generateDiff(exec *DiffExecutor) string {
+	if m.baseFile != "" {
+		output, err := multiWayDiff(m.baseFile, m.variants, m.width, exec.IgnoresWhitespace())
+		if err != nil {
+			return fmt.Sprintf("Error generating diff: %v", err)
+		}
+		return output
+	}
+	output, err := exec.ComparePair(m.firstFile, m.secondFile, m.diffUnified())
+	if err != nil {
+		return fmt.Sprintf("Error generating diff: %v", err)
+	}
+	return output
+}
+
+// openThreeWay shows two variants of the group's base file next to the base. The
+// variants are the selected pair; when choosing the first file of a group made of
+// a base and two variants, those two are used.
+func (m model) openThreeWay() model {
+	if m.state != stateSelectFirstFile && m.state != stateSelectSecondFile && m.state != stateViewDiff {
+		return m
+	}
+	group := m.getCurrentGroup()
+	base, ok := groupBase(group)
+	if !ok {
+		m.status = "No base file in this group (no name is a prefix of all the others)"
+		return m
+	}
+
+	var left, right string
+	switch m.state {
+	case stateSelectFirstFile:
+		if len(group) != 3 {
+			m.status = "Select the first variant, then press 3 on the second"
+			return m
+		}
+		for _, file := range group {
+			if file == base {
+				continue
+			}
+			if left == "" {
+				left = file
+			} else {
+				right = file
+			}
+		}
+	case stateSelectSecondFile:
+		file, ok := m.highlightedFile()
+		if !ok || file == m.firstFile {
+			return m
+		}
+		left, right = m.firstFile, file
+	case stateViewDiff:
+		left, right = m.firstFile, m.secondFile
+	}
+	if left == base || right == base {
+		m.status = fmt.Sprintf("%s is the base file; choose two other files", m.displayName(base))
+		return m
+	}
+
+	m.firstFile, m.secondFile, m.baseFile = left, right, base
+	m.variants = []string{left, right}
+	m.identical = false
+	m = m.requestDiff()
+	m.state = stateViewDiff
+	return m
+}
+
+// showPair opens the diff view for a pair, or the identical-files screen when the
+// two files have the same bytes
+func (m model) showPair(file1, file2 string) model {
+	for _, file := range []*string{&file1, &file2} {
+		local, err := m.archives.Local(*file)
+		if err != nil {
+			m.status = fmt.Sprintf("Can't extract %s: %v", m.displayName(*file), err)
+			return m
+		}
+		*file = local
+	}
+	m.firstFile, m.secondFile = file1, file2
+	m.session.ComparePair(file1, file2)
+	m.hardlinked = sameFile(file1, file2)
+	m.identical = m.hardlinked
+	if !m.identical {
+		m.identical, _ = filesByteIdentical(file1, file2)
+	}
+	m = m.closeDiff()
+	m.diffOutput = ""
+	if !m.identical {
+		m = m.requestDiff()
+	}
+	m.state = stateViewDiff
+	return m
+}
+
+// resolveIdentical removes one file of an identical pair, or with hardlink replaces
+// it with a hard link to the other. The files are compared again first in case they
+// changed while the screen was open, as are their checksums with --hash.
+func (m model) resolveIdentical(remove string, hardlink bool) model {
+	keep := m.firstFile
+	if remove == m.firstFile {
+		keep = m.secondFile
+	}
+	if err := m.checksums.Verify(context.Background(), keep, remove); err != nil {
+		m.status = fmt.Sprintf("%v; nothing was changed", err)
+		return m
+	}
+	if m.frontmatterOnly {
+		if c, ok := compareFrontmatter(keep, remove); !ok || !c.onlyFrontmatter() {
+			m.status = "Files no longer differ only in their frontmatter; nothing was changed"
+			m = m.requestDiff()
+			return m
+		}
+	} else if identical, err := filesByteIdentical(keep, remove); err != nil || !identical {
+		m.status = "Files are no longer identical; nothing was changed"
+		m.identical = false
+		m = m.requestDiff()
+		return m
+	}
+
+	var status string
+	group := m.getCurrentGroup()
+	if link := symlinkTo(group, remove); link != "" {
+		m.status = fmt.Sprintf("%s is a symbolic link to %s; nothing was changed", m.displayName(link), m.displayName(remove))
+		return m
+	}
+	if hardlink {
+		if m.hardlinked {
+			m.status = "Files are already hard links to the same data"
+			return m
+		}
+		info, err := os.Stat(remove)
+		if err == nil {
+			err = replaceWithHardlink(keep, remove)
+		}
+		if err != nil {
+			m.status = fmt.Sprintf("Error creating hard link: %v", err)
+			return m
+		}
+		status = fmt.Sprintf("Replaced %s with a hard link to %s", m.displayName(remove), m.displayName(keep))
+		status += m.record(hardlinkEntry(keep, remove, info, group), info.Size())
+	} else {
+		size := fileSize(remove)
+		dest, err := m.quarantine.Remove(remove)
+		if err != nil {
+			m.status = fmt.Sprintf("Error deleting file: %v", err)
+			return m
+		}
+		status = m.quarantine.Describe(m.displayName(remove))
+		status += m.record(removalEntry(remove, dest, m.quarantine, group), size)
+		m = m.dropFile(remove)
+	}
+	m.summary = summarizeGroups(m.groups)
+
+	switch {
+	case m.state == stateSelectGroup:
+		// The group was dissolved by the deletion
+	case m.comparePairs != nil:
+		m = m.nextPair()
+		if m.status != "" {
+			status += "; " + m.status
+		}
+	default:
+		m = m.returnToFileSelection()
+	}
+	m.status = status
+	return m
+}
+
+// dropFile removes deleted files from their group, from the marked and selected
+// files, and from any pending pairs. Groups left with a single file are removed
+// and the view returns to the group list.
+func (m model) dropFile(files ...string) model {
+	dropped := func(f string) bool { return slices.Contains(files, f) }
+	group := m.getCurrentGroup()
+	remaining := make([]string, 0, len(group))
+	for _, f := range group {
+		if !dropped(f) {
+			remaining = append(remaining, f)
+		}
+	}
+	m.marked = slices.DeleteFunc(slices.Clone(m.marked), dropped)
+	m.selected = slices.DeleteFunc(slices.Clone(m.selected), dropped)
+
+	groups := append([][]string{}, m.groups...)
+	if len(remaining) >= 2 {
+		groups[m.currentGroup] = remaining
+		m.hardlinks = hardlinkPeers(remaining)
+	} else {
+		groups = append(groups[:m.currentGroup], groups[m.currentGroup+1:]...)
+		m.comparePairs = nil
+		m.state = stateSelectGroup
+		m.firstFile, m.secondFile, m.diffOutput = "", "", ""
+		m = m.closeDiff()
+		m.identical = false
+		m.cursor = min(m.currentGroup, max(len(groups)-1, 0))
+		m.currentGroup = m.cursor
+	}
+	m.groups = groups
+
+	var pairs [][2]string
+	for i, pair := range m.comparePairs {
+		if i <= m.pairIndex || (!dropped(pair[0]) && !dropped(pair[1])) {
+			pairs = append(pairs, pair)
+		}
+	}
+	if m.comparePairs != nil {
+		m.comparePairs = pairs
+	}
+	return m
+}
+
+// groupPairs returns every unordered pair of files in a group, in group order
+func groupPairs(group []string) [][2]string {
+	var pairs [][2]string
+	for i := 0; i < len(group); i++ {
+		for j := i + 1; j < len(group); j++ {
+			pairs = append(pairs, [2]string{group[i], group[j]})
+		}
+	}
+	return pairs
+}
+
+// startCompareAll starts walking every pair of the highlighted or current group
+func (m model) startCompareAll() model {
+	switch m.state {
+	case stateSelectGroup:
+		if m.cursor >= len(m.groups) {
+			return m
+		}
+		m.currentGroup = m.cursor
+		m.hardlinks = hardlinkPeers(m.getCurrentGroup())
+		m.session.OpenGroup(m.getCurrentGroup())
+	case stateSelectFirstFile:
+	default:
+		return m
+	}
+
+	m.comparePairs = groupPairs(m.getCurrentGroup())
+	m.pairIndex = -1
+	m.skippedIdentical = 0
+	m.skippedLinked = 0
+	return m.nextPair()
+}
+
+// nextPair shows the diff of the next pair in compare-all mode, skipping pairs whose
+// files are identical, and always pairs that are hard links to the same data. After
+// the last pair, it returns to the file selection.
+func (m model) nextPair() model {
+	m.baseFile = ""
+	m.variants = nil
+	for m.pairIndex++; m.pairIndex < len(m.comparePairs); m.pairIndex++ {
+		pair := m.comparePairs[m.pairIndex]
+		if sameFile(pair[0], pair[1]) {
+			m.skippedLinked++
+			continue
+		}
+		if !m.skipIdentical {
+			return m.showPair(pair[0], pair[1])
+		}
+		if identical, err := m.diffExec.FilesIdentical(pair[0], pair[1]); err == nil && identical {
+			m.skippedIdentical++
+			continue
+		}
+		return m.showPair(pair[0], pair[1])
+	}
+
+	status := fmt.Sprintf("Compared all %d pairs", len(m.comparePairs))
+	if m.skippedIdentical > 0 {
+		status += fmt.Sprintf(" (%d identical pairs skipped)", m.skippedIdentical)
+	}
+	if m.skippedLinked > 0 {
+		status += fmt.Sprintf(" (%d already hard-linked)", m.skippedLinked)
+	}
+	m = m.returnToFileSelection()
+	m.status = status
+	return m
+}
+
+// returnToFileSelection leaves the diff view, and compare-all mode if active, for the
+// first file selection
+func (m model) returnToFileSelection() model {
+	m = m.closeDiff()
+	m.comparePairs = nil
+	m.state = stateSelectFirstFile
+	m.firstFile = ""
+	m.secondFile = ""
+	m.baseFile = ""
+	m.variants = nil
+	m.diffOutput = ""
+	m.identical = false
+	m.cursor = 0
+	return m
+}
+
+// toggleMark marks or unmarks the highlighted file for a multi-file comparison
+func (m model) toggleMark() model {
+	if m.state != stateSelectFirstFile {
+		return m
+	}
+	file, ok := m.highlightedFile()
+	if !ok {
+		return m
+	}
+
+	marked := make([]string, 0, len(m.marked)+1)
+	for _, f := range m.marked {
+		if f != file {
+			marked = append(marked, f)
+		}
+	}
+	if len(marked) == len(m.marked) {
+		marked = append(marked, file)
+	}
+	m.marked = marked
+	return m
+}
+
+// markIndex returns the 1-based position of a file among the marked files, or 0
+func (m model) markIndex(file string) int {
+	for i, f := range m.marked {
+		if f == file {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// compareMarked shows the marked files in columns, each compared with the file
+// marked first
+func (m model) compareMarked() model {
+	if m.state != stateSelectFirstFile {
+		return m
+	}
+	if len(m.marked) < 2 {
+		m.status = "Mark at least two files with m first"
+		return m
+	}
+
+	m.baseFile = m.marked[0]
+	m.variants = append([]string{}, m.marked[1:]...)
+	m.firstFile, m.secondFile = m.marked[0], m.marked[1]
+	m.identical = false
+	m = m.requestDiff()
+	m.state = stateViewDiff
+	return m
+}
+
+// toggleSelected selects or deselects the highlighted file for a bulk action
+// and moves on to the next file, so that a run of files is quick to pick.
+func (m model) toggleSelected() model {
+	file, ok := m.highlightedFile()
+	if !ok {
+		return m
+	}
+	if slices.Contains(m.selected, file) {
+		m.selected = slices.DeleteFunc(slices.Clone(m.selected), func(f string) bool { return f == file })
+	} else {
+		m.selected = append(slices.Clone(m.selected), file)
+	}
+	return m.jumpCursor(m.cursor+1, 1)
+}
+
+// selectionStatus counts the selected files and their total size for the
+// status bar, such as "3 files selected (1.2 MB)".
+func (m model) selectionStatus() string {
+	var size int64
+	for _, file := range m.selected {
+		if info, err := os.Stat(file); err == nil {
+			size += info.Size()
+		}
+	}
+	noun := "files"
+	if len(m.selected) == 1 {
+		noun = "file"
+	}
+	return fmt.Sprintf("%d %s selected (%s)", len(m.selected), noun, formatBytes(size))
+}
+
+// startDeleteSelected asks to confirm deleting the selected files. At least one
+// file of the group has to stay unselected, but selected files may differ from
+// every unselected one, so the prompt says how many would lose their contents.
+func (m model) startDeleteSelected() model {
+	if m.hasArchiveMember(m.selected...) {
+		m.status = archiveMemberRefusal
+		return m
+	}
+	if len(m.selected) >= len(m.getCurrentGroup()) {
+		m.status = "Leave at least one file of the group unselected; nothing was deleted"
+		return m
+	}
+	unselected := slices.DeleteFunc(slices.Clone(m.getCurrentGroup()), func(f string) bool { return slices.Contains(m.selected, f) })
+	for _, file := range m.selected {
+		if link := symlinkTo(unselected, file); link != "" {
+			m.status = fmt.Sprintf("%s is a symbolic link to %s; nothing was deleted", m.displayName(link), m.displayName(file))
+			return m
+		}
+	}
+	verb := "Delete"
+	if m.quarantine != nil {
+		verb = "Quarantine"
+	}
+	prompt := fmt.Sprintf("%s %d selected files? ", verb, len(m.selected))
+	if n := countWithoutCopy(m.selected, unselected); n > 0 {
+		prompt += fmt.Sprintf("%d differ from every file left and their contents will be lost. ", n)
+	}
+	m.pending = &pendingInput{
+		input:  newLineInput(prompt+"(y/N) ", ""),
+		submit: model.deleteSelected,
+	}
+	return m
+}
+
+// countWithoutCopy counts the files that aren't byte-identical to any of others.
+// Files that can't be read count as having no copy.
+func countWithoutCopy(files, others []string) int {
+	var n int
+	for _, file := range files {
+		if !slices.ContainsFunc(others, func(other string) bool {
+			same, err := filesByteIdentical(file, other)
+			return err == nil && same
+		}) {
+			n++
+		}
+	}
+	return n
+}
+
+// deleteSelected deletes the selected files, or moves them to the quarantine,
+// once confirmed with y. With --hash, their checksums are verified first.
+func (m model) deleteSelected(_, answer string) model {
+	if !strings.EqualFold(strings.TrimSpace(answer), "y") {
+		m.status = "Nothing was deleted"
+		return m
+	}
+	files := slices.Clone(m.selected)
+	if err := m.checksums.Verify(context.Background(), files...); err != nil {
+		m.status = fmt.Sprintf("%v; nothing was changed", err)
+		return m
+	}
+
+	group := m.getCurrentGroup()
+	var removed []string
+	var note string
+	var err error
+	for _, file := range files {
+		var dest string
+		size := fileSize(file)
+		if dest, err = m.quarantine.Remove(file); err != nil {
+			break
+		}
+		if n := m.record(removalEntry(file, dest, m.quarantine, group), size); note == "" {
+			note = n
+		}
+		removed = append(removed, file)
+	}
+	m = m.dropFile(removed...)
+	if n := m.listLen(); m.cursor >= n {
+		m.cursor = max(n-1, 0)
+	}
+	m.summary = summarizeGroups(m.groups)
+
+	switch {
+	case err != nil && len(removed) == 0:
+		m.status = fmt.Sprintf("Error deleting file: %v", err)
+	case err != nil:
+		m.status = m.quarantine.Describe(fmt.Sprintf("%d of %d files", len(removed), len(files))) + fmt.Sprintf("; error deleting the rest: %v", err)
+	default:
+		m.status = m.quarantine.Describe(fmt.Sprintf("%d files", len(removed)))
+	}
+	m.status += note
+	return m
+}
+
+// hardlinkSelected replaces each selected file with a hard link to the
+// highlighted file. Files that are not byte-identical to it are left alone.
+// With --hash, the checksums of all of them are verified first.
+func (m model) hardlinkSelected() model {
+	keep, ok := m.highlightedFile()
+	if !ok {
+		return m
+	}
+	if slices.Contains(m.selected, keep) {
+		m.status = "Highlight the file to link to, one that is not selected"
+		return m
+	}
+	if m.hasArchiveMember(append([]string{keep}, m.selected...)...) {
+		m.status = archiveMemberRefusal
+		return m
+	}
+	if err := m.checksums.Verify(context.Background(), append([]string{keep}, m.selected...)...); err != nil {
+		m.status = fmt.Sprintf("%v; nothing was changed", err)
+		return m
+	}
+
+	group := m.getCurrentGroup()
+	var linked, differing int
+	var note string
+	var err error
+	for _, file := range m.selected {
+		if sameFile(keep, file) {
+			continue
+		}
+		if link := symlinkTo(group, file); link != "" {
+			err = fmt.Errorf("%s is a symbolic link to %s", m.displayName(link), m.displayName(file))
+			break
+		}
+		if identical, cmpErr := filesByteIdentical(keep, file); cmpErr != nil || !identical {
+			differing++
+			continue
+		}
+		var info os.FileInfo
+		if info, err = os.Stat(file); err == nil {
+			err = replaceWithHardlink(keep, file)
+		}
+		if err != nil {
+			break
+		}
+		if n := m.record(hardlinkEntry(keep, file, info, group), info.Size()); note == "" {
+			note = n
+		}
+		linked++
+	}
+	m.selected = nil
+	m.hardlinks = hardlinkPeers(group)
+	m.summary = summarizeGroups(m.groups)
+
+	status := fmt.Sprintf("Replaced %d files with hard links to %s", linked, m.displayName(keep))
+	if differing > 0 {
+		status += fmt.Sprintf("; %d differ from it and were left alone", differing)
+	}
+	if err != nil {
+		status += fmt.Sprintf("; error creating hard link: %v", err)
+	}
+	m.status = status + note
+	return m
+}
+
+// openMergeTool suspends the TUI and opens the selected pair in the external merge tool.
+// In the diff view the compared pair is used; while selecting the second file, the
+// first file and the highlighted file are used.
+func (m model) openMergeTool() (tea.Model, tea.Cmd) {
+	if m.mergeTool == nil {
+		return m, nil
+	}
+
+	var file1, file2 string
+	switch m.state {
+	case stateViewDiff:
+		file1, file2 = m.firstFile, m.secondFile
+	case stateSelectSecondFile:
+		group := m.getCurrentGroup()
+		if m.cursor >= len(group) || group[m.cursor] == m.firstFile {
+			return m, nil
+		}
+		file1, file2 = m.firstFile, group[m.cursor]
+	default:
+		return m, nil
+	}
+
+	cmd := m.mergeTool.Command(file1, file2)
+	return m, tea.ExecProcess(cmd, func(err error) tea.Msg {
+		return mergeToolFinishedMsg{err: err}
+	})
+}
+
+// explainPair opens the explanation of why the compared pair, or the first
+// file and the highlighted one, are grouped, judged with the scan options.
+func (m model) explainPair() model {
+	var file1, file2 string
+	switch m.state {
+	case stateViewDiff:
+		file1, file2 = m.firstFile, m.secondFile
+	case stateSelectSecondFile:
+		file, ok := m.highlightedFile()
+		if !ok || file == m.firstFile {
+			return m
+		}
+		file1, file2 = m.firstFile, file
+	default:
+		return m
+	}
+	if m.scanOpts.compareDir != "" {
+		m.status = "Files are paired by their path in --compare mode"
+		return m
+	}
+	m.explanation = explainPair(m.scanOpts, file1, file2, m.getCurrentGroup()).lines
+	return m
+}
+
+// diffCommitted compares the last committed version of the highlighted file
+// with the file, to see the changes made to it since.
+func (m model) diffCommitted() model {
+	file, ok := m.highlightedFile()
+	if !ok {
+		return m
+	}
+	return m.diffRevision(file, "HEAD", file)
+}
+
+// diffRevision compares source as committed in rev with file.
+func (m model) diffRevision(source, rev, file string) model {
+	revision, err := m.git.CommittedVersion(context.Background(), source, rev)
+	if err != nil {
+		m.status = fmt.Sprintf("Can't diff against the committed version: %v", err)
+		return m
+	}
+	m = m.showPair(revision, file)
+	m.revisionFile = revision
+	return m
+}
+
+// openRevisionMenu lists the recent revisions of the highlighted file to diff
+// it against. A copy that was never committed is diffed against the revisions
+// of the first file of its group that was, to see whether it holds edits that
+// never made it into the history.
+func (m model) openRevisionMenu() model {
+	file, ok := m.highlightedFile()
+	if !ok {
+		return m
+	}
+	if m.git == nil {
+		m.status = "Not inside a git repository"
+		return m
+	}
+	others := slices.DeleteFunc(slices.Clone(m.getCurrentGroup()), func(f string) bool { return f == file })
+	for _, source := range append([]string{file}, others...) {
+		revisions, err := m.git.Revisions(context.Background(), source, maxRevisions)
+		if err != nil {
+			m.status = err.Error()
+			return m
+		}
+		if len(revisions) > 0 {
+			m.revisionMenu = &revisionMenu{file: file, source: source, revisions: revisions}
+			return m
+		}
+	}
+	m.status = fmt.Sprintf("No file of the group of %s was ever committed", m.displayName(file))
+	return m
+}
+
+// updateRevisionMenu handles a key while the revision list is open: the arrows
+// move between revisions, Enter diffs against one, and Esc closes the list.
+func (m model) updateRevisionMenu(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	menu := *m.revisionMenu
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc", "q":
+		m.revisionMenu = nil
+	case "up", "k":
+		menu.cursor = max(menu.cursor-1, 0)
+		m.revisionMenu = &menu
+	case "down", "j":
+		menu.cursor = min(menu.cursor+1, len(menu.revisions)-1)
+		m.revisionMenu = &menu
+	case "enter":
+		m.revisionMenu = nil
+		return m.diffRevision(menu.source, menu.revisions[menu.cursor].Hash, menu.file), nil
+	}
+	return m, nil
+}
+
+// openActionMenu opens the menu of custom actions for the highlighted file, or
+// in the group list for the first file of the highlighted group.
+func (m model) openActionMenu() model {
+	var file string
+	var group []string
+	switch m.state {
+	case stateSelectGroup:
+		if m.cursor >= len(m.groups) {
+			return m
+		}
+		group = m.groups[m.cursor]
+		file = group[0]
+	case stateSelectFirstFile, stateSelectSecondFile:
+		var ok bool
+		if file, ok = m.highlightedFile(); !ok {
+			return m
+		}
+		group = m.getCurrentGroup()
+	default:
+		return m
+	}
+	if len(m.actions) == 0 {
+		m.status = "No actions configured; add them to the actions list of the config file"
+		return m
+	}
+	m.actionMenu = &actionMenu{file: file, group: group}
+	return m
+}
+
+// updateActionMenu handles a key while the action menu is open: the arrows
+// move between actions, Enter or a digit runs one, and Esc closes the menu.
+func (m model) updateActionMenu(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	menu := *m.actionMenu
+	key := msg.String()
+	switch key {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc", "q":
+		m.actionMenu = nil
+	case "up", "k":
+		menu.cursor = max(menu.cursor-1, 0)
+		m.actionMenu = &menu
+	case "down", "j":
+		menu.cursor = min(menu.cursor+1, len(m.actions)-1)
+		m.actionMenu = &menu
+	case "enter":
+		return m.runAction(menu.cursor)
+	default:
+		if len(key) == 1 && key >= "1" && key <= "9" {
+			if i := int(key[0] - '1'); i < len(m.actions) {
+				return m.runAction(i)
+			}
+		}
+	}
+	return m, nil
+}
+
+// runAction closes the action menu and runs action i on its file, handing the
+// terminal to the command until it exits.
+func (m model) runAction(i int) (tea.Model, tea.Cmd) {
+	menu := m.actionMenu
+	m.actionMenu = nil
+	action := m.actions[i]
+	cmd := action.Command(menu.file, menu.group)
+	return m, tea.ExecProcess(cmd, func(err error) tea.Msg {
+		return fileOpenedMsg{status: describeActionResult(action.Name, err)}
+	})
+}
+
+// highlightedFile returns the file under the cursor in the file selection views
+func (m model) highlightedFile() (string, bool) {
+	if m.state != stateSelectFirstFile && m.state != stateSelectSecondFile {
+		return "", false
+	}
+	group := m.getCurrentGroup()
+	if m.cursor >= len(group) {
+		return "", false
+	}
+	return group[m.cursor], true
+}
+
+// openInEditor suspends the TUI and opens the highlighted file in $VISUAL or $EDITOR
+func (m model) openInEditor() (tea.Model, tea.Cmd) {
+	file, ok := m.highlightedFile()
+	if !ok {
+		return m, nil
+	}
+
+	cmd, err := editorCommand(file)
+	if err != nil {
+		m.status = err.Error()
+		return m, nil
+	}
+	name := filepath.Base(cmd.Args[0])
+	return m, tea.ExecProcess(cmd, func(err error) tea.Msg {
+		return fileOpenedMsg{status: describeMergeToolResult(name, err)}
+	})
+}
+
+// canPreviewImages reports whether the compared pair can be shown with showImagePreviews.
+func (m model) canPreviewImages() bool {
+	return m.imagePreview && m.state == stateViewDiff &&
+		handlerFor(m.firstFile) == imageHandler && handlerFor(m.secondFile) == imageHandler
+}
+
+// showImagePreviews suspends the TUI and draws the compared image pair with the
+// kitty graphics protocol, returning when Enter is pressed.
+func (m model) showImagePreviews() (tea.Model, tea.Cmd) {
+	if !m.canPreviewImages() {
+		return m, nil
+	}
+	if !supportsKittyGraphics() {
+		m.status = "Image previews need a terminal with the kitty graphics protocol (kitty, WezTerm)"
+		return m, nil
+	}
+	preview := &kittyPreview{files: [2]string{m.firstFile, m.secondFile}}
+	return m, tea.Exec(preview, func(err error) tea.Msg {
+		if err != nil {
+			return fileOpenedMsg{status: fmt.Sprintf("Error showing image previews: %v", err)}
+		}
+		return fileOpenedMsg{}
+	})
+}
+
+// openInViewer opens the highlighted file in the system's default application.
+// The TUI keeps running since the viewer opens in its own window.
+func (m model) openInViewer() (tea.Model, tea.Cmd) {
+	file, ok := m.highlightedFile()
+	if !ok {
+		return m, nil
+	}
+
+	cmd := systemOpenCommand(file)
+	name := cmd.Args[0]
+	return m, func() tea.Msg {
+		if err := cmd.Run(); err != nil {
+			return fileOpenedMsg{status: describeMergeToolResult(name, err)}
+		}
+		return fileOpenedMsg{status: fmt.Sprintf("Opened %s with %s", m.displayName(file), name)}
+	}
+}
+
+// copyPath copies the absolute path of the highlighted file to the clipboard.
+func (m model) copyPath() (tea.Model, tea.Cmd) {
+	file, ok := m.highlightedFile()
+	if !ok {
+		return m, nil
+	}
+
+	path := absPath(file)
+	return m, func() tea.Msg {
+		method, err := copyToClipboard(path, os.Stdout)
+		if err != nil {
+			return fileOpenedMsg{status: fmt.Sprintf("Error copying path: %v", err)}
+		}
+		return fileOpenedMsg{status: fmt.Sprintf("Copied %s to the clipboard (%s)", path, method)}
+	}
+}
+
+// pendingInput is a line of text being entered for file; submit acts on the
+// value once Enter is pressed.
+type pendingInput struct {
+	input  lineInput
+	file   string
+	submit func(m model, file, value string) model
+	// complete, if set, is called on Tab to complete the value; it returns the
+	// completed value and the candidates, which are listed while several remain.
+	complete func(value string) (string, []string)
+	hint     string
+}
+
+// updatePending passes a key to the pending input. Enter submits it, Esc
+// cancels it, and Ctrl+C still quits.
+func (m model) updatePending(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	p := *m.pending
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc":
+		m.pending = nil
+	case "enter":
+		m.pending = nil
+		m = p.submit(m, p.file, p.input.Value())
+	case "tab":
+		if p.complete != nil {
+			value, candidates := p.complete(p.input.Value())
+			p.input = newLineInput(p.input.prompt, value)
+			p.hint = ""
+			if len(candidates) > 1 {
+				p.hint = strings.Join(candidates, "  ")
+			}
+			m.pending = &p
+		}
+	default:
+		p.input = p.input.update(msg)
+		m.pending = &p
+	}
+	return m, nil
+}
+
+// hasArchiveMember reports whether any of files is inside an archive, or
+// extracted from one, and so can't be changed.
+func (m model) hasArchiveMember(files ...string) bool {
+	return slices.ContainsFunc(files, func(f string) bool { return isArchiveMember(m.archives.Virtual(f)) })
+}
+
+// startRename asks for a new name for the highlighted file, pre-filled with its
+// current one.
+func (m model) startRename() model {
+	file, ok := m.highlightedFile()
+	if !ok {
+		return m
+	}
+	if m.hasArchiveMember(file) {
+		m.status = archiveMemberRefusal
+		return m
+	}
+	m.pending = &pendingInput{
+		input:  newLineInput("Rename to: ", filepath.Base(file)),
+		file:   file,
+		submit: model.renameFile,
+	}
+	return m
+}
+
+// renameFile renames file to name within its directory, refusing to replace an
+// existing file, and updates the group in place.
+func (m model) renameFile(file, name string) model {
+	if name == filepath.Base(file) {
+		return m
+	}
+	if err := checkFileName(name); err != nil {
+		m.status = err.Error()
+		return m
+	}
+	target := filepath.Join(filepath.Dir(file), name)
+	if _, err := os.Lstat(target); err == nil {
+		m.status = fmt.Sprintf("%s already exists; nothing was renamed", name)
+		return m
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		m.status = fmt.Sprintf("Error renaming file: %v", err)
+		return m
+	}
+	if err := os.Rename(file, target); err != nil {
+		m.status = fmt.Sprintf("Error renaming file: %v", err)
+		return m
+	}
+
+	m = m.replaceFile(file, target)
+	m.status = fmt.Sprintf("Renamed %s to %s", filepath.Base(file), name)
+	m.status += m.record(JournalEntry{Action: JournalRename, Path: file, Target: target}, 0)
+	return m
+}
+
+// startMove asks for a directory to move the highlighted file to, or the
+// selected files if there are any, pre-filled with the last one used. Tab
+// completes directory names.
+func (m model) startMove() model {
+	if m.state == stateSelectFirstFile && len(m.selected) > 0 {
+		if m.hasArchiveMember(m.selected...) {
+			m.status = archiveMemberRefusal
+			return m
+		}
+		m.pending = &pendingInput{
+			input: newLineInput(fmt.Sprintf("Move %d selected files to: ", len(m.selected)), m.lastMoveDir),
+			submit: func(m model, _, dest string) model {
+				files := m.selected
+				m.selected = nil
+				return m.moveFilesTo(files, dest)
+			},
+			complete: completeDir,
+		}
+		return m
+	}
+	file, ok := m.highlightedFile()
+	if !ok {
+		return m
+	}
+	if m.hasArchiveMember(file) {
+		m.status = archiveMemberRefusal
+		return m
+	}
+	m.pending = &pendingInput{
+		input:    newLineInput("Move to: ", m.lastMoveDir),
+		file:     file,
+		submit:   model.moveFileTo,
+		complete: completeDir,
+	}
+	return m
+}
+
+// moveFileTo moves file into the directory dest; see moveFilesTo.
+func (m model) moveFileTo(file, dest string) model {
+	return m.moveFilesTo([]string{file}, dest)
+}
+
+// moveFilesTo moves files into the directory dest, creating it if needed. Files
+// moved out of the scanned directory, such as into a quarantine folder, leave
+// their group; otherwise the group is updated with the new paths. Moving stops
+// at the first file that can't be moved.
+func (m model) moveFilesTo(files []string, dest string) model {
+	dest = strings.TrimSpace(dest)
+	if dest == "" {
+		m.status = "No destination given; nothing was moved"
+		return m
+	}
+	dir, err := filepath.Abs(expandHome(dest))
+	if err != nil {
+		m.status = fmt.Sprintf("Error moving file: %v", err)
+		return m
+	}
+
+	var moved, left []string
+	var note string
+	for _, file := range files {
+		target := filepath.Join(dir, filepath.Base(file))
+		if err = moveFile(file, target); err != nil {
+			break
+		}
+		if n := m.record(JournalEntry{Action: JournalMove, Path: file, Target: target, Group: m.getCurrentGroup()}, 0); note == "" {
+			note = n
+		}
+		moved = append(moved, file)
+		if m.displayRoot != "" && !pathWithin(m.displayRoot, target) {
+			left = append(left, file)
+		} else {
+			m = m.replaceFile(file, target)
+		}
+	}
+	if len(moved) > 0 {
+		m.lastMoveDir = dest
+	}
+	if len(left) > 0 {
+		m = m.dropFile(left...)
+		if n := m.listLen(); m.cursor >= n {
+			m.cursor = max(n-1, 0)
+		}
+	}
+	m.summary = summarizeGroups(m.groups)
+
+	switch {
+	case err != nil && len(moved) == 0:
+		m.status = fmt.Sprintf("Error moving file: %v", err)
+	case err != nil:
+		m.status = fmt.Sprintf("Moved %d of %d files to %s; error moving the rest: %v", len(moved), len(files), dir, err)
+	case len(files) == 1:
+		m.status = fmt.Sprintf("Moved %s to %s", m.displayName(files[0]), dir)
+	default:
+		m.status = fmt.Sprintf("Moved %d files to %s", len(files), dir)
+	}
+	m.status += note
+	return m
+}
 
-		case "esc":
-			return m.handleEscape()
+// record adds e to the session journal and to the session totals with the
+// size it freed. It returns a note for the status line if the journal failed,
+// since the operation then can't be undone.
+func (m model) record(e JournalEntry, size int64) string {
+	m.session.Record(e, size)
+	if err := m.journal.Record(e); err != nil {
+		return fmt.Sprintf(" (can't be undone: %v)", err)
+	}
+	return ""
+}
 
-		case "n":
-			if m.state == stateSelectGroup {
-				if m.currentGroup < len(m.groups)-1 {
-					m.currentGroup++
-					m.cursor = 0
-				}
-				return m, nil
-			}
-			return m, nil
+// reviewedGroups returns how many of the groups are marked reviewed.
+func (m model) reviewedGroups() int {
+	n := 0
+	for _, group := range m.groups {
+		if m.reviewed[groupFingerprint("", group)] {
+			n++
 		}
 	}
+	return n
+}
+
+// undo reverses the last operation in the session journal and updates the
+// groups: renamed and moved files get their old path back, and restored files
+// rejoin their group.
+func (m model) undo() model {
+	e, err := m.journal.Undo()
+	if errors.Is(err, errNothingToUndo) {
+		m.status = "Nothing to undo"
+		return m
+	}
+	if err != nil {
+		m.status = fmt.Sprintf("Could not undo: %v", err)
+		return m
+	}
 
-	return m, nil
+	switch {
+	case e.Action == JournalHardlink:
+	case (e.Action == JournalRename || e.Action == JournalMove) && slices.ContainsFunc(m.groups, func(g []string) bool { return slices.Contains(g, e.Target) }):
+		m = m.replaceFile(e.Target, e.Path)
+	default:
+		m = m.rejoinFile(e.Path, e.Group)
+	}
+	if m.state != stateSelectGroup {
+		m.hardlinks = hardlinkPeers(m.getCurrentGroup())
+	}
+	if m.state == stateViewDiff {
+		m.hardlinked = sameFile(m.firstFile, m.secondFile)
+	}
+	m.session.Undo(e)
+	m.summary = summarizeGroups(m.groups)
+	m.status = "Undone: " + e.String()
+	return m
 }
 
-// handleEnter handles the enter key press
-func (m model) handleEnter() (tea.Model, tea.Cmd) {
-	switch m.state {
-	case stateSelectGroup:
-		if len(m.groups) == 0 {
-			return m, tea.Quit
+// rejoinFile adds a restored file back to the group that holds the rest of
+// original, its group when it was removed. If that group was dissolved, it is
+// added again at the end of the list from the files still present.
+func (m model) rejoinFile(file string, original []string) model {
+	groups := append([][]string{}, m.groups...)
+	for i, group := range groups {
+		if slices.ContainsFunc(original, func(f string) bool { return f != file && slices.Contains(group, f) }) {
+			groups[i] = rejoinGroup(group, file, original)
+			m.groups = groups
+			return m
 		}
-		// Update currentGroup to match the selected group (cursor position)
-		m.currentGroup = m.cursor
-		m.state = stateSelectFirstFile
-		m.cursor = 0
-		return m, nil
+	}
 
-	case stateSelectFirstFile:
-		group := m.getCurrentGroup()
-		if m.cursor < len(group) {
-			m.firstFile = group[m.cursor]
-			m.state = stateSelectSecondFile
-			// Set cursor to first available file (skip the selected first file)
-			m.cursor = 0
-			if m.cursor < len(group) && group[m.cursor] == m.firstFile {
-				if len(group) > 1 {
-					m.cursor = 1
-				}
-			}
+	var present []string
+	for _, f := range original {
+		if _, err := os.Stat(f); err == nil {
+			present = append(present, f)
 		}
-		return m, nil
+	}
+	if len(present) >= 2 {
+		m.groups = append(groups, present)
+	}
+	return m
+}
 
-	case stateSelectSecondFile:
-		group := m.getCurrentGroup()
-		if m.cursor < len(group) {
-			selectedFile := group[m.cursor]
-			if selectedFile == m.firstFile {
-				// Can't select the same file
-				return m, nil
-			}
-			m.secondFile = selectedFile
-			// Generate diff
-			diff, err := m.diffExec.DiffSideBySide(m.firstFile, m.secondFile)
-			if err != nil {
-				m.diffOutput = fmt.Sprintf("Error generating diff: %v", err)
-			} else {
-				m.diffOutput = diff
-			}
-			m.state = stateViewDiff
+// checkFileName reports why name can't be used as a file name within a
+// directory, or returns nil if it can.
+func checkFileName(name string) error {
+	switch {
+	case strings.TrimSpace(name) == "":
+		return errors.New("the new name is empty")
+	case name == "." || name == "..":
+		return fmt.Errorf("%q is not a file name", name)
+	case strings.ContainsRune(name, '/') || strings.ContainsRune(name, filepath.Separator) || strings.ContainsRune(name, 0):
+		return fmt.Errorf("%q must not contain a path separator", name)
+	}
+	return nil
+}
+
+// replaceFile updates every reference to file after it was renamed to target:
+// its group, the selected and marked files, and pending compare-all pairs.
+// Markers kept by group, such as reviewed, move to the renamed group.
+func (m model) replaceFile(file, target string) model {
+	rename := func(f string) string {
+		if f == file {
+			return target
 		}
-		return m, nil
+		return f
+	}
 
-	case stateViewDiff:
-		// After viewing diff, go back to selecting first file
-		m.state = stateSelectFirstFile
-		m.firstFile = ""
-		m.secondFile = ""
-		m.diffOutput = ""
-		m.cursor = 0
-		return m, nil
+	groups := append([][]string{}, m.groups...)
+	for i, group := range groups {
+		if !slices.Contains(group, file) {
+			continue
+		}
+		renamed := make([]string, len(group))
+		for j, f := range group {
+			renamed[j] = rename(f)
+		}
+		oldKey, newKey := groupFingerprint("", group), groupFingerprint("", renamed)
+		m.reviewed[newKey], m.expanded[newKey] = m.reviewed[oldKey], m.expanded[oldKey]
+		groups[i] = renamed
 	}
+	m.groups = groups
 
-	return m, nil
+	m.firstFile, m.secondFile, m.baseFile = rename(m.firstFile), rename(m.secondFile), rename(m.baseFile)
+	m.variants = slices.Clone(m.variants)
+	for i, f := range m.variants {
+		m.variants[i] = rename(f)
+	}
+	m.marked = slices.Clone(m.marked)
+	for i, f := range m.marked {
+		m.marked[i] = rename(f)
+	}
+	m.selected = slices.Clone(m.selected)
+	for i, f := range m.selected {
+		m.selected[i] = rename(f)
+	}
+	m.comparePairs = slices.Clone(m.comparePairs)
+	for i, pair := range m.comparePairs {
+		m.comparePairs[i] = [2]string{rename(pair[0]), rename(pair[1])}
+	}
+	return m
 }
 
 // handleEscape handles the escape key press
 func (m model) handleEscape() (tea.Model, tea.Cmd) {
 	switch m.state {
+	case stateSelectGroup:
+		if m.mergeSource != "" {
+			m.mergeSource = ""
+			m.status = "Merge cancelled"
+		}
+		return m, nil
+
 	case stateSelectFirstFile:
+		if len(m.selected) > 0 {
+			m.selected = nil
+			return m, nil
+		}
 		// Go back to group selection
 		m.state = stateSelectGroup
+		m.marked = nil
 		m.cursor = 0
 		return m, nil
 
@@ -222,10 +2392,33 @@ func (m model) handleEscape() (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case stateViewDiff:
+		if m.diffJob != nil {
+			m.status = "Diff cancelled"
+		} else if m.diffSearch != "" {
+			// The first Esc only ends the search
+			m.diffSearch, m.diffMatches = "", nil
+			m.status = "Search cleared"
+			return m, nil
+		}
+		if m.revisionFile != "" {
+			// File 1 isn't in the group, so go back to the file compared with it
+			file := m.secondFile
+			m = m.returnToFileSelection()
+			m.cursor = max(slices.Index(m.getCurrentGroup(), file), 0)
+			return m, nil
+		}
+		m = m.closeDiff()
+		if m.comparePairs != nil {
+			return m.returnToFileSelection(), nil
+		}
 		// Go back to second file selection
 		m.state = stateSelectSecondFile
+		m.firstFile = m.archives.Virtual(m.firstFile)
 		m.secondFile = ""
+		m.baseFile = ""
+		m.variants = nil
 		m.diffOutput = ""
+		m.identical = false
 		m.cursor = 0
 		return m, nil
 	}
@@ -246,10 +2439,29 @@ func (m model) View() string {
 	if m.width == 0 {
 		return "Loading..."
 	}
+	if m.showKeys {
+		return renderKeyHelp(m.state, m.width, m.height)
+	}
+	if m.explanation != nil {
+		return renderExplanation(m.explanation, m.width)
+	}
+	if m.actionMenu != nil {
+		return renderActionMenu(m.actionMenu, m.actions, m.displayName(m.actionMenu.file), m.width)
+	}
+	if m.revisionMenu != nil {
+		return renderRevisionMenu(m.revisionMenu, m.displayName, m.width, m.height)
+	}
 
 	var s strings.Builder
+	if m.state != stateLoading {
+		s.WriteString(m.renderBreadcrumb())
+		s.WriteString("\n\n")
+	}
 
 	switch m.state {
+	case stateLoading:
+		s.WriteString(m.renderLoading())
+
 	case stateSelectGroup:
 		s.WriteString(m.renderGroupSelection())
 
@@ -264,11 +2476,89 @@ func (m model) View() string {
 	}
 
 	s.WriteString("\n\n")
+	if m.pending != nil {
+		s.WriteString(m.pending.input.View())
+		s.WriteString("\n")
+		if m.pending.hint != "" {
+			s.WriteString(helpStyle.Render(m.pending.hint))
+			s.WriteString("\n")
+		}
+		help := "Enter: confirm  Esc: cancel"
+		if m.pending.complete != nil {
+			help = "Tab: complete  " + help
+		}
+		s.WriteString(helpStyle.Render(help))
+		return s.String()
+	}
+	if len(m.selected) > 0 && m.state == stateSelectFirstFile {
+		s.WriteString(selectedStyle.Render(m.selectionStatus()))
+		s.WriteString("\n")
+	}
+	if m.status != "" {
+		s.WriteString(helpStyle.Render(m.status))
+		s.WriteString("\n")
+	}
+	if m.state != stateLoading {
+		s.WriteString(m.renderStatusBar())
+		s.WriteString("\n")
+	}
 	s.WriteString(m.renderHelp())
 
 	return s.String()
 }
 
+// breadcrumb returns the way to the current view from the group list, such as
+// "Groups", the group's title, and "notes.md vs notes 2.md". Esc goes back
+// towards the group list one step at a time.
+func (m model) breadcrumb() []string {
+	crumbs := []string{"Groups"}
+	if m.state == stateSelectGroup || m.currentGroup >= len(m.groups) {
+		return crumbs
+	}
+	crumbs = append(crumbs, m.groupTitle(m.currentGroup))
+	switch m.state {
+	case stateSelectSecondFile:
+		crumbs = append(crumbs, m.displayName(m.firstFile))
+	case stateViewDiff:
+		pair := m.displayName(m.firstFile) + " vs " + m.displayName(m.secondFile)
+		switch {
+		case m.baseFile != "":
+			variants := make([]string, len(m.variants))
+			for i, file := range m.variants {
+				variants[i] = m.displayName(file)
+			}
+			pair = strings.Join(variants, ", ") + " against " + m.displayName(m.baseFile)
+		case m.comparePairs != nil:
+			pair += fmt.Sprintf(" (pair %d of %d)", m.pairIndex+1, len(m.comparePairs))
+		}
+		crumbs = append(crumbs, pair)
+	}
+	return crumbs
+}
+
+// renderBreadcrumb renders the breadcrumb with the current view last and
+// highlighted. A breadcrumb wider than the terminal is cut instead.
+func (m model) renderBreadcrumb() string {
+	crumbs := m.breadcrumb()
+	const sep = " ▸ "
+	if line := strings.Join(crumbs, sep); m.width > 0 && lipgloss.Width(line) > m.width {
+		return titleStyle.Render(truncateLine(line, m.width))
+	}
+	last := len(crumbs) - 1
+	var s strings.Builder
+	for _, crumb := range crumbs[:last] {
+		s.WriteString(helpStyle.Render(crumb + sep))
+	}
+	s.WriteString(titleStyle.Render(crumbs[last]))
+	return s.String()
+}
+
+// renderLoading renders the loading screen shown while the directory is scanned
+func (m model) renderLoading() string {
+	frame := spinnerFrames[m.spinner%len(spinnerFrames)]
+	return titleStyle.Render(fmt.Sprintf("%s %s", frame, m.progress))
+}
+
 // renderGroupSelection renders the group selection view
 func (m model) renderGroupSelection() string {
 	var s strings.Builder
@@ -278,10 +2568,14 @@ func (m model) renderGroupSelection() string {
 		return s.String()
 	}
 
-	s.WriteString(titleStyle.Render(fmt.Sprintf("Found %d group(s) of similar files", len(m.groups))))
+	s.WriteString(fitWidth(titleStyle.Render(fmt.Sprintf("Found %d group(s) of similar files", len(m.groups))), m.width))
+	s.WriteString("\n")
+	s.WriteString(fitWidth(helpStyle.Render(m.summary.String()), m.width))
 	s.WriteString("\n\n")
 
-	for i, group := range m.groups {
+	start, end := m.groupRange()
+	for i := start; i < end; i++ {
+		group := m.groups[i]
 		style := normalStyle
 		if i == m.cursor {
 			style = selectedStyle
@@ -294,62 +2588,133 @@ func (m model) renderGroupSelection() string {
 		}
 
 		// Show group number and file count - apply style only to the text, not the prefix
-		groupText := fmt.Sprintf("Group %d: %d files", i+1, len(group))
-		s.WriteString(prefix)
-		s.WriteString(style.Render(groupText))
+		line := prefix + style.Render(m.groupTitle(i))
+		if m.reviewed[groupFingerprint("", group)] {
+			line += helpStyle.Render("  ✓ reviewed")
+		}
+		if m.mergeSource != "" && m.mergeSource == groupFingerprint("", group) {
+			line += helpStyle.Render("  ⇢ merging")
+		}
+		s.WriteString(fitWidth(line, m.width))
 		s.WriteString("\n")
-		
-		// Show the filenames in this group
-		var filenames []string
-		for _, file := range group {
-			filenames = append(filenames, filepath.Base(file))
-		}
-		// Use consistent indentation for file list (4 spaces to align with group text)
-		indent := "    "
-		// Calculate available width (account for indent and some margin)
-		availableWidth := m.width - len(indent) - 2
-		if availableWidth < 20 {
-			availableWidth = 20 // Minimum width
-		}
-		
-		// Build wrapped file list
-		currentLine := ""
-		for i, filename := range filenames {
-			// Add comma and space if not first item
-			item := filename
-			if i > 0 {
-				item = ", " + filename
-			}
-			
-			// Check if adding this item would exceed the width
-			if len(currentLine)+len(item) > availableWidth && currentLine != "" {
-				// Write current line and start new line
-				s.WriteString(indent)
-				s.WriteString(helpStyle.Render(currentLine))
+
+		// Expanded groups list their files, indented to align with the group text
+		if m.expanded[groupFingerprint("", group)] {
+			for _, line := range m.groupFileLines(group) {
+				s.WriteString(fitWidth("    "+helpStyle.Render(line), m.width))
 				s.WriteString("\n")
-				currentLine = filename
-			} else {
-				// Append to current line
-				if currentLine == "" {
-					currentLine = filename
-				} else {
-					currentLine += ", " + filename
-				}
 			}
 		}
-		
-		// Write the last line if there's content
-		if currentLine != "" {
-			s.WriteString(indent)
-			s.WriteString(helpStyle.Render(currentLine))
-			s.WriteString("\n")
-		}
 		s.WriteString("\n")
 	}
+	s.WriteString(m.renderRangeIndicator(start, end, len(m.groups)))
 
 	return s.String()
 }
 
+// groupFileLines returns the names of the files in group as comma-separated
+// lines wrapped to the terminal width.
+func (m model) groupFileLines(group []string) []string {
+	// Calculate available width (account for indent and some margin)
+	availableWidth := max(m.width-6, 20)
+
+	keeper := m.suggestedKeeper(group)
+	var lines []string
+	currentLine := ""
+	for _, file := range group {
+		filename := m.displayName(file)
+		if file == keeper {
+			filename += " ★"
+		}
+		switch {
+		case currentLine == "":
+			currentLine = filename
+		case len(currentLine)+len(", "+filename) > availableWidth:
+			lines = append(lines, currentLine)
+			currentLine = filename
+		default:
+			currentLine += ", " + filename
+		}
+	}
+	if currentLine != "" {
+		lines = append(lines, currentLine)
+	}
+	return lines
+}
+
+// toggleExpanded shows or hides the file list of the highlighted group.
+func (m model) toggleExpanded() model {
+	if m.state != stateSelectGroup || m.cursor >= len(m.groups) {
+		return m
+	}
+	key := groupFingerprint("", m.groups[m.cursor])
+	m.expanded[key] = !m.expanded[key]
+	return m
+}
+
+// toggleExpandAll expands every group, or collapses them all if they already are.
+func (m model) toggleExpandAll() model {
+	if m.state != stateSelectGroup {
+		return m
+	}
+	allExpanded := true
+	for _, group := range m.groups {
+		if !m.expanded[groupFingerprint("", group)] {
+			allExpanded = false
+			break
+		}
+	}
+	m.expanded = make(map[string]bool)
+	if !allExpanded {
+		for _, group := range m.groups {
+			m.expanded[groupFingerprint("", group)] = true
+		}
+	}
+	return m
+}
+
+// groupRange returns the half-open range of groups on the page holding the
+// cursor. Groups are collapsed to their title unless expanded, so pages are
+// filled by the lines each group takes rather than a fixed count, and always
+// hold at least one group.
+func (m model) groupRange() (int, int) {
+	// Leave room for the breadcrumb, title, summary, range indicator, status bar,
+	// and help lines
+	available := max(m.height-10-m.extraHelpLines(), 1)
+	start, used := 0, 0
+	for i, group := range m.groups {
+		// A title line and a blank line, plus the file list when expanded
+		lines := 2
+		if m.expanded[groupFingerprint("", group)] {
+			lines += len(m.groupFileLines(group))
+		}
+		if used > 0 && used+lines > available {
+			if i > m.cursor {
+				return start, i
+			}
+			start, used = i, 0
+		}
+		used += lines
+	}
+	return start, len(m.groups)
+}
+
+// visibleRange returns the half-open range of list items shown on the current page
+func (m model) visibleRange(n int) (int, int) {
+	page := m.pageSize()
+	start := (m.cursor / page) * page
+	end := min(start+page, n)
+	return start, end
+}
+
+// renderRangeIndicator describes which items are visible when a list doesn't fit on screen
+func (m model) renderRangeIndicator(start, end, n int) string {
+	if start == 0 && end == n {
+		return ""
+	}
+	return helpStyle.Render(fmt.Sprintf("Showing %d-%d of %d", start+1, end, n))
+}
+
 // renderFileSelection renders the file selection view
 func (m model) renderFileSelection(prompt string) string {
 	var s strings.Builder
@@ -359,11 +2724,18 @@ func (m model) renderFileSelection(prompt string) string {
 		return "No files in group."
 	}
 
-	s.WriteString(titleStyle.Render(fmt.Sprintf("Group %d: %d files\n\n", m.currentGroup+1, len(group))))
 	s.WriteString(titleStyle.Render(prompt))
 	s.WriteString("\n\n")
 
-	for i, file := range group {
+	// Lines are cut rather than wrapped by the terminal or the preview pane
+	width := m.width
+	if side, lines, previewWidth := m.previewLayout(); side && lines > 0 {
+		width -= previewWidth + 2
+	}
+	keeper := m.suggestedKeeper(group)
+	start, end := m.visibleRange(len(group))
+	for i := start; i < end; i++ {
+		file := group[i]
 		style := normalStyle
 		if i == m.cursor {
 			style = selectedStyle
@@ -373,66 +2745,306 @@ func (m model) renderFileSelection(prompt string) string {
 		if i == m.cursor {
 			prefix = "> "
 		}
+		if len(m.selected) > 0 && m.state == stateSelectFirstFile {
+			if slices.Contains(m.selected, file) {
+				prefix += "[x] "
+			} else {
+				prefix += "[ ] "
+			}
+		}
 
-		filename := filepath.Base(file)
+		var line strings.Builder
+		filename := m.displayName(file)
 		// Skip the first file if we're selecting the second file
 		if m.state == stateSelectSecondFile && file == m.firstFile {
 			// Show it but make it clear it's already selected
-			s.WriteString(helpStyle.Render(fmt.Sprintf("%s%s (already selected as first file)", prefix, filename)))
+			line.WriteString(helpStyle.Render(fmt.Sprintf("%s%s (already selected as first file)", prefix, filename)))
 		} else {
-			s.WriteString(style.Render(fmt.Sprintf("%s%s", prefix, filename)))
+			line.WriteString(style.Render(fmt.Sprintf("%s%s", prefix, filename)))
+		}
+		if sum := m.checksums.Short(file); sum != "" {
+			line.WriteString(helpStyle.Render("  " + sum))
+		}
+		if m.gitStatus {
+			if status := m.git.Status(file); status != "" {
+				line.WriteString(helpStyle.Render("  git: " + status))
+			}
+		}
+		if i < len(m.hardlinks) && m.hardlinks[i] >= 0 {
+			line.WriteString(helpStyle.Render(fmt.Sprintf("  (hard link of %s)", m.displayName(group[m.hardlinks[i]]))))
+		}
+		if n := m.markIndex(file); n > 0 && m.state == stateSelectFirstFile {
+			line.WriteString(helpStyle.Render(fmt.Sprintf("  [%d]", n)))
+		}
+		if file == keeper {
+			line.WriteString(suggestStyle.Render("  ★ likely keeper"))
 		}
+		s.WriteString(fitWidth(line.String(), width))
 		s.WriteString("\n")
 	}
+	s.WriteString(m.renderRangeIndicator(start, end, len(group)))
 
 	if m.state == stateSelectSecondFile && m.firstFile != "" {
 		s.WriteString("\n")
-		s.WriteString(helpStyle.Render(fmt.Sprintf("First file: %s", filepath.Base(m.firstFile))))
+		s.WriteString(fitWidth(helpStyle.Render(fmt.Sprintf("First file: %s", m.displayName(m.firstFile))), width))
 	}
 
-	return s.String()
+	return m.renderWithPreview(s.String())
+}
+
+// previewLayout decides where the file preview goes: beside the list on wide
+// terminals, below it otherwise. Returns lines=0 when the terminal is too small.
+func (m model) previewLayout() (side bool, lines, width int) {
+	if m.height < previewMinHeight {
+		return false, 0, 0
+	}
+	if m.width >= previewSideMinWidth {
+		return true, m.height - 9 - m.extraHelpLines(), m.width/2 - 2
+	}
+	return false, m.height / 3, m.width
+}
+
+// previewKey identifies a preview: the file and the size it is cut to.
+type previewKey struct {
+	file         string
+	lines, width int
+}
+
+// previewMsg delivers a preview loaded in the background.
+type previewMsg struct {
+	key  previewKey
+	text string
+}
+
+// previewKey returns the key of the preview the file list shows, if any.
+func (m model) previewKey() (previewKey, bool) {
+	file, ok := m.highlightedFile()
+	_, lines, width := m.previewLayout()
+	if !ok || lines <= 0 {
+		return previewKey{}, false
+	}
+	return previewKey{file, lines, width}, true
+}
+
+// previewCmd returns a command loading the preview the file list shows, or
+// nil if it is cached or already loading. A file inside an archive is
+// extracted first, here, since ArchiveFiles isn't safe for concurrent use.
+func (m model) previewCmd() tea.Cmd {
+	key, ok := m.previewKey()
+	if !ok || key == m.previewLoading {
+		return nil
+	}
+	if _, cached := m.previews[key]; cached {
+		return nil
+	}
+	local, err := m.archives.Local(key.file)
+	return func() tea.Msg {
+		var text string
+		if err == nil {
+			text, err = previewFile(local, key.lines, key.width-2)
+		}
+		if err != nil {
+			text = fmt.Sprintf("Error reading file: %v", err)
+		}
+		return previewMsg{key, text}
+	}
+}
+
+// renderWithPreview adds a preview of the highlighted file to a rendered file
+// list, once previewCmd has loaded it.
+func (m model) renderWithPreview(list string) string {
+	key, ok := m.previewKey()
+	if !ok {
+		return list
+	}
+	file, width := key.file, key.width
+	side, _, _ := m.previewLayout()
+	preview, loaded := m.previews[key]
+	if !loaded {
+		preview = "Loading preview..."
+	}
+
+	if side {
+		pane := previewStyle.Width(width).Render(helpStyle.Render(m.displayName(file)) + "\n" + diffStyle.Render(preview))
+		listPane := lipgloss.NewStyle().Width(m.width - width - 2).Render(list)
+		return lipgloss.JoinHorizontal(lipgloss.Top, listPane, pane)
+	}
+	return list + "\n" + strings.Repeat("─", width) + "\n" + diffStyle.Render(preview)
 }
 
 // renderDiff renders the diff view
 func (m model) renderDiff() string {
 	var s strings.Builder
 
-	s.WriteString(titleStyle.Render("Comparing files:\n\n"))
-	s.WriteString(fmt.Sprintf("File 1: %s\n", filepath.Base(m.firstFile)))
-	s.WriteString(fmt.Sprintf("File 2: %s\n\n", filepath.Base(m.secondFile)))
+	if m.baseFile != "" {
+		s.WriteString(fitWidth("Base:   "+m.displayName(m.baseFile), m.width) + "\n")
+		for i, file := range m.variants {
+			s.WriteString(fitWidth(fmt.Sprintf("File %d: %s", i+1, m.displayName(file)), m.width) + "\n")
+		}
+		s.WriteString("\n")
+	} else {
+		s.WriteString(fitWidth("File 1: "+m.displayName(m.firstFile), m.width) + "\n")
+		s.WriteString(fitWidth("File 2: "+m.displayName(m.secondFile), m.width) + "\n\n")
+	}
 	s.WriteString(strings.Repeat("─", m.width))
 	s.WriteString("\n\n")
 
-	// Split diff output into lines and display
+	if m.identical && m.revisionFile != "" {
+		s.WriteString(titleStyle.Render("The file is unchanged since it was committed."))
+		return s.String()
+	}
+	if m.identical {
+		if m.hardlinked {
+			s.WriteString(titleStyle.Render("Files are hard links to the same data (already deduplicated)."))
+		} else {
+			s.WriteString(titleStyle.Render("Files are byte-identical."))
+		}
+		s.WriteString("\n\n")
+		s.WriteString(fmt.Sprintf("d: delete File 2 (%s)\n", m.displayName(m.secondFile)))
+		s.WriteString(fmt.Sprintf("D: delete File 1 (%s)\n", m.displayName(m.firstFile)))
+		if !m.hardlinked {
+			s.WriteString("h: replace File 2 with a hard link to File 1\n")
+		}
+		return s.String()
+	}
+
+	if m.diffJob != nil {
+		frame := spinnerFrames[m.spinner%len(spinnerFrames)]
+		s.WriteString(titleStyle.Render(fmt.Sprintf("%s Running diff...", frame)))
+		s.WriteString("\n\n")
+		s.WriteString(helpStyle.Render("esc: cancel"))
+		return s.String()
+	}
+
+	if m.diffSummary != "" {
+		s.WriteString(fitWidth(helpStyle.Render(m.diffSummary), m.width))
+		s.WriteString("\n\n")
+	}
+	if m.frontmatterOnly {
+		s.WriteString(fmt.Sprintf("d: delete File 2 (%s)  D: delete File 1 (%s)\n\n", m.displayName(m.secondFile), m.displayName(m.firstFile)))
+	}
+
+	// Split diff output into lines and display the page scrolled to
 	diffLines := strings.Split(m.diffOutput, "\n")
-	maxLines := m.height - 15 // Leave room for header and help
-	if maxLines < 1 {
-		maxLines = 10
+	maxLines := m.diffPageSize()
+	start := min(m.diffScroll, len(diffLines))
+	if start > 0 {
+		s.WriteString(helpStyle.Render(fmt.Sprintf("... (%d lines above)", start)))
+		s.WriteString("\n")
+	}
+	diffLines = diffLines[start:]
+	more := len(diffLines) - maxLines
+	if more > 0 {
+		diffLines = diffLines[:maxLines]
 	}
 
-	if len(diffLines) > maxLines {
-		s.WriteString(diffStyle.Render(strings.Join(diffLines[:maxLines], "\n")))
+	for i, line := range diffLines {
+		if i > 0 {
+			s.WriteString("\n")
+		}
+		// Long lines are cut; ←/→ scroll to the rest
+		s.WriteString(fitWidth(m.renderDiffLine(line), m.width))
+	}
+	if more > 0 {
 		s.WriteString("\n")
-		s.WriteString(helpStyle.Render(fmt.Sprintf("... (%d more lines, scroll to see more)", len(diffLines)-maxLines)))
-	} else {
-		s.WriteString(diffStyle.Render(m.diffOutput))
+		s.WriteString(helpStyle.Render(fmt.Sprintf("... (%d more lines, scroll or press n for the next change)", more)))
 	}
 
 	return s.String()
 }
 
-// renderHelp renders the help text
+// renderStatusBar renders the bar above the help line: the scanned directory,
+// how many files were scanned and grouped, the active filters, and which group
+// is highlighted or open, cut to the terminal width.
+func (m model) renderStatusBar() string {
+	var parts []string
+	if m.displayRoot != "" {
+		parts = append(parts, m.displayRoot)
+	}
+	if m.scanDone {
+		parts = append(parts, plural(m.scan.fileCount, "file"))
+	}
+	parts = append(parts, plural(len(m.groups), "group"))
+	parts = append(parts, m.filters...)
+	if len(m.groups) > 0 {
+		pos := m.currentGroup
+		if m.state == stateSelectGroup {
+			pos = m.cursor
+		}
+		parts = append(parts, fmt.Sprintf("group %d/%d", min(pos, len(m.groups)-1)+1, len(m.groups)))
+	}
+	return statusBarStyle.Render(truncateLine(" "+strings.Join(parts, " · ")+" ", m.width))
+}
+
+// renderHelp renders the help text, wrapped to the terminal width
 func (m model) renderHelp() string {
+	return helpStyle.Render(strings.Join(wrapHelp(m.helpText(), m.width), "\n"))
+}
+
+// extraHelpLines returns how many more lines than one the wrapped help text
+// takes, for the views to leave room for.
+func (m model) extraHelpLines() int {
+	return len(wrapHelp(m.helpText(), m.width)) - 1
+}
+
+// helpText returns the keys of the current screen, on one line
+func (m model) helpText() string {
 	var help string
+	committed := ""
+	if m.git != nil {
+		committed = "  H: diff against HEAD  L: diff against a revision"
+	}
 	switch m.state {
+	case stateLoading:
+		help = "q: quit"
 	case stateSelectGroup:
-		help = "↑/↓: navigate  Enter: select group  n: next group  q: quit"
+		help = "↑/↓: navigate  PgUp/PgDn: page  g/G: first/last  Enter: select group  Tab: expand  z: expand all  a: compare all pairs  n: next group  v: mark reviewed  i: ignore forever  u: undo  p: full paths  ?: help  q: quit"
 	case stateSelectFirstFile:
-		help = "↑/↓: navigate  Enter: select file  Esc: back  q: quit"
+		if len(m.selected) > 0 {
+			help = "Space: select  d: delete selected  M: move selected  h: hardlink selected to highlighted  S: split off selected  ↑/↓: navigate  u: undo  Esc: clear selection  ?: help  q: quit"
+			break
+		}
+		help = "↑/↓: navigate  PgUp/PgDn: page  g/G: first/last  Enter: select file  Space: select  m: mark  c: compare marked  a: compare all pairs  3: diff against base" + committed + "  e: edit  r: rename  M: move  u: undo  x: open  y: copy path  p: full paths  Esc: back  ?: help  q: quit"
 	case stateSelectSecondFile:
-		help = "↑/↓: navigate  Enter: select file  Esc: back  q: quit"
+		help = "↑/↓: navigate  PgUp/PgDn: page  g/G: first/last  Enter: select file  3: diff against base  o: open in merge tool  E: why grouped" + committed + "  e: edit  r: rename  M: move  u: undo  x: open  y: copy path  p: full paths  Esc: back  ?: help  q: quit"
 	case stateViewDiff:
-		help = "Enter: select another pair  Esc: back  q: quit"
+		mode := "  u: unified"
+		switch {
+		case m.narrow():
+			// Too narrow for side-by-side, so there is nothing to switch to
+			mode = ""
+		case m.unified:
+			mode = "  s: side-by-side"
+		}
+		whitespace := "w: ignore whitespace"
+		if m.diffExec.IgnoresWhitespace() {
+			whitespace = "w: show whitespace"
+		}
+		next := "Enter: select another pair"
+		if m.comparePairs != nil {
+			next = "Enter: next pair"
+		}
+		if m.identical {
+			if m.canPreviewImages() {
+				next += "  I: image previews"
+			}
+			help = next + "  d/D: delete  h: hardlink  u: undo  Esc: back  ?: help  q: quit"
+			if m.revisionFile != "" {
+				help = next + "  u: undo  Esc: back  ?: help  q: quit"
+			}
+			break
+		}
+		changes := "n/p: next/prev change"
+		if m.diffSearch != "" {
+			changes = "n/N: next/prev match  p: prev change"
+		}
+		if m.frontmatterOnly && m.revisionFile == "" {
+			next += "  d/D: delete"
+		}
+		if m.canPreviewImages() {
+			next += "  I: image previews"
+		}
+		help = next + "  ↑/↓/←/→: scroll  " + changes + "  /: search" + mode + "  " + whitespace + "  3: diff against base  o: open in merge tool  E: why grouped  Esc: back  ?: help  q: quit"
 	}
-	return helpStyle.Render(help)
+	return help
 }
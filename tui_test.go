@@ -0,0 +1,1725 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// testGroups builds n groups of two files each.
+func testGroups(n int) [][]string {
+	var groups [][]string
+	for i := 0; i < n; i++ {
+		groups = append(groups, []string{fmt.Sprintf("/tmp/g%d.txt", i), fmt.Sprintf("/tmp/g%d-1.txt", i)})
+	}
+	return groups
+}
+
+// sendKey feeds a key press to the model and returns the updated model.
+func sendKey(t *testing.T, m model, key string) model {
+	t.Helper()
+	var msg tea.KeyMsg
+	switch key {
+	case "pgdown":
+		msg = tea.KeyMsg{Type: tea.KeyPgDown}
+	case "pgup":
+		msg = tea.KeyMsg{Type: tea.KeyPgUp}
+	case "home":
+		msg = tea.KeyMsg{Type: tea.KeyHome}
+	case "end":
+		msg = tea.KeyMsg{Type: tea.KeyEnd}
+	case "enter":
+		msg = tea.KeyMsg{Type: tea.KeyEnter}
+	case "esc":
+		msg = tea.KeyMsg{Type: tea.KeyEsc}
+	case "tab":
+		msg = tea.KeyMsg{Type: tea.KeyTab}
+	case "ctrl+f":
+		msg = tea.KeyMsg{Type: tea.KeyCtrlF}
+	case "ctrl+b":
+		msg = tea.KeyMsg{Type: tea.KeyCtrlB}
+	default:
+		msg = tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)}
+	}
+	updated, _ := m.Update(msg)
+	return finishDiff(t, finishPreview(updated.(model)))
+}
+
+// finishDiff runs the diff the model is waiting for, if any, and delivers its
+// output the way the program would.
+func finishDiff(t *testing.T, m model) model {
+	t.Helper()
+	if m.diffJob == nil {
+		return m
+	}
+	updated, _ := m.Update(m.diffJob.run())
+	return updated.(model)
+}
+
+// finishPreview loads the preview the model is waiting for, if any, and
+// delivers it the way the program would.
+func finishPreview(m model) model {
+	if m.previewLoading == (previewKey{}) {
+		return m
+	}
+	m.previewLoading = previewKey{}
+	load := m.previewCmd()
+	if load == nil {
+		return m
+	}
+	updated, _ := m.Update(load())
+	return updated.(model)
+}
+
+// TestTUI_PageNavigation tests page up/down and home/end in the group list.
+func TestTUI_PageNavigation(t *testing.T) {
+	m := initialModel(testGroups(50), NewDiffExecutor(""), nil)
+	m.state = stateSelectGroup
+	m.width, m.height = 80, 40 // (40-10-2)/2 = 14 collapsed groups per page, with the help on three lines
+
+	steps := []struct {
+		key      string
+		expected int
+	}{
+		{"pgdown", 14},
+		{"ctrl+f", 28},
+		{"pgup", 14},
+		{"ctrl+b", 0},
+		{"pgup", 0},
+		{"G", 49},
+		{"pgdown", 49},
+		{"g", 0},
+		{"end", 49},
+		{"home", 0},
+	}
+	for _, step := range steps {
+		m = sendKey(t, m, step.key)
+		if m.cursor != step.expected {
+			t.Fatalf("after %q cursor = %d, expected %d", step.key, m.cursor, step.expected)
+		}
+	}
+}
+
+// TestTUI_JumpSkipsFirstFile tests that jumps never land on the already-selected first file.
+func TestTUI_JumpSkipsFirstFile(t *testing.T) {
+	group := []string{"/tmp/a.txt", "/tmp/a-1.txt", "/tmp/a-2.txt"}
+	m := initialModel([][]string{group}, NewDiffExecutor(""), nil)
+	m.state = stateSelectSecondFile
+	m.width, m.height = 80, 40
+
+	m.firstFile = group[2]
+	m = sendKey(t, m, "G")
+	if m.cursor != 1 {
+		t.Errorf("end with last file selected: cursor = %d, expected 1", m.cursor)
+	}
+
+	m.firstFile = group[0]
+	m = sendKey(t, m, "g")
+	if m.cursor != 1 {
+		t.Errorf("home with first file selected: cursor = %d, expected 1", m.cursor)
+	}
+}
+
+// TestTUI_VisibleRange tests that the rendered window follows the cursor.
+func TestTUI_VisibleRange(t *testing.T) {
+	m := initialModel(testGroups(25), NewDiffExecutor(""), nil)
+	m.state = stateSelectGroup
+	m.width, m.height = 80, 40
+
+	m.cursor = 23
+	start, end := m.visibleRange(len(m.groups))
+	if start != 14 || end != 25 {
+		t.Errorf("visibleRange() = (%d, %d), expected (14, 25)", start, end)
+	}
+}
+
+// TestTUI_GroupRange tests that pages of the group list hold as many groups as
+// fit, counting the file lists of expanded groups.
+func TestTUI_GroupRange(t *testing.T) {
+	m := initialModel(testGroups(25), NewDiffExecutor(""), nil)
+	m.state = stateSelectGroup
+	m.width, m.height = 80, 40 // 28 lines: 14 collapsed groups
+
+	m.cursor = 23
+	if start, end := m.groupRange(); start != 14 || end != 25 {
+		t.Errorf("groupRange() = (%d, %d), expected (14, 25)", start, end)
+	}
+
+	// Each expanded group takes another line, so two push a group off the first page
+	m.cursor = 0
+	m = sendKey(t, m, "tab")
+	m = sendKey(t, m, "down")
+	m = sendKey(t, m, "tab")
+	if start, end := m.groupRange(); start != 0 || end != 13 {
+		t.Errorf("groupRange() with an expanded group = (%d, %d), expected (0, 13)", start, end)
+	}
+}
+
+// TestTUI_ExpandGroups tests that groups are collapsed until expanded with tab or z.
+func TestTUI_ExpandGroups(t *testing.T) {
+	groups := [][]string{
+		{"/dir/notes.txt", "/dir/notes-1.txt"},
+		{"/dir/photo.jpg", "/dir/photo-1.jpg"},
+	}
+	m := initialModel(groups, NewDiffExecutor(""), nil)
+	m.width, m.height = 80, 40
+
+	if strings.Contains(m.View(), "notes-1.txt") {
+		t.Error("groups should be collapsed by default")
+	}
+	m = sendKey(t, m, "tab")
+	view := m.View()
+	if !strings.Contains(view, "notes.txt, notes-1.txt") || strings.Contains(view, "photo-1.jpg") {
+		t.Errorf("tab should expand only the highlighted group:\n%s", view)
+	}
+	m = sendKey(t, m, "tab")
+	if strings.Contains(m.View(), "notes-1.txt") {
+		t.Error("tab again should collapse the group")
+	}
+
+	m = sendKey(t, m, "z")
+	view = m.View()
+	if !strings.Contains(view, "notes-1.txt") || !strings.Contains(view, "photo-1.jpg") {
+		t.Errorf("z should expand every group:\n%s", view)
+	}
+	m = sendKey(t, m, "z")
+	if strings.Contains(m.View(), "photo-1.jpg") {
+		t.Error("z again should collapse every group")
+	}
+}
+
+// TestTUI_IgnoreGroup tests that 'i' hides a group and persists it.
+func TestTUI_IgnoreGroup(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	list, err := LoadIgnoreList(tmpDir)
+	if err != nil {
+		t.Fatalf("LoadIgnoreList() returned error: %v", err)
+	}
+	groups := [][]string{
+		{filepath.Join(tmpDir, "a.txt"), filepath.Join(tmpDir, "a-1.txt")},
+		{filepath.Join(tmpDir, "b.txt"), filepath.Join(tmpDir, "b-1.txt")},
+	}
+	m := initialModel(groups, NewDiffExecutor(""), nil)
+	m.state = stateSelectGroup
+	m.ignoreList = list
+	m.width, m.height = 80, 40
+
+	m = sendKey(t, m, "G")
+	m = sendKey(t, m, "i")
+	if len(m.groups) != 1 || m.cursor != 0 {
+		t.Fatalf("after ignore: %d groups, cursor %d; expected 1 group, cursor 0", len(m.groups), m.cursor)
+	}
+
+	reloaded, err := LoadIgnoreList(tmpDir)
+	if err != nil {
+		t.Fatalf("LoadIgnoreList() returned error: %v", err)
+	}
+	if !reloaded.Contains(groups[1]) {
+		t.Error("ignored group should be saved to disk")
+	}
+}
+
+// TestTUI_MarkReviewed tests that 'v' toggles the reviewed marker.
+func TestTUI_MarkReviewed(t *testing.T) {
+	m := initialModel(testGroups(2), NewDiffExecutor(""), nil)
+	m.state = stateSelectGroup
+	m.width, m.height = 80, 40
+
+	m = sendKey(t, m, "v")
+	if !strings.Contains(m.View(), "✓ reviewed") {
+		t.Error("View() should mark the group as reviewed")
+	}
+	m = sendKey(t, m, "v")
+	if strings.Contains(m.View(), "✓ reviewed") {
+		t.Error("pressing v again should clear the reviewed marker")
+	}
+}
+
+// TestTUI_GroupTitle tests that groups are titled with their shared name in
+// the group list and the file selection.
+func TestTUI_GroupTitle(t *testing.T) {
+	groups := [][]string{
+		{"/dir/Obsidian Daily Notes 2024.md", "/dir/Obsidian Daily Notes (1).md", "/dir/Obsidian Daily Notes.md"},
+		{"/dir/alpha.txt", "/dir/beta.txt"},
+	}
+	m := initialModel(groups, NewDiffExecutor(""), nil)
+	m.matcher = NewMatcher(3)
+	m.width, m.height = 80, 40
+
+	view := m.View()
+	if !strings.Contains(view, "Group 1 — 'Obsidian Daily Notes' (3 files)") {
+		t.Errorf("the group list should label the group:\n%s", view)
+	}
+	if !strings.Contains(view, "Group 2: 2 files") {
+		t.Errorf("groups without a shared name should keep the plain title:\n%s", view)
+	}
+
+	m = sendKey(t, m, "enter")
+	if !strings.Contains(m.View(), "Group 1 — 'Obsidian Daily Notes' (3 files)") {
+		t.Errorf("the file selection should label the group:\n%s", m.View())
+	}
+}
+
+// TestTUI_Breadcrumb tests that the breadcrumb follows each step into a group
+// and back out with Esc.
+func TestTUI_Breadcrumb(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	group := []string{
+		createFileWithContent(t, tmpDir, "notes.md", "one\n"),
+		createFileWithContent(t, tmpDir, "notes 2.md", "two\n"),
+		createFileWithContent(t, tmpDir, "notes 3.md", "three\n"),
+	}
+	m := initialModel([][]string{group}, NewDiffExecutor(""), nil)
+	m.width, m.height = 120, 40
+
+	steps := []struct {
+		key  string
+		want string
+	}{
+		{"", "Groups"},
+		{"enter", "Groups ▸ Group 1 — 'notes' (3 files)"},
+		{"enter", "Groups ▸ Group 1 — 'notes' (3 files) ▸ notes.md"},
+		{"enter", "Groups ▸ Group 1 — 'notes' (3 files) ▸ notes.md vs notes 2.md"},
+		{"esc", "Groups ▸ Group 1 — 'notes' (3 files) ▸ notes.md"},
+		{"esc", "Groups ▸ Group 1 — 'notes' (3 files)"},
+		{"a", "Groups ▸ Group 1 — 'notes' (3 files) ▸ notes.md vs notes 2.md (pair 1 of 3)"},
+		{"esc", "Groups ▸ Group 1 — 'notes' (3 files)"},
+		{"esc", "Groups"},
+	}
+	for _, step := range steps {
+		if step.key != "" {
+			m = sendKey(t, m, step.key)
+		}
+		if got := strings.Join(m.breadcrumb(), " ▸ "); got != step.want {
+			t.Fatalf("after %q breadcrumb = %q, expected %q", step.key, got, step.want)
+		}
+		if view := m.View(); !strings.HasPrefix(view, step.want) {
+			t.Fatalf("after %q the view should start with the breadcrumb:\n%s", step.key, view)
+		}
+	}
+
+	m.width = 10
+	if got := m.renderBreadcrumb(); got != "Groups" {
+		t.Errorf("renderBreadcrumb() = %q at 10 columns", got)
+	}
+}
+
+// TestTUI_NarrowTerminal tests that diffs become unified below 80 columns and
+// that no line of the view is wider than the terminal.
+func TestTUI_NarrowTerminal(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	group := []string{
+		createFileWithContent(t, tmpDir, "a rather long file name for a narrow terminal.md", "one\ntwo\n"),
+		createFileWithContent(t, tmpDir, "a rather long file name for a narrow terminal 2.md", "one\nTWO\n"),
+	}
+	m := initialModel([][]string{group}, NewDiffExecutor(""), nil)
+	m.displayRoot = tmpDir
+	m.fullPaths = true
+	updated, _ := m.Update(tea.WindowSizeMsg{Width: 50, Height: 30})
+	m = updated.(model)
+
+	checkWidth := func(view string) {
+		t.Helper()
+		for _, line := range strings.Split(view, "\n") {
+			if w := lipgloss.Width(line); w > 50 {
+				t.Fatalf("line of %d columns in a 50-column terminal: %q", w, line)
+			}
+		}
+	}
+	checkWidth(m.View())
+	for _, key := range []string{"enter", "enter", "enter"} {
+		m = sendKey(t, m, key)
+		checkWidth(m.View())
+	}
+	if !m.diffUnified() || !strings.Contains(m.diffOutput, "@@") {
+		t.Fatalf("a narrow terminal should show a unified diff:\n%s", m.diffOutput)
+	}
+
+	m = sendKey(t, m, "s")
+	if m.diffJob != nil || !strings.Contains(m.status, "80 columns") {
+		t.Errorf("s on a narrow terminal should explain why the diff stays unified, status = %q", m.status)
+	}
+
+	// Widening the terminal brings back the side-by-side diff asked for
+	updated, _ = m.Update(tea.WindowSizeMsg{Width: 120, Height: 30})
+	m = finishDiff(t, updated.(model))
+	if m.diffUnified() || strings.Contains(m.diffOutput, "@@") {
+		t.Errorf("a wide terminal should show the side-by-side diff:\n%s", m.diffOutput)
+	}
+}
+
+// TestTUI_OpenFile tests that e and x only act on a highlighted file.
+func TestTUI_OpenFile(t *testing.T) {
+	m := initialModel(testGroups(1), NewDiffExecutor(""), nil)
+	m.width, m.height = 80, 40
+
+	if _, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")}); cmd != nil {
+		t.Error("x in group selection should not open anything")
+	}
+
+	m = sendKey(t, m, "enter")
+	if _, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")}); cmd == nil {
+		t.Error("x in file selection should open the highlighted file")
+	}
+
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", "vim 'unterminated")
+	m = sendKey(t, m, "e")
+	if !strings.Contains(m.status, "invalid $EDITOR") {
+		t.Errorf("status = %q, expected an $EDITOR error", m.status)
+	}
+}
+
+// TestTUI_CopyPath tests that y only acts on a highlighted file.
+func TestTUI_CopyPath(t *testing.T) {
+	m := initialModel(testGroups(1), NewDiffExecutor(""), nil)
+	m.width, m.height = 80, 40
+
+	if _, cmd := m.copyPath(); cmd != nil {
+		t.Error("y in the group list should do nothing")
+	}
+	m = sendKey(t, m, "enter")
+	if _, cmd := m.copyPath(); cmd == nil {
+		t.Error("y in the file selection should copy the highlighted path")
+	}
+}
+
+// TestTUI_FilePreview tests that the highlighted file is previewed in both layouts.
+func TestTUI_FilePreview(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	createFileWithContent(t, tmpDir, "notes.txt", "buy milk\n")
+	createFileWithContent(t, tmpDir, "notes-1.txt", "buy bread\n")
+	group := []string{filepath.Join(tmpDir, "notes.txt"), filepath.Join(tmpDir, "notes-1.txt")}
+
+	for _, width := range []int{80, 140} {
+		m := initialModel([][]string{group}, NewDiffExecutor(""), nil)
+		m.width, m.height = width, 30
+		m = sendKey(t, m, "enter")
+		if !strings.Contains(m.View(), "buy milk") {
+			t.Errorf("width %d: View() should preview the highlighted file", width)
+		}
+		m = sendKey(t, m, "down")
+		if !strings.Contains(m.View(), "buy bread") {
+			t.Errorf("width %d: View() should follow the cursor", width)
+		}
+	}
+}
+
+// TestTUI_FilePreviewCached tests that the preview is loaded by a command when
+// the highlight moves and read from the cache by View, not from disk.
+func TestTUI_FilePreviewCached(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	notes := createFileWithContent(t, tmpDir, "notes.txt", "buy milk\n")
+	group := []string{notes, createFileWithContent(t, tmpDir, "notes-1.txt", "buy bread\n")}
+	m := initialModel([][]string{group}, NewDiffExecutor(""), nil)
+	m.width, m.height = 80, 30
+
+	updated, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = updated.(model)
+	if !strings.Contains(m.View(), "Loading preview...") {
+		t.Error("View() should show the preview as loading until it is read")
+	}
+	if cmd == nil {
+		t.Fatal("moving onto a file should return a command loading its preview")
+	}
+	m = finishPreview(m)
+	if !strings.Contains(m.View(), "buy milk") {
+		t.Fatal("View() should show the loaded preview")
+	}
+
+	// View doesn't read the file again
+	createFileWithContent(t, tmpDir, "notes.txt", "buy eggs\n")
+	if view := m.View(); !strings.Contains(view, "buy milk") {
+		t.Errorf("View() should show the cached preview:\n%s", view)
+	}
+	if _, cmd := m.Update(tea.WindowSizeMsg{Width: 80, Height: 30}); cmd != nil {
+		t.Error("an update that leaves the highlight and layout alone should load nothing")
+	}
+}
+
+// TestTUI_ToggleUnified tests switching the diff view between unified and side-by-side.
+func TestTUI_ToggleUnified(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	createFileWithContent(t, tmpDir, "notes.txt", "buy milk\n")
+	createFileWithContent(t, tmpDir, "notes-1.txt", "buy bread\n")
+	group := []string{filepath.Join(tmpDir, "notes.txt"), filepath.Join(tmpDir, "notes-1.txt")}
+
+	m := initialModel([][]string{group}, NewDiffExecutor(""), nil)
+	m.width, m.height = 80, 40
+	for _, key := range []string{"enter", "enter", "enter"} {
+		m = sendKey(t, m, key)
+	}
+	if m.state != stateViewDiff {
+		t.Fatalf("state = %v, expected the diff view", m.state)
+	}
+	if strings.Contains(m.diffOutput, "@@") {
+		t.Fatal("diff view should start side-by-side")
+	}
+
+	m = sendKey(t, m, "u")
+	if !strings.Contains(m.diffOutput, "@@") {
+		t.Errorf("after u, diffOutput = %q, expected a unified diff", m.diffOutput)
+	}
+	m = sendKey(t, m, "s")
+	if strings.Contains(m.diffOutput, "@@") {
+		t.Errorf("after s, diffOutput = %q, expected a side-by-side diff", m.diffOutput)
+	}
+}
+
+// TestTUI_ToggleWhitespace tests that w re-renders the diff ignoring whitespace.
+func TestTUI_ToggleWhitespace(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	createFileWithContent(t, tmpDir, "notes.txt", "buy milk\n")
+	createFileWithContent(t, tmpDir, "notes-1.txt", "buy    milk\n")
+	group := []string{filepath.Join(tmpDir, "notes.txt"), filepath.Join(tmpDir, "notes-1.txt")}
+
+	m := initialModel([][]string{group}, NewDiffExecutor(""), nil)
+	m.width, m.height = 80, 40
+	for _, key := range []string{"enter", "enter", "enter", "u"} {
+		m = sendKey(t, m, key)
+	}
+	if m.diffOutput == "" {
+		t.Fatal("diff should show the whitespace change")
+	}
+
+	m = sendKey(t, m, "w")
+	if m.diffOutput != "" {
+		t.Errorf("after w, diffOutput = %q, expected no differences", m.diffOutput)
+	}
+}
+
+// TestTUI_DiffInBackground tests that diffs are generated by a command, with a
+// spinner until the output arrives, and that Esc cancels them.
+func TestTUI_DiffInBackground(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	createFileWithContent(t, tmpDir, "notes.txt", "buy milk\n")
+	createFileWithContent(t, tmpDir, "notes-1.txt", "buy bread\n")
+	group := []string{filepath.Join(tmpDir, "notes.txt"), filepath.Join(tmpDir, "notes-1.txt")}
+
+	m := initialModel([][]string{group}, NewDiffExecutor(""), nil)
+	m.width, m.height = 80, 40
+	for _, key := range []string{"enter", "enter"} {
+		m = sendKey(t, m, key)
+	}
+	updated, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = updated.(model)
+	if m.state != stateViewDiff || m.diffJob == nil || cmd == nil {
+		t.Fatalf("state = %v, diffJob = %v, expected a diff running in the diff view", m.state, m.diffJob)
+	}
+	if !strings.Contains(m.View(), "Running diff") {
+		t.Error("diff view should show a spinner while the diff runs")
+	}
+
+	// Output of a diff that was replaced is dropped
+	first := m.diffJob
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("u")})
+	m = updated.(model)
+	updated, _ = m.Update(diffDoneMsg{seq: first.seq, output: "stale"})
+	m = updated.(model)
+	if m.diffJob == nil || m.diffOutput != "" {
+		t.Errorf("diffOutput = %q, expected the stale output to be dropped", m.diffOutput)
+	}
+
+	m = finishDiff(t, m)
+	if m.diffJob != nil || !strings.Contains(m.diffOutput, "@@") {
+		t.Errorf("diffOutput = %q, expected the unified diff", m.diffOutput)
+	}
+	if !strings.Contains(m.View(), "+1 −1 lines, 0% similar") {
+		t.Errorf("diff view should show the summary, got %q", m.diffSummary)
+	}
+
+	m = sendKey(t, m, "s")
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("u")})
+	m = updated.(model)
+	job := m.diffJob
+	m = sendKey(t, m, "esc")
+	if m.state != stateSelectSecondFile || m.diffJob != nil || m.status != "Diff cancelled" {
+		t.Errorf("after esc, state = %v, status = %q, expected the diff cancelled", m.state, m.status)
+	}
+	updated, _ = m.Update(job.run())
+	if updated.(model).diffOutput != "" {
+		t.Error("output of a cancelled diff should be dropped")
+	}
+}
+
+// TestTUI_JumpToChange tests scrolling the diff view to the next and previous
+// blocks of changes with n and p.
+func TestTUI_JumpToChange(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	var lines1, lines2 []string
+	for i := range 100 {
+		line := fmt.Sprintf("line %d", i)
+		lines1 = append(lines1, line)
+		if i == 4 || i == 79 {
+			line = "changed"
+		}
+		lines2 = append(lines2, line)
+	}
+	createFileWithContent(t, tmpDir, "notes.txt", strings.Join(lines1, "\n")+"\n")
+	createFileWithContent(t, tmpDir, "notes-1.txt", strings.Join(lines2, "\n")+"\n")
+	group := []string{filepath.Join(tmpDir, "notes.txt"), filepath.Join(tmpDir, "notes-1.txt")}
+
+	m := initialModel([][]string{group}, NewDiffExecutor(""), nil)
+	m.width, m.height = 80, 42
+	for _, key := range []string{"enter", "enter", "enter"} {
+		m = sendKey(t, m, key)
+	}
+	last := m.diffLineCount() - m.diffPageSize()
+
+	steps := []struct {
+		key    string
+		scroll int
+		status string
+	}{
+		{"n", 4, "Change 1 of 2"},
+		{"n", last, "Change 2 of 2"},
+		{"n", last, "No more changes below"},
+		{"p", 4, "Change 1 of 2"},
+		{"p", 4, "No more changes above"},
+		{"j", 5, ""},
+		{"G", last, ""},
+		{"g", 0, ""},
+	}
+	for _, step := range steps {
+		m = sendKey(t, m, step.key)
+		if m.diffScroll != step.scroll || m.status != step.status {
+			t.Errorf("after %s, diffScroll = %d, status = %q, expected %d, %q", step.key, m.diffScroll, m.status, step.scroll, step.status)
+		}
+	}
+
+	m = sendKey(t, m, "n")
+	if !strings.Contains(m.View(), "lines above") {
+		t.Error("a scrolled diff should say how many lines are above")
+	}
+}
+
+// TestTUI_SearchDiff tests searching the diff output with / and moving between
+// matches with n and N.
+func TestTUI_SearchDiff(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	var lines1, lines2 []string
+	for i := range 100 {
+		lines1 = append(lines1, fmt.Sprintf("line %d", i))
+		lines2 = append(lines2, fmt.Sprintf("line %d", i))
+	}
+	lines1[10], lines1[60] = "## Heading", "## heading again"
+	lines2[90] = "changed"
+	createFileWithContent(t, tmpDir, "notes.txt", strings.Join(lines1, "\n")+"\n")
+	createFileWithContent(t, tmpDir, "notes-1.txt", strings.Join(lines2, "\n")+"\n")
+	group := []string{filepath.Join(tmpDir, "notes.txt"), filepath.Join(tmpDir, "notes-1.txt")}
+
+	m := initialModel([][]string{group}, NewDiffExecutor(""), nil)
+	m.width, m.height = 80, 40
+	for _, key := range []string{"enter", "enter", "enter", "/"} {
+		m = sendKey(t, m, key)
+	}
+	if m.pending == nil {
+		t.Fatal("/ should ask for the text to search for")
+	}
+	for _, key := range []string{"h", "e", "a", "d", "i", "n", "g", "enter"} {
+		m = sendKey(t, m, key)
+	}
+	if m.diffScroll != 10 || m.status != "Match 1 of 2" {
+		t.Fatalf("diffScroll = %d, status = %q, expected the first match", m.diffScroll, m.status)
+	}
+	if !strings.Contains(m.View(), "Heading") {
+		t.Error("the match should be shown")
+	}
+
+	steps := []struct {
+		key    string
+		scroll int
+		status string
+	}{
+		{"n", 60, "Match 2 of 2"},
+		{"n", 10, "Match 1 of 2 (wrapped)"},
+		{"N", 60, "Match 2 of 2 (wrapped)"},
+	}
+	for _, step := range steps {
+		m = sendKey(t, m, step.key)
+		if m.diffScroll != step.scroll || m.status != step.status {
+			t.Errorf("after %s, diffScroll = %d, status = %q, expected %d, %q", step.key, m.diffScroll, m.status, step.scroll, step.status)
+		}
+	}
+
+	// Upper case makes the search match case
+	m = sendKey(t, m, "/")
+	m.pending.input = newLineInput("Search: ", "Heading")
+	m = sendKey(t, m, "enter")
+	if len(m.diffMatches) != 1 {
+		t.Errorf("diffMatches = %v, expected only the upper-case heading", m.diffMatches)
+	}
+
+	// The first Esc ends the search, the second leaves the diff
+	m = sendKey(t, m, "esc")
+	if m.state != stateViewDiff || m.diffSearch != "" {
+		t.Errorf("after esc, state = %v, diffSearch = %q, expected the search ended", m.state, m.diffSearch)
+	}
+	m = sendKey(t, m, "n")
+	if m.status != "Change 2 of 3" {
+		t.Errorf("after the search, n should jump to a change, status = %q", m.status)
+	}
+	m = sendKey(t, m, "esc")
+	if m.state != stateSelectSecondFile {
+		t.Errorf("state = %v, expected second file selection", m.state)
+	}
+}
+
+// TestFindMatches tests the case rules of diff search.
+func TestFindMatches(t *testing.T) {
+	tests := []struct {
+		line, query string
+		want        [][2]int
+	}{
+		{"Notes and notes", "notes", [][2]int{{0, 5}, {10, 15}}},
+		{"Notes and notes", "Notes", [][2]int{{0, 5}}},
+		{"aaaa", "aa", [][2]int{{0, 2}, {2, 4}}},
+		{"abc", "x", nil},
+	}
+	for _, tt := range tests {
+		if got := findMatches(tt.line, tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("findMatches(%q, %q) = %v, expected %v", tt.line, tt.query, got, tt.want)
+		}
+	}
+}
+
+// TestTUI_DiffWidth tests that side-by-side diffs follow the terminal width and
+// that lines that still overflow scroll sideways.
+func TestTUI_DiffWidth(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	long := strings.Repeat("word ", 40) + "end"
+	createFileWithContent(t, tmpDir, "notes.txt", "short\n"+long+"\n")
+	createFileWithContent(t, tmpDir, "notes-1.txt", "short\n"+long+" more\n")
+	group := []string{filepath.Join(tmpDir, "notes.txt"), filepath.Join(tmpDir, "notes-1.txt")}
+
+	m := initialModel([][]string{group}, NewDiffExecutor(""), nil)
+	m.width, m.height = 80, 40
+	for _, key := range []string{"enter", "enter", "enter"} {
+		m = sendKey(t, m, key)
+	}
+	if m.diffWidth != 80 {
+		t.Fatalf("diffWidth = %d, expected the terminal width", m.diffWidth)
+	}
+
+	updated, _ := m.Update(tea.WindowSizeMsg{Width: 100, Height: 40})
+	m = finishDiff(t, updated.(model))
+	if m.diffWidth != 100 {
+		t.Errorf("after resizing, diffWidth = %d, expected 100", m.diffWidth)
+	}
+
+	m = sendKey(t, m, "u")
+	if !strings.Contains(m.View(), "+word") {
+		t.Fatal("the long line should be shown from its start")
+	}
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRight})
+	m = updated.(model)
+	if m.diffHScroll != 25 {
+		t.Errorf("diffHScroll = %d, expected a quarter of the width", m.diffHScroll)
+	}
+	for range 10 {
+		updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRight})
+		m = updated.(model)
+	}
+	if view := m.View(); strings.Contains(view, "+word") || !strings.Contains(view, "end more") {
+		t.Error("scrolling right should move the start of the long line off screen")
+	}
+	if want := utf8.RuneCountInString(long+" more") + 1 - 100; m.diffHScroll != want {
+		t.Errorf("diffHScroll = %d, expected it to stop at %d with the line's end at the edge", m.diffHScroll, want)
+	}
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyLeft})
+	m = updated.(model)
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyLeft})
+	if updated.(model).diffHScroll >= m.diffHScroll {
+		t.Error("scrolling left should move back")
+	}
+}
+
+// TestTUI_CompareAll tests walking every differing pair of a group with Enter.
+func TestTUI_CompareAll(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	var group []string
+	for name, content := range map[string]string{"a.txt": "one\n", "a-1.txt": "one\n", "a-2.txt": "two\n"} {
+		group = append(group, createFileWithContent(t, tmpDir, name, content))
+	}
+	sort.Strings(group)
+
+	m := initialModel([][]string{group}, NewDiffExecutor(""), nil)
+	m.width, m.height = 80, 40
+	m = sendKey(t, m, "a")
+
+	// a.txt and a-1.txt are identical, so only two of the three pairs are shown
+	var seen [][2]string
+	for m.state == stateViewDiff && len(seen) < 5 {
+		seen = append(seen, [2]string{m.firstFile, m.secondFile})
+		m = sendKey(t, m, "enter")
+	}
+	if len(seen) != 2 {
+		t.Fatalf("compare all showed %d pairs, expected 2: %v", len(seen), seen)
+	}
+	if m.state != stateSelectFirstFile || !strings.Contains(m.status, "1 identical") {
+		t.Errorf("after the last pair: state = %v, status = %q", m.state, m.status)
+	}
+}
+
+// identicalPairModel returns a model showing the identical-files screen for a
+// group of two files with the same content.
+func identicalPairModel(t *testing.T, dir string) model {
+	t.Helper()
+	group := []string{
+		createFileWithContent(t, dir, "notes-1.txt", "same\n"),
+		createFileWithContent(t, dir, "notes.txt", "same\n"),
+	}
+	m := initialModel([][]string{group}, NewDiffExecutor(""), nil)
+	m.width, m.height = 80, 40
+	for _, key := range []string{"enter", "enter", "enter"} {
+		m = sendKey(t, m, key)
+	}
+	if m.state != stateViewDiff || !m.identical {
+		t.Fatalf("state = %v, identical = %v; expected the identical-files screen", m.state, m.identical)
+	}
+	return m
+}
+
+// TestTUI_SessionSummary tests that the session totals count the group opened,
+// the pair compared, and the file deleted with the space it freed.
+func TestTUI_SessionSummary(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	m := identicalPairModel(t, tmpDir)
+	m = sendKey(t, m, "d")
+
+	var out bytes.Buffer
+	if err := m.session.WriteSummary(&out, m.reviewedGroups()); err != nil {
+		t.Fatalf("WriteSummary() returned error: %v", err)
+	}
+	for _, want := range []string{"Groups opened:    1\n", "Pairs compared:   1\n", "Files changed:    1 deleted\n", "Space reclaimed:  5 B\n"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("summary should contain %q:\n%s", want, out.String())
+		}
+	}
+}
+
+// TestTUI_IdenticalDelete tests deleting one file of an identical pair.
+func TestTUI_IdenticalDelete(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	m := identicalPairModel(t, tmpDir)
+	list := initialModel(m.groups, nil, nil)
+	list.width, list.height = 80, 40
+	if !strings.Contains(list.View(), "1 group, 2 files, up to 5 B reclaimable") {
+		t.Error("the group list should show the summary")
+	}
+	if !strings.Contains(m.View(), "byte-identical") {
+		t.Error("View() should say the files are byte-identical")
+	}
+
+	m = sendKey(t, m, "d")
+	if _, err := os.Stat(filepath.Join(tmpDir, "notes.txt")); !os.IsNotExist(err) {
+		t.Error("d should delete the second file")
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "notes-1.txt")); err != nil {
+		t.Error("d should keep the first file")
+	}
+	// The group had only two files, so it is gone
+	if len(m.groups) != 0 || m.state != stateSelectGroup {
+		t.Errorf("after delete: %d groups, state %v; expected the group to be removed", len(m.groups), m.state)
+	}
+}
+
+// TestTUI_IdenticalDeleteSymlinkTarget tests that the file a symbolic link of
+// the group points to isn't deleted as a copy of the link.
+func TestTUI_IdenticalDeleteSymlinkTarget(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	target := createFileWithContent(t, tmpDir, "notes.txt", "same\n")
+	link := filepath.Join(tmpDir, "notes-1.txt")
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	m := initialModel([][]string{{link, target}}, NewDiffExecutor(""), nil)
+	m.width, m.height = 80, 40
+	for _, key := range []string{"enter", "enter", "enter"} {
+		m = sendKey(t, m, key)
+	}
+	if !m.identical || m.hardlinked {
+		t.Fatalf("identical = %v, hardlinked = %v; expected a link to count as a separate identical file", m.identical, m.hardlinked)
+	}
+
+	m = sendKey(t, m, "d")
+	if _, err := os.Stat(target); err != nil {
+		t.Fatal("d should not delete the file the link points to")
+	}
+	if !strings.Contains(m.status, "notes-1.txt is a symbolic link to notes.txt") {
+		t.Errorf("status = %q, expected the refusal to name the link", m.status)
+	}
+	m = sendKey(t, m, "D")
+	if _, err := os.Stat(link); !os.IsNotExist(err) {
+		t.Error("D should delete the link itself")
+	}
+	if _, err := os.Stat(target); err != nil {
+		t.Errorf("deleting the link should keep its target: %v", err)
+	}
+}
+
+// TestTUI_ChecksumChangedBeforeDelete tests that with --hash, a file rewritten
+// after its checksum was shown is not deleted until the new checksums are seen.
+func TestTUI_ChecksumChangedBeforeDelete(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	group := []string{
+		createFileWithContent(t, tmpDir, "notes-1.txt", "hello\n"),
+		createFileWithContent(t, tmpDir, "notes.txt", "hello\n"),
+	}
+	m := initialModel([][]string{group}, NewDiffExecutor(""), nil)
+	m.checksums = NewChecksums(hashSHA256)
+	m.width, m.height = 80, 40
+	m = sendKey(t, m, "enter")
+	if view := m.View(); !strings.Contains(view, "5891b5b522d5") {
+		t.Fatalf("the file list should show checksums:\n%s", view)
+	}
+	m = sendKey(t, m, "enter")
+	m = sendKey(t, m, "enter")
+
+	// A sync client rewrites both files the same way
+	createFileWithContent(t, tmpDir, "notes-1.txt", "hello again\n")
+	createFileWithContent(t, tmpDir, "notes.txt", "hello again\n")
+	m = sendKey(t, m, "d")
+	if _, err := os.Stat(group[1]); err != nil {
+		t.Fatal("d should not delete a file whose checksum changed")
+	}
+	if !strings.Contains(m.status, "changed since their sha256 checksums were shown") {
+		t.Errorf("status = %q, expected the change to be reported", m.status)
+	}
+
+	m = sendKey(t, m, "d")
+	if _, err := os.Stat(group[1]); !os.IsNotExist(err) {
+		t.Error("d should delete the file once its new checksum was shown")
+	}
+}
+
+// TestTUI_FrontmatterOnlyDelete tests deleting one of two notes whose frontmatter
+// alone differs.
+func TestTUI_FrontmatterOnlyDelete(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	group := []string{
+		createFileWithContent(t, tmpDir, "notes-1.md", "---\nmodified: 2024-01-30\n---\nmilk\n"),
+		createFileWithContent(t, tmpDir, "notes.md", "---\nmodified: 2024-02-02\n---\nmilk\n"),
+	}
+	exec := NewDiffExecutor("")
+	exec.SetFrontmatter(true)
+	m := initialModel([][]string{group}, exec, nil)
+	m.width, m.height = 80, 40
+	for _, key := range []string{"enter", "enter", "enter"} {
+		m = sendKey(t, m, key)
+	}
+	if m.identical || !m.frontmatterOnly {
+		t.Fatalf("identical = %v, frontmatterOnly = %v; expected a pair differing only in frontmatter", m.identical, m.frontmatterOnly)
+	}
+	if view := m.View(); !strings.Contains(view, "only frontmatter differs (modified)") || !strings.Contains(view, "d: delete File 2") {
+		t.Errorf("View() should report the frontmatter change and offer deleting:\n%s", view)
+	}
+
+	m = sendKey(t, m, "d")
+	if _, err := os.Stat(filepath.Join(tmpDir, "notes.md")); !os.IsNotExist(err) {
+		t.Error("d should delete the second note")
+	}
+	if len(m.groups) != 0 || m.state != stateSelectGroup {
+		t.Errorf("after delete: %d groups, state %v; expected the group to be removed", len(m.groups), m.state)
+	}
+}
+
+// TestTUI_IdenticalHardlink tests replacing one file of an identical pair with a hard link.
+func TestTUI_IdenticalHardlink(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	m := identicalPairModel(t, tmpDir)
+	if m.summary.Reclaimable != 5 {
+		t.Errorf("summary.Reclaimable = %d before linking, expected 5", m.summary.Reclaimable)
+	}
+	m = sendKey(t, m, "h")
+	if m.summary.Reclaimable != 0 {
+		t.Errorf("summary.Reclaimable = %d after linking, expected 0", m.summary.Reclaimable)
+	}
+
+	info1, err1 := os.Stat(filepath.Join(tmpDir, "notes-1.txt"))
+	info2, err2 := os.Stat(filepath.Join(tmpDir, "notes.txt"))
+	if err1 != nil || err2 != nil {
+		t.Fatalf("both files should still exist: %v, %v", err1, err2)
+	}
+	if !os.SameFile(info1, info2) {
+		t.Error("h should hard link the second file to the first")
+	}
+	if m.state != stateSelectFirstFile || len(m.groups) != 1 {
+		t.Errorf("after hardlink: state %v, %d groups; expected the file selection", m.state, len(m.groups))
+	}
+}
+
+// TestTUI_CompareAllShowIdentical tests stopping at identical pairs when skipping is off.
+func TestTUI_CompareAllShowIdentical(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	group := []string{
+		createFileWithContent(t, tmpDir, "a-1.txt", "one\n"),
+		createFileWithContent(t, tmpDir, "a.txt", "one\n"),
+	}
+	m := initialModel([][]string{group}, NewDiffExecutor(""), nil)
+	m.width, m.height = 80, 40
+	m.skipIdentical = false
+
+	m = sendKey(t, m, "a")
+	if m.state != stateViewDiff || !m.identical {
+		t.Errorf("state = %v, identical = %v; expected the identical pair to be shown", m.state, m.identical)
+	}
+}
+
+// TestTUI_Hardlinks tests that hard-linked files are labeled and left out of compare-all.
+func TestTUI_Hardlinks(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	original := createFileWithContent(t, tmpDir, "notes.txt", "one\n")
+	other := createFileWithContent(t, tmpDir, "notes-1.txt", "two\n")
+	link := filepath.Join(tmpDir, "notes-2.txt")
+	if err := os.Link(original, link); err != nil {
+		t.Skipf("hard links not supported: %v", err)
+	}
+
+	m := initialModel([][]string{{original, other, link}}, NewDiffExecutor(""), nil)
+	m.width, m.height = 80, 40
+	m = sendKey(t, m, "enter")
+	if view := m.View(); !strings.Contains(view, "(hard link of notes.txt)") {
+		t.Errorf("file list should label the hard link:\n%s", view)
+	}
+
+	// Compare-all shows the two real pairs and skips the linked one
+	m = sendKey(t, m, "a")
+	shown := 0
+	for m.state == stateViewDiff {
+		shown++
+		m = sendKey(t, m, "enter")
+	}
+	if shown != 2 || !strings.Contains(m.status, "1 already hard-linked") {
+		t.Errorf("compare-all showed %d pairs with status %q; expected 2 and one linked pair skipped", shown, m.status)
+	}
+
+	// Comparing the linked pair directly says so and offers no hard link action
+	m = m.showPair(original, link)
+	view := m.View()
+	if !m.hardlinked || !strings.Contains(view, "already deduplicated") || strings.Contains(view, "h: replace") {
+		t.Errorf("linked pair view should say the files are already deduplicated:\n%s", view)
+	}
+}
+
+// TestDisplayPath tests base names and root-relative paths.
+func TestDisplayPath(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		root string
+		full bool
+		want string
+	}{
+		{"Base name", "/scan/a/notes.txt", "/scan", false, "notes.txt"},
+		{"Relative to root", "/scan/a/notes.txt", "/scan", true, filepath.Join("a", "notes.txt")},
+		{"Outside root", "/other/notes.txt", "/scan", true, "/other/notes.txt"},
+		{"No root", "b/notes.txt", "", true, "b/notes.txt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := displayPath(tt.file, tt.root, tt.full); got != tt.want {
+				t.Errorf("displayPath() = %q, expected %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestTUI_ToggleFullPaths tests that p switches between base names and paths.
+func TestTUI_ToggleFullPaths(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	for _, sub := range []string{"2023", "2024"} {
+		if err := os.Mkdir(filepath.Join(tmpDir, sub), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	groups := [][]string{{
+		createFileWithContent(t, tmpDir, filepath.Join("2023", "notes.txt"), "old\n"),
+		createFileWithContent(t, tmpDir, filepath.Join("2024", "notes.txt"), "new\n"),
+	}}
+	m := initialModel(groups, NewDiffExecutor(""), nil)
+	m.displayRoot = tmpDir
+	m.width, m.height = 80, 40
+	m = sendKey(t, m, "enter")
+
+	if strings.Contains(m.View(), filepath.Join("2023", "notes.txt")) {
+		t.Error("files should show as base names by default")
+	}
+	m = sendKey(t, m, "p")
+	view := m.View()
+	if !strings.Contains(view, filepath.Join("2023", "notes.txt")) || !strings.Contains(view, filepath.Join("2024", "notes.txt")) {
+		t.Errorf("p should show paths relative to the scanned directory:\n%s", view)
+	}
+	m = sendKey(t, m, "p")
+	if strings.Contains(m.View(), filepath.Join("2023", "notes.txt")) {
+		t.Error("p again should go back to base names")
+	}
+}
+
+// TestTUI_Rename tests renaming the highlighted file from the file selection.
+func TestTUI_Rename(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	keep := createFileWithContent(t, tmpDir, "notes 2.txt", "new\n")
+	other := createFileWithContent(t, tmpDir, "notes.txt", "old\n")
+
+	m := initialModel([][]string{{keep, other}}, NewDiffExecutor(""), nil)
+	m.width, m.height = 80, 40
+	m = sendKey(t, m, "enter")
+
+	m = sendKey(t, m, "r")
+	if m.pending == nil || m.pending.input.Value() != "notes 2.txt" {
+		t.Fatal("r should ask for a name pre-filled with the current one")
+	}
+	if !strings.Contains(m.View(), "Rename to: ") {
+		t.Error("View() should show the rename prompt")
+	}
+
+	// The taken name is refused
+	m = sendKey(t, m, "esc")
+	m = sendKey(t, m, "r")
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyCtrlU})
+	m = updated.(model)
+	m = sendKey(t, m, "notes.txt")
+	m = sendKey(t, m, "enter")
+	if !strings.Contains(m.status, "already exists") {
+		t.Errorf("status = %q, expected the name to be refused", m.status)
+	}
+
+	m = sendKey(t, m, "r")
+	for range len(" 2.txt") {
+		updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyBackspace})
+		m = updated.(model)
+	}
+	m = sendKey(t, m, "-final.txt")
+	m = sendKey(t, m, "enter")
+
+	renamed := filepath.Join(tmpDir, "notes-final.txt")
+	if _, err := os.Stat(renamed); err != nil {
+		t.Fatalf("file should be renamed: %v (status %q)", err, m.status)
+	}
+	if _, err := os.Stat(keep); !os.IsNotExist(err) {
+		t.Error("the old name should be gone")
+	}
+	if got := m.groups[0]; got[0] != renamed || got[1] != other {
+		t.Errorf("group = %v, expected the renamed file in place", got)
+	}
+	if m.pending != nil || m.state != stateSelectFirstFile {
+		t.Error("the view should return to the file selection")
+	}
+}
+
+// TestCheckFileName tests the names accepted when renaming.
+func TestCheckFileName(t *testing.T) {
+	for _, name := range []string{"notes.txt", "notes 2.txt", ".hidden"} {
+		if err := checkFileName(name); err != nil {
+			t.Errorf("checkFileName(%q) returned error: %v", name, err)
+		}
+	}
+	for _, name := range []string{"", " ", ".", "..", "a/b.txt"} {
+		if err := checkFileName(name); err == nil {
+			t.Errorf("checkFileName(%q) should return an error", name)
+		}
+	}
+}
+
+// TestTUI_Move tests moving the highlighted file within and out of the scanned directory.
+func TestTUI_Move(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	scanDir := filepath.Join(tmpDir, "scan")
+	if err := os.Mkdir(scanDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	group := []string{
+		createFileWithContent(t, scanDir, "notes.txt", "a\n"),
+		createFileWithContent(t, scanDir, "notes 2.txt", "b\n"),
+		createFileWithContent(t, scanDir, "notes 3.txt", "c\n"),
+	}
+	m := initialModel([][]string{group}, NewDiffExecutor(""), nil)
+	m.displayRoot = scanDir
+	m.width, m.height = 80, 40
+	m = sendKey(t, m, "enter")
+
+	// Within the scanned directory the file keeps its place
+	m = sendKey(t, m, "down")
+	m = sendKey(t, m, "M")
+	m = sendKey(t, m, filepath.Join(scanDir, "old"))
+	m = sendKey(t, m, "enter")
+	moved := filepath.Join(scanDir, "old", "notes 2.txt")
+	if got := m.groups[0]; len(got) != 3 || got[1] != moved {
+		t.Fatalf("group = %v, expected %s in place (status %q)", got, moved, m.status)
+	}
+
+	// Out of it, the file leaves the group; the last destination is offered again
+	quarantine := filepath.Join(tmpDir, "quarantine")
+	m = sendKey(t, m, "down")
+	m = sendKey(t, m, "M")
+	if m.pending.input.Value() != filepath.Join(scanDir, "old") {
+		t.Errorf("prompt = %q, expected the last destination", m.pending.input.Value())
+	}
+	m.pending.input = newLineInput("Move to: ", quarantine)
+	m = sendKey(t, m, "enter")
+	if _, err := os.Stat(filepath.Join(quarantine, "notes 3.txt")); err != nil {
+		t.Fatalf("file should be in the quarantine: %v (status %q)", err, m.status)
+	}
+	if got := m.groups[0]; len(got) != 2 || m.cursor != 1 {
+		t.Errorf("group = %v, cursor = %d; expected the moved file dropped", got, m.cursor)
+	}
+}
+
+// bulkModel returns a model showing the file selection of a group of four
+// files, of which the first three are identical.
+func bulkModel(t *testing.T, dir string) model {
+	t.Helper()
+	group := []string{
+		createFileWithContent(t, dir, "notes.txt", "same\n"),
+		createFileWithContent(t, dir, "notes 2.txt", "same\n"),
+		createFileWithContent(t, dir, "notes 3.txt", "same\n"),
+		createFileWithContent(t, dir, "notes 4.txt", "other\n"),
+	}
+	m := initialModel([][]string{group}, NewDiffExecutor(""), nil)
+	m.displayRoot = dir
+	m.width, m.height = 80, 40
+	return sendKey(t, m, "enter")
+}
+
+// TestTUI_BulkDelete tests selecting files with Space and deleting them together.
+func TestTUI_BulkDelete(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	m := bulkModel(t, tmpDir)
+	m = sendKey(t, m, "down")
+	m = sendKey(t, m, " ")
+	m = sendKey(t, m, " ")
+	if m.cursor != 3 {
+		t.Errorf("cursor = %d after selecting two files, expected 3", m.cursor)
+	}
+	view := m.View()
+	for _, want := range []string{"[ ] notes.txt", "[x] notes 2.txt", "[x] notes 3.txt", "2 files selected (10 B)"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("View() should contain %q:\n%s", want, view)
+		}
+	}
+
+	// Anything but y cancels
+	m = sendKey(t, m, "d")
+	m = sendKey(t, m, "enter")
+	if len(m.groups[0]) != 4 || m.status != "Nothing was deleted" {
+		t.Fatalf("group = %v, status = %q; expected nothing deleted", m.groups[0], m.status)
+	}
+
+	m = sendKey(t, m, "d")
+	if m.pending == nil || !strings.Contains(m.pending.input.View(), "Delete 2 selected files?") {
+		t.Fatal("d should ask to confirm deleting the selected files")
+	}
+	if strings.Contains(m.pending.input.View(), "will be lost") {
+		t.Error("deleting copies of an unselected file shouldn't warn about lost contents")
+	}
+	m = sendKey(t, m, "y")
+	m = sendKey(t, m, "enter")
+	for _, name := range []string{"notes 2.txt", "notes 3.txt"} {
+		if _, err := os.Stat(filepath.Join(tmpDir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s should be deleted", name)
+		}
+	}
+	if got := m.groups[0]; len(got) != 2 || len(m.selected) != 0 || m.status != "Deleted 2 files" {
+		t.Errorf("group = %v, selected = %v, status = %q", got, m.selected, m.status)
+	}
+
+	// Every file of a group can't be deleted at once
+	m = sendKey(t, m, "home")
+	m = sendKey(t, m, " ")
+	m = sendKey(t, m, " ")
+	m = sendKey(t, m, "d")
+	if m.pending != nil || !strings.Contains(m.status, "at least one file") {
+		t.Errorf("status = %q; expected deleting the whole group to be refused", m.status)
+	}
+
+	// A selected file unlike every unselected one is deleted only after a warning
+	m = sendKey(t, m, "esc")
+	m = sendKey(t, m, "down")
+	m = sendKey(t, m, " ")
+	m = sendKey(t, m, "d")
+	if m.pending == nil || !strings.Contains(m.pending.input.View(), "1 differ from every file left and their contents will be lost") {
+		t.Fatal("d should warn that the contents of a selected file without a copy will be lost")
+	}
+	m = sendKey(t, m, "enter")
+	m = sendKey(t, m, "esc")
+	if len(m.selected) != 0 || m.state != stateSelectFirstFile {
+		t.Errorf("Esc should clear the selection first (selected %v, state %v)", m.selected, m.state)
+	}
+}
+
+// TestTUI_SplitAndMergeGroups tests correcting the grouping by hand: splitting
+// files off into a group of their own and merging it into another group.
+func TestTUI_SplitAndMergeGroups(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	m := bulkModel(t, tmpDir)
+	group := append([]string{}, m.groups[0]...)
+	todo := []string{
+		createFileWithContent(t, tmpDir, "todo.txt", "a\n"),
+		createFileWithContent(t, tmpDir, "todo 2.txt", "b\n"),
+	}
+	m.groups = append(m.groups, todo)
+
+	// Split the selected files off
+	m = sendKey(t, m, "down")
+	m = sendKey(t, m, " ")
+	m = sendKey(t, m, " ")
+	m = sendKey(t, m, "S")
+	expected := [][]string{{group[0], group[3]}, {group[1], group[2]}, todo}
+	if !reflect.DeepEqual(m.groups, expected) || m.status != "Split 2 files off into group 2" {
+		t.Fatalf("groups = %v, status = %q; expected %v", m.groups, m.status, expected)
+	}
+
+	// Every file of a group can't be split off
+	m = sendKey(t, m, "home")
+	m = sendKey(t, m, " ")
+	m = sendKey(t, m, " ")
+	m = sendKey(t, m, "S")
+	if len(m.groups) != 3 || !strings.Contains(m.status, "at least one file") {
+		t.Errorf("status = %q; expected splitting the whole group off to be refused", m.status)
+	}
+
+	// Merge the todo group into the split-off group
+	m = sendKey(t, m, "esc")
+	m = sendKey(t, m, "esc")
+	m = sendKey(t, m, "end")
+	m = sendKey(t, m, "J")
+	if !strings.Contains(m.View(), "⇢ merging") {
+		t.Errorf("the picked group should be marked:\n%s", m.View())
+	}
+	m = sendKey(t, m, "up")
+	m = sendKey(t, m, "J")
+	expected = [][]string{{group[0], group[3]}, {group[1], group[2], todo[0], todo[1]}}
+	if !reflect.DeepEqual(m.groups, expected) || m.cursor != 1 || m.mergeSource != "" {
+		t.Fatalf("groups = %v, cursor = %d; expected %v", m.groups, m.cursor, expected)
+	}
+
+	// Esc cancels a merge
+	m = sendKey(t, m, "J")
+	m = sendKey(t, m, "esc")
+	if m.mergeSource != "" || m.status != "Merge cancelled" {
+		t.Errorf("Esc should cancel the merge, status = %q", m.status)
+	}
+
+	var out bytes.Buffer
+	if err := m.session.WriteSummary(&out, 0); err != nil {
+		t.Fatalf("WriteSummary() returned error: %v", err)
+	}
+	for _, want := range []string{
+		"Groups edited:    2\n",
+		"  split " + group[1] + ", " + group[2] + " off into a group of their own\n",
+		"  merged the group of " + todo[0] + " into the group of " + group[1] + "\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("summary should contain %q:\n%s", want, out.String())
+		}
+	}
+}
+
+// TestTUI_BulkHardlink tests linking the selected files to the highlighted one,
+// leaving out a file with different content.
+func TestTUI_BulkHardlink(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	m := bulkModel(t, tmpDir)
+	m = sendKey(t, m, "down")
+	for range 3 {
+		m = sendKey(t, m, " ")
+	}
+	m = sendKey(t, m, "home")
+	m = sendKey(t, m, "h")
+	if want := "Replaced 2 files with hard links to notes.txt; 1 differ from it and were left alone"; m.status != want {
+		t.Errorf("status = %q, expected %q", m.status, want)
+	}
+	group := m.getCurrentGroup()
+	if !sameFile(group[0], group[1]) || !sameFile(group[0], group[2]) || sameFile(group[0], group[3]) {
+		t.Error("the identical selected files should be hard links to the highlighted file")
+	}
+	if len(m.selected) != 0 {
+		t.Errorf("selected = %v after linking, expected none", m.selected)
+	}
+}
+
+// TestTUI_BulkMove tests moving the selected files together.
+func TestTUI_BulkMove(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	m := bulkModel(t, tmpDir)
+	m = sendKey(t, m, " ")
+	m = sendKey(t, m, "down")
+	m = sendKey(t, m, " ")
+	m = sendKey(t, m, "M")
+	m = sendKey(t, m, filepath.Join(tmpDir, "old"))
+	m = sendKey(t, m, "enter")
+	for _, name := range []string{"notes.txt", "notes 3.txt"} {
+		if _, err := os.Stat(filepath.Join(tmpDir, "old", name)); err != nil {
+			t.Errorf("%s should be moved: %v", name, err)
+		}
+	}
+	if got := m.groups[0]; len(got) != 4 || got[0] != filepath.Join(tmpDir, "old", "notes.txt") {
+		t.Errorf("group = %v, expected the moved files in place", got)
+	}
+	if want := "Moved 2 files to " + filepath.Join(tmpDir, "old"); m.status != want {
+		t.Errorf("status = %q, expected %q", m.status, want)
+	}
+}
+
+// TestTUI_StatusBar tests the bar with the scan context above the help line.
+func TestTUI_StatusBar(t *testing.T) {
+	groups := [][]string{{"/notes/a.txt", "/notes/a-1.txt"}, {"/notes/b.txt", "/notes/b-1.txt"}}
+	m := initialModel(groups, NewDiffExecutor(""), nil)
+	m.width, m.height = 120, 40
+	m.displayRoot = "/notes"
+	m.filters = []string{"ext txt"}
+	m.scan, m.scanDone = scanResult{groups: groups, fileCount: 7}, true
+
+	if view := m.View(); !strings.Contains(view, " /notes · 7 files · 2 groups · ext txt · group 1/2 ") {
+		t.Errorf("View() should show the scan context:\n%s", view)
+	}
+	m = sendKey(t, m, "down")
+	if view := m.View(); !strings.Contains(view, "group 2/2") {
+		t.Errorf("the status bar should follow the highlighted group:\n%s", view)
+	}
+	m = sendKey(t, m, "enter")
+	if view := m.View(); !strings.Contains(view, "group 2/2") {
+		t.Errorf("the status bar should show the open group:\n%s", view)
+	}
+
+	m.width = 20
+	if bar := m.renderStatusBar(); !strings.Contains(bar, " /notes · 7 files ·…") {
+		t.Errorf("renderStatusBar() = %q, expected it cut to the terminal width", bar)
+	}
+}
+
+// TestTUI_KeyHelp tests opening the help screen with ? and closing it with any
+// key, which is not acted on otherwise.
+func TestTUI_KeyHelp(t *testing.T) {
+	groups := [][]string{{"/notes/a.txt", "/notes/a-1.txt"}, {"/notes/b.txt", "/notes/b-1.txt"}}
+	m := initialModel(groups, NewDiffExecutor(""), nil)
+	m.width, m.height = 80, 60
+	if !strings.Contains(m.View(), "?: help") {
+		t.Error("the hint line should mention ?")
+	}
+
+	m = sendKey(t, m, "?")
+	if view := m.View(); !strings.Contains(view, "Group list") || !strings.Contains(view, "ignore the group on future runs") {
+		t.Fatalf("? should open the help screen:\n%s", view)
+	}
+	m = sendKey(t, m, "down")
+	if m.showKeys || m.cursor != 0 {
+		t.Errorf("showKeys = %v, cursor = %d; expected the key to only close the help", m.showKeys, m.cursor)
+	}
+	m = sendKey(t, m, "?")
+	updated, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("q")})
+	if m = updated.(model); m.showKeys || cmd != nil {
+		t.Error("q should close the help without quitting")
+	}
+}
+
+// TestTUI_Undo tests that u brings back a quarantined file into its group.
+func TestTUI_Undo(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	t.Setenv(journalEnvVar, filepath.Join(tmpDir, "journal"))
+	scanDir := filepath.Join(tmpDir, "scan")
+	if err := os.Mkdir(scanDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	m := identicalPairModel(t, scanDir)
+	group := m.groups[0]
+	q, err := NewQuarantine(filepath.Join(tmpDir, "quarantine"), scanDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.quarantine, m.journal = q, NewJournal()
+
+	m = sendKey(t, m, "d")
+	if len(m.groups) != 0 {
+		t.Fatalf("groups = %v, expected the group dissolved (status %q)", m.groups, m.status)
+	}
+	m = sendKey(t, m, "u")
+	if !reflect.DeepEqual(m.groups, [][]string{group}) {
+		t.Errorf("groups after undo = %v, expected %v (status %q)", m.groups, group, m.status)
+	}
+	if !strings.HasPrefix(m.status, "Undone: quarantined") {
+		t.Errorf("status = %q, expected the undone action", m.status)
+	}
+	m = sendKey(t, m, "u")
+	if m.status != "Nothing to undo" {
+		t.Errorf("status = %q, expected nothing left to undo", m.status)
+	}
+}
+
+// TestTUI_SuggestKeeper tests that the newest file of a group is marked as the
+// likely keeper in the file selection and the expanded group list.
+func TestTUI_SuggestKeeper(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	group := []string{
+		createFileWithContent(t, tmpDir, "notes-1.txt", "old\n"),
+		createFileWithContent(t, tmpDir, "notes.txt", "new\n"),
+	}
+	old := time.Date(2024, 1, 30, 0, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(group[0], old, old); err != nil {
+		t.Fatal(err)
+	}
+	m := initialModel([][]string{group}, NewDiffExecutor(""), nil)
+	m.keeper = &Keeper{Policy: KeepNewest}
+	m.width, m.height = 80, 40
+
+	m = sendKey(t, m, "tab")
+	if !strings.Contains(m.View(), "notes.txt ★") {
+		t.Errorf("the expanded group should mark the newest file:\n%s", m.View())
+	}
+	m = sendKey(t, m, "enter")
+	for _, line := range strings.Split(m.View(), "\n") {
+		if strings.Contains(line, "likely keeper") && !strings.Contains(line, "notes.txt") {
+			t.Errorf("the wrong file is marked: %q", line)
+		}
+	}
+	if !strings.Contains(m.View(), "★ likely keeper") {
+		t.Errorf("the file selection should mark the newest file:\n%s", m.View())
+	}
+}
+
+// TestTUI_ExplainPair tests that E explains the compared pair until a key closes it.
+func TestTUI_ExplainPair(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	m := identicalPairModel(t, tmpDir)
+
+	m = sendKey(t, m, "E")
+	view := m.View()
+	if !strings.Contains(view, "Why these files are grouped") ||
+		!strings.Contains(view, `the common prefix "notes" is 5 bytes, at least --min-prefix 3`) {
+		t.Fatalf("E should show the explanation:\n%s", view)
+	}
+	m = sendKey(t, m, "q")
+	if m.explanation != nil || m.state != stateViewDiff {
+		t.Errorf("a key should close the explanation and stay in the diff view")
+	}
+}
+
+// TestTUI_ActionMenu tests opening, navigating, and closing the action menu.
+func TestTUI_ActionMenu(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	group := []string{
+		createFileWithContent(t, tmpDir, "notes.md", "one\n"),
+		createFileWithContent(t, tmpDir, "notes 2.md", "two\n"),
+	}
+	m := initialModel([][]string{group}, NewDiffExecutor(""), nil)
+	m.width, m.height = 80, 40
+
+	m = sendKey(t, m, "A")
+	if m.actionMenu != nil || !strings.Contains(m.status, "No actions configured") {
+		t.Fatalf("A without actions should say so, status = %q", m.status)
+	}
+
+	var err error
+	m.actions, err = NewGroupActions([]ActionConfig{
+		{Name: "Open in Obsidian", Command: "open {file}"},
+		{Name: "git log", Command: "git log {file}"},
+	})
+	if err != nil {
+		t.Fatalf("NewGroupActions() returned error: %v", err)
+	}
+	m = sendKey(t, m, "enter")
+	m = sendKey(t, m, "down")
+	m = sendKey(t, m, "A")
+	if m.actionMenu == nil || m.actionMenu.file != group[1] {
+		t.Fatalf("A should open the menu for the highlighted file, menu = %+v", m.actionMenu)
+	}
+	m = sendKey(t, m, "down")
+	view := m.View()
+	if !strings.Contains(view, "Actions for notes 2.md") || !strings.Contains(view, "> 2  git log") {
+		t.Errorf("the menu should highlight the second action:\n%s", view)
+	}
+
+	m = sendKey(t, m, "esc")
+	if m.actionMenu != nil || m.state != stateSelectFirstFile {
+		t.Errorf("Esc should close the menu and stay in the file list")
+	}
+}
+
+// TestTUI_DiffCommitted tests that H diffs a file against its committed
+// version, which can't be deleted, and that Esc returns to the file.
+func TestTUI_DiffCommitted(t *testing.T) {
+	dir := gitTestRepo(t)
+	group := []string{
+		createFileWithContent(t, dir, "notes 2.md", "edited\n"),
+		createFileWithContent(t, dir, "notes.md", "changed\n"),
+	}
+	repo, err := FindGitRepo(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Cleanup()
+	m := initialModel([][]string{group}, NewDiffExecutor(""), nil)
+	m.width, m.height = 100, 40
+	m.git, m.gitStatus = repo, true
+
+	m = sendKey(t, m, "enter")
+	if view := m.View(); !strings.Contains(view, "notes 2.md  git: untracked") || !strings.Contains(view, "notes.md  git: modified") {
+		t.Errorf("the file list should show the git status:\n%s", view)
+	}
+
+	m = sendKey(t, m, "H")
+	if !strings.Contains(m.status, "not in HEAD") {
+		t.Errorf("H on an untracked file should say it isn't committed, status = %q", m.status)
+	}
+	m = sendKey(t, m, "down")
+	m = sendKey(t, m, "H")
+	if m.state != stateViewDiff || filepath.Base(m.firstFile) != "notes@HEAD.md" || m.secondFile != group[1] {
+		t.Fatalf("H should diff the committed version against the file, got %s vs %s", m.firstFile, m.secondFile)
+	}
+	if !strings.Contains(m.diffOutput, "committed") || !strings.Contains(m.diffOutput, "changed") {
+		t.Errorf("unexpected diff:\n%s", m.diffOutput)
+	}
+	m = sendKey(t, m, "D")
+	if _, err := os.Stat(m.firstFile); err != nil || !strings.Contains(m.status, "can't be deleted") {
+		t.Errorf("D should refuse to delete the committed version, status = %q", m.status)
+	}
+
+	m = sendKey(t, m, "esc")
+	if m.state != stateSelectFirstFile || m.cursor != 1 || m.revisionFile != "" {
+		t.Errorf("Esc should return to the file list at notes.md, state = %v, cursor = %d", m.state, m.cursor)
+	}
+}
+
+// TestTUI_DiffRevision tests that L on a copy never committed lists the
+// revisions of its group's committed file and diffs the copy against one.
+func TestTUI_DiffRevision(t *testing.T) {
+	dir := gitTestRepo(t)
+	group := []string{
+		createFileWithContent(t, dir, "notes 2.md", "edited\n"),
+		filepath.Join(dir, "notes.md"),
+	}
+	repo, err := FindGitRepo(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Cleanup()
+	m := initialModel([][]string{group}, NewDiffExecutor(""), nil)
+	m.width, m.height = 100, 40
+	m.git = repo
+
+	m = sendKey(t, m, "enter")
+	m = sendKey(t, m, "L")
+	if m.revisionMenu == nil || m.revisionMenu.source != group[1] || len(m.revisionMenu.revisions) != 1 {
+		t.Fatalf("L should list the revisions of notes.md, menu = %+v, status = %q", m.revisionMenu, m.status)
+	}
+	view := m.View()
+	if !strings.Contains(view, "Revisions of notes.md to compare with notes 2.md") || !strings.Contains(view, "  notes") {
+		t.Errorf("unexpected revision list:\n%s", view)
+	}
+
+	m = sendKey(t, m, "enter")
+	if m.revisionMenu != nil || m.state != stateViewDiff || m.secondFile != group[0] || !strings.HasPrefix(filepath.Base(m.firstFile), "notes@") {
+		t.Fatalf("Enter should diff notes 2.md against the revision, got %s vs %s", m.firstFile, m.secondFile)
+	}
+	if !strings.Contains(m.diffOutput, "committed") || !strings.Contains(m.diffOutput, "edited") {
+		t.Errorf("unexpected diff:\n%s", m.diffOutput)
+	}
+}
+
+// TestTUI_ArchiveMember tests comparing a file with its copy inside a zip
+// archive, which is extracted for the comparison and can't be deleted.
+func TestTUI_ArchiveMember(t *testing.T) {
+	tmpDir := createTempDir(t)
+	defer os.RemoveAll(tmpDir)
+	notes := createFileWithContent(t, tmpDir, "notes.md", "one\n")
+	backup := createZip(t, tmpDir, "backup.zip", map[string]string{"notes.md": "one\n"})
+	member := backup + "!/notes.md"
+	archives := NewArchiveFiles()
+	defer archives.Cleanup()
+	m := initialModel([][]string{{notes, member}}, NewDiffExecutor(""), nil)
+	m.width, m.height = 100, 40
+	m.archives = archives
+	m.fullPaths, m.displayRoot = true, tmpDir
+
+	m = sendKey(t, m, "enter")
+	m = sendKey(t, m, "down")
+	m = sendKey(t, m, "r")
+	if m.pending != nil || m.status != archiveMemberRefusal {
+		t.Errorf("renaming a file inside an archive should be refused, status = %q", m.status)
+	}
+	m = sendKey(t, m, "up")
+	m = sendKey(t, m, "enter")
+	m = sendKey(t, m, "down")
+	m = sendKey(t, m, "enter")
+	if m.state != stateViewDiff || !m.identical || !archives.Extracted(m.secondFile) {
+		t.Fatalf("the copy should be extracted and found identical, state = %v, second = %s", m.state, m.secondFile)
+	}
+	if view := m.View(); !strings.Contains(view, "File 2: backup.zip!/notes.md") {
+		t.Errorf("the copy should be shown by its path inside the archive:\n%s", view)
+	}
+
+	m = sendKey(t, m, "d")
+	if _, err := os.Stat(m.secondFile); err != nil || m.status != archiveMemberRefusal {
+		t.Errorf("d should refuse to delete the extracted copy, status = %q", m.status)
+	}
+	m = sendKey(t, m, "esc")
+	if m.state != stateSelectSecondFile || m.firstFile != notes {
+		t.Errorf("Esc should return to choosing the second file, state = %v, first = %s", m.state, m.firstFile)
+	}
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// XXH3 is a fast non-cryptographic hash, offered by --hash for large files
+// where SHA-256 would be slow. This is the 64-bit variant with the default
+// secret and seed 0, matching "xxhsum -H3".
+
+const (
+	xxh3StripeLen     = 64
+	xxh3SecretRate    = 8
+	xxh3Accs          = 8
+	xxh3MidSizeMax    = 240
+	xxh3SecretSizeMin = 136
+	xxh3BufferSize    = 256
+	// xxh3StripesPerBlock is how many stripes are accumulated between scrambles.
+	xxh3StripesPerBlock = (len(xxh3Secret) - xxh3StripeLen) / xxh3SecretRate
+
+	xxhPrime32_1 = 0x9E3779B1
+	xxhPrime32_2 = 0x85EBCA77
+	xxhPrime32_3 = 0xC2B2AE3D
+	xxhPrime64_1 = 0x9E3779B185EBCA87
+	xxhPrime64_2 = 0xC2B2AE3D27D4EB4F
+	xxhPrime64_3 = 0x165667B19E3779F9
+	xxhPrime64_4 = 0x85EBCA77C2B2AE63
+	xxhPrime64_5 = 0x27D4EB2F165667C5
+)
+
+// xxh3Secret is the default secret of XXH3.
+var xxh3Secret = [192]byte{
+	0xb8, 0xfe, 0x6c, 0x39, 0x23, 0xa4, 0x4b, 0xbe, 0x7c, 0x01, 0x81, 0x2c, 0xf7, 0x21, 0xad, 0x1c,
+	0xde, 0xd4, 0x6d, 0xe9, 0x83, 0x90, 0x97, 0xdb, 0x72, 0x40, 0xa4, 0xa4, 0xb7, 0xb3, 0x67, 0x1f,
+	0xcb, 0x79, 0xe6, 0x4e, 0xcc, 0xc0, 0xe5, 0x78, 0x82, 0x5a, 0xd0, 0x7d, 0xcc, 0xff, 0x72, 0x21,
+	0xb8, 0x08, 0x46, 0x74, 0xf7, 0x43, 0x24, 0x8e, 0xe0, 0x35, 0x90, 0xe6, 0x81, 0x3a, 0x26, 0x4c,
+	0x3c, 0x28, 0x52, 0xbb, 0x91, 0xc3, 0x00, 0xcb, 0x88, 0xd0, 0x65, 0x8b, 0x1b, 0x53, 0x2e, 0xa3,
+	0x71, 0x64, 0x48, 0x97, 0xa2, 0x0d, 0xf9, 0x4e, 0x38, 0x19, 0xef, 0x46, 0xa9, 0xde, 0xac, 0xd8,
+	0xa8, 0xfa, 0x76, 0x3f, 0xe3, 0x9c, 0x34, 0x3f, 0xf9, 0xdc, 0xbb, 0xc7, 0xc7, 0x0b, 0x4f, 0x1d,
+	0x8a, 0x51, 0xe0, 0x4b, 0xcd, 0xb4, 0x59, 0x31, 0xc8, 0x9f, 0x7e, 0xc9, 0xd9, 0x78, 0x73, 0x64,
+	0xea, 0xc5, 0xac, 0x83, 0x34, 0xd3, 0xeb, 0xc3, 0xc5, 0x81, 0xa0, 0xff, 0xfa, 0x13, 0x63, 0xeb,
+	0x17, 0x0d, 0xdd, 0x51, 0xb7, 0xf0, 0xda, 0x49, 0xd3, 0x16, 0x55, 0x26, 0x29, 0xd4, 0x68, 0x9e,
+	0x2b, 0x16, 0xbe, 0x58, 0x7d, 0x47, 0xa1, 0xfc, 0x8f, 0xf8, 0xb8, 0xd1, 0x7a, 0xd0, 0x31, 0xce,
+	0x45, 0xcb, 0x3a, 0x8f, 0x95, 0x16, 0x04, 0x28, 0xaf, 0xd7, 0xfb, 0xca, 0xbb, 0x4b, 0x40, 0x7e,
+}
+
+// xxh3Digest computes XXH3 over a stream. Like the reference implementation,
+// it holds back the last bytes written, since the final stripe is hashed
+// differently, and keeps the last consumed stripe for short tails.
+type xxh3Digest struct {
+	acc     [xxh3Accs]uint64
+	buf     [xxh3BufferSize]byte
+	n       int
+	stripes int
+	total   uint64
+}
+
+// newXXH3 returns a hash.Hash64 computing XXH3-64.
+func newXXH3() *xxh3Digest {
+	d := &xxh3Digest{}
+	d.Reset()
+	return d
+}
+
+// Reset restores the initial state.
+func (d *xxh3Digest) Reset() {
+	*d = xxh3Digest{acc: [xxh3Accs]uint64{
+		xxhPrime32_3, xxhPrime64_1, xxhPrime64_2, xxhPrime64_3,
+		xxhPrime64_4, xxhPrime32_2, xxhPrime64_5, xxhPrime32_1,
+	}}
+}
+
+// Size returns the number of bytes Sum appends.
+func (d *xxh3Digest) Size() int { return 8 }
+
+// BlockSize returns the size of the chunks data is consumed in.
+func (d *xxh3Digest) BlockSize() int { return xxh3StripeLen }
+
+// Write adds p to the hashed data. It never fails.
+func (d *xxh3Digest) Write(p []byte) (int, error) {
+	written := len(p)
+	d.total += uint64(written)
+	if d.n+len(p) <= xxh3BufferSize {
+		d.n += copy(d.buf[d.n:], p)
+		return written, nil
+	}
+
+	// The buffer is consumed only once more data follows it
+	if d.n > 0 {
+		k := copy(d.buf[d.n:], p)
+		p = p[k:]
+		d.consume(d.buf[:])
+		d.n = 0
+	}
+	if len(p) > xxh3BufferSize {
+		var block []byte
+		for len(p) > xxh3BufferSize {
+			block, p = p[:xxh3BufferSize], p[xxh3BufferSize:]
+			d.consume(block)
+		}
+		copy(d.buf[xxh3BufferSize-xxh3StripeLen:], block[xxh3BufferSize-xxh3StripeLen:])
+	}
+	d.n = copy(d.buf[:], p)
+	return written, nil
+}
+
+// consume accumulates the stripes of data, scrambling after every block.
+func (d *xxh3Digest) consume(data []byte) {
+	d.acc, d.stripes = xxh3Accumulate(d.acc, d.stripes, data)
+}
+
+// xxh3Accumulate accumulates the whole stripes of data into acc, where
+// stripes have been accumulated since the last scramble.
+func xxh3Accumulate(acc [xxh3Accs]uint64, stripes int, data []byte) ([xxh3Accs]uint64, int) {
+	for ; len(data) >= xxh3StripeLen; data = data[xxh3StripeLen:] {
+		acc = xxh3Accumulate512(acc, data, xxh3Secret[stripes*xxh3SecretRate:])
+		stripes++
+		if stripes == xxh3StripesPerBlock {
+			acc = xxh3Scramble(acc, xxh3Secret[len(xxh3Secret)-xxh3StripeLen:])
+			stripes = 0
+		}
+	}
+	return acc, stripes
+}
+
+// Sum appends the big-endian hash of the data written so far to b.
+func (d *xxh3Digest) Sum(b []byte) []byte {
+	return binary.BigEndian.AppendUint64(b, d.Sum64())
+}
+
+// Sum64 returns the hash of the data written so far.
+func (d *xxh3Digest) Sum64() uint64 {
+	if d.total <= xxh3MidSizeMax {
+		return xxh3Hash(d.buf[:d.total])
+	}
+	acc, stripes := d.acc, d.stripes
+	var last [xxh3StripeLen]byte
+	if d.n >= xxh3StripeLen {
+		acc, _ = xxh3Accumulate(acc, stripes, d.buf[:(d.n-1)/xxh3StripeLen*xxh3StripeLen])
+		copy(last[:], d.buf[d.n-xxh3StripeLen:d.n])
+	} else {
+		// The stripe reaches back into data consumed already
+		catchup := xxh3StripeLen - d.n
+		copy(last[:], d.buf[xxh3BufferSize-catchup:])
+		copy(last[catchup:], d.buf[:d.n])
+	}
+	acc = xxh3Accumulate512(acc, last[:], xxh3Secret[len(xxh3Secret)-xxh3StripeLen-7:])
+	return xxh3MergeAccs(acc, xxh3Secret[11:], d.total*xxhPrime64_1)
+}
+
+// xxh3Hash returns the XXH3-64 hash of input.
+func xxh3Hash(input []byte) uint64 {
+	d := newXXH3()
+	switch n := len(input); {
+	case n <= 16:
+		return xxh3Hash0to16(input)
+	case n <= 128:
+		return xxh3Hash17to128(input)
+	case n <= xxh3MidSizeMax:
+		return xxh3Hash129to240(input)
+	}
+	d.Write(input)
+	return d.Sum64()
+}
+
+func xxh3Hash0to16(input []byte) uint64 {
+	secret := xxh3Secret[:]
+	n := len(input)
+	switch {
+	case n > 8:
+		flip1 := read64(secret[24:]) ^ read64(secret[32:])
+		flip2 := read64(secret[40:]) ^ read64(secret[48:])
+		lo := read64(input) ^ flip1
+		hi := read64(input[n-8:]) ^ flip2
+		acc := uint64(n) + bits.ReverseBytes64(lo) + hi + mul128Fold64(lo, hi)
+		return xxh3Avalanche(acc)
+	case n >= 4:
+		in1 := uint64(binary.LittleEndian.Uint32(input))
+		in2 := uint64(binary.LittleEndian.Uint32(input[n-4:]))
+		flip := read64(secret[8:]) ^ read64(secret[16:])
+		keyed := (in2 + in1<<32) ^ flip
+		return xxh3StrongAvalanche(keyed, uint64(n))
+	case n > 0:
+		combo := uint32(input[0])<<16 | uint32(input[n>>1])<<24 | uint32(input[n-1]) | uint32(n)<<8
+		flip := uint64(binary.LittleEndian.Uint32(secret) ^ binary.LittleEndian.Uint32(secret[4:]))
+		return xxh64Avalanche(uint64(combo) ^ flip)
+	}
+	return xxh64Avalanche(read64(secret[56:]) ^ read64(secret[64:]))
+}
+
+func xxh3Hash17to128(input []byte) uint64 {
+	secret := xxh3Secret[:]
+	n := len(input)
+	acc := uint64(n) * xxhPrime64_1
+	if n > 32 {
+		if n > 64 {
+			if n > 96 {
+				acc += mix16(input[48:], secret[96:])
+				acc += mix16(input[n-64:], secret[112:])
+			}
+			acc += mix16(input[32:], secret[64:])
+			acc += mix16(input[n-48:], secret[80:])
+		}
+		acc += mix16(input[16:], secret[32:])
+		acc += mix16(input[n-32:], secret[48:])
+	}
+	acc += mix16(input, secret)
+	acc += mix16(input[n-16:], secret[16:])
+	return xxh3Avalanche(acc)
+}
+
+func xxh3Hash129to240(input []byte) uint64 {
+	const startOffset, lastOffset = 3, 17
+	secret := xxh3Secret[:]
+	n := len(input)
+	acc := uint64(n) * xxhPrime64_1
+	rounds := n / 16
+	for i := 0; i < 8; i++ {
+		acc += mix16(input[16*i:], secret[16*i:])
+	}
+	acc = xxh3Avalanche(acc)
+	for i := 8; i < rounds; i++ {
+		acc += mix16(input[16*i:], secret[16*(i-8)+startOffset:])
+	}
+	acc += mix16(input[n-16:], secret[xxh3SecretSizeMin-lastOffset:])
+	return xxh3Avalanche(acc)
+}
+
+func xxh3Accumulate512(acc [xxh3Accs]uint64, input, secret []byte) [xxh3Accs]uint64 {
+	for i := 0; i < xxh3Accs; i++ {
+		value := read64(input[8*i:])
+		key := value ^ read64(secret[8*i:])
+		acc[i^1] += value
+		acc[i] += (key & 0xFFFFFFFF) * (key >> 32)
+	}
+	return acc
+}
+
+func xxh3Scramble(acc [xxh3Accs]uint64, secret []byte) [xxh3Accs]uint64 {
+	for i := range acc {
+		value := acc[i] ^ acc[i]>>47 ^ read64(secret[8*i:])
+		acc[i] = value * xxhPrime32_1
+	}
+	return acc
+}
+
+func xxh3MergeAccs(acc [xxh3Accs]uint64, secret []byte, result uint64) uint64 {
+	for i := 0; i < 4; i++ {
+		result += mul128Fold64(acc[2*i]^read64(secret[16*i:]), acc[2*i+1]^read64(secret[16*i+8:]))
+	}
+	return xxh3Avalanche(result)
+}
+
+func mix16(input, secret []byte) uint64 {
+	return mul128Fold64(read64(input)^read64(secret), read64(input[8:])^read64(secret[8:]))
+}
+
+func mul128Fold64(a, b uint64) uint64 {
+	hi, lo := bits.Mul64(a, b)
+	return hi ^ lo
+}
+
+func xxh3Avalanche(h uint64) uint64 {
+	h ^= h >> 37
+	h *= 0x165667919E3779F9
+	return h ^ h>>32
+}
+
+func xxh3StrongAvalanche(h, n uint64) uint64 {
+	h ^= bits.RotateLeft64(h, 49) ^ bits.RotateLeft64(h, 24)
+	h *= 0x9FB21C651E98DF25
+	h ^= h>>35 + n
+	h *= 0x9FB21C651E98DF25
+	return h ^ h>>28
+}
+
+func xxh64Avalanche(h uint64) uint64 {
+	h ^= h >> 33
+	h *= xxhPrime64_2
+	h ^= h >> 29
+	h *= xxhPrime64_3
+	return h ^ h>>32
+}
+
+func read64(b []byte) uint64 {
+	return binary.LittleEndian.Uint64(b)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// xxh3Vectors are hashes of xxh3Input(n) from the reference implementation,
+// covering each input size class and the block and buffer boundaries.
+var xxh3Vectors = []struct {
+	n    int
+	hash uint64
+}{
+	{0, 0x2d06800538d394c2},
+	{1, 0x4c5cca45d0f4811f},
+	{3, 0x15f7093b173d005c},
+	{4, 0xdca012f95811b6b9},
+	{8, 0xdec6a9a43575982e},
+	{9, 0xcbe393399f17ffbd},
+	{16, 0x7e484c18d74895d0},
+	{17, 0x208bde5ee2bed407},
+	{32, 0x03df0ac5255d1446},
+	{33, 0x199a362122d71f46},
+	{64, 0xdd30702ab46b3745},
+	{65, 0xfab36b851b94ce20},
+	{96, 0xd245cd2541582982},
+	{97, 0x60e3e1d0d43785b3},
+	{128, 0xf92b70eaa21a6288},
+	{129, 0xf8f76713f2bb60fa},
+	{200, 0x12fdb864685f344d},
+	{240, 0xccc7375172c41f03},
+	{241, 0x0b3b630948ce4a00},
+	{255, 0x89932170686cdd9a},
+	{256, 0xec85b75bafe6ca74},
+	{257, 0x12ef0ff633841459},
+	{320, 0x73df0720aa2b40f3},
+	{1024, 0x23bc880ebf0d29c6},
+	{1025, 0xc09fdfbc398c7d82},
+	{1087, 0x3ab6ed4b5be06fcc},
+	{2048, 0x19f6f9c987331373},
+	{2049, 0x5045460f5d85c275},
+	{100000, 0xccf90df7e7e37036},
+}
+
+// xxh3Input returns n bytes of a repeating pattern.
+func xxh3Input(n int) []byte {
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(i*31 + 7)
+	}
+	return data
+}
+
+// TestXXH3 tests hashing whole inputs against the reference vectors.
+func TestXXH3(t *testing.T) {
+	for _, v := range xxh3Vectors {
+		if got := xxh3Hash(xxh3Input(v.n)); got != v.hash {
+			t.Errorf("xxh3Hash(%d bytes) = %016x, expected %016x", v.n, got, v.hash)
+		}
+	}
+}
+
+// TestXXH3_Streaming tests that writing the input in pieces of any size gives
+// the same hash, since files are hashed as they are read.
+func TestXXH3_Streaming(t *testing.T) {
+	for _, v := range xxh3Vectors {
+		for _, chunk := range []int{1, 7, 63, 64, 200, 256, 257, 4096} {
+			t.Run(fmt.Sprintf("%d/%d", v.n, chunk), func(t *testing.T) {
+				data := xxh3Input(v.n)
+				d := newXXH3()
+				for len(data) > 0 {
+					n := min(chunk, len(data))
+					d.Write(data[:n])
+					data = data[n:]
+				}
+				if got := d.Sum64(); got != v.hash {
+					t.Errorf("Sum64() = %016x, expected %016x", got, v.hash)
+				}
+				if got := fmt.Sprintf("%x", d.Sum(nil)); got != fmt.Sprintf("%016x", v.hash) {
+					t.Errorf("Sum() = %s, expected the big-endian hash", got)
+				}
+			})
+		}
+	}
+}
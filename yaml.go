@@ -0,0 +1,456 @@
+package main
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// writeYAML writes v as a YAML document with the same fields as its JSON
+// encoding: struct fields are named and left out by their json tags, and
+// values that marshal as text, such as times, are written as strings. Only
+// the kinds doppel's reports and plans use are supported.
+func writeYAML(w io.Writer, v any) error {
+	lines, block, err := yamlLines(reflect.ValueOf(v))
+	if err != nil {
+		return err
+	}
+	if !block {
+		lines = []string{lines[0]}
+	}
+	_, err = io.WriteString(w, strings.Join(lines, "\n")+"\n")
+	return err
+}
+
+// yamlLines returns the lines of v, unindented. block is false when v is a
+// scalar or an empty collection, which fits on the line of its key.
+func yamlLines(v reflect.Value) (lines []string, block bool, err error) {
+	if !v.IsValid() {
+		return []string{"null"}, false, nil
+	}
+	if m, ok := v.Interface().(encoding.TextMarshaler); ok && v.Kind() != reflect.Pointer {
+		text, err := m.MarshalText()
+		if err != nil {
+			return nil, false, err
+		}
+		return []string{yamlString(string(text))}, false, nil
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return []string{"null"}, false, nil
+		}
+		return yamlLines(v.Elem())
+	case reflect.String:
+		return []string{yamlString(v.String())}, false, nil
+	case reflect.Bool:
+		return []string{strconv.FormatBool(v.Bool())}, false, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return []string{strconv.FormatInt(v.Int(), 10)}, false, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return []string{strconv.FormatUint(v.Uint(), 10)}, false, nil
+	case reflect.Float32, reflect.Float64:
+		return []string{strconv.FormatFloat(v.Float(), 'f', -1, 64)}, false, nil
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return []string{"null"}, false, nil
+		}
+		if v.Len() == 0 {
+			return []string{"[]"}, false, nil
+		}
+		for i := 0; i < v.Len(); i++ {
+			item, _, err := yamlLines(v.Index(i))
+			if err != nil {
+				return nil, false, err
+			}
+			lines = append(lines, "- "+item[0])
+			for _, line := range item[1:] {
+				lines = append(lines, "  "+line)
+			}
+		}
+		return lines, true, nil
+
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, false, fmt.Errorf("yaml: unsupported map key type %s", v.Type().Key())
+		}
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		for _, k := range keys {
+			if lines, err = appendYAMLField(lines, k.String(), v.MapIndex(k)); err != nil {
+				return nil, false, err
+			}
+		}
+
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			if strings.Contains(opts, "omitempty") && v.Field(i).IsZero() {
+				continue
+			}
+			if lines, err = appendYAMLField(lines, name, v.Field(i)); err != nil {
+				return nil, false, err
+			}
+		}
+
+	default:
+		return nil, false, fmt.Errorf("yaml: unsupported type %s", v.Type())
+	}
+
+	if len(lines) == 0 {
+		return []string{"{}"}, false, nil
+	}
+	return lines, true, nil
+}
+
+// appendYAMLField appends the mapping entry of key and v to lines.
+func appendYAMLField(lines []string, key string, v reflect.Value) ([]string, error) {
+	value, block, err := yamlLines(v)
+	if err != nil {
+		return nil, err
+	}
+	key = yamlString(key)
+	if !block {
+		return append(lines, key+": "+value[0]), nil
+	}
+	lines = append(lines, key+":")
+	for _, line := range value {
+		lines = append(lines, "  "+line)
+	}
+	return lines, nil
+}
+
+// yamlString returns s as a YAML scalar: plain when it can't be read as
+// anything but that string, double-quoted otherwise.
+func yamlString(s string) string {
+	if yamlPlainSafe(s) {
+		return s
+	}
+	return strconv.Quote(s)
+}
+
+// yamlPlainSafe reports whether s can be written as a plain scalar. Words
+// that YAML 1.1 parsers such as Ansible's read as booleans are quoted.
+func yamlPlainSafe(s string) bool {
+	if s == "" || s != strings.TrimSpace(s) {
+		return false
+	}
+	switch strings.ToLower(s) {
+	case "y", "n", "yes", "no", "on", "off":
+		return false
+	}
+	for i, r := range s {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+		case strings.ContainsRune("_./()+", r):
+		case (r == '-' || r == ' ') && i > 0:
+		default:
+			return false
+		}
+	}
+	if _, ok := yamlPlainValue(s).(string); !ok {
+		return false
+	}
+	return true
+}
+
+// yamlPlainValue returns what a plain scalar stands for: nil, a bool, a
+// json.Number, or the string itself.
+func yamlPlainValue(s string) any {
+	switch strings.ToLower(s) {
+	case "null", "~":
+		return nil
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil && strings.ToLower(s) != "inf" && strings.ToLower(s) != "nan" {
+		return json.Number(s)
+	}
+	return s
+}
+
+// isYAMLPath reports whether path names a YAML file.
+func isYAMLPath(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// decodeJSONOrYAML decodes data into v as JSON if it starts with "{", or as
+// YAML otherwise, so files written either way can be read back.
+func decodeJSONOrYAML(data []byte, v any) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return json.Unmarshal(data, v)
+	}
+	return decodeYAML(data, v)
+}
+
+// decodeYAML decodes the block-style YAML written by writeYAML into v, which
+// is filled like json.Unmarshal would from the same document. Hand edits may
+// add comments, reindent, and quote with either kind of quotes; flow
+// collections other than [] and {}, multi-line scalars, anchors, and tags are
+// not supported.
+func decodeYAML(data []byte, v any) error {
+	p := &yamlParser{}
+	for i, text := range strings.Split(string(data), "\n") {
+		text = strings.TrimRight(stripYAMLComment(text), " \t\r")
+		if strings.TrimSpace(text) == "" || text == "---" {
+			continue
+		}
+		trimmed := strings.TrimLeft(text, " ")
+		if strings.HasPrefix(trimmed, "\t") {
+			return fmt.Errorf("yaml: line %d: tabs are not allowed in indentation", i+1)
+		}
+		p.lines = append(p.lines, yamlLine{number: i + 1, indent: len(text) - len(trimmed), text: trimmed})
+	}
+	if len(p.lines) == 0 {
+		return errors.New("yaml: empty document")
+	}
+	tree, err := p.block(p.lines[0].indent)
+	if err != nil {
+		return err
+	}
+	if p.pos < len(p.lines) {
+		return fmt.Errorf("yaml: line %d: unexpected indentation", p.lines[p.pos].number)
+	}
+	data, err = json.Marshal(tree)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// yamlLine is a line of a YAML document without its indentation and comment.
+type yamlLine struct {
+	number int
+	indent int
+	text   string
+}
+
+// yamlParser parses the lines of a document into maps, slices, and scalars.
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// block parses the mapping or sequence whose entries start at indent.
+func (p *yamlParser) block(indent int) (any, error) {
+	line := p.lines[p.pos]
+	if line.indent != indent {
+		return nil, fmt.Errorf("yaml: line %d: unexpected indentation", line.number)
+	}
+	if isYAMLItem(line.text) {
+		return p.sequence(indent)
+	}
+	return p.mapping(indent)
+}
+
+// sequence parses the items of a sequence at indent.
+func (p *yamlParser) sequence(indent int) (any, error) {
+	items := []any{}
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent != indent || !isYAMLItem(line.text) {
+			break
+		}
+		rest := strings.TrimLeft(strings.TrimPrefix(line.text, "-"), " ")
+		if rest == "" {
+			p.pos++
+			item, err := p.nested(indent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+			continue
+		}
+		if _, _, ok := splitYAMLKey(rest); ok || isYAMLItem(rest) {
+			// The first entry of a nested block shares the line of the dash
+			p.lines[p.pos] = yamlLine{number: line.number, indent: indent + len(line.text) - len(rest), text: rest}
+			item, err := p.block(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+			continue
+		}
+		item, err := yamlScalar(rest, line.number)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		p.pos++
+	}
+	return items, nil
+}
+
+// mapping parses the entries of a mapping at indent.
+func (p *yamlParser) mapping(indent int) (any, error) {
+	entries := map[string]any{}
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent != indent || isYAMLItem(line.text) {
+			break
+		}
+		key, value, ok := splitYAMLKey(line.text)
+		if !ok {
+			return nil, fmt.Errorf("yaml: line %d: expected a key", line.number)
+		}
+		if _, dup := entries[key]; dup {
+			return nil, fmt.Errorf("yaml: line %d: duplicate key %q", line.number, key)
+		}
+		p.pos++
+		var v any
+		var err error
+		if value != "" {
+			v, err = yamlScalar(value, line.number)
+		} else {
+			v, err = p.nested(indent)
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries[key] = v
+	}
+	return entries, nil
+}
+
+// nested parses the block under a key or dash at indent, which is null when
+// the next line is not indented further. A sequence may also sit at the
+// indentation of its key.
+func (p *yamlParser) nested(indent int) (any, error) {
+	if p.pos == len(p.lines) {
+		return nil, nil
+	}
+	next := p.lines[p.pos]
+	if next.indent > indent || (next.indent == indent && isYAMLItem(next.text) && !p.inSequence(indent)) {
+		return p.block(next.indent)
+	}
+	return nil, nil
+}
+
+// inSequence reports whether the line before the current one is an item of a
+// sequence at indent, whose next item is not a nested block.
+func (p *yamlParser) inSequence(indent int) bool {
+	prev := p.lines[p.pos-1]
+	return prev.indent == indent && isYAMLItem(prev.text)
+}
+
+// isYAMLItem reports whether text starts a sequence item.
+func isYAMLItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// splitYAMLKey splits a "key: value" entry. ok is false if text is not one.
+func splitYAMLKey(text string) (key, value string, ok bool) {
+	if strings.HasPrefix(text, `"`) || strings.HasPrefix(text, "'") {
+		end := yamlQuoteEnd(text)
+		if end < 0 || !strings.HasPrefix(text[end:], ":") {
+			return "", "", false
+		}
+		k, err := yamlScalar(text[:end], 0)
+		if err != nil {
+			return "", "", false
+		}
+		rest := text[end+1:]
+		if rest != "" && !strings.HasPrefix(rest, " ") {
+			return "", "", false
+		}
+		return k.(string), strings.TrimSpace(rest), true
+	}
+	if i := strings.Index(text, ": "); i > 0 {
+		return text[:i], strings.TrimSpace(text[i+2:]), true
+	}
+	if strings.HasSuffix(text, ":") && len(text) > 1 {
+		return text[:len(text)-1], "", true
+	}
+	return "", "", false
+}
+
+// yamlQuoteEnd returns the index after the closing quote of the quoted scalar
+// text starts with, or -1 if it isn't closed.
+func yamlQuoteEnd(text string) int {
+	quote := text[0]
+	for i := 1; i < len(text); i++ {
+		switch {
+		case quote == '"' && text[i] == '\\':
+			i++
+		case text[i] == quote && quote == '\'' && i+1 < len(text) && text[i+1] == '\'':
+			i++
+		case text[i] == quote:
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// stripYAMLComment removes a comment from line, leaving # inside quotes. A
+// quote only starts a quoted scalar at the start of a key or value, so the
+// apostrophe of a plain "it's" doesn't.
+func stripYAMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			if before := strings.TrimRight(line[:i], " "); before == "" || strings.HasSuffix(before, ":") || strings.HasSuffix(before, "-") {
+				quote = c
+			}
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// yamlScalar returns the value of a scalar on line number.
+func yamlScalar(text string, number int) (any, error) {
+	switch {
+	case text == "[]":
+		return []any{}, nil
+	case text == "{}":
+		return map[string]any{}, nil
+	case strings.HasPrefix(text, `"`):
+		s, err := strconv.Unquote(text)
+		if err != nil {
+			return nil, fmt.Errorf("yaml: line %d: invalid double-quoted string %s", number, text)
+		}
+		return s, nil
+	case strings.HasPrefix(text, "'"):
+		if yamlQuoteEnd(text) != len(text) {
+			return nil, fmt.Errorf("yaml: line %d: invalid single-quoted string %s", number, text)
+		}
+		return strings.ReplaceAll(text[1:len(text)-1], "''", "'"), nil
+	case strings.ContainsAny(text[:1], "[{|>&*!%@`"):
+		return nil, fmt.Errorf("yaml: line %d: unsupported value %s", number, text)
+	}
+	return yamlPlainValue(text), nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestWriteYAML tests that YAML output has the fields of the JSON encoding.
+func TestWriteYAML(t *testing.T) {
+	plan := &Plan{
+		Version: planVersion,
+		Created: time.Date(2024, 1, 30, 12, 0, 0, 0, time.UTC),
+		Dir:     "/notes",
+		Entries: []PlanEntry{
+			{Action: ActionKeep, Path: "/notes/todo (1).md", Size: 3, Hash: "aa", Group: "af021d6738113403"},
+			{Action: ActionDelete, Path: "/notes/it's: done #2.md", Size: 3, Hash: "aa", Target: "/notes/todo (1).md"},
+		},
+	}
+	var out bytes.Buffer
+	if err := writeYAML(&out, plan); err != nil {
+		t.Fatalf("writeYAML() returned error: %v", err)
+	}
+	want := `version: 1
+created: "2024-01-30T12:00:00Z"
+dir: /notes
+entries:
+  - action: keep
+    path: /notes/todo (1).md
+    size: 3
+    sha256: aa
+    group: af021d6738113403
+  - action: delete
+    path: "/notes/it's: done #2.md"
+    size: 3
+    sha256: aa
+    target: /notes/todo (1).md
+`
+	if out.String() != want {
+		t.Errorf("writeYAML() =\n%s\nexpected\n%s", out.String(), want)
+	}
+
+	var back Plan
+	if err := decodeYAML(out.Bytes(), &back); err != nil {
+		t.Fatalf("decodeYAML() returned error: %v", err)
+	}
+	if !reflect.DeepEqual(&back, plan) {
+		t.Errorf("decodeYAML() = %+v, expected %+v", back, *plan)
+	}
+}
+
+// TestYAMLString tests quoting strings that would read as something else.
+func TestYAMLString(t *testing.T) {
+	for s, want := range map[string]string{
+		"notes.md":    "notes.md",
+		"my notes.md": "my notes.md",
+		"true":        `"true"`,
+		"yes":         `"yes"`,
+		"2024":        `"2024"`,
+		"1.5":         `"1.5"`,
+		"":            `""`,
+		"- item":      `"- item"`,
+		"a: b":        `"a: b"`,
+		" padded":     `" padded"`,
+		"line\nbreak": `"line\nbreak"`,
+		"null":        `"null"`,
+	} {
+		if got := yamlString(s); got != want {
+			t.Errorf("yamlString(%q) = %s, expected %s", s, got, want)
+		}
+	}
+}
+
+// TestDecodeYAML_HandEdited tests reading a plan edited by hand.
+func TestDecodeYAML_HandEdited(t *testing.T) {
+	doc := `# Reviewed on Tuesday
+---
+version: 1
+dir: '/notes'
+entries:
+- action: keep   # the newer one
+  path: '/notes/it''s.md'
+  size: 3
+  sha256: "aa"
+-
+  action: delete
+  path: /notes/it's 2.md # old copy
+  size: 3
+  sha256: aa
+  target: "/notes/it's.md"
+`
+	var plan Plan
+	if err := decodeYAML([]byte(doc), &plan); err != nil {
+		t.Fatalf("decodeYAML() returned error: %v", err)
+	}
+	want := []PlanEntry{
+		{Action: ActionKeep, Path: "/notes/it's.md", Size: 3, Hash: "aa"},
+		{Action: ActionDelete, Path: "/notes/it's 2.md", Size: 3, Hash: "aa", Target: "/notes/it's.md"},
+	}
+	if plan.Version != 1 || plan.Dir != "/notes" || !reflect.DeepEqual(plan.Entries, want) {
+		t.Errorf("decodeYAML() = %+v", plan)
+	}
+
+	for name, doc := range map[string]string{
+		"empty":       "# nothing\n",
+		"flow":        "entries: [a, b]\n",
+		"no key":      "version: 1\njust text\n",
+		"indentation": "version: 1\n  dir: /notes\n",
+		"duplicate":   "version: 1\nversion: 2\n",
+		"tab":         "entries:\n\t- action: keep\n",
+		"type":        "version: one\n",
+	} {
+		if err := decodeYAML([]byte(doc), &plan); err == nil {
+			t.Errorf("decodeYAML() of %s should fail", name)
+		}
+	}
+}
+
+// TestDecodeJSONOrYAML tests reading files written in either format.
+func TestDecodeJSONOrYAML(t *testing.T) {
+	report := newJSONReport("/notes", []FileRecord{
+		{Group: 1, Path: "/notes/a.md", Size: 1, Hash: "aa", Leader: true},
+		{Group: 1, Path: "/notes/a-1.md", Size: 1, Hash: "aa", IdenticalToLeader: true},
+	})
+	var j, y bytes.Buffer
+	if err := json.NewEncoder(&j).Encode(report); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeYAML(&y, report); err != nil {
+		t.Fatal(err)
+	}
+	for _, data := range [][]byte{j.Bytes(), y.Bytes()} {
+		var got jsonReport
+		if err := decodeJSONOrYAML(data, &got); err != nil {
+			t.Fatalf("decodeJSONOrYAML() returned error: %v\n%s", err, data)
+		}
+		if !reflect.DeepEqual(got, report) {
+			t.Errorf("decodeJSONOrYAML() = %+v, expected %+v", got, report)
+		}
+	}
+	if !strings.Contains(y.String(), "    fingerprint: "+report.Groups[0].Fingerprint+"\n") {
+		t.Errorf("YAML report should have the group fingerprint:\n%s", y.String())
+	}
+}